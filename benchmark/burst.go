@@ -0,0 +1,206 @@
+package benchmark
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"mail-stress-test/config"
+)
+
+// BurstWindow is one baseline or burst window of a burst traffic run
+type BurstWindow struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Burst bool      `json:"burst"`
+}
+
+// BurstPhaseStats isolates the requests that started in one kind of window
+type BurstPhaseStats struct {
+	Requests          int64         `json:"requests"`
+	Errors            int64         `json:"errors"`
+	Duration          time.Duration `json:"duration"` // total time spent in this kind of window
+	RequestsPerSecond float64       `json:"requests_per_second"`
+	AvgResponseTime   time.Duration `json:"avg_response_time"`
+	P50ResponseTime   time.Duration `json:"p50_response_time"`
+	P95ResponseTime   time.Duration `json:"p95_response_time"`
+	P99ResponseTime   time.Duration `json:"p99_response_time"`
+}
+
+// BurstResult splits a burst traffic run into its burst and baseline windows
+type BurstResult struct {
+	BaselineRate int              `json:"baseline_rate"`
+	BurstRate    int              `json:"burst_rate"`
+	Windows      []BurstWindow    `json:"windows"`
+	Burst        *BurstPhaseStats `json:"burst"`
+	Baseline     *BurstPhaseStats `json:"baseline"`
+}
+
+// burstSchedule alternates between a baseline rate and a burst rate: each
+// period starts with a burst window of the configured duration
+type burstSchedule struct {
+	start         int64 // unix nanos
+	baselineRate  int
+	burstRate     int
+	burstDuration int64
+	period        int64
+}
+
+func newBurstSchedule(cfg config.BurstConfig, start time.Time) (*burstSchedule, error) {
+	if cfg.BurstRate <= 0 {
+		return nil, fmt.Errorf("burst rate must be positive, got %d", cfg.BurstRate)
+	}
+	if cfg.BaselineRate < 0 {
+		return nil, fmt.Errorf("baseline rate must not be negative, got %d", cfg.BaselineRate)
+	}
+	if cfg.BurstDuration <= 0 || cfg.BurstPeriod <= cfg.BurstDuration {
+		return nil, fmt.Errorf("burst duration (%s) must be positive and shorter than the burst period (%s)",
+			cfg.BurstDuration, cfg.BurstPeriod)
+	}
+	return &burstSchedule{
+		start:         start.UnixNano(),
+		baselineRate:  cfg.BaselineRate,
+		burstRate:     cfg.BurstRate,
+		burstDuration: int64(cfg.BurstDuration),
+		period:        int64(cfg.BurstPeriod),
+	}, nil
+}
+
+// inBurst reports whether t (unix nanos) falls in a burst window
+func (s *burstSchedule) inBurst(t int64) bool {
+	if t < s.start {
+		return false
+	}
+	return (t-s.start)%s.period < s.burstDuration
+}
+
+// slot returns the earliest time at or after t a request may be issued and
+// the interval to the next one. With a zero baseline rate, requests falling
+// in a baseline window are pushed to the start of the next burst.
+func (s *burstSchedule) slot(t int64) (int64, int64) {
+	if s.inBurst(t) {
+		return t, int64(time.Second) / int64(s.burstRate)
+	}
+	if s.baselineRate > 0 {
+		return t, int64(time.Second) / int64(s.baselineRate)
+	}
+
+	elapsed := t - s.start
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	next := s.start + (elapsed/s.period+1)*s.period
+	return next, int64(time.Second) / int64(s.burstRate)
+}
+
+// windows lists the burst and baseline windows between start and end
+func (s *burstSchedule) windows(end time.Time) []BurstWindow {
+	var windows []BurstWindow
+	stop := end.UnixNano()
+	for periodStart := s.start; periodStart < stop; periodStart += s.period {
+		burstEnd := min64(periodStart+s.burstDuration, stop)
+		windows = append(windows, BurstWindow{Start: time.Unix(0, periodStart), End: time.Unix(0, burstEnd), Burst: true})
+		if burstEnd < stop {
+			windows = append(windows, BurstWindow{
+				Start: time.Unix(0, burstEnd),
+				End:   time.Unix(0, min64(periodStart+s.period, stop)),
+			})
+		}
+	}
+	return windows
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// burstPhaseRecorder collects latencies of requests started in one kind of window
+type burstPhaseRecorder struct {
+	mu       sync.Mutex
+	latency  *streamingLatency
+	sum      time.Duration
+	requests int64
+	errors   int64
+}
+
+func newBurstPhaseRecorder(compression float64) *burstPhaseRecorder {
+	return &burstPhaseRecorder{latency: newStreamingLatency(compression)}
+}
+
+func (r *burstPhaseRecorder) record(duration time.Duration, failed bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.latency.add(duration)
+	r.sum += duration
+	r.requests++
+	if failed {
+		r.errors++
+	}
+}
+
+// stats summarizes the phase given the total time spent in its windows
+func (r *burstPhaseRecorder) stats(elapsed time.Duration) *BurstPhaseStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats := &BurstPhaseStats{Requests: r.requests, Errors: r.errors, Duration: elapsed}
+	if r.requests == 0 {
+		return stats
+	}
+	stats.AvgResponseTime = r.sum / time.Duration(r.requests)
+	stats.P50ResponseTime = r.latency.percentile(50)
+	stats.P95ResponseTime = r.latency.percentile(95)
+	stats.P99ResponseTime = r.latency.percentile(99)
+	if elapsed > 0 {
+		stats.RequestsPerSecond = float64(r.requests) / elapsed.Seconds()
+	}
+	return stats
+}
+
+// burstTracker classifies completed requests into burst and baseline phases
+type burstTracker struct {
+	schedule *burstSchedule
+	burst    *burstPhaseRecorder
+	baseline *burstPhaseRecorder
+}
+
+func newBurstTracker(schedule *burstSchedule, compression float64) *burstTracker {
+	return &burstTracker{
+		schedule: schedule,
+		burst:    newBurstPhaseRecorder(compression),
+		baseline: newBurstPhaseRecorder(compression),
+	}
+}
+
+// record attributes a request to the window it started in
+func (t *burstTracker) record(start time.Time, duration time.Duration, failed bool) {
+	if t.schedule.inBurst(start.UnixNano()) {
+		t.burst.record(duration, failed)
+	} else {
+		t.baseline.record(duration, failed)
+	}
+}
+
+// result builds the burst report for a run that ended at end
+func (t *burstTracker) result(end time.Time) *BurstResult {
+	windows := t.schedule.windows(end)
+	var burstTime, baselineTime time.Duration
+	for _, w := range windows {
+		if w.Burst {
+			burstTime += w.End.Sub(w.Start)
+		} else {
+			baselineTime += w.End.Sub(w.Start)
+		}
+	}
+
+	return &BurstResult{
+		BaselineRate: t.schedule.baselineRate,
+		BurstRate:    t.schedule.burstRate,
+		Windows:      windows,
+		Burst:        t.burst.stats(burstTime),
+		Baseline:     t.baseline.stats(baselineTime),
+	}
+}
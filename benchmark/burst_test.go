@@ -0,0 +1,121 @@
+package benchmark
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"mail-stress-test/config"
+	"mail-stress-test/models"
+)
+
+// testBurst is a 100ms burst at the start of every 300ms period
+var testBurst = config.BurstConfig{
+	Enabled:       true,
+	BaselineRate:  20,
+	BurstRate:     500,
+	BurstDuration: 100 * time.Millisecond,
+	BurstPeriod:   300 * time.Millisecond,
+}
+
+func TestBurstScheduleWindows(t *testing.T) {
+	start := time.Unix(0, 0)
+	cfg := testBurst
+	cfg.BaselineRate = 0
+	schedule, err := newBurstSchedule(cfg, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for at, want := range map[time.Duration]bool{0: true, 99 * time.Millisecond: true, 100 * time.Millisecond: false, 299 * time.Millisecond: false, 350 * time.Millisecond: true} {
+		if got := schedule.inBurst(int64(at)); got != want {
+			t.Errorf("inBurst(%s) = %v, want %v", at, got, want)
+		}
+	}
+	// An idle baseline pushes the request to the next burst
+	if slot, interval := schedule.slot(int64(150 * time.Millisecond)); slot != int64(300*time.Millisecond) || interval != int64(2*time.Millisecond) {
+		t.Errorf("slot(150ms) = %s every %s, want 300ms every 2ms", time.Duration(slot), time.Duration(interval))
+	}
+
+	windows := schedule.windows(start.Add(700 * time.Millisecond))
+	want := []struct {
+		start, end time.Duration
+		burst      bool
+	}{{0, 100, true}, {100, 300, false}, {300, 400, true}, {400, 600, false}, {600, 700, true}}
+	if len(windows) != len(want) {
+		t.Fatalf("windows = %+v, want %d", windows, len(want))
+	}
+	for i, w := range want {
+		got := windows[i]
+		if got.Start.Sub(start) != w.start*time.Millisecond || got.End.Sub(start) != w.end*time.Millisecond || got.Burst != w.burst {
+			t.Errorf("window %d = %s-%s burst=%v, want %dms-%dms burst=%v",
+				i, got.Start.Sub(start), got.End.Sub(start), got.Burst, w.start, w.end, w.burst)
+		}
+	}
+}
+
+func TestBurstScheduleRejectsBadConfig(t *testing.T) {
+	for _, cfg := range []config.BurstConfig{
+		{BurstRate: 0, BurstDuration: time.Second, BurstPeriod: 2 * time.Second},
+		{BurstRate: 10, BaselineRate: -1, BurstDuration: time.Second, BurstPeriod: 2 * time.Second},
+		{BurstRate: 10, BurstDuration: 2 * time.Second, BurstPeriod: 2 * time.Second},
+	} {
+		if _, err := newBurstSchedule(cfg, time.Now()); err == nil {
+			t.Errorf("newBurstSchedule(%+v) accepted", cfg)
+		}
+	}
+}
+
+// TestBurstTrackerSplitsLatency records slow requests started in burst
+// windows and fast ones in baseline windows and checks each phase only sees
+// its own latencies
+func TestBurstTrackerSplitsLatency(t *testing.T) {
+	start := time.Now()
+	schedule, err := newBurstSchedule(testBurst, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker := newBurstTracker(schedule, 100)
+	for i := 0; i < 50; i++ {
+		tracker.record(start.Add(time.Duration(i)*time.Millisecond), 40*time.Millisecond, i%10 == 0)
+		tracker.record(start.Add(150*time.Millisecond+time.Duration(i)*time.Millisecond), 2*time.Millisecond, false)
+	}
+
+	result := tracker.result(start.Add(300 * time.Millisecond))
+	if result.Burst.Requests != 50 || result.Burst.Errors != 5 || result.Baseline.Requests != 50 || result.Baseline.Errors != 0 {
+		t.Errorf("burst %d requests/%d errors, baseline %d/%d; want 50/5 and 50/0",
+			result.Burst.Requests, result.Burst.Errors, result.Baseline.Requests, result.Baseline.Errors)
+	}
+	if result.Burst.P95ResponseTime != 40*time.Millisecond || result.Baseline.P95ResponseTime != 2*time.Millisecond {
+		t.Errorf("P95 burst %s, baseline %s; want 40ms and 2ms", result.Burst.P95ResponseTime, result.Baseline.P95ResponseTime)
+	}
+	if result.Burst.Duration != 100*time.Millisecond || result.Baseline.Duration != 200*time.Millisecond {
+		t.Errorf("time in burst %s, baseline %s; want 100ms and 200ms", result.Burst.Duration, result.Baseline.Duration)
+	}
+}
+
+// TestBurstRun drives a short run with the burst pattern and checks the
+// offered rate rises during bursts and every request is attributed to one
+// of the two phases
+func TestBurstRun(t *testing.T) {
+	h := &fakeHandler{create: func(ctx context.Context, req *models.MailRequest) error { return nil }}
+	st, cfg := newTestStressTest(t, h)
+	cfg.StressTest.Duration = 600 * time.Millisecond
+	cfg.StressTest.Burst = testBurst
+
+	result, err := st.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Burst == nil {
+		t.Fatal("no burst result")
+	}
+	burst, baseline := result.Burst.Burst, result.Burst.Baseline
+	if burst.Requests+baseline.Requests != result.TotalRequests {
+		t.Errorf("%d burst + %d baseline requests, want the %d issued", burst.Requests, baseline.Requests, result.TotalRequests)
+	}
+	if burst.RequestsPerSecond < 5*baseline.RequestsPerSecond {
+		t.Errorf("burst rate %.0f rps, baseline %.0f rps; want bursts far above the baseline",
+			burst.RequestsPerSecond, baseline.RequestsPerSecond)
+	}
+}
@@ -0,0 +1,126 @@
+package benchmark
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ComparisonSummary is the structured outcome of a search strategy comparison
+type ComparisonSummary struct {
+	FastestAvg         string            `json:"fastest_avg"`
+	FastestAvgDuration time.Duration     `json:"fastest_avg_duration"`
+	FastestP99         string            `json:"fastest_p99"`
+	FastestP99Duration time.Duration     `json:"fastest_p99_duration"`
+	MostReliable       string            `json:"most_reliable"`
+	HighestSuccessRate float64           `json:"highest_success_rate"` // percent
+	Ranking            []StrategyRanking `json:"ranking"`
+
+	// ArchiveOverhead is set when the archive_union strategy ran next to regex
+	ArchiveOverhead *ArchiveOverhead `json:"archive_overhead,omitempty"`
+}
+
+// ArchiveOverhead is the cost of searching hot and archive collections
+// together, relative to the same regex search on the hot collection only
+type ArchiveOverhead struct {
+	HotAvg          time.Duration `json:"hot_avg"`
+	UnionAvg        time.Duration `json:"union_avg"`
+	Overhead        time.Duration `json:"overhead"`
+	OverheadPercent float64       `json:"overhead_percent"`
+}
+
+// StrategyRanking is one strategy's position when ranked by average latency
+type StrategyRanking struct {
+	Rank        int           `json:"rank"`
+	Strategy    string        `json:"strategy"`
+	AvgDuration time.Duration `json:"avg_duration"`
+	P99Duration time.Duration `json:"p99_duration"`
+	SuccessRate float64       `json:"success_rate"` // percent
+}
+
+// SummarizeComparison picks the winners among strategies that answered at
+// least one query. Ties are broken by strategy name so the result is stable.
+func SummarizeComparison(results map[string]*SearchBenchmarkResult) *ComparisonSummary {
+	summary := &ComparisonSummary{Ranking: make([]StrategyRanking, 0, len(results))}
+
+	for name, result := range results {
+		if result.SuccessQueries == 0 {
+			continue
+		}
+		summary.Ranking = append(summary.Ranking, StrategyRanking{
+			Strategy:    name,
+			AvgDuration: result.AvgDuration,
+			P99Duration: result.P99Duration,
+			SuccessRate: float64(result.SuccessQueries) / float64(result.TotalQueries) * 100,
+		})
+	}
+
+	sort.Slice(summary.Ranking, func(i, j int) bool {
+		a, b := summary.Ranking[i], summary.Ranking[j]
+		if a.AvgDuration != b.AvgDuration {
+			return a.AvgDuration < b.AvgDuration
+		}
+		return a.Strategy < b.Strategy
+	})
+
+	for i := range summary.Ranking {
+		ranked := &summary.Ranking[i]
+		ranked.Rank = i + 1
+
+		if i == 0 {
+			summary.FastestAvg, summary.FastestAvgDuration = ranked.Strategy, ranked.AvgDuration
+			summary.FastestP99, summary.FastestP99Duration = ranked.Strategy, ranked.P99Duration
+			summary.MostReliable, summary.HighestSuccessRate = ranked.Strategy, ranked.SuccessRate
+			continue
+		}
+		if ranked.P99Duration < summary.FastestP99Duration {
+			summary.FastestP99, summary.FastestP99Duration = ranked.Strategy, ranked.P99Duration
+		}
+		if ranked.SuccessRate > summary.HighestSuccessRate {
+			summary.MostReliable, summary.HighestSuccessRate = ranked.Strategy, ranked.SuccessRate
+		}
+	}
+
+	hot, union := results["regex"], results["archive_union"]
+	if hot != nil && union != nil && hot.SuccessQueries > 0 && union.SuccessQueries > 0 {
+		overhead := &ArchiveOverhead{
+			HotAvg:   hot.AvgDuration,
+			UnionAvg: union.AvgDuration,
+			Overhead: union.AvgDuration - hot.AvgDuration,
+		}
+		if hot.AvgDuration > 0 {
+			overhead.OverheadPercent = float64(overhead.Overhead) / float64(hot.AvgDuration) * 100
+		}
+		summary.ArchiveOverhead = overhead
+	}
+
+	return summary
+}
+
+// String renders the summary as the human-readable comparison report
+func (s *ComparisonSummary) String() string {
+	report := "\n=== Search Strategy Comparison Report ===\n\n"
+
+	report += fmt.Sprintf("🏆 Fastest Average: %s (%s)\n", s.FastestAvg, s.FastestAvgDuration)
+	report += fmt.Sprintf("🏆 Fastest P99: %s (%s)\n", s.FastestP99, s.FastestP99Duration)
+	report += fmt.Sprintf("🏆 Most Reliable: %s (%.1f%% success)\n\n", s.MostReliable, s.HighestSuccessRate)
+
+	report += "Ranking (by average latency):\n"
+	for _, ranked := range s.Ranking {
+		report += fmt.Sprintf("  %d. %s: Avg %s, P99 %s, %.1f%% success\n",
+			ranked.Rank, ranked.Strategy, ranked.AvgDuration, ranked.P99Duration, ranked.SuccessRate)
+	}
+	report += "\n"
+
+	if o := s.ArchiveOverhead; o != nil {
+		report += fmt.Sprintf("🗄️  Archive union: Avg %s vs hot-only regex %s (%+.1f%%, %s)\n\n",
+			o.UnionAvg, o.HotAvg, o.OverheadPercent, o.Overhead)
+	}
+
+	report += "Recommendations:\n"
+	report += fmt.Sprintf("  • For best average performance: Use '%s'\n", s.FastestAvg)
+	report += fmt.Sprintf("  • For consistent latency: Use '%s'\n", s.FastestP99)
+	report += fmt.Sprintf("  • For reliability: Use '%s'\n", s.MostReliable)
+
+	return report
+}
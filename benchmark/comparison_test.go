@@ -0,0 +1,65 @@
+package benchmark
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSummarizeComparisonWinners crafts results where a different strategy
+// wins each category and checks the summary names each winner
+func TestSummarizeComparisonWinners(t *testing.T) {
+	ms := time.Millisecond
+	results := map[string]*SearchBenchmarkResult{
+		// Fastest on average, but a heavy tail and some failures
+		"text_search": {AvgDuration: 2 * ms, P99Duration: 90 * ms, TotalQueries: 100, SuccessQueries: 90},
+		// Best tail
+		"index_optimized": {AvgDuration: 5 * ms, P99Duration: 8 * ms, TotalQueries: 100, SuccessQueries: 95},
+		// Slow but never fails
+		"regex": {AvgDuration: 20 * ms, P99Duration: 40 * ms, TotalQueries: 100, SuccessQueries: 100},
+		// Answered nothing: left out of the ranking
+		"hybrid": {TotalQueries: 100, FailedQueries: 100},
+	}
+
+	summary := SummarizeComparison(results)
+	if summary.FastestAvg != "text_search" || summary.FastestAvgDuration != 2*ms {
+		t.Errorf("fastest avg = %s (%s), want text_search (2ms)", summary.FastestAvg, summary.FastestAvgDuration)
+	}
+	if summary.FastestP99 != "index_optimized" || summary.FastestP99Duration != 8*ms {
+		t.Errorf("fastest P99 = %s (%s), want index_optimized (8ms)", summary.FastestP99, summary.FastestP99Duration)
+	}
+	if summary.MostReliable != "regex" || summary.HighestSuccessRate != 100 {
+		t.Errorf("most reliable = %s (%.1f%%), want regex (100%%)", summary.MostReliable, summary.HighestSuccessRate)
+	}
+
+	want := []string{"text_search", "index_optimized", "regex"}
+	if len(summary.Ranking) != len(want) {
+		t.Fatalf("ranking = %+v, want %v", summary.Ranking, want)
+	}
+	for i, name := range want {
+		if got := summary.Ranking[i]; got.Strategy != name || got.Rank != i+1 {
+			t.Errorf("rank %d = %s (rank %d), want %s", i+1, got.Strategy, got.Rank, name)
+		}
+	}
+
+	report := summary.String()
+	for _, line := range []string{"Fastest Average: text_search", "Fastest P99: index_optimized", "Most Reliable: regex"} {
+		if !strings.Contains(report, line) {
+			t.Errorf("report is missing %q", line)
+		}
+	}
+}
+
+// TestSummarizeComparisonTies checks equal averages rank by strategy name
+func TestSummarizeComparisonTies(t *testing.T) {
+	results := map[string]*SearchBenchmarkResult{
+		"regex":       {AvgDuration: time.Millisecond, P99Duration: time.Millisecond, TotalQueries: 1, SuccessQueries: 1},
+		"aggregation": {AvgDuration: time.Millisecond, P99Duration: time.Millisecond, TotalQueries: 1, SuccessQueries: 1},
+	}
+	for i := 0; i < 10; i++ {
+		summary := SummarizeComparison(results)
+		if summary.FastestAvg != "aggregation" || summary.FastestP99 != "aggregation" || summary.MostReliable != "aggregation" {
+			t.Fatalf("tied winners = %s/%s/%s, want aggregation for all", summary.FastestAvg, summary.FastestP99, summary.MostReliable)
+		}
+	}
+}
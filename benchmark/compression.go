@@ -0,0 +1,150 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"mail-stress-test/database"
+	"mail-stress-test/generator"
+	"mail-stress-test/models"
+)
+
+// Defaults for the compression benchmark when unconfigured
+const (
+	defaultCompressionWrites      = 1000
+	defaultCompressionContentSize = 16 * 1024
+)
+
+// CompressionRun is the write latency and storage of one block compressor
+type CompressionRun struct {
+	Compressor       string        `json:"compressor"`
+	Applied          string        `json:"applied,omitempty"` // compressor reported by the server
+	Writes           int           `json:"writes"`
+	Failed           int           `json:"failed"`
+	AvgLatency       time.Duration `json:"avg_latency"`
+	P95Latency       time.Duration `json:"p95_latency"`
+	P99Latency       time.Duration `json:"p99_latency"`
+	DataSizeBytes    int64         `json:"data_size_bytes"`
+	StorageSizeBytes int64         `json:"storage_size_bytes"`
+	Ratio            float64       `json:"ratio"` // data size / storage size
+	Error            string        `json:"error,omitempty"`
+}
+
+// CompressionResult compares compressors on an identical write workload
+type CompressionResult struct {
+	Writes      int               `json:"writes"`
+	ContentSize int               `json:"content_size"`
+	Runs        []*CompressionRun `json:"runs"`
+}
+
+// BenchmarkCompression inserts the same n mails with large bodies into a
+// scratch collection per compressor and reports insert latency and on-disk
+// size for each. Scratch collections are dropped afterwards.
+func BenchmarkCompression(ctx context.Context, db *database.MongoDB, gen *generator.DataGenerator, compressors []string, n, contentSize int) (*CompressionResult, error) {
+	if len(compressors) == 0 {
+		compressors = database.Compressors
+	}
+	if n <= 0 {
+		n = defaultCompressionWrites
+	}
+	if contentSize <= 0 {
+		contentSize = defaultCompressionContentSize
+	}
+
+	mails := compressionWorkload(gen, n, contentSize)
+	result := &CompressionResult{Writes: n, ContentSize: contentSize}
+
+	fmt.Printf("\n=== Compression Benchmark (%d writes of ~%d byte bodies) ===\n", n, contentSize)
+	suffix := time.Now().UnixNano()
+	for _, compressor := range compressors {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		collection := fmt.Sprintf("%s_compression_%s_%d", db.MailsCollection, compressor, suffix)
+		result.Runs = append(result.Runs, runCompression(ctx, db, collection, compressor, mails))
+	}
+	return result, nil
+}
+
+// compressionWorkload builds n mails whose content is roughly contentSize
+// bytes of repeated mail prose with unique tokens, compressible like real
+// mail bodies. Every compressor inserts these same documents.
+func compressionWorkload(gen *generator.DataGenerator, n, contentSize int) []models.Mail {
+	mails := make([]models.Mail, n)
+	for i := range mails {
+		mail := gen.GenerateMail()
+		var content strings.Builder
+		for content.Len() < contentSize {
+			fmt.Fprintf(&content, "%s [ref %08x] ", mail.Content, rand.Uint32())
+		}
+		mail.Content = content.String()[:contentSize]
+		mails[i] = *mail
+	}
+	return mails
+}
+
+// runCompression inserts mails into a new collection using compressor
+func runCompression(ctx context.Context, db *database.MongoDB, collection, compressor string, mails []models.Mail) *CompressionRun {
+	run := &CompressionRun{Compressor: compressor, Writes: len(mails)}
+	if err := db.CreateCompressedCollection(ctx, collection, compressor); err != nil {
+		run.Error = err.Error()
+		fmt.Printf("  %s: %v\n", compressor, err)
+		return run
+	}
+	coll := db.Database.Collection(collection)
+	defer coll.Drop(context.Background())
+
+	if applied, err := db.BlockCompressor(ctx, collection); err == nil {
+		run.Applied = applied
+	}
+
+	durations := make([]time.Duration, 0, len(mails))
+	for i := range mails {
+		start := time.Now()
+		if _, err := coll.InsertOne(ctx, &mails[i]); err != nil {
+			run.Failed++
+			continue
+		}
+		durations = append(durations, time.Since(start))
+	}
+	run.AvgLatency = averageDuration(durations)
+	run.P95Latency = calculatePercentile(durations, 95)
+	run.P99Latency = calculatePercentile(durations, 99)
+
+	// Storage size only reflects compression once blocks reach disk
+	if err := db.Checkpoint(ctx); err != nil {
+		fmt.Printf("  %s: %v; storage size may be understated\n", compressor, err)
+	}
+	stats, err := db.CollectionStats(ctx, collection)
+	if err != nil {
+		run.Error = err.Error()
+		return run
+	}
+	run.DataSizeBytes = stats.SizeBytes
+	run.StorageSizeBytes = stats.StorageSizeBytes
+	if stats.StorageSizeBytes > 0 {
+		run.Ratio = float64(stats.SizeBytes) / float64(stats.StorageSizeBytes)
+	}
+	fmt.Printf("  %s: avg %s, P95 %s, %d -> %d bytes on disk\n",
+		compressor, run.AvgLatency, run.P95Latency, run.DataSizeBytes, run.StorageSizeBytes)
+	return run
+}
+
+// String renders one row per compressor
+func (r *CompressionResult) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-8s %12s %12s %12s %14s %14s %7s %7s\n", "Codec", "Avg", "P95", "P99", "Data bytes", "Disk bytes", "Ratio", "Failed")
+	for _, run := range r.Runs {
+		if run.Error != "" {
+			fmt.Fprintf(&b, "%-8s %s\n", run.Compressor, run.Error)
+			continue
+		}
+		fmt.Fprintf(&b, "%-8s %12s %12s %12s %14d %14d %6.2fx %7d\n",
+			run.Compressor, run.AvgLatency, run.P95Latency, run.P99Latency,
+			run.DataSizeBytes, run.StorageSizeBytes, run.Ratio, run.Failed)
+	}
+	return b.String()
+}
@@ -0,0 +1,70 @@
+package benchmark
+
+import (
+	"context"
+	"testing"
+
+	"mail-stress-test/database"
+	"mail-stress-test/generator"
+	"mail-stress-test/internal/mongotest"
+)
+
+func TestCompressionWorkload(t *testing.T) {
+	gen, err := generator.NewDataGenerator([]string{"user-1", "user-2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mails := compressionWorkload(gen, 20, 4096)
+	if len(mails) != 20 {
+		t.Fatalf("%d mails, want 20", len(mails))
+	}
+	for i, mail := range mails {
+		if len(mail.Content) != 4096 {
+			t.Errorf("mail %d content is %d bytes, want 4096", i, len(mail.Content))
+		}
+	}
+	if mails[0].Content == mails[1].Content {
+		t.Error("two mails share a body, want unique tokens in each")
+	}
+}
+
+// TestCompressionIntegration writes the same workload uncompressed and with
+// zstd and checks each collection was created with its compressor and zstd
+// stores the same data in less space
+func TestCompressionIntegration(t *testing.T) {
+	mdb := mongotest.Database(t)
+	db := &database.MongoDB{
+		Client:            mdb.Client(),
+		Database:          mdb,
+		MailsCollection:   database.DefaultMailsCollection,
+		ThreadsCollection: database.DefaultThreadsCollection,
+	}
+	gen, err := generator.NewDataGenerator([]string{"user-1", "user-2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := BenchmarkCompression(context.Background(), db, gen, []string{"none", "zstd"}, 300, 16*1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Runs) != 2 {
+		t.Fatalf("%d runs, want 2", len(result.Runs))
+	}
+	none, zstd := result.Runs[0], result.Runs[1]
+	for _, run := range result.Runs {
+		if run.Error != "" || run.Failed != 0 {
+			t.Fatalf("%s: error %q, %d failed writes", run.Compressor, run.Error, run.Failed)
+		}
+		if run.Applied != run.Compressor {
+			t.Errorf("%s run created a collection compressed with %q", run.Compressor, run.Applied)
+		}
+	}
+	if none.DataSizeBytes != zstd.DataSizeBytes {
+		t.Errorf("data sizes %d and %d, want the same workload in both", none.DataSizeBytes, zstd.DataSizeBytes)
+	}
+	if zstd.StorageSizeBytes >= none.StorageSizeBytes || zstd.Ratio <= none.Ratio {
+		t.Errorf("zstd uses %d bytes (%.2fx), uncompressed %d bytes (%.2fx); want zstd smaller",
+			zstd.StorageSizeBytes, zstd.Ratio, none.StorageSizeBytes, none.Ratio)
+	}
+}
@@ -0,0 +1,47 @@
+package benchmark
+
+import (
+	"context"
+	"sync"
+)
+
+// Phase runs one part of the test, e.g. the stress test or the search
+// benchmark, keeping its own result so metrics stay isolated when phases
+// run concurrently
+type Phase func(ctx context.Context) error
+
+// RunPhases runs the stress and search phases, skipping a nil one. With
+// concurrent and both set they run at the same time and both finish before
+// the first error, stress before search, is returned; otherwise they run in
+// turn and a failed stress phase skips the search.
+func RunPhases(ctx context.Context, stress, search Phase, concurrent bool) error {
+	if concurrent && stress != nil && search != nil {
+		var wg sync.WaitGroup
+		var stressErr, searchErr error
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			stressErr = stress(ctx)
+		}()
+		go func() {
+			defer wg.Done()
+			searchErr = search(ctx)
+		}()
+		wg.Wait()
+
+		if stressErr != nil {
+			return stressErr
+		}
+		return searchErr
+	}
+
+	for _, phase := range []Phase{stress, search} {
+		if phase == nil {
+			continue
+		}
+		if err := phase(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
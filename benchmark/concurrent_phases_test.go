@@ -0,0 +1,122 @@
+package benchmark
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"mail-stress-test/config"
+
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// TestConcurrentPhases runs the stress test and the search benchmark through
+// RunPhases at the same time over one shared generator, as
+// -concurrent-phases does, and checks both produce their own results. Run
+// with -race.
+func TestConcurrentPhases(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("stress and search", func(mt *mtest.T) {
+		cfg := config.DefaultConfig()
+		cfg.Benchmark.Iterations = 200
+		strategy := &recordingStrategy{name: "a"}
+		sb := newTestSearchBenchmark(mt, cfg, strategy)
+		st := NewStressTest(cfg, sb.generator, &fakeHandler{})
+		cfg.StressTest.ConcurrentWorkers = 4
+		cfg.StressTest.RequestRate = 0
+		cfg.StressTest.Duration = 200 * time.Millisecond
+		cfg.StressTest.Operations = config.Operations{CreateMailWeight: 50, SearchWeight: 50}
+
+		var stressResult *StressTestResult
+		var searchResults map[string]*SearchBenchmarkResult
+		err := RunPhases(context.Background(),
+			func(ctx context.Context) (err error) {
+				stressResult, err = st.Run(ctx)
+				return err
+			},
+			func(ctx context.Context) (err error) {
+				searchResults, err = sb.Run(ctx)
+				return err
+			},
+			true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stressResult.TotalRequests == 0 || stressResult.FailedRequests != 0 {
+			t.Errorf("stress result: %d requests, %d failed", stressResult.TotalRequests, stressResult.FailedRequests)
+		}
+		if got := searchResults["a"]; got == nil || got.TotalQueries != cfg.Benchmark.Iterations {
+			t.Errorf("search result = %+v, want %d queries", got, cfg.Benchmark.Iterations)
+		}
+		// The stress test's searches went to its handler, not the strategy
+		if len(strategy.requests) != cfg.Benchmark.Iterations+1 {
+			t.Errorf("strategy served %d requests, want %d", len(strategy.requests), cfg.Benchmark.Iterations+1)
+		}
+	})
+}
+
+// TestRunPhasesOverlap has each phase wait for the other to start, which
+// only finishes when they run at the same time
+func TestRunPhasesOverlap(t *testing.T) {
+	stressStarted, searchStarted := make(chan struct{}), make(chan struct{})
+	waitFor := func(started, other chan struct{}) Phase {
+		return func(ctx context.Context) error {
+			close(started)
+			select {
+			case <-other:
+				return nil
+			case <-time.After(5 * time.Second):
+				return errors.New("phases ran one after the other")
+			}
+		}
+	}
+	if err := RunPhases(context.Background(), waitFor(stressStarted, searchStarted), waitFor(searchStarted, stressStarted), true); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestRunPhasesOrder checks the phases run stress first when not concurrent,
+// a nil phase is skipped, and which error wins
+func TestRunPhasesOrder(t *testing.T) {
+	stressErr, searchErr := errors.New("stress failed"), errors.New("search failed")
+	var ran []string
+	phase := func(name string, err error) Phase {
+		return func(context.Context) error {
+			ran = append(ran, name)
+			return err
+		}
+	}
+	tests := []struct {
+		name           string
+		stress, search Phase
+		concurrent     bool
+		wantRan        string
+		wantErr        error
+	}{
+		{"sequential", phase("stress", nil), phase("search", nil), false, "[stress search]", nil},
+		{"stress only", phase("stress", nil), nil, true, "[stress]", nil},
+		{"search only", nil, phase("search", nil), true, "[search]", nil},
+		{"stress failure skips search", phase("stress", stressErr), phase("search", nil), false, "[stress]", stressErr},
+		{"search failure", phase("stress", nil), phase("search", searchErr), false, "[stress search]", searchErr},
+	}
+	for _, tt := range tests {
+		ran = nil
+		err := RunPhases(context.Background(), tt.stress, tt.search, tt.concurrent)
+		if fmt.Sprint(ran) != tt.wantRan || err != tt.wantErr {
+			t.Errorf("%s: ran %v, err %v; want %s, %v", tt.name, ran, err, tt.wantRan, tt.wantErr)
+		}
+	}
+
+	// Concurrent phases both finish; the stress error is reported first
+	var searchRan bool
+	err := RunPhases(context.Background(),
+		func(context.Context) error { return stressErr },
+		func(context.Context) error { searchRan = true; return searchErr },
+		true)
+	if err != stressErr || !searchRan {
+		t.Errorf("concurrent failures: err %v, search ran %v; want %v after both finished", err, searchRan, stressErr)
+	}
+}
@@ -0,0 +1,97 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"mail-stress-test/database"
+	"mail-stress-test/generator"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CountResult holds the latency of per-user counts run one way
+type CountResult struct {
+	Method     string        `json:"method"`
+	Queries    int           `json:"queries"`
+	Failed     int           `json:"failed"`
+	AvgLatency time.Duration `json:"avg_latency"`
+	P95Latency time.Duration `json:"p95_latency"`
+	AvgCount   float64       `json:"avg_count"`
+}
+
+// CountComparison compares counting a user's mails through the userId
+// index (a covered COUNT_SCAN) with the same count as a collection scan
+type CountComparison struct {
+	Queries        int          `json:"queries"`
+	Covered        *CountResult `json:"covered"`
+	CollectionScan *CountResult `json:"collection_scan"`
+	Speedup        float64      `json:"speedup"` // collection scan avg / covered avg
+}
+
+// CompareCount counts n random users' mails with the userId index hinted
+// and then with a $natural hint that forces a collection scan
+func CompareCount(ctx context.Context, db *database.MongoDB, gen *generator.DataGenerator, n int) (*CountComparison, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("count comparison needs at least one query")
+	}
+
+	users := make([]string, n)
+	for i := range users {
+		users[i] = gen.GetRandomUserID()
+	}
+
+	fmt.Printf("\n=== Count: Covered Index vs Collection Scan (%d queries) ===\n", n)
+	comparison := &CountComparison{
+		Queries:        n,
+		Covered:        runCount(ctx, db, "covered", bson.D{{Key: "userId", Value: 1}}, users),
+		CollectionScan: runCount(ctx, db, "collscan", bson.D{{Key: "$natural", Value: 1}}, users),
+	}
+	if comparison.Covered.AvgLatency > 0 {
+		comparison.Speedup = float64(comparison.CollectionScan.AvgLatency) / float64(comparison.Covered.AvgLatency)
+	}
+	return comparison, nil
+}
+
+// runCount counts each user's mails with the given index hint
+func runCount(ctx context.Context, db *database.MongoDB, method string, hint bson.D, users []string) *CountResult {
+	result := &CountResult{Method: method, Queries: len(users)}
+	durations := make([]time.Duration, 0, len(users))
+	var total int64
+
+	opts := options.Count().SetHint(hint).SetComment(db.QueryComment("count:" + method))
+	for _, userID := range users {
+		if ctx.Err() != nil {
+			break
+		}
+		start := time.Now()
+		count, err := db.Mails().CountDocuments(ctx, bson.M{"userId": userID}, opts)
+		if err != nil {
+			result.Failed++
+			continue
+		}
+		durations = append(durations, time.Since(start))
+		total += count
+	}
+
+	result.AvgLatency = averageDuration(durations)
+	result.P95Latency = calculatePercentile(durations, 95)
+	if len(durations) > 0 {
+		result.AvgCount = float64(total) / float64(len(durations))
+	}
+	return result
+}
+
+// String renders the comparison as a side-by-side table
+func (c *CountComparison) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-10s %12s %12s %10s %8s\n", "Method", "Avg", "P95", "Count", "Failed")
+	for _, r := range []*CountResult{c.Covered, c.CollectionScan} {
+		fmt.Fprintf(&b, "%-10s %12s %12s %10.1f %8d\n", r.Method, r.AvgLatency, r.P95Latency, r.AvgCount, r.Failed)
+	}
+	fmt.Fprintf(&b, "\nThe covered index count is %.1fx faster than a collection scan\n", c.Speedup)
+	return b.String()
+}
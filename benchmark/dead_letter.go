@@ -0,0 +1,91 @@
+package benchmark
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"mail-stress-test/models"
+)
+
+// defaultDeadLetterMaxBytes caps the dead-letter file when no limit is configured
+const defaultDeadLetterMaxBytes = 10 * 1024 * 1024
+
+// DeadLetter is one failed create request as written to the JSONL file
+type DeadLetter struct {
+	Timestamp time.Time           `json:"timestamp"`
+	Error     string              `json:"error"`
+	Request   *models.MailRequest `json:"request"`
+}
+
+// deadLetterWriter appends failed create payloads to a JSONL file so the
+// exact failing request can be replayed; entries beyond maxBytes are dropped
+type deadLetterWriter struct {
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	maxBytes int64
+	written  int64
+	dropped  int64
+}
+
+func newDeadLetterWriter(path string, maxBytes int64) (*deadLetterWriter, error) {
+	if maxBytes <= 0 {
+		maxBytes = defaultDeadLetterMaxBytes
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create dead-letter directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dead-letter file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &deadLetterWriter{file: file, size: info.Size(), maxBytes: maxBytes}, nil
+}
+
+// record appends a failed request, dropping it if the file is full
+func (w *deadLetterWriter) record(req *models.MailRequest, reqErr error) {
+	line, err := json.Marshal(DeadLetter{
+		Timestamp: time.Now(),
+		Error:     reqErr.Error(),
+		Request:   req,
+	})
+	if err != nil {
+		return
+	}
+	line = append(line, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.size+int64(len(line)) > w.maxBytes {
+		w.dropped++
+		return
+	}
+	n, err := w.file.Write(line)
+	w.size += int64(n)
+	if err != nil {
+		w.dropped++
+		return
+	}
+	w.written++
+}
+
+// close flushes the file and returns how many entries were written and dropped
+func (w *deadLetterWriter) close() (written, dropped int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.file.Close()
+	return w.written, w.dropped
+}
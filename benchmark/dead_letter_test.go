@@ -0,0 +1,120 @@
+package benchmark
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"mail-stress-test/models"
+)
+
+// TestDeadLetterRecordsFailedCreates fails every other create with a unique
+// error and checks each failed payload lands in the dead-letter file with
+// its own error, and nothing else does
+func TestDeadLetterRecordsFailedCreates(t *testing.T) {
+	var mu sync.Mutex
+	failed := make(map[string]*models.MailRequest)
+	calls := 0
+	h := &fakeHandler{create: func(ctx context.Context, req *models.MailRequest) error {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if calls%2 == 0 {
+			return nil
+		}
+		err := fmt.Errorf("backend rejected create %d", calls)
+		failed[err.Error()] = req
+		return err
+	}}
+	st, cfg := newTestStressTest(t, h)
+	cfg.StressTest.RequestRate = 200
+	cfg.StressTest.DeadLetterPath = filepath.Join(t.TempDir(), "failed", "creates.jsonl")
+
+	result, err := st.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failed) == 0 {
+		t.Fatal("no create failed")
+	}
+
+	letters := readDeadLetters(t, cfg.StressTest.DeadLetterPath)
+	if len(letters) != len(failed) || result.DeadLetters != int64(len(failed)) {
+		t.Errorf("%d dead letters in the file, %d reported; want %d", len(letters), result.DeadLetters, len(failed))
+	}
+	for _, letter := range letters {
+		req, ok := failed[letter.Error]
+		if !ok {
+			t.Errorf("dead letter with unexpected error %q", letter.Error)
+			continue
+		}
+		want, _ := json.Marshal(req)
+		got, _ := json.Marshal(letter.Request)
+		if string(got) != string(want) {
+			t.Errorf("dead letter for %q carries %s, want the failed payload %s", letter.Error, got, want)
+		}
+		if letter.Timestamp.IsZero() || time.Since(letter.Timestamp) > time.Minute {
+			t.Errorf("dead letter timestamp = %s", letter.Timestamp)
+		}
+	}
+}
+
+// TestDeadLetterBounded checks entries beyond the size cap are dropped and
+// counted rather than written
+func TestDeadLetterBounded(t *testing.T) {
+	const maxBytes = 2048
+	path := filepath.Join(t.TempDir(), "creates.jsonl")
+	w, err := newDeadLetterWriter(path, maxBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &models.MailRequest{Subject: "Weekly Report", Content: "Update on Weekly Report"}
+	for i := 0; i < 100; i++ {
+		w.record(req, fmt.Errorf("create %d failed", i))
+	}
+	written, dropped := w.close()
+
+	if written == 0 || dropped == 0 || written+dropped != 100 {
+		t.Errorf("written %d, dropped %d; want both non-zero, 100 total", written, dropped)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() > maxBytes {
+		t.Errorf("dead-letter file is %d bytes, over the %d byte cap", info.Size(), maxBytes)
+	}
+	if got := len(readDeadLetters(t, path)); int64(got) != written {
+		t.Errorf("file holds %d entries, writer reported %d", got, written)
+	}
+}
+
+// readDeadLetters decodes every line of a dead-letter file
+func readDeadLetters(t *testing.T, path string) []DeadLetter {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var letters []DeadLetter
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var letter DeadLetter
+		if err := json.Unmarshal(scanner.Bytes(), &letter); err != nil {
+			t.Fatalf("malformed dead letter %q: %v", scanner.Text(), err)
+		}
+		letters = append(letters, letter)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return letters
+}
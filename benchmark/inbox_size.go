@@ -0,0 +1,97 @@
+package benchmark
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// inboxSizeBounds are the inclusive upper bounds (in mails) of the inbox
+// size buckets; the last bucket is unbounded
+var inboxSizeBounds = []int64{100, 1000, 10000}
+
+// InboxSizeLatency is the latency of one operation against inboxes whose
+// size falls in [MinMails, MaxMails]; MaxMails 0 is unbounded
+type InboxSizeLatency struct {
+	Operation  string        `json:"operation"`
+	Label      string        `json:"label"`
+	MinMails   int64         `json:"min_mails"`
+	MaxMails   int64         `json:"max_mails,omitempty"`
+	Requests   int           `json:"requests"`
+	AvgLatency time.Duration `json:"avg_latency"`
+	P95Latency time.Duration `json:"p95_latency"`
+	P99Latency time.Duration `json:"p99_latency"`
+}
+
+func (l InboxSizeLatency) String() string {
+	return fmt.Sprintf("%-7s %12s mails: %6d requests, Avg=%s, P95=%s, P99=%s",
+		l.Operation, l.Label, l.Requests, l.AvgLatency, l.P95Latency, l.P99Latency)
+}
+
+// inboxSizeTracker buckets list and search latencies by the target inbox's
+// size as counted before the run
+type inboxSizeTracker struct {
+	sizes map[string]int64
+
+	mu      sync.Mutex
+	samples map[string][][]time.Duration // operation -> bucket -> latencies
+}
+
+func newInboxSizeTracker(sizes map[string]int64) *inboxSizeTracker {
+	return &inboxSizeTracker{sizes: sizes, samples: make(map[string][][]time.Duration)}
+}
+
+func inboxSizeBucket(mails int64) int {
+	for i, upper := range inboxSizeBounds {
+		if mails <= upper {
+			return i
+		}
+	}
+	return len(inboxSizeBounds)
+}
+
+func (t *inboxSizeTracker) record(operation, userID string, duration time.Duration) {
+	bucket := inboxSizeBucket(t.sizes[userID])
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	buckets, ok := t.samples[operation]
+	if !ok {
+		buckets = make([][]time.Duration, len(inboxSizeBounds)+1)
+		t.samples[operation] = buckets
+	}
+	buckets[bucket] = append(buckets[bucket], duration)
+}
+
+// result summarizes every non-empty bucket, list, then search, then count
+func (t *inboxSizeTracker) result() []InboxSizeLatency {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var latencies []InboxSizeLatency
+	for _, operation := range []string{"list", "search", "count"} {
+		for i, samples := range t.samples[operation] {
+			if len(samples) == 0 {
+				continue
+			}
+			entry := InboxSizeLatency{
+				Operation:  operation,
+				Requests:   len(samples),
+				AvgLatency: averageDuration(samples),
+				P95Latency: calculatePercentile(samples, 95),
+				P99Latency: calculatePercentile(samples, 99),
+			}
+			if i > 0 {
+				entry.MinMails = inboxSizeBounds[i-1] + 1
+			}
+			if i < len(inboxSizeBounds) {
+				entry.MaxMails = inboxSizeBounds[i]
+				entry.Label = fmt.Sprintf("%d-%d", entry.MinMails, entry.MaxMails)
+			} else {
+				entry.Label = fmt.Sprintf(">%d", inboxSizeBounds[i-1])
+			}
+			latencies = append(latencies, entry)
+		}
+	}
+	return latencies
+}
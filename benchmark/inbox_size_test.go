@@ -0,0 +1,39 @@
+package benchmark
+
+import (
+	"testing"
+	"time"
+)
+
+// TestInboxSizeBuckets records list and search latencies against a small and
+// a huge inbox and checks each lands in its own size bucket
+func TestInboxSizeBuckets(t *testing.T) {
+	tracker := newInboxSizeTracker(map[string]int64{"small": 40, "huge": 25000})
+	for i := 0; i < 10; i++ {
+		tracker.record("list", "small", 2*time.Millisecond)
+		tracker.record("list", "huge", 80*time.Millisecond)
+		tracker.record("search", "huge", 150*time.Millisecond)
+	}
+
+	want := []struct {
+		operation, label string
+		p95              time.Duration
+	}{
+		{"list", "0-100", 2 * time.Millisecond},
+		{"list", ">10000", 80 * time.Millisecond},
+		{"search", ">10000", 150 * time.Millisecond},
+	}
+	got := tracker.result()
+	if len(got) != len(want) {
+		t.Fatalf("buckets = %v, want %d", got, len(want))
+	}
+	for i, w := range want {
+		if got[i].Operation != w.operation || got[i].Label != w.label || got[i].Requests != 10 || got[i].P95Latency != w.p95 {
+			t.Errorf("bucket %d = %s, want %s %s with 10 requests at P95 %s", i, got[i], w.operation, w.label, w.p95)
+		}
+	}
+	if got[0].MinMails != 0 || got[0].MaxMails != 100 || got[1].MinMails != 10001 || got[1].MaxMails != 0 {
+		t.Errorf("bounds %d-%d and %d-%d, want 0-100 and 10001-unbounded",
+			got[0].MinMails, got[0].MaxMails, got[1].MinMails, got[1].MaxMails)
+	}
+}
@@ -0,0 +1,32 @@
+package benchmark
+
+import (
+	"fmt"
+	"time"
+)
+
+// stressInsufficientData explains why a stress result has no meaningful
+// latency or throughput figures, or returns "" when it has
+func stressInsufficientData(result *StressTestResult) string {
+	switch {
+	case result.TotalRequests == 0 && result.AbortError != "":
+		return "run aborted before any request completed: " + result.AbortError
+	case result.TotalRequests == 0:
+		return fmt.Sprintf("no requests completed in %s; the run was too short or cancelled", result.TotalDuration.Round(time.Millisecond))
+	case result.SuccessRequests == 0:
+		return fmt.Sprintf("all %d requests failed; latency figures describe failures only", result.TotalRequests)
+	}
+	return ""
+}
+
+// searchInsufficientData explains why a strategy has no meaningful query
+// latency, or returns "" when it has
+func searchInsufficientData(result *SearchBenchmarkResult) string {
+	switch {
+	case result.TotalQueries == 0:
+		return "no queries ran; the benchmark was cancelled or has no queries configured"
+	case result.SuccessQueries == 0:
+		return fmt.Sprintf("all %d queries failed", result.TotalQueries)
+	}
+	return ""
+}
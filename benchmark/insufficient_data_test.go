@@ -0,0 +1,79 @@
+package benchmark
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"mail-stress-test/models"
+)
+
+// TestZeroDurationRunHasInsufficientData runs a stress test with no time to
+// issue requests and checks it says so instead of reporting zero figures
+func TestZeroDurationRunHasInsufficientData(t *testing.T) {
+	h := &fakeHandler{create: func(ctx context.Context, req *models.MailRequest) error { return nil }}
+	st, cfg := newTestStressTest(t, h)
+	cfg.StressTest.Duration = 0
+
+	result, err := st.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.TotalRequests != 0 {
+		t.Fatalf("TotalRequests = %d, want 0 in a zero-duration run", result.TotalRequests)
+	}
+	if !strings.Contains(result.InsufficientData, "no requests completed") {
+		t.Errorf("InsufficientData = %q, want it to say no requests completed", result.InsufficientData)
+	}
+	if result.MinResponseTime != 0 {
+		t.Errorf("MinResponseTime = %s, want 0 with no requests", result.MinResponseTime)
+	}
+}
+
+func TestAllFailedRunHasInsufficientData(t *testing.T) {
+	h := &fakeHandler{create: func(ctx context.Context, req *models.MailRequest) error { return errors.New("backend down") }}
+	st, cfg := newTestStressTest(t, h)
+	cfg.StressTest.Duration = 50 * time.Millisecond
+
+	result, err := st.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.TotalRequests == 0 || result.SuccessRequests != 0 {
+		t.Fatalf("%d requests, %d succeeded; want only failures", result.TotalRequests, result.SuccessRequests)
+	}
+	if !strings.Contains(result.InsufficientData, "requests failed") {
+		t.Errorf("InsufficientData = %q, want it to say every request failed", result.InsufficientData)
+	}
+}
+
+func TestInsufficientDataReasons(t *testing.T) {
+	tests := []struct {
+		result *StressTestResult
+		want   string
+	}{
+		{&StressTestResult{AbortError: "create: refused"}, "run aborted before any request completed: create: refused"},
+		{&StressTestResult{TotalRequests: 10, SuccessRequests: 1}, ""},
+	}
+	for _, tt := range tests {
+		if got := stressInsufficientData(tt.result); got != tt.want {
+			t.Errorf("stressInsufficientData(%+v) = %q, want %q", tt.result, got, tt.want)
+		}
+	}
+
+	searches := []struct {
+		result *SearchBenchmarkResult
+		want   string
+	}{
+		{&SearchBenchmarkResult{}, "no queries ran; the benchmark was cancelled or has no queries configured"},
+		{&SearchBenchmarkResult{TotalQueries: 5}, "all 5 queries failed"},
+		{&SearchBenchmarkResult{TotalQueries: 5, SuccessQueries: 5}, ""},
+	}
+	for _, tt := range searches {
+		if got := searchInsufficientData(tt.result); got != tt.want {
+			t.Errorf("searchInsufficientData(%+v) = %q, want %q", tt.result, got, tt.want)
+		}
+	}
+}
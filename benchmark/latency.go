@@ -0,0 +1,131 @@
+package benchmark
+
+import (
+	"fmt"
+	"time"
+)
+
+// LatencyBucket is one bar of the response time histogram
+type LatencyBucket struct {
+	Label             string        `json:"label"`
+	UpperBound        time.Duration `json:"upper_bound"` // 0 for the open-ended last bucket
+	Count             int64         `json:"count"`
+	CumulativePercent float64       `json:"cumulative_percent"`
+}
+
+// latencyBucketBounds are the upper bounds of the histogram buckets
+var latencyBucketBounds = []time.Duration{
+	1 * time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	1 * time.Second,
+}
+
+// BuildLatencyHistogram buckets samples into fixed latency ranges and computes
+// the cumulative distribution for each bucket
+func BuildLatencyHistogram(samples []time.Duration) []LatencyBucket {
+	buckets := make([]LatencyBucket, 0, len(latencyBucketBounds)+1)
+	var lower time.Duration
+	for _, upper := range latencyBucketBounds {
+		buckets = append(buckets, LatencyBucket{
+			Label:      formatBucketRange(lower, upper),
+			UpperBound: upper,
+		})
+		lower = upper
+	}
+	buckets = append(buckets, LatencyBucket{Label: formatBucketBound(lower) + "+"})
+
+	for _, sample := range samples {
+		buckets[latencyBucketIndex(sample)].Count++
+	}
+
+	if len(samples) > 0 {
+		var cumulative int64
+		for i := range buckets {
+			cumulative += buckets[i].Count
+			buckets[i].CumulativePercent = float64(cumulative) / float64(len(samples)) * 100
+		}
+	}
+
+	return buckets
+}
+
+// latencyBucketIndex returns the histogram bucket a sample falls into
+func latencyBucketIndex(sample time.Duration) int {
+	for i, upper := range latencyBucketBounds {
+		if sample < upper {
+			return i
+		}
+	}
+	return len(latencyBucketBounds)
+}
+
+// streamingLatency aggregates latencies in bounded memory: a t-digest for
+// percentiles plus fixed histogram bucket counts
+type streamingLatency struct {
+	digest *TDigest
+	counts []int64
+}
+
+func newStreamingLatency(compression float64) *streamingLatency {
+	return &streamingLatency{
+		digest: NewTDigest(compression),
+		counts: make([]int64, len(latencyBucketBounds)+1),
+	}
+}
+
+func (s *streamingLatency) add(sample time.Duration) {
+	s.digest.Add(float64(sample))
+	s.counts[latencyBucketIndex(sample)]++
+}
+
+// percentile estimates the nth percentile (0-100)
+func (s *streamingLatency) percentile(p float64) time.Duration {
+	return time.Duration(s.digest.Quantile(p / 100))
+}
+
+// histogram builds the same bucket layout as BuildLatencyHistogram
+func (s *streamingLatency) histogram() []LatencyBucket {
+	buckets := BuildLatencyHistogram(nil)
+	total := s.digest.Count()
+	var cumulative int64
+	for i := range buckets {
+		buckets[i].Count = s.counts[i]
+		cumulative += s.counts[i]
+		if total > 0 {
+			buckets[i].CumulativePercent = float64(cumulative) / float64(total) * 100
+		}
+	}
+	return buckets
+}
+
+// sla evaluates the SLA from the digest; nil when unconfigured or empty
+func (s *streamingLatency) sla(percentileTarget float64, budget time.Duration) *SLAResult {
+	if s.digest.Count() == 0 || percentileTarget <= 0 || budget <= 0 {
+		return nil
+	}
+	result := &SLAResult{
+		PercentileTarget: percentileTarget,
+		LatencyBudget:    budget,
+		ActualLatency:    s.percentile(percentileTarget),
+		WithinBudget:     s.digest.CDF(float64(budget)) * 100,
+	}
+	result.Passed = result.ActualLatency <= budget
+	return result
+}
+
+func formatBucketRange(lower, upper time.Duration) string {
+	return formatBucketBound(lower) + "-" + formatBucketBound(upper)
+}
+
+func formatBucketBound(d time.Duration) string {
+	if d >= time.Second {
+		return fmt.Sprintf("%ds", d/time.Second)
+	}
+	return fmt.Sprintf("%dms", d/time.Millisecond)
+}
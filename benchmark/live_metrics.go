@@ -0,0 +1,183 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// LiveMetrics holds the generator's counters while a run is in progress so
+// they can be scraped by Prometheus alongside the target's own metrics
+type LiveMetrics struct {
+	mu         sync.Mutex
+	startTime  time.Time
+	operations map[string]*liveOperation
+
+	// runSnapshot is extra exposition text served after the live counters,
+	// e.g. the finished run's monitoring diffs
+	runSnapshot []byte
+}
+
+// liveOperation is a cumulative latency histogram for one operation
+type liveOperation struct {
+	success int64
+	errors  int64
+	sum     time.Duration
+	buckets []int64 // cumulative counts per latencyBucketBounds entry
+}
+
+// NewLiveMetrics creates an empty live metrics registry
+func NewLiveMetrics() *LiveMetrics {
+	return &LiveMetrics{
+		startTime:  time.Now(),
+		operations: make(map[string]*liveOperation),
+	}
+}
+
+// Observe records one completed operation
+func (m *LiveMetrics) Observe(operation string, duration time.Duration, failed bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	op, ok := m.operations[operation]
+	if !ok {
+		op = &liveOperation{buckets: make([]int64, len(latencyBucketBounds))}
+		m.operations[operation] = op
+	}
+
+	if failed {
+		op.errors++
+	} else {
+		op.success++
+	}
+	op.sum += duration
+	for i, upper := range latencyBucketBounds {
+		if duration <= upper {
+			op.buckets[i]++
+		}
+	}
+}
+
+// LiveOperation is a point-in-time copy of one operation's counters
+type LiveOperation struct {
+	Requests      int64
+	Errors        int64
+	TotalDuration time.Duration
+}
+
+// Snapshot copies the cumulative per-operation counters, e.g. for a live
+// dashboard that diffs consecutive snapshots
+func (m *LiveMetrics) Snapshot() map[string]LiveOperation {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := make(map[string]LiveOperation, len(m.operations))
+	for name, op := range m.operations {
+		snapshot[name] = LiveOperation{
+			Requests:      op.success + op.errors,
+			Errors:        op.errors,
+			TotalDuration: op.sum,
+		}
+	}
+	return snapshot
+}
+
+// writeExposition renders the metrics in the Prometheus text exposition format
+func (m *LiveMetrics) writeExposition(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.operations))
+	for name := range m.operations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var total, failed int64
+	for _, name := range names {
+		op := m.operations[name]
+		total += op.success + op.errors
+		failed += op.errors
+	}
+
+	io.WriteString(w, "# HELP mail_stress_requests_total Requests issued by the stress tool\n")
+	io.WriteString(w, "# TYPE mail_stress_requests_total counter\n")
+	for _, name := range names {
+		op := m.operations[name]
+		fmt.Fprintf(w, "mail_stress_requests_total{operation=%q,status=\"success\"} %d\n", name, op.success)
+		fmt.Fprintf(w, "mail_stress_requests_total{operation=%q,status=\"error\"} %d\n", name, op.errors)
+	}
+
+	io.WriteString(w, "# HELP mail_stress_requests_per_second Average request rate since the run started\n")
+	io.WriteString(w, "# TYPE mail_stress_requests_per_second gauge\n")
+	rps := 0.0
+	if elapsed := time.Since(m.startTime).Seconds(); elapsed > 0 {
+		rps = float64(total) / elapsed
+	}
+	fmt.Fprintf(w, "mail_stress_requests_per_second %g\n", rps)
+
+	io.WriteString(w, "# HELP mail_stress_error_rate Fraction of failed requests since the run started\n")
+	io.WriteString(w, "# TYPE mail_stress_error_rate gauge\n")
+	errorRate := 0.0
+	if total > 0 {
+		errorRate = float64(failed) / float64(total)
+	}
+	fmt.Fprintf(w, "mail_stress_error_rate %g\n", errorRate)
+
+	io.WriteString(w, "# HELP mail_stress_request_duration_seconds Response time observed by the stress tool\n")
+	io.WriteString(w, "# TYPE mail_stress_request_duration_seconds histogram\n")
+	for _, name := range names {
+		op := m.operations[name]
+		for i, upper := range latencyBucketBounds {
+			fmt.Fprintf(w, "mail_stress_request_duration_seconds_bucket{operation=%q,le=\"%g\"} %d\n",
+				name, upper.Seconds(), op.buckets[i])
+		}
+		count := op.success + op.errors
+		fmt.Fprintf(w, "mail_stress_request_duration_seconds_bucket{operation=%q,le=\"+Inf\"} %d\n", name, count)
+		fmt.Fprintf(w, "mail_stress_request_duration_seconds_sum{operation=%q} %g\n", name, op.sum.Seconds())
+		fmt.Fprintf(w, "mail_stress_request_duration_seconds_count{operation=%q} %d\n", name, count)
+	}
+	w.Write(m.runSnapshot)
+}
+
+// SetRunSnapshot appends exposition text, such as a finished run's
+// monitoring diffs, to every later scrape
+func (m *LiveMetrics) SetRunSnapshot(exposition []byte) {
+	m.mu.Lock()
+	m.runSnapshot = exposition
+	m.mu.Unlock()
+}
+
+// ServeHTTP exposes the metrics at any path, typically mounted on /metrics
+func (m *LiveMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.writeExposition(w)
+}
+
+// StartMetricsServer serves the live metrics on /metrics at the given port
+// until ctx is cancelled. It returns once the listener is bound.
+func StartMetricsServer(ctx context.Context, port int, metrics *LiveMetrics) (string, error) {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return "", fmt.Errorf("failed to listen on metrics port %d: %w", port, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics)
+	server := &http.Server{Handler: mux}
+
+	go server.Serve(listener)
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	return listener.Addr().String(), nil
+}
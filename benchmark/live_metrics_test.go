@@ -0,0 +1,95 @@
+package benchmark
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"mail-stress-test/models"
+)
+
+// TestMetricsServerMidRun scrapes the live metrics endpoint while a stress
+// test is running and checks the request, rate, error and latency series are
+// exposed for the operations issued so far
+func TestMetricsServerMidRun(t *testing.T) {
+	var creates int64
+	h := &fakeHandler{create: func(ctx context.Context, req *models.MailRequest) error {
+		time.Sleep(time.Millisecond)
+		if atomic.AddInt64(&creates, 1)%5 == 0 {
+			return errors.New("backend unavailable")
+		}
+		return nil
+	}}
+	st, cfg := newTestStressTest(t, h)
+	cfg.StressTest.Duration = 2 * time.Second
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	metrics := NewLiveMetrics()
+	st.SetLiveMetrics(metrics)
+	addr, err := StartMetricsServer(ctx, 0, metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		st.Run(ctx)
+	}()
+
+	// Wait until some creates failed so the error series has data
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt64(&creates) < 20 {
+		if time.Now().After(deadline) {
+			t.Fatal("stress test issued no requests")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	resp, err := http.Get("http://127.0.0.1:" + port + "/metrics")
+	if err != nil {
+		t.Fatalf("scrape: %v", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+		t.Fatal("run finished before the scrape; the test did not scrape mid-run")
+	default:
+	}
+
+	exposition := string(body)
+	for _, series := range []string{
+		`mail_stress_requests_total{operation="create",status="success"} `,
+		`mail_stress_requests_total{operation="create",status="error"} `,
+		"mail_stress_requests_per_second ",
+		"mail_stress_error_rate ",
+		`mail_stress_request_duration_seconds_bucket{operation="create",le="+Inf"} `,
+		`mail_stress_request_duration_seconds_sum{operation="create"} `,
+		`mail_stress_request_duration_seconds_count{operation="create"} `,
+	} {
+		if !strings.Contains(exposition, series) {
+			t.Errorf("scrape is missing series %s", series)
+		}
+	}
+	if strings.Contains(exposition, `status="error"} 0`+"\n") {
+		t.Errorf("error series reports no failures:\n%s", exposition)
+	}
+
+	cancel()
+	<-done
+}
@@ -0,0 +1,180 @@
+package benchmark
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"mail-stress-test/database"
+	"mail-stress-test/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// PaginationDepth is the latency of fetching one page by skip/limit and by
+// page cursor
+type PaginationDepth struct {
+	Page      int           `json:"page"`
+	Offset    int           `json:"offset"`
+	Skipped   bool          `json:"skipped,omitempty"` // inbox too small to reach the page
+	SkipAvg   time.Duration `json:"skip_avg"`
+	SkipP95   time.Duration `json:"skip_p95"`
+	CursorAvg time.Duration `json:"cursor_avg"`
+	CursorP95 time.Duration `json:"cursor_p95"`
+	Failed    int           `json:"failed"`
+}
+
+// PaginationComparison is the latency-vs-offset curve of skip/limit and
+// cursor pagination over the largest inbox
+type PaginationComparison struct {
+	UserID    string            `json:"user_id"`
+	InboxSize int64             `json:"inbox_size"`
+	PageSize  int               `json:"page_size"`
+	Queries   int               `json:"queries"` // per page and method
+	Depths    []PaginationDepth `json:"depths"`
+}
+
+// ComparePagination fetches each page of the largest inbox queries times by
+// skip/limit and by page cursor, both sorted by PageSort. The cursor for a
+// page is located once, untimed, as an application would have it from the
+// previous page.
+func ComparePagination(ctx context.Context, db *database.MongoDB, pages []int, pageSize, queries int) (*PaginationComparison, error) {
+	if pageSize <= 0 || queries <= 0 || len(pages) == 0 {
+		return nil, fmt.Errorf("pagination comparison needs pages, a page size and at least one query")
+	}
+
+	sizes, err := db.InboxSizes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	comparison := &PaginationComparison{PageSize: pageSize, Queries: queries}
+	for userID, size := range sizes {
+		if size > comparison.InboxSize {
+			comparison.UserID, comparison.InboxSize = userID, size
+		}
+	}
+	if comparison.UserID == "" {
+		return nil, fmt.Errorf("no mails to paginate; seed the database first")
+	}
+
+	fmt.Printf("\n=== Pagination: Skip/Limit vs Cursor (%d mails, %d per page) ===\n", comparison.InboxSize, pageSize)
+	collection := db.Mails()
+	for _, page := range pages {
+		depth := PaginationDepth{Page: page, Offset: (page - 1) * pageSize}
+		if page < 1 || int64(depth.Offset) >= comparison.InboxSize {
+			depth.Skipped = true
+			comparison.Depths = append(comparison.Depths, depth)
+			continue
+		}
+
+		skipOpts := options.Find().SetSort(database.PageSort).SetSkip(int64(depth.Offset)).SetLimit(int64(pageSize)).
+			SetComment(db.QueryComment("page:skip"))
+		skipFilter := bson.M{"userId": comparison.UserID}
+
+		// The client holds the previous page's token; each fetch decodes it
+		// the way a list endpoint would
+		var token string
+		if depth.Offset > 0 {
+			cursor, err := pageCursorAt(ctx, collection, comparison.UserID, depth.Offset-1)
+			if err != nil {
+				return nil, fmt.Errorf("page %d: %w", page, err)
+			}
+			token = cursor.Token()
+		}
+		cursorOpts := options.Find().SetSort(database.PageSort).SetLimit(int64(pageSize)).
+			SetComment(db.QueryComment("page:cursor"))
+
+		var skipDurations, cursorDurations []time.Duration
+		for i := 0; i < queries && ctx.Err() == nil; i++ {
+			if d, err := timePage(ctx, collection, skipFilter, skipOpts); err == nil {
+				skipDurations = append(skipDurations, d)
+			} else {
+				depth.Failed++
+			}
+			if d, err := timeCursorPage(ctx, collection, comparison.UserID, token, cursorOpts); err == nil {
+				cursorDurations = append(cursorDurations, d)
+			} else {
+				depth.Failed++
+			}
+		}
+		depth.SkipAvg, depth.SkipP95 = averageDuration(skipDurations), calculatePercentile(skipDurations, 95)
+		depth.CursorAvg, depth.CursorP95 = averageDuration(cursorDurations), calculatePercentile(cursorDurations, 95)
+		comparison.Depths = append(comparison.Depths, depth)
+	}
+	return comparison, nil
+}
+
+// pageCursorAt returns the cursor of the mail at offset in PageSort order
+func pageCursorAt(ctx context.Context, collection *mongo.Collection, userID string, offset int) (database.PageCursor, error) {
+	var mail models.Mail
+	err := collection.FindOne(ctx, bson.M{"userId": userID}, options.FindOne().
+		SetSort(database.PageSort).SetSkip(int64(offset)).
+		SetProjection(bson.M{"createdAt": 1})).Decode(&mail)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return database.PageCursor{}, fmt.Errorf("no mail at offset %d", offset)
+	}
+	if err != nil {
+		return database.PageCursor{}, err
+	}
+	return database.PageCursor{CreatedAt: mail.CreatedAt, ID: mail.ID}, nil
+}
+
+// timePage fetches and drains one page
+func timePage(ctx context.Context, collection *mongo.Collection, filter bson.M, opts *options.FindOptions) (time.Duration, error) {
+	start := time.Now()
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return 0, err
+	}
+	var mails []*models.Mail
+	err = cursor.All(ctx, &mails)
+	return time.Since(start), err
+}
+
+// timeCursorPage fetches the page after token, or the first page when
+// token is empty
+func timeCursorPage(ctx context.Context, collection *mongo.Collection, userID, token string, opts *options.FindOptions) (time.Duration, error) {
+	start := time.Now()
+	filter := bson.M{"userId": userID}
+	if token != "" {
+		cursor, err := database.ParsePageCursor(token)
+		if err != nil {
+			return 0, err
+		}
+		filter = cursor.After(userID)
+	}
+	_, err := timePage(ctx, collection, filter, opts)
+	return time.Since(start), err
+}
+
+// String renders the latency-vs-offset curve of both methods
+func (c *PaginationComparison) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-6s %8s %12s %12s %12s %12s %8s\n", "Page", "Offset", "Skip Avg", "Skip P95", "Cursor Avg", "Cursor P95", "Ratio")
+	var first, last *PaginationDepth
+	for i := range c.Depths {
+		d := &c.Depths[i]
+		if d.Skipped {
+			fmt.Fprintf(&b, "%-6d %8d %s\n", d.Page, d.Offset, "  skipped: beyond the largest inbox")
+			continue
+		}
+		ratio := 0.0
+		if d.CursorAvg > 0 {
+			ratio = float64(d.SkipAvg) / float64(d.CursorAvg)
+		}
+		fmt.Fprintf(&b, "%-6d %8d %12s %12s %12s %12s %7.1fx\n", d.Page, d.Offset, d.SkipAvg, d.SkipP95, d.CursorAvg, d.CursorP95, ratio)
+		if first == nil {
+			first = d
+		}
+		last = d
+	}
+	if first != nil && last != first && first.SkipAvg > 0 && first.CursorAvg > 0 {
+		fmt.Fprintf(&b, "\nFrom page %d to %d skip/limit slowed %.1fx, cursor %.1fx\n", first.Page, last.Page,
+			float64(last.SkipAvg)/float64(first.SkipAvg), float64(last.CursorAvg)/float64(first.CursorAvg))
+	}
+	return b.String()
+}
@@ -0,0 +1,82 @@
+package benchmark
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"mail-stress-test/database"
+	"mail-stress-test/internal/mongotest"
+	"mail-stress-test/models"
+)
+
+func TestComparePaginationRejectsBadArgs(t *testing.T) {
+	for _, args := range []struct{ pages, size, queries int }{{0, 20, 5}, {1, 0, 5}, {1, 20, 0}} {
+		pages := make([]int, args.pages)
+		if _, err := ComparePagination(context.Background(), nil, pages, args.size, args.queries); err == nil {
+			t.Errorf("ComparePagination(%d pages, size %d, %d queries) accepted", args.pages, args.size, args.queries)
+		}
+	}
+}
+
+func TestPaginationComparisonString(t *testing.T) {
+	c := &PaginationComparison{Depths: []PaginationDepth{
+		{Page: 1, SkipAvg: time.Millisecond, CursorAvg: time.Millisecond},
+		{Page: 500, Offset: 9980, SkipAvg: 20 * time.Millisecond, CursorAvg: time.Millisecond},
+		{Page: 5000, Offset: 99980, Skipped: true},
+	}}
+	out := c.String()
+	for _, want := range []string{"20.0x", "skipped: beyond the largest inbox", "From page 1 to 500 skip/limit slowed 20.0x, cursor 1.0x"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("String() = %q, want it to contain %q", out, want)
+		}
+	}
+}
+
+// TestPaginationIntegration seeds one deep inbox and checks skip/limit slows
+// down with the offset while the page cursor stays roughly flat
+func TestPaginationIntegration(t *testing.T) {
+	const inbox, pageSize = 20000, 20
+	mdb := mongotest.Database(t)
+	db := &database.MongoDB{
+		Client:            mdb.Client(),
+		Database:          mdb,
+		MailsCollection:   database.DefaultMailsCollection,
+		ThreadsCollection: database.DefaultThreadsCollection,
+	}
+	ctx := context.Background()
+	if err := db.CreateIndexes(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	mails := make([]interface{}, inbox)
+	for i := range mails {
+		mails[i] = models.Mail{From: "user-2", To: []string{"user-1"}, Subject: "page", UserID: "user-1", CreatedAt: start.Add(-time.Duration(i) * time.Second)}
+	}
+	if _, err := db.Mails().InsertMany(ctx, mails); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := ComparePagination(ctx, db, []int{1, 50, inbox / pageSize, inbox/pageSize + 1}, pageSize, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.UserID != "user-1" || result.InboxSize != inbox || len(result.Depths) != 4 {
+		t.Fatalf("compared %s (%d mails) at %d depths, want user-1, %d mails and 4 depths", result.UserID, result.InboxSize, len(result.Depths), inbox)
+	}
+	if !result.Depths[3].Skipped {
+		t.Errorf("page past the inbox was not skipped: %+v", result.Depths[3])
+	}
+	first, deep := result.Depths[0], result.Depths[2]
+	if first.Failed != 0 || deep.Failed != 0 {
+		t.Fatalf("%d and %d failed fetches", first.Failed, deep.Failed)
+	}
+	if deep.CursorAvg > 3*first.CursorAvg+2*time.Millisecond {
+		t.Errorf("cursor page at offset %d took %s, page 1 %s; want it roughly flat", deep.Offset, deep.CursorAvg, first.CursorAvg)
+	}
+	if deep.SkipAvg < 2*deep.CursorAvg {
+		t.Errorf("skip page at offset %d took %s, cursor %s; want skipping to cost far more", deep.Offset, deep.SkipAvg, deep.CursorAvg)
+	}
+}
@@ -0,0 +1,152 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"mail-stress-test/config"
+	"mail-stress-test/generator"
+	"mail-stress-test/handler"
+	"mail-stress-test/models"
+)
+
+// PathResult holds latency and throughput for one access path (API or DB)
+type PathResult struct {
+	Path              string        `json:"path"`
+	Operations        int           `json:"operations"`
+	Failed            int           `json:"failed"`
+	TotalDuration     time.Duration `json:"total_duration"`
+	AvgLatency        time.Duration `json:"avg_latency"`
+	P50Latency        time.Duration `json:"p50_latency"`
+	P95Latency        time.Duration `json:"p95_latency"`
+	P99Latency        time.Duration `json:"p99_latency"`
+	RequestsPerSecond float64       `json:"requests_per_second"`
+}
+
+// PathComparison compares the same operation sequence run through the
+// HTTP/JSON API and through direct BSON database access. The latency delta is
+// the cost of JSON serialization, HTTP and the network hop.
+type PathComparison struct {
+	Operations      int           `json:"operations"`
+	DB              *PathResult   `json:"db"`
+	API             *PathResult   `json:"api"`
+	AvgOverhead     time.Duration `json:"avg_overhead"`
+	P95Overhead     time.Duration `json:"p95_overhead"`
+	OverheadPercent float64       `json:"overhead_percent"`
+}
+
+// pathOperation is one pre-generated request replayed against both paths
+type pathOperation struct {
+	kind   string
+	create *models.MailRequest
+	list   *models.ListMailsRequest
+	search *models.SearchMailsRequest
+}
+
+// ComparePaths replays an identical sequence of n operations, weighted like
+// the stress test, through dbHandler and then apiHandler on a single worker
+func ComparePaths(ctx context.Context, cfg *config.Config, gen *generator.DataGenerator, dbHandler, apiHandler handler.MailHandler, n int) (*PathComparison, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("path comparison needs at least one operation")
+	}
+
+	ops, err := generatePathOperations(cfg.StressTest.Operations, gen, n)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("\n=== API vs DB Path Comparison (%d operations) ===\n", n)
+	dbResult := runPath(ctx, "db", dbHandler, ops)
+	apiResult := runPath(ctx, "api", apiHandler, ops)
+
+	comparison := &PathComparison{
+		Operations:  n,
+		DB:          dbResult,
+		API:         apiResult,
+		AvgOverhead: apiResult.AvgLatency - dbResult.AvgLatency,
+		P95Overhead: apiResult.P95Latency - dbResult.P95Latency,
+	}
+	if dbResult.AvgLatency > 0 {
+		comparison.OverheadPercent = float64(comparison.AvgOverhead) / float64(dbResult.AvgLatency) * 100
+	}
+
+	return comparison, nil
+}
+
+// generatePathOperations builds the fixed create/list/search sequence
+func generatePathOperations(weights config.Operations, gen *generator.DataGenerator, n int) ([]pathOperation, error) {
+	total := weights.CreateMailWeight + weights.ListMailWeight + weights.SearchWeight
+	if total <= 0 {
+		return nil, fmt.Errorf("path comparison needs a positive create, list or search weight")
+	}
+
+	ops := make([]pathOperation, n)
+	for i := range ops {
+		r := rand.Intn(total)
+		switch {
+		case r < weights.CreateMailWeight:
+			ops[i] = pathOperation{kind: "create", create: gen.GenerateCreateMailRequest("")}
+		case r < weights.CreateMailWeight+weights.ListMailWeight:
+			ops[i] = pathOperation{kind: "list", list: gen.GenerateListMailsRequest()}
+		default:
+			ops[i] = pathOperation{kind: "search", search: gen.GenerateSearchMailsRequest()}
+		}
+	}
+	return ops, nil
+}
+
+// runPath executes ops sequentially against h and summarizes the latencies
+func runPath(ctx context.Context, path string, h handler.MailHandler, ops []pathOperation) *PathResult {
+	result := &PathResult{Path: path, Operations: len(ops)}
+	durations := make([]time.Duration, 0, len(ops))
+
+	start := time.Now()
+	for _, op := range ops {
+		if ctx.Err() != nil {
+			break
+		}
+
+		opStart := time.Now()
+		var err error
+		switch op.kind {
+		case "create":
+			err = h.CreateMail(ctx, op.create)
+		case "list":
+			_, err = h.ListMails(ctx, op.list)
+		case "search":
+			_, err = h.SearchMails(ctx, op.search)
+		}
+		durations = append(durations, time.Since(opStart))
+
+		if err != nil {
+			result.Failed++
+		}
+	}
+	result.TotalDuration = time.Since(start)
+
+	result.AvgLatency = averageDuration(durations)
+	result.P50Latency = calculatePercentile(durations, 50)
+	result.P95Latency = calculatePercentile(durations, 95)
+	result.P99Latency = calculatePercentile(durations, 99)
+	if result.TotalDuration > 0 {
+		result.RequestsPerSecond = float64(len(durations)) / result.TotalDuration.Seconds()
+	}
+
+	return result
+}
+
+// String renders the comparison as a side-by-side table
+func (c *PathComparison) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-10s %12s %12s %12s %12s %10s %8s\n", "Path", "Avg", "P50", "P95", "P99", "Req/s", "Failed")
+	for _, r := range []*PathResult{c.DB, c.API} {
+		fmt.Fprintf(&b, "%-10s %12s %12s %12s %12s %10.1f %8d\n",
+			r.Path, r.AvgLatency, r.P50Latency, r.P95Latency, r.P99Latency, r.RequestsPerSecond, r.Failed)
+	}
+	fmt.Fprintf(&b, "\nAPI overhead (serialization + HTTP + network): avg %s (%.1f%%), P95 %s\n",
+		c.AvgOverhead, c.OverheadPercent, c.P95Overhead)
+	return b.String()
+}
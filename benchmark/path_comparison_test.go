@@ -0,0 +1,89 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"mail-stress-test/config"
+	"mail-stress-test/generator"
+	"mail-stress-test/handler"
+	"mail-stress-test/models"
+)
+
+// TestComparePathsInProcessAPI replays the same sequence through a fake
+// database handler and an in-process API server, checking both paths ran
+// every operation and the report shows both side by side
+func TestComparePathsInProcessAPI(t *testing.T) {
+	const n = 60
+	var mu sync.Mutex
+	apiCalls := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		apiCalls[r.URL.Path]++
+		mu.Unlock()
+		if r.URL.Path == "/api/mails" {
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"id": "mail-1"}`)
+			return
+		}
+		fmt.Fprint(w, `[]`)
+	}))
+	defer server.Close()
+
+	dbCalls := make(map[string]int)
+	dbHandler := &fakeHandler{
+		create: func(ctx context.Context, req *models.MailRequest) error {
+			dbCalls["/api/mails"]++
+			return nil
+		},
+		list: func(ctx context.Context, req *models.ListMailsRequest) ([]*models.Mail, error) {
+			dbCalls["/api/mails/list"]++
+			return nil, nil
+		},
+		search: func(ctx context.Context, req *models.SearchMailsRequest) ([]*models.Mail, error) {
+			dbCalls["/api/mails/search"]++
+			return nil, nil
+		},
+	}
+
+	gen, err := generator.NewDataGenerator([]string{"user-1", "user-2", "user-3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.DefaultConfig()
+	cfg.StressTest.Operations = config.Operations{CreateMailWeight: 40, ListMailWeight: 30, SearchWeight: 30}
+
+	comparison, err := ComparePaths(context.Background(), cfg, gen, dbHandler, handler.NewAPIHandler(server.URL), n)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for path, count := range dbCalls {
+		if apiCalls[path] != count {
+			t.Errorf("%s: db path ran %d, api path %d; want the same sequence", path, count, apiCalls[path])
+		}
+	}
+	if len(apiCalls) != 3 {
+		t.Errorf("api path called %v, want create, list and search", apiCalls)
+	}
+	for _, result := range []*PathResult{comparison.DB, comparison.API} {
+		if result.Operations != n || result.Failed != 0 || result.RequestsPerSecond <= 0 || result.AvgLatency <= 0 {
+			t.Errorf("%s path result = %+v", result.Path, result)
+		}
+	}
+	if comparison.AvgOverhead != comparison.API.AvgLatency-comparison.DB.AvgLatency {
+		t.Errorf("AvgOverhead = %s, want the API minus DB average", comparison.AvgOverhead)
+	}
+
+	report := comparison.String()
+	for _, row := range []string{"\ndb ", "\napi ", "API overhead"} {
+		if !strings.Contains(report, row) {
+			t.Errorf("report is missing %q:\n%s", row, report)
+		}
+	}
+}
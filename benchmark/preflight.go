@@ -0,0 +1,219 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+
+	"mail-stress-test/config"
+	"mail-stress-test/database"
+	"mail-stress-test/generator"
+	"mail-stress-test/handler"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// knownSearchMethods lists the strategy names accepted in benchmark.search_methods
+var knownSearchMethods = map[string]bool{
+	"text_search":     true,
+	"regex":           true,
+	"aggregation":     true,
+	"index_optimized": true,
+	"hybrid":          true,
+	"archive_union":   true,
+}
+
+// operationFeatures describes what an operation needs from the handler, for
+// unsupported-operation warnings
+var operationFeatures = map[string]string{
+	"draft":       "drafts",
+	"forward":     "forwarding",
+	"reply_all":   "reply-all",
+	"soft_delete": "soft delete",
+	"read":        "reading a single mail",
+}
+
+// Preflight checks that the configured operation weights make sense for the
+// selected handler and that the indexes they rely on exist. It returns a list
+// of actionable warnings; an empty list means the configuration looks consistent.
+// db may be nil, in which case index checks are skipped.
+func Preflight(ctx context.Context, cfg *config.Config, mailHandler handler.MailHandler, db *database.MongoDB) []string {
+	warnings := make([]string, 0)
+	ops := cfg.StressTest.Operations
+
+	weights := make(map[string]int)
+	for _, op := range operationWeights(ops) {
+		weights[op.name] = op.weight
+	}
+
+	total := 0
+	implemented := 0
+	for _, weighted := range operationWeights(ops) {
+		op, weight := weighted.name, weighted.weight
+		if weight < 0 {
+			warnings = append(warnings, fmt.Sprintf("operation %q has negative weight %d; set it to 0 or more", op, weight))
+			continue
+		}
+		total += weight
+		if weight == 0 {
+			continue
+		}
+
+		if !handler.SupportsOperation(mailHandler, op) {
+			feature, ok := operationFeatures[op]
+			if !ok {
+				feature = "it"
+			}
+			warnings = append(warnings, fmt.Sprintf("operation %q has weight %d but the selected handler does not support %s; it is left out of the mix, set its weight to 0 to silence this", op, weight, feature))
+			continue
+		}
+		if op == "soft_delete" && cfg.StressTest.IncludeDeleted {
+			warnings = append(warnings, fmt.Sprintf("operation %q has weight %d but include_deleted is on, so list and search still return trashed mails", op, weight))
+		}
+		implemented += weight
+	}
+
+	if total == 0 {
+		warnings = append(warnings, "all operation weights are 0; set at least one of create_mail_weight, list_mail_weight, search_weight, draft_weight, forward_weight, reply_all_weight, soft_delete_weight, count_weight")
+	} else if implemented == 0 {
+		warnings = append(warnings, "every weighted operation is unsupported by the selected handler; the stress test cannot run")
+	}
+
+	for _, method := range cfg.Benchmark.SearchMethods {
+		if !knownSearchMethods[method] {
+			warnings = append(warnings, fmt.Sprintf("unknown search method %q in benchmark.search_methods", method))
+		}
+	}
+
+	if db != nil {
+		stored, err := db.Manifests(ctx)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("could not read dataset manifest: %v", err))
+		}
+		for _, problem := range database.ManifestIncompatibilities(stored, CurrentManifest(cfg)) {
+			warnings = append(warnings, "dataset: "+problem)
+		}
+	}
+
+	if db == nil {
+		return warnings
+	}
+	indexes, err := mailIndexKeys(ctx, db)
+	if err != nil {
+		return append(warnings, fmt.Sprintf("could not inspect mail indexes: %v", err))
+	}
+	warnings = append(warnings, searchIndexWarnings(cfg.Benchmark.SearchMethods, indexes)...)
+
+	// The API handler queries through the backend, which manages its own
+	// indexes. The DB handler's list and search filter by userId; its
+	// searches are $regex matches, so no other index helps them.
+	if _, isDB := mailHandler.(*handler.DBHandler); isDB && (weights["list"] > 0 || weights["search"] > 0) && !hasIndexPrefix(indexes, "userId") {
+		warnings = append(warnings, "no index on mails.userId; the handler's list and regex searches will scan the whole collection (run without skipping index creation)")
+	}
+
+	return warnings
+}
+
+// searchIndex is the mails index a search strategy queries through
+type searchIndex struct {
+	description string
+	present     func(indexes []bson.D) bool
+}
+
+// searchMethodIndexes maps each strategy to the index its queries rely on
+var searchMethodIndexes = map[string]searchIndex{
+	"text_search":     {"a text index on subject and content", hasTextIndex},
+	"hybrid":          {"a text index on subject and content", hasTextIndex},
+	"regex":           {"an index on {userId, subject}", indexPrefix("userId", "subject")},
+	"aggregation":     {"an index on {userId, createdAt}", indexPrefix("userId", "createdAt")},
+	"index_optimized": {"an index on {userId, subject, createdAt}", indexPrefix("userId", "subject", "createdAt")},
+	"archive_union":   {"an index on {userId, createdAt}", indexPrefix("userId", "createdAt")},
+}
+
+// searchIndexWarnings names the configured search methods whose index is
+// not built yet
+func searchIndexWarnings(methods []string, indexes []bson.D) []string {
+	var warnings []string
+	for _, method := range methods {
+		required, ok := searchMethodIndexes[method]
+		if !ok || required.present(indexes) {
+			continue
+		}
+		warnings = append(warnings, fmt.Sprintf("search method %q relies on %s, which mails does not have yet; its setup builds it before measuring, which can take a while on a large collection", method, required.description))
+	}
+	return warnings
+}
+
+// CurrentManifest describes the dataset the config would seed; seed counts
+// are left for the caller to fill in
+func CurrentManifest(cfg *config.Config) database.DatasetManifest {
+	scheme := cfg.StressTest.UserIDScheme
+	if scheme == "" {
+		scheme = generator.UserIDSchemeObjectID
+	}
+	return database.DatasetManifest{
+		MailsCollection:   cfg.MongoDB.MailsCollection,
+		SchemaVersion:     database.ManifestSchemaVersion,
+		ThreadsCollection: cfg.MongoDB.ThreadsCollection,
+		ArchiveCollection: cfg.MongoDB.ArchiveCollection,
+		UserIDScheme:      scheme,
+		UserIDPrefix:      cfg.StressTest.UserIDPrefix,
+		UserIDSeed:        cfg.StressTest.UserIDSeed,
+		NumUsers:          cfg.StressTest.NumUsers,
+	}
+}
+
+// mailIndexKeys returns the key document of every index on mails
+func mailIndexKeys(ctx context.Context, db *database.MongoDB) ([]bson.D, error) {
+	cursor, err := db.Mails().Indexes().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var indexes []struct {
+		Key bson.D `bson:"key"`
+	}
+	if err := cursor.All(ctx, &indexes); err != nil {
+		return nil, err
+	}
+
+	keys := make([]bson.D, len(indexes))
+	for i, idx := range indexes {
+		keys[i] = idx.Key
+	}
+	return keys, nil
+}
+
+// hasIndexPrefix reports whether an index starts with fields, in order
+func hasIndexPrefix(indexes []bson.D, fields ...string) bool {
+	for _, keys := range indexes {
+		if len(keys) < len(fields) {
+			continue
+		}
+		matches := true
+		for i, field := range fields {
+			matches = matches && keys[i].Key == field
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
+
+// indexPrefix returns a check for an index starting with fields
+func indexPrefix(fields ...string) func([]bson.D) bool {
+	return func(indexes []bson.D) bool { return hasIndexPrefix(indexes, fields...) }
+}
+
+// hasTextIndex reports whether one of the indexes is a text index
+func hasTextIndex(indexes []bson.D) bool {
+	for _, keys := range indexes {
+		for _, elem := range keys {
+			if elem.Key == "_fts" {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+package benchmark
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"mail-stress-test/config"
+	"mail-stress-test/database"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// containsWarning reports whether one of warnings mentions every part
+func containsWarning(warnings []string, parts ...string) bool {
+	for _, warning := range warnings {
+		matches := true
+		for _, part := range parts {
+			matches = matches && strings.Contains(warning, part)
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
+
+func TestPreflightWeights(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.StressTest.Operations = config.Operations{CreateMailWeight: -10, ListMailWeight: 0}
+	warnings := Preflight(context.Background(), cfg, &fakeHandler{}, nil)
+	if !containsWarning(warnings, `"create"`, "negative weight") {
+		t.Errorf("no warning for the negative create weight: %q", warnings)
+	}
+	if !containsWarning(warnings, "all operation weights are 0") {
+		t.Errorf("no warning that nothing is weighted: %q", warnings)
+	}
+
+	cfg.StressTest.Operations = config.Operations{CreateMailWeight: 50, SearchWeight: 50}
+	if warnings := Preflight(context.Background(), cfg, &fakeHandler{}, nil); len(warnings) != 0 {
+		t.Errorf("supported operations warned: %q", warnings)
+	}
+}
+
+func TestPreflightUnsupportedOperation(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.StressTest.Operations = config.Operations{CreateMailWeight: 50, DraftWeight: 50}
+
+	warnings := Preflight(context.Background(), cfg, &fakeHandler{}, nil)
+	if !containsWarning(warnings, `"draft"`, "does not support drafts") {
+		t.Errorf("no warning for the unsupported draft operation: %q", warnings)
+	}
+	if containsWarning(warnings, "cannot run") {
+		t.Errorf("create is supported, the run should not be reported as impossible: %q", warnings)
+	}
+}
+
+func TestPreflightOnlyUnsupportedOperations(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.StressTest.Operations = config.Operations{ForwardWeight: 10, ReplyAllWeight: 10}
+
+	warnings := Preflight(context.Background(), cfg, &fakeHandler{}, nil)
+	if !containsWarning(warnings, `"forward"`) || !containsWarning(warnings, `"reply_all"`) {
+		t.Errorf("missing per-operation warnings: %q", warnings)
+	}
+	if !containsWarning(warnings, "every weighted operation is unsupported") {
+		t.Errorf("no warning that the run cannot do anything: %q", warnings)
+	}
+}
+
+func TestSearchIndexWarnings(t *testing.T) {
+	methods := []string{"text_search", "regex", "aggregation", "index_optimized"}
+	indexes := []bson.D{
+		{{Key: "_id", Value: 1}},
+		{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}},
+		{{Key: "userId", Value: 1}, {Key: "subject", Value: 1}},
+	}
+
+	warnings := searchIndexWarnings(methods, indexes)
+	if len(warnings) != 2 {
+		t.Fatalf("got %d warnings, want text_search and index_optimized: %q", len(warnings), warnings)
+	}
+	if !containsWarning(warnings, `"text_search"`, "text index") {
+		t.Errorf("no text index warning for text_search: %q", warnings)
+	}
+	if !containsWarning(warnings, `"index_optimized"`, "{userId, subject, createdAt}") {
+		t.Errorf("no compound index warning for index_optimized: %q", warnings)
+	}
+
+	indexes = append(indexes, bson.D{{Key: "_fts", Value: "text"}, {Key: "_ftsx", Value: 1}})
+	if warnings := searchIndexWarnings([]string{"text_search", "hybrid"}, indexes); len(warnings) != 0 {
+		t.Errorf("text strategies warned although a text index exists: %q", warnings)
+	}
+}
+
+// TestPreflightManifestMismatch answers the manifest lookup with a dataset
+// seeded by one config and checks a run with another user ID seed or scheme
+// is warned about, while the seeding config itself is not
+func TestPreflightManifestMismatch(t *testing.T) {
+	seeding := config.DefaultConfig()
+	seeding.StressTest.UserIDSeed = 42
+	stored := CurrentManifest(seeding)
+	raw, err := bson.Marshal(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc bson.D
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	for _, tt := range []struct {
+		name   string
+		change func(cfg *config.Config)
+		want   string // "" = no dataset warning
+	}{
+		{"seeding config", func(cfg *config.Config) {}, ""},
+		{"other seed", func(cfg *config.Config) { cfg.StressTest.UserIDSeed = 7 }, "user_id_seed 42 but it is 7"},
+		{"other scheme", func(cfg *config.Config) { cfg.StressTest.UserIDScheme = "uuid" }, `but user_id_scheme is "uuid"`},
+	} {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			mt.AddMockResponses(
+				mtest.CreateCursorResponse(0, mt.DB.Name()+"."+database.MetadataCollection, mtest.FirstBatch, doc),
+				indexesBuilt(mt))
+			cfg := config.DefaultConfig()
+			cfg.StressTest.UserIDSeed = 42
+			tt.change(cfg)
+
+			warnings := Preflight(context.Background(), cfg, &fakeHandler{}, newMockDB(mt))
+			if tt.want == "" {
+				if containsWarning(warnings, "dataset:") {
+					mt.Errorf("warnings = %q, want no dataset warning", warnings)
+				}
+				return
+			}
+			if !containsWarning(warnings, "dataset:", tt.want) {
+				mt.Errorf("warnings = %q, want a dataset warning mentioning %q", warnings, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"mail-stress-test/generator"
+	"mail-stress-test/handler"
+	"mail-stress-test/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ViewResult holds latency and payload size for one mail view
+type ViewResult struct {
+	View            string        `json:"view"`
+	Queries         int           `json:"queries"`
+	Failed          int           `json:"failed"`
+	AvgLatency      time.Duration `json:"avg_latency"`
+	P95Latency      time.Duration `json:"p95_latency"`
+	AvgResults      float64       `json:"avg_results"`
+	AvgPayloadBytes float64       `json:"avg_payload_bytes"` // BSON size of the decoded results per query
+}
+
+// ProjectionComparison compares full-document list/search queries with the
+// same queries returning only list-view fields
+type ProjectionComparison struct {
+	Queries         int         `json:"queries"`
+	Full            *ViewResult `json:"full"`
+	List            *ViewResult `json:"list"`
+	LatencySavedPct float64     `json:"latency_saved_percent"`
+	PayloadSavedPct float64     `json:"payload_saved_percent"`
+}
+
+// viewQuery is one pre-generated list or search request
+type viewQuery struct {
+	list   *models.ListMailsRequest
+	search *models.SearchMailsRequest
+}
+
+// CompareProjection replays n list/search queries with the full view and
+// then with the list view on a single worker
+func CompareProjection(ctx context.Context, gen *generator.DataGenerator, h handler.MailHandler, n int) (*ProjectionComparison, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("projection comparison needs at least one query")
+	}
+
+	queries := make([]viewQuery, n)
+	for i := range queries {
+		if rand.Intn(2) == 0 {
+			queries[i] = viewQuery{list: gen.GenerateListMailsRequest()}
+		} else {
+			queries[i] = viewQuery{search: gen.GenerateSearchMailsRequest()}
+		}
+	}
+
+	fmt.Printf("\n=== Full vs List-View Projection (%d queries) ===\n", n)
+	comparison := &ProjectionComparison{
+		Queries: n,
+		Full:    runView(ctx, h, models.MailViewFull, queries),
+		List:    runView(ctx, h, models.MailViewList, queries),
+	}
+	if comparison.Full.AvgLatency > 0 {
+		comparison.LatencySavedPct = float64(comparison.Full.AvgLatency-comparison.List.AvgLatency) / float64(comparison.Full.AvgLatency) * 100
+	}
+	if comparison.Full.AvgPayloadBytes > 0 {
+		comparison.PayloadSavedPct = (comparison.Full.AvgPayloadBytes - comparison.List.AvgPayloadBytes) / comparison.Full.AvgPayloadBytes * 100
+	}
+
+	return comparison, nil
+}
+
+// runView executes queries with the given view and summarizes them
+func runView(ctx context.Context, h handler.MailHandler, view string, queries []viewQuery) *ViewResult {
+	name := view
+	if view == models.MailViewFull {
+		name = "full"
+	}
+	stats := newViewStats(name, len(queries))
+	for _, q := range queries {
+		if ctx.Err() != nil {
+			break
+		}
+		stats.run(ctx, h, view, q)
+	}
+	return stats.finish()
+}
+
+// viewStats accumulates the latency and results of queries run one at a
+// time, so comparisons can interleave their passes query by query
+type viewStats struct {
+	result       *ViewResult
+	durations    []time.Duration
+	totalResults int
+	totalBytes   int
+}
+
+// newViewStats starts the summary of n queries reported under name
+func newViewStats(name string, n int) *viewStats {
+	return &viewStats{
+		result:    &ViewResult{View: name, Queries: n},
+		durations: make([]time.Duration, 0, n),
+	}
+}
+
+// run executes q with view through h and records it
+func (s *viewStats) run(ctx context.Context, h handler.MailHandler, view string, q viewQuery) {
+	start := time.Now()
+	var mails []*models.Mail
+	var err error
+	if q.list != nil {
+		req := *q.list
+		req.View = view
+		mails, err = h.ListMails(ctx, &req)
+	} else {
+		req := *q.search
+		req.View = view
+		mails, err = h.SearchMails(ctx, &req)
+	}
+	s.durations = append(s.durations, time.Since(start))
+
+	if err != nil {
+		s.result.Failed++
+		return
+	}
+	s.totalResults += len(mails)
+	for _, mail := range mails {
+		if data, err := bson.Marshal(mail); err == nil {
+			s.totalBytes += len(data)
+		}
+	}
+}
+
+// finish computes the summary of the recorded queries
+func (s *viewStats) finish() *ViewResult {
+	result := s.result
+	result.AvgLatency = averageDuration(s.durations)
+	result.P95Latency = calculatePercentile(s.durations, 95)
+	if succeeded := len(s.durations) - result.Failed; succeeded > 0 {
+		result.AvgResults = float64(s.totalResults) / float64(succeeded)
+		result.AvgPayloadBytes = float64(s.totalBytes) / float64(succeeded)
+	}
+	return result
+}
+
+// String renders the comparison as a side-by-side table
+func (c *ProjectionComparison) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-6s %12s %12s %10s %14s %8s\n", "View", "Avg", "P95", "Results", "Bytes/query", "Failed")
+	for _, r := range []*ViewResult{c.Full, c.List} {
+		fmt.Fprintf(&b, "%-6s %12s %12s %10.1f %14.0f %8d\n",
+			r.View, r.AvgLatency, r.P95Latency, r.AvgResults, r.AvgPayloadBytes, r.Failed)
+	}
+	fmt.Fprintf(&b, "\nList view saves %.1f%% latency and %.1f%% payload\n", c.LatencySavedPct, c.PayloadSavedPct)
+	return b.String()
+}
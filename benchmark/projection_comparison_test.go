@@ -0,0 +1,74 @@
+package benchmark
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"mail-stress-test/generator"
+	"mail-stress-test/models"
+)
+
+// viewMails answers a query with full documents or list-view snippets
+func viewMails(view string) []*models.Mail {
+	content := strings.Repeat("quarterly numbers attached ", 200)
+	mails := make([]*models.Mail, 10)
+	for i := range mails {
+		if view == models.MailViewList {
+			mails[i] = &models.Mail{Subject: "Weekly Report", From: "user-1", Snippet: content[:100]}
+		} else {
+			mails[i] = &models.Mail{Subject: "Weekly Report", From: "user-1", To: []string{"user-2"}, Content: content}
+		}
+	}
+	return mails
+}
+
+// TestCompareProjection replays the same queries with both views and checks
+// each view sees every query, and the list view's smaller payload is
+// reported as a saving
+func TestCompareProjection(t *testing.T) {
+	views := make(map[string]int)
+	h := &fakeHandler{
+		list: func(ctx context.Context, req *models.ListMailsRequest) ([]*models.Mail, error) {
+			views[req.View]++
+			return viewMails(req.View), nil
+		},
+		search: func(ctx context.Context, req *models.SearchMailsRequest) ([]*models.Mail, error) {
+			views[req.View]++
+			if req.View == models.MailViewList && views[req.View] == 1 {
+				return nil, errors.New("search failed")
+			}
+			return viewMails(req.View), nil
+		},
+	}
+	gen, err := generator.NewDataGenerator([]string{"user-1", "user-2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	comparison, err := CompareProjection(context.Background(), gen, h, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if views[models.MailViewFull] != 40 || views[models.MailViewList] != 40 {
+		t.Errorf("queries per view = %v, want 40 each", views)
+	}
+	if comparison.Full.View != "full" || comparison.List.View != models.MailViewList {
+		t.Errorf("views = %q and %q", comparison.Full.View, comparison.List.View)
+	}
+	if comparison.List.Failed > 1 || comparison.Full.Failed != 0 {
+		t.Errorf("failed full %d, list %d; want 0 and at most 1", comparison.Full.Failed, comparison.List.Failed)
+	}
+	if comparison.Full.AvgResults != 10 || comparison.List.AvgResults != 10 {
+		t.Errorf("avg results full %.1f, list %.1f; want 10 each, failures excluded", comparison.Full.AvgResults, comparison.List.AvgResults)
+	}
+	if comparison.List.AvgPayloadBytes >= comparison.Full.AvgPayloadBytes || comparison.PayloadSavedPct < 80 {
+		t.Errorf("payload full %.0f, list %.0f bytes (%.1f%% saved); want the snippets far smaller",
+			comparison.Full.AvgPayloadBytes, comparison.List.AvgPayloadBytes, comparison.PayloadSavedPct)
+	}
+
+	if _, err := CompareProjection(context.Background(), gen, h, 0); err == nil {
+		t.Error("comparison with no queries accepted")
+	}
+}
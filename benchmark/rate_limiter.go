@@ -0,0 +1,80 @@
+package benchmark
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// rateLimiter is a lock-free limiter shared by all workers. Each call to Wait
+// reserves the next free slot with a CAS on a single timestamp and sleeps until
+// it, so the offered rate scales with workers instead of being capped by one
+// ticker channel. A rate of 0 disables limiting. With a burst schedule the
+// interval follows the window each slot falls in.
+type rateLimiter struct {
+	interval int64 // nanoseconds between requests
+	next     int64 // unix nanos of the next free slot
+	schedule *burstSchedule
+}
+
+func newRateLimiter(requestsPerSecond int) *rateLimiter {
+	limiter := &rateLimiter{next: time.Now().UnixNano()}
+	if requestsPerSecond > 0 {
+		limiter.interval = int64(time.Second) / int64(requestsPerSecond)
+	}
+	return limiter
+}
+
+func newBurstRateLimiter(schedule *burstSchedule) *rateLimiter {
+	return &rateLimiter{next: schedule.start, schedule: schedule}
+}
+
+// Wait blocks until the caller's slot arrives. It returns false if stop is
+// closed first.
+func (l *rateLimiter) Wait(stop <-chan struct{}) bool {
+	if l.interval == 0 && l.schedule == nil {
+		select {
+		case <-stop:
+			return false
+		default:
+			return true
+		}
+	}
+
+	var slot int64
+	for {
+		now := time.Now().UnixNano()
+		prev := atomic.LoadInt64(&l.next)
+		slot = prev
+		// Do not let an idle period build up a burst of back-dated slots
+		if slot < now {
+			slot = now
+		}
+		interval := l.interval
+		if l.schedule != nil {
+			slot, interval = l.schedule.slot(slot)
+		}
+		if atomic.CompareAndSwapInt64(&l.next, prev, slot+interval) {
+			break
+		}
+	}
+
+	delay := time.Duration(slot - time.Now().UnixNano())
+	if delay <= 0 {
+		select {
+		case <-stop:
+			return false
+		default:
+			return true
+		}
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-stop:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
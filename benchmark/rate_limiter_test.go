@@ -0,0 +1,73 @@
+package benchmark
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestRateLimiterAchievedRate has many workers share one limiter and checks
+// the achieved rate is the configured target, not capped by contention
+func TestRateLimiterAchievedRate(t *testing.T) {
+	const rate, workers = 2000, 64
+	const window = 500 * time.Millisecond
+
+	limiter := newRateLimiter(rate)
+	stop := make(chan struct{})
+	var granted int64
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for limiter.Wait(stop) {
+				atomic.AddInt64(&granted, 1)
+			}
+		}()
+	}
+	time.Sleep(window)
+	close(stop)
+	wg.Wait()
+
+	achieved := float64(granted) / window.Seconds()
+	if achieved < rate*0.8 || achieved > rate*1.2 {
+		t.Errorf("achieved %.0f req/s with %d workers, want %d ±20%%", achieved, workers, rate)
+	}
+}
+
+func TestRateLimiterUnlimited(t *testing.T) {
+	limiter := newRateLimiter(0)
+	stop := make(chan struct{})
+	start := time.Now()
+	for i := 0; i < 10000; i++ {
+		if !limiter.Wait(stop) {
+			t.Fatal("Wait returned false before stop")
+		}
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("10000 unlimited waits took %s", elapsed)
+	}
+	close(stop)
+	if limiter.Wait(stop) {
+		t.Error("Wait returned true after stop")
+	}
+}
+
+func TestRateLimiterStop(t *testing.T) {
+	limiter := newRateLimiter(1) // next slot one second away after the first
+	stop := make(chan struct{})
+	limiter.Wait(stop)
+
+	done := make(chan bool)
+	go func() { done <- limiter.Wait(stop) }()
+	close(stop)
+	select {
+	case ok := <-done:
+		if ok {
+			t.Error("Wait granted a slot after stop was closed")
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("Wait did not return promptly after stop")
+	}
+}
@@ -0,0 +1,70 @@
+package benchmark
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"mail-stress-test/models"
+)
+
+// flakyHandler fails every other create once and counts the retry that
+// rescued it against a shared budget, like the DB handler's thread retries
+type flakyHandler struct {
+	fakeHandler
+
+	mu                         sync.Mutex
+	budget, calls              int
+	retries, exhausted, denied int64
+}
+
+func (h *flakyHandler) CreateMail(ctx context.Context, req *models.MailRequest) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.calls++
+	if h.calls%2 == 1 {
+		return nil
+	}
+	if h.budget == 0 {
+		h.denied++
+		h.exhausted++
+		return errors.New("write conflict")
+	}
+	h.budget--
+	h.retries++
+	return nil
+}
+
+func (h *flakyHandler) RetryStats() (retries, exhausted int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.retries, h.exhausted
+}
+
+func (h *flakyHandler) RetryBudgetDenied() int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.denied
+}
+
+// TestRetryBudgetReported checks the run reports the handler's retries,
+// what they cost per success and how often the budget ran out
+func TestRetryBudgetReported(t *testing.T) {
+	h := &flakyHandler{budget: 20}
+	st, _ := newTestStressTest(t, h)
+
+	result, err := st.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Retries != 20 {
+		t.Errorf("Retries = %d, want the budget of 20", result.Retries)
+	}
+	if result.RetryBudgetDenied == 0 || result.RetryBudgetDenied != result.FailedRequests {
+		t.Errorf("RetryBudgetDenied = %d, want every one of the %d failures", result.RetryBudgetDenied, result.FailedRequests)
+	}
+	if want := 20 / float64(result.SuccessRequests); result.RetriesPerSuccess != want {
+		t.Errorf("RetriesPerSuccess = %.4f, want %.4f", result.RetriesPerSuccess, want)
+	}
+}
@@ -0,0 +1,472 @@
+package benchmark
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"mail-stress-test/config"
+	"mail-stress-test/handler"
+	"mail-stress-test/models"
+)
+
+// ScenarioStepStats counts one scenario step's outcomes
+type ScenarioStepStats struct {
+	Name      string `json:"name,omitempty"`
+	Op        string `json:"op"`
+	Succeeded int64  `json:"succeeded"`
+	Failed    int64  `json:"failed"`
+}
+
+// ScenarioStats summarizes the scenario iterations of a run
+type ScenarioStats struct {
+	Completed   int64               `json:"completed"`   // iterations that ran every step
+	Failed      int64               `json:"failed"`      // iterations stopped by a failed step
+	Interrupted int64               `json:"interrupted"` // iterations cut off by the end of the run
+	Steps       []ScenarioStepStats `json:"steps"`
+}
+
+func (s *ScenarioStats) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d completed, %d failed, %d interrupted", s.Completed, s.Failed, s.Interrupted)
+	for i, step := range s.Steps {
+		name := step.Op
+		if step.Name != "" {
+			name = step.Name + ":" + step.Op
+		}
+		fmt.Fprintf(&b, "; %d.%s %d ok/%d failed", i+1, name, step.Succeeded, step.Failed)
+	}
+	return b.String()
+}
+
+// scenarioOp is an operation a scenario step can run
+type scenarioOp struct {
+	operation string   // OperationStats key the request is recorded under
+	required  []string // params that must be set
+	optional  []string
+	outputs   []string // fields later steps can reference
+	run       func(ctx context.Context, st *StressTest, params map[string]string) (map[string]string, error)
+}
+
+// mailOutputs are the fields of a mail a step exposes
+var mailOutputs = []string{"id", "from", "to", "subject", "thread_id", "user"}
+
+// resultOutputs are the fields of a list or search step: the result count
+// and the fields of its first mail
+var resultOutputs = append([]string{"count"}, mailOutputs...)
+
+var scenarioOps = map[string]scenarioOp{
+	"create": {
+		operation: "create",
+		optional:  []string{"from", "to", "subject", "content"},
+		outputs:   mailOutputs,
+		run: func(ctx context.Context, st *StressTest, p map[string]string) (map[string]string, error) {
+			return st.scenarioCreate(ctx, p, "")
+		},
+	},
+	"reply": {
+		operation: "create",
+		required:  []string{"id"},
+		optional:  []string{"from", "to", "subject", "content"},
+		outputs:   mailOutputs,
+		run: func(ctx context.Context, st *StressTest, p map[string]string) (map[string]string, error) {
+			return st.scenarioCreate(ctx, p, p["id"])
+		},
+	},
+	"read": {
+		operation: "read",
+		required:  []string{"id"},
+		outputs:   mailOutputs,
+		run: func(ctx context.Context, st *StressTest, p map[string]string) (map[string]string, error) {
+			reader, ok := st.handler.(handler.MailReader)
+			if !ok {
+				return nil, fmt.Errorf("handler does not support reading a mail by ID")
+			}
+			mail, err := reader.GetMail(ctx, p["id"])
+			if err != nil {
+				return nil, err
+			}
+			return mailFields(mail), nil
+		},
+	},
+	"list": {
+		operation: "list",
+		optional:  []string{"user", "limit"},
+		outputs:   resultOutputs,
+		run: func(ctx context.Context, st *StressTest, p map[string]string) (map[string]string, error) {
+			req := st.generator.GenerateListMailsRequest()
+			req.UserID, req.Offset = p["user"], 0
+			if err := scenarioLimit(p, &req.Limit); err != nil {
+				return nil, err
+			}
+			mails, err := st.listInbox(ctx, req)
+			return resultFields(mails), err
+		},
+	},
+	"search": {
+		operation: "search",
+		optional:  []string{"user", "term", "limit"},
+		outputs:   resultOutputs,
+		run: func(ctx context.Context, st *StressTest, p map[string]string) (map[string]string, error) {
+			req := st.generator.GenerateSearchMailsRequestFor(p["user"])
+			if term := p["term"]; term != "" {
+				req.SearchTerm, req.Hot, req.Miss = term, false, false
+			}
+			if err := scenarioLimit(p, &req.Limit); err != nil {
+				return nil, err
+			}
+			mails, err := st.handler.SearchMails(ctx, req)
+			return resultFields(mails), err
+		},
+	},
+	"count": {
+		operation: "count",
+		optional:  []string{"user"},
+		outputs:   []string{"count"},
+		run: func(ctx context.Context, st *StressTest, p map[string]string) (map[string]string, error) {
+			count, err := st.handler.CountMails(ctx, p["user"])
+			return map[string]string{"count": strconv.FormatInt(count, 10)}, err
+		},
+	},
+	"forward": {
+		operation: "forward",
+		required:  []string{"id"},
+		optional:  []string{"from", "to"},
+		run: func(ctx context.Context, st *StressTest, p map[string]string) (map[string]string, error) {
+			forwarder, ok := st.handler.(handler.ForwardHandler)
+			if !ok {
+				return nil, fmt.Errorf("handler does not support forwarding")
+			}
+			req := st.generator.GenerateForwardMailRequest(p["id"], p["from"])
+			if to := scenarioList(p["to"]); len(to) > 0 {
+				req.To = to
+			}
+			return nil, forwarder.ForwardMail(ctx, req)
+		},
+	},
+	"reply_all": {
+		operation: "reply_all",
+		required:  []string{"id"},
+		optional:  []string{"from", "content"},
+		run: func(ctx context.Context, st *StressTest, p map[string]string) (map[string]string, error) {
+			replier, ok := st.handler.(handler.ReplyAllHandler)
+			if !ok {
+				return nil, fmt.Errorf("handler does not support reply-all")
+			}
+			req := st.generator.GenerateReplyAllRequest(p["id"], p["from"])
+			if content := p["content"]; content != "" {
+				req.Content = content
+			}
+			return nil, replier.ReplyAll(ctx, req)
+		},
+	},
+	"soft_delete": {
+		operation: "soft_delete",
+		required:  []string{"id"},
+		run: func(ctx context.Context, st *StressTest, p map[string]string) (map[string]string, error) {
+			deleter, ok := st.handler.(handler.SoftDeleteHandler)
+			if !ok {
+				return nil, fmt.Errorf("handler does not support soft delete")
+			}
+			return nil, deleter.SoftDeleteMail(ctx, p["id"])
+		},
+	},
+}
+
+// scenarioUserParams default to the iteration's user when not set
+var scenarioUserParams = map[string]bool{"from": true, "user": true}
+
+// scenarioRef matches ${user} and ${step.field} references
+var scenarioRef = regexp.MustCompile(`\$\{([A-Za-z0-9_]+)(?:\.([A-Za-z0-9_]+))?\}`)
+
+// scenarioStep is a validated step
+type scenarioStep struct {
+	name   string
+	opName string
+	op     scenarioOp
+	params map[string]string
+}
+
+// compileScenario checks every step's operation and params, and that each
+// reference names the iteration user or an output of an earlier named step
+func compileScenario(cfg config.ScenarioConfig) ([]scenarioStep, error) {
+	if len(cfg.Steps) == 0 {
+		return nil, fmt.Errorf("scenario has no steps")
+	}
+
+	outputs := make(map[string][]string) // step name -> fields
+	steps := make([]scenarioStep, len(cfg.Steps))
+	for i, s := range cfg.Steps {
+		label := fmt.Sprintf("step %d (%s)", i+1, s.Op)
+		op, ok := scenarioOps[s.Op]
+		if !ok {
+			return nil, fmt.Errorf("%s: unknown operation %q (want one of %s)", label, s.Op, scenarioOpNames())
+		}
+		if s.Name == "user" {
+			return nil, fmt.Errorf("%s: \"user\" is reserved for the iteration's user", label)
+		}
+		if _, dup := outputs[s.Name]; dup && s.Name != "" {
+			return nil, fmt.Errorf("%s: step name %q is already used", label, s.Name)
+		}
+
+		allowed := append(append([]string{}, op.required...), op.optional...)
+		for _, param := range op.required {
+			if s.Params[param] == "" {
+				return nil, fmt.Errorf("%s: missing param %q", label, param)
+			}
+		}
+		params := make(map[string]string, len(allowed))
+		for param, value := range s.Params {
+			if !containsString(allowed, param) {
+				return nil, fmt.Errorf("%s: unknown param %q (accepted: %s)", label, param, strings.Join(allowed, ", "))
+			}
+			for _, ref := range scenarioRef.FindAllStringSubmatch(value, -1) {
+				if err := checkScenarioRef(ref, outputs); err != nil {
+					return nil, fmt.Errorf("%s: param %q: %w", label, param, err)
+				}
+			}
+			params[param] = value
+		}
+		for param := range scenarioUserParams {
+			if _, set := params[param]; !set && containsString(allowed, param) {
+				params[param] = "${user}"
+			}
+		}
+
+		steps[i] = scenarioStep{name: s.Name, opName: s.Op, op: op, params: params}
+		if s.Name != "" {
+			outputs[s.Name] = op.outputs
+		}
+	}
+	return steps, nil
+}
+
+// checkScenarioRef validates one ${...} reference against earlier steps
+func checkScenarioRef(ref []string, outputs map[string][]string) error {
+	name, field := ref[1], ref[2]
+	if name == "user" && field == "" {
+		return nil
+	}
+	fields, ok := outputs[name]
+	if !ok {
+		return fmt.Errorf("%s refers to no earlier named step", ref[0])
+	}
+	if !containsString(fields, field) {
+		return fmt.Errorf("%s: step %q outputs %s", ref[0], name, strings.Join(fields, ", "))
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// scenarioVars holds the iteration user and earlier steps' outputs, keyed
+// "user" and "step.field"
+type scenarioVars map[string]string
+
+// expand substitutes every reference in params; a reference to an output
+// the earlier step didn't produce (e.g. the id of an empty search) fails
+func (v scenarioVars) expand(params map[string]string) (map[string]string, error) {
+	expanded := make(map[string]string, len(params))
+	for param, value := range params {
+		var missing string
+		expanded[param] = scenarioRef.ReplaceAllStringFunc(value, func(ref string) string {
+			key := strings.TrimSuffix(strings.TrimPrefix(ref, "${"), "}")
+			value, ok := v[key]
+			if !ok && missing == "" {
+				missing = ref
+			}
+			return value
+		})
+		if missing != "" {
+			return nil, fmt.Errorf("param %q: %s has no value in this iteration", param, missing)
+		}
+	}
+	return expanded, nil
+}
+
+// set records a step's outputs
+func (v scenarioVars) set(step string, outputs map[string]string) {
+	if step == "" {
+		return
+	}
+	for field, value := range outputs {
+		v[step+"."+field] = value
+	}
+}
+
+// scenarioCreate sends a new mail, or a reply to replyToID, from params
+func (st *StressTest) scenarioCreate(ctx context.Context, p map[string]string, replyToID string) (map[string]string, error) {
+	req := st.generator.GenerateCreateMailRequestFrom(p["from"], replyToID)
+	if to := scenarioList(p["to"]); len(to) > 0 {
+		req.To = to
+	}
+	if subject := p["subject"]; subject != "" {
+		req.Subject = subject
+	}
+	if content := p["content"]; content != "" {
+		req.Content = content
+	}
+
+	outputs := map[string]string{"from": req.From, "user": req.From, "subject": req.Subject}
+	if len(req.To) > 0 {
+		outputs["to"] = req.To[0]
+	}
+	creator, ok := st.handler.(handler.MailCreator)
+	if !ok {
+		return outputs, st.sendCreate(ctx, req)
+	}
+	id, err := creator.CreateMailWithID(ctx, req)
+	if err != nil && st.deadLetter != nil && !errors.Is(err, handler.ErrCircuitOpen) {
+		st.deadLetter.record(req, err)
+	}
+	if id != "" {
+		outputs["id"] = id
+	}
+	return outputs, err
+}
+
+// scenarioLimit parses an optional limit param into limit
+func scenarioLimit(p map[string]string, limit *int) error {
+	if p["limit"] == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(p["limit"])
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid limit %q", p["limit"])
+	}
+	*limit = n
+	return nil
+}
+
+// scenarioList splits a comma-separated param
+func scenarioList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// mailFields exposes a mail's fields to later steps
+func mailFields(mail *models.Mail) map[string]string {
+	fields := map[string]string{
+		"id":        mail.ID.Hex(),
+		"from":      mail.From,
+		"subject":   mail.Subject,
+		"thread_id": mail.ThreadID,
+		"user":      mail.UserID,
+	}
+	if len(mail.To) > 0 {
+		fields["to"] = mail.To[0]
+	}
+	return fields
+}
+
+// resultFields exposes the result count and the first mail's fields
+func resultFields(mails []*models.Mail) map[string]string {
+	fields := map[string]string{}
+	if len(mails) > 0 {
+		fields = mailFields(mails[0])
+	}
+	fields["count"] = strconv.Itoa(len(mails))
+	return fields
+}
+
+// scenarioTracker counts iteration and step outcomes across workers
+type scenarioTracker struct {
+	steps       []scenarioStep
+	succeeded   []int64
+	failed      []int64
+	completed   int64
+	iterFailed  int64
+	interrupted int64
+}
+
+func newScenarioTracker(steps []scenarioStep) *scenarioTracker {
+	return &scenarioTracker{steps: steps, succeeded: make([]int64, len(steps)), failed: make([]int64, len(steps))}
+}
+
+func (t *scenarioTracker) result() *ScenarioStats {
+	stats := &ScenarioStats{
+		Completed:   atomic.LoadInt64(&t.completed),
+		Failed:      atomic.LoadInt64(&t.iterFailed),
+		Interrupted: atomic.LoadInt64(&t.interrupted),
+	}
+	for i, step := range t.steps {
+		stats.Steps = append(stats.Steps, ScenarioStepStats{
+			Name:      step.name,
+			Op:        step.opName,
+			Succeeded: atomic.LoadInt64(&t.succeeded[i]),
+			Failed:    atomic.LoadInt64(&t.failed[i]),
+		})
+	}
+	return stats
+}
+
+// scenarioWorker runs the scenario's steps in order, one iteration after
+// another, each step waiting for the rate limiter like a generated
+// operation. The iteration user is the worker's sticky user, or random.
+func (st *StressTest) scenarioWorker(ctx context.Context, stop <-chan struct{}, endTime time.Time, limiter *rateLimiter, user *stickyUser, result *StressTestResult, totalDuration *int64) {
+	t := st.scenario
+	for time.Now().Before(endTime) {
+		vars := scenarioVars{"user": st.generator.GetRandomUserID()}
+		if user != nil {
+			vars["user"] = user.next()
+		}
+
+		completed := true
+		for i, step := range t.steps {
+			if !limiter.Wait(stop) || !time.Now().Before(endTime) {
+				atomic.AddInt64(&t.interrupted, 1)
+				return
+			}
+
+			params, err := vars.expand(step.params)
+			if err == nil {
+				var outputs map[string]string
+				err = st.runOperation(ctx, result, totalDuration, step.op.operation, func(opCtx context.Context) error {
+					var runErr error
+					outputs, runErr = step.op.run(opCtx, st, params)
+					return runErr
+				})
+				vars.set(step.name, outputs)
+			}
+			if err != nil {
+				atomic.AddInt64(&t.failed[i], 1)
+				completed = false
+				break
+			}
+			atomic.AddInt64(&t.succeeded[i], 1)
+		}
+		if completed {
+			atomic.AddInt64(&t.completed, 1)
+		} else {
+			atomic.AddInt64(&t.iterFailed, 1)
+		}
+	}
+}
+
+// scenarioOpNames lists the operations a scenario step accepts
+func scenarioOpNames() string {
+	names := make([]string, 0, len(scenarioOps))
+	for name := range scenarioOps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
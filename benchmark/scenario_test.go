@@ -0,0 +1,97 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"mail-stress-test/config"
+	"mail-stress-test/models"
+)
+
+// scenarioHandler is a fakeHandler that hands out sequential mail IDs on
+// create and records the IDs it is asked to read
+type scenarioHandler struct {
+	fakeHandler
+	mu      sync.Mutex
+	created []string
+	read    []string
+}
+
+func (h *scenarioHandler) CreateMailWithID(ctx context.Context, req *models.MailRequest) (string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	id := fmt.Sprintf("mail-%d", len(h.created)+1)
+	h.created = append(h.created, id)
+	return id, nil
+}
+
+func (h *scenarioHandler) GetMail(ctx context.Context, mailID string) (*models.Mail, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.read = append(h.read, mailID)
+	return &models.Mail{Subject: "read"}, nil
+}
+
+// TestScenarioCreateThenRead runs a create step followed by a read of
+// ${sent.id} and checks every read asked for the mail its iteration created
+func TestScenarioCreateThenRead(t *testing.T) {
+	h := &scenarioHandler{}
+	st, cfg := newTestStressTest(t, h)
+	cfg.StressTest.ConcurrentWorkers = 1
+	cfg.StressTest.Duration = 50 * time.Millisecond
+	cfg.StressTest.Scenario = config.ScenarioConfig{Enabled: true, Steps: []config.ScenarioStep{
+		{Name: "sent", Op: "create"},
+		{Op: "read", Params: map[string]string{"id": "${sent.id}"}},
+	}}
+
+	result, err := st.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h.read) == 0 {
+		t.Fatal("no read step ran")
+	}
+	// The run may end between an iteration's create and its read
+	if len(h.created)-len(h.read) > 1 {
+		t.Fatalf("%d creates, %d reads; want a read after every create", len(h.created), len(h.read))
+	}
+	for i, id := range h.read {
+		if id != h.created[i] {
+			t.Errorf("read %d asked for %s, want the created %s", i, id, h.created[i])
+		}
+	}
+
+	stats := result.Scenario
+	if stats == nil || stats.Completed != int64(len(h.read)) || stats.Failed != 0 {
+		t.Fatalf("scenario stats = %+v, want %d completed iterations", stats, len(h.read))
+	}
+	if stats.Steps[1].Op != "read" || stats.Steps[1].Succeeded != int64(len(h.read)) {
+		t.Errorf("read step = %+v, want %d successes", stats.Steps[1], len(h.read))
+	}
+}
+
+func TestCompileScenarioErrors(t *testing.T) {
+	tests := []struct {
+		steps []config.ScenarioStep
+		want  string
+	}{
+		{nil, "no steps"},
+		{[]config.ScenarioStep{{Op: "teleport"}}, "unknown operation"},
+		{[]config.ScenarioStep{{Op: "read"}}, `missing param "id"`},
+		{[]config.ScenarioStep{{Op: "count", Params: map[string]string{"limit": "5"}}}, `unknown param "limit"`},
+		{[]config.ScenarioStep{{Op: "read", Params: map[string]string{"id": "${sent.id}"}}, {Name: "sent", Op: "create"}}, "no earlier named step"},
+		{[]config.ScenarioStep{{Name: "sent", Op: "create"}, {Op: "read", Params: map[string]string{"id": "${sent.count}"}}}, `step "sent" outputs`},
+		{[]config.ScenarioStep{{Name: "user", Op: "create"}}, "reserved"},
+		{[]config.ScenarioStep{{Name: "a", Op: "create"}, {Name: "a", Op: "create"}}, "already used"},
+	}
+	for _, tt := range tests {
+		_, err := compileScenario(config.ScenarioConfig{Enabled: true, Steps: tt.steps})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("compileScenario(%+v) = %v, want an error containing %q", tt.steps, err, tt.want)
+		}
+	}
+}
@@ -2,12 +2,16 @@ package benchmark
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"mail-stress-test/config"
 	"mail-stress-test/database"
 	"mail-stress-test/generator"
+	"mail-stress-test/models"
 	"mail-stress-test/search"
 )
 
@@ -16,30 +20,86 @@ type SearchBenchmarkResult struct {
 	StrategyName   string        `json:"strategy_name"`
 	Description    string        `json:"description"`
 	SetupDuration  time.Duration `json:"setup_duration"`
+	IndexBuildTime time.Duration `json:"index_build_time"` // time spent waiting for index builds to finish
 	AvgDuration    time.Duration `json:"avg_duration"`
 	MinDuration    time.Duration `json:"min_duration"`
 	MaxDuration    time.Duration `json:"max_duration"`
 	P50Duration    time.Duration `json:"p50_duration"`
 	P95Duration    time.Duration `json:"p95_duration"`
 	P99Duration    time.Duration `json:"p99_duration"`
-	TotalQueries   int           `json:"total_queries"`
-	SuccessQueries int           `json:"success_queries"`
-	FailedQueries  int           `json:"failed_queries"`
-	TotalResults   int           `json:"total_results"`
-	AvgResults     float64       `json:"avg_results"`
+
+	TailPercentiles []PercentileValue `json:"tail_percentiles,omitempty"` // report.tail_percentiles, e.g. P99.9
+
+	TotalQueries   int     `json:"total_queries"`
+	SuccessQueries int     `json:"success_queries"`
+	FailedQueries  int     `json:"failed_queries"`
+	TotalResults   int     `json:"total_results"`
+	AvgResults     float64 `json:"avg_results"`
+
+	// EmptyQueries succeeded with no results; MissQueries of them were
+	// meant to match nothing (benchmark.miss_query_ratio)
+	EmptyQueries int `json:"empty_queries"`
+	MissQueries  int `json:"miss_queries,omitempty"`
+
+	// Hot/cold split when a hot/cold term mix is configured
+	HotQueries      int           `json:"hot_queries,omitempty"`
+	ColdQueries     int           `json:"cold_queries,omitempty"`
+	HotAvgDuration  time.Duration `json:"hot_avg_duration,omitempty"`
+	ColdAvgDuration time.Duration `json:"cold_avg_duration,omitempty"`
+	HotP95Duration  time.Duration `json:"hot_p95_duration,omitempty"`
+	ColdP95Duration time.Duration `json:"cold_p95_duration,omitempty"`
+
+	// Queries with a from:/to: participant filter, measured separately
+	ParticipantQueries     int           `json:"participant_queries,omitempty"`
+	ParticipantAvgDuration time.Duration `json:"participant_avg_duration,omitempty"`
+	ParticipantP95Duration time.Duration `json:"participant_p95_duration,omitempty"`
+
+	// Unsupported is set when the strategy could not run because its setup
+	// is missing (e.g. no text index); such queries are not counted as failures
+	Unsupported       bool   `json:"unsupported,omitempty"`
+	UnsupportedReason string `json:"unsupported_reason,omitempty"`
+
+	// InsufficientData says why no latency figures could be measured
+	InsufficientData string `json:"insufficient_data,omitempty"`
+
+	// DegradedSetup is set when some of the strategy's indexes failed to
+	// build and it ran with the ones that succeeded
+	DegradedSetup bool                  `json:"degraded_setup,omitempty"`
+	SetupFailures []search.IndexFailure `json:"setup_failures,omitempty"`
+
+	// Cache preparation before the measured iterations
+	PlanCacheCleared bool `json:"plan_cache_cleared,omitempty"`
+	WarmUpQueries    int  `json:"warm_up_queries,omitempty"`
+
+	// Warm-up phase latency, excluded from the measured figures above:
+	// ColdFirstQuery is the first query after setup, which pays for loading
+	// indexes and planning; WarmUpAvgDuration averages the whole phase
+	ColdFirstQuery    time.Duration `json:"cold_first_query,omitempty"`
+	WarmUpAvgDuration time.Duration `json:"warm_up_avg_duration,omitempty"`
+
+	// Verification compares sampled result sets with a ground-truth scan
+	// when verification is enabled
+	Verification *VerificationResult `json:"verification,omitempty"`
 }
 
+// indexBuildTimeout bounds how long a strategy waits for its indexes to build
+const indexBuildTimeout = 10 * time.Minute
+
 // SearchBenchmark benchmarks different search strategies
 type SearchBenchmark struct {
 	config     *config.Config
 	db         *database.MongoDB
 	generator  *generator.DataGenerator
 	strategies []search.SearchStrategy
+	verify     bool
+
+	// capabilities gates strategies on server features when probed
+	capabilities *database.ServerCapabilities
 }
 
 // NewSearchBenchmark creates a new search benchmark
 func NewSearchBenchmark(cfg *config.Config, db *database.MongoDB, gen *generator.DataGenerator) *SearchBenchmark {
-	return &SearchBenchmark{
+	sb := &SearchBenchmark{
 		config:    cfg,
 		db:        db,
 		generator: gen,
@@ -47,9 +107,26 @@ func NewSearchBenchmark(cfg *config.Config, db *database.MongoDB, gen *generator
 			search.NewTextSearchStrategy(),
 			search.NewRegexSearchStrategy(),
 			search.NewAggregationSearchStrategy(),
-			search.NewIndexOptimizedStrategy(),
+			search.NewIndexOptimizedStrategy(cfg.Benchmark.CollationLocale, cfg.Benchmark.CollationStrength),
+			search.NewHybridSearchStrategy(cfg.Benchmark.RecencyHalfLife),
 		},
 	}
+	// Hot/cold split: compare the union with the hot-only regex search
+	if db.ArchiveCollection != "" {
+		sb.strategies = append(sb.strategies, search.NewArchiveUnionStrategy())
+	}
+	return sb
+}
+
+// SetVerify enables checking a sample of each strategy's result sets against
+// a linear scan; this is expensive on large collections
+func (sb *SearchBenchmark) SetVerify(verify bool) {
+	sb.verify = verify
+}
+
+// SetCapabilities skips strategies the probed server cannot run
+func (sb *SearchBenchmark) SetCapabilities(caps *database.ServerCapabilities) {
+	sb.capabilities = caps
 }
 
 // Run executes the benchmark for all strategies
@@ -60,11 +137,30 @@ func (sb *SearchBenchmark) Run(ctx context.Context) (map[string]*SearchBenchmark
 	fmt.Printf("Testing %d strategies with %d iterations each\n\n",
 		len(sb.strategies), sb.config.Benchmark.Iterations)
 
+	// Every strategy replays the identical query set so the comparison
+	// isn't skewed by query-mix variance
+	queries := sb.generateQuerySet()
+	warmUpQueries := sb.generateQueries(sb.config.Benchmark.StrategyWarmUpQueries)
+
 	for _, strategy := range sb.strategies {
 		fmt.Printf("Testing strategy: %s\n", strategy.GetName())
 		fmt.Printf("  Description: %s\n", strategy.GetDescription())
 
-		result, err := sb.benchmarkStrategy(ctx, strategy)
+		if checker, ok := strategy.(search.CapabilityChecker); ok && sb.capabilities != nil {
+			if err := checker.CheckCapabilities(sb.capabilities); err != nil {
+				sb.capabilities.Skip("strategy:"+strategy.GetName(), err.Error())
+				results[strategy.GetName()] = &SearchBenchmarkResult{
+					StrategyName:      strategy.GetName(),
+					Description:       strategy.GetDescription(),
+					Unsupported:       true,
+					UnsupportedReason: "server capability: " + err.Error(),
+				}
+				fmt.Printf("  ⏭️  Skipped: %v\n\n", err)
+				continue
+			}
+		}
+
+		result, err := sb.benchmarkStrategy(ctx, strategy, warmUpQueries, queries)
 		if err != nil {
 			fmt.Printf("  ❌ Failed: %v\n\n", err)
 			continue
@@ -72,23 +168,115 @@ func (sb *SearchBenchmark) Run(ctx context.Context) (map[string]*SearchBenchmark
 
 		results[strategy.GetName()] = result
 
+		if result.Unsupported {
+			fmt.Printf("  ⚠️  Unsupported: %s\n", result.UnsupportedReason)
+			if strings.Contains(result.UnsupportedReason, search.ErrSetupMissing.Error()) {
+				fmt.Printf("  💡 Hint: run the strategy setup (or `-seed`) so the required indexes exist\n")
+			}
+			fmt.Println()
+			continue
+		}
+
 		// Print results
-		fmt.Printf("  ✅ Setup: %s\n", result.SetupDuration)
+		if result.DegradedSetup {
+			fmt.Printf("  ⚠️  Degraded setup: %s (index build wait: %s)\n", result.SetupDuration, result.IndexBuildTime)
+			for _, failure := range result.SetupFailures {
+				fmt.Printf("     ✗ %s\n", failure)
+			}
+		} else {
+			fmt.Printf("  ✅ Setup: %s (index build wait: %s)\n", result.SetupDuration, result.IndexBuildTime)
+		}
+		if result.PlanCacheCleared || result.WarmUpQueries > 0 {
+			fmt.Printf("  🧊 Plan cache cleared: %t, warm-up queries: %d\n", result.PlanCacheCleared, result.WarmUpQueries)
+		}
+		if result.ColdFirstQuery > 0 {
+			fmt.Printf("  🥶 Cold first query: %s, warm-up avg: %s (not measured)\n", result.ColdFirstQuery, result.WarmUpAvgDuration)
+		}
+		if result.InsufficientData != "" {
+			fmt.Printf("  ⚠️  Insufficient data: %s\n\n", result.InsufficientData)
+			continue
+		}
 		fmt.Printf("  📊 Avg: %s, Min: %s, Max: %s\n",
 			result.AvgDuration, result.MinDuration, result.MaxDuration)
 		fmt.Printf("  📈 P50: %s, P95: %s, P99: %s\n",
 			result.P50Duration, result.P95Duration, result.P99Duration)
+		if len(result.TailPercentiles) > 0 {
+			fmt.Printf("  🔭 Tail: %s, Max: %s\n", FormatPercentiles(result.TailPercentiles), result.MaxDuration)
+		}
 		fmt.Printf("  ✓ Success: %d/%d (%.1f%%)\n",
 			result.SuccessQueries, result.TotalQueries,
 			float64(result.SuccessQueries)/float64(result.TotalQueries)*100)
-		fmt.Printf("  📧 Avg Results: %.1f mails per query\n\n", result.AvgResults)
+		if result.HotQueries > 0 {
+			fmt.Printf("  🔥 Hot: %d queries, Avg %s, P95 %s | ❄️  Cold: %d queries, Avg %s, P95 %s\n",
+				result.HotQueries, result.HotAvgDuration, result.HotP95Duration,
+				result.ColdQueries, result.ColdAvgDuration, result.ColdP95Duration)
+		}
+		if result.ParticipantQueries > 0 {
+			fmt.Printf("  👤 from:/to: filtered: %d queries, Avg %s, P95 %s\n",
+				result.ParticipantQueries, result.ParticipantAvgDuration, result.ParticipantP95Duration)
+		}
+		fmt.Printf("  📧 Avg Results: %.1f mails per query\n", result.AvgResults)
+		if result.SuccessQueries > 0 {
+			fmt.Printf("  🕳️  Empty: %d/%d (%.1f%%)", result.EmptyQueries, result.SuccessQueries,
+				float64(result.EmptyQueries)/float64(result.SuccessQueries)*100)
+			if result.MissQueries > 0 {
+				fmt.Printf(", %d intentional misses", result.MissQueries)
+			}
+			fmt.Println()
+		}
+
+		if sb.verify {
+			result.Verification = verifyStrategy(ctx, sb.db, strategy, sampleQueries(queries, sb.config.Benchmark.VerifySampleSize))
+			printVerification(result.Verification)
+		}
+		fmt.Println()
 	}
 
 	return results, nil
 }
 
-// benchmarkStrategy benchmarks a single search strategy
-func (sb *SearchBenchmark) benchmarkStrategy(ctx context.Context, strategy search.SearchStrategy) (*SearchBenchmarkResult, error) {
+// generateQuerySet pre-generates the search requests replayed against every strategy
+func (sb *SearchBenchmark) generateQuerySet() []*models.SearchMailsRequest {
+	return sb.generateQueries(sb.config.Benchmark.Iterations)
+}
+
+// generateQueries generates n search requests
+func (sb *SearchBenchmark) generateQueries(n int) []*models.SearchMailsRequest {
+	if n < 0 {
+		n = 0
+	}
+	queries := make([]*models.SearchMailsRequest, n)
+	for i := range queries {
+		queries[i] = sb.generator.GenerateSearchMailsRequest()
+	}
+	return queries
+}
+
+// warmUpStrategy runs the unmeasured warm-up queries and returns how many
+// completed with the latency of each successful one, the first being the
+// cold query; it stops early if the strategy's setup is missing, which the
+// measured iterations then report
+func warmUpStrategy(ctx context.Context, db *database.MongoDB, strategy search.SearchStrategy, queries []*models.SearchMailsRequest) (ran int, durations []time.Duration) {
+	for _, req := range queries {
+		if ctx.Err() != nil {
+			break
+		}
+		start := time.Now()
+		_, err := strategy.SearchMails(ctx, db, req)
+		duration := time.Since(start)
+		if search.IsUnsupported(err) {
+			break
+		}
+		ran++
+		if err == nil {
+			durations = append(durations, duration)
+		}
+	}
+	return ran, durations
+}
+
+// benchmarkStrategy benchmarks a single search strategy against the given query set
+func (sb *SearchBenchmark) benchmarkStrategy(ctx context.Context, strategy search.SearchStrategy, warmUpQueries, queries []*models.SearchMailsRequest) (*SearchBenchmarkResult, error) {
 	result := &SearchBenchmarkResult{
 		StrategyName: strategy.GetName(),
 		Description:  strategy.GetDescription(),
@@ -98,24 +286,64 @@ func (sb *SearchBenchmark) benchmarkStrategy(ctx context.Context, strategy searc
 	// Setup database for this strategy
 	setupStart := time.Now()
 	if err := strategy.SetupDatabase(ctx, sb.db); err != nil {
-		return nil, fmt.Errorf("setup failed: %w", err)
+		var setupErr *search.IndexSetupError
+		if !errors.As(err, &setupErr) || !setupErr.Degraded() {
+			return nil, fmt.Errorf("setup failed: %w", err)
+		}
+		// Keep going with the indexes that were built
+		result.DegradedSetup = true
+		result.SetupFailures = setupErr.Failed
+	}
+
+	// Wait until the indexes are actually queryable before measuring
+	buildStart := time.Now()
+	buildCtx, cancel := context.WithTimeout(ctx, indexBuildTimeout)
+	err := sb.db.WaitForIndexBuilds(buildCtx, sb.db.MailsCollection, 100*time.Millisecond)
+	cancel()
+	if err != nil {
+		// Older servers don't report build state; measure anyway
+		fmt.Printf("  ⚠️  Could not confirm index builds finished: %v\n", err)
 	}
+	result.IndexBuildTime = time.Since(buildStart)
 	result.SetupDuration = time.Since(setupStart)
 
-	// Wait a bit for indexes to be ready
-	time.Sleep(100 * time.Millisecond)
+	// Start every strategy from a comparable cache state
+	if sb.config.Benchmark.ClearPlanCache {
+		if err := sb.db.ClearPlanCache(ctx, sb.db.MailsCollection); err != nil {
+			fmt.Printf("  ⚠️  Could not clear plan cache: %v\n", err)
+		} else {
+			result.PlanCacheCleared = true
+		}
+	}
+
+	// Warm-up phase: without configured warm-up queries the first measured
+	// query is replayed once, so the cold query never lands in the results
+	if len(warmUpQueries) == 0 && len(queries) > 0 {
+		warmUpQueries = queries[:1]
+	}
+	var warmUpDurations []time.Duration
+	result.WarmUpQueries, warmUpDurations = warmUpStrategy(ctx, sb.db, strategy, warmUpQueries)
+	if len(warmUpDurations) > 0 {
+		result.ColdFirstQuery = warmUpDurations[0]
+		result.WarmUpAvgDuration = averageDuration(warmUpDurations)
+	}
 
 	// Collect durations for percentile calculation
-	durations := make([]time.Duration, 0, sb.config.Benchmark.Iterations)
+	durations := make([]time.Duration, 0, len(queries))
+	var hotDurations, coldDurations, participantDurations []time.Duration
 
 	// Run benchmark iterations
-	for i := 0; i < sb.config.Benchmark.Iterations; i++ {
-		req := sb.generator.GenerateSearchMailsRequest()
-
+	for _, req := range queries {
 		start := time.Now()
 		mails, err := strategy.SearchMails(ctx, sb.db, req)
 		duration := time.Since(start)
 
+		if search.IsUnsupported(err) {
+			result.Unsupported = true
+			result.UnsupportedReason = err.Error()
+			break
+		}
+
 		result.TotalQueries++
 
 		if err != nil {
@@ -125,7 +353,21 @@ func (sb *SearchBenchmark) benchmarkStrategy(ctx context.Context, strategy searc
 
 		result.SuccessQueries++
 		result.TotalResults += len(mails)
+		if len(mails) == 0 {
+			result.EmptyQueries++
+		}
+		if req.Miss {
+			result.MissQueries++
+		}
 		durations = append(durations, duration)
+		if req.Hot {
+			hotDurations = append(hotDurations, duration)
+		} else {
+			coldDurations = append(coldDurations, duration)
+		}
+		if req.HasParticipantFilter() {
+			participantDurations = append(participantDurations, duration)
+		}
 
 		// Update min/max
 		if duration < result.MinDuration {
@@ -151,73 +393,57 @@ func (sb *SearchBenchmark) benchmarkStrategy(ctx context.Context, strategy searc
 		result.P50Duration = calculatePercentile(durations, 50)
 		result.P95Duration = calculatePercentile(durations, 95)
 		result.P99Duration = calculatePercentile(durations, 99)
+		result.TailPercentiles = calculateTailPercentiles(durations, tailPercentiles(sb.config.Report.TailPercentiles))
+	}
+
+	// Hot/cold split is only meaningful when hot terms were generated
+	if len(hotDurations) > 0 {
+		result.HotQueries = len(hotDurations)
+		result.ColdQueries = len(coldDurations)
+		result.HotAvgDuration = averageDuration(hotDurations)
+		result.ColdAvgDuration = averageDuration(coldDurations)
+		result.HotP95Duration = calculatePercentile(hotDurations, 95)
+		result.ColdP95Duration = calculatePercentile(coldDurations, 95)
+	}
+	if len(participantDurations) > 0 {
+		result.ParticipantQueries = len(participantDurations)
+		result.ParticipantAvgDuration = averageDuration(participantDurations)
+		result.ParticipantP95Duration = calculatePercentile(participantDurations, 95)
+	}
+	if result.InsufficientData = searchInsufficientData(result); result.InsufficientData != "" {
+		result.MinDuration = 0
 	}
 
 	return result, nil
 }
 
-// calculatePercentile calculates the nth percentile of durations
-func calculatePercentile(durations []time.Duration, percentile int) time.Duration {
+// averageDuration returns the mean of durations, or 0 if empty
+func averageDuration(durations []time.Duration) time.Duration {
 	if len(durations) == 0 {
 		return 0
 	}
-
-	// Simple bubble sort for small datasets
-	sorted := make([]time.Duration, len(durations))
-	copy(sorted, durations)
-
-	for i := 0; i < len(sorted); i++ {
-		for j := i + 1; j < len(sorted); j++ {
-			if sorted[i] > sorted[j] {
-				sorted[i], sorted[j] = sorted[j], sorted[i]
-			}
-		}
+	var total time.Duration
+	for _, d := range durations {
+		total += d
 	}
+	return total / time.Duration(len(durations))
+}
 
-	index := (len(sorted) * percentile) / 100
-	if index >= len(sorted) {
-		index = len(sorted) - 1
+// calculatePercentile calculates the nth percentile (0-100, fractional
+// allowed) of durations using the nearest-rank method
+func calculatePercentile(durations []time.Duration, percentile float64) time.Duration {
+	if len(durations) == 0 {
+		return 0
 	}
 
-	return sorted[index]
+	sorted := make([]time.Duration, len(durations))
+	copy(sorted, durations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	return sorted[nearestRank(len(sorted), percentile)]
 }
 
 // GenerateComparisonReport generates a textual comparison of all strategies
 func (sb *SearchBenchmark) GenerateComparisonReport(results map[string]*SearchBenchmarkResult) string {
-	report := "\n=== Search Strategy Comparison Report ===\n\n"
-
-	// Find best performers
-	var fastestAvg, fastestP99, mostReliable string
-	var minAvg, minP99 time.Duration = time.Hour, time.Hour
-	var maxSuccess float64 = 0
-
-	for name, result := range results {
-		if result.SuccessQueries > 0 {
-			successRate := float64(result.SuccessQueries) / float64(result.TotalQueries)
-
-			if result.AvgDuration < minAvg {
-				minAvg = result.AvgDuration
-				fastestAvg = name
-			}
-			if result.P99Duration < minP99 {
-				minP99 = result.P99Duration
-				fastestP99 = name
-			}
-			if successRate > maxSuccess {
-				maxSuccess = successRate
-				mostReliable = name
-			}
-		}
-	}
-
-	report += fmt.Sprintf("🏆 Fastest Average: %s (%s)\n", fastestAvg, minAvg)
-	report += fmt.Sprintf("🏆 Fastest P99: %s (%s)\n", fastestP99, minP99)
-	report += fmt.Sprintf("🏆 Most Reliable: %s (%.1f%% success)\n\n", mostReliable, maxSuccess*100)
-
-	report += "Recommendations:\n"
-	report += fmt.Sprintf("  • For best average performance: Use '%s'\n", fastestAvg)
-	report += fmt.Sprintf("  • For consistent latency: Use '%s'\n", fastestP99)
-	report += fmt.Sprintf("  • For reliability: Use '%s'\n", mostReliable)
-
-	return report
+	return SummarizeComparison(results).String()
 }
@@ -0,0 +1,392 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"mail-stress-test/config"
+	"mail-stress-test/database"
+	"mail-stress-test/generator"
+	"mail-stress-test/models"
+	"mail-stress-test/search"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// newMockDB returns a MongoDB over mt's mock deployment; tests queue the
+// server's replies with mt.AddMockResponses
+func newMockDB(mt *mtest.T) *database.MongoDB {
+	return &database.MongoDB{
+		Client:            mt.Client,
+		Database:          mt.DB,
+		MailsCollection:   database.DefaultMailsCollection,
+		ThreadsCollection: database.DefaultThreadsCollection,
+	}
+}
+
+// indexesBuilt is the listIndexes reply once no index build is in progress
+func indexesBuilt(mt *mtest.T) bson.D {
+	return mtest.CreateCursorResponse(0, mt.DB.Name()+"."+database.DefaultMailsCollection, mtest.FirstBatch,
+		bson.D{{Key: "name", Value: "_id_"}, {Key: "key", Value: bson.D{{Key: "_id", Value: 1}}}})
+}
+
+// recordingStrategy is a SearchStrategy that records every request it
+// serves and answers through its search hook when set; its setup hook, when
+// set, decides the setup result
+type recordingStrategy struct {
+	name     string
+	setup    func() error
+	search   func(req *models.SearchMailsRequest) ([]*models.Mail, error)
+	requests []*models.SearchMailsRequest
+}
+
+func (s *recordingStrategy) GetName() string        { return s.name }
+func (s *recordingStrategy) GetDescription() string { return "records its requests" }
+
+func (s *recordingStrategy) SetupDatabase(ctx context.Context, db *database.MongoDB) error {
+	if s.setup != nil {
+		return s.setup()
+	}
+	return nil
+}
+
+func (s *recordingStrategy) SearchMails(ctx context.Context, db *database.MongoDB, req *models.SearchMailsRequest) ([]*models.Mail, error) {
+	s.requests = append(s.requests, req)
+	if s.search != nil {
+		return s.search(req)
+	}
+	return nil, nil
+}
+
+// newTestSearchBenchmark returns a benchmark of strategies over mt's mock
+// deployment, queueing one finished index build reply per strategy
+func newTestSearchBenchmark(mt *mtest.T, cfg *config.Config, strategies ...*recordingStrategy) *SearchBenchmark {
+	mt.Helper()
+	gen, err := generator.NewDataGenerator([]string{"user-1", "user-2", "user-3", "user-4"})
+	if err != nil {
+		mt.Fatal(err)
+	}
+	sb := &SearchBenchmark{config: cfg, db: newMockDB(mt), generator: gen}
+	for _, strategy := range strategies {
+		sb.strategies = append(sb.strategies, strategy)
+		mt.AddMockResponses(indexesBuilt(mt))
+	}
+	return sb
+}
+
+// TestStrategiesReplaySameQuerySet runs three strategies and checks each was
+// sent the identical sequence of requests, warm-up included
+func TestStrategiesReplaySameQuerySet(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("same sequence", func(mt *mtest.T) {
+		cfg := config.DefaultConfig()
+		cfg.Benchmark.Iterations = 25
+		cfg.Benchmark.StrategyWarmUpQueries = 0
+		strategies := []*recordingStrategy{{name: "a"}, {name: "b"}, {name: "c"}}
+		sb := newTestSearchBenchmark(mt, cfg, strategies...)
+
+		results, err := sb.Run(context.Background())
+		if err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+
+		want := strategies[0].requests
+		// One replayed warm-up query, then every measured iteration
+		if len(want) != cfg.Benchmark.Iterations+1 {
+			t.Fatalf("strategy a served %d requests, want %d", len(want), cfg.Benchmark.Iterations+1)
+		}
+		for _, strategy := range strategies[1:] {
+			if len(strategy.requests) != len(want) {
+				t.Fatalf("strategy %s served %d requests, want %d", strategy.name, len(strategy.requests), len(want))
+			}
+			for i, req := range strategy.requests {
+				if req != want[i] {
+					t.Errorf("strategy %s request %d = %+v, want %+v", strategy.name, i, req, want[i])
+				}
+			}
+		}
+		for _, strategy := range strategies {
+			if got := results[strategy.name].TotalQueries; got != cfg.Benchmark.Iterations {
+				t.Errorf("strategy %s measured %d queries, want %d", strategy.name, got, cfg.Benchmark.Iterations)
+			}
+		}
+	})
+}
+
+// indexBuilding is the listIndexes reply while an index build is in progress
+func indexBuilding(mt *mtest.T) bson.D {
+	return mtest.CreateCursorResponse(0, mt.DB.Name()+"."+database.DefaultMailsCollection, mtest.FirstBatch,
+		bson.D{{Key: "name", Value: "_id_"}, {Key: "key", Value: bson.D{{Key: "_id", Value: 1}}}},
+		bson.D{{Key: "name", Value: "subject_1"}, {Key: "key", Value: bson.D{{Key: "subject", Value: 1}}},
+			{Key: "buildUUID", Value: "in-progress"}})
+}
+
+// TestFirstQueryWaitsForIndexBuilds reports an index build in progress on the
+// first two polls and checks no query runs before the build finished, with
+// the wait recorded as index build time
+func TestFirstQueryWaitsForIndexBuilds(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("wait for build", func(mt *mtest.T) {
+		cfg := config.DefaultConfig()
+		cfg.Benchmark.Iterations = 3
+		mt.AddMockResponses(indexBuilding(mt), indexBuilding(mt))
+
+		polls := func() int {
+			n := 0
+			for _, event := range mt.GetAllStartedEvents() {
+				if event.CommandName == "listIndexes" {
+					n++
+				}
+			}
+			return n
+		}
+		strategy := &recordingStrategy{name: "a", search: func(req *models.SearchMailsRequest) ([]*models.Mail, error) {
+			if n := polls(); n != 3 {
+				t.Errorf("query ran after %d index build polls, want 3", n)
+			}
+			return nil, nil
+		}}
+		sb := newTestSearchBenchmark(mt, cfg, strategy)
+
+		results, err := sb.Run(context.Background())
+		if err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+		if len(strategy.requests) == 0 {
+			t.Fatal("strategy never queried")
+		}
+		// Two polls found the build running, 100ms apart
+		if got := results["a"].IndexBuildTime; got < 200*time.Millisecond {
+			t.Errorf("IndexBuildTime = %s, want at least the 200ms spent waiting", got)
+		}
+	})
+}
+
+// TestUnsupportedQueryReported checks a strategy refusing the query set is
+// reported as unsupported rather than as failing every query
+func TestUnsupportedQueryReported(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("scoped phrase", func(mt *mtest.T) {
+		cfg := config.DefaultConfig()
+		cfg.Benchmark.Iterations = 5
+		strategy := &recordingStrategy{name: "text", search: func(req *models.SearchMailsRequest) ([]*models.Mail, error) {
+			return nil, fmt.Errorf("%w: subject-scoped text search needs a single-word term", search.ErrUnsupportedQuery)
+		}}
+		sb := newTestSearchBenchmark(mt, cfg, strategy)
+
+		results, err := sb.Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		result := results["text"]
+		if !result.Unsupported || result.FailedQueries != 0 {
+			t.Errorf("result = %+v, want unsupported with no failed queries", result)
+		}
+	})
+}
+
+// TestStrategiesSkippedOnOldServer probes a MongoDB 4.0 server and checks the
+// aggregation strategy, which needs 4.2, is skipped and recorded while a
+// plain strategy still runs
+func TestStrategiesSkippedOnOldServer(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("4.0 standalone", func(mt *mtest.T) {
+		cfg := config.DefaultConfig()
+		cfg.Benchmark.Iterations = 3
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "version", Value: "4.0.28"}), mtest.CreateSuccessResponse())
+		caps, err := newMockDB(mt).ProbeCapabilities(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		plain := &recordingStrategy{name: "plain"}
+		sb := newTestSearchBenchmark(mt, cfg, plain)
+		sb.strategies = append([]search.SearchStrategy{search.NewAggregationSearchStrategy()}, sb.strategies...)
+		sb.SetCapabilities(caps)
+
+		results, err := sb.Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if agg := results["aggregation"]; agg == nil || !agg.Unsupported || agg.TotalQueries != 0 {
+			t.Errorf("aggregation result = %+v, want it skipped as unsupported", agg)
+		}
+		if len(caps.Skipped) != 1 || caps.Skipped[0].Feature != "strategy:aggregation" {
+			t.Errorf("skipped = %+v, want the aggregation strategy recorded", caps.Skipped)
+		}
+		if len(plain.requests) == 0 || results["plain"].Unsupported {
+			t.Error("plain strategy did not run")
+		}
+	})
+}
+
+// TestWarmUpBeforeMeasurement clears the plan cache and runs the configured
+// warm-up queries for each strategy, slow ones standing in for cold caches,
+// and checks they all ran before the measured iterations and none of their
+// latency was measured
+func TestWarmUpBeforeMeasurement(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("warm then measure", func(mt *mtest.T) {
+		cfg := config.DefaultConfig()
+		cfg.Benchmark.Iterations = 10
+		cfg.Benchmark.StrategyWarmUpQueries = 5
+		cfg.Benchmark.ClearPlanCache = true
+
+		var strategies []*recordingStrategy
+		for _, name := range []string{"a", "b"} {
+			strategy := &recordingStrategy{name: name}
+			strategy.search = func(req *models.SearchMailsRequest) ([]*models.Mail, error) {
+				if len(strategy.requests) <= cfg.Benchmark.StrategyWarmUpQueries {
+					time.Sleep(20 * time.Millisecond)
+				}
+				return nil, nil
+			}
+			strategies = append(strategies, strategy)
+		}
+		sb := newTestSearchBenchmark(mt, cfg)
+		for _, strategy := range strategies {
+			sb.strategies = append(sb.strategies, strategy)
+			mt.AddMockResponses(indexesBuilt(mt), mtest.CreateSuccessResponse())
+		}
+
+		results, err := sb.Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		cleared := 0
+		for _, event := range mt.GetAllStartedEvents() {
+			if event.CommandName == "planCacheClear" {
+				cleared++
+				if got := event.Command.Lookup("planCacheClear").StringValue(); got != database.DefaultMailsCollection {
+					t.Errorf("cleared the plan cache of %s, want %s", got, database.DefaultMailsCollection)
+				}
+			}
+		}
+		if cleared != 2 {
+			t.Errorf("plan cache cleared %d times, want once per strategy", cleared)
+		}
+
+		warmUp := strategies[0].requests[:5]
+		for _, strategy := range strategies {
+			result := results[strategy.name]
+			if len(strategy.requests) != 15 || result.WarmUpQueries != 5 || result.TotalQueries != 10 {
+				t.Errorf("strategy %s served %d requests, %d warm-up and %d measured; want 15, 5 and 10",
+					strategy.name, len(strategy.requests), result.WarmUpQueries, result.TotalQueries)
+				continue
+			}
+			for i, req := range strategy.requests[:5] {
+				if req != warmUp[i] {
+					t.Errorf("strategy %s warm-up query %d differs from strategy a's", strategy.name, i)
+				}
+			}
+			if !result.PlanCacheCleared {
+				t.Errorf("strategy %s: plan cache not reported cleared", strategy.name)
+			}
+			if result.ColdFirstQuery < 20*time.Millisecond || result.MaxDuration >= 20*time.Millisecond {
+				t.Errorf("strategy %s: cold first query %s, measured max %s; want the slow warm-up excluded",
+					strategy.name, result.ColdFirstQuery, result.MaxDuration)
+			}
+		}
+	})
+}
+
+// TestColdFirstQueryReported makes the first query of each strategy slow and
+// the rest of the warm-up moderately slow, and checks the cold query and the
+// warm-up average are reported apart from measured percentiles that only
+// see fast queries, with or without configured warm-up queries
+func TestColdFirstQueryReported(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	for _, warmUp := range []int{0, 3} {
+		mt.Run(fmt.Sprintf("%d warm-up queries", warmUp), func(mt *mtest.T) {
+			cfg := config.DefaultConfig()
+			cfg.Benchmark.Iterations = 10
+			cfg.Benchmark.StrategyWarmUpQueries = warmUp
+			cfg.Benchmark.ClearPlanCache = false
+
+			strategy := &recordingStrategy{name: "a"}
+			strategy.search = func(req *models.SearchMailsRequest) ([]*models.Mail, error) {
+				switch n := len(strategy.requests); {
+				case n == 1:
+					time.Sleep(40 * time.Millisecond)
+				case n <= warmUp:
+					time.Sleep(10 * time.Millisecond)
+				}
+				return nil, nil
+			}
+			sb := newTestSearchBenchmark(mt, cfg, strategy)
+
+			results, err := sb.Run(context.Background())
+			if err != nil {
+				mt.Fatal(err)
+			}
+			result := results["a"]
+			// Without configured warm-up the first measured query runs once first
+			wantWarmUp := warmUp
+			if wantWarmUp == 0 {
+				wantWarmUp = 1
+				if strategy.requests[0] != strategy.requests[1] {
+					mt.Error("cold query is not a replay of the first measured query")
+				}
+			}
+			if result.WarmUpQueries != wantWarmUp || result.TotalQueries != 10 || len(strategy.requests) != wantWarmUp+10 {
+				mt.Fatalf("%d warm-up and %d measured of %d requests, want %d and 10", result.WarmUpQueries, result.TotalQueries, len(strategy.requests), wantWarmUp)
+			}
+
+			if result.ColdFirstQuery < 40*time.Millisecond {
+				mt.Errorf("ColdFirstQuery = %s, want the 40ms first query", result.ColdFirstQuery)
+			}
+			if warmUp > 0 && (result.WarmUpAvgDuration < 20*time.Millisecond || result.WarmUpAvgDuration >= result.ColdFirstQuery) {
+				mt.Errorf("WarmUpAvgDuration = %s, want the warm-up average between 20ms and the cold %s", result.WarmUpAvgDuration, result.ColdFirstQuery)
+			}
+			if result.P99Duration >= 10*time.Millisecond || result.MaxDuration >= 10*time.Millisecond {
+				mt.Errorf("measured P99 %s, max %s; want the warm-up excluded", result.P99Duration, result.MaxDuration)
+			}
+		})
+	}
+}
+
+// TestDegradedSetupStillMeasured fails one index of a strategy and all of
+// another's, and checks the first is measured with its failure reported
+// while the second is left out
+func TestDegradedSetupStillMeasured(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("degraded", func(mt *mtest.T) {
+		cfg := config.DefaultConfig()
+		cfg.Benchmark.Iterations = 5
+		failure := search.IndexFailure{Name: "mail_userid_content_idx", CodeName: "IndexOptionsConflict", Reason: "conflict"}
+		degraded := &recordingStrategy{name: "degraded", setup: func() error {
+			return &search.IndexSetupError{Failed: []search.IndexFailure{failure}, Created: []string{"mail_userid_subject_idx"}}
+		}}
+		broken := &recordingStrategy{name: "broken", setup: func() error {
+			return &search.IndexSetupError{Failed: []search.IndexFailure{failure}}
+		}}
+		// Only the degraded strategy gets as far as waiting for its indexes
+		sb := newTestSearchBenchmark(mt, cfg, degraded)
+		sb.strategies = append(sb.strategies, broken)
+
+		results, err := sb.Run(context.Background())
+		if err != nil {
+			mt.Fatal(err)
+		}
+		result := results["degraded"]
+		if result == nil || !result.DegradedSetup || result.TotalQueries != 5 {
+			mt.Fatalf("degraded result = %+v, want 5 measured queries with a degraded setup", result)
+		}
+		if len(result.SetupFailures) != 1 || result.SetupFailures[0].Name != failure.Name {
+			mt.Errorf("setup failures = %v, want %s", result.SetupFailures, failure.Name)
+		}
+		if _, ok := results["broken"]; ok || len(broken.requests) != 0 {
+			mt.Errorf("strategy without any index ran %d queries, want it skipped", len(broken.requests))
+		}
+	})
+}
@@ -0,0 +1,180 @@
+package benchmark
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// defaultSelfMonitorInterval is how often the tool samples its own
+// goroutines and open file descriptors
+const defaultSelfMonitorInterval = 5 * time.Second
+
+// leakWindows is how many consecutive slices of the run the samples are split
+// into; a resource whose minimum rises in every slice is reported as leaking.
+// Comparing minima ignores goroutines and sockets held by in-flight requests.
+const leakWindows = 4
+
+// SelfMonitorStats reports the load generator's own goroutine and open file
+// descriptor counts over the run, to catch leaks in the tool itself
+type SelfMonitorStats struct {
+	Samples         int    `json:"samples"`
+	StartGoroutines int    `json:"start_goroutines"`
+	PeakGoroutines  int    `json:"peak_goroutines"`
+	EndGoroutines   int    `json:"end_goroutines"`
+	StartFDs        int    `json:"start_fds,omitempty"` // -1 when open FDs can't be counted on this OS
+	PeakFDs         int    `json:"peak_fds,omitempty"`
+	EndFDs          int    `json:"end_fds,omitempty"`
+	GoroutineLeak   bool   `json:"goroutine_leak,omitempty"`
+	FDLeak          bool   `json:"fd_leak,omitempty"`
+	Warning         string `json:"warning,omitempty"`
+}
+
+func (s *SelfMonitorStats) String() string {
+	out := fmt.Sprintf("goroutines %d → %d (peak %d)", s.StartGoroutines, s.EndGoroutines, s.PeakGoroutines)
+	if s.StartFDs >= 0 {
+		out += fmt.Sprintf(", open FDs %d → %d (peak %d)", s.StartFDs, s.EndFDs, s.PeakFDs)
+	}
+	return out + fmt.Sprintf(" over %d samples", s.Samples)
+}
+
+// resourceSample is one reading of the tool's own resources
+type resourceSample struct {
+	goroutines int
+	fds        int
+}
+
+// sampleResources reads the current goroutine and open FD counts
+func sampleResources() resourceSample {
+	return resourceSample{goroutines: runtime.NumGoroutine(), fds: openFDs()}
+}
+
+// openFDs counts the process's open file descriptors, or returns -1 where
+// /proc/self/fd is unavailable
+func openFDs() int {
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return -1
+	}
+	// ReadDir's own handle on the directory is listed too
+	return len(entries) - 1
+}
+
+// selfMonitor samples the tool's resources in the background and warns once
+// per resource as soon as it has grown through every window
+type selfMonitor struct {
+	samples []resourceSample
+	warned  map[string]bool
+}
+
+func newSelfMonitor() *selfMonitor {
+	return &selfMonitor{samples: []resourceSample{sampleResources()}, warned: make(map[string]bool)}
+}
+
+// run samples every interval until stop is closed. Only run touches the
+// samples, so the result is read after it returns.
+func (m *selfMonitor) run(stop <-chan struct{}, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultSelfMonitorInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			m.samples = append(m.samples, sampleResources())
+			for _, leak := range m.leaks() {
+				if !m.warned[leak] {
+					m.warned[leak] = true
+					fmt.Printf("⚠️  Load generator %s keep growing (%s); the tool itself may be leaking\n", leak, m.growth(leak))
+				}
+			}
+		}
+	}
+}
+
+// leaks names the resources whose minimum rose in every window
+func (m *selfMonitor) leaks() []string {
+	var leaks []string
+	if growing(m.samples, func(s resourceSample) int { return s.goroutines }) {
+		leaks = append(leaks, "goroutines")
+	}
+	if m.samples[0].fds >= 0 && growing(m.samples, func(s resourceSample) int { return s.fds }) {
+		leaks = append(leaks, "open FDs")
+	}
+	return leaks
+}
+
+// growth renders a resource's first and latest count
+func (m *selfMonitor) growth(resource string) string {
+	first, last := m.samples[0], m.samples[len(m.samples)-1]
+	if resource == "goroutines" {
+		return fmt.Sprintf("%d → %d", first.goroutines, last.goroutines)
+	}
+	return fmt.Sprintf("%d → %d", first.fds, last.fds)
+}
+
+// growing splits samples into leakWindows slices and reports whether the
+// minimum of each slice is higher than the one before
+func growing(samples []resourceSample, value func(resourceSample) int) bool {
+	if len(samples) < leakWindows*2 {
+		return false
+	}
+	size := len(samples) / leakWindows
+	prev := -1
+	for w := 0; w < leakWindows; w++ {
+		end := (w + 1) * size
+		if w == leakWindows-1 {
+			end = len(samples)
+		}
+		low := value(samples[w*size])
+		for _, s := range samples[w*size : end] {
+			if v := value(s); v < low {
+				low = v
+			}
+		}
+		if low <= prev {
+			return false
+		}
+		prev = low
+	}
+	return true
+}
+
+// result takes a final sample and summarizes the run
+func (m *selfMonitor) result() *SelfMonitorStats {
+	m.samples = append(m.samples, sampleResources())
+	first, last := m.samples[0], m.samples[len(m.samples)-1]
+	stats := &SelfMonitorStats{
+		Samples:         len(m.samples),
+		StartGoroutines: first.goroutines,
+		EndGoroutines:   last.goroutines,
+		StartFDs:        first.fds,
+		EndFDs:          last.fds,
+	}
+	for _, s := range m.samples {
+		if s.goroutines > stats.PeakGoroutines {
+			stats.PeakGoroutines = s.goroutines
+		}
+		if s.fds > stats.PeakFDs {
+			stats.PeakFDs = s.fds
+		}
+	}
+	if first.fds < 0 {
+		stats.PeakFDs = -1
+	}
+
+	var warnings []string
+	for _, leak := range m.leaks() {
+		warnings = append(warnings, fmt.Sprintf("%s grew through the whole run (%s)", leak, m.growth(leak)))
+		stats.GoroutineLeak = stats.GoroutineLeak || leak == "goroutines"
+		stats.FDLeak = stats.FDLeak || leak == "open FDs"
+	}
+	stats.Warning = strings.Join(warnings, "; ")
+	return stats
+}
@@ -0,0 +1,58 @@
+package benchmark
+
+import "testing"
+
+// samplesOf builds samples with the given goroutine counts and a steady FD
+// count
+func samplesOf(fds int, goroutines ...int) []resourceSample {
+	samples := make([]resourceSample, len(goroutines))
+	for i, g := range goroutines {
+		samples[i] = resourceSample{goroutines: g, fds: fds}
+	}
+	return samples
+}
+
+func TestGrowingNeedsRisingMinimum(t *testing.T) {
+	goroutines := func(s resourceSample) int { return s.goroutines }
+	tests := []struct {
+		name    string
+		samples []resourceSample
+		want    bool
+	}{
+		{"steady climb", samplesOf(10, 10, 11, 12, 13, 14, 15, 16, 17), true},
+		// In-flight requests spike the count but the floor stays put
+		{"busy but flat", samplesOf(10, 10, 50, 10, 60, 10, 55, 10, 70), false},
+		{"climb then recovery", samplesOf(10, 10, 12, 14, 16, 18, 20, 22, 12), false},
+		{"too few samples", samplesOf(10, 10, 11, 12), false},
+	}
+	for _, tt := range tests {
+		if got := growing(tt.samples, goroutines); got != tt.want {
+			t.Errorf("%s: growing = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestSelfMonitorReportsLeak feeds a monitor goroutine counts that grow
+// through the run and checks only the goroutine leak is reported
+func TestSelfMonitorReportsLeak(t *testing.T) {
+	m := &selfMonitor{samples: samplesOf(-1, 10, 12, 14, 16, 18, 20, 22, 24), warned: map[string]bool{}}
+	if leaks := m.leaks(); len(leaks) != 1 || leaks[0] != "goroutines" {
+		t.Fatalf("leaks = %v, want goroutines only; FDs can't be counted", leaks)
+	}
+	if got := m.growth("goroutines"); got != "10 → 24" {
+		t.Errorf("growth = %q, want 10 → 24", got)
+	}
+
+	// The final sample is real: far fewer goroutines than the synthetic
+	// ones, so the run no longer looks like a leak
+	stats := m.result()
+	if stats.GoroutineLeak || stats.Warning != "" {
+		t.Errorf("stats = %+v, want no leak once the count dropped", stats)
+	}
+	if stats.StartGoroutines != 10 || stats.PeakGoroutines != 24 || stats.Samples != 9 {
+		t.Errorf("goroutines start %d, peak %d over %d samples; want 10, 24 and 9", stats.StartGoroutines, stats.PeakGoroutines, stats.Samples)
+	}
+	if stats.StartFDs != -1 || stats.PeakFDs != -1 {
+		t.Errorf("FDs start %d, peak %d; want -1 when they can't be counted", stats.StartFDs, stats.PeakFDs)
+	}
+}
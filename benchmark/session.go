@@ -0,0 +1,238 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"mail-stress-test/config"
+	"mail-stress-test/handler"
+	"mail-stress-test/models"
+)
+
+// SessionStats summarizes the virtual user sessions of a run
+type SessionStats struct {
+	Completed     int64         `json:"completed"`   // sessions that ran every step
+	Interrupted   int64         `json:"interrupted"` // sessions cut off by the end of the run
+	AvgDuration   time.Duration `json:"avg_duration"`
+	P50Duration   time.Duration `json:"p50_duration"`
+	P95Duration   time.Duration `json:"p95_duration"`
+	AvgOperations float64       `json:"avg_operations"` // requests per completed session
+	AvgThinkTime  time.Duration `json:"avg_think_time"` // pause between steps
+}
+
+// String formats the session summary for reports
+func (s *SessionStats) String() string {
+	return fmt.Sprintf("%d completed (%d interrupted), duration avg=%s p50=%s p95=%s, %.1f ops/session, think time avg=%s",
+		s.Completed, s.Interrupted, s.AvgDuration, s.P50Duration, s.P95Duration, s.AvgOperations, s.AvgThinkTime)
+}
+
+// sessionState is what a virtual user remembers between steps
+type sessionState struct {
+	userID string
+	inbox  []*models.Mail // mails listed when the inbox was opened
+	read   []*models.Mail // mails opened so far
+}
+
+// sessionStep is one scripted action of a session; steps whose ready
+// check fails are skipped without a request or a think-time pause
+type sessionStep struct {
+	operation string
+	ready     func(s *sessionState) bool
+	run       func(ctx context.Context, s *sessionState) error
+}
+
+// sessionScript draws the steps of one session: open the inbox, read some
+// of the listed mails, then maybe search and maybe reply
+func (st *StressTest) sessionScript(cfg config.SessionConfig) []sessionStep {
+	steps := []sessionStep{{operation: "list", run: st.sessionList}}
+
+	if reader, ok := st.handler.(handler.MailReader); ok {
+		for i := 0; i < cfg.ReadMails; i++ {
+			i := i
+			steps = append(steps, sessionStep{
+				operation: "read",
+				ready:     func(s *sessionState) bool { return i < len(s.inbox) },
+				run: func(ctx context.Context, s *sessionState) error {
+					mail, err := reader.GetMail(ctx, s.inbox[i].ID.Hex())
+					if err != nil {
+						return err
+					}
+					s.read = append(s.read, mail)
+					return nil
+				},
+			})
+		}
+	}
+
+	if rand.Float64() < cfg.SearchProbability {
+		steps = append(steps, sessionStep{operation: "search", run: st.sessionSearch})
+	}
+	if rand.Float64() < cfg.ReplyProbability {
+		steps = append(steps, sessionStep{
+			operation: "create",
+			ready:     func(s *sessionState) bool { return len(s.read) > 0 },
+			run:       st.sessionReply,
+		})
+	}
+	return steps
+}
+
+// sessionList opens the first page of the user's inbox
+func (st *StressTest) sessionList(ctx context.Context, s *sessionState) error {
+	req := st.generator.GenerateListMailsRequest()
+	req.UserID = s.userID
+	req.Offset = 0
+	mails, err := st.listInbox(ctx, req)
+	if err != nil {
+		return err
+	}
+	s.inbox = mails
+	return nil
+}
+
+// sessionSearch searches the user's own mails
+func (st *StressTest) sessionSearch(ctx context.Context, s *sessionState) error {
+	req := st.generator.GenerateSearchMailsRequest()
+	req.UserID = s.userID
+	return st.sendSearch(ctx, req)
+}
+
+// sessionReply answers a random mail the user has read
+func (st *StressTest) sessionReply(ctx context.Context, s *sessionState) error {
+	mail := s.read[rand.Intn(len(s.read))]
+	req := st.generator.GenerateCreateMailRequest(mail.ID.Hex())
+	req.From = s.userID
+	req.To = []string{mail.From}
+	if mail.From == s.userID {
+		req.To = mail.To // following up on a sent mail
+	}
+	req.Cc, req.Bcc = nil, nil
+	return st.sendCreate(ctx, req)
+}
+
+// sessionWorker runs back-to-back sessions as one virtual user until the
+// run ends. It is closed loop: the next request waits for the previous one
+// and a think time, so no rate limiter applies.
+func (st *StressTest) sessionWorker(ctx context.Context, stop <-chan struct{}, endTime time.Time, user *stickyUser, result *StressTestResult, totalDuration *int64) {
+	cfg := st.config.StressTest.Sessions
+	for time.Now().Before(endTime) {
+		s := &sessionState{}
+		if user != nil {
+			s.userID = user.next()
+		} else {
+			s.userID = st.generator.GenerateListMailsRequest().UserID
+		}
+		start := time.Now()
+		operations, thinking, completed := runSession(ctx, stop, endTime, st.sessionScript(cfg), s,
+			func() time.Duration { return thinkTime(cfg) },
+			func(operation string, fn func(context.Context) error) {
+				st.runOperation(ctx, result, totalDuration, operation, fn)
+			})
+		if !completed {
+			st.sessions.interrupted()
+			return
+		}
+		st.sessions.record(time.Since(start), operations, thinking)
+	}
+}
+
+// runSession runs the ready steps in order, pausing think() before every
+// step but the first. It stops early, returning completed=false, when the
+// run is stopped or the next step would start after endTime.
+func runSession(ctx context.Context, stop <-chan struct{}, endTime time.Time, steps []sessionStep, s *sessionState, think func() time.Duration, exec func(operation string, fn func(context.Context) error)) (operations int, thinking time.Duration, completed bool) {
+	for _, step := range steps {
+		if step.ready != nil && !step.ready(s) {
+			continue
+		}
+		if operations > 0 {
+			pause := think()
+			if !time.Now().Add(pause).Before(endTime) || !sleepOrStop(ctx, stop, pause) {
+				return operations, thinking, false
+			}
+			thinking += pause
+		}
+		select {
+		case <-stop:
+			return operations, thinking, false
+		default:
+		}
+		step := step
+		exec(step.operation, func(opCtx context.Context) error { return step.run(opCtx, s) })
+		operations++
+	}
+	return operations, thinking, true
+}
+
+// thinkTime draws a pause of ThinkTime +/- ThinkTimeJitter
+func thinkTime(cfg config.SessionConfig) time.Duration {
+	factor := 1 + cfg.ThinkTimeJitter*(2*rand.Float64()-1)
+	if factor < 0 {
+		factor = 0
+	}
+	return time.Duration(float64(cfg.ThinkTime) * factor)
+}
+
+// sleepOrStop waits d, returning false if the run is stopped first
+func sleepOrStop(ctx context.Context, stop <-chan struct{}, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-stop:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// sessionTracker aggregates finished sessions across workers
+type sessionTracker struct {
+	mu         sync.Mutex
+	durations  []time.Duration
+	operations int64
+	thinking   time.Duration
+	pauses     int64
+	interrupts int64
+}
+
+func (t *sessionTracker) record(duration time.Duration, operations int, thinking time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.durations = append(t.durations, duration)
+	t.operations += int64(operations)
+	t.thinking += thinking
+	if operations > 1 {
+		t.pauses += int64(operations - 1)
+	}
+}
+
+func (t *sessionTracker) interrupted() {
+	t.mu.Lock()
+	t.interrupts++
+	t.mu.Unlock()
+}
+
+func (t *sessionTracker) result() *SessionStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stats := &SessionStats{Completed: int64(len(t.durations)), Interrupted: t.interrupts}
+	if len(t.durations) == 0 {
+		return stats
+	}
+	var total time.Duration
+	for _, d := range t.durations {
+		total += d
+	}
+	stats.AvgDuration = total / time.Duration(len(t.durations))
+	stats.P50Duration = calculatePercentile(t.durations, 50)
+	stats.P95Duration = calculatePercentile(t.durations, 95)
+	stats.AvgOperations = float64(t.operations) / float64(len(t.durations))
+	if t.pauses > 0 {
+		stats.AvgThinkTime = t.thinking / time.Duration(t.pauses)
+	}
+	return stats
+}
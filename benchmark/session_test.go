@@ -0,0 +1,105 @@
+package benchmark
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"mail-stress-test/config"
+	"mail-stress-test/handler"
+	"mail-stress-test/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// scriptedStep is a session step that always runs
+func scriptedStep(operation string) sessionStep {
+	return sessionStep{operation: operation, run: func(ctx context.Context, s *sessionState) error { return nil }}
+}
+
+// TestRunSessionStepsInOrder runs a script with a step that is never ready
+// and checks the others execute in order, each after a think-time pause,
+// and the skipped step costs no pause
+func TestRunSessionStepsInOrder(t *testing.T) {
+	const think = 30 * time.Millisecond
+	steps := []sessionStep{
+		scriptedStep("list"),
+		{operation: "read", ready: func(s *sessionState) bool { return false }},
+		scriptedStep("read"),
+		scriptedStep("search"),
+		scriptedStep("create"),
+	}
+
+	var executed []string
+	var at []time.Time
+	exec := func(operation string, fn func(context.Context) error) {
+		executed = append(executed, operation)
+		at = append(at, time.Now())
+		fn(context.Background())
+	}
+	operations, thinking, completed := runSession(context.Background(), make(chan struct{}), time.Now().Add(time.Minute),
+		steps, &sessionState{}, func() time.Duration { return think }, exec)
+
+	if !completed || operations != 4 || thinking != 3*think {
+		t.Errorf("completed=%v, %d operations, %s thinking; want true, 4, %s", completed, operations, thinking, 3*think)
+	}
+	if got := strings.Join(executed, ","); got != "list,read,search,create" {
+		t.Errorf("executed %s, want list,read,search,create", got)
+	}
+	for i := 1; i < len(at); i++ {
+		if gap := at[i].Sub(at[i-1]); gap < think || gap > think+50*time.Millisecond {
+			t.Errorf("gap before %s = %s, want about the %s think time", executed[i], gap, think)
+		}
+	}
+
+	// A pause that would run past the end of the run interrupts the session
+	executed = nil
+	_, _, completed = runSession(context.Background(), make(chan struct{}), time.Now().Add(50*time.Millisecond),
+		steps, &sessionState{}, func() time.Duration { return think }, exec)
+	if completed || strings.Join(executed, ",") != "list,read" {
+		t.Errorf("completed=%v after %v, want the session cut off after list,read", completed, executed)
+	}
+}
+
+// TestSessionReadTimeout runs sessions against a backend whose point reads
+// hang and checks the reads fail at the read timeout, counted as read
+// timeouts, while the inbox lists succeed
+func TestSessionReadTimeout(t *testing.T) {
+	inbox := []*models.Mail{{ID: primitive.NewObjectID(), From: "user-2"}, {ID: primitive.NewObjectID(), From: "user-3"}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Path == "/api/mails/list" {
+			json.NewEncoder(w).Encode(inbox)
+			return
+		}
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+
+	api := handler.NewAPIHandler(server.URL)
+	api.SetOperationTimeouts(config.OperationTimeouts{Default: 5 * time.Second, Read: 20 * time.Millisecond}.ByOperation())
+	st, cfg := newTestStressTest(t, api)
+	cfg.StressTest.Duration = 300 * time.Millisecond
+	cfg.StressTest.Sessions = config.SessionConfig{Enabled: true, ThinkTime: time.Millisecond, ReadMails: 2}
+
+	result, err := st.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, read := result.OperationStats["list"], result.OperationStats["read"]
+	if list.Count == 0 || list.Errors != 0 {
+		t.Errorf("list: %d requests, %d errors; want successful inbox lists", list.Count, list.Errors)
+	}
+	if read.Count == 0 || read.Errors != read.Count || read.Timeouts != read.Count {
+		t.Errorf("read: %d requests, %d errors, %d timeouts; want every read timed out", read.Count, read.Errors, read.Timeouts)
+	}
+	if read.MaxDuration > 200*time.Millisecond {
+		t.Errorf("slowest read took %s, want it cut off near the 20ms read timeout", read.MaxDuration)
+	}
+}
@@ -0,0 +1,50 @@
+package benchmark
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// SLAResult states whether a run met an SLA such as "99% of requests under 200ms"
+type SLAResult struct {
+	PercentileTarget float64       `json:"percentile_target"` // e.g. 99
+	LatencyBudget    time.Duration `json:"latency_budget"`    // e.g. 200ms
+	ActualLatency    time.Duration `json:"actual_latency"`    // observed latency at PercentileTarget
+	WithinBudget     float64       `json:"within_budget_percent"`
+	Passed           bool          `json:"passed"`
+}
+
+// EvaluateSLA checks the captured samples against the SLA. It returns nil
+// when there are no samples or the SLA is not configured.
+func EvaluateSLA(samples []time.Duration, percentileTarget float64, budget time.Duration) *SLAResult {
+	if len(samples) == 0 || percentileTarget <= 0 || budget <= 0 {
+		return nil
+	}
+
+	sorted := make([]time.Duration, len(samples))
+	copy(sorted, samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	within := sort.Search(len(sorted), func(i int) bool { return sorted[i] > budget })
+
+	result := &SLAResult{
+		PercentileTarget: percentileTarget,
+		LatencyBudget:    budget,
+		ActualLatency:    sorted[nearestRank(len(sorted), percentileTarget)],
+		WithinBudget:     float64(within) / float64(len(sorted)) * 100,
+	}
+	result.Passed = result.ActualLatency <= budget
+
+	return result
+}
+
+// String renders the SLA as a one-line PASS/FAIL statement
+func (r *SLAResult) String() string {
+	status := "FAIL"
+	if r.Passed {
+		status = "PASS"
+	}
+	return fmt.Sprintf("SLA %s: P%g = %s (budget %s, %.2f%% of requests within budget)",
+		status, r.PercentileTarget, r.ActualLatency, r.LatencyBudget, r.WithinBudget)
+}
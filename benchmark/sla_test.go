@@ -0,0 +1,60 @@
+package benchmark
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// latencies returns n samples of d
+func latencies(n int, d time.Duration) []time.Duration {
+	samples := make([]time.Duration, n)
+	for i := range samples {
+		samples[i] = d
+	}
+	return samples
+}
+
+func TestEvaluateSLA(t *testing.T) {
+	budget := 200 * time.Millisecond
+
+	// 99 fast requests and one slow one: P99 is still within budget
+	samples := append(latencies(99, 50*time.Millisecond), time.Second)
+	sla := EvaluateSLA(samples, 99, budget)
+	if sla == nil || !sla.Passed {
+		t.Fatalf("1%% of requests over budget should pass a P99 SLA: %+v", sla)
+	}
+	if sla.ActualLatency != 50*time.Millisecond || sla.WithinBudget != 99 {
+		t.Errorf("ActualLatency = %s, WithinBudget = %.1f%%; want 50ms, 99%%", sla.ActualLatency, sla.WithinBudget)
+	}
+	if !strings.HasPrefix(sla.String(), "SLA PASS") {
+		t.Errorf("String() = %q, want a PASS line", sla.String())
+	}
+
+	// Two slow requests push P99 over budget
+	samples = append(latencies(98, 50*time.Millisecond), time.Second, time.Second)
+	sla = EvaluateSLA(samples, 99, budget)
+	if sla == nil || sla.Passed {
+		t.Fatalf("2%% of requests over budget should fail a P99 SLA: %+v", sla)
+	}
+	if sla.ActualLatency != time.Second {
+		t.Errorf("ActualLatency = %s, want 1s", sla.ActualLatency)
+	}
+	if !strings.HasPrefix(sla.String(), "SLA FAIL") {
+		t.Errorf("String() = %q, want a FAIL line", sla.String())
+	}
+
+	// A sample exactly on the budget is within it
+	if sla := EvaluateSLA(latencies(10, budget), 99, budget); !sla.Passed {
+		t.Errorf("samples equal to the budget should pass: %+v", sla)
+	}
+}
+
+func TestEvaluateSLANotConfigured(t *testing.T) {
+	if sla := EvaluateSLA(nil, 99, time.Second); sla != nil {
+		t.Errorf("no samples: got %+v, want nil", sla)
+	}
+	if sla := EvaluateSLA(latencies(10, time.Millisecond), 0, 0); sla != nil {
+		t.Errorf("no SLA configured: got %+v, want nil", sla)
+	}
+}
@@ -0,0 +1,87 @@
+package benchmark
+
+import (
+	"container/heap"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SlowRequest is one of the slowest operations of the run. RequestIDs are
+// the X-Request-Id values sent to the backend (API handler only), to find
+// the matching backend logs and traces.
+type SlowRequest struct {
+	Operation  string        `json:"operation"`
+	StartedAt  time.Time     `json:"started_at"`
+	Duration   time.Duration `json:"duration"`
+	Error      string        `json:"error,omitempty"`
+	RequestIDs []string      `json:"request_ids,omitempty"`
+}
+
+func (r SlowRequest) String() string {
+	s := fmt.Sprintf("%-11s %10s at %s", r.Operation, r.Duration, r.StartedAt.Format("15:04:05.000"))
+	if len(r.RequestIDs) > 0 {
+		s += " request_id=" + strings.Join(r.RequestIDs, ",")
+	}
+	if r.Error != "" {
+		s += " error=" + r.Error
+	}
+	return s
+}
+
+// slowRequestHeap is a min-heap on duration, so the fastest of the kept
+// outliers is evicted first
+type slowRequestHeap []SlowRequest
+
+func (h slowRequestHeap) Len() int            { return len(h) }
+func (h slowRequestHeap) Less(i, j int) bool  { return h[i].Duration < h[j].Duration }
+func (h slowRequestHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *slowRequestHeap) Push(x interface{}) { *h = append(*h, x.(SlowRequest)) }
+func (h *slowRequestHeap) Pop() interface{} {
+	old := *h
+	last := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return last
+}
+
+// slowRequestTracker keeps the n slowest operations
+type slowRequestTracker struct {
+	n    int
+	mu   sync.Mutex
+	heap slowRequestHeap
+}
+
+func newSlowRequestTracker(n int) *slowRequestTracker {
+	return &slowRequestTracker{n: n}
+}
+
+// qualifies is a cheap pre-check so fast requests skip building a record
+func (t *slowRequestTracker) qualifies(duration time.Duration) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.heap) < t.n || duration > t.heap[0].Duration
+}
+
+func (t *slowRequestTracker) record(r SlowRequest) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.heap) < t.n {
+		heap.Push(&t.heap, r)
+		return
+	}
+	if r.Duration > t.heap[0].Duration {
+		t.heap[0] = r
+		heap.Fix(&t.heap, 0)
+	}
+}
+
+// result returns the kept outliers, slowest first
+func (t *slowRequestTracker) result() []SlowRequest {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	slowest := append([]SlowRequest(nil), t.heap...)
+	sort.Slice(slowest, func(i, j int) bool { return slowest[i].Duration > slowest[j].Duration })
+	return slowest
+}
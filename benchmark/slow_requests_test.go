@@ -0,0 +1,60 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"mail-stress-test/handler"
+)
+
+// TestSlowRequestsCarryRequestIDs runs against a backend that stalls every
+// tenth create and checks the reported outliers are those stalled requests,
+// identified by the X-Request-Id the backend received
+func TestSlowRequestsCarryRequestIDs(t *testing.T) {
+	var mu sync.Mutex
+	var received int
+	stalled := make(map[string]bool)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		received++
+		stall := received%10 == 0
+		if stall {
+			stalled[r.Header.Get(handler.RequestIDHeader)] = true
+		}
+		mu.Unlock()
+		if stall {
+			time.Sleep(40 * time.Millisecond)
+		}
+		fmt.Fprint(w, `{"id":"mail-1"}`)
+	}))
+	defer server.Close()
+
+	st, cfg := newTestStressTest(t, handler.NewAPIHandler(server.URL))
+	cfg.StressTest.SlowRequestCount = 3
+
+	result, err := st.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.SlowRequests) != 3 {
+		t.Fatalf("%d slow requests reported, want 3", len(result.SlowRequests))
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for i, slow := range result.SlowRequests {
+		if slow.Operation != "create" || slow.Duration < 40*time.Millisecond {
+			t.Errorf("outlier %d = %s, want a stalled create", i, slow)
+		}
+		if len(slow.RequestIDs) != 1 || !stalled[slow.RequestIDs[0]] {
+			t.Errorf("outlier %d request IDs = %v, want the ID of a stalled request", i, slow.RequestIDs)
+		}
+		if i > 0 && slow.Duration > result.SlowRequests[i-1].Duration {
+			t.Errorf("outliers not slowest first: %s after %s", slow.Duration, result.SlowRequests[i-1].Duration)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"mail-stress-test/database"
+	"mail-stress-test/generator"
+	"mail-stress-test/handler"
+	"mail-stress-test/models"
+)
+
+// comparedSorts are the result orders replayed by CompareSorts. Relevance is
+// left out: find queries aren't scored, so it falls back to the date order.
+var comparedSorts = []string{models.SortByDate, models.SortBySubject, models.SortByNone}
+
+// SortResult holds the plan and latency of list or search queries run in
+// one result order
+type SortResult struct {
+	Operation  string              `json:"operation"` // list or search
+	SortBy     string              `json:"sort_by"`
+	Plan       *database.QueryPlan `json:"plan,omitempty"` // explain of the first query
+	PlanError  string              `json:"plan_error,omitempty"`
+	Queries    int                 `json:"queries"`
+	Failed     int                 `json:"failed"`
+	AvgLatency time.Duration       `json:"avg_latency"`
+	P95Latency time.Duration       `json:"p95_latency"`
+}
+
+// SortComparison compares list/search queries across result orders and
+// flags the orders no index covers
+type SortComparison struct {
+	Queries       int           `json:"queries"`
+	Results       []*SortResult `json:"results"`
+	InMemorySorts []string      `json:"in_memory_sorts,omitempty"` // operation:sort pairs sorted after fetching
+}
+
+// CompareSorts replays the same n list and n search queries through a DB
+// handler in each result order on a single worker, explaining the first
+// query of each to see which index serves the sort
+func CompareSorts(ctx context.Context, gen *generator.DataGenerator, h *handler.DBHandler, n int) (*SortComparison, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("sort comparison needs at least one query")
+	}
+
+	lists := make([]*models.ListMailsRequest, n)
+	searches := make([]*models.SearchMailsRequest, n)
+	for i := 0; i < n; i++ {
+		lists[i] = gen.GenerateListMailsRequest()
+		searches[i] = gen.GenerateSearchMailsRequest()
+	}
+
+	fmt.Printf("\n=== List/Search by Sort Order (%d queries each) ===\n", n)
+	comparison := &SortComparison{Queries: n}
+	for _, sortBy := range comparedSorts {
+		list := &SortResult{Operation: "list", SortBy: sortBy, Queries: n}
+		first := *lists[0]
+		first.SortBy = sortBy
+		list.setPlan(h.ExplainList(ctx, &first))
+		list.time(ctx, func(i int) error {
+			req := *lists[i]
+			req.SortBy = sortBy
+			_, err := h.ListMails(ctx, &req)
+			return err
+		})
+
+		search := &SortResult{Operation: "search", SortBy: sortBy, Queries: n}
+		firstSearch := *searches[0]
+		firstSearch.SortBy = sortBy
+		search.setPlan(h.ExplainSearch(ctx, &firstSearch))
+		search.time(ctx, func(i int) error {
+			req := *searches[i]
+			req.SortBy = sortBy
+			_, err := h.SearchMails(ctx, &req)
+			return err
+		})
+
+		for _, r := range []*SortResult{list, search} {
+			comparison.Results = append(comparison.Results, r)
+			if r.Plan != nil && r.Plan.InMemorySort {
+				comparison.InMemorySorts = append(comparison.InMemorySorts, r.Operation+":"+r.SortBy)
+			}
+		}
+	}
+	return comparison, nil
+}
+
+// setPlan records an explain outcome; a failed explain doesn't stop timing
+func (r *SortResult) setPlan(plan *database.QueryPlan, err error) {
+	if err != nil {
+		r.PlanError = err.Error()
+		return
+	}
+	r.Plan = plan
+}
+
+// time runs query for each of the r.Queries requests and records latency
+func (r *SortResult) time(ctx context.Context, query func(i int) error) {
+	durations := make([]time.Duration, 0, r.Queries)
+	for i := 0; i < r.Queries; i++ {
+		if ctx.Err() != nil {
+			break
+		}
+		start := time.Now()
+		if err := query(i); err != nil {
+			r.Failed++
+			continue
+		}
+		durations = append(durations, time.Since(start))
+	}
+	r.AvgLatency = averageDuration(durations)
+	r.P95Latency = calculatePercentile(durations, 95)
+}
+
+// String renders the comparison as a table with each query's plan
+func (c *SortComparison) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-8s %-8s %12s %12s %8s  %s\n", "Query", "Sort", "Avg", "P95", "Failed", "Plan")
+	for _, r := range c.Results {
+		plan := r.PlanError
+		if r.Plan != nil {
+			plan = r.Plan.String()
+		}
+		fmt.Fprintf(&b, "%-8s %-8s %12s %12s %8d  %s\n", r.Operation, r.SortBy, r.AvgLatency, r.P95Latency, r.Failed, plan)
+	}
+	if len(c.InMemorySorts) > 0 {
+		fmt.Fprintf(&b, "\n⚠️  No index covers the sort of %s: results are sorted in memory\n", strings.Join(c.InMemorySorts, ", "))
+	} else {
+		b.WriteString("\n✅ Every sort order is served by an index\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,135 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"mail-stress-test/config"
+)
+
+// SteadyStateWindow holds throughput and latency for one measurement window
+type SteadyStateWindow struct {
+	Requests          int64         `json:"requests"`
+	RequestsPerSecond float64       `json:"requests_per_second"`
+	P95Duration       time.Duration `json:"p95_duration"`
+}
+
+// steadyStateDetector collects per-window latency samples and reports when
+// the last MinWindows windows stay within the configured variance band
+type steadyStateDetector struct {
+	config config.SteadyState
+
+	mu        sync.Mutex
+	durations []time.Duration
+	windows   []SteadyStateWindow
+}
+
+func newSteadyStateDetector(cfg config.SteadyState) *steadyStateDetector {
+	if cfg.Window <= 0 {
+		cfg.Window = 5 * time.Second
+	}
+	if cfg.MinWindows < 2 {
+		cfg.MinWindows = 2
+	}
+	return &steadyStateDetector{config: cfg}
+}
+
+// record adds a latency sample to the current window
+func (d *steadyStateDetector) record(duration time.Duration) {
+	d.mu.Lock()
+	d.durations = append(d.durations, duration)
+	d.mu.Unlock()
+}
+
+// closeWindow finalizes the current window and starts a new one
+func (d *steadyStateDetector) closeWindow(elapsed time.Duration) SteadyStateWindow {
+	d.mu.Lock()
+	samples := d.durations
+	d.durations = nil
+	d.mu.Unlock()
+
+	window := SteadyStateWindow{
+		Requests:    int64(len(samples)),
+		P95Duration: calculatePercentile(samples, 95),
+	}
+	if elapsed > 0 {
+		window.RequestsPerSecond = float64(len(samples)) / elapsed.Seconds()
+	}
+
+	d.windows = append(d.windows, window)
+	return window
+}
+
+// isSteady reports whether the most recent windows are within the variance band
+func (d *steadyStateDetector) isSteady() bool {
+	if len(d.windows) < d.config.MinWindows {
+		return false
+	}
+
+	recent := d.windows[len(d.windows)-d.config.MinWindows:]
+	rps := make([]float64, len(recent))
+	p95 := make([]float64, len(recent))
+	for i, w := range recent {
+		if w.Requests == 0 {
+			return false
+		}
+		rps[i] = w.RequestsPerSecond
+		p95[i] = float64(w.P95Duration)
+	}
+
+	return withinVariance(rps, d.config.RPSVariancePercent) &&
+		withinVariance(p95, d.config.P95VariancePercent)
+}
+
+// watch closes a window on every tick and cancels the run once steady
+func (d *steadyStateDetector) watch(ctx context.Context, cancel context.CancelFunc, result *StressTestResult) {
+	ticker := time.NewTicker(d.config.Window)
+	defer ticker.Stop()
+
+	lastTick := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			window := d.closeWindow(now.Sub(lastTick))
+			lastTick = now
+
+			fmt.Printf("  [steady-state] window %d: %.1f req/s, P95=%s\n",
+				len(d.windows), window.RequestsPerSecond, window.P95Duration)
+
+			if d.isSteady() {
+				result.SteadyStateReached = true
+				result.SteadyStateWindows = append([]SteadyStateWindow(nil), d.windows...)
+				fmt.Printf("  [steady-state] stable for %d windows, stopping\n", d.config.MinWindows)
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+// withinVariance checks every value is within percent of the mean
+func withinVariance(values []float64, percent float64) bool {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	if mean == 0 {
+		return false
+	}
+
+	for _, v := range values {
+		deviation := (v - mean) / mean * 100
+		if deviation < 0 {
+			deviation = -deviation
+		}
+		if deviation > percent {
+			return false
+		}
+	}
+	return true
+}
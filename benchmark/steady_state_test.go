@@ -0,0 +1,71 @@
+package benchmark
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"mail-stress-test/config"
+	"mail-stress-test/models"
+)
+
+func TestWithinVariance(t *testing.T) {
+	if !withinVariance([]float64{100, 105, 95}, 10) {
+		t.Error("values within 5% of the mean reported unstable")
+	}
+	if withinVariance([]float64{100, 150, 50}, 10) {
+		t.Error("values 50% off the mean reported stable")
+	}
+	if withinVariance([]float64{0, 0}, 10) {
+		t.Error("an all-zero window reported stable")
+	}
+}
+
+// TestSteadyStateStopsOnceStable runs a handler whose latency swings between
+// fast and slow phases for a while and then settles, and checks the run stops after it settled
+// and well before the duration cap
+func TestSteadyStateStopsOnceStable(t *testing.T) {
+	const settleAfter = 300 * time.Millisecond
+	start := time.Now()
+	h := &fakeHandler{create: func(ctx context.Context, req *models.MailRequest) error {
+		latency := 2 * time.Millisecond
+		if elapsed := time.Since(start); elapsed < settleAfter && elapsed/(40*time.Millisecond)%2 == 1 {
+			latency = 20 * time.Millisecond
+		}
+		time.Sleep(latency)
+		return nil
+	}}
+	st, cfg := newTestStressTest(t, h)
+	cfg.StressTest.SteadyState = config.SteadyState{
+		Enabled:            true,
+		Window:             50 * time.Millisecond,
+		MinWindows:         3,
+		RPSVariancePercent: 30,
+		P95VariancePercent: 30,
+		MaxDuration:        10 * time.Second,
+	}
+
+	result, err := st.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	elapsed := time.Since(start)
+
+	if !result.SteadyStateReached {
+		t.Fatalf("steady state not reached after %s: %+v", elapsed, result.SteadyStateWindows)
+	}
+	if elapsed < settleAfter {
+		t.Errorf("run stopped after %s, before latency settled at %s", elapsed, settleAfter)
+	}
+	if elapsed > 5*time.Second {
+		t.Errorf("run took %s, it should stop soon after settling", elapsed)
+	}
+
+	// The windows that triggered the stop are all in the settled phase
+	windows := result.SteadyStateWindows
+	for _, w := range windows[len(windows)-cfg.StressTest.SteadyState.MinWindows:] {
+		if w.P95Duration > 10*time.Millisecond {
+			t.Errorf("stable window P95 = %s, want the settled ~2ms latency", w.P95Duration)
+		}
+	}
+}
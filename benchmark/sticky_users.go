@@ -0,0 +1,82 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+)
+
+// StickyUserStats describes how workers were pinned to users
+type StickyUserStats struct {
+	Workers        int `json:"workers"`
+	UsersPerWorker int `json:"users_per_worker"`
+	RotateEvery    int `json:"rotate_every"`
+	DistinctUsers  int `json:"distinct_users"`
+}
+
+func (s *StickyUserStats) String() string {
+	if s.UsersPerWorker == 1 {
+		return fmt.Sprintf("%d workers pinned to %d distinct users, one each", s.Workers, s.DistinctUsers)
+	}
+	return fmt.Sprintf("%d workers pinned to %d distinct users, %d each rotating every %d operations",
+		s.Workers, s.DistinctUsers, s.UsersPerWorker, s.RotateEvery)
+}
+
+// stickyUser is the user set one worker acts as for its lifetime; it is
+// only used by that worker's goroutine
+type stickyUser struct {
+	users       []string
+	rotateEvery int
+	operations  int
+}
+
+// next returns the user for the worker's next operation, moving to the
+// next user of the set every rotateEvery operations
+func (u *stickyUser) next() string {
+	user := u.users[(u.operations/u.rotateEvery)%len(u.users)]
+	u.operations++
+	return user
+}
+
+// assignStickyUsers deals each worker perWorker users from a shuffled copy
+// of users, so workers share a user only once every user is taken
+func assignStickyUsers(users []string, workers, perWorker, rotateEvery int) ([]*stickyUser, *StickyUserStats) {
+	if perWorker <= 0 {
+		perWorker = 1
+	}
+	if rotateEvery <= 0 {
+		rotateEvery = 1
+	}
+	shuffled := make([]string, len(users))
+	for i, j := range rand.Perm(len(users)) {
+		shuffled[i] = users[j]
+	}
+
+	assigned := make([]*stickyUser, workers)
+	for w := range assigned {
+		set := make([]string, perWorker)
+		for j := range set {
+			set[j] = shuffled[(w*perWorker+j)%len(shuffled)]
+		}
+		assigned[w] = &stickyUser{users: set, rotateEvery: rotateEvery}
+	}
+
+	distinct := workers * perWorker
+	if distinct > len(users) {
+		distinct = len(users)
+	}
+	return assigned, &StickyUserStats{Workers: workers, UsersPerWorker: perWorker, RotateEvery: rotateEvery, DistinctUsers: distinct}
+}
+
+type actingUserKey struct{}
+
+// withActingUser makes generated operations run as userID
+func withActingUser(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, actingUserKey{}, userID)
+}
+
+// actingUser returns the user set by withActingUser, if any
+func actingUser(ctx context.Context) (string, bool) {
+	userID, ok := ctx.Value(actingUserKey{}).(string)
+	return userID, ok
+}
@@ -0,0 +1,97 @@
+package benchmark
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"mail-stress-test/config"
+	"mail-stress-test/generator"
+	"mail-stress-test/models"
+)
+
+func TestStickyUserRotation(t *testing.T) {
+	user := &stickyUser{users: []string{"a", "b"}, rotateEvery: 3}
+	var got string
+	for i := 0; i < 8; i++ {
+		got += user.next()
+	}
+	if got != "aaabbbaa" {
+		t.Errorf("users = %q, want aaabbbaa", got)
+	}
+}
+
+// TestAssignStickyUsers checks workers get distinct users until the pool
+// runs out and the stats count the distinct users in play
+func TestAssignStickyUsers(t *testing.T) {
+	users := []string{"u1", "u2", "u3", "u4", "u5", "u6"}
+	assigned, stats := assignStickyUsers(users, 3, 2, 0)
+	seen := map[string]bool{}
+	for w, user := range assigned {
+		if len(user.users) != 2 || user.rotateEvery != 1 {
+			t.Errorf("worker %d = %+v, want 2 users rotating every operation", w, user)
+		}
+		for _, u := range user.users {
+			if seen[u] {
+				t.Errorf("user %s assigned twice with users to spare", u)
+			}
+			seen[u] = true
+		}
+	}
+	if stats.DistinctUsers != 6 || stats.Workers != 3 || stats.UsersPerWorker != 2 {
+		t.Errorf("stats = %+v, want 3 workers with 2 of 6 distinct users each", stats)
+	}
+
+	if _, stats := assignStickyUsers(users, 10, 1, 1); stats.DistinctUsers != len(users) {
+		t.Errorf("10 workers over 6 users report %d distinct users, want 6", stats.DistinctUsers)
+	}
+}
+
+// TestStickyUsersRun records the user every operation targets and checks a
+// lone sticky worker stays on one user across operation types, and four
+// workers over eight users only ever touch four of them
+func TestStickyUsersRun(t *testing.T) {
+	for _, workers := range []int{1, 4} {
+		var mu sync.Mutex
+		targeted := map[string]int{}
+		target := func(userID string) {
+			mu.Lock()
+			targeted[userID]++
+			mu.Unlock()
+		}
+		h := &fakeHandler{
+			create: func(ctx context.Context, req *models.MailRequest) error { target(req.From); return nil },
+			list: func(ctx context.Context, req *models.ListMailsRequest) ([]*models.Mail, error) {
+				target(req.UserID)
+				return nil, nil
+			},
+			search: func(ctx context.Context, req *models.SearchMailsRequest) ([]*models.Mail, error) {
+				target(req.UserID)
+				return nil, nil
+			},
+			count: func(ctx context.Context, userID string) (int64, error) { target(userID); return 0, nil },
+		}
+		gen, err := generator.NewDataGenerator([]string{"u1", "u2", "u3", "u4", "u5", "u6", "u7", "u8"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg := config.DefaultConfig()
+		cfg.StressTest.ConcurrentWorkers = workers
+		cfg.StressTest.RequestRate = 0
+		cfg.StressTest.Duration = 100 * time.Millisecond
+		cfg.StressTest.Operations = config.Operations{CreateMailWeight: 25, ListMailWeight: 25, SearchWeight: 25, CountWeight: 25}
+		cfg.StressTest.StickyUsers = config.StickyUsersConfig{Enabled: true}
+
+		result, err := NewStressTest(cfg, gen, h).Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.StickyUsers == nil || result.StickyUsers.DistinctUsers != workers {
+			t.Errorf("%d workers: sticky stats = %+v, want %d distinct users", workers, result.StickyUsers, workers)
+		}
+		if len(targeted) != workers {
+			t.Errorf("%d workers targeted users %v, want one stable user per worker", workers, targeted)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package benchmark
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"mail-stress-test/database"
+	"mail-stress-test/generator"
+	"mail-stress-test/handler"
+	"mail-stress-test/models"
+)
+
+// StreamRun holds latency and client memory of unlimited list/search
+// queries consumed one way
+type StreamRun struct {
+	Mode          string        `json:"mode"`
+	Queries       int           `json:"queries"`
+	Failed        int           `json:"failed"`
+	AvgLatency    time.Duration `json:"avg_latency"`
+	P95Latency    time.Duration `json:"p95_latency"`
+	AvgResults    float64       `json:"avg_results"`
+	PeakHeapBytes uint64        `json:"peak_heap_bytes"` // live heap above the pre-run baseline
+	AllocPerQuery uint64        `json:"alloc_per_query"` // bytes allocated per query
+}
+
+// StreamComparison compares materializing whole list/search results into a
+// slice with streaming them mail by mail, both exported as NDJSON
+type StreamComparison struct {
+	UserID       string     `json:"user_id"`
+	InboxSize    int64      `json:"inbox_size"`
+	Queries      int        `json:"queries"`
+	Materialized *StreamRun `json:"materialized"`
+	Streamed     *StreamRun `json:"streamed"`
+}
+
+// CompareStreaming replays n unlimited queries on the largest inbox,
+// alternating a full list with a search, through h's slice-returning calls
+// and then through its MailStreamer calls
+func CompareStreaming(ctx context.Context, db *database.MongoDB, gen *generator.DataGenerator, h handler.MailHandler, n int) (*StreamComparison, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("streaming comparison needs at least one query")
+	}
+	streamer, ok := h.(handler.MailStreamer)
+	if !ok {
+		return nil, fmt.Errorf("handler %T does not support streaming", h)
+	}
+
+	sizes, err := db.InboxSizes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	comparison := &StreamComparison{Queries: n}
+	for userID, size := range sizes {
+		if size > comparison.InboxSize {
+			comparison.UserID, comparison.InboxSize = userID, size
+		}
+	}
+	if comparison.UserID == "" {
+		return nil, fmt.Errorf("no mails to stream; seed the database first")
+	}
+
+	queries := make([]viewQuery, n)
+	for i := range queries {
+		if i%2 == 0 {
+			queries[i] = viewQuery{list: &models.ListMailsRequest{UserID: comparison.UserID}}
+		} else {
+			search := gen.GenerateSearchMailsRequest()
+			search.UserID, search.Limit, search.From, search.To = comparison.UserID, 0, "", ""
+			queries[i] = viewQuery{search: search}
+		}
+	}
+
+	fmt.Printf("\n=== Materialized vs Streamed Results (%d mails, %d queries) ===\n", comparison.InboxSize, n)
+	comparison.Materialized = runStream(ctx, "materialized", queries, func(q viewQuery, fn func(*models.Mail) error) error {
+		var mails []*models.Mail
+		var err error
+		if q.list != nil {
+			mails, err = h.ListMails(ctx, q.list)
+		} else {
+			mails, err = h.SearchMails(ctx, q.search)
+		}
+		if err != nil {
+			return err
+		}
+		for _, mail := range mails {
+			if err := fn(mail); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	comparison.Streamed = runStream(ctx, "streamed", queries, func(q viewQuery, fn func(*models.Mail) error) error {
+		if q.list != nil {
+			return streamer.ListMailsStream(ctx, q.list, fn)
+		}
+		return streamer.SearchMailsStream(ctx, q.search, fn)
+	})
+	return comparison, nil
+}
+
+// runStream runs every query through fetch, writing each mail as an NDJSON
+// line the way an export would, and samples the heap while they run
+func runStream(ctx context.Context, mode string, queries []viewQuery, fetch func(viewQuery, func(*models.Mail) error) error) *StreamRun {
+	result := &StreamRun{Mode: mode, Queries: len(queries)}
+	encoder := json.NewEncoder(io.Discard)
+
+	sampler := startHeapSampler()
+	var durations []time.Duration
+	var results int
+	for _, q := range queries {
+		if ctx.Err() != nil {
+			break
+		}
+		start := time.Now()
+		err := fetch(q, func(mail *models.Mail) error {
+			results++
+			return encoder.Encode(mail)
+		})
+		if err != nil {
+			result.Failed++
+			continue
+		}
+		durations = append(durations, time.Since(start))
+	}
+	result.PeakHeapBytes, result.AllocPerQuery = sampler.stop()
+	if len(queries) > 0 {
+		result.AllocPerQuery /= uint64(len(queries))
+	}
+
+	result.AvgLatency = averageDuration(durations)
+	result.P95Latency = calculatePercentile(durations, 95)
+	if len(durations) > 0 {
+		result.AvgResults = float64(results) / float64(len(durations))
+	}
+	return result
+}
+
+// heapSampler tracks the highest live heap seen while a run is in progress
+type heapSampler struct {
+	baseline   uint64
+	totalAlloc uint64
+	peak       uint64
+	done       chan struct{}
+	wg         sync.WaitGroup
+}
+
+// heapSampleInterval trades sampling precision against stop-the-world pauses
+const heapSampleInterval = 2 * time.Millisecond
+
+// startHeapSampler collects garbage, records the baseline heap and samples
+// it in the background until stop
+func startHeapSampler() *heapSampler {
+	runtime.GC()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	s := &heapSampler{baseline: m.HeapAlloc, totalAlloc: m.TotalAlloc, peak: m.HeapAlloc, done: make(chan struct{})}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(heapSampleInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+				s.sample()
+			}
+		}
+	}()
+	return s
+}
+
+// sample reads the heap and raises the peak
+func (s *heapSampler) sample() *runtime.MemStats {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	if m.HeapAlloc > s.peak {
+		s.peak = m.HeapAlloc
+	}
+	return &m
+}
+
+// stop ends sampling and returns the peak heap above the baseline and the
+// bytes allocated since start
+func (s *heapSampler) stop() (peak, allocated uint64) {
+	close(s.done)
+	s.wg.Wait()
+	m := s.sample()
+	if s.peak > s.baseline {
+		peak = s.peak - s.baseline
+	}
+	return peak, m.TotalAlloc - s.totalAlloc
+}
+
+// String renders both runs side by side
+func (c *StreamComparison) String() string {
+	var b strings.Builder
+	const mib = 1 << 20
+	fmt.Fprintf(&b, "%-13s %12s %12s %10s %14s %16s %8s\n", "Mode", "Avg", "P95", "Results", "Peak Heap MiB", "Alloc/Query MiB", "Failed")
+	for _, r := range []*StreamRun{c.Materialized, c.Streamed} {
+		fmt.Fprintf(&b, "%-13s %12s %12s %10.0f %14.2f %16.2f %8d\n", r.Mode, r.AvgLatency, r.P95Latency, r.AvgResults,
+			float64(r.PeakHeapBytes)/mib, float64(r.AllocPerQuery)/mib, r.Failed)
+	}
+	if c.Streamed.PeakHeapBytes > 0 {
+		fmt.Fprintf(&b, "\nStreaming peak heap is %.1fx smaller\n", float64(c.Materialized.PeakHeapBytes)/float64(c.Streamed.PeakHeapBytes))
+	}
+	return b.String()
+}
@@ -2,8 +2,10 @@ package benchmark
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/rand"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -11,8 +13,7 @@ import (
 	"mail-stress-test/config"
 	"mail-stress-test/generator"
 	"mail-stress-test/handler"
-
-	"go.mongodb.org/mongo-driver/bson/primitive"
+	"mail-stress-test/models"
 )
 
 type StressTestResult struct {
@@ -23,25 +24,188 @@ type StressTestResult struct {
 	AvgResponseTime   time.Duration              `json:"avg_response_time"`
 	MinResponseTime   time.Duration              `json:"min_response_time"`
 	MaxResponseTime   time.Duration              `json:"max_response_time"`
+	P50ResponseTime   time.Duration              `json:"p50_response_time"`
+	P95ResponseTime   time.Duration              `json:"p95_response_time"`
+	P99ResponseTime   time.Duration              `json:"p99_response_time"`
+	TailPercentiles   []PercentileValue          `json:"tail_percentiles,omitempty"`   // report.tail_percentiles, e.g. P99.9
+	InboxSizeLatency  []InboxSizeLatency         `json:"inbox_size_latency,omitempty"` // list/search latency by target inbox size
+	SlowRequests      []SlowRequest              `json:"slow_requests,omitempty"`      // slowest operations, slowest first
+	LatencyHistogram  []LatencyBucket            `json:"latency_histogram,omitempty"`
+	SLA               *SLAResult                 `json:"sla,omitempty"`
 	RequestsPerSecond float64                    `json:"requests_per_second"`
 	ErrorRate         float64                    `json:"error_rate"`
 	OperationStats    map[string]*OperationStats `json:"operation_stats"`
+	TimeSeries        []TimeSeriesPoint          `json:"time_series,omitempty"`
+	FailureModes      []FailureModeShift         `json:"failure_modes,omitempty"` // dominant failure mode changes over time
+
+	Retries          int64 `json:"retries,omitempty"`
+	RetriesExhausted int64 `json:"retries_exhausted,omitempty"`
+
+	// RetriesPerSuccess is the retry cost of each successful request;
+	// RetryBudgetDenied counts retries refused by the retry budget
+	RetriesPerSuccess float64 `json:"retries_per_success,omitempty"`
+	RetryBudgetDenied int64   `json:"retry_budget_denied,omitempty"`
+
+	// Responses that were error-free but broke a success predicate; they are
+	// not counted in FailedRequests
+	SoftFailures int64 `json:"soft_failures,omitempty"`
+
+	// Requests short-circuited by an open circuit breaker; they never reached
+	// the backend and are not counted in TotalRequests
+	CircuitOpenRejections int64 `json:"circuit_open_rejections,omitempty"`
+	CircuitOpens          int64 `json:"circuit_opens,omitempty"`
+
+	// Peak, waits and rejections of the handler's in-flight cap
+	InFlight *handler.InFlightStats `json:"in_flight,omitempty"`
+
+	// Operations shed at a full in-flight cap; they never reached the
+	// backend and are not counted in TotalRequests
+	ShedLoad int64 `json:"shed_load,omitempty"`
+
+	// Failed create payloads written to (or dropped from) the dead-letter file
+	DeadLetters        int64 `json:"dead_letters,omitempty"`
+	DeadLettersDropped int64 `json:"dead_letters_dropped,omitempty"`
+
+	// WarmUpDuration is the time spent opening connections before measurement
+	WarmUpDuration time.Duration `json:"warm_up_duration,omitempty"`
+
+	// AbortError is the first error that stopped a fail-fast run
+	AbortError string `json:"abort_error,omitempty"`
+
+	// InsufficientData says why the run produced no meaningful latency or
+	// throughput figures (too short, cancelled, every request failed)
+	InsufficientData string `json:"insufficient_data,omitempty"`
+
+	// Burst traffic windows and the latency within burst vs baseline windows
+	Burst *BurstResult `json:"burst,omitempty"`
+
+	// Worker pool utilization and whether the target rate was delivered
+	WorkerUtilization *WorkerUtilization `json:"worker_utilization,omitempty"`
+
+	SteadyStateReached bool                `json:"steady_state_reached,omitempty"`
+	SteadyStateWindows []SteadyStateWindow `json:"steady_state_windows,omitempty"`
+
+	// Virtual user session durations and sizes
+	Sessions *SessionStats `json:"sessions,omitempty"`
+
+	// Worker-to-user pinning in sticky user mode
+	StickyUsers *StickyUserStats `json:"sticky_users,omitempty"`
+
+	// The load generator's own goroutine and open FD counts
+	SelfMonitor *SelfMonitorStats `json:"self_monitor,omitempty"`
+
+	// Scenario iterations and per-step outcomes in scenario mode
+	Scenario *ScenarioStats `json:"scenario,omitempty"`
+
+	// API request latency split into DNS, connect, TLS, server and body phases
+	LatencyPhases *handler.LatencyPhaseStats `json:"latency_phases,omitempty"`
+
+	// Negotiated HTTP protocol and connections used by the API handler
+	Protocol *handler.ProtocolStats `json:"protocol,omitempty"`
 }
 
+// OperationStats aggregates one operation's requests. Workers update it
+// concurrently through record; AvgDuration is only valid after finalize.
 type OperationStats struct {
 	Count       int64         `json:"count"`
 	AvgDuration time.Duration `json:"avg_duration"`
 	MinDuration time.Duration `json:"min_duration"`
 	MaxDuration time.Duration `json:"max_duration"`
 	Errors      int64         `json:"errors"`
+	Timeouts    int64         `json:"timeouts,omitempty"`    // errors caused by the per-operation deadline
+	SoftErrors  int64         `json:"soft_errors,omitempty"` // success predicate failures
+
+	// totalDurationNanos is the running sum AvgDuration is computed from
+	totalDurationNanos int64
+}
+
+// record adds one request's duration and outcome
+func (s *OperationStats) record(duration time.Duration, isError bool) {
+	atomic.AddInt64(&s.Count, 1)
+	atomic.AddInt64(&s.totalDurationNanos, int64(duration))
+	if isError {
+		atomic.AddInt64(&s.Errors, 1)
+	}
+	atomicMinDuration(&s.MinDuration, duration)
+	atomicMaxDuration(&s.MaxDuration, duration)
+}
+
+// finalize computes AvgDuration once all workers have stopped
+func (s *OperationStats) finalize() {
+	if count := atomic.LoadInt64(&s.Count); count > 0 {
+		s.AvgDuration = time.Duration(atomic.LoadInt64(&s.totalDurationNanos) / count)
+	} else {
+		s.MinDuration = 0
+	}
+}
+
+// atomicMinDuration lowers *target to d if d is smaller
+func atomicMinDuration(target *time.Duration, d time.Duration) {
+	p := (*int64)(target)
+	for {
+		current := atomic.LoadInt64(p)
+		if int64(d) >= current || atomic.CompareAndSwapInt64(p, current, int64(d)) {
+			return
+		}
+	}
+}
+
+// atomicMaxDuration raises *target to d if d is larger
+func atomicMaxDuration(target *time.Duration, d time.Duration) {
+	p := (*int64)(target)
+	for {
+		current := atomic.LoadInt64(p)
+		if int64(d) <= current || atomic.CompareAndSwapInt64(p, current, int64(d)) {
+			return
+		}
+	}
 }
 
 type StressTest struct {
-	config    *config.Config
-	generator *generator.DataGenerator
-	handler   handler.MailHandler
+	config      *config.Config
+	generator   *generator.DataGenerator
+	handler     handler.MailHandler
+	steadyState *steadyStateDetector
+	liveMetrics *LiveMetrics
+	deadLetter  *deadLetterWriter
+
+	// Fail-fast: the first real error cancels the run via abort
+	failFast  bool
+	abortOnce sync.Once
+	abort     context.CancelFunc
+
+	// Latency samples: every sample in exact mode, or a streaming digest
+	samplesMu sync.Mutex
+	samples   []time.Duration
+	streaming *streamingLatency
+	timeline  *timeSeriesRecorder
+	burst     *burstTracker
+	watchdog  *workerWatchdog
+
+	// inboxSizes buckets list/search latency by inbox size when set
+	inboxSizes *inboxSizeTracker
+
+	// slowRequests keeps the slowest operations with their request IDs
+	slowRequests *slowRequestTracker
+
+	// workload replays a golden workload instead of generating requests
+	workload *workloadCursor
+
+	// sessions aggregates virtual user sessions when sessions are enabled
+	sessions *sessionTracker
+
+	// scenario runs the configured step sequence when scenarios are enabled
+	scenario *scenarioTracker
+
+	// operations is the weighted mix selectOperation draws from: the
+	// configured weights of the operations the handler supports
+	operations []weightedOperation
 }
 
+// PercentileModeTDigest estimates percentiles with a t-digest instead of
+// keeping every sample
+const PercentileModeTDigest = "tdigest"
+
 // NewStressTest creates a new stress test with the given dependencies
 func NewStressTest(cfg *config.Config, gen *generator.DataGenerator, handler handler.MailHandler) *StressTest {
 	return &StressTest{
@@ -51,100 +215,459 @@ func NewStressTest(cfg *config.Config, gen *generator.DataGenerator, handler han
 	}
 }
 
+// SetFailFast stops the run on the first non-cancellation error
+func (st *StressTest) SetFailFast(enabled bool) {
+	st.failFast = enabled
+}
+
+// SetInboxSizes enables list/search latency reporting by inbox size, using
+// per-user mail counts taken before the run
+func (st *StressTest) SetInboxSizes(sizes map[string]int64) {
+	st.inboxSizes = newInboxSizeTracker(sizes)
+}
+
+// SetWorkload replays ops in order instead of generating requests; workers
+// stop once every operation has been sent
+func (st *StressTest) SetWorkload(ops []WorkloadOperation) {
+	st.workload = &workloadCursor{ops: ops}
+}
+
+// SetLiveMetrics publishes per-operation counters to m while the test runs
+func (st *StressTest) SetLiveMetrics(m *LiveMetrics) {
+	st.liveMetrics = m
+}
+
 func (st *StressTest) Run(ctx context.Context) (*StressTestResult, error) {
 	result := &StressTestResult{
 		MinResponseTime: time.Hour,
 		OperationStats: map[string]*OperationStats{
-			"create": {MinDuration: time.Hour},
-			"list":   {MinDuration: time.Hour},
-			"search": {MinDuration: time.Hour},
+			"create":      {MinDuration: time.Hour},
+			"list":        {MinDuration: time.Hour},
+			"search":      {MinDuration: time.Hour},
+			"draft":       {MinDuration: time.Hour},
+			"forward":     {MinDuration: time.Hour},
+			"reply_all":   {MinDuration: time.Hour},
+			"soft_delete": {MinDuration: time.Hour},
+			"count":       {MinDuration: time.Hour},
+			"read":        {MinDuration: time.Hour},
 		},
 	}
 
 	var totalDuration int64
 	var wg sync.WaitGroup
+	st.samples = nil
+	st.slowRequests = nil
+	if n := st.config.StressTest.SlowRequestCount; n > 0 {
+		st.slowRequests = newSlowRequestTracker(n)
+	}
+	st.streaming = nil
+	if st.config.StressTest.PercentileMode == PercentileModeTDigest {
+		st.streaming = newStreamingLatency(st.config.StressTest.TDigestCompression)
+	}
+
+	// Scenario: each iteration runs the configured steps in order
+	st.scenario = nil
+	if cfg := st.config.StressTest.Scenario; cfg.Enabled {
+		if st.config.StressTest.Sessions.Enabled || st.workload != nil {
+			return nil, fmt.Errorf("scenario cannot be combined with sessions or workload replay")
+		}
+		steps, err := compileScenario(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid scenario: %w", err)
+		}
+		st.scenario = newScenarioTracker(steps)
+	}
+
+	// Operations the handler can't run (e.g. drafts over the API) are left
+	// out of the mix instead of failing every time they are picked
+	operations, skipped := st.runnableOperations()
+	st.operations = operations
+	if len(skipped) > 0 {
+		fmt.Printf("⏭️  Skipping operations the handler does not support: %s\n", strings.Join(skipped, ", "))
+	}
+	if len(operations) == 0 && st.workload == nil && st.scenario == nil {
+		return nil, fmt.Errorf("no operation with a positive weight is supported by the handler")
+	}
+
+	// Pre-open connections so setup cost is not measured
+	if requests := st.config.StressTest.WarmUpRequests; requests > 0 {
+		if warmer, ok := st.handler.(handler.Warmer); ok {
+			warmUpStart := time.Now()
+			if err := warmer.WarmUp(ctx, st.config.StressTest.ConcurrentWorkers, requests); err != nil {
+				fmt.Printf("⚠️  Warm-up failed: %v\n", err)
+			}
+			result.WarmUpDuration = time.Since(warmUpStart)
+		}
+	}
 
 	startTime := time.Now()
 	endTime := startTime.Add(st.config.StressTest.Duration)
+	st.timeline = newTimeSeriesRecorder(startTime, st.config.StressTest.TimeSeriesInterval)
+
+	// stopCtx is cancelled when workers should stop picking up new work;
+	// in-flight operations keep using ctx so they are not failed by the stop
+	stopCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	st.abort = stop
+	st.abortOnce = sync.Once{}
+
+	// Steady-state mode runs until latency stabilizes or the cap is hit
+	steadyCfg := st.config.StressTest.SteadyState
+	watchDone := make(chan struct{})
+	if steadyCfg.Enabled {
+		st.steadyState = newSteadyStateDetector(steadyCfg)
+		if steadyCfg.MaxDuration > 0 {
+			endTime = startTime.Add(steadyCfg.MaxDuration)
+		}
+		go func() {
+			defer close(watchDone)
+			st.steadyState.watch(stopCtx, stop, result)
+		}()
+	} else {
+		close(watchDone)
+	}
 
-	// Rate limiter
-	rateLimiter := time.NewTicker(time.Second / time.Duration(st.config.StressTest.RequestRate))
-	defer rateLimiter.Stop()
+	// Dead-letter log for failed creates
+	if path := st.config.StressTest.DeadLetterPath; path != "" {
+		writer, err := newDeadLetterWriter(path, st.config.StressTest.DeadLetterMaxBytes)
+		if err != nil {
+			return nil, err
+		}
+		st.deadLetter = writer
+		defer func() {
+			result.DeadLetters, result.DeadLettersDropped = writer.close()
+			st.deadLetter = nil
+		}()
+	}
+
+	// Rate limiter shared by all workers, following the burst schedule if set
+	limiter := newRateLimiter(st.config.StressTest.RequestRate)
+	st.burst = nil
+	if burstCfg := st.config.StressTest.Burst; burstCfg.Enabled {
+		schedule, err := newBurstSchedule(burstCfg, startTime)
+		if err != nil {
+			return nil, fmt.Errorf("invalid burst pattern: %w", err)
+		}
+		limiter = newBurstRateLimiter(schedule)
+		st.burst = newBurstTracker(schedule, st.config.StressTest.TDigestCompression)
+	}
 
-	// Worker pool
+	// Watchdog sampling how many workers are busy vs waiting
+	st.watchdog = newWorkerWatchdog(st.config.StressTest.ConcurrentWorkers)
+	watchdogDone := make(chan struct{})
+	go func() {
+		defer close(watchdogDone)
+		st.watchdog.run(stopCtx.Done(), st.config.StressTest.WorkerSampleInterval)
+	}()
+
+	// Self-monitor sampling the tool's own goroutines and open FDs
+	monitor := newSelfMonitor()
+	monitorDone := make(chan struct{})
+	go func() {
+		defer close(monitorDone)
+		monitor.run(stopCtx.Done(), st.config.StressTest.SelfMonitorInterval)
+	}()
+
+	// Worker pool: rate-limited operations, or one virtual user per worker
+	st.sessions = nil
+	if st.config.StressTest.Sessions.Enabled {
+		st.sessions = &sessionTracker{}
+	}
+	// Sticky users: each worker acts as its own user set for the whole run
+	sticky := make([]*stickyUser, st.config.StressTest.ConcurrentWorkers)
+	if cfg := st.config.StressTest.StickyUsers; cfg.Enabled {
+		sticky, result.StickyUsers = assignStickyUsers(st.generator.GetUserIDs(),
+			st.config.StressTest.ConcurrentWorkers, cfg.UsersPerWorker, cfg.RotateEvery)
+	}
 	for i := 0; i < st.config.StressTest.ConcurrentWorkers; i++ {
 		wg.Add(1)
-		go func() {
+		go func(user *stickyUser) {
 			defer wg.Done()
-			st.worker(ctx, endTime, rateLimiter, result, &totalDuration)
-		}()
+			if st.sessions != nil {
+				st.sessionWorker(ctx, stopCtx.Done(), endTime, user, result, &totalDuration)
+				return
+			}
+			if st.scenario != nil {
+				st.scenarioWorker(ctx, stopCtx.Done(), endTime, limiter, user, result, &totalDuration)
+				return
+			}
+			st.worker(ctx, stopCtx.Done(), endTime, limiter, user, result, &totalDuration)
+		}(sticky[i])
 	}
 
 	wg.Wait()
+	stop()
+	<-watchDone
+	<-watchdogDone
+	<-monitorDone
 
 	// Calculate final stats
 	result.TotalDuration = time.Since(startTime)
+	result.TimeSeries = st.timeline.points()
+	result.FailureModes = FailureModeShifts(result.TimeSeries)
+	if st.burst != nil {
+		result.Burst = st.burst.result(startTime.Add(result.TotalDuration))
+	}
+	if reporter, ok := st.handler.(handler.RetryReporter); ok {
+		result.Retries, result.RetriesExhausted = reporter.RetryStats()
+	}
+	if reporter, ok := st.handler.(handler.RetryBudgetReporter); ok {
+		result.RetryBudgetDenied = reporter.RetryBudgetDenied()
+	}
+	if reporter, ok := st.handler.(handler.CircuitReporter); ok {
+		result.CircuitOpenRejections, result.CircuitOpens = reporter.CircuitStats()
+	}
+	if reporter, ok := st.handler.(handler.InFlightReporter); ok {
+		if stats, enabled := reporter.InFlightStats(); enabled {
+			result.InFlight = &stats
+		}
+	}
+	if reporter, ok := st.handler.(handler.ProtocolReporter); ok {
+		if stats, enabled := reporter.ProtocolStats(); enabled {
+			result.Protocol = &stats
+		}
+	}
+	if reporter, ok := st.handler.(handler.PhaseReporter); ok {
+		if stats, enabled := reporter.PhaseStats(); enabled {
+			result.LatencyPhases = &stats
+		}
+	}
+	if reporter, ok := st.handler.(handler.TimeoutReporter); ok {
+		for op, count := range reporter.TimeoutStats() {
+			if stats, exists := result.OperationStats[op]; exists {
+				stats.Timeouts = count
+			}
+		}
+	}
 	if result.TotalRequests > 0 {
 		result.AvgResponseTime = time.Duration(totalDuration / result.TotalRequests)
 		result.RequestsPerSecond = float64(result.TotalRequests) / result.TotalDuration.Seconds()
 		result.ErrorRate = float64(result.FailedRequests) / float64(result.TotalRequests) * 100
 	}
+	if result.SuccessRequests > 0 {
+		result.RetriesPerSuccess = float64(result.Retries) / float64(result.SuccessRequests)
+	}
+	result.WorkerUtilization = st.watchdog.result(st.targetRPS(), result.RequestsPerSecond)
+	result.SelfMonitor = monitor.result()
+
+	// Latency distribution from captured samples
+	if st.streaming != nil && st.streaming.digest.Count() > 0 {
+		result.P50ResponseTime = st.streaming.percentile(50)
+		result.P95ResponseTime = st.streaming.percentile(95)
+		result.P99ResponseTime = st.streaming.percentile(99)
+		for _, p := range tailPercentiles(st.config.Report.TailPercentiles) {
+			result.TailPercentiles = append(result.TailPercentiles, PercentileValue{Percentile: p, Latency: st.streaming.percentile(p)})
+		}
+		result.LatencyHistogram = st.streaming.histogram()
+		result.SLA = st.streaming.sla(st.config.SLA.PercentileTarget, st.config.SLA.LatencyBudget)
+	} else if len(st.samples) > 0 {
+		result.P50ResponseTime = calculatePercentile(st.samples, 50)
+		result.P95ResponseTime = calculatePercentile(st.samples, 95)
+		result.P99ResponseTime = calculatePercentile(st.samples, 99)
+		result.TailPercentiles = calculateTailPercentiles(st.samples, tailPercentiles(st.config.Report.TailPercentiles))
+		result.LatencyHistogram = BuildLatencyHistogram(st.samples)
+		result.SLA = EvaluateSLA(st.samples, st.config.SLA.PercentileTarget, st.config.SLA.LatencyBudget)
+	}
+
+	if st.inboxSizes != nil {
+		result.InboxSizeLatency = st.inboxSizes.result()
+	}
+	if st.slowRequests != nil {
+		result.SlowRequests = st.slowRequests.result()
+	}
+	if st.sessions != nil {
+		result.Sessions = st.sessions.result()
+	}
+	if st.scenario != nil {
+		result.Scenario = st.scenario.result()
+	}
 
 	// Calculate operation stats
 	for _, stats := range result.OperationStats {
-		if stats.Count > 0 {
-			stats.AvgDuration = time.Duration(int64(stats.AvgDuration) / stats.Count)
-		}
+		stats.finalize()
 	}
+	if result.TotalRequests == 0 {
+		result.MinResponseTime = 0
+	}
+	result.InsufficientData = stressInsufficientData(result)
 
 	return result, nil
 }
 
-func (st *StressTest) worker(ctx context.Context, endTime time.Time, rateLimiter *time.Ticker, result *StressTestResult, totalDuration *int64) {
+func (st *StressTest) worker(ctx context.Context, stop <-chan struct{}, endTime time.Time, limiter *rateLimiter, user *stickyUser, result *StressTestResult, totalDuration *int64) {
 	for time.Now().Before(endTime) {
-		select {
-		case <-ctx.Done():
+		if !limiter.Wait(stop) || !time.Now().Before(endTime) {
 			return
-		case <-rateLimiter.C:
-			operation := st.selectOperation()
-			start := time.Now()
-
-			err := st.executeOperation(ctx, operation)
-			duration := time.Since(start)
-
-			atomic.AddInt64(totalDuration, int64(duration))
-			atomic.AddInt64(&result.TotalRequests, 1)
-
-			if err != nil {
-				atomic.AddInt64(&result.FailedRequests, 1)
-				st.updateOperationStats(result, operation, duration, true)
-			} else {
-				atomic.AddInt64(&result.SuccessRequests, 1)
-				st.updateOperationStats(result, operation, duration, false)
+		}
+
+		var replay *WorkloadOperation
+		var operation string
+		if st.workload != nil {
+			if replay = st.workload.take(); replay == nil {
+				return
 			}
+			operation = replay.Op
+		} else {
+			operation = st.selectOperation()
+		}
 
-			// Update min/max
-			if duration < result.MinResponseTime {
-				result.MinResponseTime = duration
+		var userID string
+		if user != nil && replay == nil {
+			userID = user.next()
+		}
+		st.runOperation(ctx, result, totalDuration, operation, func(opCtx context.Context) error {
+			if replay != nil {
+				return st.replayOperation(opCtx, replay)
 			}
-			if duration > result.MaxResponseTime {
-				result.MaxResponseTime = duration
+			if userID != "" {
+				opCtx = withActingUser(opCtx, userID)
 			}
+			return st.executeOperation(opCtx, operation)
+		})
+	}
+}
+
+// runOperation times fn as one request of operation and records its outcome
+// in result and every tracker. The error is returned as recorded: nil for a
+// soft failure.
+func (st *StressTest) runOperation(ctx context.Context, result *StressTestResult, totalDuration *int64, operation string, fn func(context.Context) error) error {
+	start := time.Now()
+
+	// Collect the X-Request-Id values the API handler sends, so slow
+	// outliers can be matched with backend traces
+	opCtx, requestIDs := ctx, (*handler.RequestIDs)(nil)
+	if st.slowRequests != nil {
+		opCtx, requestIDs = handler.WithRequestIDs(ctx)
+	}
+
+	st.watchdog.begin()
+	err := fn(opCtx)
+	st.watchdog.end()
+	duration := time.Since(start)
+
+	if st.slowRequests != nil && st.slowRequests.qualifies(duration) {
+		slow := SlowRequest{Operation: operation, StartedAt: start, Duration: duration, RequestIDs: requestIDs.List()}
+		if err != nil {
+			slow.Error = err.Error()
+		}
+		st.slowRequests.record(slow)
+	}
+
+	// Short-circuited requests never reached the backend
+	if errors.Is(err, handler.ErrCircuitOpen) {
+		return err
+	}
+	if errors.Is(err, handler.ErrInFlightLimit) {
+		atomic.AddInt64(&result.ShedLoad, 1)
+		return err
+	}
+
+	// Predicate failures are logical errors in an otherwise good response
+	var soft *SoftFailure
+	if errors.As(err, &soft) {
+		atomic.AddInt64(&result.SoftFailures, 1)
+		atomic.AddInt64(&result.OperationStats[operation].SoftErrors, 1)
+		err = nil
+	}
+
+	atomic.AddInt64(totalDuration, int64(duration))
+	atomic.AddInt64(&result.TotalRequests, 1)
+
+	st.recordSample(duration)
+	st.timeline.record(start.Add(duration), duration, err)
+	if st.burst != nil {
+		st.burst.record(start, duration, err != nil)
+	}
+	if st.steadyState != nil {
+		st.steadyState.record(duration)
+	}
+	if st.liveMetrics != nil {
+		st.liveMetrics.Observe(operation, duration, err != nil)
+	}
+
+	if err != nil {
+		atomic.AddInt64(&result.FailedRequests, 1)
+		st.updateOperationStats(result, operation, duration, true)
+		if st.failFast && ctx.Err() == nil && !errors.Is(err, context.Canceled) {
+			st.abortOnce.Do(func() {
+				result.AbortError = fmt.Sprintf("%s: %v", operation, err)
+				st.abort()
+			})
 		}
+	} else {
+		atomic.AddInt64(&result.SuccessRequests, 1)
+		st.updateOperationStats(result, operation, duration, false)
 	}
+
+	// Update min/max
+	atomicMinDuration(&result.MinResponseTime, duration)
+	atomicMaxDuration(&result.MaxResponseTime, duration)
+	return err
+}
+
+// targetRPS is the average request rate the run was configured to deliver,
+// or 0 when unlimited
+func (st *StressTest) targetRPS() float64 {
+	if st.config.StressTest.Sessions.Enabled {
+		return 0 // closed loop: think time, not a rate, paces the users
+	}
+	if burst := st.config.StressTest.Burst; burst.Enabled && burst.BurstPeriod > 0 {
+		burstShare := float64(burst.BurstDuration) / float64(burst.BurstPeriod)
+		return float64(burst.BurstRate)*burstShare + float64(burst.BaselineRate)*(1-burstShare)
+	}
+	return float64(st.config.StressTest.RequestRate)
+}
+
+// weightedOperation is a stress test operation and its share of the mix
+type weightedOperation struct {
+	name   string
+	weight int
+}
+
+// operationWeights lists every operation with its configured weight
+func operationWeights(weights config.Operations) []weightedOperation {
+	return []weightedOperation{
+		{"create", weights.CreateMailWeight},
+		{"list", weights.ListMailWeight},
+		{"search", weights.SearchWeight},
+		{"draft", weights.DraftWeight},
+		{"forward", weights.ForwardWeight},
+		{"reply_all", weights.ReplyAllWeight},
+		{"soft_delete", weights.SoftDeleteWeight},
+		{"count", weights.CountWeight},
+	}
+}
+
+// runnableOperations returns the positively weighted operations the handler
+// supports, and the names of those it doesn't
+func (st *StressTest) runnableOperations() (runnable []weightedOperation, skipped []string) {
+	for _, op := range operationWeights(st.config.StressTest.Operations) {
+		switch {
+		case op.weight <= 0:
+		case handler.SupportsOperation(st.handler, op.name):
+			runnable = append(runnable, op)
+		default:
+			skipped = append(skipped, op.name)
+		}
+	}
+	return runnable, skipped
 }
 
 func (st *StressTest) selectOperation() string {
-	weights := st.config.StressTest.Operations
-	total := weights.CreateMailWeight + weights.ListMailWeight + weights.SearchWeight
-	r := rand.Intn(total)
+	ops := st.operations
+	total := 0
+	for _, op := range ops {
+		total += op.weight
+	}
 
-	if r < weights.CreateMailWeight {
-		return "create"
-	} else if r < weights.CreateMailWeight+weights.ListMailWeight {
-		return "list"
+	r := rand.Intn(total)
+	for _, op := range ops {
+		if r < op.weight {
+			return op.name
+		}
+		r -= op.weight
 	}
-	return "search"
+	return ops[len(ops)-1].name
 }
 
 func (st *StressTest) executeOperation(ctx context.Context, operation string) error {
@@ -155,48 +678,217 @@ func (st *StressTest) executeOperation(ctx context.Context, operation string) er
 		return st.listMails(ctx)
 	case "search":
 		return st.searchMails(ctx)
+	case "draft":
+		return st.draftMail(ctx)
+	case "forward":
+		return st.forwardMail(ctx)
+	case "reply_all":
+		return st.replyAll(ctx)
+	case "soft_delete":
+		return st.softDeleteMail(ctx)
+	case "count":
+		return st.countMails(ctx)
 	default:
 		return fmt.Errorf("unknown operation: %s", operation)
 	}
 }
 
+// replayOperation sends one operation of a golden workload verbatim
+func (st *StressTest) replayOperation(ctx context.Context, op *WorkloadOperation) error {
+	switch op.Op {
+	case "create":
+		return st.sendCreate(ctx, op.Create)
+	case "list":
+		return st.sendList(ctx, op.List)
+	case "search":
+		return st.sendSearch(ctx, op.Search)
+	default:
+		return fmt.Errorf("unknown workload operation: %s", op.Op)
+	}
+}
+
 func (st *StressTest) createMail(ctx context.Context) error {
-	// Generate mail request with optional reply
-	var replyToID string
-	if rand.Float32() < 0.3 { // 30% chance of being a reply
-		replyToID = primitive.NewObjectID().Hex() // In real scenario, you'd pick from existing mails
+	return st.sendCreate(ctx, st.newMail(ctx, randomReplyToID()))
+}
+
+// newMail generates a mail sent by the acting user, or by a random
+// participant when there is none
+func (st *StressTest) newMail(ctx context.Context, replyToID string) *models.MailRequest {
+	if userID, ok := actingUser(ctx); ok {
+		return st.generator.GenerateCreateMailRequestFrom(userID, replyToID)
 	}
+	return st.generator.GenerateCreateMailRequest(replyToID)
+}
 
-	req := st.generator.GenerateCreateMailRequest(replyToID)
-	return st.handler.CreateMail(ctx, req)
+// userID returns the acting user, or a random user when there is none
+func (st *StressTest) userID(ctx context.Context) string {
+	if userID, ok := actingUser(ctx); ok {
+		return userID
+	}
+	return st.generator.GetRandomUserID()
+}
+
+func (st *StressTest) sendCreate(ctx context.Context, req *models.MailRequest) error {
+	err := st.handler.CreateMail(ctx, req)
+	if err != nil && st.deadLetter != nil && !errors.Is(err, handler.ErrCircuitOpen) {
+		st.deadLetter.record(req, err)
+	}
+	return err
 }
 
 func (st *StressTest) listMails(ctx context.Context) error {
 	req := st.generator.GenerateListMailsRequest()
-	_, err := st.handler.ListMails(ctx, req)
+	if userID, ok := actingUser(ctx); ok {
+		req.UserID = userID
+	}
+	return st.sendList(ctx, req)
+}
+
+func (st *StressTest) sendList(ctx context.Context, req *models.ListMailsRequest) error {
+	_, err := st.listInbox(ctx, req)
 	return err
 }
 
+// listInbox lists a page of mails and checks it against the success criteria
+func (st *StressTest) listInbox(ctx context.Context, req *models.ListMailsRequest) ([]*models.Mail, error) {
+	start := time.Now()
+	mails, err := st.handler.ListMails(ctx, req)
+	if st.inboxSizes != nil {
+		st.inboxSizes.record("list", req.UserID, time.Since(start))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return mails, checkListResult(st.config.StressTest.SuccessCriteria, req, mails)
+}
+
 func (st *StressTest) searchMails(ctx context.Context) error {
-	req := st.generator.GenerateSearchMailsRequest()
-	_, err := st.handler.SearchMails(ctx, req)
+	if userID, ok := actingUser(ctx); ok {
+		return st.sendSearch(ctx, st.generator.GenerateSearchMailsRequestFor(userID))
+	}
+	return st.sendSearch(ctx, st.generator.GenerateSearchMailsRequest())
+}
+
+func (st *StressTest) sendSearch(ctx context.Context, req *models.SearchMailsRequest) error {
+	start := time.Now()
+	mails, err := st.handler.SearchMails(ctx, req)
+	if st.inboxSizes != nil {
+		st.inboxSizes.record("search", req.UserID, time.Since(start))
+	}
+	if err != nil {
+		return err
+	}
+	return checkSearchResult(st.config.StressTest.SuccessCriteria, req, mails)
+}
+
+// countMails counts the acting or a random user's mails, bucketed by inbox
+// size when inbox sizes are known
+func (st *StressTest) countMails(ctx context.Context) error {
+	userID := st.userID(ctx)
+	start := time.Now()
+	_, err := st.handler.CountMails(ctx, userID)
+	if st.inboxSizes != nil {
+		st.inboxSizes.record("count", userID, time.Since(start))
+	}
 	return err
 }
 
-func (st *StressTest) updateOperationStats(result *StressTestResult, operation string, duration time.Duration, isError bool) {
-	stats := result.OperationStats[operation]
+// draftMail saves a draft, edits it in place several times, then sends it
+// with probability DraftSendRatio
+func (st *StressTest) draftMail(ctx context.Context) error {
+	drafts, ok := st.handler.(handler.DraftHandler)
+	if !ok {
+		return fmt.Errorf("handler does not support drafts")
+	}
 
-	atomic.AddInt64(&stats.Count, 1)
-	atomic.AddInt64((*int64)(&stats.AvgDuration), int64(duration))
+	draftID, err := drafts.SaveDraft(ctx, st.newMail(ctx, ""))
+	if err != nil {
+		return err
+	}
 
-	if isError {
-		atomic.AddInt64(&stats.Errors, 1)
+	for i := 0; i < st.config.StressTest.DraftUpdates; i++ {
+		if err := drafts.UpdateDraft(ctx, draftID, st.generator.GenerateDraftUpdate()); err != nil {
+			return err
+		}
+	}
+
+	if rand.Float64() < st.config.StressTest.DraftSendRatio {
+		return drafts.SendDraft(ctx, draftID)
+	}
+	return nil
+}
+
+// forwardMail forwards one of a random user's recent mails
+func (st *StressTest) forwardMail(ctx context.Context) error {
+	forwarder, ok := st.handler.(handler.ForwardHandler)
+	if !ok {
+		return fmt.Errorf("handler does not support forwarding")
 	}
 
-	if duration < stats.MinDuration {
-		stats.MinDuration = duration
+	userID, original, err := st.pickRecentMail(ctx)
+	if err != nil {
+		return err
 	}
-	if duration > stats.MaxDuration {
-		stats.MaxDuration = duration
+
+	req := st.generator.GenerateForwardMailRequest(original.ID.Hex(), userID)
+	return forwarder.ForwardMail(ctx, req)
+}
+
+// replyAll replies to all participants of one of a random user's recent mails
+func (st *StressTest) replyAll(ctx context.Context) error {
+	replier, ok := st.handler.(handler.ReplyAllHandler)
+	if !ok {
+		return fmt.Errorf("handler does not support reply-all")
+	}
+
+	userID, parent, err := st.pickRecentMail(ctx)
+	if err != nil {
+		return err
+	}
+
+	req := st.generator.GenerateReplyAllRequest(parent.ID.Hex(), userID)
+	return replier.ReplyAll(ctx, req)
+}
+
+// softDeleteMail moves one of a random user's recent mails to the trash
+func (st *StressTest) softDeleteMail(ctx context.Context) error {
+	deleter, ok := st.handler.(handler.SoftDeleteHandler)
+	if !ok {
+		return fmt.Errorf("handler does not support soft delete")
 	}
+
+	_, mail, err := st.pickRecentMail(ctx)
+	if err != nil {
+		return err
+	}
+	return deleter.SoftDeleteMail(ctx, mail.ID.Hex())
+}
+
+// pickRecentMail lists the acting or a random user's recent mails and
+// returns one of them
+func (st *StressTest) pickRecentMail(ctx context.Context) (string, *models.Mail, error) {
+	userID := st.userID(ctx)
+	mails, err := st.handler.ListMails(ctx, &models.ListMailsRequest{UserID: userID, Limit: 20})
+	if err != nil {
+		return "", nil, err
+	}
+	if len(mails) == 0 {
+		return "", nil, fmt.Errorf("no recent mail for user %s", userID)
+	}
+	return userID, mails[rand.Intn(len(mails))], nil
+}
+
+// recordSample captures a response time for percentile and histogram reporting
+func (st *StressTest) recordSample(duration time.Duration) {
+	st.samplesMu.Lock()
+	if st.streaming != nil {
+		st.streaming.add(duration)
+	} else {
+		st.samples = append(st.samples, duration)
+	}
+	st.samplesMu.Unlock()
+}
+
+func (st *StressTest) updateOperationStats(result *StressTestResult, operation string, duration time.Duration, isError bool) {
+	result.OperationStats[operation].record(duration, isError)
 }
@@ -0,0 +1,385 @@
+package benchmark
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"mail-stress-test/config"
+	"mail-stress-test/database"
+	"mail-stress-test/generator"
+	"mail-stress-test/handler"
+	"mail-stress-test/models"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// fakeHandler is an in-memory MailHandler. Each operation calls its hook
+// when set and succeeds with no results otherwise.
+type fakeHandler struct {
+	create func(ctx context.Context, req *models.MailRequest) error
+	list   func(ctx context.Context, req *models.ListMailsRequest) ([]*models.Mail, error)
+	search func(ctx context.Context, req *models.SearchMailsRequest) ([]*models.Mail, error)
+	count  func(ctx context.Context, userID string) (int64, error)
+}
+
+func (h *fakeHandler) CreateMail(ctx context.Context, req *models.MailRequest) error {
+	if h.create != nil {
+		return h.create(ctx, req)
+	}
+	return nil
+}
+
+func (h *fakeHandler) ListMails(ctx context.Context, req *models.ListMailsRequest) ([]*models.Mail, error) {
+	if h.list != nil {
+		return h.list(ctx, req)
+	}
+	return nil, nil
+}
+
+func (h *fakeHandler) SearchMails(ctx context.Context, req *models.SearchMailsRequest) ([]*models.Mail, error) {
+	if h.search != nil {
+		return h.search(ctx, req)
+	}
+	return nil, nil
+}
+
+func (h *fakeHandler) CountMails(ctx context.Context, userID string) (int64, error) {
+	if h.count != nil {
+		return h.count(ctx, userID)
+	}
+	return 0, nil
+}
+
+// newTestStressTest returns a stress test over h with a few generated users
+// and a short, create-only run that tests adjust as needed
+func newTestStressTest(t *testing.T, h handler.MailHandler) (*StressTest, *config.Config) {
+	t.Helper()
+	gen, err := generator.NewDataGenerator([]string{"user-1", "user-2", "user-3", "user-4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.DefaultConfig()
+	cfg.StressTest.ConcurrentWorkers = 4
+	cfg.StressTest.RequestRate = 0
+	cfg.StressTest.Duration = 200 * time.Millisecond
+	cfg.StressTest.Operations = config.Operations{CreateMailWeight: 100}
+	return NewStressTest(cfg, gen, h), cfg
+}
+
+// TestOperationStatsConcurrentRecord has many workers record into one stats
+// object at once and checks nothing was lost. Run with -race.
+func TestOperationStatsConcurrentRecord(t *testing.T) {
+	const workers, perWorker = 64, 2000
+	stats := &OperationStats{MinDuration: time.Hour}
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				// Durations 1µs..1000µs, shifted per worker so min and max
+				// are hit by different goroutines
+				d := time.Duration((w*perWorker+i)%1000+1) * time.Microsecond
+				stats.record(d, i%7 == 0)
+			}
+		}(w)
+	}
+	wg.Wait()
+	stats.finalize()
+
+	var total time.Duration
+	var wantErrors int64
+	for w := 0; w < workers; w++ {
+		for i := 0; i < perWorker; i++ {
+			total += time.Duration((w*perWorker+i)%1000+1) * time.Microsecond
+			if i%7 == 0 {
+				wantErrors++
+			}
+		}
+	}
+	count := int64(workers * perWorker)
+
+	if stats.Count != count {
+		t.Errorf("Count = %d, want %d", stats.Count, count)
+	}
+	if stats.Errors != wantErrors {
+		t.Errorf("Errors = %d, want %d", stats.Errors, wantErrors)
+	}
+	if want := total / time.Duration(count); stats.AvgDuration != want {
+		t.Errorf("AvgDuration = %s, want %s", stats.AvgDuration, want)
+	}
+	if stats.MinDuration != time.Microsecond {
+		t.Errorf("MinDuration = %s, want 1µs", stats.MinDuration)
+	}
+	if stats.MaxDuration != 1000*time.Microsecond {
+		t.Errorf("MaxDuration = %s, want 1ms", stats.MaxDuration)
+	}
+}
+
+func TestOperationStatsFinalizeWithoutRequests(t *testing.T) {
+	stats := &OperationStats{MinDuration: time.Hour}
+	stats.finalize()
+	if stats.AvgDuration != 0 || stats.MinDuration != 0 || stats.MaxDuration != 0 {
+		t.Errorf("unused stats should report zero durations: %+v", stats)
+	}
+}
+
+// slowDBHandler returns a DBHandler whose MongoDB accepts connections but
+// never answers, so every operation hangs until its context is done
+func slowDBHandler(t *testing.T) *handler.DBHandler {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var conns []net.Conn
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			conns = append(conns, conn)
+			mu.Unlock()
+		}
+	}()
+
+	client, err := mongo.Connect(context.Background(), options.Client().
+		ApplyURI("mongodb://"+listener.Addr().String()).
+		SetDirect(true).
+		SetServerSelectionTimeout(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		listener.Close()
+		mu.Lock()
+		for _, conn := range conns {
+			conn.Close()
+		}
+		mu.Unlock()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		client.Disconnect(ctx)
+	})
+
+	return handler.NewDBHandler(&database.MongoDB{
+		Client:            client,
+		Database:          client.Database("stress_test"),
+		MailsCollection:   "mails",
+		ThreadsCollection: "threads",
+	})
+}
+
+// newOperationRunner returns a stress test set up for calling runOperation
+// directly, outside Run
+func newOperationRunner(t *testing.T, h handler.MailHandler) (*StressTest, *StressTestResult) {
+	t.Helper()
+	gen, err := generator.NewDataGenerator([]string{"user-1", "user-2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := &StressTest{
+		config:    &config.Config{},
+		generator: gen,
+		handler:   h,
+		timeline:  newTimeSeriesRecorder(time.Now(), time.Second),
+		watchdog:  newWorkerWatchdog(1),
+	}
+	result := &StressTestResult{
+		MinResponseTime: time.Hour,
+		OperationStats:  map[string]*OperationStats{"count": {MinDuration: time.Hour}},
+	}
+	return st, result
+}
+
+// TestSlowDBInFlightCapBlocks sends three times the cap of operations to a
+// database that never answers: they queue for a slot instead of piling up,
+// and none is shed
+func TestSlowDBInFlightCapBlocks(t *testing.T) {
+	const limit, waves = 3, 3
+	h := slowDBHandler(t)
+	h.SetMaxInFlight(limit, false)
+	st, result := newOperationRunner(t, h)
+	var totalDuration int64
+
+	// Each wave holds its slots until its deadline, which is when the next
+	// wave's operations get in
+	var wg sync.WaitGroup
+	for i := 0; i < limit*waves; i++ {
+		wg.Add(1)
+		go func(wave int) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), time.Duration(wave+1)*50*time.Millisecond)
+			defer cancel()
+			if err := st.runOperation(ctx, result, &totalDuration, "count", st.countMails); errors.Is(err, handler.ErrInFlightLimit) {
+				t.Errorf("blocking mode shed an operation")
+			}
+		}(i / limit)
+	}
+	wg.Wait()
+
+	stats, ok := h.InFlightStats()
+	if !ok {
+		t.Fatal("InFlightStats not reported with a cap set")
+	}
+	if stats.Peak > limit {
+		t.Errorf("in-flight peak %d exceeds the cap of %d", stats.Peak, limit)
+	}
+	if stats.Peak != limit {
+		t.Errorf("in-flight peak %d, want the cap %d to be reached", stats.Peak, limit)
+	}
+	if stats.Waits == 0 {
+		t.Errorf("operations over the cap should have waited: %+v", stats)
+	}
+	if result.ShedLoad != 0 || stats.Rejections != 0 {
+		t.Errorf("blocking mode shed load: result %d, limiter %d", result.ShedLoad, stats.Rejections)
+	}
+	if result.TotalRequests != limit*waves {
+		t.Errorf("TotalRequests = %d, want %d", result.TotalRequests, limit*waves)
+	}
+}
+
+// TestSlowDBInFlightCapShedsLoad fills the cap with operations stuck on a
+// database that never answers and checks further operations are shed,
+// counted as shed load and not as requests
+func TestSlowDBInFlightCapShedsLoad(t *testing.T) {
+	const limit, over = 2, 5
+	h := slowDBHandler(t)
+	h.SetMaxInFlight(limit, true)
+	st, result := newOperationRunner(t, h)
+	var totalDuration int64
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var held sync.WaitGroup
+	for i := 0; i < limit; i++ {
+		held.Add(1)
+		go func() {
+			defer held.Done()
+			st.runOperation(ctx, result, &totalDuration, "count", st.countMails)
+		}()
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for stats, _ := h.InFlightStats(); stats.Peak < limit; stats, _ = h.InFlightStats() {
+		if time.Now().After(deadline) {
+			t.Fatalf("the cap never filled: %+v", stats)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	for i := 0; i < over; i++ {
+		if err := st.runOperation(context.Background(), result, &totalDuration, "count", st.countMails); !errors.Is(err, handler.ErrInFlightLimit) {
+			t.Errorf("operation over the cap: got %v, want ErrInFlightLimit", err)
+		}
+	}
+	cancel()
+	held.Wait()
+
+	stats, _ := h.InFlightStats()
+	if stats.Peak > limit {
+		t.Errorf("in-flight peak %d exceeds the cap of %d", stats.Peak, limit)
+	}
+	if result.ShedLoad != over || stats.Rejections != over {
+		t.Errorf("shed load: result %d, limiter %d, want %d", result.ShedLoad, stats.Rejections, over)
+	}
+	if result.TotalRequests != limit || result.FailedRequests != limit {
+		t.Errorf("requests %d (failed %d), want only the %d held operations counted", result.TotalRequests, result.FailedRequests, limit)
+	}
+}
+
+// TestFailFastAbortsOnFirstError fails the first request of a long run and
+// checks fail-fast stops the run promptly, recording the error, while the
+// default mode keeps going
+func TestFailFastAbortsOnFirstError(t *testing.T) {
+	newHandler := func() *fakeHandler {
+		var calls int64
+		return &fakeHandler{create: func(ctx context.Context, req *models.MailRequest) error {
+			if atomic.AddInt64(&calls, 1) == 1 {
+				return errors.New("backend returned 500")
+			}
+			time.Sleep(time.Millisecond)
+			return nil
+		}}
+	}
+
+	st, cfg := newTestStressTest(t, newHandler())
+	cfg.StressTest.Duration = 10 * time.Second
+	st.SetFailFast(true)
+	start := time.Now()
+	result, err := st.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("fail-fast run took %s after the first error, want it aborted promptly", elapsed)
+	}
+	if !strings.Contains(result.AbortError, "create: backend returned 500") {
+		t.Errorf("AbortError = %q, want the first error", result.AbortError)
+	}
+	if result.FailedRequests != 1 {
+		t.Errorf("FailedRequests = %d, want 1", result.FailedRequests)
+	}
+
+	st, cfg = newTestStressTest(t, newHandler())
+	cfg.StressTest.Duration = 200 * time.Millisecond
+	start = time.Now()
+	result, err = st.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < cfg.StressTest.Duration {
+		t.Errorf("default run stopped after %s, want the full %s", elapsed, cfg.StressTest.Duration)
+	}
+	if result.AbortError != "" {
+		t.Errorf("default run aborted: %s", result.AbortError)
+	}
+}
+
+// warmingHandler is a fakeHandler that records its warm-up call
+type warmingHandler struct {
+	fakeHandler
+	connections, requests int
+}
+
+func (h *warmingHandler) WarmUp(ctx context.Context, connections, requests int) error {
+	h.connections, h.requests = connections, requests
+	time.Sleep(10 * time.Millisecond)
+	return nil
+}
+
+// TestWarmUpExcludedFromResults checks the handler is warmed once per
+// worker before measurement and its warm-up calls are not counted
+func TestWarmUpExcludedFromResults(t *testing.T) {
+	var creates atomic.Int64
+	h := &warmingHandler{fakeHandler: fakeHandler{create: func(ctx context.Context, req *models.MailRequest) error {
+		creates.Add(1)
+		return nil
+	}}}
+	st, cfg := newTestStressTest(t, h)
+	cfg.StressTest.WarmUpRequests = 5
+
+	result, err := st.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.connections != cfg.StressTest.ConcurrentWorkers || h.requests != 5 {
+		t.Errorf("WarmUp(%d, %d), want (%d, 5)", h.connections, h.requests, cfg.StressTest.ConcurrentWorkers)
+	}
+	if result.WarmUpDuration < 10*time.Millisecond {
+		t.Errorf("WarmUpDuration = %s, want the warm-up timed", result.WarmUpDuration)
+	}
+	if result.TotalRequests != creates.Load() {
+		t.Errorf("TotalRequests = %d, want the %d measured creates only", result.TotalRequests, creates.Load())
+	}
+}
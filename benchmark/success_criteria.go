@@ -0,0 +1,50 @@
+package benchmark
+
+import (
+	"fmt"
+
+	"mail-stress-test/config"
+	"mail-stress-test/generator"
+	"mail-stress-test/models"
+)
+
+// SoftFailure is returned when an operation completed without error but its
+// response broke a configured success predicate. It is counted separately
+// from errors.
+type SoftFailure struct {
+	Operation string
+	Reason    string
+}
+
+func (e *SoftFailure) Error() string {
+	return fmt.Sprintf("%s: %s", e.Operation, e.Reason)
+}
+
+// checkSearchResult requires at least SearchMinResults mails for a term from
+// the seeded subject vocabulary; unique cold terms are expected to miss
+func checkSearchResult(criteria config.SuccessCriteria, req *models.SearchMailsRequest, mails []*models.Mail) error {
+	if criteria.SearchMinResults <= 0 || !generator.IsKnownTerm(req.SearchTerm) {
+		return nil
+	}
+	if len(mails) < criteria.SearchMinResults {
+		return &SoftFailure{
+			Operation: "search",
+			Reason:    fmt.Sprintf("term %q returned %d results, expected at least %d", req.SearchTerm, len(mails), criteria.SearchMinResults),
+		}
+	}
+	return nil
+}
+
+// checkListResult rejects pages larger than the requested limit
+func checkListResult(criteria config.SuccessCriteria, req *models.ListMailsRequest, mails []*models.Mail) error {
+	if !criteria.ListWithinLimit || req.Limit <= 0 {
+		return nil
+	}
+	if len(mails) > req.Limit {
+		return &SoftFailure{
+			Operation: "list",
+			Reason:    fmt.Sprintf("returned %d rows for limit %d", len(mails), req.Limit),
+		}
+	}
+	return nil
+}
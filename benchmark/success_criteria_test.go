@@ -0,0 +1,68 @@
+package benchmark
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"mail-stress-test/config"
+	"mail-stress-test/models"
+)
+
+// TestSoftFailuresCounted runs searches that always come back empty and
+// lists that overrun their limit, and checks each counts as a soft failure
+// per operation while the requests still count as successful
+func TestSoftFailuresCounted(t *testing.T) {
+	h := &fakeHandler{
+		list: func(ctx context.Context, req *models.ListMailsRequest) ([]*models.Mail, error) {
+			return make([]*models.Mail, req.Limit+1), nil
+		},
+	}
+	st, cfg := newTestStressTest(t, h)
+	cfg.StressTest.Operations = config.Operations{ListMailWeight: 50, SearchWeight: 50}
+	cfg.StressTest.SuccessCriteria = config.SuccessCriteria{SearchMinResults: 1, ListWithinLimit: true}
+
+	result, err := st.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, search := result.OperationStats["list"], result.OperationStats["search"]
+	if list.Count == 0 || search.Count == 0 {
+		t.Fatalf("ran %d lists and %d searches, want both", list.Count, search.Count)
+	}
+	if list.SoftErrors != list.Count || search.SoftErrors != search.Count {
+		t.Errorf("soft errors: list %d/%d, search %d/%d; want every request", list.SoftErrors, list.Count, search.SoftErrors, search.Count)
+	}
+	if result.SoftFailures != list.Count+search.Count {
+		t.Errorf("SoftFailures = %d, want %d", result.SoftFailures, list.Count+search.Count)
+	}
+	if result.FailedRequests != 0 || list.Errors != 0 || search.Errors != 0 {
+		t.Errorf("%d failed requests, want soft failures kept out of errors", result.FailedRequests)
+	}
+}
+
+func TestSuccessPredicates(t *testing.T) {
+	criteria := config.SuccessCriteria{SearchMinResults: 2, ListWithinLimit: true}
+	two := make([]*models.Mail, 2)
+	var soft *SoftFailure
+
+	if err := checkSearchResult(criteria, &models.SearchMailsRequest{SearchTerm: "Weekly Report"}, two[:1]); !errors.As(err, &soft) || soft.Operation != "search" {
+		t.Errorf("known term with 1 of 2 results: %v, want a search soft failure", err)
+	}
+	if err := checkSearchResult(criteria, &models.SearchMailsRequest{SearchTerm: "Weekly Report"}, two); err != nil {
+		t.Errorf("known term with enough results: %v", err)
+	}
+	if err := checkSearchResult(criteria, &models.SearchMailsRequest{SearchTerm: "Weekly17"}, nil); err != nil {
+		t.Errorf("cold term without results: %v, want it expected to miss", err)
+	}
+	if err := checkSearchResult(config.SuccessCriteria{}, &models.SearchMailsRequest{SearchTerm: "Weekly Report"}, nil); err != nil {
+		t.Errorf("disabled predicate: %v", err)
+	}
+
+	if err := checkListResult(criteria, &models.ListMailsRequest{Limit: 1}, two); !errors.As(err, &soft) || soft.Operation != "list" {
+		t.Errorf("2 rows for limit 1: %v, want a list soft failure", err)
+	}
+	if err := checkListResult(criteria, &models.ListMailsRequest{Limit: 2}, two); err != nil {
+		t.Errorf("rows within the limit: %v", err)
+	}
+}
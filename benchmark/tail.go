@@ -0,0 +1,74 @@
+package benchmark
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultTailPercentiles are reported beyond P50/P95/P99 when
+// report.tail_percentiles is not set
+var DefaultTailPercentiles = []float64{99.9}
+
+// PercentileValue is the latency at one (possibly fractional) percentile
+type PercentileValue struct {
+	Percentile float64       `json:"percentile"` // e.g. 99.9
+	Latency    time.Duration `json:"latency"`
+}
+
+func (p PercentileValue) String() string {
+	return fmt.Sprintf("P%g=%s", p.Percentile, p.Latency)
+}
+
+// FormatPercentiles renders values as "P99.9=12ms, P99.99=40ms"
+func FormatPercentiles(values []PercentileValue) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = v.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+// tailPercentiles returns the configured tail percentiles, or the defaults
+func tailPercentiles(configured []float64) []float64 {
+	if configured == nil {
+		return DefaultTailPercentiles
+	}
+	return configured
+}
+
+// calculateTailPercentiles computes every percentile in ps from one sorted
+// copy of durations
+func calculateTailPercentiles(durations []time.Duration, ps []float64) []PercentileValue {
+	if len(durations) == 0 || len(ps) == 0 {
+		return nil
+	}
+
+	sorted := make([]time.Duration, len(durations))
+	copy(sorted, durations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	values := make([]PercentileValue, len(ps))
+	for i, p := range ps {
+		values[i] = PercentileValue{Percentile: p, Latency: sorted[nearestRank(len(sorted), p)]}
+	}
+	return values
+}
+
+// nearestRank returns the index of the pth percentile (0-100, fractional
+// allowed) in n sorted samples: the smallest sample with at least p% of all
+// samples at or below it. With 1000 samples P99.9 is the 999th.
+func nearestRank(n int, p float64) int {
+	// Round before Ceil so float error in p/100*n (e.g. 99.9/100*1000 =
+	// 999.0000000000001) does not skip to the next sample
+	rank := int(math.Ceil(math.Round(p/100*float64(n)*1e6)/1e6)) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= n {
+		rank = n - 1
+	}
+	return rank
+}
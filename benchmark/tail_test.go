@@ -0,0 +1,84 @@
+package benchmark
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"mail-stress-test/models"
+)
+
+// TestNearestRankP999 builds 1000 samples where only the slowest two stand
+// out and checks P99.9 lands on the 999th sample rather than being rounded
+// up to the worst one or down to the bulk
+func TestNearestRankP999(t *testing.T) {
+	durations := make([]time.Duration, 1000)
+	for i := range durations {
+		durations[i] = time.Millisecond
+	}
+	durations[998] = 50 * time.Millisecond
+	durations[999] = 900 * time.Millisecond
+	// Unsorted input: the slow samples first
+	durations[0], durations[998] = durations[998], durations[0]
+	durations[1], durations[999] = durations[999], durations[1]
+
+	values := calculateTailPercentiles(durations, []float64{99, 99.9, 99.99, 100})
+	want := []time.Duration{time.Millisecond, 50 * time.Millisecond, 900 * time.Millisecond, 900 * time.Millisecond}
+	for i, v := range values {
+		if v.Latency != want[i] {
+			t.Errorf("%s, want %s", v, want[i])
+		}
+	}
+	if got := FormatPercentiles(values[1:2]); got != "P99.9=50ms" {
+		t.Errorf("FormatPercentiles = %q", got)
+	}
+	if durations[0] != 50*time.Millisecond {
+		t.Error("calculateTailPercentiles sorted its input in place")
+	}
+}
+
+func TestNearestRank(t *testing.T) {
+	tests := []struct {
+		n    int
+		p    float64
+		want int
+	}{
+		{1000, 99.9, 998},
+		{1000, 99.99, 999},
+		{10000, 99.99, 9998},
+		{100, 50, 49},
+		{3, 0, 0},
+		{1, 99.9, 0},
+	}
+	for _, tt := range tests {
+		if got := nearestRank(tt.n, tt.p); got != tt.want {
+			t.Errorf("nearestRank(%d, %g) = %d, want %d", tt.n, tt.p, got, tt.want)
+		}
+	}
+}
+
+// TestStressReportsTailPercentiles checks a run reports the default P99.9,
+// or the configured tail percentiles in order
+func TestStressReportsTailPercentiles(t *testing.T) {
+	h := &fakeHandler{create: func(ctx context.Context, req *models.MailRequest) error { return nil }}
+	st, _ := newTestStressTest(t, h)
+	result, err := st.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.TailPercentiles) != 1 || result.TailPercentiles[0].Percentile != 99.9 {
+		t.Errorf("default tail percentiles = %v, want P99.9", result.TailPercentiles)
+	}
+
+	st, cfg := newTestStressTest(t, h)
+	cfg.Report.TailPercentiles = []float64{99.5, 99.99}
+	if result, err = st.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.TailPercentiles) != 2 || result.TailPercentiles[0].Percentile != 99.5 || result.TailPercentiles[1].Percentile != 99.99 {
+		t.Errorf("configured tail percentiles = %v, want P99.5 and P99.99", result.TailPercentiles)
+	}
+	if tail := result.TailPercentiles; tail[0].Latency > tail[1].Latency || tail[1].Latency > result.MaxResponseTime {
+		t.Errorf("tail %v not ordered up to the max %s", tail, result.MaxResponseTime)
+	}
+}
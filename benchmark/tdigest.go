@@ -0,0 +1,168 @@
+package benchmark
+
+import (
+	"math"
+	"sort"
+)
+
+// DefaultTDigestCompression trades accuracy for memory; roughly the number
+// of centroids kept, so ~100 gives sub-percent error on tail percentiles
+const DefaultTDigestCompression = 100
+
+// TDigest is a merging t-digest that estimates quantiles of a stream in
+// bounded memory. Tails are kept at higher resolution than the median.
+// It is not safe for concurrent use.
+type TDigest struct {
+	compression float64
+	centroids   []centroid
+	buffer      []centroid
+	count       float64
+	min         float64
+	max         float64
+}
+
+type centroid struct {
+	mean   float64
+	weight float64
+}
+
+// NewTDigest creates a digest; compression <= 0 uses DefaultTDigestCompression
+func NewTDigest(compression float64) *TDigest {
+	if compression <= 0 {
+		compression = DefaultTDigestCompression
+	}
+	return &TDigest{
+		compression: compression,
+		min:         math.Inf(1),
+		max:         math.Inf(-1),
+	}
+}
+
+// Add inserts a value into the digest
+func (t *TDigest) Add(x float64) {
+	t.buffer = append(t.buffer, centroid{mean: x, weight: 1})
+	t.count++
+	if x < t.min {
+		t.min = x
+	}
+	if x > t.max {
+		t.max = x
+	}
+	if len(t.buffer) >= int(5*t.compression) {
+		t.compress()
+	}
+}
+
+// Count returns the number of values added
+func (t *TDigest) Count() int64 {
+	return int64(t.count)
+}
+
+// compress merges buffered values into the centroid list, keeping each
+// centroid within the size bound of the k1 scale function
+func (t *TDigest) compress() {
+	if len(t.buffer) == 0 {
+		return
+	}
+
+	all := make([]centroid, 0, len(t.centroids)+len(t.buffer))
+	all = append(all, t.centroids...)
+	all = append(all, t.buffer...)
+	t.buffer = t.buffer[:0]
+	sort.Slice(all, func(i, j int) bool { return all[i].mean < all[j].mean })
+
+	merged := make([]centroid, 0, len(t.centroids)+1)
+	current := all[0]
+	var cumulative float64
+	for _, c := range all[1:] {
+		q := (cumulative + (current.weight+c.weight)/2) / t.count
+		limit := math.Max(1, 4*t.count*q*(1-q)/t.compression)
+
+		if current.weight+c.weight <= limit {
+			current.weight += c.weight
+			current.mean += (c.mean - current.mean) * c.weight / current.weight
+			continue
+		}
+		cumulative += current.weight
+		merged = append(merged, current)
+		current = c
+	}
+	t.centroids = append(merged, current)
+}
+
+// Quantile estimates the value at q (0..1) by interpolating between centroids
+func (t *TDigest) Quantile(q float64) float64 {
+	t.compress()
+	if len(t.centroids) == 0 {
+		return 0
+	}
+	if q <= 0 {
+		return t.min
+	}
+	if q >= 1 {
+		return t.max
+	}
+	if len(t.centroids) == 1 {
+		return t.centroids[0].mean
+	}
+
+	target := q * t.count
+	var cumulative float64
+	for i, c := range t.centroids {
+		mid := cumulative + c.weight/2
+		if target < mid {
+			if i == 0 {
+				return interpolate(t.min, c.mean, target/mid)
+			}
+			prev := t.centroids[i-1]
+			prevMid := cumulative - prev.weight/2
+			return interpolate(prev.mean, c.mean, (target-prevMid)/(mid-prevMid))
+		}
+		cumulative += c.weight
+	}
+
+	last := t.centroids[len(t.centroids)-1]
+	lastMid := t.count - last.weight/2
+	return interpolate(last.mean, t.max, (target-lastMid)/(t.count-lastMid))
+}
+
+// CDF estimates the fraction of values <= x
+func (t *TDigest) CDF(x float64) float64 {
+	t.compress()
+	if len(t.centroids) == 0 || x < t.min {
+		return 0
+	}
+	if x >= t.max {
+		return 1
+	}
+
+	var cumulative float64
+	for i, c := range t.centroids {
+		if x < c.mean {
+			mid := cumulative + c.weight/2
+			if i == 0 {
+				return mid * fraction(x, t.min, c.mean) / t.count
+			}
+			prev := t.centroids[i-1]
+			prevMid := cumulative - prev.weight/2
+			return (prevMid + (mid-prevMid)*fraction(x, prev.mean, c.mean)) / t.count
+		}
+		cumulative += c.weight
+	}
+
+	last := t.centroids[len(t.centroids)-1]
+	lastMid := t.count - last.weight/2
+	return (lastMid + (t.count-lastMid)*fraction(x, last.mean, t.max)) / t.count
+}
+
+func interpolate(a, b, f float64) float64 {
+	return a + (b-a)*f
+}
+
+// fraction returns where x lies between a and b, as 0..1
+func fraction(x, a, b float64) float64 {
+	if b <= a {
+		return 1
+	}
+	return (x - a) / (b - a)
+}
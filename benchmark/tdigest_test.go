@@ -0,0 +1,78 @@
+package benchmark
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestTDigestQuantileMatchesExactPercentiles(t *testing.T) {
+	distributions := map[string]func(r *rand.Rand) float64{
+		"uniform":     func(r *rand.Rand) float64 { return 1 + r.Float64()*99 },
+		"exponential": func(r *rand.Rand) float64 { return 1 + r.ExpFloat64()*20 },
+		"lognormal":   func(r *rand.Rand) float64 { return math.Exp(3 + r.NormFloat64()*0.8) },
+	}
+
+	for name, sample := range distributions {
+		t.Run(name, func(t *testing.T) {
+			r := rand.New(rand.NewSource(42))
+			digest := NewTDigest(DefaultTDigestCompression)
+			durations := make([]time.Duration, 100000)
+			for i := range durations {
+				ms := sample(r)
+				durations[i] = time.Duration(ms * float64(time.Millisecond))
+				digest.Add(float64(durations[i]))
+			}
+
+			if got := digest.Count(); got != int64(len(durations)) {
+				t.Fatalf("Count() = %d, want %d", got, len(durations))
+			}
+			for _, percentile := range []float64{50, 90, 95, 99, 99.9} {
+				exact := float64(calculatePercentile(durations, percentile))
+				estimate := digest.Quantile(percentile / 100)
+				if relErr := math.Abs(estimate-exact) / exact; relErr > 0.02 {
+					t.Errorf("p%v: t-digest %s, exact %s (%.2f%% error, want <= 2%%)",
+						percentile, time.Duration(estimate), time.Duration(exact), relErr*100)
+				}
+			}
+		})
+	}
+}
+
+func TestTDigestCDFInvertsQuantile(t *testing.T) {
+	r := rand.New(rand.NewSource(7))
+	digest := NewTDigest(DefaultTDigestCompression)
+	for i := 0; i < 50000; i++ {
+		digest.Add(r.ExpFloat64())
+	}
+
+	for _, q := range []float64{0.1, 0.5, 0.9, 0.99} {
+		if got := digest.CDF(digest.Quantile(q)); math.Abs(got-q) > 0.005 {
+			t.Errorf("CDF(Quantile(%v)) = %v", q, got)
+		}
+	}
+}
+
+func TestTDigestEmptyAndBounds(t *testing.T) {
+	digest := NewTDigest(0)
+	if got := digest.Quantile(0.5); got != 0 {
+		t.Errorf("empty digest Quantile(0.5) = %v, want 0", got)
+	}
+
+	for _, x := range []float64{5, 1, 9} {
+		digest.Add(x)
+	}
+	if got := digest.Quantile(0); got != 1 {
+		t.Errorf("Quantile(0) = %v, want min 1", got)
+	}
+	if got := digest.Quantile(1); got != 9 {
+		t.Errorf("Quantile(1) = %v, want max 9", got)
+	}
+	if got := digest.CDF(0); got != 0 {
+		t.Errorf("CDF below min = %v, want 0", got)
+	}
+	if got := digest.CDF(9); got != 1 {
+		t.Errorf("CDF at max = %v, want 1", got)
+	}
+}
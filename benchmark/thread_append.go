@@ -0,0 +1,146 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"mail-stress-test/database"
+	"mail-stress-test/handler"
+	"mail-stress-test/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// defaultThreadAppendSizes are the thread sizes grown when none are configured
+var defaultThreadAppendSizes = []int{10, 100, 500, 1000}
+
+// threadAppendContentSize keeps every appended entry the same size so
+// latency differences come from the array length alone
+const threadAppendContentSize = 256
+
+// AppendBucket holds append latencies for mails arrays of MinSize..MaxSize
+// entries at the time of the append
+type AppendBucket struct {
+	MinSize    int           `json:"min_size"`
+	MaxSize    int           `json:"max_size"`
+	Appends    int           `json:"appends"`
+	Failed     int           `json:"failed"`
+	AvgLatency time.Duration `json:"avg_latency"`
+	P50Latency time.Duration `json:"p50_latency"`
+	P95Latency time.Duration `json:"p95_latency"`
+	P99Latency time.Duration `json:"p99_latency"`
+}
+
+// ThreadAppendResult is the append latency-vs-array-size curve
+type ThreadAppendResult struct {
+	Threads  int             `json:"threads"`
+	Appends  int             `json:"appends"`
+	Duration time.Duration   `json:"duration"`
+	Buckets  []*AppendBucket `json:"buckets"`
+}
+
+// BenchmarkThreadAppend grows one thread per entry of sizes to that many
+// mails through the same upsert create uses, appending round-robin so all
+// threads grow together, and buckets each append by the array size it saw.
+// The benchmark threads are removed afterwards.
+func BenchmarkThreadAppend(ctx context.Context, db *database.MongoDB, h *handler.DBHandler, sizes []int) (*ThreadAppendResult, error) {
+	if len(sizes) == 0 {
+		sizes = defaultThreadAppendSizes
+	}
+	sizes = append([]int(nil), sizes...)
+	sort.Ints(sizes)
+	if sizes[0] <= 0 {
+		return nil, fmt.Errorf("thread append sizes must be positive, got %d", sizes[0])
+	}
+
+	owner := fmt.Sprintf("thread-append-bench-%d", time.Now().UnixNano())
+	defer db.Threads().DeleteMany(context.Background(), bson.M{"user_id": owner})
+
+	threadMail := models.ThreadMail{
+		From:    owner,
+		Subject: "thread append benchmark",
+		Content: strings.Repeat("x", threadAppendContentSize),
+		To:      []string{owner},
+		Type:    1,
+	}
+
+	fmt.Printf("\n=== Thread Append Benchmark (%d threads, up to %d mails) ===\n", len(sizes), sizes[len(sizes)-1])
+
+	durations := make([][]time.Duration, len(sizes))
+	failed := make([]int, len(sizes))
+	lengths := make([]int, len(sizes))
+	result := &ThreadAppendResult{Threads: len(sizes)}
+	start := time.Now()
+
+	for grown := false; !grown; {
+		grown = true
+		for i, size := range sizes {
+			if lengths[i] >= size {
+				continue
+			}
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			grown = false
+
+			threadMail.MsgID = fmt.Sprintf("%s-%d-%d", owner, i, lengths[i])
+			opStart := time.Now()
+			err := h.AppendThread(ctx, owner, fmt.Sprintf("%s-%d", owner, i), threadMail)
+			latency := time.Since(opStart)
+
+			bucket := appendBucketIndex(sizes, lengths[i])
+			result.Appends++
+			if err != nil {
+				// Stop growing a thread whose upsert fails rather than retrying it forever
+				failed[bucket]++
+				lengths[i] = size
+				continue
+			}
+			durations[bucket] = append(durations[bucket], latency)
+			lengths[i]++
+		}
+	}
+	result.Duration = time.Since(start)
+
+	minSize := 0
+	for i, size := range sizes {
+		bucket := &AppendBucket{
+			MinSize:    minSize,
+			MaxSize:    size - 1,
+			Appends:    len(durations[i]) + failed[i],
+			Failed:     failed[i],
+			AvgLatency: averageDuration(durations[i]),
+			P50Latency: calculatePercentile(durations[i], 50),
+			P95Latency: calculatePercentile(durations[i], 95),
+			P99Latency: calculatePercentile(durations[i], 99),
+		}
+		minSize = size
+		if bucket.Appends > 0 {
+			result.Buckets = append(result.Buckets, bucket)
+		}
+	}
+
+	return result, nil
+}
+
+// appendBucketIndex returns the bucket for an append to an array of length
+// entries: the first size greater than length
+func appendBucketIndex(sizes []int, length int) int {
+	return sort.SearchInts(sizes, length+1)
+}
+
+// String renders the latency curve, one row per array-size bucket
+func (r *ThreadAppendResult) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-12s %8s %12s %12s %12s %12s %8s\n", "Array size", "Appends", "Avg", "P50", "P95", "P99", "Failed")
+	for _, bucket := range r.Buckets {
+		fmt.Fprintf(&b, "%-12s %8d %12s %12s %12s %12s %8d\n",
+			fmt.Sprintf("%d-%d", bucket.MinSize, bucket.MaxSize), bucket.Appends,
+			bucket.AvgLatency, bucket.P50Latency, bucket.P95Latency, bucket.P99Latency, bucket.Failed)
+	}
+	fmt.Fprintf(&b, "\n%d appends across %d threads in %s\n", r.Appends, r.Threads, r.Duration)
+	return b.String()
+}
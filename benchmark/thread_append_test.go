@@ -0,0 +1,68 @@
+package benchmark
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"mail-stress-test/handler"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// TestThreadAppendBuckets grows threads of 2 and 5 mails against a mock and
+// checks each append lands in the bucket for the array size it saw, and a
+// thread whose upsert fails stops growing
+func TestThreadAppendBuckets(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("round robin", func(mt *mtest.T) {
+		db := newMockDB(mt)
+		updated := mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1})
+		// Appends run thread 0 at 0, thread 1 at 0, thread 0 at 1, then
+		// thread 1 at 1, 2 and 3, where it fails
+		mt.AddMockResponses(updated, updated, updated, updated, updated,
+			mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 2, Name: "BadValue", Message: "document too large"}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 2}))
+
+		result, err := BenchmarkThreadAppend(context.Background(), db, handler.NewDBHandler(db), []int{5, 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Threads != 2 || result.Appends != 6 {
+			t.Errorf("%d threads, %d appends; want 2 and 6", result.Threads, result.Appends)
+		}
+		if len(result.Buckets) != 2 {
+			t.Fatalf("buckets = %+v, want 0-1 and 2-4", result.Buckets)
+		}
+		small, large := result.Buckets[0], result.Buckets[1]
+		if small.MinSize != 0 || small.MaxSize != 1 || small.Appends != 4 || small.Failed != 0 {
+			t.Errorf("first bucket = %+v, want sizes 0-1 with 4 appends", small)
+		}
+		if large.MinSize != 2 || large.MaxSize != 4 || large.Appends != 2 || large.Failed != 1 {
+			t.Errorf("second bucket = %+v, want sizes 2-4 with 2 appends, 1 failed", large)
+		}
+
+		events := mt.GetAllStartedEvents()
+		update := events[0].Command.Lookup("updates").Array().Index(0).Value().Document()
+		if !update.Lookup("upsert").Boolean() || update.Lookup("u", "$push", "mails").Type != bson.TypeEmbeddedDocument {
+			t.Errorf("append sent %s, want a $push upsert", update)
+		}
+		if last := events[len(events)-1]; last.CommandName != "delete" {
+			t.Errorf("last command = %s, want the benchmark threads deleted", last.CommandName)
+		}
+		if !strings.Contains(result.String(), "2-4") {
+			t.Errorf("String() = %q, want a 2-4 row", result.String())
+		}
+	})
+}
+
+func TestAppendBucketIndex(t *testing.T) {
+	sizes := []int{10, 100, 500}
+	for length, want := range map[int]int{0: 0, 9: 0, 10: 1, 99: 1, 100: 2, 499: 2} {
+		if got := appendBucketIndex(sizes, length); got != want {
+			t.Errorf("appendBucketIndex(%d) = %d, want %d", length, got, want)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package benchmark
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"mail-stress-test/handler"
+)
+
+// timeSeriesCompression keeps each window's digest small; per-window P95 does
+// not need the accuracy of the run-wide percentiles
+const timeSeriesCompression = 50
+
+// TimeSeriesPoint summarizes the requests completed in one interval of the run
+type TimeSeriesPoint struct {
+	Offset            time.Duration `json:"offset"` // start of the window since the run started
+	Requests          int64         `json:"requests"`
+	Errors            int64         `json:"errors"`
+	RequestsPerSecond float64       `json:"requests_per_second"`
+	P95Duration       time.Duration `json:"p95_duration"`
+
+	// Errors by failure mode; the rest of Errors are 4xx or unclassified
+	ConnectionErrors int64 `json:"connection_errors,omitempty"`
+	Timeouts         int64 `json:"timeouts,omitempty"`
+	ServerErrors     int64 `json:"server_errors,omitempty"`
+}
+
+// timeSeriesRecorder buckets completed requests into fixed windows
+type timeSeriesRecorder struct {
+	interval time.Duration
+	start    time.Time
+
+	mu      sync.Mutex
+	windows []*timeSeriesWindow
+}
+
+type timeSeriesWindow struct {
+	requests    int64
+	errors      int64
+	connections int64
+	timeouts    int64
+	server      int64
+	digest      *TDigest
+}
+
+func newTimeSeriesRecorder(start time.Time, interval time.Duration) *timeSeriesRecorder {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	return &timeSeriesRecorder{interval: interval, start: start}
+}
+
+// record adds a request that completed at the given time; err classifies
+// a failure by mode
+func (r *timeSeriesRecorder) record(completed time.Time, duration time.Duration, err error) {
+	idx := int(completed.Sub(r.start) / r.interval)
+	if idx < 0 {
+		idx = 0
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for len(r.windows) <= idx {
+		r.windows = append(r.windows, &timeSeriesWindow{digest: NewTDigest(timeSeriesCompression)})
+	}
+	window := r.windows[idx]
+	window.requests++
+	if err != nil {
+		window.errors++
+		switch handler.ClassifyError(err) {
+		case handler.ErrorKindConnection:
+			window.connections++
+		case handler.ErrorKindTimeout:
+			window.timeouts++
+		case handler.ErrorKindServer:
+			window.server++
+		}
+	}
+	window.digest.Add(float64(duration))
+}
+
+// points returns one point per window, including empty windows
+func (r *timeSeriesRecorder) points() []TimeSeriesPoint {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	points := make([]TimeSeriesPoint, len(r.windows))
+	for i, window := range r.windows {
+		points[i] = TimeSeriesPoint{
+			Offset:            time.Duration(i) * r.interval,
+			Requests:          window.requests,
+			Errors:            window.errors,
+			RequestsPerSecond: float64(window.requests) / r.interval.Seconds(),
+			P95Duration:       time.Duration(window.digest.Quantile(0.95)),
+			ConnectionErrors:  window.connections,
+			Timeouts:          window.timeouts,
+			ServerErrors:      window.server,
+		}
+	}
+	return points
+}
+
+// FailureModeShift marks the window where the dominant failure mode changed
+type FailureModeShift struct {
+	Offset time.Duration `json:"offset"`
+	Mode   string        `json:"mode"` // connection, timeout, server
+	Errors int64         `json:"errors"`
+}
+
+// dominantFailureMode returns the most frequent classified failure in the
+// window, or "" if it had none. Ties favor connection errors, then
+// timeouts, as the more severe symptom.
+func (p TimeSeriesPoint) dominantFailureMode() (string, int64) {
+	mode, count := "", int64(0)
+	for _, candidate := range []struct {
+		mode  string
+		count int64
+	}{
+		{handler.ErrorKindConnection, p.ConnectionErrors},
+		{handler.ErrorKindTimeout, p.Timeouts},
+		{handler.ErrorKindServer, p.ServerErrors},
+	} {
+		if candidate.count > count {
+			mode, count = candidate.mode, candidate.count
+		}
+	}
+	return mode, count
+}
+
+// FailureModeShifts walks the time series and records each window where
+// the dominant failure mode differs from the previous failing window, so a
+// degradation reads as e.g. timeout at 10s, then connection at 40s.
+// Windows without classified failures don't end the current mode.
+func FailureModeShifts(points []TimeSeriesPoint) []FailureModeShift {
+	var shifts []FailureModeShift
+	current := ""
+	for _, point := range points {
+		mode, count := point.dominantFailureMode()
+		if mode == "" || mode == current {
+			continue
+		}
+		shifts = append(shifts, FailureModeShift{Offset: point.Offset, Mode: mode, Errors: count})
+		current = mode
+	}
+	return shifts
+}
+
+// FormatFailureModeShifts renders shifts as "timeout@10s → connection@40s"
+func FormatFailureModeShifts(shifts []FailureModeShift) string {
+	parts := make([]string, len(shifts))
+	for i, shift := range shifts {
+		parts[i] = fmt.Sprintf("%s@%s", shift.Mode, shift.Offset)
+	}
+	return strings.Join(parts, " → ")
+}
@@ -0,0 +1,109 @@
+package benchmark
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"mail-stress-test/handler"
+	"mail-stress-test/models"
+)
+
+// TestTimeSeriesBuckets runs briefly with a short interval and checks there
+// is about one bucket per elapsed interval and the buckets add up to the
+// run's totals
+func TestTimeSeriesBuckets(t *testing.T) {
+	var calls int64
+	h := &fakeHandler{create: func(ctx context.Context, req *models.MailRequest) error {
+		time.Sleep(time.Millisecond)
+		if atomic.AddInt64(&calls, 1)%10 == 0 {
+			return errors.New("backend returned 500")
+		}
+		return nil
+	}}
+	st, cfg := newTestStressTest(t, h)
+	cfg.StressTest.Duration = 500 * time.Millisecond
+	cfg.StressTest.TimeSeriesInterval = 100 * time.Millisecond
+
+	result, err := st.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := float64(result.TotalDuration) / float64(cfg.StressTest.TimeSeriesInterval)
+	if got := float64(len(result.TimeSeries)); math.Abs(got-want) > 1 {
+		t.Errorf("%d buckets over %s, want about %.1f", len(result.TimeSeries), result.TotalDuration, want)
+	}
+
+	var requests, failed int64
+	for i, point := range result.TimeSeries {
+		if want := time.Duration(i) * cfg.StressTest.TimeSeriesInterval; point.Offset != want {
+			t.Errorf("bucket %d offset = %s, want %s", i, point.Offset, want)
+		}
+		requests += point.Requests
+		failed += point.Errors
+	}
+	if requests != result.TotalRequests || failed != result.FailedRequests {
+		t.Errorf("buckets total %d requests, %d errors; run reported %d, %d",
+			requests, failed, result.TotalRequests, result.FailedRequests)
+	}
+	if failed == 0 {
+		t.Error("no errors recorded in the time series")
+	}
+}
+
+// TestFailureModeTransition feeds a backend degrading from timeouts to
+// refused connections to 5xx, one phase per interval, and checks each
+// window counts its failures by mode and the shifts read in that order
+func TestFailureModeTransition(t *testing.T) {
+	start := time.Unix(0, 0)
+	recorder := newTimeSeriesRecorder(start, 10*time.Second)
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	timeout := fmt.Errorf("list timed out after 1s: %w", context.DeadlineExceeded)
+	unavailable := &handler.StatusError{StatusCode: 503}
+
+	record := func(window, n int, err error) {
+		for i := 0; i < n; i++ {
+			recorder.record(start.Add(time.Duration(window)*10*time.Second+time.Duration(i)*time.Millisecond), time.Millisecond, err)
+		}
+	}
+	record(0, 10, nil)
+	record(1, 4, timeout)
+	record(1, 1, refused)
+	record(2, 6, refused)
+	record(2, 2, timeout)
+	// window 3 has only successes and keeps the connection mode
+	record(3, 5, nil)
+	record(4, 3, unavailable)
+	record(4, 1, errors.New("unclassified"))
+
+	points := recorder.points()
+	want := []struct{ connections, timeouts, server, errors int64 }{
+		{0, 0, 0, 0}, {1, 4, 0, 5}, {6, 2, 0, 8}, {0, 0, 0, 0}, {0, 0, 3, 4},
+	}
+	if len(points) != len(want) {
+		t.Fatalf("%d windows, want %d", len(points), len(want))
+	}
+	for i, w := range want {
+		p := points[i]
+		if p.ConnectionErrors != w.connections || p.Timeouts != w.timeouts || p.ServerErrors != w.server || p.Errors != w.errors {
+			t.Errorf("window %d: %d connection, %d timeout, %d server of %d errors; want %d, %d, %d of %d",
+				i, p.ConnectionErrors, p.Timeouts, p.ServerErrors, p.Errors, w.connections, w.timeouts, w.server, w.errors)
+		}
+	}
+
+	shifts := FailureModeShifts(points)
+	if got, want := FormatFailureModeShifts(shifts), "timeout@10s → connection@20s → server@40s"; got != want {
+		t.Errorf("shifts = %q, want %q", got, want)
+	}
+	if len(shifts) == 3 && shifts[1].Errors != 6 {
+		t.Errorf("connection shift counts %d errors, want 6", shifts[1].Errors)
+	}
+}
@@ -0,0 +1,78 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"mail-stress-test/generator"
+	"mail-stress-test/handler"
+	"mail-stress-test/models"
+)
+
+// TombstoneComparison compares list/search queries without a deletedAt
+// filter against the same queries excluding soft-deleted mails
+type TombstoneComparison struct {
+	Queries     int         `json:"queries"`
+	Unfiltered  *ViewResult `json:"unfiltered"`
+	Filtered    *ViewResult `json:"filtered"`
+	OverheadPct float64     `json:"overhead_percent"` // added average latency of the filter
+}
+
+// CompareTombstoneFilter replays n list/search queries on a single worker,
+// each through a DB handler including deleted mails and one excluding them.
+// The two passes alternate which goes first per query, so neither always
+// runs against caches the other just warmed.
+func CompareTombstoneFilter(ctx context.Context, gen *generator.DataGenerator, unfiltered, filtered *handler.DBHandler, n int) (*TombstoneComparison, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("tombstone comparison needs at least one query")
+	}
+	unfiltered.SetIncludeDeleted(true)
+	filtered.SetIncludeDeleted(false)
+
+	queries := make([]viewQuery, n)
+	for i := range queries {
+		if rand.Intn(2) == 0 {
+			queries[i] = viewQuery{list: gen.GenerateListMailsRequest()}
+		} else {
+			queries[i] = viewQuery{search: gen.GenerateSearchMailsRequest()}
+		}
+	}
+
+	fmt.Printf("\n=== Tombstone Filter Overhead (%d queries) ===\n", n)
+	unfilteredStats, filteredStats := newViewStats("unfiltered", n), newViewStats("filtered", n)
+	for i, q := range queries {
+		if ctx.Err() != nil {
+			break
+		}
+		if i%2 == 0 {
+			unfilteredStats.run(ctx, unfiltered, models.MailViewFull, q)
+			filteredStats.run(ctx, filtered, models.MailViewFull, q)
+		} else {
+			filteredStats.run(ctx, filtered, models.MailViewFull, q)
+			unfilteredStats.run(ctx, unfiltered, models.MailViewFull, q)
+		}
+	}
+	comparison := &TombstoneComparison{
+		Queries:    n,
+		Unfiltered: unfilteredStats.finish(),
+		Filtered:   filteredStats.finish(),
+	}
+	if comparison.Unfiltered.AvgLatency > 0 {
+		comparison.OverheadPct = float64(comparison.Filtered.AvgLatency-comparison.Unfiltered.AvgLatency) / float64(comparison.Unfiltered.AvgLatency) * 100
+	}
+
+	return comparison, nil
+}
+
+// String renders the comparison as a side-by-side table
+func (c *TombstoneComparison) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-10s %12s %12s %10s %8s\n", "Filter", "Avg", "P95", "Results", "Failed")
+	for _, r := range []*ViewResult{c.Unfiltered, c.Filtered} {
+		fmt.Fprintf(&b, "%-10s %12s %12s %10.1f %8d\n", r.View, r.AvgLatency, r.P95Latency, r.AvgResults, r.Failed)
+	}
+	fmt.Fprintf(&b, "\nExcluding soft-deleted mails adds %.1f%% average latency\n", c.OverheadPct)
+	return b.String()
+}
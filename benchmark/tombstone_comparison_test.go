@@ -0,0 +1,57 @@
+package benchmark
+
+import (
+	"context"
+	"testing"
+
+	"mail-stress-test/database"
+	"mail-stress-test/generator"
+	"mail-stress-test/handler"
+
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// TestTombstonePassesAlternate runs the comparison against a mock and checks
+// both passes see every query, and which pass goes first alternates query by
+// query rather than one pass always running on caches the other warmed
+func TestTombstonePassesAlternate(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("interleaved", func(mt *mtest.T) {
+		const n = 6
+		db := newMockDB(mt)
+		for i := 0; i < 2*n; i++ {
+			mt.AddMockResponses(mtest.CreateCursorResponse(0, mt.DB.Name()+"."+database.DefaultMailsCollection, mtest.FirstBatch))
+		}
+		gen, err := generator.NewDataGenerator([]string{"user-1", "user-2"})
+		if err != nil {
+			mt.Fatal(err)
+		}
+
+		comparison, err := CompareTombstoneFilter(context.Background(), gen, handler.NewDBHandler(db), handler.NewDBHandler(db), n)
+		if err != nil {
+			mt.Fatal(err)
+		}
+		if comparison.Unfiltered.Queries != n || comparison.Filtered.Queries != n ||
+			comparison.Unfiltered.Failed != 0 || comparison.Filtered.Failed != 0 {
+			mt.Errorf("unfiltered %+v, filtered %+v; want %d successful queries each", comparison.Unfiltered, comparison.Filtered, n)
+		}
+
+		events := mt.GetAllStartedEvents()
+		if len(events) != 2*n {
+			mt.Fatalf("sent %d commands, want %d", len(events), 2*n)
+		}
+		for i := 0; i < n; i++ {
+			first, second := events[2*i].Command.Lookup("filter"), events[2*i+1].Command.Lookup("filter")
+			_, firstErr := first.Document().LookupErr("deletedAt")
+			_, secondErr := second.Document().LookupErr("deletedAt")
+			firstFiltered, secondFiltered := firstErr == nil, secondErr == nil
+			if firstFiltered == secondFiltered {
+				mt.Errorf("query %d ran %s and %s, want one pass of each", i, first, second)
+			}
+			if want := i%2 == 1; firstFiltered != want {
+				mt.Errorf("query %d: filtered pass first = %v, want %v", i, firstFiltered, want)
+			}
+		}
+	})
+}
@@ -0,0 +1,147 @@
+package benchmark
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"mail-stress-test/database"
+	"mail-stress-test/generator"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// defaultValidationWrites is the insert count when unconfigured
+const defaultValidationWrites = 2000
+
+// ValidationRun is the insert latency into one scratch collection
+type ValidationRun struct {
+	Collection string        `json:"collection"`
+	Failed     int           `json:"failed"`
+	AvgLatency time.Duration `json:"avg_latency"`
+	P95Latency time.Duration `json:"p95_latency"`
+	P99Latency time.Duration `json:"p99_latency"`
+}
+
+// ValidationResult compares inserts with and without the mail JSON Schema
+// validator, and checks the validator actually rejects a bad document
+type ValidationResult struct {
+	Writes          int            `json:"writes"`
+	Plain           *ValidationRun `json:"plain"`
+	Validated       *ValidationRun `json:"validated"`
+	Overhead        time.Duration  `json:"overhead"`         // validated avg - plain avg
+	OverheadPercent float64        `json:"overhead_percent"` // relative to the plain avg
+	ValidatorActive bool           `json:"validator_active"` // validator found on the collection
+	InvalidRejected bool           `json:"invalid_rejected"` // a document missing required fields failed validation
+}
+
+// BenchmarkValidation inserts the same n mails into a plain scratch
+// collection and one created with database.MailSchema, alternating between
+// them so both see the same server conditions. Scratch collections are
+// dropped afterwards.
+func BenchmarkValidation(ctx context.Context, db *database.MongoDB, gen *generator.DataGenerator, n int) (*ValidationResult, error) {
+	if n <= 0 {
+		n = defaultValidationWrites
+	}
+
+	suffix := time.Now().UnixNano()
+	plainName := fmt.Sprintf("%s_validation_off_%d", db.MailsCollection, suffix)
+	validatedName := fmt.Sprintf("%s_validation_on_%d", db.MailsCollection, suffix)
+	if err := db.Database.CreateCollection(ctx, plainName); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", plainName, err)
+	}
+	plain := db.Database.Collection(plainName)
+	defer plain.Drop(context.Background())
+	if err := db.CreateValidatedCollection(ctx, validatedName); err != nil {
+		return nil, err
+	}
+	validated := db.Database.Collection(validatedName)
+	defer validated.Drop(context.Background())
+
+	result := &ValidationResult{
+		Writes:    n,
+		Plain:     &ValidationRun{Collection: plainName},
+		Validated: &ValidationRun{Collection: validatedName},
+	}
+	active, err := db.HasValidator(ctx, validatedName)
+	if err != nil {
+		return nil, err
+	}
+	result.ValidatorActive = active
+
+	fmt.Printf("\n=== Schema Validation Benchmark (%d writes per collection) ===\n", n)
+	var plainDurations, validatedDurations []time.Duration
+	for i := 0; i < n && ctx.Err() == nil; i++ {
+		mail := gen.GenerateMail()
+		if d, err := timeInsert(ctx, plain, mail); err == nil {
+			plainDurations = append(plainDurations, d)
+		} else {
+			result.Plain.Failed++
+		}
+		mail.ID = primitive.NewObjectID()
+		if d, err := timeInsert(ctx, validated, mail); err == nil {
+			validatedDurations = append(validatedDurations, d)
+		} else {
+			result.Validated.Failed++
+		}
+	}
+	for _, run := range []struct {
+		run       *ValidationRun
+		durations []time.Duration
+	}{{result.Plain, plainDurations}, {result.Validated, validatedDurations}} {
+		run.run.AvgLatency = averageDuration(run.durations)
+		run.run.P95Latency = calculatePercentile(run.durations, 95)
+		run.run.P99Latency = calculatePercentile(run.durations, 99)
+	}
+	result.Overhead = result.Validated.AvgLatency - result.Plain.AvgLatency
+	if result.Plain.AvgLatency > 0 {
+		result.OverheadPercent = float64(result.Overhead) / float64(result.Plain.AvgLatency) * 100
+	}
+
+	// A mail without subject, content or userId must be refused
+	_, err = validated.InsertOne(ctx, bson.M{"from": "validation-probe", "to": bson.A{"nobody"}, "type": 1})
+	result.InvalidRejected = isValidationError(err)
+	return result, nil
+}
+
+// timeInsert inserts doc into collection and returns the latency
+func timeInsert(ctx context.Context, collection *mongo.Collection, doc interface{}) (time.Duration, error) {
+	start := time.Now()
+	_, err := collection.InsertOne(ctx, doc)
+	return time.Since(start), err
+}
+
+// isValidationError reports whether err is a DocumentValidationFailure (121)
+func isValidationError(err error) bool {
+	var writeErr mongo.WriteException
+	if !errors.As(err, &writeErr) {
+		return false
+	}
+	for _, e := range writeErr.WriteErrors {
+		if e.Code == 121 {
+			return true
+		}
+	}
+	return false
+}
+
+// String renders both runs and the validation overhead
+func (r *ValidationResult) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-10s %12s %12s %12s %8s\n", "Validator", "Avg", "P95", "P99", "Failed")
+	for _, row := range []struct {
+		name string
+		run  *ValidationRun
+	}{{"off", r.Plain}, {"on", r.Validated}} {
+		fmt.Fprintf(&b, "%-10s %12s %12s %12s %8d\n", row.name, row.run.AvgLatency, row.run.P95Latency, row.run.P99Latency, row.run.Failed)
+	}
+	fmt.Fprintf(&b, "\nValidation adds %s per insert (%+.1f%%)\n", r.Overhead, r.OverheadPercent)
+	if !r.ValidatorActive || !r.InvalidRejected {
+		fmt.Fprintf(&b, "⚠️  Validator not enforced (found on collection: %t, invalid document rejected: %t)\n", r.ValidatorActive, r.InvalidRejected)
+	}
+	return b.String()
+}
@@ -0,0 +1,62 @@
+package benchmark
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"mail-stress-test/database"
+	"mail-stress-test/generator"
+	"mail-stress-test/internal/mongotest"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestIsValidationError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{errors.New("connection reset"), false},
+		{mongo.WriteException{WriteErrors: mongo.WriteErrors{{Code: 11000}}}, false},
+		{mongo.WriteException{WriteErrors: mongo.WriteErrors{{Code: 121}}}, true},
+	}
+	for _, tt := range tests {
+		if got := isValidationError(tt.err); got != tt.want {
+			t.Errorf("isValidationError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+// TestValidationBenchmarkIntegration checks generated mails pass the schema,
+// the validator is enforced and the scratch collections are dropped
+func TestValidationBenchmarkIntegration(t *testing.T) {
+	mdb := mongotest.Database(t)
+	db := &database.MongoDB{
+		Client:            mdb.Client(),
+		Database:          mdb,
+		MailsCollection:   database.DefaultMailsCollection,
+		ThreadsCollection: database.DefaultThreadsCollection,
+	}
+	gen, err := generator.NewDataGenerator([]string{"user-1", "user-2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	result, err := BenchmarkValidation(ctx, db, gen, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.ValidatorActive || !result.InvalidRejected {
+		t.Errorf("validator found %v, invalid document rejected %v; want both", result.ValidatorActive, result.InvalidRejected)
+	}
+	if result.Plain.Failed != 0 || result.Validated.Failed != 0 {
+		t.Errorf("%d plain and %d validated inserts failed, want generated mails to pass the schema", result.Plain.Failed, result.Validated.Failed)
+	}
+	if names, err := mdb.ListCollectionNames(ctx, bson.M{}); err != nil || len(names) != 0 {
+		t.Errorf("collections left behind: %v, %v", names, err)
+	}
+}
@@ -0,0 +1,194 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"mail-stress-test/database"
+	"mail-stress-test/models"
+	"mail-stress-test/search"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// defaultVerifySampleSize is the number of queries checked when none is configured
+const defaultVerifySampleSize = 20
+
+// maxVerifyExamples caps the discrepancies kept per strategy in the report
+const maxVerifyExamples = 5
+
+// VerificationResult compares a strategy's result sets against a ground-truth
+// linear scan for a sample of queries
+type VerificationResult struct {
+	SampledQueries int                    `json:"sampled_queries"`
+	Matched        int                    `json:"matched"`
+	Mismatched     int                    `json:"mismatched"`
+	Errors         int                    `json:"errors"`
+	MissingIDs     int                    `json:"missing_ids"`    // expected but not returned
+	UnexpectedIDs  int                    `json:"unexpected_ids"` // returned but not expected
+	Examples       []VerificationMismatch `json:"examples,omitempty"`
+}
+
+// VerificationMismatch describes one query whose results differed from the ground truth
+type VerificationMismatch struct {
+	UserID     string `json:"user_id"`
+	SearchTerm string `json:"search_term"`
+	Expected   int    `json:"expected"`
+	Returned   int    `json:"returned"`
+	Missing    int    `json:"missing"`
+	Unexpected int    `json:"unexpected"`
+}
+
+// Correct reports whether every sampled query matched the ground truth
+func (v *VerificationResult) Correct() bool {
+	return v.Mismatched == 0 && v.Errors == 0
+}
+
+// groundTruth scans all of the user's mails matching the participant
+// filters and keeps those whose subject and/or content, per the request
+// scope, contains the term case-insensitively. With subjectPrefix a
+// subject-only search keeps subjects starting with the term instead. With
+// includeArchive the archive collection is scanned too.
+func groundTruth(ctx context.Context, db *database.MongoDB, req *models.SearchMailsRequest, includeArchive, subjectPrefix bool) (map[string]bool, error) {
+	expected := make(map[string]bool)
+	if err := scanMatches(ctx, db.Mails(), req, subjectPrefix, expected); err != nil {
+		return nil, err
+	}
+	if includeArchive && db.ArchiveCollection != "" {
+		if err := scanMatches(ctx, db.Archive(), req, subjectPrefix, expected); err != nil {
+			return nil, err
+		}
+	}
+	return expected, nil
+}
+
+// scanMatches adds the IDs of collection's mails matching req to expected
+func scanMatches(ctx context.Context, collection *mongo.Collection, req *models.SearchMailsRequest, subjectPrefix bool, expected map[string]bool) error {
+	cursor, err := collection.Find(ctx, search.BaseFilter(req),
+		options.Find().SetProjection(bson.M{"_id": 1, "subject": 1, "content": 1}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	term := strings.ToLower(req.SearchTerm)
+	matchSubject := strings.Contains
+	if subjectPrefix && req.Scope == models.SearchScopeSubject {
+		matchSubject = strings.HasPrefix
+	}
+	for cursor.Next(ctx) {
+		var mail models.Mail
+		if err := cursor.Decode(&mail); err != nil {
+			return err
+		}
+		inSubject := req.Scope != models.SearchScopeContent && matchSubject(strings.ToLower(mail.Subject), term)
+		inContent := req.Scope != models.SearchScopeSubject && strings.Contains(strings.ToLower(mail.Content), term)
+		if inSubject || inContent {
+			expected[mail.ID.Hex()] = true
+		}
+	}
+	return cursor.Err()
+}
+
+// verifyStrategy replays queries through strategy and compares each result
+// set by mail ID with the ground truth. When the ground truth has more
+// matches than the request limit, any limit-sized subset is accepted.
+func verifyStrategy(ctx context.Context, db *database.MongoDB, strategy search.SearchStrategy, queries []*models.SearchMailsRequest) *VerificationResult {
+	result := &VerificationResult{}
+	archiver, ok := strategy.(search.ArchiveSearcher)
+	includeArchive := ok && archiver.SearchesArchive()
+	prefixer, ok := strategy.(search.SubjectPrefixMatcher)
+	subjectPrefix := ok && prefixer.MatchesSubjectPrefix()
+
+	for _, req := range queries {
+		result.SampledQueries++
+
+		expected, err := groundTruth(ctx, db, req, includeArchive, subjectPrefix)
+		if err != nil {
+			result.Errors++
+			continue
+		}
+		mails, err := strategy.SearchMails(ctx, db, req)
+		if err != nil {
+			result.Errors++
+			continue
+		}
+
+		returned := make(map[string]bool, len(mails))
+		for _, mail := range mails {
+			returned[mail.ID.Hex()] = true
+		}
+
+		mismatch := compareResultSets(expected, returned, req.Limit)
+		mismatch.UserID, mismatch.SearchTerm = req.UserID, req.SearchTerm
+		if mismatch.Missing == 0 && mismatch.Unexpected == 0 {
+			result.Matched++
+			continue
+		}
+
+		result.Mismatched++
+		result.MissingIDs += mismatch.Missing
+		result.UnexpectedIDs += mismatch.Unexpected
+		if len(result.Examples) < maxVerifyExamples {
+			result.Examples = append(result.Examples, mismatch)
+		}
+	}
+
+	return result
+}
+
+// compareResultSets counts returned IDs absent from expected and, allowing
+// for truncation at limit, expected IDs that should have been returned
+func compareResultSets(expected, returned map[string]bool, limit int) VerificationMismatch {
+	m := VerificationMismatch{Expected: len(expected), Returned: len(returned)}
+
+	for id := range returned {
+		if !expected[id] {
+			m.Unexpected++
+		}
+	}
+
+	want := len(expected)
+	if limit > 0 && want > limit {
+		want = limit
+	}
+	if found := len(returned) - m.Unexpected; found < want {
+		m.Missing = want - found
+	}
+
+	return m
+}
+
+// sampleQueries returns up to n queries spread evenly across the set
+func sampleQueries(queries []*models.SearchMailsRequest, n int) []*models.SearchMailsRequest {
+	if n <= 0 {
+		n = defaultVerifySampleSize
+	}
+	if n >= len(queries) {
+		return queries
+	}
+
+	sampled := make([]*models.SearchMailsRequest, n)
+	for i := range sampled {
+		sampled[i] = queries[i*len(queries)/n]
+	}
+	return sampled
+}
+
+// printVerification prints a one-line verdict plus the first discrepancies
+func printVerification(v *VerificationResult) {
+	if v.Correct() {
+		fmt.Printf("  🔎 Verified: %d/%d sampled queries match the ground truth\n", v.Matched, v.SampledQueries)
+		return
+	}
+
+	fmt.Printf("  ❗ Verification: %d/%d mismatched, %d errors (missing %d, unexpected %d mail IDs)\n",
+		v.Mismatched, v.SampledQueries, v.Errors, v.MissingIDs, v.UnexpectedIDs)
+	for _, ex := range v.Examples {
+		fmt.Printf("     - user %s, term %q: expected %d, returned %d (missing %d, unexpected %d)\n",
+			ex.UserID, ex.SearchTerm, ex.Expected, ex.Returned, ex.Missing, ex.Unexpected)
+	}
+}
@@ -0,0 +1,182 @@
+package benchmark
+
+import (
+	"context"
+	"testing"
+
+	"mail-stress-test/database"
+	"mail-stress-test/internal/mongotest"
+	"mail-stress-test/models"
+	"mail-stress-test/search"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// TestVerificationCatchesWrongStrategy scans a user's mails where two of
+// three contain the term, and checks a strategy returning those two is
+// verified while one returning the third is reported as a mismatch
+func TestVerificationCatchesWrongStrategy(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	invoice, overdue, lunch := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	scan := func(mt *mtest.T) bson.D {
+		return mtest.CreateCursorResponse(0, mt.DB.Name()+"."+database.DefaultMailsCollection, mtest.FirstBatch,
+			bson.D{{Key: "_id", Value: invoice}, {Key: "subject", Value: "Invoice March"}, {Key: "content", Value: "attached"}},
+			bson.D{{Key: "_id", Value: overdue}, {Key: "subject", Value: "Reminder"}, {Key: "content", Value: "your INVOICE is overdue"}},
+			bson.D{{Key: "_id", Value: lunch}, {Key: "subject", Value: "Lunch"}, {Key: "content", Value: "noon?"}})
+	}
+	req := &models.SearchMailsRequest{UserID: "user-1", SearchTerm: "invoice", Limit: 10}
+
+	mt.Run("correct strategy", func(mt *mtest.T) {
+		mt.AddMockResponses(scan(mt))
+		strategy := &recordingStrategy{name: "right", search: func(*models.SearchMailsRequest) ([]*models.Mail, error) {
+			return []*models.Mail{{ID: invoice}, {ID: overdue}}, nil
+		}}
+
+		v := verifyStrategy(context.Background(), newMockDB(mt), strategy, []*models.SearchMailsRequest{req})
+		if !v.Correct() || v.Matched != 1 {
+			t.Errorf("verification = %+v, want the query matched", v)
+		}
+	})
+
+	mt.Run("wrong strategy", func(mt *mtest.T) {
+		mt.AddMockResponses(scan(mt))
+		strategy := &recordingStrategy{name: "wrong", search: func(*models.SearchMailsRequest) ([]*models.Mail, error) {
+			return []*models.Mail{{ID: invoice}, {ID: lunch}}, nil
+		}}
+
+		v := verifyStrategy(context.Background(), newMockDB(mt), strategy, []*models.SearchMailsRequest{req})
+		if v.Correct() || v.Mismatched != 1 {
+			t.Fatalf("verification = %+v, want the query mismatched", v)
+		}
+		if v.MissingIDs != 1 || v.UnexpectedIDs != 1 {
+			t.Errorf("missing %d, unexpected %d; want 1 and 1", v.MissingIDs, v.UnexpectedIDs)
+		}
+		if len(v.Examples) != 1 || v.Examples[0].SearchTerm != "invoice" || v.Examples[0].Expected != 2 {
+			t.Errorf("examples = %+v, want the mismatched query", v.Examples)
+		}
+	})
+
+	mt.Run("scan error", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 2, Name: "BadValue", Message: "scan failed"}))
+		v := verifyStrategy(context.Background(), newMockDB(mt), &recordingStrategy{name: "any"}, []*models.SearchMailsRequest{req})
+		if v.Correct() || v.Errors != 1 {
+			t.Errorf("verification = %+v, want one error", v)
+		}
+	})
+}
+
+// prefixStrategy is a recordingStrategy declaring prefix subject matches,
+// like index_optimized
+type prefixStrategy struct {
+	*recordingStrategy
+}
+
+func (s prefixStrategy) MatchesSubjectPrefix() bool { return true }
+
+// TestVerificationMidSubjectTerm scans mails with the term at the start and
+// in the middle of the subject, and checks the ground truth keeps the
+// mid-subject hit when searching both fields, and drops it for a subject-only
+// search only from strategies declaring prefix matches
+func TestVerificationMidSubjectTerm(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	prefix, middle := primitive.NewObjectID(), primitive.NewObjectID()
+	scan := func(mt *mtest.T) bson.D {
+		return mtest.CreateCursorResponse(0, mt.DB.Name()+"."+database.DefaultMailsCollection, mtest.FirstBatch,
+			bson.D{{Key: "_id", Value: prefix}, {Key: "subject", Value: "Invoice March"}, {Key: "content", Value: "attached"}},
+			bson.D{{Key: "_id", Value: middle}, {Key: "subject", Value: "Overdue invoice reminder"}, {Key: "content", Value: "please pay"}},
+			bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "subject", Value: "Lunch"}, {Key: "content", Value: "noon?"}})
+	}
+	returning := func(ids ...primitive.ObjectID) *recordingStrategy {
+		return &recordingStrategy{name: "index_optimized", search: func(*models.SearchMailsRequest) ([]*models.Mail, error) {
+			mails := make([]*models.Mail, len(ids))
+			for i, id := range ids {
+				mails[i] = &models.Mail{ID: id}
+			}
+			return mails, nil
+		}}
+	}
+
+	tests := []struct {
+		name     string
+		scope    string
+		strategy search.SearchStrategy
+		correct  bool
+	}{
+		{"both fields match mid-subject", models.SearchScopeBoth, prefixStrategy{returning(prefix, middle)}, true},
+		{"both fields miss mid-subject", models.SearchScopeBoth, prefixStrategy{returning(prefix)}, false},
+		{"prefix subject scope", models.SearchScopeSubject, prefixStrategy{returning(prefix)}, true},
+		{"substring subject scope", models.SearchScopeSubject, returning(prefix), false},
+	}
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			mt.AddMockResponses(scan(mt))
+			req := &models.SearchMailsRequest{UserID: "user-1", SearchTerm: "invoice", Scope: tt.scope, Limit: 10}
+			v := verifyStrategy(context.Background(), newMockDB(mt), tt.strategy, []*models.SearchMailsRequest{req})
+			if v.Correct() != tt.correct || v.Errors != 0 {
+				t.Errorf("verification = %+v, want correct %v", v, tt.correct)
+			}
+		})
+	}
+}
+
+// TestVerifyIndexOptimizedIntegration verifies index_optimized against the
+// ground truth for a term in the middle of a subject, searching both fields
+// and the subject alone
+func TestVerifyIndexOptimizedIntegration(t *testing.T) {
+	mdb := mongotest.Database(t)
+	db := &database.MongoDB{
+		Client:            mdb.Client(),
+		Database:          mdb,
+		MailsCollection:   database.DefaultMailsCollection,
+		ThreadsCollection: database.DefaultThreadsCollection,
+	}
+	ctx := context.Background()
+	if _, err := db.Mails().InsertMany(ctx, []interface{}{
+		models.Mail{UserID: "user-1", Subject: "Invoice March", Content: "attached"},
+		models.Mail{UserID: "user-1", Subject: "Overdue INVOICE reminder", Content: "please pay"},
+		models.Mail{UserID: "user-1", Subject: "Lunch", Content: "noon?"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	strategy := search.NewIndexOptimizedStrategy("", 0)
+	if err := strategy.SetupDatabase(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, scope := range []string{models.SearchScopeBoth, models.SearchScopeSubject} {
+		req := &models.SearchMailsRequest{UserID: "user-1", SearchTerm: "invoice", Scope: scope, Limit: 10}
+		if v := verifyStrategy(ctx, db, strategy, []*models.SearchMailsRequest{req}); !v.Correct() {
+			t.Errorf("scope %q: verification = %+v, want every query matched", scope, v)
+		}
+	}
+}
+
+// TestCompareResultSetsTruncated checks a limit-sized subset of a larger
+// ground truth counts as complete
+func TestCompareResultSetsTruncated(t *testing.T) {
+	expected := map[string]bool{"a": true, "b": true, "c": true}
+	if m := compareResultSets(expected, map[string]bool{"a": true, "c": true}, 2); m.Missing != 0 || m.Unexpected != 0 {
+		t.Errorf("truncated subset: %+v, want no discrepancies", m)
+	}
+	if m := compareResultSets(expected, map[string]bool{"a": true}, 2); m.Missing != 1 {
+		t.Errorf("short result: missing %d, want 1", m.Missing)
+	}
+}
+
+func TestSampleQueries(t *testing.T) {
+	queries := make([]*models.SearchMailsRequest, 100)
+	for i := range queries {
+		queries[i] = &models.SearchMailsRequest{Limit: i}
+	}
+	sampled := sampleQueries(queries, 4)
+	for i, want := range []int{0, 25, 50, 75} {
+		if sampled[i].Limit != want {
+			t.Errorf("sample %d = query %d, want %d", i, sampled[i].Limit, want)
+		}
+	}
+	if got := len(sampleQueries(queries[:5], 0)); got != 5 {
+		t.Errorf("default sample of 5 queries has %d, want all 5", got)
+	}
+}
@@ -0,0 +1,111 @@
+package benchmark
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// defaultWorkerSampleInterval is how often the watchdog samples worker activity
+const defaultWorkerSampleInterval = 100 * time.Millisecond
+
+// rateShortfallThreshold is the fraction of the target rate below which the
+// run is reported as under-delivering
+const rateShortfallThreshold = 0.8
+
+// saturatedUtilization is the average utilization (percent) above which the
+// worker pool is considered the bottleneck
+const saturatedUtilization = 90.0
+
+// WorkerUtilization reports how busy the worker pool was and whether the
+// configured request rate was actually delivered
+type WorkerUtilization struct {
+	Workers        int     `json:"workers"`
+	Samples        int     `json:"samples"`
+	AvgActive      float64 `json:"avg_active"`
+	MaxActive      int64   `json:"max_active"`
+	Utilization    float64 `json:"utilization"` // percent of worker time spent executing operations
+	TargetRPS      float64 `json:"target_rps,omitempty"`
+	AchievedRPS    float64 `json:"achieved_rps"`
+	TargetAchieved bool    `json:"target_achieved"`
+	Warning        string  `json:"warning,omitempty"`
+}
+
+// workerWatchdog counts workers inside executeOperation and samples the
+// count periodically
+type workerWatchdog struct {
+	workers   int
+	active    int64
+	samples   int
+	activeSum int64
+	maxActive int64
+}
+
+func newWorkerWatchdog(workers int) *workerWatchdog {
+	return &workerWatchdog{workers: workers}
+}
+
+// begin marks a worker as executing an operation
+func (w *workerWatchdog) begin() {
+	atomic.AddInt64(&w.active, 1)
+}
+
+// end marks a worker as idle again
+func (w *workerWatchdog) end() {
+	atomic.AddInt64(&w.active, -1)
+}
+
+// run samples the active worker count every interval until stop is closed.
+// Only run touches the sample fields, so they are read after it returns.
+func (w *workerWatchdog) run(stop <-chan struct{}, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultWorkerSampleInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			active := atomic.LoadInt64(&w.active)
+			w.samples++
+			w.activeSum += active
+			if active > w.maxActive {
+				w.maxActive = active
+			}
+		}
+	}
+}
+
+// result summarizes utilization against the target rate (0 = unlimited)
+func (w *workerWatchdog) result(targetRPS, achievedRPS float64) *WorkerUtilization {
+	u := &WorkerUtilization{
+		Workers:        w.workers,
+		Samples:        w.samples,
+		MaxActive:      w.maxActive,
+		TargetRPS:      targetRPS,
+		AchievedRPS:    achievedRPS,
+		TargetAchieved: true,
+	}
+	if w.samples > 0 {
+		u.AvgActive = float64(w.activeSum) / float64(w.samples)
+	}
+	if w.workers > 0 {
+		u.Utilization = u.AvgActive / float64(w.workers) * 100
+	}
+
+	if targetRPS > 0 && achievedRPS < targetRPS*rateShortfallThreshold {
+		u.TargetAchieved = false
+		if u.Utilization >= saturatedUtilization {
+			u.Warning = fmt.Sprintf("achieved %.1f of %.0f target RPS with workers %.0f%% busy: the backend is too slow for this worker count, add concurrent_workers",
+				achievedRPS, targetRPS, u.Utilization)
+		} else {
+			u.Warning = fmt.Sprintf("achieved %.1f of %.0f target RPS with workers only %.0f%% busy: workers are starved by the client side (rate limiter, generator or tool CPU)",
+				achievedRPS, targetRPS, u.Utilization)
+		}
+	}
+
+	return u
+}
@@ -0,0 +1,96 @@
+package benchmark
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"mail-stress-test/models"
+)
+
+// TestWatchdogSaturatedWorkers runs two workers against a backend too slow
+// for the target rate and checks the shortfall is blamed on the worker count
+func TestWatchdogSaturatedWorkers(t *testing.T) {
+	h := &fakeHandler{create: func(ctx context.Context, req *models.MailRequest) error {
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	}}
+	st, cfg := newTestStressTest(t, h)
+	cfg.StressTest.ConcurrentWorkers = 2
+	cfg.StressTest.RequestRate = 1000
+	cfg.StressTest.WorkerSampleInterval = 5 * time.Millisecond
+
+	result, err := st.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := result.WorkerUtilization
+	if u == nil || u.Samples == 0 {
+		t.Fatalf("utilization = %+v, want sampled workers", u)
+	}
+	if u.TargetAchieved || u.Utilization < saturatedUtilization || u.MaxActive != 2 {
+		t.Errorf("utilization = %+v, want a missed target with both workers busy", u)
+	}
+	if !strings.Contains(u.Warning, "add concurrent_workers") {
+		t.Errorf("warning = %q, want advice to add workers", u.Warning)
+	}
+}
+
+// TestWatchdogTargetAchieved checks a rate the workers easily deliver is
+// reported as achieved without a warning
+func TestWatchdogTargetAchieved(t *testing.T) {
+	st, cfg := newTestStressTest(t, &fakeHandler{})
+	cfg.StressTest.RequestRate = 50
+	cfg.StressTest.Duration = 400 * time.Millisecond
+	cfg.StressTest.WorkerSampleInterval = 5 * time.Millisecond
+
+	result, err := st.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u := result.WorkerUtilization; !u.TargetAchieved || u.Warning != "" || u.TargetRPS != 50 {
+		t.Errorf("utilization = %+v, want the 50 RPS target achieved", u)
+	}
+}
+
+// TestWatchdogStarvedWorkers samples idle workers during a missed target and
+// checks the shortfall is blamed on the client side
+func TestWatchdogStarvedWorkers(t *testing.T) {
+	w := newWorkerWatchdog(10)
+	w.begin()
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.run(stop, time.Millisecond)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(stop)
+	<-done
+	w.end()
+
+	u := w.result(100, 30)
+	if u.TargetAchieved || u.MaxActive != 1 || u.Utilization != 10 {
+		t.Errorf("utilization = %+v, want one of ten workers busy and the target missed", u)
+	}
+	if !strings.Contains(u.Warning, "starved by the client side") {
+		t.Errorf("warning = %q, want the client side blamed", u.Warning)
+	}
+	if u := w.result(0, 30); !u.TargetAchieved || u.Warning != "" {
+		t.Errorf("unlimited rate: %+v, want no target to miss", u)
+	}
+}
+
+func TestTargetRPSWithBurst(t *testing.T) {
+	st, cfg := newTestStressTest(t, &fakeHandler{})
+	cfg.StressTest.RequestRate = 200
+	if got := st.targetRPS(); got != 200 {
+		t.Errorf("targetRPS = %.1f, want the request rate", got)
+	}
+	cfg.StressTest.Burst = testBurst
+	// 100ms of 500 rps and 200ms of 20 rps per 300ms period
+	if got, want := st.targetRPS(), 500.0/3+20*2.0/3; got < want-0.01 || got > want+0.01 {
+		t.Errorf("targetRPS = %.2f, want %.2f", got, want)
+	}
+}
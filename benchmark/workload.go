@@ -0,0 +1,155 @@
+package benchmark
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"mail-stress-test/config"
+	"mail-stress-test/generator"
+	"mail-stress-test/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// WorkloadOperation is one fully generated request of a golden workload.
+// Exactly one request field is set, matching Op.
+type WorkloadOperation struct {
+	Op     string                     `json:"op"` // create, list or search
+	Create *models.MailRequest        `json:"create,omitempty"`
+	List   *models.ListMailsRequest   `json:"list,omitempty"`
+	Search *models.SearchMailsRequest `json:"search,omitempty"`
+}
+
+// GenerateWorkload builds a sequence of n create/list/search operations
+// weighted like the stress test. Drafts, forwards, reply-all and soft
+// deletes target mails picked at run time, and counts and single-mail reads
+// have no request in the file format, so none of them can be pinned in a
+// workload: the draft, forward, reply-all, soft delete and count weights are
+// ignored and reads are never generated.
+func GenerateWorkload(weights config.Operations, gen *generator.DataGenerator, n int) ([]WorkloadOperation, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("workload needs at least one operation")
+	}
+	total := weights.CreateMailWeight + weights.ListMailWeight + weights.SearchWeight
+	if total <= 0 {
+		return nil, fmt.Errorf("workload needs a positive create, list or search weight")
+	}
+
+	ops := make([]WorkloadOperation, n)
+	for i := range ops {
+		r := rand.Intn(total)
+		switch {
+		case r < weights.CreateMailWeight:
+			ops[i] = WorkloadOperation{Op: "create", Create: generateCreateRequest(gen)}
+		case r < weights.CreateMailWeight+weights.ListMailWeight:
+			ops[i] = WorkloadOperation{Op: "list", List: gen.GenerateListMailsRequest()}
+		default:
+			ops[i] = WorkloadOperation{Op: "search", Search: gen.GenerateSearchMailsRequest()}
+		}
+	}
+	return ops, nil
+}
+
+// generateCreateRequest generates a new mail, 30% of the time as a reply
+func generateCreateRequest(gen *generator.DataGenerator) *models.MailRequest {
+	return gen.GenerateCreateMailRequest(randomReplyToID())
+}
+
+// randomReplyToID returns a reply target 30% of the time, or ""
+func randomReplyToID() string {
+	if rand.Float32() < 0.3 {
+		return primitive.NewObjectID().Hex() // In real scenario, you'd pick from existing mails
+	}
+	return ""
+}
+
+// WriteWorkload writes ops to path as JSON lines, one operation per line
+func WriteWorkload(path string, ops []WorkloadOperation) error {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create workload directory: %w", err)
+		}
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create workload file: %w", err)
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	encoder := json.NewEncoder(w)
+	for i := range ops {
+		if err := encoder.Encode(&ops[i]); err != nil {
+			return fmt.Errorf("failed to write workload operation %d: %w", i, err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write workload file: %w", err)
+	}
+	return file.Close()
+}
+
+// ReadWorkload loads a workload written by WriteWorkload
+func ReadWorkload(path string) ([]WorkloadOperation, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open workload file: %w", err)
+	}
+	defer file.Close()
+
+	var ops []WorkloadOperation
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var op WorkloadOperation
+		if err := json.Unmarshal(scanner.Bytes(), &op); err != nil {
+			return nil, fmt.Errorf("workload line %d: %w", line, err)
+		}
+		if err := op.validate(); err != nil {
+			return nil, fmt.Errorf("workload line %d: %w", line, err)
+		}
+		ops = append(ops, op)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read workload file: %w", err)
+	}
+	if len(ops) == 0 {
+		return nil, fmt.Errorf("workload file %s has no operations", path)
+	}
+	return ops, nil
+}
+
+// validate checks that the request matching Op is present
+func (op *WorkloadOperation) validate() error {
+	switch {
+	case op.Op == "create" && op.Create != nil,
+		op.Op == "list" && op.List != nil,
+		op.Op == "search" && op.Search != nil:
+		return nil
+	}
+	return fmt.Errorf("operation %q has no matching request", op.Op)
+}
+
+// workloadCursor hands out workload operations to workers in file order
+type workloadCursor struct {
+	ops  []WorkloadOperation
+	next int64
+}
+
+// take returns the next operation, or nil once the workload is exhausted
+func (c *workloadCursor) take() *WorkloadOperation {
+	i := atomic.AddInt64(&c.next, 1) - 1
+	if i >= int64(len(c.ops)) {
+		return nil
+	}
+	return &c.ops[i]
+}
@@ -0,0 +1,128 @@
+package benchmark
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"mail-stress-test/config"
+	"mail-stress-test/generator"
+	"mail-stress-test/models"
+)
+
+// recordingHandler keeps every request it receives as an encoded
+// WorkloadOperation, so replayed traffic can be compared with the file
+type recordingHandler struct {
+	t        *testing.T
+	requests [][]byte
+}
+
+func (h *recordingHandler) record(op WorkloadOperation) {
+	encoded, err := json.Marshal(op)
+	if err != nil {
+		h.t.Fatalf("encode %s request: %v", op.Op, err)
+	}
+	h.requests = append(h.requests, encoded)
+}
+
+func (h *recordingHandler) CreateMail(ctx context.Context, req *models.MailRequest) error {
+	h.record(WorkloadOperation{Op: "create", Create: req})
+	return nil
+}
+
+func (h *recordingHandler) ListMails(ctx context.Context, req *models.ListMailsRequest) ([]*models.Mail, error) {
+	h.record(WorkloadOperation{Op: "list", List: req})
+	return nil, nil
+}
+
+func (h *recordingHandler) SearchMails(ctx context.Context, req *models.SearchMailsRequest) ([]*models.Mail, error) {
+	h.record(WorkloadOperation{Op: "search", Search: req})
+	return nil, nil
+}
+
+func (h *recordingHandler) CountMails(ctx context.Context, userID string) (int64, error) {
+	h.t.Fatalf("replay sent a count for %s; counts are not part of a workload", userID)
+	return 0, nil
+}
+
+// TestWorkloadExportReplayRoundTrip exports a generated workload, reads it
+// back and replays it, checking the requests sent are byte-identical to the
+// generated ones and in the same order
+func TestWorkloadExportReplayRoundTrip(t *testing.T) {
+	userIDs, err := generator.GenerateUserIDs(generator.UserIDSchemeEmail, 20, "example.com", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gen, err := generator.NewDataGenerator(userIDs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Weights the workload can't pin must not leak into it
+	weights := config.Operations{
+		CreateMailWeight: 30, ListMailWeight: 40, SearchWeight: 30,
+		DraftWeight: 10, ForwardWeight: 10, ReplyAllWeight: 10, SoftDeleteWeight: 10, CountWeight: 10,
+	}
+	ops, err := GenerateWorkload(weights, gen, 300)
+	if err != nil {
+		t.Fatalf("GenerateWorkload: %v", err)
+	}
+	generated := make([][]byte, len(ops))
+	seen := make(map[string]int)
+	for i, op := range ops {
+		if err := op.validate(); err != nil {
+			t.Fatalf("generated operation %d: %v", i, err)
+		}
+		seen[op.Op]++
+		if generated[i], err = json.Marshal(op); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(seen) != 3 || seen["create"] == 0 || seen["list"] == 0 || seen["search"] == 0 {
+		t.Fatalf("workload should mix only create, list and search: %v", seen)
+	}
+
+	path := filepath.Join(t.TempDir(), "workloads", "golden.jsonl")
+	if err := WriteWorkload(path, ops); err != nil {
+		t.Fatalf("WriteWorkload: %v", err)
+	}
+	loaded, err := ReadWorkload(path)
+	if err != nil {
+		t.Fatalf("ReadWorkload: %v", err)
+	}
+	if len(loaded) != len(ops) {
+		t.Fatalf("read %d operations, wrote %d", len(loaded), len(ops))
+	}
+
+	// Re-exporting what was read must reproduce the file exactly
+	again := filepath.Join(t.TempDir(), "again.jsonl")
+	if err := WriteWorkload(again, loaded); err != nil {
+		t.Fatalf("WriteWorkload of the loaded workload: %v", err)
+	}
+	first, _ := os.ReadFile(path)
+	second, _ := os.ReadFile(again)
+	if !bytes.Equal(first, second) {
+		t.Errorf("re-exported workload differs from the original file")
+	}
+
+	recorder := &recordingHandler{t: t}
+	st := &StressTest{config: &config.Config{}, handler: recorder}
+	st.SetWorkload(loaded)
+	for op := st.workload.take(); op != nil; op = st.workload.take() {
+		if err := st.replayOperation(context.Background(), op); err != nil {
+			t.Fatalf("replay %s: %v", op.Op, err)
+		}
+	}
+
+	if len(recorder.requests) != len(generated) {
+		t.Fatalf("replay sent %d requests, generated %d", len(recorder.requests), len(generated))
+	}
+	for i := range generated {
+		if !bytes.Equal(recorder.requests[i], generated[i]) {
+			t.Fatalf("request %d differs after the round trip:\n generated %s\n replayed  %s", i, generated[i], recorder.requests[i])
+		}
+	}
+}
@@ -1,12 +1,15 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"path"
+	"strings"
 	"syscall"
 	"time"
 
@@ -15,37 +18,110 @@ import (
 	"mail-stress-test/database"
 	"mail-stress-test/generator"
 	"mail-stress-test/handler"
+	"mail-stress-test/models"
 	"mail-stress-test/monitoring"
 	"mail-stress-test/report"
-
-	"go.mongodb.org/mongo-driver/bson/primitive"
+	"mail-stress-test/search"
+	"mail-stress-test/tui"
 )
 
 func main() {
-	configPath := flag.String("config", "", "Path to config file")
+	configPath := flag.String("config", "", "Path to config file, or - to read it from stdin")
+	presetName := flag.String("preset", "", "Start from a built-in workload preset ("+strings.Join(config.PresetNames(), ", ")+"); -config values override it")
+	configFormat := flag.String("config-format", "", "Format of a config read from stdin: yaml or json (default: sniffed from content)")
 	seedData := flag.Bool("seed", false, "Seed initial data")
 	runStress := flag.Bool("stress", true, "Run stress test")
 	runBenchmark := flag.Bool("benchmark", true, "Run search benchmark")
 	useAPI := flag.Bool("use-api", false, "Use API handler instead of direct DB")
+	cpuProfile := flag.String("cpuprofile", "", "Write CPU profile of the tool to file")
+	memProfile := flag.String("memprofile", "", "Write heap profile of the tool to file at the end of the run")
+	traceFile := flag.String("trace", "", "Write execution trace of the tool to file")
+	runID := flag.String("run-id", "", "Run identifier used for the report directory (default: generated ULID)")
+	concurrentPhases := flag.Bool("concurrent-phases", false, "Run the stress test and search benchmark at the same time")
+	failFast := flag.Bool("fail-fast", false, "Abort the stress test on the first error and report partial results")
+	comparePaths := flag.Bool("compare-paths", false, "Replay the same operations through the API and DB handlers and compare latency")
+	verify := flag.Bool("verify", false, "Check a sample of each search strategy's results against a linear scan (slow)")
+	compareProjection := flag.Bool("compare-projection", false, "Benchmark list/search with full documents against the list-view projection")
+	purgeOlderThan := flag.Duration("purge-older-than", 0, "Hard-delete all users' mails older than this age (e.g. 720h) and report storage reclaimed")
+	compareStreaming := flag.Bool("compare-streaming", false, "Benchmark unlimited list/search results materialized into memory against streamed mail by mail")
+	compareTombstone := flag.Bool("compare-tombstone", false, "Benchmark list/search with and without the soft-delete tombstone filter")
+	comparePagination := flag.Bool("compare-pagination", false, "Benchmark deep inbox pages fetched by skip/limit against a page cursor")
+	compareSorts := flag.Bool("compare-sorts", false, "Benchmark list/search in each sort order and flag sorts no index covers")
+	compareCount := flag.Bool("compare-count", false, "Benchmark per-user mail counts through the userId index against a collection scan")
+	benchThreadAppend := flag.Bool("bench-thread-append", false, "Benchmark thread appends in isolation and report latency by mails array size")
+	benchValidation := flag.Bool("bench-validation", false, "Compare insert latency into a mails collection with and without the JSON Schema validator")
+	benchCompression := flag.Bool("bench-compression", false, "Compare write latency and on-disk size of the mails collection across block compressors")
+	liveTUI := flag.Bool("tui", false, "Show a live terminal dashboard during the stress test (ignored when stdout is not a terminal)")
+	exportWorkload := flag.String("export-workload", "", "Generate a golden workload of -workload-ops operations, write it to this file and exit")
+	workloadOps := flag.Int("workload-ops", 10000, "Number of operations generated by -export-workload")
+	workloadPath := flag.String("workload", "", "Replay the golden workload in this file verbatim instead of generating requests")
+	metricsPort := flag.Int("metrics-port", 0, "Expose the tool's own Prometheus metrics on this port during the run (0 = disabled)")
+	metricsHold := flag.Duration("metrics-hold", 0, "Keep serving -metrics-port this long after the run so the final snapshot can be scraped")
 	flag.Parse()
 
+	// Profile the load generator itself to rule out client-side saturation
+	stopProfiling, err := startProfiling(*cpuProfile, *memProfile, *traceFile)
+	if err != nil {
+		log.Fatalf("Failed to start profiling: %v", err)
+	}
+	stopProfilingOnExit = stopProfiling
+	defer stopProfiling()
+
+	runStart := time.Now()
+
 	// Load configuration
-	cfg, err := config.LoadConfig(*configPath)
+	var cfg *config.Config
+	if *presetName != "" {
+		cfg, err = config.LoadPreset(*presetName, *configPath, *configFormat)
+		if err == nil {
+			fmt.Printf("Preset: %s (%s)\n", *presetName, config.PresetDescription(*presetName))
+		}
+	} else if *configPath == config.StdinPath {
+		cfg, err = config.LoadConfigFrom(os.Stdin, *configFormat)
+	} else {
+		cfg, err = config.LoadConfig(*configPath)
+	}
 	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+		fatalf("Failed to load config: %v", err)
+	}
+
+	if *runID == "" {
+		*runID = report.NewRunID()
+	} else if err := report.ValidateRunID(*runID); err != nil {
+		fatalf("Invalid -run-id: %v", err)
 	}
+	runDir := cfg.Report.RunDir(*runID)
+	fmt.Printf("Run ID: %s (artifacts: %s)\n", *runID, runDir)
 
 	// Override use_api from flag if provided
 	if *useAPI {
 		cfg.StressTest.UseAPI = true
 	}
 
+	// The dashboard redraws the whole screen; realtime log lines would be
+	// wiped on the next frame anyway
+	if *liveTUI {
+		if tui.IsTerminal(os.Stdout) {
+			cfg.Monitoring.EnableRealtimeLog = false
+		} else {
+			fmt.Println("ℹ️  -tui ignored: stdout is not a terminal")
+			*liveTUI = false
+		}
+	}
+
 	// Connect to MongoDB
-	db, err := database.NewMongoDB(cfg.MongoDB.URI, cfg.MongoDB.Database, cfg.MongoDB.Timeout)
+	db, err := database.NewMongoDB(cfg.MongoDB.URI, cfg.MongoDB.Database, cfg.MongoDB.Timeout, cfg.MongoDB.AppName)
 	if err != nil {
-		log.Fatalf("Failed to connect to MongoDB: %v", err)
+		fatalf("Failed to connect to MongoDB: %v", err)
 	}
 	defer db.Close()
+	db.SetRunID(*runID)
+	fmt.Printf("🏷️  Queries tagged with comment %q\n", db.QueryComment("<operation>"))
+	db.SetCollectionNames(cfg.MongoDB.MailsCollection, cfg.MongoDB.ThreadsCollection)
+	db.SetArchiveCollection(cfg.MongoDB.ArchiveCollection)
+	if err := db.SetConsistency(cfg.MongoDB.ReadPreference, cfg.MongoDB.WriteConcern); err != nil {
+		fatalf("Invalid MongoDB consistency settings: %v", err)
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -59,62 +135,295 @@ func main() {
 		cancel()
 	}()
 
+	// Estimate clock skew against the server before any timestamps are written
+	var clockOffset *database.ClockOffset
+	if cfg.ClockSkew.Enabled {
+		clockOffset, err = db.EstimateClockOffset(ctx, cfg.ClockSkew.Samples)
+		if err != nil {
+			log.Printf("Warning: Failed to estimate clock skew: %v", err)
+		} else {
+			fmt.Printf("🕐 Clock offset (server - client): %s (round trip %s)\n", clockOffset.Offset, clockOffset.RoundTrip)
+			if cfg.ClockSkew.MaxOffset > 0 && clockOffset.Exceeds(cfg.ClockSkew.MaxOffset) {
+				fmt.Printf("⚠️  Clock offset exceeds %s; timestamps written by the tool are skewed relative to the server\n",
+					cfg.ClockSkew.MaxOffset)
+			}
+		}
+	}
+
+	// Probe server version and deployment type to gate unsupported features
+	capabilities, err := db.ProbeCapabilities(ctx)
+	if err != nil {
+		log.Printf("Warning: Failed to probe server capabilities: %v", err)
+	} else {
+		fmt.Printf("🧩 Server: %s\n", capabilities)
+	}
+
 	// Create indexes
 	fmt.Println("Creating database indexes...")
 	if err := db.CreateIndexes(ctx); err != nil {
-		log.Fatalf("Failed to create indexes: %v", err)
+		fatalf("Failed to create indexes: %v", err)
+	}
+	if cfg.MongoDB.SchemaValidation {
+		if err := db.EnableMailsValidation(ctx); err != nil {
+			fatalf("Failed to enable schema validation: %v", err)
+		}
+		fmt.Println("📐 Schema validation enabled on the mails collection")
 	}
 
 	// Prepare user IDs for data generator
-	userIDs := make([]string, cfg.StressTest.NumUsers)
-	for i := 0; i < cfg.StressTest.NumUsers; i++ {
-		userIDs[i] = primitive.NewObjectID().Hex()
+	userIDs, err := generator.GenerateUserIDs(cfg.StressTest.UserIDScheme, cfg.StressTest.NumUsers, cfg.StressTest.UserIDPrefix, cfg.StressTest.UserIDSeed)
+	if err != nil {
+		fatalf("Failed to generate user IDs: %v", err)
 	}
 
 	// Create data generator
-	dataGen := generator.NewDataGenerator(userIDs)
+	dataGen, err := generator.NewDataGenerator(userIDs)
+	if err != nil {
+		fatalf("Failed to create data generator: %v", err)
+	}
+	if err := dataGen.SetRecipientRange(cfg.StressTest.MinRecipients, cfg.StressTest.MaxRecipients); err != nil {
+		fatalf("Invalid recipient range: %v", err)
+	}
+	if err := dataGen.SetSentRatio(cfg.StressTest.SentRatio); err != nil {
+		fatalf("Invalid sent ratio: %v", err)
+	}
+	if delay := cfg.StressTest.SeedThreads.ReplyDelay; cfg.StressTest.SeedThreads.Enabled {
+		if err := dataGen.SetReplyDelay(delay.Distribution, delay.Min, delay.Mean, delay.Max); err != nil {
+			fatalf("Invalid seed_threads.reply_delay: %v", err)
+		}
+	}
+	dataGen.SetSearchTermMix(cfg.Benchmark.HotQueryRatio, cfg.Benchmark.HotTermCount)
+	if err := search.ValidateScope(cfg.Benchmark.SearchScope); err != nil {
+		fatalf("Invalid benchmark.search_scope: %v", err)
+	}
+	dataGen.SetSearchScope(cfg.Benchmark.SearchScope)
+	if err := search.ValidateSortBy(cfg.Benchmark.SortBy); err != nil {
+		fatalf("Invalid benchmark.sort_by: %v", err)
+	}
+	dataGen.SetSortBy(cfg.Benchmark.SortBy)
+	dataGen.SetParticipantFilterRatio(cfg.Benchmark.ParticipantFilterRatio)
+	dataGen.SetInboxSkew(cfg.StressTest.InboxSkew.Exponent, cfg.StressTest.InboxSkew.HeavyTargetRatio)
+	if cfg.StressTest.ListView {
+		// The list view computes its snippet in a find projection (4.4+)
+		if capabilities != nil && !capabilities.AtLeast(4, 4) {
+			capabilities.Skip("list_view", "aggregation expressions in find projections require MongoDB 4.4+, server is "+capabilities.Version)
+			fmt.Println("⏭️  list_view disabled: server is older than MongoDB 4.4")
+		} else {
+			dataGen.SetView(models.MailViewList)
+		}
+	}
+
+	// Golden workload: pin the exact request sequence for replay across
+	// backend versions, independent of the generator
+	if *exportWorkload != "" {
+		ops, err := benchmark.GenerateWorkload(cfg.StressTest.Operations, dataGen, *workloadOps)
+		if err != nil {
+			fatalf("Failed to generate workload: %v", err)
+		}
+		if err := benchmark.WriteWorkload(*exportWorkload, ops); err != nil {
+			fatalf("Failed to export workload: %v", err)
+		}
+		fmt.Printf("📼 Wrote %d operations to %s\n", len(ops), *exportWorkload)
+		return
+	}
+	var workload []benchmark.WorkloadOperation
+	if *workloadPath != "" {
+		workload, err = benchmark.ReadWorkload(*workloadPath)
+		if err != nil {
+			fatalf("Failed to load workload: %v", err)
+		}
+		fmt.Printf("📼 Replaying %d operations from %s\n", len(workload), *workloadPath)
+	}
 
 	// Create mail handler based on configuration
 	var mailHandler handler.MailHandler
 	if cfg.StressTest.UseAPI {
 		fmt.Println("Using API Handler (endpoint: " + cfg.StressTest.APIEndpoint + ")")
-		mailHandler = handler.NewAPIHandler(cfg.StressTest.APIEndpoint)
+		apiHandler, err := newAPIHandler(cfg)
+		if err != nil {
+			fatalf("Failed to configure API handler: %v", err)
+		}
+		apiHandler.SetCircuitBreaker(cfg.StressTest.CircuitBreakerThreshold, cfg.StressTest.CircuitBreakerCooldown)
+		apiHandler.SetMaxInFlight(cfg.StressTest.MaxInFlight, cfg.StressTest.InFlightFailFast)
+		mailHandler = apiHandler
 	} else {
 		fmt.Println("Using Direct DB Handler")
-		mailHandler = handler.NewDBHandler(db)
+		dbHandler := newDBHandler(cfg, db)
+		dbHandler.SetRetryBudget(cfg.MongoDB.RetryBudget)
+		dbHandler.SetSnippetLength(cfg.StressTest.SnippetLength)
+		dbHandler.SetIncludeDeleted(cfg.StressTest.IncludeDeleted)
+		dbHandler.SetMaxInFlight(cfg.StressTest.MaxInFlight, cfg.StressTest.InFlightFailFast)
+		if clockOffset != nil && cfg.ClockSkew.Correct {
+			dbHandler.SetClockOffset(clockOffset.Offset)
+		}
+		mailHandler = dbHandler
+	}
+
+	// Preflight checks for weights/handler/index consistency
+	if warnings := benchmark.Preflight(ctx, cfg, mailHandler, db); len(warnings) > 0 {
+		fmt.Println("\n⚠️  Preflight warnings:")
+		for _, warning := range warnings {
+			fmt.Printf("  - %s\n", warning)
+		}
 	}
 
 	// Seed data if requested
+	var threadDistribution *database.ThreadDistribution
+	var archiveResult *database.ArchiveResult
 	if *seedData {
 		fmt.Println("\n=== Seeding Test Data ===")
 		fmt.Printf("Creating mails for %d users...\n", cfg.StressTest.NumUsers)
 
-		// Seed some initial mails
-		for i := 0; i < cfg.StressTest.NumMailsPerUser; i++ {
-			req := dataGen.GenerateCreateMailRequest("")
-			if err := mailHandler.CreateMail(ctx, req); err != nil {
-				log.Printf("Warning: Failed to seed mail %d: %v", i, err)
-				continue
+		// Synthetic mails by default, or real mails sampled from another
+		// collection with their participants anonymized
+		numMails := cfg.StressTest.NumMailsPerUser
+		nextMail := func(int) *models.MailRequest { return dataGen.GenerateCreateMailRequest("") }
+		switch cfg.StressTest.SeedSource {
+		case "", config.SeedSourceSynthetic:
+		case config.SeedSourceSample:
+			sampled, err := db.SampleMails(ctx, cfg.StressTest.SeedSourceCollection, numMails)
+			if err != nil {
+				fatalf("Failed to sample seed mails: %v", err)
+			}
+			anonymizer := generator.NewUserAnonymizer(userIDs)
+			numMails = len(sampled)
+			nextMail = func(i int) *models.MailRequest { return anonymizer.MailRequest(sampled[i]) }
+			fmt.Printf("Sampled %d mails from %s\n", numMails, cfg.StressTest.SeedSourceCollection)
+		default:
+			fatalf("Unknown seed_source %q (expected %q or %q)", cfg.StressTest.SeedSource, config.SeedSourceSynthetic, config.SeedSourceSample)
+		}
+
+		// Threaded seeding: each reply answers the previous message of its
+		// thread, so it needs the ID that message was stored under
+		create := func(req *models.MailRequest) error { return mailHandler.CreateMail(ctx, req) }
+		if threads := cfg.StressTest.SeedThreads; threads.Enabled && cfg.StressTest.SeedSource != config.SeedSourceSample {
+			creator, ok := mailHandler.(handler.MailCreator)
+			if !ok {
+				fatalf("seed_threads needs a handler that returns created mail IDs")
+			}
+			var thread []*models.MailRequest
+			var previousID string
+			nextMail = func(int) *models.MailRequest {
+				if len(thread) == 0 {
+					thread, previousID = dataGen.GenerateThread(threads.MaxReplies), ""
+				}
+				req := thread[0]
+				thread = thread[1:]
+				req.ReplyTo = previousID
+				return req
+			}
+			create = func(req *models.MailRequest) error {
+				id, err := creator.CreateMailWithID(ctx, req)
+				if err != nil {
+					thread = nil // the rest of the thread has nothing to reply to
+				}
+				previousID = id
+				return err
 			}
+			fmt.Printf("🧵 Seeding threads of up to %d replies\n", threads.MaxReplies)
+		}
+
+		// Seed some initial mails
+		seededMails, seededDocuments := seedMails(numMails, cfg.StressTest.MaxSeedDocuments, nextMail, create)
+		fmt.Printf("Data seeding completed! (%d mail documents inserted)\n", seededDocuments)
+
+		// Record how the dataset was seeded so later runs can detect a
+		// config that doesn't match it
+		manifest := benchmark.CurrentManifest(cfg)
+		manifest.SeededMails = seededMails
+		manifest.SeededDocuments = seededDocuments
+		manifest.SeededAt = time.Now()
+		if err := db.RecordSeed(ctx, manifest); err != nil {
+			log.Printf("Warning: %v", err)
+		}
 
-			if i%100 == 0 && i > 0 {
-				fmt.Printf("  Created %d/%d mails\n", i, cfg.StressTest.NumMailsPerUser)
+		// Hot/cold split: age the oldest mails out into the archive
+		if cfg.MongoDB.ArchiveCollection != "" && cfg.MongoDB.ArchiveRatio > 0 {
+			archiveResult, err = db.ArchiveOldestMails(ctx, cfg.MongoDB.ArchiveRatio)
+			if err != nil {
+				fatalf("Failed to archive mails: %v", err)
 			}
+			fmt.Printf("🗄️  %s\n", archiveResult)
+		}
+
+		// Thread size drives thread-append and thread-read cost
+		distribution, err := db.ThreadDistribution(ctx)
+		if err != nil {
+			log.Printf("Warning: Failed to analyze thread distribution: %v", err)
+		} else {
+			threadDistribution = distribution
+			fmt.Printf("\n🧵 Mails per thread after seeding:\n%s", distribution)
+		}
+	}
+
+	// With a skewed dataset, list/search latency is reported by inbox size
+	var inboxSizes map[string]int64
+	var inboxDistribution *database.InboxDistribution
+	if cfg.StressTest.InboxSkew.Exponent > 0 {
+		inboxSizes, err = db.InboxSizes(ctx)
+		if err != nil {
+			log.Printf("Warning: Failed to count inbox sizes: %v", err)
+		} else {
+			inboxDistribution = database.SummarizeInboxSizes(inboxSizes)
+			fmt.Printf("\n📬 Inbox sizes (skew exponent %g, expected top-10%% share %.1f%%):\n  %s\n",
+				cfg.StressTest.InboxSkew.Exponent, dataGen.ExpectedHeavyShare()*100, inboxDistribution)
+		}
+	}
+
+	// Search terms the target user actually has, so searches return results
+	if cfg.Benchmark.UserTermBias {
+		subjects, err := db.UserSubjects(ctx, userIDs, 100) // 100 distinct subjects per user is plenty to sample from
+		if err != nil {
+			log.Printf("Warning: Failed to load user subjects, search terms stay random: %v", err)
+		} else {
+			dataGen.SetUserTerms(subjects, cfg.Benchmark.MissQueryRatio)
+			fmt.Printf("🎯 Search terms drawn from %d users' own subjects (%.0f%% intentional misses)\n",
+				len(subjects), cfg.Benchmark.MissQueryRatio*100)
 		}
-		fmt.Println("Data seeding completed!")
+	}
+
+	// Hard-delete old mails to measure purge cost and reclaimed storage
+	var purgeResult *database.PurgeResult
+	if *purgeOlderThan > 0 {
+		fmt.Println("\n=== Purging Old Mails ===")
+		purgeResult, err = db.PurgeMailsBefore(ctx, time.Now().Add(-*purgeOlderThan))
+		if err != nil {
+			fatalf("Purge failed: %v", err)
+		}
+		fmt.Printf("🗑️  %s\n", purgeResult)
 	}
 
 	var stressResult *benchmark.StressTestResult
 	var searchResults map[string]*benchmark.SearchBenchmarkResult
 	var monitoringReport *monitoring.MonitoringReport
+	var liveMetrics *benchmark.LiveMetrics
+	var pathComparison *benchmark.PathComparison
+	var projectionComparison *benchmark.ProjectionComparison
+	var threadAppend *benchmark.ThreadAppendResult
+	var compression *benchmark.CompressionResult
+	var streamComparison *benchmark.StreamComparison
+	var validation *benchmark.ValidationResult
+	var countComparison *benchmark.CountComparison
+	var paginationComparison *benchmark.PaginationComparison
+	var tombstoneComparison *benchmark.TombstoneComparison
+	var sortComparison *benchmark.SortComparison
 
 	// Setup monitoring if enabled
 	var monitoringMgr *monitoring.MonitoringManager
 	if cfg.Monitoring.Enabled {
 		fmt.Println("\n=== Setting up Monitoring ===")
+		prometheusAuth := monitoring.PrometheusAuthConfig{
+			BearerToken:        cfg.Monitoring.BearerToken,
+			BasicAuthUser:      cfg.Monitoring.BasicAuthUser,
+			BasicAuthPass:      cfg.Monitoring.BasicAuthPass,
+			Headers:            cfg.Monitoring.Headers,
+			InsecureSkipVerify: cfg.Monitoring.InsecureSkipVerify,
+		}
 		monitoringConfig := monitoring.MonitoringManagerConfig{
-			EnablePrometheus:    cfg.Monitoring.PrometheusURL != "",
+			EnablePrometheus:    cfg.Monitoring.PrometheusURL != "" || len(cfg.Monitoring.PrometheusTargets) > 0,
 			PrometheusURL:       cfg.Monitoring.PrometheusURL,
+			PrometheusAuth:      prometheusAuth,
+			PrometheusTargets:   buildPrometheusTargets(cfg.Monitoring.PrometheusTargets, prometheusAuth),
 			EnableSystemMonitor: cfg.Monitoring.EnableSystemMonitor,
 			SystemConfig: monitoring.MonitoringConfig{
 				TargetHost:     cfg.Monitoring.TargetHost,
@@ -123,8 +432,12 @@ func main() {
 				ScrapeInterval: cfg.Monitoring.ScrapeInterval,
 			},
 			ScrapeInterval:    cfg.Monitoring.ScrapeInterval,
-			OutputDir:         cfg.Report.OutputDir,
+			ScrapeJitter:      cfg.Monitoring.ScrapeJitter,
+			OutputDir:         runDir,
 			EnableRealtimeLog: cfg.Monitoring.EnableRealtimeLog,
+			RunID:             *runID,
+			Compress:          cfg.Report.CompressReports,
+			ExportExposition:  cfg.Monitoring.ExportExposition,
 		}
 		monitoringMgr = monitoring.NewMonitoringManager(monitoringConfig)
 
@@ -136,81 +449,487 @@ func main() {
 		time.Sleep(2 * time.Second)
 	}
 
-	// Run stress test
-	if *runStress {
+	// Profile slow and unindexed queries while the phases run
+	var profiler *database.Profiler
+	if cfg.MongoDB.Profiler.Enabled {
+		profiler, err = db.StartProfiler(ctx, cfg.MongoDB.Profiler.SlowMs)
+		if err != nil {
+			log.Printf("Warning: Failed to enable the database profiler: %v", err)
+		} else {
+			fmt.Printf("🔬 Database profiler enabled (slowms=%d)\n", cfg.MongoDB.Profiler.SlowMs)
+			restoreProfilerOnExit = func() { restoreProfiler(profiler) }
+			defer restoreProfiler(profiler)
+		}
+	}
+
+	// Run the stress test and search benchmark; each phase keeps its own
+	// result so metrics stay isolated when they run concurrently
+	runStressPhase := func(ctx context.Context) error {
 		fmt.Println("\n=== Running Stress Test ===")
 		stressTest := benchmark.NewStressTest(cfg, dataGen, mailHandler)
-		stressResult, err = stressTest.Run(ctx)
+		stressTest.SetFailFast(*failFast)
+		if workload != nil {
+			stressTest.SetWorkload(workload)
+		}
+		if inboxSizes != nil {
+			stressTest.SetInboxSizes(inboxSizes)
+		}
+		if *metricsPort > 0 || *liveTUI {
+			liveMetrics = benchmark.NewLiveMetrics()
+			stressTest.SetLiveMetrics(liveMetrics)
+		}
+		if *metricsPort > 0 {
+			addr, err := benchmark.StartMetricsServer(ctx, *metricsPort, liveMetrics)
+			if err != nil {
+				fatalf("Failed to start metrics server: %v", err)
+			}
+			fmt.Printf("📡 Serving stress tool metrics on http://%s/metrics\n", addr)
+		}
+		stopDashboard := func() {}
+		if *liveTUI {
+			stopDashboard = tui.NewDashboard(liveMetrics, monitoringMgr, os.Stdout).Start(ctx, time.Second)
+		}
+		result, err := stressTest.Run(ctx)
+		stopDashboard()
 		if err != nil {
-			log.Fatalf("Stress test failed: %v", err)
+			return fmt.Errorf("stress test failed: %w", err)
 		}
+		stressResult = result
+		printStressResult(cfg, result)
+		return nil
+	}
+	runSearchPhase := func(ctx context.Context) error {
+		searchBench := benchmark.NewSearchBenchmark(cfg, db, dataGen)
+		searchBench.SetVerify(*verify)
+		searchBench.SetCapabilities(capabilities)
+		results, err := searchBench.Run(ctx)
+		if err != nil {
+			return fmt.Errorf("search benchmark failed: %w", err)
+		}
+		searchResults = results
+
+		// Print comparison report
+		fmt.Println(searchBench.GenerateComparisonReport(results))
+		return nil
+	}
 
-		fmt.Printf("\nStress Test Results:\n")
-		fmt.Printf("  Total Requests: %d\n", stressResult.TotalRequests)
-		if stressResult.TotalRequests > 0 {
-			fmt.Printf("  Success: %d (%.2f%%)\n", stressResult.SuccessRequests,
-				float64(stressResult.SuccessRequests)/float64(stressResult.TotalRequests)*100)
+	var stressPhase, searchPhase benchmark.Phase
+	if *runStress {
+		stressPhase = runStressPhase
+	}
+	if *runBenchmark {
+		searchPhase = runSearchPhase
+	}
+	concurrent := *concurrentPhases && *runStress && *runBenchmark
+	if concurrent {
+		fmt.Println("\n=== Running Stress Test and Search Benchmark Concurrently ===")
+	}
+	if err := benchmark.RunPhases(ctx, stressPhase, searchPhase, concurrent); err != nil {
+		fatalf("%v", err)
+	}
+
+	// Slowest and COLLSCAN operations issued by the phases
+	var profile *database.ProfileReport
+	if profiler != nil {
+		profile, err = profiler.Report(ctx, cfg.MongoDB.Profiler.Top)
+		if err != nil {
+			log.Printf("Warning: Failed to read the profiler: %v", err)
 		} else {
-			fmt.Printf("  Success: %d\n", stressResult.SuccessRequests)
+			fmt.Printf("\n%s\n", profile)
+		}
+		restoreProfiler(profiler)
+	}
+
+	// Compare the HTTP/JSON API path against direct BSON DB access
+	if *comparePaths {
+		dbHandler := newDBHandler(cfg, db)
+		apiHandler, err := newAPIHandler(cfg)
+		if err != nil {
+			fatalf("Failed to configure API handler: %v", err)
 		}
-		fmt.Printf("  Failed: %d (%.2f%%)\n", stressResult.FailedRequests, stressResult.ErrorRate)
-		fmt.Printf("  Avg Response Time: %s\n", stressResult.AvgResponseTime)
-		fmt.Printf("  Requests/Second: %.2f\n", stressResult.RequestsPerSecond)
 
-		// Print operation breakdown
-		fmt.Println("\n  Operation Breakdown:")
-		for op, stats := range stressResult.OperationStats {
-			fmt.Printf("    %s: Count=%d, Avg=%s, Errors=%d\n",
-				op, stats.Count, stats.AvgDuration, stats.Errors)
+		pathComparison, err = benchmark.ComparePaths(ctx, cfg, dataGen, dbHandler, apiHandler, cfg.Benchmark.PathComparisonOperations)
+		if err != nil {
+			fatalf("Path comparison failed: %v", err)
 		}
+		fmt.Println(pathComparison)
 	}
 
-	// Run search benchmark
-	if *runBenchmark {
-		searchBench := benchmark.NewSearchBenchmark(cfg, db, dataGen)
-		searchResults, err = searchBench.Run(ctx)
+	// Compare full-document fetches with the list-view projection
+	if *compareProjection {
+		projectionComparison, err = benchmark.CompareProjection(ctx, dataGen, mailHandler, cfg.Benchmark.ProjectionComparisonQueries)
+		if err != nil {
+			fatalf("Projection comparison failed: %v", err)
+		}
+		fmt.Println(projectionComparison)
+	}
+
+	// Whole result sets held in a slice against streamed one mail at a time
+	if *compareStreaming {
+		streamComparison, err = benchmark.CompareStreaming(ctx, db, dataGen, mailHandler, cfg.Benchmark.StreamComparisonQueries)
 		if err != nil {
-			log.Fatalf("Search benchmark failed: %v", err)
+			fatalf("Streaming comparison failed: %v", err)
 		}
+		fmt.Println(streamComparison)
+	}
 
-		// Print comparison report
-		comparisonReport := searchBench.GenerateComparisonReport(searchResults)
-		fmt.Println(comparisonReport)
+	// Measure the cost of excluding soft-deleted mails
+	if *compareTombstone {
+		tombstoneComparison, err = benchmark.CompareTombstoneFilter(ctx, dataGen,
+			handler.NewDBHandler(db), handler.NewDBHandler(db), cfg.Benchmark.TombstoneComparisonQueries)
+		if err != nil {
+			fatalf("Tombstone comparison failed: %v", err)
+		}
+		fmt.Println(tombstoneComparison)
+	}
+
+	// Index-served against in-memory sorts per result order
+	if *compareSorts {
+		sortComparison, err = benchmark.CompareSorts(ctx, dataGen, handler.NewDBHandler(db), cfg.Benchmark.SortComparisonQueries)
+		if err != nil {
+			fatalf("Sort comparison failed: %v", err)
+		}
+		fmt.Println(sortComparison)
+	}
+
+	// Covered-index count against a full collection scan
+	if *compareCount {
+		countComparison, err = benchmark.CompareCount(ctx, db, dataGen, cfg.Benchmark.CountComparisonQueries)
+		if err != nil {
+			fatalf("Count comparison failed: %v", err)
+		}
+		fmt.Println(countComparison)
+	}
+
+	// Skip/limit against cursor pagination at increasing page depth
+	if *comparePagination {
+		paginationComparison, err = benchmark.ComparePagination(ctx, db, cfg.Benchmark.PaginationPages,
+			cfg.Benchmark.PaginationPageSize, cfg.Benchmark.PaginationQueries)
+		if err != nil {
+			fatalf("Pagination comparison failed: %v", err)
+		}
+		fmt.Println(paginationComparison)
+	}
+
+	// Measure the thread $push/$inc upsert as the embedded array grows
+	if *benchThreadAppend {
+		dbHandler := newDBHandler(cfg, db)
+
+		threadAppend, err = benchmark.BenchmarkThreadAppend(ctx, db, dbHandler, cfg.Benchmark.ThreadAppendSizes)
+		if err != nil {
+			fatalf("Thread append benchmark failed: %v", err)
+		}
+		fmt.Println(threadAppend)
+	}
+
+	// Write latency and storage of large mail bodies per block compressor
+	if *benchCompression {
+		compression, err = benchmark.BenchmarkCompression(ctx, db, dataGen, cfg.Benchmark.Compressors,
+			cfg.Benchmark.CompressionWrites, cfg.Benchmark.CompressionContentSize)
+		if err != nil {
+			fatalf("Compression benchmark failed: %v", err)
+		}
+		fmt.Println(compression)
+	}
+
+	// Insert latency attributable to the mail JSON Schema validator
+	if *benchValidation {
+		validation, err = benchmark.BenchmarkValidation(ctx, db, dataGen, cfg.Benchmark.ValidationWrites)
+		if err != nil {
+			fatalf("Validation benchmark failed: %v", err)
+		}
+		fmt.Println(validation)
+	}
+
+	// Storage footprint after the run, including every strategy's indexes
+	storageStats, err := db.StorageStats(ctx)
+	if err != nil {
+		log.Printf("Warning: Failed to collect storage stats: %v", err)
+	} else {
+		fmt.Println("\n💾 Storage:")
+		for _, stats := range storageStats {
+			fmt.Printf("  %s\n", stats)
+		}
 	}
 
 	// Stop monitoring and get report
 	if monitoringMgr != nil {
+		if cfg.StressTest.Cooldown > 0 {
+			monitoringMgr.Cooldown(ctx, cfg.StressTest.Cooldown)
+		}
 		fmt.Println("\n=== Collecting Monitoring Results ===")
 		monitoringReport, err = monitoringMgr.StopMonitoring(ctx)
 		if err != nil {
 			log.Printf("Warning: Failed to stop monitoring: %v", err)
 		} else {
 			monitoringMgr.PrintSummary(monitoringReport)
+			if cfg.Monitoring.ExportExposition && liveMetrics != nil {
+				var exposition bytes.Buffer
+				monitoringReport.WriteExposition(&exposition)
+				liveMetrics.SetRunSnapshot(exposition.Bytes())
+			}
 		}
 	}
 
 	// Generate reports
-	if stressResult != nil || searchResults != nil {
+	if stressResult != nil || searchResults != nil || pathComparison != nil || projectionComparison != nil || threadAppend != nil || compression != nil || purgeResult != nil || tombstoneComparison != nil || countComparison != nil || paginationComparison != nil || validation != nil || streamComparison != nil || sortComparison != nil {
 		fmt.Println("\n=== Generating Reports ===")
-		reporter := report.NewReporter(cfg.Report.OutputDir)
+		sink, err := report.NewOutputSink(cfg.Report.Sink, runDir, *runID)
+		if err != nil {
+			fatalf("Failed to set up report sink: %v", err)
+		}
+		reporter := report.NewReporter(runDir, *runID, cfg)
+		reporter.SetSink(sink)
 
 		if err := reporter.GenerateReport(stressResult, searchResults); err != nil {
-			log.Fatalf("Failed to generate report: %v", err)
+			fatalf("Failed to generate report: %v", err)
 		}
 
-		if cfg.Report.GenerateChart {
-			chartGen := report.NewChartGenerator(cfg.Report.OutputDir)
+		// Consolidated report with stress, search and monitoring results
+		runReportPath, err := reporter.GenerateRunReport(&report.RunReport{
+			StartTime:            runStart,
+			EndTime:              time.Now(),
+			StressTestResult:     stressResult,
+			SearchBenchmark:      searchResults,
+			Monitoring:           monitoringReport,
+			PathComparison:       pathComparison,
+			ProjectionComparison: projectionComparison,
+			ThreadAppend:         threadAppend,
+			TombstoneComparison:  tombstoneComparison,
+			CountComparison:      countComparison,
+			SortComparison:       sortComparison,
+			PaginationComparison: paginationComparison,
+			Purge:                purgeResult,
+			ClockOffset:          clockOffset,
+			ThreadDistribution:   threadDistribution,
+			InboxDistribution:    inboxDistribution,
+			Archive:              archiveResult,
+			Storage:              storageStats,
+			Profile:              profile,
+			Compression:          compression,
+			Validation:           validation,
+			StreamComparison:     streamComparison,
+			ServerCapabilities:   capabilities,
+		})
+		if err != nil {
+			fatalf("Failed to generate run report: %v", err)
+		}
+		fmt.Printf("Run report: %s\n", runReportPath)
+
+		// Charts are built around the stress test results
+		if cfg.Report.GenerateChart && stressResult != nil {
+			chartGen := report.NewChartGenerator(runDir, *runID, cfg)
+			chartGen.SetSink(sink)
 			if err := chartGen.GenerateCharts(stressResult, searchResults); err != nil {
-				log.Fatalf("Failed to generate charts: %v", err)
+				fatalf("Failed to generate charts: %v", err)
 			}
 		}
 
-		fmt.Printf("Reports generated in: %s\n", cfg.Report.OutputDir)
+		if cfg.Report.Sink.Type == report.SinkS3 {
+			fmt.Printf("Reports uploaded to: s3://%s/%s\n", cfg.Report.Sink.Bucket, path.Join(cfg.Report.Sink.Prefix, *runID))
+		} else {
+			fmt.Printf("Reports generated in: %s\n", runDir)
+		}
 	}
 
 	fmt.Println("\n✅ Benchmark completed successfully!")
 
+	if liveMetrics != nil && *metricsPort > 0 && *metricsHold > 0 {
+		fmt.Printf("📡 Serving the final metrics snapshot for %s\n", *metricsHold)
+		select {
+		case <-time.After(*metricsHold):
+		case <-ctx.Done():
+		}
+	}
+
 	if monitoringReport != nil {
 		fmt.Println("\n💡 Tip: Check monitoring report for detailed performance insights!")
 	}
 }
+
+// printStressResult prints the stress test summary and operation breakdown
+func printStressResult(cfg *config.Config, result *benchmark.StressTestResult) {
+	if result.AbortError != "" {
+		fmt.Printf("\n🛑 Aborted on first error (fail-fast): %s\n", result.AbortError)
+		fmt.Println("   Results below are partial.")
+	}
+
+	fmt.Printf("\nStress Test Results:\n")
+	if result.WarmUpDuration > 0 {
+		fmt.Printf("  Warm-up: %s (excluded from results)\n", result.WarmUpDuration)
+	}
+	fmt.Printf("  Total Requests: %d\n", result.TotalRequests)
+	if result.InsufficientData != "" {
+		fmt.Printf("  ⚠️  Insufficient data: %s\n", result.InsufficientData)
+		if result.TotalRequests == 0 {
+			return
+		}
+	}
+	if result.TotalRequests > 0 {
+		fmt.Printf("  Success: %d (%.2f%%)\n", result.SuccessRequests,
+			float64(result.SuccessRequests)/float64(result.TotalRequests)*100)
+	} else {
+		fmt.Printf("  Success: %d\n", result.SuccessRequests)
+	}
+	fmt.Printf("  Failed: %d (%.2f%%)\n", result.FailedRequests, result.ErrorRate)
+	if result.SoftFailures > 0 {
+		fmt.Printf("  Soft Failures (success predicates): %d\n", result.SoftFailures)
+	}
+	fmt.Printf("  Avg Response Time: %s\n", result.AvgResponseTime)
+	if len(result.TailPercentiles) > 0 {
+		fmt.Printf("  Tail Response Time: %s, Max=%s\n", benchmark.FormatPercentiles(result.TailPercentiles), result.MaxResponseTime)
+	}
+	fmt.Printf("  Requests/Second: %.2f\n", result.RequestsPerSecond)
+	if result.CircuitOpens > 0 {
+		fmt.Printf("  Circuit Breaker: opened %d times, %d requests short-circuited\n",
+			result.CircuitOpens, result.CircuitOpenRejections)
+	}
+	if result.InFlight != nil {
+		fmt.Printf("  In-Flight Cap: %d (peak %d), %d waits totalling %s, %d rejected\n",
+			result.InFlight.Limit, result.InFlight.Peak, result.InFlight.Waits, result.InFlight.WaitTime, result.InFlight.Rejections)
+	}
+	if result.ShedLoad > 0 {
+		fmt.Printf("  Shed Load: %d operations dropped at the in-flight cap\n", result.ShedLoad)
+	}
+	if result.Retries > 0 || result.RetryBudgetDenied > 0 {
+		fmt.Printf("  Write Retries: %d (exhausted: %d), %.3f per success, %d refused by retry budget\n",
+			result.Retries, result.RetriesExhausted, result.RetriesPerSuccess, result.RetryBudgetDenied)
+	}
+	if result.DeadLetters > 0 || result.DeadLettersDropped > 0 {
+		fmt.Printf("  Dead Letters: %d written to %s (dropped: %d)\n",
+			result.DeadLetters, cfg.StressTest.DeadLetterPath, result.DeadLettersDropped)
+	}
+	if result.SteadyStateReached {
+		fmt.Printf("  Steady State: reached after %d windows\n", len(result.SteadyStateWindows))
+	}
+
+	if result.SLA != nil {
+		fmt.Printf("\n  %s\n", result.SLA)
+	}
+	if u := result.WorkerUtilization; u != nil {
+		fmt.Printf("  Worker Utilization: %.1f%% (avg %.1f/%d busy, max %d)\n", u.Utilization, u.AvgActive, u.Workers, u.MaxActive)
+		if u.Warning != "" {
+			fmt.Printf("  ⚠️  Target rate not reached: %s\n", u.Warning)
+		}
+	}
+	if m := result.SelfMonitor; m != nil {
+		fmt.Printf("  Load Generator: %s\n", m)
+		if m.Warning != "" {
+			fmt.Printf("  ⚠️  Possible leak in the load generator: %s\n", m.Warning)
+		}
+	}
+	if result.Burst != nil {
+		fmt.Printf("\n  Burst Pattern (%d rps baseline, %d rps burst, %d windows):\n",
+			result.Burst.BaselineRate, result.Burst.BurstRate, len(result.Burst.Windows))
+		for _, phase := range []struct {
+			name  string
+			stats *benchmark.BurstPhaseStats
+		}{{"Burst", result.Burst.Burst}, {"Baseline", result.Burst.Baseline}} {
+			fmt.Printf("    %s: %d requests (%.1f rps), Avg=%s, P95=%s, P99=%s, Errors=%d\n",
+				phase.name, phase.stats.Requests, phase.stats.RequestsPerSecond,
+				phase.stats.AvgResponseTime, phase.stats.P95ResponseTime, phase.stats.P99ResponseTime, phase.stats.Errors)
+		}
+	}
+	if result.StickyUsers != nil {
+		fmt.Printf("\n  Sticky Users: %s\n", result.StickyUsers)
+	}
+	if result.Scenario != nil {
+		fmt.Printf("\n  Scenario: %s\n", result.Scenario)
+	}
+	if result.Sessions != nil {
+		fmt.Printf("\n  Virtual User Sessions: %s\n", result.Sessions)
+	}
+	if result.Protocol != nil {
+		fmt.Printf("\n  HTTP Protocol: %s\n", result.Protocol)
+	}
+	if phases := result.LatencyPhases; phases != nil {
+		fmt.Printf("\n  Latency Phases: %s\n", phases.Overall)
+		for _, op := range phases.Operations() {
+			fmt.Printf("    %s: %s\n", op, phases.ByOperation[op])
+		}
+	}
+	if len(result.FailureModes) > 0 {
+		fmt.Printf("\n  Failure Modes Over Time: %s\n", benchmark.FormatFailureModeShifts(result.FailureModes))
+	}
+	if len(result.SlowRequests) > 0 {
+		fmt.Printf("\n  Slowest Requests:\n")
+		for _, slow := range result.SlowRequests {
+			fmt.Printf("    %s\n", slow)
+		}
+	}
+	if len(result.InboxSizeLatency) > 0 {
+		fmt.Printf("\n  Latency by Inbox Size:\n")
+		for _, bucket := range result.InboxSizeLatency {
+			fmt.Printf("    %s\n", bucket)
+		}
+	}
+
+	// Print operation breakdown
+	fmt.Println("\n  Operation Breakdown:")
+	for op, stats := range result.OperationStats {
+		fmt.Printf("    %s: Count=%d, Avg=%s, Errors=%d, Timeouts=%d, SoftErrors=%d\n",
+			op, stats.Count, stats.AvgDuration, stats.Errors, stats.Timeouts, stats.SoftErrors)
+	}
+}
+
+// restoreProfiler puts back the profiler level found before the run; it uses
+// its own context so it still runs after an interrupt cancelled the main one
+func restoreProfiler(profiler *database.Profiler) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := profiler.Restore(ctx); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// newDBHandler builds a DB handler with the configured thread upsert retries;
+// an unset max_thread_retries keeps the handler's default
+func newDBHandler(cfg *config.Config, db *database.MongoDB) *handler.DBHandler {
+	dbHandler := handler.NewDBHandler(db)
+	if retries := cfg.MongoDB.MaxThreadRetries; retries != nil {
+		dbHandler.SetMaxThreadRetries(*retries)
+	}
+	return dbHandler
+}
+
+// newAPIHandler builds an API handler with the configured timeouts and TLS settings
+func newAPIHandler(cfg *config.Config) (*handler.APIHandler, error) {
+	apiHandler := handler.NewAPIHandler(cfg.StressTest.APIEndpoint)
+	apiHandler.SetOperationTimeouts(cfg.StressTest.OperationTimeouts.ByOperation())
+	apiHandler.SetPhaseTracing(cfg.StressTest.TracePhases)
+
+	tlsCfg := cfg.StressTest.APITLS
+	if err := apiHandler.SetTLS(handler.TLSConfig{
+		CAFile:             tlsCfg.CAFile,
+		CertFile:           tlsCfg.CertFile,
+		KeyFile:            tlsCfg.KeyFile,
+		InsecureSkipVerify: tlsCfg.InsecureSkipVerify,
+	}); err != nil {
+		return nil, fmt.Errorf("invalid api_tls settings: %w", err)
+	}
+	if err := apiHandler.SetProtocol(cfg.StressTest.APIProtocol); err != nil {
+		return nil, fmt.Errorf("invalid api_protocol: %w", err)
+	}
+	return apiHandler, nil
+}
+
+// buildPrometheusTargets converts configured targets, inheriting the top-level
+// credentials when a target does not define its own
+func buildPrometheusTargets(targets []config.PrometheusTarget, defaultAuth monitoring.PrometheusAuthConfig) []monitoring.PrometheusTarget {
+	result := make([]monitoring.PrometheusTarget, 0, len(targets))
+	for _, target := range targets {
+		auth := defaultAuth
+		if target.BearerToken != "" || target.BasicAuthUser != "" {
+			auth.BearerToken = target.BearerToken
+			auth.BasicAuthUser = target.BasicAuthUser
+			auth.BasicAuthPass = target.BasicAuthPass
+		}
+
+		result = append(result, monitoring.PrometheusTarget{
+			Name: target.Name,
+			URL:  target.URL,
+			Auth: auth,
+		})
+	}
+	return result
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"sync"
+)
+
+// stopProfilingOnExit is called by fatalf before exiting, since os.Exit
+// skips main's deferred stop and would leave the profiles truncated
+var stopProfilingOnExit = func() {}
+
+// restoreProfilerOnExit is called by fatalf before exiting, since os.Exit
+// skips main's deferred restore and would leave the database profiler on
+var restoreProfilerOnExit = func() {}
+
+// fatalf runs the exit hooks, then logs and exits like log.Fatalf
+func fatalf(format string, args ...interface{}) {
+	runExitHooks()
+	log.Fatalf(format, args...)
+}
+
+// runExitHooks does the cleanup main's defers would: it puts the database
+// profiler back, then stops profiling the tool
+func runExitHooks() {
+	restoreProfilerOnExit()
+	stopProfilingOnExit()
+}
+
+// startProfiling starts CPU profiling and execution tracing for the tool itself
+// when the corresponding paths are set. The returned function stops them and
+// writes the heap profile; it must be called once the run is complete, and
+// later calls are no-ops.
+func startProfiling(cpuProfile, memProfile, traceFile string) (func(), error) {
+	var cpuFile, traceOut *os.File
+
+	if cpuProfile != "" {
+		f, err := os.Create(cpuProfile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create CPU profile: %w", err)
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to start CPU profile: %w", err)
+		}
+		cpuFile = f
+	}
+
+	if traceFile != "" {
+		f, err := os.Create(traceFile)
+		if err != nil {
+			stopCPUProfile(cpuFile)
+			return nil, fmt.Errorf("failed to create trace file: %w", err)
+		}
+		if err := trace.Start(f); err != nil {
+			f.Close()
+			stopCPUProfile(cpuFile)
+			return nil, fmt.Errorf("failed to start trace: %w", err)
+		}
+		traceOut = f
+	}
+
+	stop := func() {
+		stopCPUProfile(cpuFile)
+		if cpuFile != nil {
+			fmt.Printf("CPU profile written to: %s\n", cpuProfile)
+		}
+
+		if traceOut != nil {
+			trace.Stop()
+			traceOut.Close()
+			fmt.Printf("Execution trace written to: %s\n", traceFile)
+		}
+
+		if memProfile != "" {
+			if err := writeHeapProfile(memProfile); err != nil {
+				fmt.Printf("Warning: %v\n", err)
+			} else {
+				fmt.Printf("Memory profile written to: %s\n", memProfile)
+			}
+		}
+	}
+
+	var once sync.Once
+	return func() { once.Do(stop) }, nil
+}
+
+func stopCPUProfile(f *os.File) {
+	if f == nil {
+		return
+	}
+	pprof.StopCPUProfile()
+	f.Close()
+}
+
+func writeHeapProfile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create memory profile: %w", err)
+	}
+	defer f.Close()
+
+	runtime.GC() // get up-to-date allocation statistics
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		return fmt.Errorf("failed to write memory profile: %w", err)
+	}
+	return nil
+}
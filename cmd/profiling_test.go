@@ -0,0 +1,92 @@
+package main
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestStartProfilingWritesProfiles profiles a short burst of work and checks
+// the CPU profile, heap profile and trace are written and non-empty
+func TestStartProfilingWritesProfiles(t *testing.T) {
+	dir := t.TempDir()
+	cpuPath := filepath.Join(dir, "cpu.pprof")
+	memPath := filepath.Join(dir, "mem.pprof")
+	tracePath := filepath.Join(dir, "run.trace")
+
+	stop, err := startProfiling(cpuPath, memPath, tracePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(nil)
+	for i := 0; i < 200000; i++ {
+		sum = sha256.Sum256(sum[:])
+	}
+	stop()
+	stop() // later calls are no-ops
+
+	for _, path := range []string{cpuPath, memPath, tracePath} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Errorf("profile not written: %v", err)
+			continue
+		}
+		if info.Size() == 0 {
+			t.Errorf("%s is empty", filepath.Base(path))
+		}
+	}
+}
+
+func TestStartProfilingDisabled(t *testing.T) {
+	stop, err := startProfiling("", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop()
+}
+
+func TestStartProfilingBadPath(t *testing.T) {
+	if _, err := startProfiling(filepath.Join(t.TempDir(), "missing", "cpu.pprof"), "", ""); err == nil {
+		t.Error("expected an error for an unwritable CPU profile path")
+	}
+}
+
+// TestFatalfRunsExitHooks runs fatalf in a child process and checks it
+// restored the database profiler and stopped profiling, in that order,
+// before exiting
+func TestFatalfRunsExitHooks(t *testing.T) {
+	if marker := os.Getenv("FATALF_MARKER"); marker != "" {
+		record := func(step string) func() {
+			return func() {
+				f, _ := os.OpenFile(marker, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+				fmt.Fprintln(f, step)
+				f.Close()
+			}
+		}
+		restoreProfilerOnExit = record("restore profiler")
+		stopProfilingOnExit = record("stop profiling")
+		fatalf("phase failed")
+		return
+	}
+
+	marker := filepath.Join(t.TempDir(), "hooks")
+	cmd := exec.Command(os.Args[0], "-test.run=^TestFatalfRunsExitHooks$")
+	cmd.Env = append(os.Environ(), "FATALF_MARKER="+marker)
+	out, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 || !strings.Contains(string(out), "phase failed") {
+		t.Fatalf("child exited with %v, output %q; want fatalf's exit status 1", err, out)
+	}
+	hooks, err := os.ReadFile(marker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(hooks); got != "restore profiler\nstop profiling\n" {
+		t.Errorf("exit hooks ran %q, want the profiler restored, then profiling stopped", got)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"mail-stress-test/handler"
+	"mail-stress-test/models"
+)
+
+// seedMails creates numMails mails from nextMail through create. With
+// maxDocuments > 0 it stops before a mail whose sender and recipient copies
+// would take the running document total past the cap. Failed creates are
+// logged and skipped.
+func seedMails(numMails int, maxDocuments int64, nextMail func(int) *models.MailRequest, create func(*models.MailRequest) error) (seededMails, seededDocuments int64) {
+	for i := 0; i < numMails; i++ {
+		req := nextMail(i)
+		documents := int64(handler.MailDocuments(req))
+		if maxDocuments > 0 && seededDocuments+documents > maxDocuments {
+			fmt.Printf("🛑 Seeding stopped: max_seed_documents cap of %d reached after %d mails (%d documents)\n",
+				maxDocuments, i, seededDocuments)
+			break
+		}
+
+		if err := create(req); err != nil {
+			log.Printf("Warning: Failed to seed mail %d: %v", i, err)
+			continue
+		}
+		seededMails++
+		seededDocuments += documents
+
+		if i%100 == 0 && i > 0 {
+			fmt.Printf("  Created %d/%d mails\n", i, numMails)
+		}
+	}
+	return seededMails, seededDocuments
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"mail-stress-test/models"
+)
+
+// TestSeedMailsStopsAtCap seeds mails of three documents each (the sender's
+// copy and two recipients) under a cap of ten documents and checks seeding
+// stops after three mails, before the fourth would exceed it
+func TestSeedMailsStopsAtCap(t *testing.T) {
+	created := 0
+	next := func(int) *models.MailRequest {
+		return &models.MailRequest{From: "user-1", To: []string{"user-2", "user-3"}}
+	}
+	create := func(*models.MailRequest) error {
+		created++
+		return nil
+	}
+
+	mails, documents := seedMails(100, 10, next, create)
+	if mails != 3 || documents != 9 {
+		t.Errorf("seeded %d mails, %d documents; want 3, 9", mails, documents)
+	}
+	if created != 3 {
+		t.Errorf("create called %d times, want 3", created)
+	}
+}
+
+// TestSeedMailsCountsOnlyCreated checks failed creates don't count toward
+// the cap or the reported totals, and no cap seeds every mail
+func TestSeedMailsCountsOnlyCreated(t *testing.T) {
+	next := func(int) *models.MailRequest {
+		return &models.MailRequest{From: "user-1", To: []string{"user-1", "user-2"}}
+	}
+	calls := 0
+	create := func(*models.MailRequest) error {
+		calls++
+		if calls%2 == 0 {
+			return errors.New("insert failed")
+		}
+		return nil
+	}
+
+	mails, documents := seedMails(10, 0, next, create)
+	if mails != 5 || documents != 10 {
+		t.Errorf("seeded %d mails, %d documents; want 5, 10", mails, documents)
+	}
+}
@@ -0,0 +1,155 @@
+package config
+
+import (
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// defaultYAML is config/default.yaml, the base every preset starts from
+//
+//go:embed default.yaml
+var defaultYAML []byte
+
+// preset tunes a base config for one workload shape
+type preset struct {
+	description string
+	apply       func(c *Config)
+}
+
+// presets are selected with -preset. Operation weights sum to 100; worker
+// counts and rates are starting points for a single backend instance.
+var presets = map[string]preset{
+	"balanced": {
+		description: "even mix of writes, listing and search",
+		apply: func(c *Config) {
+			c.StressTest.ConcurrentWorkers = 50
+			c.StressTest.RequestRate = 200
+			c.StressTest.Operations = Operations{
+				CreateMailWeight: 30,
+				ListMailWeight:   35,
+				SearchWeight:     20,
+				DraftWeight:      5,
+				ForwardWeight:    5,
+				ReplyAllWeight:   5,
+			}
+		},
+	},
+	"read-heavy": {
+		description: "mostly inbox listing and search, few writes",
+		apply: func(c *Config) {
+			c.StressTest.ConcurrentWorkers = 100
+			c.StressTest.RequestRate = 500
+			c.StressTest.Operations = Operations{
+				CreateMailWeight: 10,
+				ListMailWeight:   60,
+				SearchWeight:     25,
+				ForwardWeight:    3,
+				ReplyAllWeight:   2,
+			}
+		},
+	},
+	"write-heavy": {
+		description: "mostly sends, drafts and replies, little reading",
+		apply: func(c *Config) {
+			c.StressTest.ConcurrentWorkers = 50
+			c.StressTest.RequestRate = 200
+			c.StressTest.Operations = Operations{
+				CreateMailWeight: 55,
+				ListMailWeight:   15,
+				SearchWeight:     5,
+				DraftWeight:      10,
+				ForwardWeight:    5,
+				ReplyAllWeight:   10,
+			}
+		},
+	},
+	"search-heavy": {
+		description: "search-dominated load against a large mailbox",
+		apply: func(c *Config) {
+			c.StressTest.ConcurrentWorkers = 50
+			c.StressTest.RequestRate = 150
+			c.StressTest.Operations = Operations{
+				CreateMailWeight: 10,
+				ListMailWeight:   15,
+				SearchWeight:     75,
+			}
+			c.Benchmark.Iterations *= 2
+		},
+	},
+}
+
+// PresetNames lists the available presets in alphabetical order
+func PresetNames() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PresetDescription returns a one-line summary of the named preset
+func PresetDescription(name string) string {
+	return presets[name].description
+}
+
+// Preset builds the named preset on top of the built-in defaults, with the
+// env overrides applied last
+func Preset(name string) (*Config, error) {
+	cfg, err := buildPreset(name)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.finish()
+}
+
+// buildPreset applies the named preset to the built-in defaults, without
+// env overrides or validation
+func buildPreset(name string) (*Config, error) {
+	p, ok := presets[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown preset %q (available: %s)", name, strings.Join(PresetNames(), ", "))
+	}
+
+	cfg := &Config{}
+	if err := unmarshalConfig(cfg, defaultYAML, FormatYAML); err != nil {
+		return nil, fmt.Errorf("failed to parse built-in defaults: %w", err)
+	}
+	p.apply(cfg)
+	return cfg, nil
+}
+
+// LoadPreset builds the named preset and applies the config at path on top
+// of it, so every key set in the file wins, and the env overrides on top of
+// both. An empty path uses CONFIG_PATH, or the preset alone if that is unset
+// too. A path of "-" reads the config from stdin.
+func LoadPreset(name, path, format string) (*Config, error) {
+	cfg, err := buildPreset(name)
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		path = os.Getenv("CONFIG_PATH")
+	}
+	if path == "" {
+		return cfg.finish()
+	}
+
+	var data []byte
+	if path == StdinPath {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	if err := unmarshalConfig(cfg, data, format); err != nil {
+		return nil, err
+	}
+	return cfg.finish()
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestPresetsAreValid builds every preset and checks it passes validation
+// with operation weights summing to 100
+func TestPresetsAreValid(t *testing.T) {
+	clearEnvOverrides(t)
+	if len(PresetNames()) == 0 {
+		t.Fatal("no presets")
+	}
+	for _, name := range PresetNames() {
+		cfg, err := Preset(name)
+		if err != nil {
+			t.Errorf("Preset(%s): %v", name, err)
+			continue
+		}
+		if err := cfg.validate(); err != nil {
+			t.Errorf("preset %s is invalid: %v", name, err)
+		}
+		ops := cfg.StressTest.Operations
+		sum := ops.CreateMailWeight + ops.ListMailWeight + ops.SearchWeight + ops.DraftWeight +
+			ops.ForwardWeight + ops.ReplyAllWeight + ops.SoftDeleteWeight + ops.CountWeight
+		if sum != 100 {
+			t.Errorf("preset %s weights sum to %d, want 100", name, sum)
+		}
+		if PresetDescription(name) == "" {
+			t.Errorf("preset %s has no description", name)
+		}
+		// Settings the preset does not tune come from the defaults
+		if cfg.MongoDB.Database == "" || cfg.StressTest.Duration == 0 {
+			t.Errorf("preset %s lost the built-in defaults: %+v", name, cfg.MongoDB)
+		}
+	}
+}
+
+// TestConfigOverridesPreset applies a file setting only the worker count on
+// top of a preset and checks that key wins while the preset's mix is kept
+func TestConfigOverridesPreset(t *testing.T) {
+	clearEnvOverrides(t)
+	path := filepath.Join(t.TempDir(), "override.yaml")
+	if err := os.WriteFile(path, []byte("stress_test:\n  concurrent_workers: 7\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadPreset("search-heavy", path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	preset, _ := Preset("search-heavy")
+	if cfg.StressTest.ConcurrentWorkers != 7 {
+		t.Errorf("workers = %d, want the file's 7", cfg.StressTest.ConcurrentWorkers)
+	}
+	if cfg.StressTest.Operations != preset.StressTest.Operations || cfg.StressTest.RequestRate != preset.StressTest.RequestRate {
+		t.Errorf("operations %+v at %v rps, want the preset's %+v at %v rps",
+			cfg.StressTest.Operations, cfg.StressTest.RequestRate, preset.StressTest.Operations, preset.StressTest.RequestRate)
+	}
+
+	if _, err := LoadPreset("nightly", "", ""); err == nil || !strings.Contains(err.Error(), "balanced") {
+		t.Errorf("unknown preset error = %v, want the available presets listed", err)
+	}
+}
+
+// TestEnvOverridesPreset checks the env overrides win over the preset, with
+// and without a config file, and that CONFIG_PATH stands in for the path
+func TestEnvOverridesPreset(t *testing.T) {
+	clearEnvOverrides(t)
+	t.Setenv("STRESS_WORKERS", "13")
+
+	cfg, err := LoadPreset("search-heavy", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.StressTest.ConcurrentWorkers != 13 {
+		t.Errorf("workers = %d, want STRESS_WORKERS's 13 over the preset", cfg.StressTest.ConcurrentWorkers)
+	}
+
+	path := filepath.Join(t.TempDir(), "override.yaml")
+	if err := os.WriteFile(path, []byte("stress_test:\n  concurrent_workers: 7\n  request_rate: 250\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_PATH", path)
+	cfg, err = LoadPreset("search-heavy", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.StressTest.ConcurrentWorkers != 13 || cfg.StressTest.RequestRate != 250 {
+		t.Errorf("workers = %d at %d rps, want STRESS_WORKERS's 13 and CONFIG_PATH's 250 rps",
+			cfg.StressTest.ConcurrentWorkers, cfg.StressTest.RequestRate)
+	}
+}
@@ -0,0 +1,127 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// archiveBatchSize is the number of mails copied and deleted per round trip
+const archiveBatchSize = 1000
+
+// ArchiveResult reports mails moved from the mails collection into the
+// archive collection
+type ArchiveResult struct {
+	Collection string        `json:"collection"`
+	Cutoff     time.Time     `json:"cutoff"` // mails created at or before this moved
+	Moved      int64         `json:"moved"`
+	Remaining  int64         `json:"remaining"` // mails left in the hot collection
+	Duration   time.Duration `json:"duration"`
+}
+
+// SetArchiveCollection enables the cold archive collection; empty disables it
+func (m *MongoDB) SetArchiveCollection(name string) {
+	m.ArchiveCollection = name
+}
+
+// Archive returns the archive collection; check ArchiveCollection first
+func (m *MongoDB) Archive() *mongo.Collection {
+	return m.Database.Collection(m.ArchiveCollection)
+}
+
+// ArchiveOldestMails moves the oldest ratio (0-1) of all mails into the
+// archive collection, as a hot/cold split would after mails age out. Mails
+// are copied in batches and deleted from the hot collection once copied, so
+// an interrupted move never loses mails.
+func (m *MongoDB) ArchiveOldestMails(ctx context.Context, ratio float64) (*ArchiveResult, error) {
+	if m.ArchiveCollection == "" {
+		return nil, fmt.Errorf("no archive collection configured")
+	}
+	result := &ArchiveResult{Collection: m.ArchiveCollection}
+	start := time.Now()
+
+	total, err := m.Mails().CountDocuments(ctx, bson.M{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to count mails: %w", err)
+	}
+	n := int64(float64(total) * ratio)
+	if n <= 0 {
+		result.Remaining = total
+		return result, nil
+	}
+
+	// The createdAt of the n-th oldest mail is the cutoff
+	var oldest struct {
+		CreatedAt time.Time `bson:"createdAt"`
+	}
+	err = m.Mails().FindOne(ctx, bson.M{}, options.FindOne().
+		SetSort(bson.D{{Key: "createdAt", Value: 1}}).
+		SetSkip(n-1).
+		SetProjection(bson.M{"createdAt": 1})).Decode(&oldest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find archive cutoff: %w", err)
+	}
+	result.Cutoff = oldest.CreatedAt
+
+	filter := bson.M{"createdAt": bson.M{"$lte": result.Cutoff}}
+	cursor, err := m.Mails().Find(ctx, filter, options.Find().SetBatchSize(archiveBatchSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mails to archive: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	batch := make([]interface{}, 0, archiveBatchSize)
+	ids := make([]interface{}, 0, archiveBatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		// Unordered so mails already copied by an earlier, interrupted run
+		// (duplicate _id) don't stop the rest of the batch
+		_, err := m.Archive().InsertMany(ctx, batch, options.InsertMany().SetOrdered(false))
+		if err != nil && !mongo.IsDuplicateKeyError(err) {
+			return fmt.Errorf("failed to copy mails to archive: %w", err)
+		}
+		res, err := m.Mails().DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+		if err != nil {
+			return fmt.Errorf("failed to delete archived mails: %w", err)
+		}
+		result.Moved += res.DeletedCount
+		batch, ids = batch[:0], ids[:0]
+		return nil
+	}
+
+	for cursor.Next(ctx) {
+		var doc bson.Raw
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		batch = append(batch, doc)
+		ids = append(ids, doc.Lookup("_id"))
+		if len(batch) == archiveBatchSize {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	result.Remaining = total - result.Moved
+	result.Duration = time.Since(start)
+	return result, nil
+}
+
+// String summarizes the move for the console
+func (r *ArchiveResult) String() string {
+	return fmt.Sprintf("Archived %d mails created at or before %s into %s in %s (%d remain hot)",
+		r.Moved, r.Cutoff.Format(time.RFC3339), r.Collection, r.Duration, r.Remaining)
+}
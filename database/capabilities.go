@@ -0,0 +1,128 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Deployment topologies reported by ServerCapabilities
+const (
+	TopologyStandalone = "standalone"
+	TopologyReplicaSet = "replica_set"
+	TopologySharded    = "sharded"
+)
+
+// ServerCapabilities describes the connected server, probed once at startup,
+// and the features that were skipped because the server cannot support them
+type ServerCapabilities struct {
+	Version  string           `json:"version"`
+	Topology string           `json:"topology"`
+	Atlas    bool             `json:"atlas"`
+	Skipped  []CapabilitySkip `json:"skipped,omitempty"`
+
+	versionParts [3]int
+}
+
+// CapabilitySkip records a strategy or feature disabled by the probe
+type CapabilitySkip struct {
+	Feature string `json:"feature"`
+	Reason  string `json:"reason"`
+}
+
+// ProbeCapabilities runs buildInfo and hello (isMaster on servers that
+// predate hello) to detect the server version and deployment type
+func (m *MongoDB) ProbeCapabilities(ctx context.Context) (*ServerCapabilities, error) {
+	var buildInfo bson.M
+	if err := m.Database.RunCommand(ctx, bson.D{{Key: "buildInfo", Value: 1}}).Decode(&buildInfo); err != nil {
+		return nil, fmt.Errorf("buildInfo command failed: %w", err)
+	}
+
+	var hello bson.M
+	if err := m.Database.RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		if err := m.Database.RunCommand(ctx, bson.D{{Key: "isMaster", Value: 1}}).Decode(&hello); err != nil {
+			return nil, fmt.Errorf("hello/isMaster command failed: %w", err)
+		}
+	}
+
+	return parseCapabilities(buildInfo, hello), nil
+}
+
+// parseCapabilities derives capabilities from buildInfo and hello replies
+func parseCapabilities(buildInfo, hello bson.M) *ServerCapabilities {
+	caps := &ServerCapabilities{Topology: TopologyStandalone}
+
+	if version, ok := buildInfo["version"].(string); ok {
+		caps.Version = version
+		for i, part := range strings.SplitN(version, ".", 3) {
+			// Drop pre-release suffixes such as "0-rc1"
+			digits := strings.FieldsFunc(part, func(r rune) bool { return r < '0' || r > '9' })
+			if len(digits) > 0 {
+				caps.versionParts[i], _ = strconv.Atoi(digits[0])
+			}
+		}
+	}
+
+	switch {
+	case hello["msg"] == "isdbgrid":
+		caps.Topology = TopologySharded
+	case hello["setName"] != nil:
+		caps.Topology = TopologyReplicaSet
+	}
+
+	// Atlas hosts are named *.mongodb.net and report an atlasVersion
+	if _, ok := buildInfo["atlasVersion"]; ok {
+		caps.Atlas = true
+	}
+	if hosts, ok := hello["hosts"].(bson.A); ok {
+		for _, host := range hosts {
+			if h, ok := host.(string); ok && strings.Contains(h, ".mongodb.net") {
+				caps.Atlas = true
+			}
+		}
+	}
+
+	return caps
+}
+
+// AtLeast reports whether the server version is major.minor or newer
+func (c *ServerCapabilities) AtLeast(major, minor int) bool {
+	if c.versionParts[0] != major {
+		return c.versionParts[0] > major
+	}
+	return c.versionParts[1] >= minor
+}
+
+// SupportsTransactions reports whether multi-document transactions are
+// available: replica sets from 4.0, sharded clusters from 4.2
+func (c *ServerCapabilities) SupportsTransactions() bool {
+	switch c.Topology {
+	case TopologyReplicaSet:
+		return c.AtLeast(4, 0)
+	case TopologySharded:
+		return c.AtLeast(4, 2)
+	}
+	return false
+}
+
+// SupportsAtlasSearch reports whether the $search aggregation stage is available
+func (c *ServerCapabilities) SupportsAtlasSearch() bool {
+	return c.Atlas
+}
+
+// Skip records that feature was disabled and why
+func (c *ServerCapabilities) Skip(feature, reason string) {
+	c.Skipped = append(c.Skipped, CapabilitySkip{Feature: feature, Reason: reason})
+}
+
+// String summarizes the server for the startup log
+func (c *ServerCapabilities) String() string {
+	atlas := ""
+	if c.Atlas {
+		atlas = ", Atlas"
+	}
+	return fmt.Sprintf("MongoDB %s (%s%s, transactions: %t)", c.Version, c.Topology, atlas, c.SupportsTransactions())
+}
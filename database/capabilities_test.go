@@ -0,0 +1,93 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// TestProbeCapabilities answers buildInfo and hello as different servers
+// would and checks the detected version, topology and derived features
+func TestProbeCapabilities(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	tests := []struct {
+		name         string
+		buildInfo    []bson.E
+		hello        []bson.E
+		topology     string
+		atLeast42    bool
+		transactions bool
+		atlas        bool
+	}{
+		{
+			name:      "standalone 4.0",
+			buildInfo: []bson.E{{Key: "version", Value: "4.0.28"}},
+			topology:  TopologyStandalone,
+		},
+		{
+			name:         "replica set 4.4",
+			buildInfo:    []bson.E{{Key: "version", Value: "4.4.6"}},
+			hello:        []bson.E{{Key: "setName", Value: "rs0"}},
+			topology:     TopologyReplicaSet,
+			atLeast42:    true,
+			transactions: true,
+		},
+		{
+			name:      "sharded 4.2 release candidate",
+			buildInfo: []bson.E{{Key: "version", Value: "4.2.0-rc1"}},
+			hello:     []bson.E{{Key: "msg", Value: "isdbgrid"}},
+			topology:  TopologySharded, atLeast42: true, transactions: true,
+		},
+		{
+			name:      "atlas",
+			buildInfo: []bson.E{{Key: "version", Value: "7.0.2"}},
+			hello: []bson.E{{Key: "setName", Value: "atlas-abc"},
+				{Key: "hosts", Value: bson.A{"cluster0-shard-00-00.abc.mongodb.net:27017"}}},
+			topology: TopologyReplicaSet, atLeast42: true, transactions: true, atlas: true,
+		},
+	}
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			m := &MongoDB{Client: mt.Client, Database: mt.DB}
+			mt.AddMockResponses(mtest.CreateSuccessResponse(tt.buildInfo...), mtest.CreateSuccessResponse(tt.hello...))
+
+			caps, err := m.ProbeCapabilities(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if caps.Topology != tt.topology || caps.AtLeast(4, 2) != tt.atLeast42 ||
+				caps.SupportsTransactions() != tt.transactions || caps.SupportsAtlasSearch() != tt.atlas {
+				t.Errorf("%s: topology %s, 4.2+ %v, transactions %v, atlas %v; want %s, %v, %v, %v", caps.Version,
+					caps.Topology, caps.AtLeast(4, 2), caps.SupportsTransactions(), caps.SupportsAtlasSearch(),
+					tt.topology, tt.atLeast42, tt.transactions, tt.atlas)
+			}
+		})
+	}
+
+	mt.Run("isMaster fallback", func(mt *mtest.T) {
+		m := &MongoDB{Client: mt.Client, Database: mt.DB}
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "version", Value: "4.0.3"}),
+			mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 59, Name: "CommandNotFound", Message: "no such command: 'hello'"}),
+			mtest.CreateSuccessResponse(bson.E{Key: "setName", Value: "rs0"}),
+		)
+
+		caps, err := m.ProbeCapabilities(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if caps.Topology != TopologyReplicaSet || !caps.AtLeast(4, 0) || caps.AtLeast(4, 1) {
+			t.Errorf("capabilities = %s, want a 4.0 replica set from isMaster", caps)
+		}
+	})
+
+	mt.Run("buildInfo fails", func(mt *mtest.T) {
+		m := &MongoDB{Client: mt.Client, Database: mt.DB}
+		mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 13, Name: "Unauthorized", Message: "not authorized"}))
+		if _, err := m.ProbeCapabilities(context.Background()); err == nil {
+			t.Error("probe succeeded without buildInfo")
+		}
+	})
+}
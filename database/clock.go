@@ -0,0 +1,64 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ClockOffset is the estimated difference between the MongoDB server clock
+// and the local clock (server - client), NTP-style from the best round trip
+type ClockOffset struct {
+	Offset    time.Duration `json:"offset"`
+	RoundTrip time.Duration `json:"round_trip"`
+	Samples   int           `json:"samples"`
+}
+
+// EstimateClockOffset sends `hello` samples times and compares the server's
+// localTime with the midpoint of the round trip. The sample with the shortest
+// round trip is used since it bounds the error best. localTime has millisecond
+// resolution, so offsets below a few ms are noise.
+func (m *MongoDB) EstimateClockOffset(ctx context.Context, samples int) (*ClockOffset, error) {
+	if samples <= 0 {
+		samples = 5
+	}
+
+	var best *ClockOffset
+	for i := 0; i < samples; i++ {
+		sent := time.Now()
+		var reply struct {
+			LocalTime time.Time `bson:"localTime"`
+		}
+		if err := m.Database.RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&reply); err != nil {
+			return nil, fmt.Errorf("hello command failed: %w", err)
+		}
+		received := time.Now()
+
+		if reply.LocalTime.IsZero() {
+			return nil, fmt.Errorf("server did not report localTime")
+		}
+
+		roundTrip := received.Sub(sent)
+		midpoint := sent.Add(roundTrip / 2)
+		if best == nil || roundTrip < best.RoundTrip {
+			best = &ClockOffset{
+				Offset:    reply.LocalTime.Sub(midpoint),
+				RoundTrip: roundTrip,
+			}
+		}
+	}
+
+	best.Samples = samples
+	return best, nil
+}
+
+// Exceeds reports whether the absolute offset is larger than limit
+func (c *ClockOffset) Exceeds(limit time.Duration) bool {
+	offset := c.Offset
+	if offset < 0 {
+		offset = -offset
+	}
+	return offset > limit
+}
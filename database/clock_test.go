@@ -0,0 +1,45 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// TestEstimateClockOffset answers hello with a server clock running a known
+// five seconds ahead and checks the estimate recovers that offset
+func TestEstimateClockOffset(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("server ahead", func(mt *mtest.T) {
+		const skew = 5 * time.Second
+		m := &MongoDB{Client: mt.Client, Database: mt.DB}
+		for i := 0; i < 3; i++ {
+			localTime := primitive.NewDateTimeFromTime(time.Now().Add(skew))
+			mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "localTime", Value: localTime}))
+		}
+
+		offset, err := m.EstimateClockOffset(context.Background(), 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := offset.Offset - skew; diff < -50*time.Millisecond || diff > 50*time.Millisecond {
+			t.Errorf("offset = %s, want %s", offset.Offset, skew)
+		}
+		if !offset.Exceeds(time.Second) || offset.Exceeds(10*time.Second) {
+			t.Errorf("Exceeds misjudges an offset of %s", offset.Offset)
+		}
+	})
+
+	mt.Run("no localTime", func(mt *mtest.T) {
+		m := &MongoDB{Client: mt.Client, Database: mt.DB}
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+		if _, err := m.EstimateClockOffset(context.Background(), 1); err == nil {
+			t.Error("estimate succeeded without a server time")
+		}
+	})
+}
@@ -0,0 +1,31 @@
+package database
+
+import "fmt"
+
+// DefaultAppName is the client appName used when neither the config nor the
+// URI sets one
+const DefaultAppName = "mail-stress-test"
+
+// SetRunID includes runID in every query comment
+func (m *MongoDB) SetRunID(runID string) {
+	m.runID = runID
+}
+
+// AppName returns the appName the client connected with
+func (m *MongoDB) AppName() string {
+	return m.appName
+}
+
+// QueryComment returns the comment attached to queries of the given
+// operation, e.g. "mail-stress-test run=01HX... op=list", so the tool's
+// load can be attributed in the profiler, currentOp and slow query logs
+func (m *MongoDB) QueryComment(operation string) string {
+	app := m.appName
+	if app == "" {
+		app = DefaultAppName
+	}
+	if m.runID == "" {
+		return fmt.Sprintf("%s op=%s", app, operation)
+	}
+	return fmt.Sprintf("%s run=%s op=%s", app, m.runID, operation)
+}
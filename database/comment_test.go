@@ -0,0 +1,32 @@
+package database
+
+import "testing"
+
+func TestQueryComment(t *testing.T) {
+	m := &MongoDB{}
+	if got := m.QueryComment("list"); got != "mail-stress-test op=list" {
+		t.Errorf("without a run: QueryComment = %q", got)
+	}
+	m.appName = "nightly"
+	m.SetRunID("01HXRUN")
+	if got, want := m.QueryComment("search:regex"), "nightly run=01HXRUN op=search:regex"; got != want {
+		t.Errorf("QueryComment = %q, want %q", got, want)
+	}
+}
+
+// TestClientAppName checks the configured appName wins over the URI's, and
+// the URI's over the default
+func TestClientAppName(t *testing.T) {
+	for _, tt := range []struct {
+		uri, appName, want string
+	}{
+		{"mongodb://localhost:27017", "", DefaultAppName},
+		{"mongodb://localhost:27017/?appName=from-uri", "", "from-uri"},
+		{"mongodb://localhost:27017/?appName=from-uri", "from-config", "from-config"},
+	} {
+		opts, appName := clientOptions(tt.uri, tt.appName)
+		if appName != tt.want || opts.AppName == nil || *opts.AppName != tt.want {
+			t.Errorf("clientOptions(%q, %q) connects as %q (options %v), want %q", tt.uri, tt.appName, appName, opts.AppName, tt.want)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// WiredTiger block compressors accepted by createCollection
+var Compressors = []string{"none", "snappy", "zlib", "zstd"}
+
+// CreateCompressedCollection creates collection with the given WiredTiger
+// block compressor instead of the server default
+func (m *MongoDB) CreateCompressedCollection(ctx context.Context, collection, compressor string) error {
+	opts := options.CreateCollection().SetStorageEngine(bson.M{
+		"wiredTiger": bson.M{"configString": "block_compressor=" + compressor},
+	})
+	if err := m.Database.CreateCollection(ctx, collection, opts); err != nil {
+		return fmt.Errorf("failed to create %s with %s compression: %w", collection, compressor, err)
+	}
+	return nil
+}
+
+// Checkpoint flushes dirty data to disk with fsync, so storage sizes reflect
+// compressed blocks rather than data still held in the cache
+func (m *MongoDB) Checkpoint(ctx context.Context) error {
+	if err := m.Client.Database("admin").RunCommand(ctx, bson.D{{Key: "fsync", Value: 1}}).Err(); err != nil {
+		return fmt.Errorf("fsync failed: %w", err)
+	}
+	return nil
+}
+
+// BlockCompressor reads the block compressor a collection was created with
+// from its WiredTiger creation string
+func (m *MongoDB) BlockCompressor(ctx context.Context, collection string) (string, error) {
+	var raw struct {
+		WiredTiger struct {
+			CreationString string `bson:"creationString"`
+		} `bson:"wiredTiger"`
+	}
+	if err := m.Database.RunCommand(ctx, bson.D{{Key: "collStats", Value: collection}}).Decode(&raw); err != nil {
+		return "", fmt.Errorf("collStats failed: %w", err)
+	}
+	for _, setting := range strings.Split(raw.WiredTiger.CreationString, ",") {
+		if value, ok := strings.CutPrefix(setting, "block_compressor="); ok {
+			if value == "" {
+				return "none", nil
+			}
+			return value, nil
+		}
+	}
+	return "", fmt.Errorf("no block_compressor in %s creation string (storage engine is not WiredTiger?)", collection)
+}
@@ -0,0 +1,59 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// TestCreateCompressedCollection checks the create command carries the
+// requested WiredTiger block compressor
+func TestCreateCompressedCollection(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("zstd", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+		if err := newTestDB(mt.DB).CreateCompressedCollection(context.Background(), "mails_zstd", "zstd"); err != nil {
+			t.Fatal(err)
+		}
+		create := mt.GetStartedEvent().Command
+		if create.Lookup("create").StringValue() != "mails_zstd" {
+			t.Errorf("create command = %s", create)
+		}
+		if config := create.Lookup("storageEngine", "wiredTiger", "configString").StringValue(); config != "block_compressor=zstd" {
+			t.Errorf("configString = %q, want block_compressor=zstd", config)
+		}
+	})
+}
+
+// TestBlockCompressor reads the compressor back from collStats creation
+// strings, including the empty setting of an uncompressed collection
+func TestBlockCompressor(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	for _, tt := range []struct {
+		name, creation, want string // want "" = error
+	}{
+		{"snappy", "access_pattern_hint=none,block_compressor=snappy,cache_resident=false", "snappy"},
+		{"uncompressed", "allocation_size=4KB,block_compressor=,checksum=on", "none"},
+		{"not wiredTiger", "", ""},
+	} {
+		creation, want := tt.creation, tt.want
+		mt.Run(tt.name, func(mt *mtest.T) {
+			mt.AddMockResponses(mtest.CreateSuccessResponse(
+				bson.E{Key: "wiredTiger", Value: bson.D{{Key: "creationString", Value: creation}}}))
+			got, err := newTestDB(mt.DB).BlockCompressor(context.Background(), "mails")
+			if want == "" {
+				if err == nil {
+					mt.Errorf("BlockCompressor = %q without a block_compressor setting, want an error", got)
+				}
+				return
+			}
+			if err != nil || got != want {
+				mt.Errorf("BlockCompressor = %q, %v; want %q", got, err, want)
+			}
+		})
+	}
+}
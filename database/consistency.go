@@ -0,0 +1,79 @@
+package database
+
+import (
+	"fmt"
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+// ParseReadPreference converts a mode name such as "primary" or
+// "secondaryPreferred" into a read preference. Empty returns nil (driver default).
+func ParseReadPreference(mode string) (*readpref.ReadPref, error) {
+	if mode == "" {
+		return nil, nil
+	}
+
+	parsed, err := readpref.ModeFromString(mode)
+	if err != nil {
+		return nil, fmt.Errorf("invalid read preference %q: %w", mode, err)
+	}
+	return readpref.New(parsed)
+}
+
+// ParseWriteConcern converts "majority", a node count such as "1", or a tag
+// set name into a write concern. Empty returns nil (driver default).
+func ParseWriteConcern(w string) (*writeconcern.WriteConcern, error) {
+	switch w {
+	case "":
+		return nil, nil
+	case "majority":
+		return writeconcern.Majority(), nil
+	}
+
+	if n, err := strconv.Atoi(w); err == nil {
+		if n < 0 {
+			return nil, fmt.Errorf("invalid write concern %q: must not be negative", w)
+		}
+		return &writeconcern.WriteConcern{W: n}, nil
+	}
+	return writeconcern.Custom(w), nil
+}
+
+// DatabaseOptions builds database options for the given read preference and
+// write concern names
+func DatabaseOptions(readPreference, writeConcern string) (*options.DatabaseOptions, error) {
+	opts := options.Database()
+
+	rp, err := ParseReadPreference(readPreference)
+	if err != nil {
+		return nil, err
+	}
+	if rp != nil {
+		opts.SetReadPreference(rp)
+	}
+
+	wc, err := ParseWriteConcern(writeConcern)
+	if err != nil {
+		return nil, err
+	}
+	if wc != nil {
+		opts.SetWriteConcern(wc)
+	}
+
+	return opts, nil
+}
+
+// SetConsistency reopens the database handle with the given read preference
+// and write concern, so reads may go to secondaries and writes may wait for a
+// majority. Empty values keep the driver defaults.
+func (m *MongoDB) SetConsistency(readPreference, writeConcern string) error {
+	opts, err := DatabaseOptions(readPreference, writeConcern)
+	if err != nil {
+		return err
+	}
+	m.Database = m.Client.Database(m.Database.Name(), opts)
+	return nil
+}
@@ -0,0 +1,59 @@
+package database
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+func TestDatabaseOptionsSecondaryPreferredMajority(t *testing.T) {
+	opts, err := DatabaseOptions("secondaryPreferred", "majority")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.ReadPreference == nil || opts.ReadPreference.Mode() != readpref.SecondaryPreferredMode {
+		t.Errorf("read preference = %v, want secondaryPreferred", opts.ReadPreference)
+	}
+	if opts.WriteConcern == nil || opts.WriteConcern.W != "majority" {
+		t.Errorf("write concern = %+v, want w: majority", opts.WriteConcern)
+	}
+}
+
+func TestDatabaseOptionsDefaults(t *testing.T) {
+	opts, err := DatabaseOptions("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.ReadPreference != nil || opts.WriteConcern != nil {
+		t.Errorf("empty settings = %v, %+v; want the driver defaults", opts.ReadPreference, opts.WriteConcern)
+	}
+}
+
+func TestParseWriteConcern(t *testing.T) {
+	tests := []struct {
+		w    string
+		want interface{}
+	}{
+		{"1", 1},
+		{"0", 0},
+		{"majority", "majority"},
+		{"dc-east", "dc-east"},
+	}
+	for _, tt := range tests {
+		wc, err := ParseWriteConcern(tt.w)
+		if err != nil {
+			t.Errorf("ParseWriteConcern(%q): %v", tt.w, err)
+			continue
+		}
+		if wc.W != tt.want {
+			t.Errorf("ParseWriteConcern(%q).W = %v, want %v", tt.w, wc.W, tt.want)
+		}
+	}
+
+	if _, err := ParseWriteConcern("-1"); err == nil {
+		t.Error("ParseWriteConcern(\"-1\") accepted a negative node count")
+	}
+	if _, err := ParseReadPreference("secondaryOnly"); err == nil {
+		t.Error("ParseReadPreference accepted an unknown mode")
+	}
+}
@@ -0,0 +1,123 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// QueryPlan summarizes the winning plan and execution stats of an explained
+// query
+type QueryPlan struct {
+	Stages       []string `json:"stages"`            // winning plan stages, root first
+	Indexes      []string `json:"indexes,omitempty"` // indexes scanned by IXSCAN stages
+	InMemorySort bool     `json:"in_memory_sort"`    // a SORT stage orders results after fetching them
+	KeysExamined int64    `json:"keys_examined"`
+	DocsExamined int64    `json:"docs_examined"`
+	Returned     int64    `json:"returned"`
+}
+
+// UsesIndex reports whether an IXSCAN stage scanned the named index
+func (p *QueryPlan) UsesIndex(name string) bool {
+	for _, index := range p.Indexes {
+		if index == name {
+			return true
+		}
+	}
+	return false
+}
+
+// String formats the plan as one report line
+func (p *QueryPlan) String() string {
+	out := fmt.Sprintf("plan=%s keys=%d docs=%d returned=%d", strings.Join(p.Stages, ">"), p.KeysExamined, p.DocsExamined, p.Returned)
+	if len(p.Indexes) > 0 {
+		out += " indexes=" + strings.Join(p.Indexes, ",")
+	}
+	if p.InMemorySort {
+		out += " (in-memory sort)"
+	}
+	return out
+}
+
+// ExplainFind explains a find on the mails collection with executionStats
+// verbosity, so the plan reflects a real execution of filter and opts
+func (m *MongoDB) ExplainFind(ctx context.Context, filter interface{}, opts *options.FindOptions) (*QueryPlan, error) {
+	find := bson.D{{Key: "find", Value: m.Mails().Name()}, {Key: "filter", Value: filter}}
+	if opts != nil {
+		if opts.Sort != nil {
+			find = append(find, bson.E{Key: "sort", Value: opts.Sort})
+		}
+		if opts.Projection != nil {
+			find = append(find, bson.E{Key: "projection", Value: opts.Projection})
+		}
+		if opts.Skip != nil {
+			find = append(find, bson.E{Key: "skip", Value: *opts.Skip})
+		}
+		if opts.Limit != nil {
+			find = append(find, bson.E{Key: "limit", Value: *opts.Limit})
+		}
+		if opts.Collation != nil {
+			find = append(find, bson.E{Key: "collation", Value: opts.Collation.ToDocument()})
+		}
+		if opts.Comment != nil {
+			find = append(find, bson.E{Key: "comment", Value: opts.Comment})
+		}
+	}
+
+	var explain bson.M
+	cmd := bson.D{{Key: "explain", Value: find}, {Key: "verbosity", Value: "executionStats"}}
+	if err := m.Database.RunCommand(ctx, cmd).Decode(&explain); err != nil {
+		return nil, fmt.Errorf("explain failed: %w", err)
+	}
+	return ParseExplain(explain), nil
+}
+
+// ParseExplain extracts the QueryPlan from an explain command's output
+func ParseExplain(explain bson.M) *QueryPlan {
+	plan := &QueryPlan{}
+	if planner, ok := explain["queryPlanner"].(bson.M); ok {
+		winning, _ := planner["winningPlan"].(bson.M)
+		// Slot-based execution (MongoDB 7.0+) nests the classic plan
+		if inner, ok := winning["queryPlan"].(bson.M); ok {
+			winning = inner
+		}
+		plan.walk(winning)
+	}
+	if stats, ok := explain["executionStats"].(bson.M); ok {
+		plan.KeysExamined = toInt64(stats["totalKeysExamined"])
+		plan.DocsExamined = toInt64(stats["totalDocsExamined"])
+		plan.Returned = toInt64(stats["nReturned"])
+	}
+	return plan
+}
+
+// walk records stage and its input stages depth first
+func (p *QueryPlan) walk(stage bson.M) {
+	if stage == nil {
+		return
+	}
+	name, _ := stage["stage"].(string)
+	p.Stages = append(p.Stages, name)
+	switch name {
+	case "IXSCAN":
+		if index, ok := stage["indexName"].(string); ok {
+			p.Indexes = append(p.Indexes, index)
+		}
+	case "SORT":
+		p.InMemorySort = true
+	}
+
+	if input, ok := stage["inputStage"].(bson.M); ok {
+		p.walk(input)
+	}
+	if inputs, ok := stage["inputStages"].(bson.A); ok {
+		for _, input := range inputs {
+			if input, ok := input.(bson.M); ok {
+				p.walk(input)
+			}
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TestParseExplain checks the plan is read from classic and slot-based
+// explain output, through single and multiple input stages
+func TestParseExplain(t *testing.T) {
+	ixscan := func(index string) bson.M { return bson.M{"stage": "IXSCAN", "indexName": index} }
+	stats := bson.M{"totalKeysExamined": int32(120), "totalDocsExamined": int64(100), "nReturned": int32(20)}
+	tests := []struct {
+		name    string
+		winning bson.M
+		stages  string
+		indexes []string
+		sort    bool
+	}{
+		{
+			name:    "index order",
+			winning: bson.M{"stage": "LIMIT", "inputStage": bson.M{"stage": "FETCH", "inputStage": ixscan("userId_1_subject_1")}},
+			stages:  "LIMIT>FETCH>IXSCAN", indexes: []string{"userId_1_subject_1"},
+		},
+		{
+			name:    "in-memory sort",
+			winning: bson.M{"queryPlan": bson.M{"stage": "SORT", "inputStage": bson.M{"stage": "FETCH", "inputStage": ixscan("userId_1")}}},
+			stages:  "SORT>FETCH>IXSCAN", indexes: []string{"userId_1"}, sort: true,
+		},
+		{
+			name:    "or",
+			winning: bson.M{"stage": "FETCH", "inputStage": bson.M{"stage": "OR", "inputStages": bson.A{ixscan("from_1"), ixscan("to_1")}}},
+			stages:  "FETCH>OR>IXSCAN>IXSCAN", indexes: []string{"from_1", "to_1"},
+		},
+	}
+	for _, tt := range tests {
+		plan := ParseExplain(bson.M{"queryPlanner": bson.M{"winningPlan": tt.winning}, "executionStats": stats})
+		if got := plan.String(); plan.InMemorySort != tt.sort || len(plan.Indexes) != len(tt.indexes) ||
+			plan.KeysExamined != 120 || plan.DocsExamined != 100 || plan.Returned != 20 {
+			t.Errorf("%s: plan = %s, want stages %s over %v, in-memory sort %v", tt.name, got, tt.stages, tt.indexes, tt.sort)
+		}
+		if got := plan.Stages; strings.Join(got, ">") != tt.stages {
+			t.Errorf("%s: stages = %v, want %s", tt.name, got, tt.stages)
+		}
+		for _, index := range tt.indexes {
+			if !plan.UsesIndex(index) {
+				t.Errorf("%s: UsesIndex(%s) = false", tt.name, index)
+			}
+		}
+	}
+}
+
+// TestExplainFindCommand checks the explained find carries the options the
+// real query would run with
+func TestExplainFindCommand(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("options", func(mt *mtest.T) {
+		m := newTestDB(mt.DB)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(
+			bson.E{Key: "queryPlanner", Value: bson.D{{Key: "winningPlan", Value: bson.D{{Key: "stage", Value: "IXSCAN"}, {Key: "indexName", Value: "userId_1_subject_1"}}}}},
+		))
+		opts := options.Find().SetSort(bson.D{{Key: "subject", Value: 1}}).SetLimit(20).SetComment("list")
+		plan, err := m.ExplainFind(context.Background(), bson.M{"userId": "user-1"}, opts)
+		if err != nil {
+			mt.Fatal(err)
+		}
+		if !plan.UsesIndex("userId_1_subject_1") {
+			mt.Errorf("plan = %s, want the mocked index", plan)
+		}
+
+		cmd := mt.GetStartedEvent().Command
+		if verbosity := cmd.Lookup("verbosity").StringValue(); verbosity != "executionStats" {
+			mt.Errorf("verbosity = %s, want executionStats", verbosity)
+		}
+		find := cmd.Lookup("explain").Document()
+		if coll := find.Lookup("find").StringValue(); coll != DefaultMailsCollection {
+			mt.Errorf("find = %s, want %s", coll, DefaultMailsCollection)
+		}
+		if sort := find.Lookup("sort", "subject"); sort.Int32() != 1 {
+			mt.Errorf("sort = %s, want subject ascending", find.Lookup("sort"))
+		}
+		if limit := find.Lookup("limit").Int64(); limit != 20 {
+			mt.Errorf("limit = %d, want 20", limit)
+		}
+		if _, err := find.LookupErr("skip"); err == nil {
+			mt.Error("explain sent a skip the query did not set")
+		}
+	})
+}
@@ -0,0 +1,88 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// InboxDistribution summarizes how many mail documents each user owns
+type InboxDistribution struct {
+	Users  int64   `json:"users"`
+	Mails  int64   `json:"mails"`
+	Min    int64   `json:"min"`
+	Median int64   `json:"median"`
+	Max    int64   `json:"max"`
+	Avg    float64 `json:"avg"`
+
+	// TopShare is the fraction of all mails owned by the largest 10% of inboxes
+	TopShare float64 `json:"top_share"`
+}
+
+// inboxSize is one row of the per-user aggregation
+type inboxSize struct {
+	UserID string `bson:"_id"`
+	Mails  int64  `bson:"mails"`
+}
+
+// InboxSizes counts mail documents per userId on the server
+func (m *MongoDB) InboxSizes(ctx context.Context) (map[string]int64, error) {
+	pipeline := bson.A{
+		bson.M{"$group": bson.M{"_id": "$userId", "mails": bson.M{"$sum": 1}}},
+	}
+
+	cursor, err := m.Mails().Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, fmt.Errorf("inbox size aggregation failed: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var rows []inboxSize
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+
+	sizes := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		sizes[row.UserID] = row.Mails
+	}
+	return sizes, nil
+}
+
+// SummarizeInboxSizes computes the distribution of per-user inbox sizes
+func SummarizeInboxSizes(sizes map[string]int64) *InboxDistribution {
+	dist := &InboxDistribution{Users: int64(len(sizes))}
+	if len(sizes) == 0 {
+		return dist
+	}
+
+	sorted := make([]int64, 0, len(sizes))
+	for _, n := range sizes {
+		sorted = append(sorted, n)
+		dist.Mails += n
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] > sorted[j] })
+
+	dist.Max = sorted[0]
+	dist.Min = sorted[len(sorted)-1]
+	dist.Median = sorted[len(sorted)/2]
+	dist.Avg = float64(dist.Mails) / float64(dist.Users)
+
+	top := (len(sorted) + 9) / 10
+	var topMails int64
+	for _, n := range sorted[:top] {
+		topMails += n
+	}
+	if dist.Mails > 0 {
+		dist.TopShare = float64(topMails) / float64(dist.Mails)
+	}
+	return dist
+}
+
+func (d *InboxDistribution) String() string {
+	return fmt.Sprintf("Users: %d, Mails: %d, Mails/inbox: min %d, median %d, avg %.1f, max %d; largest 10%% of inboxes hold %.1f%% of mails",
+		d.Users, d.Mails, d.Min, d.Median, d.Avg, d.Max, d.TopShare*100)
+}
@@ -0,0 +1,132 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MetadataCollection stores one dataset manifest per seeded mails collection
+const MetadataCollection = "stress_metadata"
+
+// ManifestSchemaVersion is bumped whenever the shape of seeded documents
+// changes, so old datasets are flagged as needing a reseed
+const ManifestSchemaVersion = 1
+
+// DatasetManifest describes how a mails collection was seeded
+type DatasetManifest struct {
+	MailsCollection   string    `bson:"_id" json:"mails_collection"`
+	SchemaVersion     int       `bson:"schemaVersion" json:"schema_version"`
+	ThreadsCollection string    `bson:"threadsCollection" json:"threads_collection"`
+	ArchiveCollection string    `bson:"archiveCollection,omitempty" json:"archive_collection,omitempty"`
+	UserIDScheme      string    `bson:"userIdScheme" json:"user_id_scheme"`
+	UserIDPrefix      string    `bson:"userIdPrefix,omitempty" json:"user_id_prefix,omitempty"`
+	UserIDSeed        int64     `bson:"userIdSeed" json:"user_id_seed"` // derives objectid and uuid IDs
+	NumUsers          int       `bson:"numUsers" json:"num_users"`
+	SeededMails       int64     `bson:"seededMails" json:"seeded_mails"`
+	SeededDocuments   int64     `bson:"seededDocuments" json:"seeded_documents"`
+	SeededAt          time.Time `bson:"seededAt" json:"seeded_at"`
+}
+
+// RecordSeed stores the manifest of a seeding run. Seed counts add up over
+// repeated seeding into the same collection; the rest is replaced.
+func (m *MongoDB) RecordSeed(ctx context.Context, manifest DatasetManifest) error {
+	update := bson.M{
+		"$set": bson.M{
+			"schemaVersion":     manifest.SchemaVersion,
+			"threadsCollection": manifest.ThreadsCollection,
+			"archiveCollection": manifest.ArchiveCollection,
+			"userIdScheme":      manifest.UserIDScheme,
+			"userIdPrefix":      manifest.UserIDPrefix,
+			"userIdSeed":        manifest.UserIDSeed,
+			"numUsers":          manifest.NumUsers,
+			"seededAt":          manifest.SeededAt,
+		},
+		"$inc": bson.M{
+			"seededMails":     manifest.SeededMails,
+			"seededDocuments": manifest.SeededDocuments,
+		},
+	}
+	_, err := m.Database.Collection(MetadataCollection).UpdateOne(ctx,
+		bson.M{"_id": manifest.MailsCollection}, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to record dataset manifest: %w", err)
+	}
+	return nil
+}
+
+// Manifests returns every stored manifest, by mails collection name
+func (m *MongoDB) Manifests(ctx context.Context) ([]DatasetManifest, error) {
+	cursor, err := m.Database.Collection(MetadataCollection).Find(ctx, bson.M{},
+		options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dataset manifests: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var manifests []DatasetManifest
+	if err := cursor.All(ctx, &manifests); err != nil {
+		return nil, err
+	}
+	return manifests, nil
+}
+
+// ManifestIncompatibilities compares the manifests stored in the database
+// with the manifest the current config would seed and describes every
+// difference that would make the run query data that doesn't exist. No
+// stored manifests (a dataset seeded before manifests existed, or none at
+// all) yields no findings.
+func ManifestIncompatibilities(stored []DatasetManifest, current DatasetManifest) []string {
+	if len(stored) == 0 {
+		return nil
+	}
+
+	var seeded *DatasetManifest
+	names := make([]string, 0, len(stored))
+	for i := range stored {
+		names = append(names, stored[i].MailsCollection)
+		if stored[i].MailsCollection == current.MailsCollection {
+			seeded = &stored[i]
+		}
+	}
+	if seeded == nil {
+		sort.Strings(names)
+		return []string{fmt.Sprintf("no dataset was seeded into mails collection %q (seeded collections: %v); set mongodb.mails_collection or reseed",
+			current.MailsCollection, names)}
+	}
+
+	var problems []string
+	if seeded.SchemaVersion != current.SchemaVersion {
+		problems = append(problems, fmt.Sprintf("dataset schema version %d differs from the tool's %d; reseed", seeded.SchemaVersion, current.SchemaVersion))
+	}
+	if seeded.ThreadsCollection != current.ThreadsCollection {
+		problems = append(problems, fmt.Sprintf("dataset threads were seeded into %q but threads_collection is %q", seeded.ThreadsCollection, current.ThreadsCollection))
+	}
+	if seeded.ArchiveCollection != current.ArchiveCollection && current.ArchiveCollection != "" {
+		problems = append(problems, fmt.Sprintf("dataset archive is %q but archive_collection is %q", seeded.ArchiveCollection, current.ArchiveCollection))
+	}
+	if seeded.UserIDScheme != current.UserIDScheme || seeded.UserIDPrefix != current.UserIDPrefix {
+		problems = append(problems, fmt.Sprintf("dataset user IDs use scheme %q (prefix %q) but user_id_scheme is %q (prefix %q); lists and searches will find no mails",
+			seeded.UserIDScheme, seeded.UserIDPrefix, current.UserIDScheme, current.UserIDPrefix))
+	}
+	// Only the random-looking schemes derive their IDs from the seed
+	if seeded.UserIDScheme == current.UserIDScheme && seededFromSeed(current.UserIDScheme) && seeded.UserIDSeed != current.UserIDSeed {
+		problems = append(problems, fmt.Sprintf("dataset %s user IDs were derived from user_id_seed %d but it is %d; lists and searches will find no mails",
+			current.UserIDScheme, seeded.UserIDSeed, current.UserIDSeed))
+	}
+	if current.NumUsers > seeded.NumUsers {
+		problems = append(problems, fmt.Sprintf("dataset was seeded for %d users but num_users is %d; the extra users have empty inboxes",
+			seeded.NumUsers, current.NumUsers))
+	}
+	return problems
+}
+
+// seededFromSeed reports whether a user ID scheme derives its IDs from the
+// user ID seed rather than a counter
+func seededFromSeed(scheme string) bool {
+	return scheme == "objectid" || scheme == "uuid"
+}
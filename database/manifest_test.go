@@ -0,0 +1,98 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"mail-stress-test/internal/mongotest"
+)
+
+// testManifest is a dataset seeded with seeded objectid users
+func testManifest() DatasetManifest {
+	return DatasetManifest{
+		MailsCollection:   DefaultMailsCollection,
+		SchemaVersion:     ManifestSchemaVersion,
+		ThreadsCollection: DefaultThreadsCollection,
+		UserIDScheme:      "objectid",
+		UserIDSeed:        42,
+		NumUsers:          100,
+	}
+}
+
+// TestManifestIncompatibilities changes one setting at a time against a
+// stored manifest and checks each change is reported, while a matching or
+// smaller config is not
+func TestManifestIncompatibilities(t *testing.T) {
+	stored := []DatasetManifest{testManifest()}
+	tests := []struct {
+		name   string
+		change func(m *DatasetManifest)
+		want   string // "" = compatible
+	}{
+		{"same config", func(m *DatasetManifest) {}, ""},
+		{"fewer users", func(m *DatasetManifest) { m.NumUsers = 10 }, ""},
+		{"other mails collection", func(m *DatasetManifest) { m.MailsCollection = "mails_v2" }, `no dataset was seeded into mails collection "mails_v2"`},
+		{"other scheme", func(m *DatasetManifest) { m.UserIDScheme = "uuid" }, `scheme "objectid"`},
+		{"other seed", func(m *DatasetManifest) { m.UserIDSeed = 7 }, "user_id_seed 42 but it is 7"},
+		{"more users", func(m *DatasetManifest) { m.NumUsers = 500 }, "seeded for 100 users"},
+		{"old schema", func(m *DatasetManifest) { m.SchemaVersion++ }, "reseed"},
+		{"other threads collection", func(m *DatasetManifest) { m.ThreadsCollection = "threads_v2" }, `threads_collection is "threads_v2"`},
+	}
+	for _, tt := range tests {
+		current := testManifest()
+		tt.change(&current)
+		problems := ManifestIncompatibilities(stored, current)
+		if tt.want == "" {
+			if len(problems) != 0 {
+				t.Errorf("%s: problems = %q, want none", tt.name, problems)
+			}
+			continue
+		}
+		if len(problems) != 1 || !strings.Contains(problems[0], tt.want) {
+			t.Errorf("%s: problems = %q, want one mentioning %q", tt.name, problems, tt.want)
+		}
+	}
+
+	// Counter-based IDs don't depend on the seed
+	email := testManifest()
+	email.UserIDScheme = "email"
+	other := email
+	other.UserIDSeed = 7
+	if problems := ManifestIncompatibilities([]DatasetManifest{email}, other); len(problems) != 0 {
+		t.Errorf("email scheme with another seed: problems = %q, want none", problems)
+	}
+	if problems := ManifestIncompatibilities(nil, testManifest()); len(problems) != 0 {
+		t.Errorf("no stored manifest: problems = %q, want none", problems)
+	}
+}
+
+// TestRecordSeedIntegration seeds twice into one collection and checks the
+// stored manifest keeps the seed, adds up the counts and flags a run with
+// another seed
+func TestRecordSeedIntegration(t *testing.T) {
+	m := newTestDB(mongotest.Database(t))
+	ctx := context.Background()
+
+	manifest := testManifest()
+	manifest.SeededMails, manifest.SeededDocuments, manifest.SeededAt = 10, 30, time.Now()
+	for i := 0; i < 2; i++ {
+		if err := m.RecordSeed(ctx, manifest); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stored, err := m.Manifests(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 1 || stored[0].UserIDSeed != 42 || stored[0].SeededMails != 20 || stored[0].SeededDocuments != 60 {
+		t.Fatalf("stored manifests = %+v, want one with seed 42, 20 mails and 60 documents", stored)
+	}
+	current := testManifest()
+	current.UserIDSeed = 0
+	if problems := ManifestIncompatibilities(stored, current); len(problems) != 1 || !strings.Contains(problems[0], "user_id_seed") {
+		t.Errorf("problems = %q, want the seed mismatch", problems)
+	}
+}
@@ -4,20 +4,42 @@ import (
 	"context"
 	"time"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// Default collection names
+const (
+	DefaultMailsCollection   = "mails"
+	DefaultThreadsCollection = "threads"
+)
+
 type MongoDB struct {
 	Client   *mongo.Client
 	Database *mongo.Database
+
+	MailsCollection   string
+	ThreadsCollection string
+
+	// ArchiveCollection holds cold mails moved out of MailsCollection;
+	// empty when no archive is used
+	ArchiveCollection string
+
+	// appName and runID tag queries via QueryComment
+	appName string
+	runID   string
 }
 
-func NewMongoDB(uri, dbName string, timeout int) (*MongoDB, error) {
+// NewMongoDB connects to uri. appName identifies the connections in server
+// logs, currentOp and the profiler; empty uses DefaultAppName unless the
+// URI sets one.
+func NewMongoDB(uri, dbName string, timeout int, appName string) (*MongoDB, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
 	defer cancel()
 
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	clientOpts, appName := clientOptions(uri, appName)
+	client, err := mongo.Connect(ctx, clientOpts)
 	if err != nil {
 		return nil, err
 	}
@@ -27,11 +49,58 @@ func NewMongoDB(uri, dbName string, timeout int) (*MongoDB, error) {
 	}
 
 	return &MongoDB{
-		Client:   client,
-		Database: client.Database(dbName),
+		Client:            client,
+		Database:          client.Database(dbName),
+		MailsCollection:   DefaultMailsCollection,
+		ThreadsCollection: DefaultThreadsCollection,
+		appName:           appName,
 	}, nil
 }
 
+// clientOptions applies uri and resolves the appName the client connects
+// with: appName if set, else the URI's appName, else DefaultAppName
+func clientOptions(uri, appName string) (*options.ClientOptions, string) {
+	clientOpts := options.Client().ApplyURI(uri)
+	switch {
+	case appName != "":
+		clientOpts.SetAppName(appName)
+	case clientOpts.AppName != nil:
+		appName = *clientOpts.AppName
+	default:
+		appName = DefaultAppName
+		clientOpts.SetAppName(appName)
+	}
+	return clientOpts, appName
+}
+
+// SetCollectionNames overrides the mails/threads collection names, so isolated
+// benchmarks can run side by side in the same database. Empty names keep the current value.
+func (m *MongoDB) SetCollectionNames(mails, threads string) {
+	if mails != "" {
+		m.MailsCollection = mails
+	}
+	if threads != "" {
+		m.ThreadsCollection = threads
+	}
+}
+
+// Mails returns the configured mails collection
+func (m *MongoDB) Mails() *mongo.Collection {
+	return m.Database.Collection(m.MailsCollection)
+}
+
+// Threads returns the configured threads collection
+func (m *MongoDB) Threads() *mongo.Collection {
+	return m.Database.Collection(m.ThreadsCollection)
+}
+
+// ClearPlanCache drops the cached query plans of collection so the next
+// queries are planned from scratch. The WiredTiger data cache cannot be
+// flushed short of a restart.
+func (m *MongoDB) ClearPlanCache(ctx context.Context, collection string) error {
+	return m.Database.RunCommand(ctx, bson.D{{Key: "planCacheClear", Value: collection}}).Err()
+}
+
 func (m *MongoDB) Close() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -40,19 +109,28 @@ func (m *MongoDB) Close() error {
 
 func (m *MongoDB) CreateIndexes(ctx context.Context) error {
 	// Mail collection indexes
-	mailCollection := m.Database.Collection("mails")
+	mailCollection := m.Mails()
 	_, err := mailCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
 		{Keys: map[string]interface{}{"userId": 1}},
 		{Keys: map[string]interface{}{"threadId": 1}},
 		{Keys: map[string]interface{}{"createdAt": -1}},
 		{Keys: map[string]interface{}{"subject": "text", "content": "text"}},
+		// Live vs trashed mails in soft-delete mode
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "deletedAt", Value: 1}, {Key: "createdAt", Value: -1}}},
+		// from:/to: participant filters on search
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "from", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "to", Value: 1}, {Key: "createdAt", Value: -1}}},
+		// Inbox pages in PageSort order, by skip or by page cursor
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}},
+		// List/search in subject order (sort_by: subject)
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "subject", Value: 1}}},
 	})
 	if err != nil {
 		return err
 	}
 
 	// Thread collection indexes
-	threadCollection := m.Database.Collection("threads")
+	threadCollection := m.Threads()
 	_, err = threadCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
 		{Keys: map[string]interface{}{"user_id": 1, "thread_id": 1}},
 		{Keys: map[string]interface{}{"user_id": 1}},
@@ -60,3 +138,46 @@ func (m *MongoDB) CreateIndexes(ctx context.Context) error {
 
 	return err
 }
+
+// WaitForIndexBuilds polls until no index build is in progress on the
+// collection, so queries issued afterwards can use the new indexes. In-progress
+// builds are reported by listIndexes with includeBuildUUIDs (MongoDB 4.4+).
+func (m *MongoDB) WaitForIndexBuilds(ctx context.Context, collection string, pollInterval time.Duration) error {
+	for {
+		building, err := m.indexBuildsInProgress(ctx, collection)
+		if err != nil {
+			return err
+		}
+		if building == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// indexBuildsInProgress counts the indexes of the collection still being built
+func (m *MongoDB) indexBuildsInProgress(ctx context.Context, collection string) (int, error) {
+	var resp struct {
+		Cursor struct {
+			FirstBatch []bson.M `bson:"firstBatch"`
+		} `bson:"cursor"`
+	}
+
+	cmd := bson.D{{Key: "listIndexes", Value: collection}, {Key: "includeBuildUUIDs", Value: true}}
+	if err := m.Database.RunCommand(ctx, cmd).Decode(&resp); err != nil {
+		return 0, err
+	}
+
+	building := 0
+	for _, idx := range resp.Cursor.FirstBatch {
+		if _, ok := idx["buildUUID"]; ok {
+			building++
+		}
+	}
+	return building, nil
+}
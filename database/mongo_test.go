@@ -0,0 +1,87 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"mail-stress-test/internal/mongotest"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// newTestDB wraps a test database in a MongoDB with the default collections
+func newTestDB(db *mongo.Database) *MongoDB {
+	return &MongoDB{
+		Client:            db.Client(),
+		Database:          db,
+		MailsCollection:   DefaultMailsCollection,
+		ThreadsCollection: DefaultThreadsCollection,
+	}
+}
+
+// TestFirstQueryUsesBuiltIndex seeds a collection large enough for the index
+// build to take a while, then checks the first query issued after
+// WaitForIndexBuilds is planned on the new index rather than a scan
+func TestFirstQueryUsesBuiltIndex(t *testing.T) {
+	m := newTestDB(mongotest.Database(t))
+	ctx := context.Background()
+
+	const users, perBatch, batches = 200, 5000, 20
+	for b := 0; b < batches; b++ {
+		docs := make([]interface{}, perBatch)
+		for i := range docs {
+			n := b*perBatch + i
+			docs[i] = bson.D{
+				{Key: "userId", Value: fmt.Sprintf("user-%d", n%users)},
+				{Key: "subject", Value: fmt.Sprintf("subject %d", n)},
+				{Key: "createdAt", Value: time.Unix(int64(n), 0)},
+			}
+		}
+		if _, err := m.Mails().InsertMany(ctx, docs); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Start the build without waiting on it, as a strategy setup on an
+	// older server or a busy replica set would
+	index := mongo.IndexModel{
+		Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}},
+		Options: options.Index().SetName("userId_createdAt"),
+	}
+	go m.Mails().Indexes().CreateOne(ctx, index)
+
+	waitCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	// Wait until the build has started (or already finished), then until
+	// it finishes
+	for {
+		building, err := m.indexBuildsInProgress(waitCtx, m.MailsCollection)
+		if err != nil {
+			t.Fatal(err)
+		}
+		specs, err := m.Mails().Indexes().ListSpecifications(waitCtx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if building > 0 || len(specs) > 1 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := m.WaitForIndexBuilds(waitCtx, m.MailsCollection, 10*time.Millisecond); err != nil {
+		t.Fatalf("WaitForIndexBuilds: %v", err)
+	}
+
+	plan, err := m.ExplainFind(ctx, bson.D{{Key: "userId", Value: "user-7"}},
+		options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}).SetLimit(50))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !plan.UsesIndex("userId_createdAt") {
+		t.Errorf("first query after the build: %s, want an IXSCAN of userId_createdAt", plan)
+	}
+}
@@ -0,0 +1,53 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PageSort orders a user's mails newest first; _id breaks createdAt ties so
+// a page cursor identifies a unique position
+var PageSort = bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}
+
+// PageCursor is the position after the last mail of a page
+type PageCursor struct {
+	CreatedAt time.Time
+	ID        primitive.ObjectID
+}
+
+// Token encodes the cursor as an opaque URL-safe string
+func (c PageCursor) Token() string {
+	buf := make([]byte, 8+len(c.ID))
+	binary.BigEndian.PutUint64(buf, uint64(c.CreatedAt.UnixMilli()))
+	copy(buf[8:], c.ID[:])
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+// ParsePageCursor decodes a token made by PageCursor.Token
+func ParsePageCursor(token string) (PageCursor, error) {
+	buf, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(buf) != 8+len(primitive.ObjectID{}) {
+		return PageCursor{}, fmt.Errorf("invalid page cursor %q", token)
+	}
+	var c PageCursor
+	c.CreatedAt = time.UnixMilli(int64(binary.BigEndian.Uint64(buf))).UTC()
+	copy(c.ID[:], buf[8:])
+	return c, nil
+}
+
+// After returns the filter selecting the user's mails past the cursor in
+// PageSort order, so the next page is an index seek instead of a skip
+func (c PageCursor) After(userID string) bson.M {
+	return bson.M{
+		"userId": userID,
+		"$or": bson.A{
+			bson.M{"createdAt": bson.M{"$lt": c.CreatedAt}},
+			bson.M{"createdAt": c.CreatedAt, "_id": bson.M{"$lt": c.ID}},
+		},
+	}
+}
@@ -0,0 +1,45 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestPageCursorToken(t *testing.T) {
+	cursor := PageCursor{CreatedAt: time.Date(2024, 5, 1, 12, 30, 0, 250*int(time.Millisecond), time.UTC), ID: primitive.NewObjectID()}
+	parsed, err := ParsePageCursor(cursor.Token())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !parsed.CreatedAt.Equal(cursor.CreatedAt) || parsed.ID != cursor.ID {
+		t.Errorf("ParsePageCursor(Token()) = %+v, want %+v", parsed, cursor)
+	}
+
+	for _, token := range []string{"", "not base64!", cursor.Token()[:10]} {
+		if _, err := ParsePageCursor(token); err == nil {
+			t.Errorf("ParsePageCursor(%q) accepted", token)
+		}
+	}
+}
+
+// TestPageCursorAfter checks the filter continues past the cursor in
+// PageSort order: older mails, or the same instant with a smaller _id
+func TestPageCursorAfter(t *testing.T) {
+	cursor := PageCursor{CreatedAt: time.Unix(1700000000, 0).UTC(), ID: primitive.NewObjectID()}
+	filter := cursor.After("user-1")
+	if filter["userId"] != "user-1" {
+		t.Errorf("userId = %v, want user-1", filter["userId"])
+	}
+	or, ok := filter["$or"].(bson.A)
+	if !ok || len(or) != 2 {
+		t.Fatalf("$or = %v, want two branches", filter["$or"])
+	}
+	older := or[0].(bson.M)["createdAt"].(bson.M)["$lt"]
+	tie := or[1].(bson.M)
+	if older != cursor.CreatedAt || tie["createdAt"] != cursor.CreatedAt || tie["_id"].(bson.M)["$lt"] != cursor.ID {
+		t.Errorf("After() = %v, want createdAt < %s or a tie with _id < %s", filter, cursor.CreatedAt, cursor.ID.Hex())
+	}
+}
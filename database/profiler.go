@@ -0,0 +1,273 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ProfilerSettings is a database's profiling level, slow-op threshold and
+// optional filter, as reported by the profile command
+type ProfilerSettings struct {
+	Level  int
+	SlowMs int
+	Filter bson.Raw // nil when no filter is set
+}
+
+// Profiler enables the database profiler for the duration of a run and
+// restores the previous settings afterwards
+type Profiler struct {
+	db       *MongoDB
+	previous ProfilerSettings
+	since    time.Time
+	restored bool
+}
+
+// ProfiledOperation is one system.profile entry issued by this tool
+type ProfiledOperation struct {
+	Operation    string        `json:"operation"` // op= from the query comment, else the profiler op type
+	Op           string        `json:"op"`        // query, command, getmore, insert, update, remove
+	Namespace    string        `json:"namespace"`
+	Duration     time.Duration `json:"duration"`
+	PlanSummary  string        `json:"plan_summary,omitempty"`
+	KeysExamined int64         `json:"keys_examined"`
+	DocsExamined int64         `json:"docs_examined"`
+	Returned     int64         `json:"returned"`
+	Timestamp    time.Time     `json:"timestamp"`
+}
+
+// CollScan reports whether the operation scanned the whole collection
+func (o ProfiledOperation) CollScan() bool {
+	return strings.HasPrefix(o.PlanSummary, "COLLSCAN")
+}
+
+// String formats the operation as one report line
+func (o ProfiledOperation) String() string {
+	return fmt.Sprintf("%-12s %-30s %8s  plan=%s keys=%d docs=%d returned=%d",
+		o.Operation, o.Namespace, o.Duration, o.PlanSummary, o.KeysExamined, o.DocsExamined, o.Returned)
+}
+
+// ProfileOperationStats aggregates the profiled operations of one type
+type ProfileOperationStats struct {
+	Operation   string        `json:"operation"`
+	Count       int           `json:"count"`
+	CollScans   int           `json:"coll_scans"`
+	AvgDuration time.Duration `json:"avg_duration"`
+	MaxDuration time.Duration `json:"max_duration"`
+}
+
+// ProfileReport summarizes what the profiler caught during the run
+type ProfileReport struct {
+	SlowMs     int                      `json:"slow_ms"`
+	Operations []*ProfileOperationStats `json:"operations"`           // by operation type, most profiled first
+	Slowest    []ProfiledOperation      `json:"slowest,omitempty"`    // slowest first
+	CollScans  []ProfiledOperation      `json:"coll_scans,omitempty"` // slowest first
+}
+
+// ProfilerSettings reads the database's current profiler settings
+func (m *MongoDB) ProfilerSettings(ctx context.Context) (ProfilerSettings, error) {
+	var reply struct {
+		Was    int      `bson:"was"`
+		SlowMs int      `bson:"slowms"`
+		Filter bson.Raw `bson:"filter"`
+	}
+	if err := m.Database.RunCommand(ctx, bson.D{{Key: "profile", Value: -1}}).Decode(&reply); err != nil {
+		return ProfilerSettings{}, fmt.Errorf("profile command failed: %w", err)
+	}
+	return ProfilerSettings{Level: reply.Was, SlowMs: reply.SlowMs, Filter: reply.Filter}, nil
+}
+
+// StartProfiler profiles operations slower than slowMs, plus every
+// collection scan on servers that support profiler filters (4.4.2+). Call
+// Restore when the run ends.
+func (m *MongoDB) StartProfiler(ctx context.Context, slowMs int) (*Profiler, error) {
+	previous, err := m.ProfilerSettings(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "millis", Value: bson.D{{Key: "$gte", Value: slowMs}}}},
+		bson.D{{Key: "planSummary", Value: bson.D{{Key: "$regex", Value: "^COLLSCAN"}}}},
+	}}}
+	cmd := bson.D{{Key: "profile", Value: 1}, {Key: "slowms", Value: slowMs}, {Key: "filter", Value: filter}}
+	if err := m.Database.RunCommand(ctx, cmd).Err(); err != nil {
+		// Older servers reject the filter; fall back to the slowms threshold
+		cmd = bson.D{{Key: "profile", Value: 1}, {Key: "slowms", Value: slowMs}}
+		if err := m.Database.RunCommand(ctx, cmd).Err(); err != nil {
+			return nil, fmt.Errorf("failed to enable profiler: %w", err)
+		}
+	}
+	return &Profiler{db: m, previous: previous, since: time.Now()}, nil
+}
+
+// Restore puts back the profiler settings found by StartProfiler. It is safe
+// to call more than once.
+func (p *Profiler) Restore(ctx context.Context) error {
+	if p == nil || p.restored {
+		return nil
+	}
+	cmd := bson.D{{Key: "profile", Value: p.previous.Level}, {Key: "slowms", Value: p.previous.SlowMs}}
+	if p.previous.Filter != nil {
+		cmd = append(cmd, bson.E{Key: "filter", Value: p.previous.Filter})
+	} else {
+		cmd = append(cmd, bson.E{Key: "filter", Value: "unset"})
+	}
+	if err := p.db.Database.RunCommand(ctx, cmd).Err(); err != nil {
+		// Servers without profiler filters reject "unset" too
+		cmd = cmd[:2]
+		if err := p.db.Database.RunCommand(ctx, cmd).Err(); err != nil {
+			return fmt.Errorf("failed to restore profiler level %d: %w", p.previous.Level, err)
+		}
+	}
+	p.restored = true
+	return nil
+}
+
+// Report reads the system.profile entries this tool's connections produced
+// since StartProfiler and keeps the top slowest and collection scans
+func (p *Profiler) Report(ctx context.Context, top int) (*ProfileReport, error) {
+	filter := bson.M{"ts": bson.M{"$gte": p.since}, "appName": p.db.appName}
+	cursor, err := p.db.Database.Collection("system.profile").Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "millis", Value: -1}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read system.profile: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var ops []ProfiledOperation
+	for cursor.Next(ctx) {
+		op, err := decodeProfileEntry(cursor)
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, op)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	settings, err := p.db.ProfilerSettings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report := SummarizeProfile(ops, top)
+	report.SlowMs = settings.SlowMs
+	return report, nil
+}
+
+// decodeProfileEntry converts a system.profile document, taking the
+// operation type from the query comment when the tool set one
+func decodeProfileEntry(cursor *mongo.Cursor) (ProfiledOperation, error) {
+	var entry struct {
+		Op                 string    `bson:"op"`
+		Ns                 string    `bson:"ns"`
+		Millis             int64     `bson:"millis"`
+		PlanSummary        string    `bson:"planSummary"`
+		KeysExamined       int64     `bson:"keysExamined"`
+		DocsExamined       int64     `bson:"docsExamined"`
+		Returned           int64     `bson:"nreturned"`
+		Ts                 time.Time `bson:"ts"`
+		Command            bson.Raw  `bson:"command"`
+		OriginatingCommand bson.Raw  `bson:"originatingCommand"`
+	}
+	if err := cursor.Decode(&entry); err != nil {
+		return ProfiledOperation{}, fmt.Errorf("failed to decode profile entry: %w", err)
+	}
+
+	operation := entry.Op
+	for _, cmd := range []bson.Raw{entry.Command, entry.OriginatingCommand} {
+		if comment, ok := cmd.Lookup("comment").StringValueOK(); ok {
+			if i := strings.Index(comment, "op="); i >= 0 {
+				operation = comment[i+len("op="):]
+				break
+			}
+		}
+	}
+
+	return ProfiledOperation{
+		Operation:    operation,
+		Op:           entry.Op,
+		Namespace:    entry.Ns,
+		Duration:     time.Duration(entry.Millis) * time.Millisecond,
+		PlanSummary:  entry.PlanSummary,
+		KeysExamined: entry.KeysExamined,
+		DocsExamined: entry.DocsExamined,
+		Returned:     entry.Returned,
+		Timestamp:    entry.Ts,
+	}, nil
+}
+
+// SummarizeProfile groups ops by operation type and keeps the top slowest
+// operations and collection scans
+func SummarizeProfile(ops []ProfiledOperation, top int) *ProfileReport {
+	sorted := make([]ProfiledOperation, len(ops))
+	copy(sorted, ops)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Duration > sorted[j].Duration })
+
+	report := &ProfileReport{}
+	byOperation := make(map[string]*ProfileOperationStats)
+	totals := make(map[string]time.Duration)
+	for _, op := range sorted {
+		stats, ok := byOperation[op.Operation]
+		if !ok {
+			stats = &ProfileOperationStats{Operation: op.Operation}
+			byOperation[op.Operation] = stats
+			report.Operations = append(report.Operations, stats)
+		}
+		stats.Count++
+		totals[op.Operation] += op.Duration
+		if op.Duration > stats.MaxDuration {
+			stats.MaxDuration = op.Duration
+		}
+		if op.CollScan() {
+			stats.CollScans++
+			if len(report.CollScans) < top {
+				report.CollScans = append(report.CollScans, op)
+			}
+		}
+		if len(report.Slowest) < top {
+			report.Slowest = append(report.Slowest, op)
+		}
+	}
+	for _, stats := range report.Operations {
+		stats.AvgDuration = totals[stats.Operation] / time.Duration(stats.Count)
+	}
+	sort.SliceStable(report.Operations, func(i, j int) bool {
+		return report.Operations[i].Count > report.Operations[j].Count
+	})
+	return report
+}
+
+// String formats the report for the console and text summary
+func (r *ProfileReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "🔬 Profiler (slowms=%d):", r.SlowMs)
+	if len(r.Operations) == 0 {
+		b.WriteString(" no slow or unindexed operations")
+		return b.String()
+	}
+	for _, stats := range r.Operations {
+		fmt.Fprintf(&b, "\n  %-12s %5d profiled, %4d COLLSCAN, avg %s, max %s",
+			stats.Operation, stats.Count, stats.CollScans, stats.AvgDuration, stats.MaxDuration)
+	}
+	if len(r.CollScans) > 0 {
+		b.WriteString("\n  ⚠️  Collection scans:")
+		for _, op := range r.CollScans {
+			fmt.Fprintf(&b, "\n    %s", op)
+		}
+	}
+	if len(r.Slowest) > 0 {
+		b.WriteString("\n  Slowest:")
+		for _, op := range r.Slowest {
+			fmt.Fprintf(&b, "\n    %s", op)
+		}
+	}
+	return b.String()
+}
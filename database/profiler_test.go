@@ -0,0 +1,100 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"mail-stress-test/internal/mongotest"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestSummarizeProfile(t *testing.T) {
+	ops := []ProfiledOperation{
+		{Operation: "list", Duration: 5 * time.Millisecond, PlanSummary: "IXSCAN { userId: 1 }"},
+		{Operation: "search:regex", Duration: 90 * time.Millisecond, PlanSummary: "COLLSCAN"},
+		{Operation: "list", Duration: 15 * time.Millisecond, PlanSummary: "IXSCAN { userId: 1 }"},
+	}
+	report := SummarizeProfile(ops, 2)
+	if len(report.Operations) != 2 || report.Operations[0].Operation != "list" || report.Operations[0].AvgDuration != 10*time.Millisecond {
+		t.Errorf("operations = %+v, want list first averaging 10ms", report.Operations)
+	}
+	if len(report.Slowest) != 2 || report.Slowest[0].Operation != "search:regex" {
+		t.Errorf("slowest = %v, want the two slowest, regex search first", report.Slowest)
+	}
+	if len(report.CollScans) != 1 || report.Operations[1].CollScans != 1 {
+		t.Errorf("collection scans = %v, want only the regex search", report.CollScans)
+	}
+}
+
+// TestProfilerCatchesCollScanIntegration enables the profiler, runs one
+// query on an unindexed field and one through an index, and checks the
+// report lists the unindexed one as a collection scan under its operation
+// and Restore puts the previous level back
+func TestProfilerCatchesCollScanIntegration(t *testing.T) {
+	mdb := mongotest.Database(t)
+	m, err := NewMongoDB(os.Getenv(mongotest.URIEnv), mdb.Name(), 10, "profiler-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	ctx := context.Background()
+
+	docs := make([]interface{}, 500)
+	for i := range docs {
+		docs[i] = bson.D{{Key: "userId", Value: fmt.Sprintf("user-%d", i%10)}, {Key: "subject", Value: fmt.Sprintf("subject %d", i)}}
+	}
+	if _, err := m.Mails().InsertMany(ctx, docs); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.CreateIndexes(ctx); err != nil {
+		t.Fatal(err)
+	}
+	before, err := m.ProfilerSettings(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// slowms is high so only the collection scan qualifies
+	profiler, err := m.StartProfiler(ctx, 10000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer profiler.Restore(context.Background())
+	for operation, filter := range map[string]bson.M{
+		"unindexed": {"isRead": true},
+		"list":      {"userId": "user-1"},
+	} {
+		cursor, err := m.Mails().Find(ctx, filter, options.Find().SetComment(m.QueryComment(operation)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cursor.All(ctx, &[]bson.M{})
+	}
+
+	report, err := profiler.Report(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.CollScans) != 1 || report.CollScans[0].Operation != "unindexed" ||
+		!strings.HasSuffix(report.CollScans[0].Namespace, "."+DefaultMailsCollection) || report.CollScans[0].DocsExamined != 500 {
+		t.Errorf("collection scans = %v, want the unindexed find over all 500 mails", report.CollScans)
+	}
+	for _, op := range report.Slowest {
+		if op.Operation == "list" {
+			t.Errorf("fast indexed list %v was profiled", op)
+		}
+	}
+
+	if err := profiler.Restore(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if after, err := m.ProfilerSettings(ctx); err != nil || after.Level != before.Level || after.SlowMs != before.SlowMs {
+		t.Errorf("profiler after restore = %+v (%v), want %+v", after, err, before)
+	}
+}
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// collStatsReply is a collStats reply for a collection of count mails
+func collStatsReply(count, size, storage, free int32) bson.D {
+	return mtest.CreateSuccessResponse(
+		bson.E{Key: "count", Value: count},
+		bson.E{Key: "size", Value: size},
+		bson.E{Key: "storageSize", Value: storage},
+		bson.E{Key: "freeStorageSize", Value: free},
+		bson.E{Key: "totalIndexSize", Value: int32(4096)},
+		bson.E{Key: "indexSizes", Value: bson.D{{Key: "_id_", Value: int32(4096)}}},
+	)
+}
+
+// TestPurgeMailsBefore deletes mails older than a cutoff against a mock and
+// checks the delete filter, the count removed and the storage reclaimed
+func TestPurgeMailsBefore(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	cutoff := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	mt.Run("with stats", func(mt *mtest.T) {
+		m := newTestDB(mt.DB)
+		mt.AddMockResponses(
+			collStatsReply(100, 100000, 65536, 0),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 40}),
+			collStatsReply(60, 60000, 65536, 28672),
+		)
+
+		result, err := m.PurgeMailsBefore(context.Background(), cutoff)
+		if err != nil {
+			t.Fatal(err)
+		}
+		events := mt.GetAllStartedEvents()
+		if len(events) != 3 || events[1].CommandName != "delete" {
+			t.Fatalf("sent %d commands, want collStats, delete, collStats", len(events))
+		}
+		filter := events[1].Command.Lookup("deletes").Array().Index(0).Value().Document().Lookup("q", "createdAt", "$lt")
+		if !filter.Time().Equal(cutoff) {
+			t.Errorf("delete filter createdAt $lt %s, want %s", filter, cutoff)
+		}
+
+		if result.Deleted != 40 {
+			t.Errorf("Deleted = %d, want 40", result.Deleted)
+		}
+		if result.Before.IndexSizes["_id_"] != 4096 || result.After.Count != 60 {
+			t.Errorf("stats before %+v, after %+v", result.Before, result.After)
+		}
+		// Storage stays allocated, freed pages are kept for reuse
+		if data, storage := result.Reclaimed(); data != 40000 || storage != 0 {
+			t.Errorf("Reclaimed() = %d, %d; want 40000 data bytes and no storage", data, storage)
+		}
+		if out := result.String(); !strings.Contains(out, "Purged 40 mails") || !strings.Contains(out, "28672 free for reuse") {
+			t.Errorf("String() = %q", out)
+		}
+	})
+
+	mt.Run("without collStats", func(mt *mtest.T) {
+		m := newTestDB(mt.DB)
+		unauthorized := mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 13, Name: "Unauthorized", Message: "not authorized"})
+		mt.AddMockResponses(unauthorized, mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 5}), unauthorized)
+
+		result, err := m.PurgeMailsBefore(context.Background(), cutoff)
+		if err != nil {
+			t.Fatalf("purge should not depend on collStats: %v", err)
+		}
+		if result.Deleted != 5 || result.Before != nil {
+			t.Errorf("deleted %d with stats %+v, want 5 and none", result.Deleted, result.Before)
+		}
+		if !strings.HasSuffix(result.String(), "(collStats unavailable)") {
+			t.Errorf("String() = %q", result.String())
+		}
+	})
+}
@@ -0,0 +1,40 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"mail-stress-test/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// SampleMails returns up to n randomly sampled mails from collection. Only
+// sender copies are sampled (type 1, or documents without a type) so each
+// original mail is seen once with its full recipient list; received copies
+// and drafts are skipped.
+func (m *MongoDB) SampleMails(ctx context.Context, collection string, n int) ([]*models.Mail, error) {
+	if collection == "" {
+		return nil, fmt.Errorf("no source collection configured")
+	}
+	if collection == m.MailsCollection {
+		return nil, fmt.Errorf("source collection %q is the collection being seeded", collection)
+	}
+
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{"type": bson.M{"$nin": bson.A{0, 2}}}},
+		bson.M{"$sample": bson.M{"size": n}},
+	}
+
+	cursor, err := m.Database.Collection(collection).Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sample %s: %w", collection, err)
+	}
+	defer cursor.Close(ctx)
+
+	var mails []*models.Mail
+	if err := cursor.All(ctx, &mails); err != nil {
+		return nil, err
+	}
+	return mails, nil
+}
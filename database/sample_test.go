@@ -0,0 +1,51 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// TestSampleMails checks the sample reads sender copies from the source
+// collection and refuses to sample the collection being seeded
+func TestSampleMails(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("sender copies", func(mt *mtest.T) {
+		m := &MongoDB{Client: mt.Client, Database: mt.DB, MailsCollection: DefaultMailsCollection}
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, mt.DB.Name()+".archive", mtest.FirstBatch,
+			bson.D{{Key: "from", Value: "alice@corp"}, {Key: "to", Value: bson.A{"bob@corp"}}, {Key: "subject", Value: "Q3"}}))
+
+		mails, err := m.SampleMails(context.Background(), "archive", 50)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(mails) != 1 || mails[0].From != "alice@corp" || mails[0].Subject != "Q3" {
+			t.Errorf("sampled %+v", mails)
+		}
+
+		cmd := mt.GetStartedEvent().Command
+		if coll := cmd.Lookup("aggregate").StringValue(); coll != "archive" {
+			t.Errorf("sampled collection %q, want archive", coll)
+		}
+		stages, _ := cmd.Lookup("pipeline").Array().Values()
+		excluded, _ := stages[0].Document().Lookup("$match", "type", "$nin").Array().Values()
+		if len(excluded) != 2 || excluded[0].AsInt64() != 0 || excluded[1].AsInt64() != 2 {
+			t.Errorf("first stage %s, want received copies and drafts excluded", stages[0])
+		}
+		if size := stages[1].Document().Lookup("$sample", "size").AsInt64(); size != 50 {
+			t.Errorf("$sample size = %d, want 50", size)
+		}
+	})
+
+	mt.Run("invalid source", func(mt *mtest.T) {
+		m := &MongoDB{Client: mt.Client, Database: mt.DB, MailsCollection: DefaultMailsCollection}
+		for _, collection := range []string{"", DefaultMailsCollection} {
+			if _, err := m.SampleMails(context.Background(), collection, 10); err == nil {
+				t.Errorf("SampleMails(%q) accepted", collection)
+			}
+		}
+	})
+}
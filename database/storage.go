@@ -0,0 +1,65 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// StorageStats runs collStats on the mails and threads collections, and on
+// the archive when one is configured, so the storage cost of each indexing
+// strategy can be weighed against its latency
+func (m *MongoDB) StorageStats(ctx context.Context) ([]*CollectionStats, error) {
+	collections := []string{m.MailsCollection, m.ThreadsCollection}
+	if m.ArchiveCollection != "" {
+		collections = append(collections, m.ArchiveCollection)
+	}
+
+	stats := make([]*CollectionStats, 0, len(collections))
+	for _, collection := range collections {
+		s, err := m.CollectionStats(ctx, collection)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", collection, err)
+		}
+		stats = append(stats, s)
+	}
+	return stats, nil
+}
+
+// String summarizes the collection's footprint with indexes largest first
+func (s *CollectionStats) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %d docs, data %s (avg %d B/doc), storage %s, indexes %s",
+		s.Name, s.Count, formatBytes(s.SizeBytes), s.AvgObjSizeBytes,
+		formatBytes(s.StorageSizeBytes), formatBytes(s.IndexSizeBytes))
+
+	names := make([]string, 0, len(s.IndexSizes))
+	for name := range s.IndexSizes {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if s.IndexSizes[names[i]] != s.IndexSizes[names[j]] {
+			return s.IndexSizes[names[i]] > s.IndexSizes[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	for _, name := range names {
+		fmt.Fprintf(&b, "\n    %-40s %s", name, formatBytes(s.IndexSizes[name]))
+	}
+	return b.String()
+}
+
+// formatBytes renders a byte count with a binary unit
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,131 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"mail-stress-test/internal/mongotest"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// TestStorageStats answers collStats for every collection against a mock
+// and checks the archive is included when configured and indexes are
+// listed largest first
+func TestStorageStats(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("with archive", func(mt *mtest.T) {
+		m := newTestDB(mt.DB)
+		m.SetArchiveCollection("mails_archive")
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(
+				bson.E{Key: "count", Value: int32(1000)},
+				bson.E{Key: "size", Value: int32(512000)},
+				bson.E{Key: "avgObjSize", Value: int32(512)},
+				bson.E{Key: "storageSize", Value: int32(262144)},
+				bson.E{Key: "totalIndexSize", Value: int64(3 << 20)},
+				bson.E{Key: "indexSizes", Value: bson.D{
+					{Key: "_id_", Value: int32(36864)},
+					{Key: "subject_text_content_text", Value: int64(3<<20 - 36864)},
+				}},
+			),
+			collStatsReply(10, 2000, 4096, 0),
+			collStatsReply(0, 0, 4096, 0),
+		)
+
+		stats, err := m.StorageStats(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, event := range mt.GetAllStartedEvents() {
+			names = append(names, event.Command.Lookup("collStats").StringValue())
+		}
+		if got := strings.Join(names, ","); got != "mails,threads,mails_archive" {
+			t.Errorf("collStats sent for %s, want mails, threads and the archive", got)
+		}
+		if len(stats) != 3 || stats[0].AvgObjSizeBytes != 512 || stats[0].IndexSizes["subject_text_content_text"] != 3<<20-36864 {
+			t.Fatalf("stats = %+v", stats)
+		}
+
+		out := stats[0].String()
+		text, id := strings.Index(out, "subject_text_content_text"), strings.Index(out, "_id_")
+		if !strings.Contains(out, "indexes 3.0 MiB") || !strings.Contains(out, "avg 512 B/doc") || text > id {
+			t.Errorf("String() = %q, want the sizes with the text index listed first", out)
+		}
+	})
+
+	mt.Run("collStats fails", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 13, Name: "Unauthorized", Message: "not authorized"}))
+		if _, err := newTestDB(mt.DB).StorageStats(context.Background()); err == nil || !strings.Contains(err.Error(), "mails") {
+			t.Errorf("err = %v, want the failing collection named", err)
+		}
+	})
+}
+
+func TestFormatBytes(t *testing.T) {
+	for n, want := range map[int64]string{512: "512 B", 1536: "1.5 KiB", 3 << 20: "3.0 MiB", 5 << 30: "5.0 GiB"} {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+// TestStorageStatsIntegration seeds mails and threads, builds the indexes
+// and checks the reported sizes are populated and consistent
+func TestStorageStatsIntegration(t *testing.T) {
+	m := newTestDB(mongotest.Database(t))
+	ctx := context.Background()
+
+	const mails = 2000
+	docs := make([]interface{}, mails)
+	for i := range docs {
+		docs[i] = bson.D{
+			{Key: "userId", Value: fmt.Sprintf("user-%d", i%50)},
+			{Key: "subject", Value: fmt.Sprintf("Weekly Report %d", i)},
+			{Key: "content", Value: strings.Repeat("status update ", 20)},
+		}
+	}
+	if _, err := m.Mails().InsertMany(ctx, docs); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Threads().InsertOne(ctx, bson.D{{Key: "user_id", Value: "user-1"}, {Key: "thread_id", Value: "t-1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.CreateIndexes(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := m.StorageStats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 2 {
+		t.Fatalf("%d collections reported, want mails and threads", len(stats))
+	}
+	mailStats := stats[0]
+	if mailStats.Count != mails || mailStats.AvgObjSizeBytes < 300 || mailStats.AvgObjSizeBytes > 1000 {
+		t.Errorf("mails: %d docs averaging %d B, want %d docs of about 400 B", mailStats.Count, mailStats.AvgObjSizeBytes, mails)
+	}
+	if mailStats.SizeBytes < mailStats.Count*mailStats.AvgObjSizeBytes-mailStats.Count {
+		t.Errorf("data size %d B is below %d docs of %d B", mailStats.SizeBytes, mailStats.Count, mailStats.AvgObjSizeBytes)
+	}
+	var indexTotal int64
+	for name, size := range mailStats.IndexSizes {
+		if size <= 0 {
+			t.Errorf("index %s has size %d", name, size)
+		}
+		indexTotal += size
+	}
+	if len(mailStats.IndexSizes) < 10 || indexTotal != mailStats.IndexSizeBytes {
+		t.Errorf("%d indexes totalling %d B, want every created index summing to %d B",
+			len(mailStats.IndexSizes), indexTotal, mailStats.IndexSizeBytes)
+	}
+	if stats[1].Count != 1 || stats[1].IndexSizes["user_id_1"] <= 0 {
+		t.Errorf("threads stats = %+v", stats[1])
+	}
+}
@@ -0,0 +1,130 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ThreadDistribution summarizes how many mails accumulate per thread
+type ThreadDistribution struct {
+	Threads   int64          `json:"threads"`
+	Mails     int64          `json:"mails"`
+	Min       int            `json:"min"`
+	Max       int            `json:"max"`
+	Avg       float64        `json:"avg"`
+	P95       int            `json:"p95"`
+	Histogram []ThreadBucket `json:"histogram"`
+}
+
+// ThreadBucket counts threads whose size falls in [Min, Max]; Max 0 is unbounded
+type ThreadBucket struct {
+	Label   string `json:"label"`
+	Min     int    `json:"min"`
+	Max     int    `json:"max"`
+	Threads int64  `json:"threads"`
+}
+
+// threadBucketBounds are the inclusive upper bounds of the histogram buckets
+var threadBucketBounds = []int{1, 2, 5, 10, 20, 50, 100}
+
+// threadSizeCount is one row of the size aggregation: Threads threads hold Size mails
+type threadSizeCount struct {
+	Size    int   `bson:"_id"`
+	Threads int64 `bson:"threads"`
+}
+
+// ThreadDistribution aggregates total_mails over the threads collection.
+// The server groups threads by size, so only one row per distinct size is
+// transferred.
+func (m *MongoDB) ThreadDistribution(ctx context.Context) (*ThreadDistribution, error) {
+	pipeline := bson.A{
+		bson.M{"$group": bson.M{"_id": "$total_mails", "threads": bson.M{"$sum": 1}}},
+		bson.M{"$sort": bson.M{"_id": 1}},
+	}
+
+	cursor, err := m.Threads().Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, fmt.Errorf("thread distribution aggregation failed: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var sizes []threadSizeCount
+	if err := cursor.All(ctx, &sizes); err != nil {
+		return nil, err
+	}
+
+	return summarizeThreadSizes(sizes), nil
+}
+
+// summarizeThreadSizes computes the distribution from size counts sorted by size
+func summarizeThreadSizes(sizes []threadSizeCount) *ThreadDistribution {
+	dist := &ThreadDistribution{Histogram: newThreadHistogram()}
+	for _, s := range sizes {
+		dist.Threads += s.Threads
+		dist.Mails += int64(s.Size) * s.Threads
+		dist.Histogram[threadBucketIndex(s.Size)].Threads += s.Threads
+	}
+	if dist.Threads == 0 {
+		return dist
+	}
+
+	dist.Min = sizes[0].Size
+	dist.Max = sizes[len(sizes)-1].Size
+	dist.Avg = float64(dist.Mails) / float64(dist.Threads)
+
+	// Nearest-rank P95 over the grouped counts
+	rank := (dist.Threads*95 + 99) / 100
+	var cumulative int64
+	for _, s := range sizes {
+		cumulative += s.Threads
+		if cumulative >= rank {
+			dist.P95 = s.Size
+			break
+		}
+	}
+
+	return dist
+}
+
+func newThreadHistogram() []ThreadBucket {
+	buckets := make([]ThreadBucket, 0, len(threadBucketBounds)+1)
+	lower := 0
+	for _, upper := range threadBucketBounds {
+		label := fmt.Sprintf("%d-%d", lower+1, upper)
+		if lower+1 == upper {
+			label = fmt.Sprintf("%d", upper)
+		}
+		buckets = append(buckets, ThreadBucket{Label: label, Min: lower + 1, Max: upper})
+		lower = upper
+	}
+	return append(buckets, ThreadBucket{Label: fmt.Sprintf(">%d", lower), Min: lower + 1})
+}
+
+func threadBucketIndex(size int) int {
+	for i, upper := range threadBucketBounds {
+		if size <= upper {
+			return i
+		}
+	}
+	return len(threadBucketBounds)
+}
+
+// String renders the distribution with a bar per histogram bucket
+func (d *ThreadDistribution) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Threads: %d, Mails: %d, Mails/thread: min %d, avg %.1f, P95 %d, max %d\n",
+		d.Threads, d.Mails, d.Min, d.Avg, d.P95, d.Max)
+	for _, bucket := range d.Histogram {
+		percent := 0.0
+		if d.Threads > 0 {
+			percent = float64(bucket.Threads) / float64(d.Threads) * 100
+		}
+		fmt.Fprintf(&b, "  %8s: %8d (%5.1f%%) %s\n", bucket.Label, bucket.Threads, percent,
+			strings.Repeat("█", int(percent/2)))
+	}
+	return b.String()
+}
@@ -0,0 +1,64 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// TestThreadDistribution answers the size aggregation with a known mix of
+// thread sizes and checks the summary statistics and histogram
+func TestThreadDistribution(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("grouped sizes", func(mt *mtest.T) {
+		m := &MongoDB{Client: mt.Client, Database: mt.DB, ThreadsCollection: DefaultThreadsCollection}
+		size := func(mails, threads int) bson.D {
+			return bson.D{{Key: "_id", Value: mails}, {Key: "threads", Value: threads}}
+		}
+		// 100 threads: 60 single mails, 30 of 4, 9 of 12, one of 250
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, mt.DB.Name()+"."+DefaultThreadsCollection, mtest.FirstBatch,
+			size(1, 60), size(4, 30), size(12, 9), size(250, 1)))
+
+		dist, err := m.ThreadDistribution(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		group := mt.GetStartedEvent().Command.Lookup("pipeline").Array().Index(0).Value().Document()
+		if group.Lookup("$group", "_id").StringValue() != "$total_mails" {
+			t.Errorf("pipeline starts with %s, want a $group on total_mails", group)
+		}
+
+		if dist.Threads != 100 || dist.Mails != 60+120+108+250 {
+			t.Errorf("%d threads, %d mails; want 100 and 538", dist.Threads, dist.Mails)
+		}
+		if dist.Min != 1 || dist.Max != 250 || dist.Avg != 5.38 || dist.P95 != 12 {
+			t.Errorf("min %d, avg %.2f, P95 %d, max %d; want 1, 5.38, 12, 250", dist.Min, dist.Avg, dist.P95, dist.Max)
+		}
+		want := map[string]int64{"1": 60, "3-5": 30, "11-20": 9, ">100": 1}
+		for _, bucket := range dist.Histogram {
+			if bucket.Threads != want[bucket.Label] {
+				t.Errorf("bucket %s holds %d threads, want %d", bucket.Label, bucket.Threads, want[bucket.Label])
+			}
+		}
+		if out := dist.String(); !strings.Contains(out, "Threads: 100") || !strings.Contains(out, "60.0%") {
+			t.Errorf("String() = %q", out)
+		}
+	})
+
+	mt.Run("no threads", func(mt *mtest.T) {
+		m := &MongoDB{Client: mt.Client, Database: mt.DB, ThreadsCollection: DefaultThreadsCollection}
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, mt.DB.Name()+"."+DefaultThreadsCollection, mtest.FirstBatch))
+
+		dist, err := m.ThreadDistribution(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dist.Threads != 0 || dist.Max != 0 || len(dist.Histogram) != len(threadBucketBounds)+1 {
+			t.Errorf("empty distribution = %+v", dist)
+		}
+	})
+}
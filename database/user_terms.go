@@ -0,0 +1,47 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// userSubjects is one row of the per-user subject aggregation
+type userSubjects struct {
+	UserID   string   `bson:"_id"`
+	Subjects []string `bson:"subjects"`
+}
+
+// UserSubjects returns up to perUser distinct subjects from each of the
+// given users' mailboxes, so search terms can be drawn from what a user
+// actually has. Users without mails are absent from the result.
+func (m *MongoDB) UserSubjects(ctx context.Context, userIDs []string, perUser int) (map[string][]string, error) {
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{"userId": bson.M{"$in": userIDs}}},
+		bson.M{"$group": bson.M{"_id": "$userId", "subjects": bson.M{"$addToSet": "$subject"}}},
+	}
+	if perUser > 0 {
+		pipeline = append(pipeline, bson.M{"$project": bson.M{"subjects": bson.M{"$slice": bson.A{"$subjects", perUser}}}})
+	}
+
+	cursor, err := m.Mails().Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, fmt.Errorf("user subject aggregation failed: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var rows []userSubjects
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+
+	subjects := make(map[string][]string, len(rows))
+	for _, row := range rows {
+		if len(row.Subjects) > 0 {
+			subjects[row.UserID] = row.Subjects
+		}
+	}
+	return subjects, nil
+}
@@ -0,0 +1,82 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MailSchema is the $jsonSchema validator for mail documents: the fields
+// every mail copy carries are required and typed, optional ones are typed
+var MailSchema = bson.M{
+	"bsonType": "object",
+	"required": bson.A{"from", "to", "subject", "content", "type", "threadId", "userId", "createdAt"},
+	"properties": bson.M{
+		"from":      bson.M{"bsonType": "string"},
+		"to":        bson.M{"bsonType": "array", "items": bson.M{"bsonType": "string"}},
+		"cc":        bson.M{"bsonType": "array", "items": bson.M{"bsonType": "string"}},
+		"bcc":       bson.M{"bsonType": "array", "items": bson.M{"bsonType": "string"}},
+		"subject":   bson.M{"bsonType": "string"},
+		"content":   bson.M{"bsonType": "string"},
+		"type":      bson.M{"bsonType": bson.A{"int", "long"}, "enum": bson.A{0, 1, 2}},
+		"replyTo":   bson.M{"bsonType": "string"},
+		"threadId":  bson.M{"bsonType": "string"},
+		"userId":    bson.M{"bsonType": "string"},
+		"createdAt": bson.M{"bsonType": "date"},
+		"isRead":    bson.M{"bsonType": "bool"},
+		"deletedAt": bson.M{"bsonType": "date"},
+	},
+}
+
+// CreateValidatedCollection creates collection with MailSchema as its
+// validator, rejecting invalid inserts and updates
+func (m *MongoDB) CreateValidatedCollection(ctx context.Context, collection string) error {
+	opts := options.CreateCollection().
+		SetValidator(bson.M{"$jsonSchema": MailSchema}).
+		SetValidationLevel("strict").
+		SetValidationAction("error")
+	if err := m.Database.CreateCollection(ctx, collection, opts); err != nil {
+		return fmt.Errorf("failed to create %s with schema validation: %w", collection, err)
+	}
+	return nil
+}
+
+// EnableMailsValidation puts MailSchema on the mails collection: created
+// with the validator when it doesn't exist yet, or added with collMod so an
+// existing dataset is kept
+func (m *MongoDB) EnableMailsValidation(ctx context.Context) error {
+	names, err := m.Database.ListCollectionNames(ctx, bson.M{"name": m.MailsCollection})
+	if err != nil {
+		return fmt.Errorf("failed to list collections: %w", err)
+	}
+	if len(names) == 0 {
+		return m.CreateValidatedCollection(ctx, m.MailsCollection)
+	}
+
+	cmd := bson.D{
+		{Key: "collMod", Value: m.MailsCollection},
+		{Key: "validator", Value: bson.M{"$jsonSchema": MailSchema}},
+		{Key: "validationLevel", Value: "strict"},
+		{Key: "validationAction", Value: "error"},
+	}
+	if err := m.Database.RunCommand(ctx, cmd).Err(); err != nil {
+		return fmt.Errorf("failed to add schema validation to %s: %w", m.MailsCollection, err)
+	}
+	return nil
+}
+
+// HasValidator reports whether collection was created or modified with a
+// validator
+func (m *MongoDB) HasValidator(ctx context.Context, collection string) (bool, error) {
+	specs, err := m.Database.ListCollectionSpecifications(ctx, bson.M{"name": collection})
+	if err != nil {
+		return false, fmt.Errorf("failed to list collections: %w", err)
+	}
+	if len(specs) == 0 {
+		return false, fmt.Errorf("collection %s does not exist", collection)
+	}
+	_, err = specs[0].Options.LookupErr("validator")
+	return err == nil, nil
+}
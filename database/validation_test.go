@@ -0,0 +1,84 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"mail-stress-test/internal/mongotest"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// TestEnableMailsValidationCommands checks a missing mails collection is
+// created with the schema and an existing one gets it through collMod
+func TestEnableMailsValidationCommands(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	tests := []struct {
+		name     string
+		existing []bson.D
+		command  string
+	}{
+		{name: "missing", command: "create"},
+		{name: "existing", existing: []bson.D{{{Key: "name", Value: DefaultMailsCollection}, {Key: "type", Value: "collection"}}}, command: "collMod"},
+	}
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			m := &MongoDB{Client: mt.Client, Database: mt.DB, MailsCollection: DefaultMailsCollection}
+			mt.AddMockResponses(
+				mtest.CreateCursorResponse(0, mt.DB.Name()+".$cmd.listCollections", mtest.FirstBatch, tt.existing...),
+				mtest.CreateSuccessResponse(),
+			)
+			if err := m.EnableMailsValidation(context.Background()); err != nil {
+				mt.Fatal(err)
+			}
+
+			events := mt.GetAllStartedEvents()
+			if len(events) != 2 || events[1].CommandName != tt.command {
+				mt.Fatalf("sent %d commands, want listCollections then %s", len(events), tt.command)
+			}
+			cmd := events[1].Command
+			if cmd.Lookup(tt.command).StringValue() != DefaultMailsCollection {
+				mt.Errorf("%s targets %s, want %s", tt.command, cmd.Lookup(tt.command), DefaultMailsCollection)
+			}
+			if _, err := cmd.LookupErr("validator", "$jsonSchema", "required"); err != nil {
+				mt.Errorf("%s has no $jsonSchema validator: %s", tt.command, cmd)
+			}
+			if level := cmd.Lookup("validationLevel").StringValue(); level != "strict" {
+				mt.Errorf("validationLevel = %q, want strict", level)
+			}
+		})
+	}
+}
+
+// TestMailsValidationIntegration creates the validated mails collection and
+// checks the validator is found, a complete mail is accepted and one missing
+// required fields is rejected
+func TestMailsValidationIntegration(t *testing.T) {
+	m := newTestDB(mongotest.Database(t))
+	ctx := context.Background()
+	if err := m.EnableMailsValidation(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if active, err := m.HasValidator(ctx, m.MailsCollection); err != nil || !active {
+		t.Fatalf("HasValidator = %v, %v; want true", active, err)
+	}
+
+	valid := bson.M{
+		"from": "user-1", "to": bson.A{"user-2"}, "subject": "hello", "content": "body", "type": 1,
+		"threadId": "thread-1", "userId": "user-1", "createdAt": time.Now(),
+	}
+	if _, err := m.Mails().InsertOne(ctx, valid); err != nil {
+		t.Errorf("complete mail rejected: %v", err)
+	}
+
+	_, err := m.Mails().InsertOne(ctx, bson.M{"from": "user-1", "type": 7})
+	var writeErr mongo.WriteException
+	if !errors.As(err, &writeErr) || len(writeErr.WriteErrors) == 0 || writeErr.WriteErrors[0].Code != 121 {
+		t.Errorf("invalid mail insert = %v, want a DocumentValidationFailure", err)
+	}
+}
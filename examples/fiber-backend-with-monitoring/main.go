@@ -141,7 +141,8 @@ func main() {
 	fiberApp.Use(recover.New())
 	fiberApp.Use(cors.New())
 	fiberApp.Use(logger.New(logger.Config{
-		Format: "[${time}] ${status} - ${latency} ${method} ${path}\n",
+		// X-Request-Id is set per request by the stress tool's API handler
+		Format: "[${time}] ${status} - ${latency} ${method} ${path} request_id=${reqHeader:X-Request-Id}\n",
 	}))
 
 	// Prometheus middleware
@@ -150,6 +151,7 @@ func main() {
 	// Routes
 	fiberApp.Get("/health", healthHandler)
 	fiberApp.Get("/metrics", adaptor.HTTPHandler(promhttp.Handler()))
+	fiberApp.Get("/openapi.json", openAPIHandler)
 
 	// API routes
 	api := fiberApp.Group("/api")
@@ -161,6 +163,7 @@ func main() {
 	log.Println("🚀 Server starting on :3000")
 	log.Println("📊 Metrics available at http://localhost:3000/metrics")
 	log.Println("💚 Health check at http://localhost:3000/health")
+	log.Println("📄 OpenAPI spec at http://localhost:3000/openapi.json")
 
 	if err := fiberApp.Listen(":3000"); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
@@ -208,6 +211,11 @@ func healthHandler(c *fiber.Ctx) error {
 	})
 }
 
+// openAPIHandler serves the OpenAPI 3 description of this API
+func openAPIHandler(c *fiber.Ctx) error {
+	return c.JSON(openAPISpec())
+}
+
 func (app *App) createMailHandler(c *fiber.Ctx) error {
 	var req CreateMailRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// openAPISpec describes the backend's endpoints as an OpenAPI 3 document. The
+// request and mail schemas are derived from the Go structs so they follow
+// the json tags.
+func openAPISpec() map[string]interface{} {
+	mailRef := map[string]interface{}{"$ref": "#/components/schemas/Mail"}
+	errorResponse := jsonResponse("Error", map[string]interface{}{"$ref": "#/components/schemas/Error"})
+
+	pageParams := []interface{}{
+		queryParam("userId", "string", true, "Owner of the mails"),
+		queryParam("page", "integer", false, "1-based page number (default 1)"),
+		queryParam("limit", "integer", false, "Page size (default 20)"),
+	}
+	mailPage := func(extra map[string]interface{}) map[string]interface{} {
+		props := map[string]interface{}{
+			"data":  map[string]interface{}{"type": "array", "items": mailRef},
+			"page":  map[string]interface{}{"type": "integer"},
+			"limit": map[string]interface{}{"type": "integer"},
+			"total": map[string]interface{}{"type": "integer"},
+		}
+		for k, v := range extra {
+			props[k] = v
+		}
+		return map[string]interface{}{"type": "object", "properties": props}
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "Mail Stress Test Backend",
+			"version": "1.0.0",
+		},
+		"paths": map[string]interface{}{
+			"/api/mails": map[string]interface{}{
+				"post": map[string]interface{}{
+					"operationId": "createMail",
+					"summary":     "Create a mail",
+					"requestBody": map[string]interface{}{
+						"required": true,
+						"content": map[string]interface{}{
+							"application/json": map[string]interface{}{
+								"schema": map[string]interface{}{"$ref": "#/components/schemas/CreateMailRequest"},
+							},
+						},
+					},
+					"responses": map[string]interface{}{
+						"201": jsonResponse("Created mail", mailRef),
+						"400": errorResponse,
+						"500": errorResponse,
+					},
+				},
+				"get": map[string]interface{}{
+					"operationId": "listMails",
+					"summary":     "List a user's mails, newest first",
+					"parameters":  pageParams,
+					"responses": map[string]interface{}{
+						"200": jsonResponse("Page of mails", mailPage(nil)),
+						"400": errorResponse,
+						"500": errorResponse,
+					},
+				},
+			},
+			"/api/mails/search": map[string]interface{}{
+				"get": map[string]interface{}{
+					"operationId": "searchMails",
+					"summary":     "Search a user's mails by subject or content (case-insensitive)",
+					"parameters": append([]interface{}{
+						queryParam("query", "string", true, "Search term"),
+					}, pageParams...),
+					"responses": map[string]interface{}{
+						"200": jsonResponse("Page of matching mails", mailPage(map[string]interface{}{
+							"query": map[string]interface{}{"type": "string"},
+						})),
+						"400": errorResponse,
+						"500": errorResponse,
+					},
+				},
+			},
+			"/health": map[string]interface{}{
+				"get": map[string]interface{}{
+					"operationId": "health",
+					"summary":     "Liveness check",
+					"responses": map[string]interface{}{
+						"200": jsonResponse("Server is up", map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"status":    map[string]interface{}{"type": "string"},
+								"timestamp": map[string]interface{}{"type": "string", "format": "date-time"},
+								"uptime":    map[string]interface{}{"type": "string"},
+							},
+						}),
+					},
+				},
+			},
+			"/metrics": map[string]interface{}{
+				"get": map[string]interface{}{
+					"operationId": "metrics",
+					"summary":     "Prometheus metrics",
+					"responses": map[string]interface{}{
+						"200": map[string]interface{}{
+							"description": "Prometheus text exposition format",
+							"content": map[string]interface{}{
+								"text/plain": map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+							},
+						},
+					},
+				},
+			},
+		},
+		"components": map[string]interface{}{
+			"schemas": map[string]interface{}{
+				"Mail":              structSchema(reflect.TypeOf(Mail{})),
+				"CreateMailRequest": withRequired(structSchema(reflect.TypeOf(CreateMailRequest{})), "userId", "from", "to", "subject"),
+				"Error": map[string]interface{}{
+					"type":       "object",
+					"properties": map[string]interface{}{"error": map[string]interface{}{"type": "string"}},
+				},
+			},
+		},
+	}
+}
+
+// jsonResponse is a response with an application/json body
+func jsonResponse(description string, schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"description": description,
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": schema},
+		},
+	}
+}
+
+// queryParam describes a query string parameter
+func queryParam(name, typ string, required bool, description string) map[string]interface{} {
+	return map[string]interface{}{
+		"name":        name,
+		"in":          "query",
+		"required":    required,
+		"description": description,
+		"schema":      map[string]interface{}{"type": typ},
+	}
+}
+
+// withRequired marks fields of an object schema as required
+func withRequired(schema map[string]interface{}, fields ...string) map[string]interface{} {
+	schema["required"] = fields
+	return schema
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// structSchema builds an object schema from the json tags of t's fields
+func structSchema(t reflect.Type) map[string]interface{} {
+	props := make(map[string]interface{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		props[name] = typeSchema(field.Type)
+	}
+	return map[string]interface{}{"type": "object", "properties": props}
+}
+
+// typeSchema maps a Go field type to its JSON schema. ObjectIDs marshal as
+// hex strings.
+func typeSchema(t reflect.Type) map[string]interface{} {
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Array && t.Elem().Kind() == reflect.Uint8:
+		return map[string]interface{}{"type": "string", "pattern": "^[0-9a-f]{24}$"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Struct:
+		return structSchema(t)
+	}
+	return map[string]interface{}{}
+}
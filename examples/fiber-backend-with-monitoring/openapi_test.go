@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// TestOpenAPISpecCoversRoutes checks every route the server registers is
+// described and the spec survives a JSON round trip
+func TestOpenAPISpecCoversRoutes(t *testing.T) {
+	data, err := json.Marshal(openAPISpec())
+	if err != nil {
+		t.Fatalf("spec does not marshal: %v", err)
+	}
+	var spec struct {
+		OpenAPI string                                       `json:"openapi"`
+		Paths   map[string]map[string]map[string]interface{} `json:"paths"`
+	}
+	if err := json.Unmarshal(data, &spec); err != nil {
+		t.Fatal(err)
+	}
+	if spec.OpenAPI != "3.0.3" {
+		t.Errorf("openapi = %q, want 3.0.3", spec.OpenAPI)
+	}
+
+	routes := map[string][]string{
+		"/health":           {"get"},
+		"/metrics":          {"get"},
+		"/api/mails":        {"get", "post"},
+		"/api/mails/search": {"get"},
+	}
+	for path, methods := range routes {
+		for _, method := range methods {
+			op, ok := spec.Paths[path][method]
+			if !ok {
+				t.Errorf("%s %s is not described", method, path)
+				continue
+			}
+			if op["operationId"] == "" || op["responses"] == nil {
+				t.Errorf("%s %s has no operationId or responses: %v", method, path, op)
+			}
+		}
+	}
+}
+
+// TestSchemasFollowJSONTags checks the derived schemas use the json field
+// names and types the handlers actually encode
+func TestSchemasFollowJSONTags(t *testing.T) {
+	schemas := openAPISpec()["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+
+	mail := schemas["Mail"].(map[string]interface{})["properties"].(map[string]interface{})
+	for name, want := range map[string]string{"id": "string", "userId": "string", "to": "array", "createdAt": "string"} {
+		prop, ok := mail[name].(map[string]interface{})
+		if !ok || prop["type"] != want {
+			t.Errorf("Mail.%s = %v, want type %s", name, mail[name], want)
+		}
+	}
+	if mail["createdAt"].(map[string]interface{})["format"] != "date-time" {
+		t.Errorf("Mail.createdAt = %v, want a date-time", mail["createdAt"])
+	}
+	if _, ok := mail["UserId"]; ok {
+		t.Error("Mail schema uses the Go field name UserId")
+	}
+
+	create := schemas["CreateMailRequest"].(map[string]interface{})
+	props := create["properties"].(map[string]interface{})
+	for _, field := range create["required"].([]string) {
+		if _, ok := props[field]; !ok {
+			t.Errorf("required field %s is not a CreateMailRequest property", field)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package generator
+
+import "mail-stress-test/models"
+
+// UserAnonymizer maps real user IDs onto the generated user IDs. The mapping
+// is consistent, so a real user keeps one pseudonym across every sampled
+// mail, and distinct users get distinct pseudonyms until the generated IDs
+// run out, after which they are reused round-robin.
+type UserAnonymizer struct {
+	userIDs []string
+	mapping map[string]string
+}
+
+// NewUserAnonymizer creates an anonymizer drawing pseudonyms from userIDs
+func NewUserAnonymizer(userIDs []string) *UserAnonymizer {
+	return &UserAnonymizer{
+		userIDs: userIDs,
+		mapping: make(map[string]string),
+	}
+}
+
+// userID returns the pseudonym for a real user ID
+func (a *UserAnonymizer) userID(real string) string {
+	if id, ok := a.mapping[real]; ok {
+		return id
+	}
+	id := a.userIDs[len(a.mapping)%len(a.userIDs)]
+	a.mapping[real] = id
+	return id
+}
+
+// recipients maps a recipient list, dropping the sender and duplicates that
+// can appear once pseudonyms are reused
+func (a *UserAnonymizer) recipients(real []string, from string, seen map[string]bool) []string {
+	var ids []string
+	for _, r := range real {
+		id := a.userID(r)
+		if id == from || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// MailRequest turns a sampled mail into a create request with anonymized
+// participants, keeping the real subject and content
+func (a *UserAnonymizer) MailRequest(mail *models.Mail) *models.MailRequest {
+	from := a.userID(mail.From)
+	seen := make(map[string]bool)
+	return &models.MailRequest{
+		From:    from,
+		To:      a.recipients(mail.To, from, seen),
+		Cc:      a.recipients(mail.Cc, from, seen),
+		Bcc:     a.recipients(mail.Bcc, from, seen),
+		Subject: mail.Subject,
+		Content: mail.Content,
+	}
+}
+
+// Users returns how many distinct real users have been mapped
+func (a *UserAnonymizer) Users() int {
+	return len(a.mapping)
+}
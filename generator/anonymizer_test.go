@@ -0,0 +1,61 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+
+	"mail-stress-test/models"
+)
+
+// TestUserAnonymizerConsistent maps mails among real users and checks every
+// real ID is replaced, each real user keeps one pseudonym across mails, and
+// subject and content survive unchanged
+func TestUserAnonymizerConsistent(t *testing.T) {
+	a := NewUserAnonymizer([]string{"user-1", "user-2", "user-3", "user-4"})
+	first := a.MailRequest(&models.Mail{
+		From: "alice@corp", To: []string{"bob@corp"}, Cc: []string{"carol@corp"},
+		Subject: "Q3 numbers", Content: "see attached",
+	})
+	reply := a.MailRequest(&models.Mail{From: "bob@corp", To: []string{"alice@corp"}, Bcc: []string{"carol@corp"}})
+
+	if first.From != "user-1" || first.To[0] != "user-2" || first.Cc[0] != "user-3" {
+		t.Errorf("first mail mapped to %s -> %v cc %v, want user-1 -> [user-2] cc [user-3]", first.From, first.To, first.Cc)
+	}
+	if reply.From != first.To[0] || reply.To[0] != first.From || reply.Bcc[0] != first.Cc[0] {
+		t.Errorf("reply mapped to %s -> %v bcc %v, want the same pseudonyms as the first mail", reply.From, reply.To, reply.Bcc)
+	}
+	if first.Subject != "Q3 numbers" || first.Content != "see attached" {
+		t.Errorf("subject/content = %q/%q, want them kept", first.Subject, first.Content)
+	}
+	for _, req := range []*models.MailRequest{first, reply} {
+		for _, id := range append(append(append([]string{req.From}, req.To...), req.Cc...), req.Bcc...) {
+			if strings.Contains(id, "@corp") {
+				t.Errorf("real user ID %q leaked", id)
+			}
+		}
+	}
+	if a.Users() != 3 {
+		t.Errorf("Users() = %d, want 3", a.Users())
+	}
+}
+
+// TestUserAnonymizerReusedPseudonyms maps more real users than generated IDs
+// and checks reused pseudonyms never make the sender its own recipient or
+// repeat a recipient
+func TestUserAnonymizerReusedPseudonyms(t *testing.T) {
+	a := NewUserAnonymizer([]string{"user-1", "user-2"})
+	req := a.MailRequest(&models.Mail{
+		From: "a", To: []string{"b", "c"}, Cc: []string{"d"}, Bcc: []string{"e"},
+	})
+
+	seen := map[string]bool{req.From: true}
+	for _, id := range append(append(append([]string{}, req.To...), req.Cc...), req.Bcc...) {
+		if seen[id] {
+			t.Errorf("pseudonym %s appears twice in %+v", id, req)
+		}
+		seen[id] = true
+	}
+	if len(req.To) != 1 || req.To[0] != "user-2" {
+		t.Errorf("To = %v, want [user-2]", req.To)
+	}
+}
@@ -0,0 +1,68 @@
+package generator
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"mail-stress-test/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Mail types stored in Mail.Type, as written by DBHandler.CreateMail
+const (
+	mailTypeReceived = 0
+	mailTypeSent     = 1
+)
+
+// SetSentRatio sets the share of GenerateMail documents that are the
+// sender's copy (Type 1); the rest are a recipient's copy (Type 0). Zero
+// uses the share CreateMail's fan-out produces: one sent copy per mail plus
+// one received copy per recipient.
+func (g *DataGenerator) SetSentRatio(ratio float64) error {
+	if ratio < 0 || ratio > 1 {
+		return fmt.Errorf("invalid sent ratio %g: need 0 <= ratio <= 1", ratio)
+	}
+	g.sentRatio = ratio
+	return nil
+}
+
+// sentRatioOrDefault returns the configured sent share, or the fan-out share
+// for the recipient range
+func (g *DataGenerator) sentRatioOrDefault() float64 {
+	if g.sentRatio > 0 {
+		return g.sentRatio
+	}
+	min, max := g.minRecipients, g.maxRecipients
+	if min == 0 {
+		min, max = DefaultMinRecipients, DefaultMaxRecipients
+	}
+	return 1 / (1 + float64(min+max)/2)
+}
+
+// GenerateMail generates one standalone mail document as stored in a
+// mailbox: the sender's copy (Type 1, owned by the sender) or one
+// recipient's copy (Type 0, owned by that recipient), split by the sent
+// ratio. Unlike GenerateCreateMailRequest it needs no handler to fan out.
+func (g *DataGenerator) GenerateMail() *models.Mail {
+	req := g.GenerateCreateMailRequest("")
+	mail := &models.Mail{
+		ID:        primitive.NewObjectID(),
+		From:      req.From,
+		To:        req.To,
+		Cc:        req.Cc,
+		Bcc:       req.Bcc,
+		Subject:   req.Subject,
+		Content:   req.Content,
+		Type:      mailTypeSent,
+		ThreadID:  primitive.NewObjectID().Hex(),
+		UserID:    req.From,
+		CreatedAt: time.Now(),
+	}
+	if rand.Float64() >= g.sentRatioOrDefault() {
+		mail.Type = mailTypeReceived
+		mail.UserID = req.To[rand.Intn(len(req.To))]
+	}
+	return mail
+}
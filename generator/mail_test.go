@@ -0,0 +1,54 @@
+package generator
+
+import (
+	"math"
+	"testing"
+)
+
+// TestGenerateMailTypes checks sender copies are Type 1 owned by the sender,
+// recipient copies are Type 0 owned by one of the recipients, and the split
+// follows the sent ratio
+func TestGenerateMailTypes(t *testing.T) {
+	const samples = 10000
+	for _, ratio := range []float64{0, 0.8} {
+		gen := newTestGenerator(t)
+		if err := gen.SetSentRatio(ratio); err != nil {
+			t.Fatal(err)
+		}
+		// Zero follows CreateMail's fan-out: 1 sent copy per 2 recipients on average
+		want := ratio
+		if want == 0 {
+			want = 1.0 / 3
+		}
+
+		sent := 0
+		for i := 0; i < samples; i++ {
+			mail := gen.GenerateMail()
+			switch mail.Type {
+			case mailTypeSent:
+				sent++
+				if mail.UserID != mail.From {
+					t.Fatalf("sent copy from %s owned by %s, want the sender", mail.From, mail.UserID)
+				}
+			case mailTypeReceived:
+				if mail.UserID == mail.From || !contains(mail.To, mail.UserID) {
+					t.Fatalf("received copy from %s to %v owned by %s, want a recipient", mail.From, mail.To, mail.UserID)
+				}
+			default:
+				t.Fatalf("mail type = %d, want %d or %d", mail.Type, mailTypeSent, mailTypeReceived)
+			}
+		}
+		if got := float64(sent) / samples; math.Abs(got-want) > 0.02 {
+			t.Errorf("ratio %v: sent share = %.3f, want %.3f", ratio, got, want)
+		}
+	}
+}
+
+func TestSetSentRatioRejectsOutOfRange(t *testing.T) {
+	gen := newTestGenerator(t)
+	for _, ratio := range []float64{-0.1, 1.5} {
+		if err := gen.SetSentRatio(ratio); err == nil {
+			t.Errorf("SetSentRatio(%v) accepted", ratio)
+		}
+	}
+}
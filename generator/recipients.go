@@ -0,0 +1,83 @@
+package generator
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// Default number of To recipients per generated mail
+const (
+	DefaultMinRecipients = 1
+	DefaultMaxRecipients = 3
+)
+
+// maxRecipientDraws bounds resampling per wanted recipient before falling
+// back to a scan of the user pool, e.g. when a skewed picker keeps drawing
+// the sender
+const maxRecipientDraws = 10
+
+// SetRecipientRange sets how many To recipients created and forwarded mails
+// get. Zero values keep the defaults. The range is checked against the user
+// pool: every recipient must be distinct and differ from the sender, so max
+// may not exceed the number of distinct users minus one.
+func (g *DataGenerator) SetRecipientRange(min, max int) error {
+	if min == 0 {
+		min = DefaultMinRecipients
+	}
+	if max == 0 {
+		max = DefaultMaxRecipients
+		if max < min {
+			max = min
+		}
+	}
+	if min < 1 || max < min {
+		return fmt.Errorf("invalid recipient range %d-%d: need 1 <= min <= max", min, max)
+	}
+
+	distinct := make(map[string]struct{}, len(g.userIDs))
+	for _, id := range g.userIDs {
+		distinct[id] = struct{}{}
+	}
+	if available := len(distinct) - 1; max > available {
+		if min > available {
+			return fmt.Errorf("recipient range %d-%d needs at least %d distinct users, have %d", min, max, min+1, len(distinct))
+		}
+		max = available
+	}
+
+	g.minRecipients, g.maxRecipients = min, max
+	return nil
+}
+
+// recipientCount draws how many recipients the next mail gets
+func (g *DataGenerator) recipientCount() int {
+	min, max := g.minRecipients, g.maxRecipients
+	if min == 0 {
+		min, max = DefaultMinRecipients, DefaultMaxRecipients
+	}
+	return min + rand.Intn(max-min+1)
+}
+
+// pickRecipients draws n distinct recipients other than from using pick,
+// resampling collisions. It always returns at least one recipient when the
+// pool has a user other than from.
+func (g *DataGenerator) pickRecipients(from string, n int, pick func() string) []string {
+	seen := map[string]bool{from: true}
+	to := make([]string, 0, n)
+	for draws := 0; len(to) < n && draws < n*maxRecipientDraws; draws++ {
+		if recipient := pick(); !seen[recipient] {
+			seen[recipient] = true
+			to = append(to, recipient)
+		}
+	}
+
+	// Fill the rest from a scan at a random offset, so a tiny or skewed
+	// pool still yields the requested count when it has enough users
+	for i, start := 0, rand.Intn(len(g.userIDs)); i < len(g.userIDs) && len(to) < n; i++ {
+		if recipient := g.userIDs[(start+i)%len(g.userIDs)]; !seen[recipient] {
+			seen[recipient] = true
+			to = append(to, recipient)
+		}
+	}
+	return to
+}
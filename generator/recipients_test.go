@@ -0,0 +1,67 @@
+package generator
+
+import "testing"
+
+// checkRecipients fails unless to holds between min and max distinct users,
+// none of them the sender
+func checkRecipients(t *testing.T, from string, to []string, min, max int) {
+	t.Helper()
+	if len(to) < min || len(to) > max {
+		t.Fatalf("%s sent to %v, want %d-%d recipients", from, to, min, max)
+	}
+	seen := map[string]bool{}
+	for _, recipient := range to {
+		if recipient == from || seen[recipient] {
+			t.Fatalf("%s sent to %v, want distinct recipients other than the sender", from, to)
+		}
+		seen[recipient] = true
+	}
+}
+
+// TestRecipientsNeverEmpty generates many created and forwarded mails, from
+// a pool skewed hard enough that the sender is usually drawn as a recipient,
+// and checks every one goes to at least one other user
+func TestRecipientsNeverEmpty(t *testing.T) {
+	const samples = 5000
+	gen := newTestGenerator(t)
+	gen.SetInboxSkew(3, 0)
+
+	for i := 0; i < samples; i++ {
+		req := gen.GenerateCreateMailRequest("")
+		checkRecipients(t, req.From, req.To, DefaultMinRecipients, DefaultMaxRecipients)
+
+		forward := gen.GenerateForwardMailRequest("mail-1", "user-1")
+		checkRecipients(t, forward.From, forward.To, DefaultMinRecipients, DefaultMaxRecipients)
+	}
+}
+
+// TestRecipientRange checks a configured range is honoured and a range the
+// user pool cannot fill is capped or rejected
+func TestRecipientRange(t *testing.T) {
+	gen := newTestGenerator(t)
+	if err := gen.SetRecipientRange(2, 2); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1000; i++ {
+		req := gen.GenerateCreateMailRequest("")
+		checkRecipients(t, req.From, req.To, 2, 2)
+	}
+
+	// Four users leave at most three recipients besides the sender
+	if err := gen.SetRecipientRange(1, 10); err != nil {
+		t.Fatal(err)
+	}
+	if gen.maxRecipients != 3 {
+		t.Errorf("max recipients = %d, want it capped at 3", gen.maxRecipients)
+	}
+	for i := 0; i < 1000; i++ {
+		req := gen.GenerateCreateMailRequest("")
+		checkRecipients(t, req.From, req.To, 1, 3)
+	}
+
+	for _, r := range [][2]int{{4, 5}, {3, 2}, {-1, 2}} {
+		if err := gen.SetRecipientRange(r[0], r[1]); err == nil {
+			t.Errorf("SetRecipientRange(%d, %d) accepted", r[0], r[1])
+		}
+	}
+}
@@ -1,8 +1,11 @@
 package generator
 
 import (
+	"errors"
 	"fmt"
 	"math/rand"
+	"strings"
+	"sync/atomic"
 
 	"mail-stress-test/models"
 )
@@ -10,13 +13,57 @@ import (
 // DataGenerator generates random mail requests for stress testing
 type DataGenerator struct {
 	userIDs []string
+
+	// Hot/cold search term mix; disabled when hotTerms is empty
+	hotRatio float64
+	hotTerms []string
+	coldSeq  int64
+
+	// view is the mail view requested by list and search requests
+	view string
+
+	// scope restricts generated searches to subject, content or both
+	scope string
+
+	// sortBy is the result order requested by list and search requests
+	sortBy string
+
+	// participantRatio of searches get a from: or to: filter
+	participantRatio float64
+
+	// Search terms drawn from each user's own subjects, except missRatio of
+	// queries which use a term no mail contains; disabled when userTerms is nil
+	userTerms map[string][]string
+	missRatio float64
+
+	// Inbox size skew; participants is nil for a uniform spread
+	participants     *powerLawPicker
+	heavyTargetRatio float64
+
+	// To recipients per created or forwarded mail; zero uses the defaults
+	minRecipients int
+	maxRecipients int
+
+	// Share of GenerateMail documents that are sender copies; zero follows
+	// the recipient range
+	sentRatio float64
+
+	// Gap between consecutive GenerateThread messages; nil uses the defaults
+	replyDelay *replyDelay
 }
 
+// ErrNoUsers is returned when a generator is created without any user IDs,
+// which every generated request needs to pick senders and recipients
+var ErrNoUsers = errors.New("no users available; set num_users > 0 or provide a user source")
+
 // NewDataGenerator creates a new DataGenerator with a list of user IDs
-func NewDataGenerator(userIDs []string) *DataGenerator {
+func NewDataGenerator(userIDs []string) (*DataGenerator, error) {
+	if len(userIDs) == 0 {
+		return nil, ErrNoUsers
+	}
 	return &DataGenerator{
 		userIDs: userIDs,
-	}
+	}, nil
 }
 
 var Subjects = []string{
@@ -35,22 +82,18 @@ var contentTemplates = []string{
 
 // GenerateCreateMailRequest generates a random CreateMail request
 func (g *DataGenerator) GenerateCreateMailRequest(replyToID string) *models.MailRequest {
-	from := g.userIDs[rand.Intn(len(g.userIDs))]
-
-	// Generate 1-3 recipients
-	numRecipients := rand.Intn(3) + 1
-	to := make([]string, 0, numRecipients)
-	for i := 0; i < numRecipients; i++ {
-		recipient := g.userIDs[rand.Intn(len(g.userIDs))]
-		if recipient != from {
-			to = append(to, recipient)
-		}
-	}
+	return g.GenerateCreateMailRequestFrom(g.pickParticipant(), replyToID)
+}
+
+// GenerateCreateMailRequestFrom generates a random CreateMail request sent
+// by from
+func (g *DataGenerator) GenerateCreateMailRequestFrom(from, replyToID string) *models.MailRequest {
+	to := g.pickRecipients(from, g.recipientCount(), g.pickParticipant)
 
 	// Sometimes add Cc
 	var cc []string
 	if rand.Float32() < 0.3 { // 30% chance
-		ccRecipient := g.userIDs[rand.Intn(len(g.userIDs))]
+		ccRecipient := g.pickParticipant()
 		if ccRecipient != from {
 			cc = []string{ccRecipient}
 		}
@@ -59,7 +102,7 @@ func (g *DataGenerator) GenerateCreateMailRequest(replyToID string) *models.Mail
 	// Rarely add Bcc
 	var bcc []string
 	if rand.Float32() < 0.1 { // 10% chance
-		bccRecipient := g.userIDs[rand.Intn(len(g.userIDs))]
+		bccRecipient := g.pickParticipant()
 		if bccRecipient != from {
 			bcc = []string{bccRecipient}
 		}
@@ -81,25 +124,164 @@ func (g *DataGenerator) GenerateCreateMailRequest(replyToID string) *models.Mail
 
 // GenerateListMailsRequest generates a random ListMails request
 func (g *DataGenerator) GenerateListMailsRequest() *models.ListMailsRequest {
-	userID := g.userIDs[rand.Intn(len(g.userIDs))]
+	userID := g.pickTargetUser()
 
 	return &models.ListMailsRequest{
 		UserID: userID,
 		Limit:  20 + rand.Intn(80), // 20-100
 		Offset: rand.Intn(100),
+		View:   g.view,
+		SortBy: g.sortBy,
+	}
+}
+
+// GenerateForwardMailRequest forwards mailID from its owner to new recipients
+func (g *DataGenerator) GenerateForwardMailRequest(mailID, from string) *models.ForwardMailRequest {
+	to := g.pickRecipients(from, g.recipientCount(), func() string {
+		return g.userIDs[rand.Intn(len(g.userIDs))]
+	})
+
+	return &models.ForwardMailRequest{
+		MailID: mailID,
+		From:   from,
+		To:     to,
+	}
+}
+
+// GenerateReplyAllRequest replies to mailID from from with random content
+func (g *DataGenerator) GenerateReplyAllRequest(mailID, from string) *models.ReplyAllRequest {
+	subject := Subjects[rand.Intn(len(Subjects))]
+	return &models.ReplyAllRequest{
+		MailID:  mailID,
+		From:    from,
+		Content: fmt.Sprintf(contentTemplates[rand.Intn(len(contentTemplates))], subject),
+	}
+}
+
+// GenerateDraftUpdate generates a new subject/content for editing a draft
+func (g *DataGenerator) GenerateDraftUpdate() *models.DraftUpdate {
+	subject := Subjects[rand.Intn(len(Subjects))]
+	return &models.DraftUpdate{
+		Subject: subject,
+		Content: fmt.Sprintf(contentTemplates[rand.Intn(len(contentTemplates))], subject),
 	}
 }
 
+// SetSearchTermMix makes hotRatio of generated search terms come from a
+// small repeated set of hotTermCount terms (cache-hot), and the rest unique
+// terms never seen before (cache-cold). hotTermCount <= 0 restores the
+// default of picking any subject at random.
+func (g *DataGenerator) SetSearchTermMix(hotRatio float64, hotTermCount int) {
+	if hotTermCount <= 0 {
+		g.hotTerms = nil
+		return
+	}
+	if hotTermCount > len(Subjects) {
+		hotTermCount = len(Subjects)
+	}
+	g.hotRatio = hotRatio
+	g.hotTerms = Subjects[:hotTermCount]
+}
+
+// SetView makes list and search requests ask for the given mail view
+func (g *DataGenerator) SetView(view string) {
+	g.view = view
+}
+
+// SetSearchScope restricts generated searches to the given scope
+func (g *DataGenerator) SetSearchScope(scope string) {
+	g.scope = scope
+}
+
+// SetSortBy makes list and search requests ask for the given result order
+func (g *DataGenerator) SetSortBy(sortBy string) {
+	g.sortBy = sortBy
+}
+
+// SetParticipantFilterRatio makes ratio of generated searches filter by a
+// random sender (from:) or recipient (to:), half each
+func (g *DataGenerator) SetParticipantFilterRatio(ratio float64) {
+	g.participantRatio = ratio
+}
+
 // GenerateSearchMailsRequest generates a random SearchMails request
 func (g *DataGenerator) GenerateSearchMailsRequest() *models.SearchMailsRequest {
-	userID := g.userIDs[rand.Intn(len(g.userIDs))]
-	searchTerm := Subjects[rand.Intn(len(Subjects))]
+	return g.GenerateSearchMailsRequestFor(g.pickTargetUser())
+}
 
-	return &models.SearchMailsRequest{
-		UserID:     userID,
-		SearchTerm: searchTerm,
-		Limit:      50,
+// GenerateSearchMailsRequestFor generates a random SearchMails request in
+// userID's mailbox
+func (g *DataGenerator) GenerateSearchMailsRequestFor(userID string) *models.SearchMailsRequest {
+	req := &models.SearchMailsRequest{
+		UserID: userID,
+		Limit:  50,
+		View:   g.view,
+		Scope:  g.scope,
+		SortBy: g.sortBy,
+	}
+
+	switch {
+	case g.userTerms != nil:
+		req.SearchTerm, req.Miss = g.userSearchTerm(userID)
+	case len(g.hotTerms) == 0:
+		req.SearchTerm = Subjects[rand.Intn(len(Subjects))]
+	case rand.Float64() < g.hotRatio:
+		req.SearchTerm = g.hotTerms[rand.Intn(len(g.hotTerms))]
+		req.Hot = true
+	default:
+		req.SearchTerm = g.coldSearchTerm()
 	}
+
+	if g.participantRatio > 0 && rand.Float64() < g.participantRatio {
+		participant := g.userIDs[rand.Intn(len(g.userIDs))]
+		if rand.Intn(2) == 0 {
+			req.From = participant
+		} else {
+			req.To = participant
+		}
+	}
+
+	return req
+}
+
+// SetUserTerms draws each user's search terms from subjects, the terms
+// found in that user's mailbox, so searches return real result sets;
+// missRatio of searches use a term that matches nothing instead. It takes
+// precedence over the hot/cold term mix. nil restores random subjects.
+func (g *DataGenerator) SetUserTerms(subjects map[string][]string, missRatio float64) {
+	g.userTerms = subjects
+	g.missRatio = missRatio
+}
+
+// userSearchTerm picks one of userID's own subjects, or a miss term; users
+// without known subjects get a random subject
+func (g *DataGenerator) userSearchTerm(userID string) (term string, miss bool) {
+	if rand.Float64() < g.missRatio {
+		return g.coldSearchTerm(), true
+	}
+	if subjects := g.userTerms[userID]; len(subjects) > 0 {
+		return subjects[rand.Intn(len(subjects))], false
+	}
+	return Subjects[rand.Intn(len(Subjects))], false
+}
+
+// IsKnownTerm reports whether term comes from the seeded subject vocabulary,
+// so a search for it is expected to match
+func IsKnownTerm(term string) bool {
+	for _, subject := range Subjects {
+		if term == subject {
+			return true
+		}
+	}
+	return false
+}
+
+// coldSearchTerm returns a term that has not been queried before: a word from
+// the subject vocabulary with a unique numeric suffix
+func (g *DataGenerator) coldSearchTerm() string {
+	words := strings.Fields(Subjects[rand.Intn(len(Subjects))])
+	word := words[rand.Intn(len(words))]
+	return fmt.Sprintf("%s%d", word, atomic.AddInt64(&g.coldSeq, 1))
 }
 
 // GetRandomUserID returns a random user ID from the generator's list
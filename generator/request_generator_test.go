@@ -0,0 +1,119 @@
+package generator
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+// newTestGenerator returns a generator over a few fixed users
+func newTestGenerator(t *testing.T) *DataGenerator {
+	t.Helper()
+	gen, err := NewDataGenerator([]string{"user-1", "user-2", "user-3", "user-4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gen
+}
+
+// TestSearchTermMix generates a long term stream and checks the hot share
+// matches the configured ratio, hot terms repeat from the small hot set and
+// cold terms are never repeated
+func TestSearchTermMix(t *testing.T) {
+	const requests, hotRatio, hotTermCount = 20000, 0.3, 3
+	gen := newTestGenerator(t)
+	gen.SetSearchTermMix(hotRatio, hotTermCount)
+
+	hotSet := make(map[string]bool)
+	for _, term := range Subjects[:hotTermCount] {
+		hotSet[term] = true
+	}
+	hot := 0
+	seenCold := make(map[string]bool)
+	for i := 0; i < requests; i++ {
+		req := gen.GenerateSearchMailsRequest()
+		if req.Hot {
+			hot++
+			if !hotSet[req.SearchTerm] {
+				t.Fatalf("hot term %q is not in the hot set", req.SearchTerm)
+			}
+			continue
+		}
+		if hotSet[req.SearchTerm] || seenCold[req.SearchTerm] {
+			t.Fatalf("cold term %q was queried before", req.SearchTerm)
+		}
+		seenCold[req.SearchTerm] = true
+	}
+
+	if got := float64(hot) / requests; math.Abs(got-hotRatio) > 0.02 {
+		t.Errorf("hot share = %.3f, want %.2f ± 0.02", got, hotRatio)
+	}
+}
+
+func TestSearchTermMixDisabled(t *testing.T) {
+	gen := newTestGenerator(t)
+	gen.SetSearchTermMix(0.5, 0)
+	for i := 0; i < 100; i++ {
+		if req := gen.GenerateSearchMailsRequest(); req.Hot || !IsKnownTerm(req.SearchTerm) {
+			t.Fatalf("request %+v without a term mix, want a plain subject", req)
+		}
+	}
+}
+
+// TestNoUsers checks an empty user source fails up front with ErrNoUsers
+// rather than panicking on the first generated request
+func TestNoUsers(t *testing.T) {
+	for _, userIDs := range [][]string{nil, {}} {
+		if gen, err := NewDataGenerator(userIDs); !errors.Is(err, ErrNoUsers) || gen != nil {
+			t.Errorf("NewDataGenerator(%v) = %v, %v; want ErrNoUsers", userIDs, gen, err)
+		}
+	}
+	for _, scheme := range []string{UserIDSchemeObjectID, UserIDSchemeEmail} {
+		if _, err := GenerateUserIDs(scheme, 0, "", 0); !errors.Is(err, ErrNoUsers) {
+			t.Errorf("GenerateUserIDs(%s, 0) = %v, want ErrNoUsers", scheme, err)
+		}
+	}
+	if !strings.Contains(ErrNoUsers.Error(), "num_users") {
+		t.Errorf("ErrNoUsers = %q, want it to name the num_users setting", ErrNoUsers)
+	}
+}
+
+// TestParticipantFilterRatio checks the configured share of searches gets
+// exactly one of a from: or to: filter naming a known user, split evenly
+func TestParticipantFilterRatio(t *testing.T) {
+	const requests, ratio = 20000, 0.25
+	gen := newTestGenerator(t)
+	gen.SetParticipantFilterRatio(ratio)
+
+	users := map[string]bool{"user-1": true, "user-2": true, "user-3": true, "user-4": true}
+	from, to := 0, 0
+	for i := 0; i < requests; i++ {
+		req := gen.GenerateSearchMailsRequest()
+		switch {
+		case req.From != "" && req.To != "":
+			t.Fatalf("request %+v has both filters, want at most one", req)
+		case req.From != "":
+			from++
+		case req.To != "":
+			to++
+		}
+		if participant := req.From + req.To; participant != "" && !users[participant] {
+			t.Fatalf("participant %q is not a generated user", participant)
+		}
+	}
+
+	if got := float64(from+to) / requests; math.Abs(got-ratio) > 0.02 {
+		t.Errorf("filtered share = %.3f, want %.2f ± 0.02", got, ratio)
+	}
+	if math.Abs(float64(from-to))/float64(from+to) > 0.1 {
+		t.Errorf("%d from: and %d to: filters, want about half each", from, to)
+	}
+
+	gen.SetParticipantFilterRatio(0)
+	for i := 0; i < 100; i++ {
+		if req := gen.GenerateSearchMailsRequest(); req.HasParticipantFilter() {
+			t.Fatalf("request %+v filtered with a zero ratio", req)
+		}
+	}
+}
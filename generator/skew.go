@@ -0,0 +1,99 @@
+package generator
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// heavyUserShare is the fraction of users, by rank, treated as huge inboxes
+// when targeting list and search requests
+const heavyUserShare = 0.1
+
+// powerLawPicker draws user indexes with Zipf-like weights: the user at
+// rank r (1-based) is picked with weight 1/r^exponent
+type powerLawPicker struct {
+	cdf []float64
+}
+
+func newPowerLawPicker(n int, exponent float64) *powerLawPicker {
+	cdf := make([]float64, n)
+	total := 0.0
+	for i := range cdf {
+		total += 1 / math.Pow(float64(i+1), exponent)
+		cdf[i] = total
+	}
+	return &powerLawPicker{cdf: cdf}
+}
+
+func (p *powerLawPicker) pick() int {
+	target := rand.Float64() * p.cdf[len(p.cdf)-1]
+	i := sort.SearchFloat64s(p.cdf, target)
+	if i >= len(p.cdf) {
+		i = len(p.cdf) - 1
+	}
+	return i
+}
+
+// share returns the expected fraction of picks landing on the first n ranks
+func (p *powerLawPicker) share(n int) float64 {
+	if n <= 0 {
+		return 0
+	}
+	if n > len(p.cdf) {
+		n = len(p.cdf)
+	}
+	return p.cdf[n-1] / p.cdf[len(p.cdf)-1]
+}
+
+// SetInboxSkew spreads generated mail participants over users with a power
+// law of the given exponent, so a few inboxes (the first user IDs) grow far
+// larger than the rest; 0 keeps the uniform spread. List and search then
+// aim heavyTargetRatio of their requests at the largest 10% of inboxes and
+// the rest at the others, so both ends of the size range are measured.
+func (g *DataGenerator) SetInboxSkew(exponent, heavyTargetRatio float64) {
+	if exponent <= 0 {
+		g.participants = nil
+		return
+	}
+	g.participants = newPowerLawPicker(len(g.userIDs), exponent)
+	g.heavyTargetRatio = heavyTargetRatio
+}
+
+// HeavyUserCount is the number of leading user IDs treated as huge inboxes
+func (g *DataGenerator) HeavyUserCount() int {
+	n := int(math.Ceil(float64(len(g.userIDs)) * heavyUserShare))
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// ExpectedHeavyShare is the expected fraction of seeded mail participants
+// that are heavy users under the configured skew
+func (g *DataGenerator) ExpectedHeavyShare() float64 {
+	if g.participants == nil {
+		return float64(g.HeavyUserCount()) / float64(len(g.userIDs))
+	}
+	return g.participants.share(g.HeavyUserCount())
+}
+
+// pickParticipant returns a sender or recipient for a generated mail
+func (g *DataGenerator) pickParticipant() string {
+	if g.participants != nil {
+		return g.userIDs[g.participants.pick()]
+	}
+	return g.userIDs[rand.Intn(len(g.userIDs))]
+}
+
+// pickTargetUser returns the mailbox owner of a list or search request
+func (g *DataGenerator) pickTargetUser() string {
+	heavy := g.HeavyUserCount()
+	if g.participants == nil || heavy >= len(g.userIDs) {
+		return g.userIDs[rand.Intn(len(g.userIDs))]
+	}
+	if rand.Float64() < g.heavyTargetRatio {
+		return g.userIDs[rand.Intn(heavy)]
+	}
+	return g.userIDs[heavy+rand.Intn(len(g.userIDs)-heavy)]
+}
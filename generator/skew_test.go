@@ -0,0 +1,76 @@
+package generator
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+// TestInboxSkewDistribution seeds mails under a power-law skew and checks
+// the share of inbox documents owned by the largest 10% of users matches the
+// configured exponent, while list and search aim at heavy inboxes at the
+// configured ratio
+func TestInboxSkewDistribution(t *testing.T) {
+	const users, mails, heavyTargetRatio = 100, 20000, 0.5
+	ids := make([]string, users)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("user-%d", i+1)
+	}
+	gen, err := NewDataGenerator(ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uniform := gen.ExpectedHeavyShare(); uniform != 0.1 {
+		t.Errorf("unskewed heavy share = %.3f, want 0.1", uniform)
+	}
+	gen.SetInboxSkew(1.2, heavyTargetRatio)
+
+	heavy := make(map[string]bool)
+	for _, id := range ids[:gen.HeavyUserCount()] {
+		heavy[id] = true
+	}
+	// Every participant of a mail gets its own inbox document
+	var docs, heavyDocs, heavySenders int
+	for i := 0; i < mails; i++ {
+		req := gen.GenerateCreateMailRequest("")
+		if heavy[req.From] {
+			heavySenders++
+		}
+		for _, group := range [][]string{{req.From}, req.To, req.Cc, req.Bcc} {
+			for _, id := range group {
+				docs++
+				if heavy[id] {
+					heavyDocs++
+				}
+			}
+		}
+	}
+	share, want := float64(heavyDocs)/float64(docs), gen.ExpectedHeavyShare()
+	if want < 0.5 {
+		t.Errorf("expected heavy share %.3f, want a skew of 1.2 to give the top 10%% most mails", want)
+	}
+	if senders := float64(heavySenders) / mails; math.Abs(senders-want) > 0.02 {
+		t.Errorf("top 10%% of users send %.3f of mails, want %.3f", senders, want)
+	}
+	// Recipients are drawn without repeating a participant of the same mail,
+	// which trims the heaviest users' share a little below the raw draw
+	if share > want || share < want-0.1 {
+		t.Errorf("top 10%% of users own %.3f of inbox documents, want just under %.3f", share, want)
+	}
+
+	var heavyTargets int
+	const targets = 10000
+	for i := 0; i < targets; i++ {
+		if heavy[gen.GenerateListMailsRequest().UserID] {
+			heavyTargets++
+		}
+	}
+	if ratio := float64(heavyTargets) / targets; math.Abs(ratio-heavyTargetRatio) > 0.03 {
+		t.Errorf("%.3f of list requests target heavy inboxes, want %.2f", ratio, heavyTargetRatio)
+	}
+
+	gen.SetInboxSkew(0, 0)
+	if gen.ExpectedHeavyShare() != 0.1 {
+		t.Errorf("skew 0 left heavy share at %.3f, want the uniform 0.1", gen.ExpectedHeavyShare())
+	}
+}
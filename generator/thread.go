@@ -0,0 +1,110 @@
+package generator
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"mail-stress-test/models"
+)
+
+// Reply delay distributions accepted by SetReplyDelay
+const (
+	ReplyDelayFixed       = "fixed"       // every reply exactly Mean after its parent
+	ReplyDelayUniform     = "uniform"     // uniform between Min and Max
+	ReplyDelayExponential = "exponential" // Min plus an exponential tail averaging Mean, capped at Max
+)
+
+// Default reply delay: most replies within the hour, a long tail over days
+const (
+	defaultReplyDelayMin  = time.Minute
+	defaultReplyDelayMean = 2 * time.Hour
+	defaultReplyDelayMax  = 72 * time.Hour
+)
+
+// replyDelay is the gap between a message and the reply to it
+type replyDelay struct {
+	distribution   string
+	min, mean, max time.Duration
+}
+
+// SetReplyDelay sets the distribution of the gap between consecutive
+// messages of a GenerateThread conversation. Zero durations take the
+// defaults (1m min, 2h mean, 72h max).
+func (g *DataGenerator) SetReplyDelay(distribution string, min, mean, max time.Duration) error {
+	if min <= 0 {
+		min = defaultReplyDelayMin
+	}
+	if mean <= 0 {
+		mean = defaultReplyDelayMean
+	}
+	if max <= 0 {
+		max = defaultReplyDelayMax
+	}
+	switch distribution {
+	case "":
+		distribution = ReplyDelayExponential
+	case ReplyDelayFixed, ReplyDelayUniform, ReplyDelayExponential:
+	default:
+		return fmt.Errorf("unknown reply delay distribution %q (want %s, %s or %s)",
+			distribution, ReplyDelayFixed, ReplyDelayUniform, ReplyDelayExponential)
+	}
+	if min > mean || mean > max {
+		return fmt.Errorf("invalid reply delay: need min (%s) <= mean (%s) <= max (%s)", min, mean, max)
+	}
+	g.replyDelay = &replyDelay{distribution: distribution, min: min, mean: mean, max: max}
+	return nil
+}
+
+// sample draws one gap; it is always positive, so replies strictly follow
+// their parent
+func (d *replyDelay) sample() time.Duration {
+	switch d.distribution {
+	case ReplyDelayFixed:
+		return d.mean
+	case ReplyDelayUniform:
+		return d.min + time.Duration(rand.Int63n(int64(d.max-d.min)+1))
+	default:
+		delay := d.min + time.Duration(rand.ExpFloat64()*float64(d.mean-d.min))
+		if delay > d.max {
+			delay = d.max
+		}
+		return delay
+	}
+}
+
+// GenerateThread generates a conversation of a new mail followed by up to
+// maxReplies replies, each answering the previous message: sent by one of
+// its recipients back to its sender. SentAt spaces the messages by the reply
+// delay, backdated so the last one is sent now. Replies leave ReplyTo empty
+// for the caller to fill with the ID the previous message was stored under.
+func (g *DataGenerator) GenerateThread(maxReplies int) []*models.MailRequest {
+	delay := g.replyDelay
+	if delay == nil {
+		delay = &replyDelay{distribution: ReplyDelayExponential, min: defaultReplyDelayMin, mean: defaultReplyDelayMean, max: defaultReplyDelayMax}
+	}
+	replies := 0
+	if maxReplies > 0 {
+		replies = rand.Intn(maxReplies + 1)
+	}
+
+	thread := []*models.MailRequest{g.GenerateCreateMailRequest("")}
+	root := thread[0]
+	for i := 0; i < replies; i++ {
+		parent := thread[i]
+		from := parent.To[rand.Intn(len(parent.To))]
+		reply := g.GenerateCreateMailRequestFrom(from, "")
+		reply.To = []string{parent.From}
+		reply.Subject = "Re: " + strings.TrimPrefix(root.Subject, "Re: ")
+		thread = append(thread, reply)
+	}
+
+	sentAt := time.Now()
+	for i := len(thread) - 1; i >= 0; i-- {
+		at := sentAt
+		thread[i].SentAt = &at
+		sentAt = sentAt.Add(-delay.sample())
+	}
+	return thread
+}
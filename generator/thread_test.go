@@ -0,0 +1,69 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestGenerateThreadSpacing generates threads under each delay distribution
+// and checks every reply is sent strictly after the message it answers, by a
+// gap within the configured bounds, with the last message sent now
+func TestGenerateThreadSpacing(t *testing.T) {
+	tests := []struct {
+		distribution   string
+		min, mean, max time.Duration
+	}{
+		{ReplyDelayFixed, time.Minute, 10 * time.Minute, time.Hour},
+		{ReplyDelayUniform, time.Minute, 3 * time.Minute, 5 * time.Minute},
+		{ReplyDelayExponential, time.Minute, 5 * time.Minute, 20 * time.Minute},
+	}
+	for _, tt := range tests {
+		gen := newTestGenerator(t)
+		if err := gen.SetReplyDelay(tt.distribution, tt.min, tt.mean, tt.max); err != nil {
+			t.Fatal(err)
+		}
+		lo, hi := tt.min, tt.max
+		if tt.distribution == ReplyDelayFixed {
+			lo, hi = tt.mean, tt.mean
+		}
+
+		replies := 0
+		for i := 0; i < 200; i++ {
+			start := time.Now()
+			thread := gen.GenerateThread(5)
+			if last := *thread[len(thread)-1].SentAt; last.Before(start) || time.Since(last) > time.Second {
+				t.Fatalf("%s: last message sent at %s, want now", tt.distribution, last)
+			}
+			for j := 1; j < len(thread); j++ {
+				replies++
+				parent, reply := thread[j-1], thread[j]
+				if gap := reply.SentAt.Sub(*parent.SentAt); gap <= 0 || gap < lo || gap > hi {
+					t.Fatalf("%s: reply %d sent %s after its parent, want %s-%s", tt.distribution, j, gap, lo, hi)
+				}
+				if !contains(parent.To, reply.From) || len(reply.To) != 1 || reply.To[0] != parent.From {
+					t.Fatalf("reply from %s to %v answers %s's mail to %v, want a recipient answering the sender", reply.From, reply.To, parent.From, parent.To)
+				}
+				if !strings.HasPrefix(reply.Subject, "Re: ") || strings.HasPrefix(reply.Subject, "Re: Re: ") {
+					t.Errorf("reply subject = %q, want a single Re: prefix", reply.Subject)
+				}
+			}
+		}
+		if replies == 0 {
+			t.Errorf("%s: 200 threads of up to 5 replies had none", tt.distribution)
+		}
+	}
+}
+
+func TestSetReplyDelayErrors(t *testing.T) {
+	gen := newTestGenerator(t)
+	if err := gen.SetReplyDelay("gaussian", 0, 0, 0); err == nil {
+		t.Error("unknown distribution accepted")
+	}
+	if err := gen.SetReplyDelay(ReplyDelayUniform, time.Hour, time.Minute, 2*time.Hour); err == nil {
+		t.Error("min above mean accepted")
+	}
+	if err := gen.SetReplyDelay("", 0, 0, 0); err != nil || gen.replyDelay.distribution != ReplyDelayExponential || gen.replyDelay.max != defaultReplyDelayMax {
+		t.Errorf("defaults = %+v, %v; want exponential up to %s", gen.replyDelay, err, defaultReplyDelayMax)
+	}
+}
@@ -0,0 +1,67 @@
+package generator
+
+import (
+	"fmt"
+	"math/rand"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// User ID schemes accepted by GenerateUserIDs
+const (
+	UserIDSchemeObjectID = "objectid" // 24-char hex ObjectID (default)
+	UserIDSchemeUUID     = "uuid"     // RFC 4122 version 4 UUID
+	UserIDSchemeEmail    = "email"    // user000001@<domain>
+	UserIDSchemePrefix   = "prefix"   // <prefix><counter>, e.g. user-1
+)
+
+// defaultEmailDomain is used by the email scheme when no domain is given
+const defaultEmailDomain = "example.com"
+
+// GenerateUserIDs creates n user IDs in the given scheme so the generated
+// workload can target datasets that key users by UUID, email or a custom
+// prefix. prefix is the email domain for "email" and the ID prefix for "prefix".
+// ObjectID and UUID schemes are derived from seed, so every run with the same
+// seed targets the same users as the run that seeded them.
+func GenerateUserIDs(scheme string, n int, prefix string, seed int64) ([]string, error) {
+	if n <= 0 {
+		return nil, ErrNoUsers
+	}
+	rng := rand.New(rand.NewSource(seed))
+	ids := make([]string, n)
+	for i := range ids {
+		switch scheme {
+		case "", UserIDSchemeObjectID:
+			ids[i] = newObjectID(rng).Hex()
+		case UserIDSchemeUUID:
+			ids[i] = newUUID(rng)
+		case UserIDSchemeEmail:
+			domain := prefix
+			if domain == "" {
+				domain = defaultEmailDomain
+			}
+			ids[i] = fmt.Sprintf("user%06d@%s", i+1, domain)
+		case UserIDSchemePrefix:
+			ids[i] = fmt.Sprintf("%s%d", prefix, i+1)
+		default:
+			return nil, fmt.Errorf("unknown user ID scheme %q (want objectid, uuid, email or prefix)", scheme)
+		}
+	}
+	return ids, nil
+}
+
+// newObjectID returns an ObjectID whose bytes are drawn from rng
+func newObjectID(rng *rand.Rand) primitive.ObjectID {
+	var id primitive.ObjectID
+	rng.Read(id[:])
+	return id
+}
+
+// newUUID returns a version 4 UUID whose bytes are drawn from rng
+func newUUID(rng *rand.Rand) string {
+	var b [16]byte
+	rng.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
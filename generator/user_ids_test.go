@@ -0,0 +1,68 @@
+package generator
+
+import (
+	"regexp"
+	"testing"
+)
+
+// TestUserIDFormats checks every scheme produces distinct IDs in its
+// documented format
+func TestUserIDFormats(t *testing.T) {
+	tests := []struct {
+		scheme, prefix string
+		format         *regexp.Regexp
+		first          string
+	}{
+		{scheme: "", format: regexp.MustCompile(`^[0-9a-f]{24}$`)},
+		{scheme: UserIDSchemeObjectID, format: regexp.MustCompile(`^[0-9a-f]{24}$`)},
+		{scheme: UserIDSchemeUUID, format: regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)},
+		{scheme: UserIDSchemeEmail, format: regexp.MustCompile(`^user\d{6}@example\.com$`), first: "user000001@example.com"},
+		{scheme: UserIDSchemeEmail, prefix: "mail.test", format: regexp.MustCompile(`^user\d{6}@mail\.test$`), first: "user000001@mail.test"},
+		{scheme: UserIDSchemePrefix, prefix: "user-", format: regexp.MustCompile(`^user-\d+$`), first: "user-1"},
+	}
+	for _, tt := range tests {
+		ids, err := GenerateUserIDs(tt.scheme, 100, tt.prefix, 1)
+		if err != nil {
+			t.Fatalf("GenerateUserIDs(%q): %v", tt.scheme, err)
+		}
+		seen := make(map[string]bool)
+		for _, id := range ids {
+			if !tt.format.MatchString(id) {
+				t.Errorf("scheme %q produced %q, want it to match %s", tt.scheme, id, tt.format)
+			}
+			if seen[id] {
+				t.Errorf("scheme %q produced %q twice", tt.scheme, id)
+			}
+			seen[id] = true
+		}
+		if tt.first != "" && ids[0] != tt.first {
+			t.Errorf("scheme %q first ID = %q, want %q", tt.scheme, ids[0], tt.first)
+		}
+	}
+
+	if _, err := GenerateUserIDs("ulid", 1, "", 1); err == nil {
+		t.Error("unknown scheme accepted")
+	}
+}
+
+// TestUserIDsFollowSeed checks the random-looking schemes repeat for the same
+// seed, so a later run targets the users an earlier run seeded, and differ
+// for another seed
+func TestUserIDsFollowSeed(t *testing.T) {
+	for _, scheme := range []string{UserIDSchemeObjectID, UserIDSchemeUUID} {
+		first, err := GenerateUserIDs(scheme, 50, "", 42)
+		if err != nil {
+			t.Fatal(err)
+		}
+		again, _ := GenerateUserIDs(scheme, 50, "", 42)
+		other, _ := GenerateUserIDs(scheme, 50, "", 43)
+		for i := range first {
+			if first[i] != again[i] {
+				t.Errorf("scheme %s ID %d = %q then %q with the same seed", scheme, i, first[i], again[i])
+			}
+		}
+		if first[0] == other[0] {
+			t.Errorf("scheme %s seeds 42 and 43 both start with %q", scheme, first[0])
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"mail-stress-test/database"
+	"mail-stress-test/handler"
+	"mail-stress-test/internal/mongotest"
+)
+
+// TestUserTermsBias checks searches draw their term from the target user's
+// own subjects, except for the configured share of intentional misses
+func TestUserTermsBias(t *testing.T) {
+	const searches, missRatio = 10000, 0.2
+	gen := newTestGenerator(t)
+	own := map[string][]string{
+		"user-1": {"Budget Review"},
+		"user-2": {"Team Sync", "Follow Up"},
+		"user-3": {"Weekly Report"},
+		"user-4": {"Quick Question"},
+	}
+	gen.SetUserTerms(own, missRatio)
+
+	var misses int
+	for i := 0; i < searches; i++ {
+		req := gen.GenerateSearchMailsRequest()
+		if req.Miss {
+			misses++
+			if IsKnownTerm(req.SearchTerm) {
+				t.Errorf("miss term %q is a seeded subject", req.SearchTerm)
+			}
+			continue
+		}
+		if !contains(own[req.UserID], req.SearchTerm) {
+			t.Fatalf("%s searched %q, want one of their subjects %v", req.UserID, req.SearchTerm, own[req.UserID])
+		}
+	}
+	if ratio := float64(misses) / searches; ratio < missRatio-0.02 || ratio > missRatio+0.02 {
+		t.Errorf("miss ratio = %.3f, want %.2f", ratio, missRatio)
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// TestUserTermsMatchIntegration seeds mails for many users, loads each
+// user's subjects back and checks every biased search finds mails,
+// and every intentional miss finds none
+func TestUserTermsMatchIntegration(t *testing.T) {
+	mdb := mongotest.Database(t)
+	db := &database.MongoDB{
+		Client:            mdb.Client(),
+		Database:          mdb,
+		MailsCollection:   database.DefaultMailsCollection,
+		ThreadsCollection: database.DefaultThreadsCollection,
+	}
+	ctx := context.Background()
+
+	ids := make([]string, 50)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("user-%d", i+1)
+	}
+	gen, err := NewDataGenerator(ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := handler.NewDBHandler(db)
+	for i := 0; i < 100; i++ {
+		if err := h.CreateMail(ctx, gen.GenerateCreateMailRequest("")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	subjects, err := db.UserSubjects(ctx, ids, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gen.SetUserTerms(subjects, 0.1)
+
+	var searches, hits, misses int
+	for i := 0; i < 200; i++ {
+		req := gen.GenerateSearchMailsRequest()
+		if _, seeded := subjects[req.UserID]; !seeded && !req.Miss {
+			continue // an empty inbox has nothing to find
+		}
+		mails, err := h.SearchMails(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if req.Miss {
+			misses++
+			if len(mails) != 0 {
+				t.Errorf("miss term %q found %d mails", req.SearchTerm, len(mails))
+			}
+			continue
+		}
+		searches++
+		if len(mails) > 0 {
+			hits++
+		}
+	}
+	if searches == 0 || misses == 0 {
+		t.Fatalf("%d searches and %d misses, want both", searches, misses)
+	}
+	if hits != searches {
+		t.Errorf("%d of %d searches for a user's own subject found mails, want all", hits, searches)
+	}
+}
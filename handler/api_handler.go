@@ -4,11 +4,17 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"mail-stress-test/config"
 	"mail-stress-test/models"
 )
 
@@ -16,6 +22,21 @@ import (
 type APIHandler struct {
 	baseURL    string
 	httpClient *http.Client
+	breaker    *circuitBreaker
+	inFlight   *inFlightLimiter
+
+	// Per-operation deadlines ("create", "list", "search"); operations
+	// without an entry use the "default" one, if any, on top of the
+	// client-wide timeout
+	timeouts   map[string]time.Duration
+	timeoutsMu sync.Mutex
+	timedOut   map[string]int64
+
+	// phases breaks request latency into httptrace phases when set
+	phases *phaseRecorder
+
+	// protocol counts negotiated HTTP protocols and connections when set
+	protocol *protocolRecorder
 }
 
 // NewAPIHandler creates a new APIHandler
@@ -28,36 +49,235 @@ func NewAPIHandler(baseURL string) *APIHandler {
 	}
 }
 
+// SetCircuitBreaker enables fast-failing after threshold consecutive
+// connection failures, for cooldown before probing the backend again.
+// A threshold of 0 disables the breaker.
+func (h *APIHandler) SetCircuitBreaker(threshold int, cooldown time.Duration) {
+	if threshold <= 0 {
+		h.breaker = nil
+		return
+	}
+	h.breaker = newCircuitBreaker(threshold, cooldown)
+}
+
+// CircuitStats returns how many requests were rejected by the open circuit
+// and how many times the circuit opened
+func (h *APIHandler) CircuitStats() (rejections, opens int64) {
+	if h.breaker == nil {
+		return 0, 0
+	}
+	return atomic.LoadInt64(&h.breaker.rejections), atomic.LoadInt64(&h.breaker.opens)
+}
+
+// SetMaxInFlight caps concurrent in-flight requests at limit, counting a
+// request until its response body is closed. Over the cap requests wait for
+// a slot, or fail with ErrInFlightLimit if failFast is set. A limit of 0
+// disables the cap.
+func (h *APIHandler) SetMaxInFlight(limit int, failFast bool) {
+	if limit <= 0 {
+		h.inFlight = nil
+		return
+	}
+	h.inFlight = newInFlightLimiter(limit, failFast)
+}
+
+// InFlightStats reports the in-flight cap's peak, waits and rejections; ok
+// is false when no cap is set
+func (h *APIHandler) InFlightStats() (stats InFlightStats, ok bool) {
+	if h.inFlight == nil {
+		return InFlightStats{}, false
+	}
+	return h.inFlight.stats(), true
+}
+
+// SetOperationTimeouts sets a per-request deadline for each operation type,
+// keyed by "create", "list", "search" and so on. Operations with a zero or
+// missing entry fall back to the "default" entry; zero there disables it.
+func (h *APIHandler) SetOperationTimeouts(timeouts map[string]time.Duration) {
+	h.timeouts = timeouts
+}
+
+// timeout returns the per-request deadline of operation, or 0 for none
+func (h *APIHandler) timeout(operation string) time.Duration {
+	if timeout := h.timeouts[operation]; timeout > 0 {
+		return timeout
+	}
+	return h.timeouts[config.DefaultOperationTimeout]
+}
+
+// TimeoutStats returns how many requests hit their per-operation deadline
+func (h *APIHandler) TimeoutStats() map[string]int64 {
+	h.timeoutsMu.Lock()
+	defer h.timeoutsMu.Unlock()
+
+	stats := make(map[string]int64, len(h.timedOut))
+	for op, count := range h.timedOut {
+		stats[op] = count
+	}
+	return stats
+}
+
+// withTimeout derives the request context for operation
+func (h *APIHandler) withTimeout(ctx context.Context, operation string) (context.Context, context.CancelFunc) {
+	if h.phases != nil {
+		ctx = withOperation(ctx, operation)
+	}
+	if timeout := h.timeout(operation); timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return ctx, func() {}
+}
+
+// recordTimeout counts err against operation if the per-operation deadline
+// expired while the caller's context was still live
+func (h *APIHandler) recordTimeout(parent, ctx context.Context, operation string, err error) error {
+	if err == nil || parent.Err() != nil || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+
+	h.timeoutsMu.Lock()
+	if h.timedOut == nil {
+		h.timedOut = make(map[string]int64)
+	}
+	h.timedOut[operation]++
+	h.timeoutsMu.Unlock()
+
+	return fmt.Errorf("%s timed out after %s: %w", operation, h.timeout(operation), err)
+}
+
+// do tags the request with a correlation ID and sends it through the
+// in-flight cap, phase tracing and the circuit breaker, if enabled
+func (h *APIHandler) do(req *http.Request) (*http.Response, error) {
+	tagRequest(req)
+	send := h.send
+	if h.phases != nil {
+		send = h.traced
+	}
+	if h.protocol != nil {
+		send = h.recordProtocol(send)
+	}
+	if h.inFlight == nil {
+		return send(req)
+	}
+
+	if err := h.inFlight.acquire(req.Context()); err != nil {
+		return nil, err
+	}
+	resp, err := send(req)
+	if err != nil {
+		h.inFlight.release()
+		return nil, err
+	}
+	resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: h.inFlight.release}
+	return resp, nil
+}
+
+// recordProtocol wraps send to count the protocol of each response
+func (h *APIHandler) recordProtocol(send func(*http.Request) (*http.Response, error)) func(*http.Request) (*http.Response, error) {
+	return func(req *http.Request) (*http.Response, error) {
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), h.protocol.trace()))
+		resp, err := send(req)
+		if err == nil {
+			h.protocol.record(resp.Proto)
+		}
+		return resp, err
+	}
+}
+
+// send sends the request through the circuit breaker, if enabled
+func (h *APIHandler) send(req *http.Request) (*http.Response, error) {
+	if h.breaker == nil {
+		return h.httpClient.Do(req)
+	}
+
+	if err := h.breaker.allow(); err != nil {
+		return nil, err
+	}
+
+	resp, err := h.httpClient.Do(req)
+	h.breaker.record(err)
+	return resp, err
+}
+
+// maxBodyDrain bounds how much of an unread response body closeBody reads
+const maxBodyDrain = 64 << 10
+
+// closeBody drains what's left of a response body (trailing whitespace after
+// a decoded JSON value, an error page) before closing it, so the keep-alive
+// connection returns to the idle pool instead of being torn down and
+// re-dialed
+func closeBody(body io.ReadCloser) error {
+	io.Copy(io.Discard, io.LimitReader(body, maxBodyDrain))
+	return body.Close()
+}
+
 // CreateMail creates a mail via API call
 func (h *APIHandler) CreateMail(ctx context.Context, req *models.MailRequest) error {
+	opCtx, cancel := h.withTimeout(ctx, "create")
+	defer cancel()
+	_, err := h.createMail(opCtx, req)
+	return h.recordTimeout(ctx, opCtx, "create", err)
+}
+
+// CreateMailWithID creates a mail via API call and returns the "id" (or
+// "_id") the backend answers with
+func (h *APIHandler) CreateMailWithID(ctx context.Context, req *models.MailRequest) (string, error) {
+	opCtx, cancel := h.withTimeout(ctx, "create")
+	defer cancel()
+	id, err := h.createMail(opCtx, req)
+	if err == nil && id == "" {
+		err = fmt.Errorf("create response carries no mail id")
+	}
+	return id, h.recordTimeout(ctx, opCtx, "create", err)
+}
+
+func (h *APIHandler) createMail(ctx context.Context, req *models.MailRequest) (string, error) {
 	body, err := json.Marshal(req)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", h.baseURL+"/api/mails", bytes.NewBuffer(body))
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	httpReq.Header.Set("Content-Type", "application/json")
 
-	resp, err := h.httpClient.Do(httpReq)
+	resp, err := h.do(httpReq)
 	if err != nil {
-		return err
+		return "", err
 	}
-	defer resp.Body.Close()
+	defer closeBody(resp.Body)
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API error: status code %d, body: %s", resp.StatusCode, string(bodyBytes))
+		return "", &StatusError{StatusCode: resp.StatusCode, Body: string(bodyBytes)}
 	}
 
-	return nil
+	// The created mail's ID is optional in the response
+	var created struct {
+		ID      string `json:"id"`
+		MongoID string `json:"_id"`
+	}
+	if json.NewDecoder(resp.Body).Decode(&created) != nil {
+		return "", nil
+	}
+	if created.ID != "" {
+		return created.ID, nil
+	}
+	return created.MongoID, nil
 }
 
 // ListMails retrieves mails via API call
 func (h *APIHandler) ListMails(ctx context.Context, req *models.ListMailsRequest) ([]*models.Mail, error) {
+	opCtx, cancel := h.withTimeout(ctx, "list")
+	defer cancel()
+	mails, err := h.listMails(opCtx, req)
+	return mails, h.recordTimeout(ctx, opCtx, "list", err)
+}
+
+func (h *APIHandler) listMails(ctx context.Context, req *models.ListMailsRequest) ([]*models.Mail, error) {
 	body, err := json.Marshal(req)
 	if err != nil {
 		return nil, err
@@ -70,15 +290,15 @@ func (h *APIHandler) ListMails(ctx context.Context, req *models.ListMailsRequest
 
 	httpReq.Header.Set("Content-Type", "application/json")
 
-	resp, err := h.httpClient.Do(httpReq)
+	resp, err := h.do(httpReq)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	defer closeBody(resp.Body)
 
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error: status code %d, body: %s", resp.StatusCode, string(bodyBytes))
+		return nil, &StatusError{StatusCode: resp.StatusCode, Body: string(bodyBytes)}
 	}
 
 	var mails []*models.Mail
@@ -89,8 +309,93 @@ func (h *APIHandler) ListMails(ctx context.Context, req *models.ListMailsRequest
 	return mails, nil
 }
 
+// GetMail fetches one mail via API call
+func (h *APIHandler) GetMail(ctx context.Context, mailID string) (*models.Mail, error) {
+	opCtx, cancel := h.withTimeout(ctx, "read")
+	defer cancel()
+	mail, err := h.getMail(opCtx, mailID)
+	return mail, h.recordTimeout(ctx, opCtx, "read", err)
+}
+
+func (h *APIHandler) getMail(ctx context.Context, mailID string) (*models.Mail, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", h.baseURL+"/api/mails/"+url.PathEscape(mailID), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := h.do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp.Body)
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrMailNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, &StatusError{StatusCode: resp.StatusCode, Body: string(bodyBytes)}
+	}
+
+	var mail models.Mail
+	if err := json.NewDecoder(resp.Body).Decode(&mail); err != nil {
+		return nil, err
+	}
+
+	return &mail, nil
+}
+
+// CountMails counts a user's mails via API call
+func (h *APIHandler) CountMails(ctx context.Context, userID string) (int64, error) {
+	opCtx, cancel := h.withTimeout(ctx, "count")
+	defer cancel()
+	count, err := h.countMails(opCtx, userID)
+	return count, h.recordTimeout(ctx, opCtx, "count", err)
+}
+
+func (h *APIHandler) countMails(ctx context.Context, userID string) (int64, error) {
+	body, err := json.Marshal(map[string]string{"userId": userID})
+	if err != nil {
+		return 0, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", h.baseURL+"/api/mails/count", bytes.NewBuffer(body))
+	if err != nil {
+		return 0, err
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := h.do(httpReq)
+	if err != nil {
+		return 0, err
+	}
+	defer closeBody(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return 0, &StatusError{StatusCode: resp.StatusCode, Body: string(bodyBytes)}
+	}
+
+	var result struct {
+		Count int64 `json:"count"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, err
+	}
+
+	return result.Count, nil
+}
+
 // SearchMails searches for mails via API call
 func (h *APIHandler) SearchMails(ctx context.Context, req *models.SearchMailsRequest) ([]*models.Mail, error) {
+	opCtx, cancel := h.withTimeout(ctx, "search")
+	defer cancel()
+	mails, err := h.searchMails(opCtx, req)
+	return mails, h.recordTimeout(ctx, opCtx, "search", err)
+}
+
+func (h *APIHandler) searchMails(ctx context.Context, req *models.SearchMailsRequest) ([]*models.Mail, error) {
 	body, err := json.Marshal(req)
 	if err != nil {
 		return nil, err
@@ -103,15 +408,15 @@ func (h *APIHandler) SearchMails(ctx context.Context, req *models.SearchMailsReq
 
 	httpReq.Header.Set("Content-Type", "application/json")
 
-	resp, err := h.httpClient.Do(httpReq)
+	resp, err := h.do(httpReq)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	defer closeBody(resp.Body)
 
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error: status code %d, body: %s", resp.StatusCode, string(bodyBytes))
+		return nil, &StatusError{StatusCode: resp.StatusCode, Body: string(bodyBytes)}
 	}
 
 	var mails []*models.Mail
@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"mail-stress-test/config"
+	"mail-stress-test/models"
+)
+
+// TestOperationTimeouts serves every operation slowly and checks a list
+// fails at its own short deadline, a create outlives it under its longer
+// one, and a search without an entry falls back to the default
+func TestOperationTimeouts(t *testing.T) {
+	const serverDelay = 100 * time.Millisecond
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(serverDelay):
+		case <-r.Context().Done():
+			return
+		}
+		if r.URL.Path == "/api/mails" {
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{}`)
+			return
+		}
+		fmt.Fprint(w, `[]`)
+	}))
+	defer server.Close()
+
+	h := NewAPIHandler(server.URL)
+	h.SetOperationTimeouts(config.OperationTimeouts{
+		Default: 30 * time.Millisecond,
+		Create:  time.Second,
+		List:    20 * time.Millisecond,
+	}.ByOperation())
+	ctx := context.Background()
+
+	start := time.Now()
+	_, err := h.ListMails(ctx, &models.ListMailsRequest{UserID: "user-1"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("slow list = %v, want its deadline exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed >= serverDelay {
+		t.Errorf("list failed after %s, want its 20ms deadline, not the server's %s", elapsed, serverDelay)
+	}
+
+	if err := h.CreateMail(ctx, &models.MailRequest{From: "user-1", To: []string{"user-2"}}); err != nil {
+		t.Errorf("create under its own 1s deadline failed: %v", err)
+	}
+
+	if _, err := h.SearchMails(ctx, &models.SearchMailsRequest{UserID: "user-1", SearchTerm: "x"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("search without its own timeout = %v, want the default deadline exceeded", err)
+	}
+
+	stats := h.TimeoutStats()
+	if stats["list"] != 1 || stats["search"] != 1 || stats["create"] != 0 {
+		t.Errorf("TimeoutStats = %v, want one list and one search timeout", stats)
+	}
+}
+
+// TestOperationTimeoutCallerCancel checks a cancelled caller is not counted
+// as an operation timeout
+func TestOperationTimeoutCallerCancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The server only notices the client leaving once the body is read
+		io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	h := NewAPIHandler(server.URL)
+	h.SetOperationTimeouts(config.OperationTimeouts{List: time.Second}.ByOperation())
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if _, err := h.ListMails(ctx, &models.ListMailsRequest{UserID: "user-1"}); err == nil {
+		t.Fatal("list succeeded against a server that never answers")
+	}
+	if stats := h.TimeoutStats(); stats["list"] != 0 {
+		t.Errorf("TimeoutStats = %v, want the caller's deadline not counted", stats)
+	}
+}
+
+// TestCountTimeout checks a count is bounded by its own timeout rather than
+// the longer default
+func TestCountTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(200 * time.Millisecond):
+		case <-r.Context().Done():
+			return
+		}
+		fmt.Fprint(w, `{"count":3}`)
+	}))
+	defer server.Close()
+
+	h := NewAPIHandler(server.URL)
+	h.SetOperationTimeouts(config.OperationTimeouts{Default: time.Second, Count: 20 * time.Millisecond}.ByOperation())
+
+	start := time.Now()
+	if _, err := h.CountMails(context.Background(), "user-1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("slow count = %v, want its deadline exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed >= 200*time.Millisecond {
+		t.Errorf("count failed after %s, want its 20ms deadline", elapsed)
+	}
+	if stats := h.TimeoutStats(); stats["count"] != 1 {
+		t.Errorf("TimeoutStats = %v, want one count timeout", stats)
+	}
+}
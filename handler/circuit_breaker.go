@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// ErrCircuitOpen is returned without contacting the backend while the circuit
+// breaker is open after repeated connection failures
+var ErrCircuitOpen = errors.New("circuit breaker open: backend unreachable")
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// circuitBreaker opens after threshold consecutive connection failures,
+// rejects requests for cooldown, then lets a single probe through (half-open).
+// A successful probe closes the circuit; a failed one re-opens it.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu                  sync.Mutex
+	state               circuitState
+	consecutiveFailures int
+	openedAt            time.Time
+
+	rejections int64
+	opens      int64
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// allow returns ErrCircuitOpen if the request must be short-circuited
+func (cb *circuitBreaker) allow() error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case circuitOpen:
+		if time.Since(cb.openedAt) < cb.cooldown {
+			atomic.AddInt64(&cb.rejections, 1)
+			return ErrCircuitOpen
+		}
+		// Cooldown elapsed: let this request probe the backend
+		cb.state = circuitHalfOpen
+		return nil
+	case circuitHalfOpen:
+		// Only one probe at a time
+		atomic.AddInt64(&cb.rejections, 1)
+		return ErrCircuitOpen
+	default:
+		return nil
+	}
+}
+
+// record updates the breaker with the outcome of a request. Only connection
+// errors (refused, reset, closed before a response) count as failures; HTTP
+// error statuses mean the backend is reachable, and timeouts mean it is slow,
+// which is latency to report rather than load to shed.
+func (cb *circuitBreaker) record(err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if err != nil && !isConnectionError(err) {
+		// Cancelled by the caller or timed out, says nothing about whether
+		// the backend is reachable
+		if cb.state == circuitHalfOpen {
+			cb.state = circuitOpen
+		}
+		return
+	}
+
+	if err == nil {
+		cb.state = circuitClosed
+		cb.consecutiveFailures = 0
+		return
+	}
+
+	cb.consecutiveFailures++
+	if cb.state == circuitHalfOpen || cb.consecutiveFailures >= cb.threshold {
+		if cb.state != circuitOpen {
+			atomic.AddInt64(&cb.opens, 1)
+		}
+		cb.state = circuitOpen
+		cb.openedAt = time.Now()
+	}
+}
+
+// isConnectionError reports whether err means the backend could not be
+// reached: the dial failed, or the connection was refused, reset or closed
+// before a response arrived. Timeouts and cancellations are not.
+func isConnectionError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return false
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,201 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// flakyBackend is a count endpoint on a fixed address that can be taken down
+// (connections refused) and brought back up
+type flakyBackend struct {
+	t      *testing.T
+	addr   string
+	server *http.Server
+}
+
+func newFlakyBackend(t *testing.T) *flakyBackend {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &flakyBackend{t: t, addr: ln.Addr().String()}
+	b.serve(ln)
+	t.Cleanup(func() { b.server.Close() })
+	return b
+}
+
+func (b *flakyBackend) serve(ln net.Listener) {
+	b.server = &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"count": 1}`)
+	})}
+	go b.server.Serve(ln)
+}
+
+func (b *flakyBackend) down() {
+	b.server.Close()
+}
+
+func (b *flakyBackend) up() {
+	b.t.Helper()
+	ln, err := net.Listen("tcp", b.addr)
+	if err != nil {
+		b.t.Fatalf("restart backend on %s: %v", b.addr, err)
+	}
+	b.serve(ln)
+}
+
+// TestCircuitBreakerOpensAndRecovers takes the backend down mid-run and
+// brings it back, checking the breaker opens, fails fast and counts its
+// rejections, re-opens on a failed probe, and closes once a probe succeeds
+func TestCircuitBreakerOpensAndRecovers(t *testing.T) {
+	const threshold, cooldown = 3, 100 * time.Millisecond
+	backend := newFlakyBackend(t)
+	h := NewAPIHandler("http://" + backend.addr)
+	h.SetCircuitBreaker(threshold, cooldown)
+	ctx := context.Background()
+
+	if _, err := h.CountMails(ctx, "user-1"); err != nil {
+		t.Fatalf("healthy backend: %v", err)
+	}
+
+	backend.down()
+	for i := 0; i < threshold; i++ {
+		_, err := h.CountMails(ctx, "user-1")
+		if err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("request %d to the down backend = %v, want a connection error", i, err)
+		}
+	}
+	if _, opens := h.CircuitStats(); opens != 1 {
+		t.Fatalf("opens = %d after %d connection failures, want 1", opens, threshold)
+	}
+
+	start := time.Now()
+	if _, err := h.CountMails(ctx, "user-1"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("request while open = %v, want ErrCircuitOpen", err)
+	}
+	if elapsed := time.Since(start); elapsed > cooldown/2 {
+		t.Errorf("rejection took %s, want a fast fail", elapsed)
+	}
+	if rejections, _ := h.CircuitStats(); rejections != 1 {
+		t.Errorf("rejections = %d, want 1", rejections)
+	}
+
+	// The half-open probe still finds the backend down and re-opens
+	time.Sleep(cooldown)
+	if _, err := h.CountMails(ctx, "user-1"); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("probe of the down backend = %v, want a connection error", err)
+	}
+	if _, err := h.CountMails(ctx, "user-1"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("request after a failed probe = %v, want ErrCircuitOpen", err)
+	}
+	if rejections, opens := h.CircuitStats(); rejections != 2 || opens != 2 {
+		t.Errorf("CircuitStats = %d rejections, %d opens; want 2, 2", rejections, opens)
+	}
+
+	backend.up()
+	time.Sleep(cooldown)
+	for i := 0; i < threshold+1; i++ {
+		if _, err := h.CountMails(ctx, "user-1"); err != nil {
+			t.Fatalf("request %d after recovery: %v", i, err)
+		}
+	}
+	if rejections, opens := h.CircuitStats(); rejections != 2 || opens != 2 {
+		t.Errorf("CircuitStats after recovery = %d rejections, %d opens; want 2, 2", rejections, opens)
+	}
+}
+
+// TestCircuitBreakerHalfOpenCancel cancels the half-open probe and checks the
+// breaker goes back to open, letting the next request probe instead of
+// rejecting everything as if a probe were still in flight
+func TestCircuitBreakerHalfOpenCancel(t *testing.T) {
+	const cooldown = 20 * time.Millisecond
+	cb := newCircuitBreaker(1, cooldown)
+	cb.record(&net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED})
+	if err := cb.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("allow while open = %v, want ErrCircuitOpen", err)
+	}
+
+	time.Sleep(cooldown)
+	if err := cb.allow(); err != nil {
+		t.Fatalf("probe after cooldown rejected: %v", err)
+	}
+	if err := cb.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("second request while half-open = %v, want ErrCircuitOpen", err)
+	}
+
+	cb.record(context.Canceled)
+	if cb.state != circuitOpen {
+		t.Fatalf("state after a cancelled probe = %d, want open", cb.state)
+	}
+	if cb.opens != 1 {
+		t.Errorf("opens = %d, want 1: a cancelled probe is not a backend failure", cb.opens)
+	}
+	if err := cb.allow(); err != nil {
+		t.Fatalf("next probe after a cancelled one rejected: %v", err)
+	}
+	cb.record(nil)
+	if cb.state != circuitClosed {
+		t.Errorf("state after a successful probe = %d, want closed", cb.state)
+	}
+}
+
+// TestCircuitBreakerIgnoresSlowBackend times out every request against a
+// backend that is up but slower than the operation timeout, and checks the
+// breaker stays closed so the run reports the latency instead of shedding load
+func TestCircuitBreakerIgnoresSlowBackend(t *testing.T) {
+	const threshold = 2
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+		fmt.Fprint(w, `{"count": 1}`)
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+
+	h := NewAPIHandler(server.URL)
+	h.SetCircuitBreaker(threshold, time.Minute)
+	h.SetOperationTimeouts(map[string]time.Duration{"count": 20 * time.Millisecond})
+
+	for i := 0; i < 3*threshold; i++ {
+		_, err := h.CountMails(context.Background(), "user-1")
+		if err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("request %d to the slow backend = %v, want a timeout", i, err)
+		}
+	}
+	if rejections, opens := h.CircuitStats(); rejections != 0 || opens != 0 {
+		t.Errorf("CircuitStats = %d rejections, %d opens; want the breaker left closed", rejections, opens)
+	}
+}
+
+func TestIsConnectionError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, true},
+		{fmt.Errorf("read: %w", syscall.ECONNRESET), true},
+		{fmt.Errorf("Get: %w", io.EOF), true},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: os.ErrDeadlineExceeded}, false},
+		{fmt.Errorf("Get: %w", context.DeadlineExceeded), false},
+		{context.Canceled, false},
+		{errors.New("unexpected status 500"), false},
+	}
+	for _, tt := range tests {
+		if got := isConnectionError(tt.err); got != tt.want {
+			t.Errorf("isConnectionError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
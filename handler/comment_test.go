@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"context"
+	"testing"
+
+	"mail-stress-test/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// TestQueriesCarryComment checks list and search options carry the run's
+// comment for their operation and a count sends it to the server
+func TestQueriesCarryComment(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("comments", func(mt *mtest.T) {
+		db := newMockDB(mt)
+		db.SetRunID("run-1")
+		h := NewDBHandler(db)
+
+		if _, opts := h.listQuery(&models.ListMailsRequest{UserID: "user-1"}); opts.Comment == nil || *opts.Comment != "mail-stress-test run=run-1 op=list" {
+			mt.Errorf("list comment = %v", opts.Comment)
+		}
+		if _, opts := h.searchQuery(&models.SearchMailsRequest{UserID: "user-1", SearchTerm: "x"}); opts.Comment == nil || *opts.Comment != "mail-stress-test run=run-1 op=search" {
+			mt.Errorf("search comment = %v", opts.Comment)
+		}
+
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, mt.DB.Name()+"."+db.MailsCollection, mtest.FirstBatch,
+			bson.D{{Key: "n", Value: int32(3)}}))
+		if _, err := h.CountMails(context.Background(), "user-1"); err != nil {
+			mt.Fatal(err)
+		}
+		if comment := mt.GetStartedEvent().Command.Lookup("comment").StringValue(); comment != "mail-stress-test run=run-1 op=count" {
+			mt.Errorf("count sent comment %q", comment)
+		}
+	})
+}
@@ -2,10 +2,16 @@ package handler
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"mail-stress-test/database"
 	"mail-stress-test/models"
+	"mail-stress-test/search"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -13,20 +19,145 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// defaultThreadUpdateRetries bounds retries of a conflicting thread upsert
+const defaultThreadUpdateRetries = 3
+
+// defaultSnippetLength is the number of content characters in a list-view snippet
+const defaultSnippetLength = 100
+
+// writeConflictCode is MongoDB's WriteConflict error code
+const writeConflictCode = 112
+
+// threadRetryBackoff is the base delay before retrying a thread upsert;
+// attempt n waits a random duration up to threadRetryBackoff << n, so
+// upserts that conflicted don't collide again in lockstep
+const threadRetryBackoff = 5 * time.Millisecond
+
 // DBHandler implements MailHandler with direct database operations
 type DBHandler struct {
-	db *database.MongoDB
+	db                 *database.MongoDB
+	maxThreadRetries   int
+	threadRetries      int64 // retries performed after a duplicate-key/write-conflict
+	threadRetryFailure int64 // thread updates that still failed after all retries
+	retryBudget        *retryBudget
+	clockOffset        time.Duration
+	snippetLength      int
+	includeDeleted     bool // list and search also return tombstoned mails
+	inFlight           *inFlightLimiter
 }
 
 // NewDBHandler creates a new DBHandler
 func NewDBHandler(db *database.MongoDB) *DBHandler {
-	return &DBHandler{db: db}
+	return &DBHandler{db: db, maxThreadRetries: defaultThreadUpdateRetries, snippetLength: defaultSnippetLength}
+}
+
+// SetMaxThreadRetries sets how many times a thread upsert is retried after a
+// duplicate-key or write-conflict error. Zero disables retries.
+func (h *DBHandler) SetMaxThreadRetries(n int) {
+	if n < 0 {
+		n = 0
+	}
+	h.maxThreadRetries = n
+}
+
+// SetRetryBudget caps thread upsert retries at perSecond across all
+// callers; retries beyond it fail instead. Zero removes the cap.
+func (h *DBHandler) SetRetryBudget(perSecond float64) {
+	if perSecond <= 0 {
+		h.retryBudget = nil
+		return
+	}
+	h.retryBudget = newRetryBudget(perSecond)
+}
+
+// SetMaxInFlight caps concurrent database operations at limit, applying
+// back-pressure when MongoDB is the bottleneck. Over the cap operations wait
+// for a slot, or are shed with ErrInFlightLimit if shed is set. A limit of 0
+// disables the cap.
+func (h *DBHandler) SetMaxInFlight(limit int, shed bool) {
+	if limit <= 0 {
+		h.inFlight = nil
+		return
+	}
+	h.inFlight = newInFlightLimiter(limit, shed)
+}
+
+// InFlightStats reports the in-flight cap's peak, waits and shed operations;
+// ok is false when no cap is set
+func (h *DBHandler) InFlightStats() (stats InFlightStats, ok bool) {
+	if h.inFlight == nil {
+		return InFlightStats{}, false
+	}
+	return h.inFlight.stats(), true
+}
+
+// SetClockOffset shifts the timestamps this handler writes by offset (server
+// minus client), so stored times line up with the server's clock
+func (h *DBHandler) SetClockOffset(offset time.Duration) {
+	h.clockOffset = offset
+}
+
+// SetSnippetLength sets how many content characters list-view results carry
+func (h *DBHandler) SetSnippetLength(n int) {
+	if n <= 0 {
+		n = defaultSnippetLength
+	}
+	h.snippetLength = n
+}
+
+// listViewProjection returns only the fields a list view renders, with the
+// content cut down to a snippet on the server
+func (h *DBHandler) listViewProjection() bson.M {
+	return bson.M{
+		"subject":   1,
+		"from":      1,
+		"createdAt": 1,
+		"isRead":    1,
+		"snippet":   bson.M{"$substrCP": bson.A{bson.M{"$ifNull": bson.A{"$content", ""}}, 0, h.snippetLength}},
+	}
+}
+
+// now returns the current time corrected for clock offset
+func (h *DBHandler) now() time.Time {
+	return time.Now().Add(h.clockOffset)
+}
+
+// sentAt returns when req is stored as sent: its SentAt if set, shifted to
+// the server's clock like now, or now
+func (h *DBHandler) sentAt(req *models.MailRequest) time.Time {
+	if req.SentAt == nil {
+		return h.now()
+	}
+	return req.SentAt.Add(h.clockOffset)
+}
+
+// RetryStats returns the number of retried thread updates and how many of
+// them were exhausted without succeeding
+func (h *DBHandler) RetryStats() (retries, exhausted int64) {
+	return atomic.LoadInt64(&h.threadRetries), atomic.LoadInt64(&h.threadRetryFailure)
+}
+
+// RetryBudgetDenied returns how many retries the retry budget refused
+func (h *DBHandler) RetryBudgetDenied() int64 {
+	return h.retryBudget.deniedCount()
 }
 
 // CreateMail creates a new mail with proper threading logic
 func (h *DBHandler) CreateMail(ctx context.Context, req *models.MailRequest) error {
-	mailCollection := h.db.Database.Collection("mails")
-	threadCollection := h.db.Database.Collection("threads")
+	_, err := h.CreateMailWithID(ctx, req)
+	return err
+}
+
+// CreateMailWithID creates a new mail and returns the ID of the sender's
+// copy, which exists even if delivery to recipients fails
+func (h *DBHandler) CreateMailWithID(ctx context.Context, req *models.MailRequest) (string, error) {
+	release, err := h.inFlight.admit(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	mailCollection := h.db.Mails()
 
 	// Determine thread ID
 	var threadID string
@@ -35,11 +166,12 @@ func (h *DBHandler) CreateMail(ctx context.Context, req *models.MailRequest) err
 		var originalMail models.Mail
 		objID, err := primitive.ObjectIDFromHex(req.ReplyTo)
 		if err != nil {
-			return err
+			return "", err
 		}
-		err = mailCollection.FindOne(ctx, bson.M{"_id": objID}).Decode(&originalMail)
+		err = mailCollection.FindOne(ctx, bson.M{"_id": objID},
+			options.FindOne().SetComment(h.db.QueryComment("reply_lookup"))).Decode(&originalMail)
 		if err != nil {
-			return err
+			return "", err
 		}
 		threadID = originalMail.ThreadID
 	} else {
@@ -60,53 +192,62 @@ func (h *DBHandler) CreateMail(ctx context.Context, req *models.MailRequest) err
 		ReplyTo:   req.ReplyTo,
 		ThreadID:  threadID,
 		UserID:    req.From,
-		CreatedAt: time.Now(),
+		CreatedAt: h.sentAt(req),
 	}
 
 	// Insert sender's mail
 	if _, err := mailCollection.InsertOne(ctx, senderMail); err != nil {
-		return err
+		return "", err
 	}
 
+	return senderMail.ID.Hex(), h.deliver(ctx, senderMail)
+}
+
+// deliver updates the sender's thread and creates a received copy and thread
+// entry for every recipient of a sent mail
+func (h *DBHandler) deliver(ctx context.Context, senderMail *models.Mail) error {
+	mailCollection := h.db.Mails()
+	threadCollection := h.db.Threads()
+	threadID := senderMail.ThreadID
+
 	// Create thread mail metadata
 	threadMail := models.ThreadMail{
-		From:    req.From,
+		From:    senderMail.From,
 		MsgID:   senderMail.ID.Hex(),
-		Subject: req.Subject,
-		Content: req.Content,
-		Cc:      req.Cc,
-		To:      req.To,
-		Bcc:     req.Bcc,
+		Subject: senderMail.Subject,
+		Content: senderMail.Content,
+		Cc:      senderMail.Cc,
+		To:      senderMail.To,
+		Bcc:     senderMail.Bcc,
 		Type:    1, // sent
 	}
 
 	// Update sender's thread
-	senderIDObj, _ := primitive.ObjectIDFromHex(req.From)
-	if err := h.updateThread(ctx, threadCollection, senderIDObj, threadID, threadMail); err != nil {
+	if err := h.updateThread(ctx, threadCollection, senderMail.From, threadID, threadMail); err != nil {
 		return err
 	}
 
 	// Create mails for all recipients (To, Cc, Bcc)
 	allRecipients := make([]string, 0)
-	allRecipients = append(allRecipients, req.To...)
-	allRecipients = append(allRecipients, req.Cc...)
-	allRecipients = append(allRecipients, req.Bcc...)
+	allRecipients = append(allRecipients, senderMail.To...)
+	allRecipients = append(allRecipients, senderMail.Cc...)
+	allRecipients = append(allRecipients, senderMail.Bcc...)
 
 	for _, recipientID := range allRecipients {
-		if recipientID == req.From {
+		if recipientID == senderMail.From {
 			continue // Skip sender
 		}
 
 		recipientMail := &models.Mail{
 			ID:        primitive.NewObjectID(),
-			From:      req.From,
-			To:        req.To,
-			Cc:        req.Cc,
-			Bcc:       req.Bcc,
-			Subject:   req.Subject,
-			Content:   req.Content,
+			From:      senderMail.From,
+			To:        senderMail.To,
+			Cc:        senderMail.Cc,
+			Bcc:       senderMail.Bcc,
+			Subject:   senderMail.Subject,
+			Content:   senderMail.Content,
 			Type:      0, // received
-			ReplyTo:   req.ReplyTo,
+			ReplyTo:   senderMail.ReplyTo,
 			ThreadID:  threadID,
 			UserID:    recipientID,
 			CreatedAt: senderMail.CreatedAt,
@@ -120,8 +261,7 @@ func (h *DBHandler) CreateMail(ctx context.Context, req *models.MailRequest) err
 		recipientThreadMail := threadMail
 		recipientThreadMail.Type = 0 // received
 
-		recipientIDObj, _ := primitive.ObjectIDFromHex(recipientID)
-		if err := h.updateThread(ctx, threadCollection, recipientIDObj, threadID, recipientThreadMail); err != nil {
+		if err := h.updateThread(ctx, threadCollection, recipientID, threadID, recipientThreadMail); err != nil {
 			return err
 		}
 	}
@@ -129,21 +269,232 @@ func (h *DBHandler) CreateMail(ctx context.Context, req *models.MailRequest) err
 	return nil
 }
 
-// ListMails retrieves mails for a user
-func (h *DBHandler) ListMails(ctx context.Context, req *models.ListMailsRequest) ([]*models.Mail, error) {
-	collection := h.db.Database.Collection("mails")
+// ForwardMail copies an existing mail into a new thread with a "Fwd:" subject
+// and quoted content, and delivers it to the new recipients
+func (h *DBHandler) ForwardMail(ctx context.Context, req *models.ForwardMailRequest) error {
+	release, err := h.inFlight.admit(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
 
-	filter := bson.M{"userId": req.UserID}
-	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+	objID, err := primitive.ObjectIDFromHex(req.MailID)
+	if err != nil {
+		return err
+	}
 
-	if req.Limit > 0 {
-		opts.SetLimit(int64(req.Limit))
+	var original models.Mail
+	if err := h.db.Mails().FindOne(ctx, bson.M{"_id": objID},
+		options.FindOne().SetComment(h.db.QueryComment("forward"))).Decode(&original); err != nil {
+		return err
 	}
-	if req.Offset > 0 {
-		opts.SetSkip(int64(req.Offset))
+
+	forwarded := &models.Mail{
+		ID:        primitive.NewObjectID(),
+		From:      req.From,
+		To:        req.To,
+		Cc:        req.Cc,
+		Bcc:       req.Bcc,
+		Subject:   forwardSubject(original.Subject),
+		Content:   forwardContent(&original),
+		Type:      MailTypeSent,
+		ThreadID:  primitive.NewObjectID().Hex(),
+		UserID:    req.From,
+		CreatedAt: h.now(),
 	}
 
-	cursor, err := collection.Find(ctx, filter, opts)
+	if _, err := h.db.Mails().InsertOne(ctx, forwarded); err != nil {
+		return err
+	}
+
+	return h.deliver(ctx, forwarded)
+}
+
+// ReplyAll replies to the original sender plus every To/Cc recipient of the
+// parent mail, minus the replier, within the parent's thread. Bcc recipients
+// are not revealed to other participants and are left out.
+func (h *DBHandler) ReplyAll(ctx context.Context, req *models.ReplyAllRequest) error {
+	release, err := h.inFlight.admit(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	objID, err := primitive.ObjectIDFromHex(req.MailID)
+	if err != nil {
+		return err
+	}
+
+	var parent models.Mail
+	if err := h.db.Mails().FindOne(ctx, bson.M{"_id": objID},
+		options.FindOne().SetComment(h.db.QueryComment("reply_all"))).Decode(&parent); err != nil {
+		return err
+	}
+
+	to, cc := replyAllRecipients(&parent, req.From)
+	reply := &models.Mail{
+		ID:        primitive.NewObjectID(),
+		From:      req.From,
+		To:        to,
+		Cc:        cc,
+		Subject:   replySubject(parent.Subject),
+		Content:   req.Content,
+		Type:      MailTypeSent,
+		ReplyTo:   req.MailID,
+		ThreadID:  parent.ThreadID,
+		UserID:    req.From,
+		CreatedAt: h.now(),
+	}
+
+	if _, err := h.db.Mails().InsertOne(ctx, reply); err != nil {
+		return err
+	}
+
+	return h.deliver(ctx, reply)
+}
+
+// replyAllRecipients addresses the parent's sender and To recipients in To and
+// keeps its Cc recipients in Cc, deduplicated and without the replier
+func replyAllRecipients(parent *models.Mail, replier string) (to, cc []string) {
+	seen := map[string]bool{replier: true}
+	add := func(list []string, ids ...string) []string {
+		for _, id := range ids {
+			if !seen[id] {
+				seen[id] = true
+				list = append(list, id)
+			}
+		}
+		return list
+	}
+
+	to = add(to, parent.From)
+	to = add(to, parent.To...)
+	cc = add(cc, parent.Cc...)
+	return to, cc
+}
+
+// replySubject prefixes subject with "Re: " unless it already has one
+func replySubject(subject string) string {
+	if strings.HasPrefix(subject, "Re: ") {
+		return subject
+	}
+	return "Re: " + subject
+}
+
+// forwardSubject prefixes subject with "Fwd: " unless it already has one
+func forwardSubject(subject string) string {
+	if strings.HasPrefix(subject, "Fwd: ") {
+		return subject
+	}
+	return "Fwd: " + subject
+}
+
+// forwardContent quotes the original mail below a forwarded-message header
+func forwardContent(original *models.Mail) string {
+	var b strings.Builder
+	b.WriteString("---------- Forwarded message ----------\n")
+	fmt.Fprintf(&b, "From: %s\nSubject: %s\n\n", original.From, original.Subject)
+	for _, line := range strings.Split(original.Content, "\n") {
+		b.WriteString("> " + line + "\n")
+	}
+	return b.String()
+}
+
+// SaveDraft stores req as a draft in the sender's mailbox without delivering it
+func (h *DBHandler) SaveDraft(ctx context.Context, req *models.MailRequest) (string, error) {
+	release, err := h.inFlight.admit(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	draft := &models.Mail{
+		ID:        primitive.NewObjectID(),
+		From:      req.From,
+		To:        req.To,
+		Cc:        req.Cc,
+		Bcc:       req.Bcc,
+		Subject:   req.Subject,
+		Content:   req.Content,
+		Type:      MailTypeDraft,
+		ReplyTo:   req.ReplyTo,
+		ThreadID:  primitive.NewObjectID().Hex(),
+		UserID:    req.From,
+		CreatedAt: h.now(),
+	}
+
+	if _, err := h.db.Mails().InsertOne(ctx, draft); err != nil {
+		return "", err
+	}
+	return draft.ID.Hex(), nil
+}
+
+// UpdateDraft edits a draft's subject and content in place
+func (h *DBHandler) UpdateDraft(ctx context.Context, draftID string, update *models.DraftUpdate) error {
+	release, err := h.inFlight.admit(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	objID, err := primitive.ObjectIDFromHex(draftID)
+	if err != nil {
+		return err
+	}
+
+	res, err := h.db.Mails().UpdateOne(ctx,
+		bson.M{"_id": objID, "type": MailTypeDraft},
+		bson.M{"$set": bson.M{"subject": update.Subject, "content": update.Content}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrDraftNotFound
+	}
+	return nil
+}
+
+// SendDraft flips a draft to sent and fans it out to its recipients
+func (h *DBHandler) SendDraft(ctx context.Context, draftID string) error {
+	release, err := h.inFlight.admit(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	objID, err := primitive.ObjectIDFromHex(draftID)
+	if err != nil {
+		return err
+	}
+
+	// Only one sender can win the draft -> sent transition
+	var sent models.Mail
+	err = h.db.Mails().FindOneAndUpdate(ctx,
+		bson.M{"_id": objID, "type": MailTypeDraft},
+		bson.M{"$set": bson.M{"type": MailTypeSent, "createdAt": h.now()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&sent)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrDraftNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	return h.deliver(ctx, &sent)
+}
+
+// ListMails retrieves mails for a user
+func (h *DBHandler) ListMails(ctx context.Context, req *models.ListMailsRequest) ([]*models.Mail, error) {
+	release, err := h.inFlight.admit(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	filter, opts := h.listQuery(req)
+	cursor, err := h.db.Mails().Find(ctx, filter, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -157,24 +508,75 @@ func (h *DBHandler) ListMails(ctx context.Context, req *models.ListMailsRequest)
 	return mails, nil
 }
 
-// SearchMails searches for mails matching the criteria
-func (h *DBHandler) SearchMails(ctx context.Context, req *models.SearchMailsRequest) ([]*models.Mail, error) {
-	collection := h.db.Database.Collection("mails")
-
-	filter := bson.M{
-		"userId": req.UserID,
-		"$or": []bson.M{
-			{"subject": bson.M{"$regex": req.SearchTerm, "$options": "i"}},
-			{"content": bson.M{"$regex": req.SearchTerm, "$options": "i"}},
-		},
+// listQuery builds the filter and options of a list request
+func (h *DBHandler) listQuery(req *models.ListMailsRequest) (bson.M, *options.FindOptions) {
+	filter := h.addTombstoneFilter(bson.M{"userId": req.UserID}, req.Trash)
+	opts := options.Find().SetComment(h.db.QueryComment("list"))
+	if sort := search.SortOrder(req.SortBy, nil); sort != nil {
+		opts.SetSort(sort)
 	}
 
-	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
 	if req.Limit > 0 {
 		opts.SetLimit(int64(req.Limit))
 	}
+	if req.Offset > 0 {
+		opts.SetSkip(int64(req.Offset))
+	}
+
+	if req.View == models.MailViewList {
+		opts.SetProjection(h.listViewProjection())
+	}
+	return filter, opts
+}
+
+// GetMail fetches one mail by ID
+func (h *DBHandler) GetMail(ctx context.Context, mailID string) (*models.Mail, error) {
+	release, err := h.inFlight.admit(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	objID, err := primitive.ObjectIDFromHex(mailID)
+	if err != nil {
+		return nil, err
+	}
 
-	cursor, err := collection.Find(ctx, filter, opts)
+	var mail models.Mail
+	err = h.db.Mails().FindOne(ctx, bson.M{"_id": objID},
+		options.FindOne().SetComment(h.db.QueryComment("read"))).Decode(&mail)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrMailNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &mail, nil
+}
+
+// CountMails counts a user's mails; with the userId index this is a
+// covered COUNT_SCAN that never fetches documents
+func (h *DBHandler) CountMails(ctx context.Context, userID string) (int64, error) {
+	release, err := h.inFlight.admit(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	return h.db.Mails().CountDocuments(ctx, h.addTombstoneFilter(bson.M{"userId": userID}, false),
+		options.Count().SetComment(h.db.QueryComment("count")))
+}
+
+// SearchMails searches for mails matching the criteria
+func (h *DBHandler) SearchMails(ctx context.Context, req *models.SearchMailsRequest) ([]*models.Mail, error) {
+	release, err := h.inFlight.admit(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	filter, opts := h.searchQuery(req)
+	cursor, err := h.db.Mails().Find(ctx, filter, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -188,8 +590,46 @@ func (h *DBHandler) SearchMails(ctx context.Context, req *models.SearchMailsRequ
 	return mails, nil
 }
 
+// searchQuery builds the filter and options of a regex search request
+func (h *DBHandler) searchQuery(req *models.SearchMailsRequest) (bson.M, *options.FindOptions) {
+	filter := search.AddScopeFilter(h.addTombstoneFilter(search.BaseFilter(req), false), req.Scope,
+		bson.M{"$regex": req.SearchTerm, "$options": "i"},
+		bson.M{"$regex": req.SearchTerm, "$options": "i"})
+
+	opts := options.Find().SetComment(h.db.QueryComment("search"))
+	if sort := search.SortOrder(req.SortBy, nil); sort != nil {
+		opts.SetSort(sort)
+	}
+	if req.Limit > 0 {
+		opts.SetLimit(int64(req.Limit))
+	}
+	if req.View == models.MailViewList {
+		opts.SetProjection(h.listViewProjection())
+	}
+	return filter, opts
+}
+
+// ExplainList explains the query ListMails runs for req
+func (h *DBHandler) ExplainList(ctx context.Context, req *models.ListMailsRequest) (*database.QueryPlan, error) {
+	filter, opts := h.listQuery(req)
+	return h.db.ExplainFind(ctx, filter, opts)
+}
+
+// ExplainSearch explains the query SearchMails runs for req
+func (h *DBHandler) ExplainSearch(ctx context.Context, req *models.SearchMailsRequest) (*database.QueryPlan, error) {
+	filter, opts := h.searchQuery(req)
+	return h.db.ExplainFind(ctx, filter, opts)
+}
+
+// AppendThread runs the thread upsert used by create on its own, appending
+// threadMail to owner's thread
+func (h *DBHandler) AppendThread(ctx context.Context, owner, threadID string, threadMail models.ThreadMail) error {
+	return h.updateThread(ctx, h.db.Threads(), owner, threadID, threadMail)
+}
+
 // updateThread updates or creates a thread document
-func (h *DBHandler) updateThread(ctx context.Context, collection *mongo.Collection, userID primitive.ObjectID, threadID string, threadMail models.ThreadMail) error {
+func (h *DBHandler) updateThread(ctx context.Context, collection *mongo.Collection, owner string, threadID string, threadMail models.ThreadMail) error {
+	userID := threadUserID(owner)
 	filter := bson.M{
 		"user_id":   userID,
 		"thread_id": threadID,
@@ -210,5 +650,63 @@ func (h *DBHandler) updateThread(ctx context.Context, collection *mongo.Collecti
 
 	opts := options.Update().SetUpsert(true)
 	_, err := collection.UpdateOne(ctx, filter, update, opts)
+
+	// Concurrent upserts on the same thread can race; retry a bounded number of times
+	for attempt := 0; err != nil && isRetryableWriteError(err) && attempt < h.maxThreadRetries; attempt++ {
+		if !h.retryBudget.allow() {
+			break
+		}
+		if !sleepContext(ctx, retryDelay(attempt)) {
+			break
+		}
+		atomic.AddInt64(&h.threadRetries, 1)
+		_, err = collection.UpdateOne(ctx, filter, update, opts)
+	}
+	if err != nil && isRetryableWriteError(err) && h.maxThreadRetries > 0 {
+		atomic.AddInt64(&h.threadRetryFailure, 1)
+	}
+
 	return err
 }
+
+// retryDelay is the jittered backoff before retry attempt (0-based)
+func retryDelay(attempt int) time.Duration {
+	return time.Duration(rand.Int63n(int64(threadRetryBackoff<<attempt))) + 1
+}
+
+// sleepContext waits d, returning false if ctx is done first
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// threadUserID keeps ObjectID user IDs as ObjectIDs in thread documents and
+// stores other schemes (UUID, email, prefixed) as plain strings
+func threadUserID(userID string) interface{} {
+	if objID, err := primitive.ObjectIDFromHex(userID); err == nil {
+		return objID
+	}
+	return userID
+}
+
+// isRetryableWriteError reports whether err is a duplicate-key (E11000) or
+// write-conflict error that is expected under concurrent upserts
+func isRetryableWriteError(err error) bool {
+	if mongo.IsDuplicateKeyError(err) {
+		return true
+	}
+
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) {
+		return serverErr.HasErrorCode(writeConflictCode) ||
+			serverErr.HasErrorLabel("TransientTransactionError")
+	}
+
+	return false
+}
@@ -0,0 +1,566 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"mail-stress-test/database"
+	"mail-stress-test/internal/mongotest"
+	"mail-stress-test/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// newMockDB returns a MongoDB over mt's mock deployment; tests queue the
+// server's replies with mt.AddMockResponses
+func newMockDB(mt *mtest.T) *database.MongoDB {
+	return &database.MongoDB{
+		Client:            mt.Client,
+		Database:          mt.DB,
+		MailsCollection:   database.DefaultMailsCollection,
+		ThreadsCollection: database.DefaultThreadsCollection,
+	}
+}
+
+// writeConflict is the reply to a thread upsert that lost a race
+func writeConflict() mtest.WriteError {
+	return mtest.WriteError{Code: writeConflictCode, Message: "WriteConflict"}
+}
+
+func TestAppendThreadRetriesWriteConflict(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("conflict then success", func(mt *mtest.T) {
+		h := NewDBHandler(newMockDB(mt))
+		mt.AddMockResponses(
+			mtest.CreateWriteErrorsResponse(writeConflict()),
+			mtest.CreateSuccessResponse(),
+		)
+
+		if err := h.AppendThread(context.Background(), "user-1", "thread-1", models.ThreadMail{}); err != nil {
+			t.Fatalf("retried append failed: %v", err)
+		}
+		if retries, exhausted := h.RetryStats(); retries != 1 || exhausted != 0 {
+			t.Errorf("RetryStats = %d retries, %d exhausted; want 1, 0", retries, exhausted)
+		}
+	})
+
+	mt.Run("duplicate key then success", func(mt *mtest.T) {
+		h := NewDBHandler(newMockDB(mt))
+		mt.AddMockResponses(
+			mtest.CreateWriteErrorsResponse(mtest.WriteError{Code: 11000, Message: "E11000 duplicate key error"}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		if err := h.AppendThread(context.Background(), "user-1", "thread-1", models.ThreadMail{}); err != nil {
+			t.Fatalf("retried append failed: %v", err)
+		}
+		if retries, _ := h.RetryStats(); retries != 1 {
+			t.Errorf("retries = %d, want 1", retries)
+		}
+	})
+
+	mt.Run("retries exhausted", func(mt *mtest.T) {
+		h := NewDBHandler(newMockDB(mt))
+		h.SetMaxThreadRetries(2)
+		mt.AddMockResponses(
+			mtest.CreateWriteErrorsResponse(writeConflict()),
+			mtest.CreateWriteErrorsResponse(writeConflict()),
+			mtest.CreateWriteErrorsResponse(writeConflict()),
+		)
+
+		if err := h.AppendThread(context.Background(), "user-1", "thread-1", models.ThreadMail{}); err == nil {
+			t.Fatal("append succeeded although every attempt conflicted")
+		}
+		if retries, exhausted := h.RetryStats(); retries != 2 || exhausted != 1 {
+			t.Errorf("RetryStats = %d retries, %d exhausted; want 2, 1", retries, exhausted)
+		}
+	})
+
+	mt.Run("other errors are not retried", func(mt *mtest.T) {
+		h := NewDBHandler(newMockDB(mt))
+		mt.AddMockResponses(mtest.CreateWriteErrorsResponse(mtest.WriteError{Code: 121, Message: "Document failed validation"}))
+
+		if err := h.AppendThread(context.Background(), "user-1", "thread-1", models.ThreadMail{}); err == nil {
+			t.Fatal("append succeeded on a validation error")
+		}
+		if retries, exhausted := h.RetryStats(); retries != 0 || exhausted != 0 {
+			t.Errorf("RetryStats = %d retries, %d exhausted; want none", retries, exhausted)
+		}
+	})
+}
+
+// commandCollections returns the collection each started command targeted,
+// as "<command> <collection>"
+func commandCollections(mt *mtest.T) []string {
+	var targets []string
+	for _, event := range mt.GetAllStartedEvents() {
+		if coll, ok := event.Command.Lookup(event.CommandName).StringValueOK(); ok {
+			targets = append(targets, event.CommandName+" "+coll)
+		}
+	}
+	return targets
+}
+
+func TestCollectionNamesIsolateHandlers(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("commands target the configured collections", func(mt *mtest.T) {
+		for _, names := range [][2]string{{"mails_a", "threads_a"}, {"mails_b", "threads_b"}} {
+			db := newMockDB(mt)
+			db.SetCollectionNames(names[0], names[1])
+			mt.ClearEvents()
+			for i := 0; i < 4; i++ {
+				mt.AddMockResponses(mtest.CreateSuccessResponse())
+			}
+
+			req := &models.MailRequest{From: "user-1", To: []string{"user-2"}, Subject: "hi", Content: "hello"}
+			if err := NewDBHandler(db).CreateMail(context.Background(), req); err != nil {
+				t.Fatal(err)
+			}
+
+			want := []string{"insert " + names[0], "update " + names[1], "insert " + names[0], "update " + names[1]}
+			got := commandCollections(mt)
+			if len(got) != len(want) {
+				t.Fatalf("commands = %q, want %q", got, want)
+			}
+			for i := range want {
+				if got[i] != want[i] {
+					t.Errorf("command %d = %q, want %q", i, got[i], want[i])
+				}
+			}
+		}
+	})
+}
+
+// TestCollectionNamesIsolationIntegration runs two handlers side by side in
+// one database and checks neither sees the other's mails
+func TestCollectionNamesIsolationIntegration(t *testing.T) {
+	mdb := mongotest.Database(t)
+	ctx := context.Background()
+	newDB := func(mails, threads string) *database.MongoDB {
+		db := &database.MongoDB{Client: mdb.Client(), Database: mdb}
+		db.SetCollectionNames(mails, threads)
+		return db
+	}
+	dbA, dbB := newDB("mails_a", "threads_a"), newDB("mails_b", "threads_b")
+	handlerA, handlerB := NewDBHandler(dbA), NewDBHandler(dbB)
+
+	req := &models.MailRequest{From: "user-1", To: []string{"user-2"}, Subject: "only in a", Content: "hello"}
+	if err := handlerA.CreateMail(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := dbA.Mails().CountDocuments(ctx, bson.M{}); err != nil || n != 2 {
+		t.Errorf("mails_a holds %d documents (%v), want the sender and recipient copies", n, err)
+	}
+	if n, err := dbB.Mails().CountDocuments(ctx, bson.M{}); err != nil || n != 0 {
+		t.Errorf("mails_b holds %d documents (%v), want none", n, err)
+	}
+	mails, err := handlerB.ListMails(ctx, &models.ListMailsRequest{UserID: "user-1", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mails) != 0 {
+		t.Errorf("handler B listed %d of handler A's mails", len(mails))
+	}
+}
+
+// TestDraftUpdatedInPlace checks every edit and the send address the draft's
+// own _id, and only while it is still a draft
+func TestDraftUpdatedInPlace(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("save, edit twice, send", func(mt *mtest.T) {
+		h := NewDBHandler(newMockDB(mt))
+		ctx := context.Background()
+		req := &models.MailRequest{From: "user-1", To: []string{"user-2"}, Subject: "draft", Content: "first"}
+
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+		draftID, err := h.SaveDraft(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		insert := mt.GetStartedEvent().Command
+		saved := insert.Lookup("documents").Array().Index(0).Value().Document()
+		if id := saved.Lookup("_id").ObjectID().Hex(); id != draftID {
+			t.Fatalf("SaveDraft returned %s, inserted %s", draftID, id)
+		}
+		if typ := saved.Lookup("type").AsInt64(); typ != MailTypeDraft {
+			t.Errorf("saved mail type = %d, want draft", typ)
+		}
+
+		for _, subject := range []string{"second", "third"} {
+			mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}))
+			if err := h.UpdateDraft(ctx, draftID, &models.DraftUpdate{Subject: subject, Content: subject}); err != nil {
+				t.Fatal(err)
+			}
+			update := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document()
+			assertDraftFilter(t, update.Lookup("q").Document(), draftID)
+			if upsert, ok := update.Lookup("upsert").BooleanOK(); ok && upsert {
+				t.Error("draft edit upserts a new document")
+			}
+		}
+
+		sent := bson.D{
+			{Key: "_id", Value: saved.Lookup("_id").ObjectID()},
+			{Key: "from", Value: "user-1"}, {Key: "to", Value: bson.A{"user-2"}},
+			{Key: "subject", Value: "third"}, {Key: "type", Value: MailTypeSent},
+			{Key: "userId", Value: "user-1"}, {Key: "threadId", Value: "thread-1"},
+		}
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "value", Value: sent}))
+		for i := 0; i < 3; i++ {
+			mt.AddMockResponses(mtest.CreateSuccessResponse())
+		}
+		mt.ClearEvents()
+		if err := h.SendDraft(ctx, draftID); err != nil {
+			t.Fatal(err)
+		}
+		send := mt.GetStartedEvent().Command
+		assertDraftFilter(t, send.Lookup("query").Document(), draftID)
+		if typ := send.Lookup("update", "$set", "type").AsInt64(); typ != MailTypeSent {
+			t.Errorf("send sets type %d, want sent", typ)
+		}
+	})
+
+	mt.Run("edit after send", func(mt *mtest.T) {
+		h := NewDBHandler(newMockDB(mt))
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}, bson.E{Key: "nModified", Value: 0}))
+		err := h.UpdateDraft(context.Background(), primitive.NewObjectID().Hex(), &models.DraftUpdate{Subject: "late"})
+		if !errors.Is(err, ErrDraftNotFound) {
+			t.Errorf("editing a sent draft = %v, want ErrDraftNotFound", err)
+		}
+	})
+}
+
+// assertDraftFilter checks filter selects draftID while it is still a draft
+func assertDraftFilter(t *testing.T, filter bson.Raw, draftID string) {
+	t.Helper()
+	if id := filter.Lookup("_id").ObjectID().Hex(); id != draftID {
+		t.Errorf("filter targets _id %s, want the draft %s", id, draftID)
+	}
+	if typ := filter.Lookup("type").AsInt64(); typ != MailTypeDraft {
+		t.Errorf("filter type = %d, want draft", typ)
+	}
+}
+
+// TestDraftLifecycleIntegration saves, edits and sends a draft on a real
+// server and checks it stays one document that ends up sent and delivered
+func TestDraftLifecycleIntegration(t *testing.T) {
+	mdb := mongotest.Database(t)
+	db := &database.MongoDB{Client: mdb.Client(), Database: mdb}
+	db.SetCollectionNames(database.DefaultMailsCollection, database.DefaultThreadsCollection)
+	h := NewDBHandler(db)
+	ctx := context.Background()
+
+	req := &models.MailRequest{From: "user-1", To: []string{"user-2"}, Subject: "draft", Content: "first"}
+	draftID, err := h.SaveDraft(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, subject := range []string{"second", "third"} {
+		if err := h.UpdateDraft(ctx, draftID, &models.DraftUpdate{Subject: subject, Content: subject}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	objID, _ := primitive.ObjectIDFromHex(draftID)
+	var draft models.Mail
+	if err := db.Mails().FindOne(ctx, bson.M{"_id": objID}).Decode(&draft); err != nil {
+		t.Fatal(err)
+	}
+	if draft.Subject != "third" || draft.Type != MailTypeDraft {
+		t.Errorf("draft after edits = %q type %d, want \"third\" type draft", draft.Subject, draft.Type)
+	}
+	if n, _ := db.Mails().CountDocuments(ctx, bson.M{}); n != 1 {
+		t.Errorf("%d mails after editing, want the single draft", n)
+	}
+
+	if err := h.SendDraft(ctx, draftID); err != nil {
+		t.Fatal(err)
+	}
+	var sent models.Mail
+	if err := db.Mails().FindOne(ctx, bson.M{"_id": objID}).Decode(&sent); err != nil {
+		t.Fatal(err)
+	}
+	if sent.Type != MailTypeSent || sent.Subject != "third" {
+		t.Errorf("sent mail = %q type %d, want \"third\" type sent", sent.Subject, sent.Type)
+	}
+	if n, _ := db.Mails().CountDocuments(ctx, bson.M{"userId": "user-2", "subject": "third"}); n != 1 {
+		t.Errorf("recipient holds %d copies, want 1", n)
+	}
+	if err := h.SendDraft(ctx, draftID); !errors.Is(err, ErrDraftNotFound) {
+		t.Errorf("sending twice = %v, want ErrDraftNotFound", err)
+	}
+}
+
+// insertedMails decodes the documents of every insert sent to the mails
+// collection
+func insertedMails(mt *mtest.T) []models.Mail {
+	var mails []models.Mail
+	for _, event := range mt.GetAllStartedEvents() {
+		if event.CommandName != "insert" || event.Command.Lookup("insert").StringValue() != database.DefaultMailsCollection {
+			continue
+		}
+		docs, _ := event.Command.Lookup("documents").Array().Values()
+		for _, doc := range docs {
+			var mail models.Mail
+			if err := bson.Unmarshal(doc.Document(), &mail); err != nil {
+				mt.Fatal(err)
+			}
+			mails = append(mails, mail)
+		}
+	}
+	return mails
+}
+
+// TestForwardMail forwards a mail to recipients outside its original thread
+// and checks the forwarded copy quotes the original content and is delivered
+// only to the new recipients
+func TestForwardMail(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("new recipients", func(mt *mtest.T) {
+		h := NewDBHandler(newMockDB(mt))
+		originalID := primitive.NewObjectID()
+		original := bson.D{
+			{Key: "_id", Value: originalID},
+			{Key: "from", Value: "user-9"}, {Key: "to", Value: bson.A{"user-1"}},
+			{Key: "subject", Value: "Budget Review"},
+			{Key: "content", Value: "Numbers attached.\nPlease check line 4."},
+			{Key: "userId", Value: "user-1"}, {Key: "threadId", Value: "thread-1"},
+		}
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, mt.DB.Name()+"."+database.DefaultMailsCollection, mtest.FirstBatch, original))
+		for i := 0; i < 10; i++ {
+			mt.AddMockResponses(mtest.CreateSuccessResponse())
+		}
+
+		req := &models.ForwardMailRequest{MailID: originalID.Hex(), From: "user-1", To: []string{"user-2"}, Cc: []string{"user-3"}}
+		if err := h.ForwardMail(context.Background(), req); err != nil {
+			t.Fatal(err)
+		}
+
+		mails := insertedMails(mt)
+		if len(mails) != 3 {
+			t.Fatalf("inserted %d mails, want the forwarder's copy and two recipients", len(mails))
+		}
+		owners := make(map[string]bool)
+		for _, mail := range mails {
+			owners[mail.UserID] = true
+			if mail.Subject != "Fwd: Budget Review" {
+				t.Errorf("%s's copy has subject %q, want \"Fwd: Budget Review\"", mail.UserID, mail.Subject)
+			}
+			for _, quoted := range []string{"From: user-9", "> Numbers attached.", "> Please check line 4."} {
+				if !strings.Contains(mail.Content, quoted) {
+					t.Errorf("%s's copy does not quote %q:\n%s", mail.UserID, quoted, mail.Content)
+				}
+			}
+			if mail.ThreadID == "thread-1" {
+				t.Errorf("%s's copy stays in the original thread", mail.UserID)
+			}
+			if len(mail.To) != 1 || mail.To[0] != "user-2" || len(mail.Cc) != 1 || mail.Cc[0] != "user-3" {
+				t.Errorf("%s's copy addressed to %v cc %v, want the new recipients", mail.UserID, mail.To, mail.Cc)
+			}
+		}
+		for _, owner := range []string{"user-1", "user-2", "user-3"} {
+			if !owners[owner] {
+				t.Errorf("no copy for %s", owner)
+			}
+		}
+		if owners["user-9"] {
+			t.Error("the original sender received the forward")
+		}
+	})
+}
+
+func TestForwardSubject(t *testing.T) {
+	for subject, want := range map[string]string{
+		"Budget Review":      "Fwd: Budget Review",
+		"Fwd: Budget Review": "Fwd: Budget Review",
+		"Re: Budget Review":  "Fwd: Re: Budget Review",
+	} {
+		if got := forwardSubject(subject); got != want {
+			t.Errorf("forwardSubject(%q) = %q, want %q", subject, got, want)
+		}
+	}
+}
+
+// TestReplyAll replies to a mail with several participants and checks every
+// sender, To and Cc participant gets a copy in the parent's thread, while the
+// parent's Bcc recipient and the replier's own address are left out
+func TestReplyAll(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("all participants", func(mt *mtest.T) {
+		h := NewDBHandler(newMockDB(mt))
+		parentID := primitive.NewObjectID()
+		parent := bson.D{
+			{Key: "_id", Value: parentID},
+			{Key: "from", Value: "user-9"},
+			{Key: "to", Value: bson.A{"user-1", "user-2"}},
+			{Key: "cc", Value: bson.A{"user-3", "user-2"}},
+			{Key: "bcc", Value: bson.A{"user-4"}},
+			{Key: "subject", Value: "Budget Review"},
+			{Key: "userId", Value: "user-1"}, {Key: "threadId", Value: "thread-1"},
+		}
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, mt.DB.Name()+"."+database.DefaultMailsCollection, mtest.FirstBatch, parent))
+		for i := 0; i < 20; i++ {
+			mt.AddMockResponses(mtest.CreateSuccessResponse())
+		}
+
+		req := &models.ReplyAllRequest{MailID: parentID.Hex(), From: "user-1", Content: "Looks good to me."}
+		if err := h.ReplyAll(context.Background(), req); err != nil {
+			t.Fatal(err)
+		}
+
+		mails := insertedMails(mt)
+		owners := make(map[string]int)
+		for _, mail := range mails {
+			owners[mail.UserID]++
+			if mail.ThreadID != "thread-1" || mail.ReplyTo != parentID.Hex() {
+				t.Errorf("%s's copy is in thread %q replying to %q, want the parent's thread and ID", mail.UserID, mail.ThreadID, mail.ReplyTo)
+			}
+			if mail.Subject != "Re: Budget Review" {
+				t.Errorf("%s's copy has subject %q, want \"Re: Budget Review\"", mail.UserID, mail.Subject)
+			}
+			if strings.Join(mail.To, ",") != "user-9,user-2" || strings.Join(mail.Cc, ",") != "user-3" || len(mail.Bcc) != 0 {
+				t.Errorf("%s's copy addressed to %v cc %v bcc %v, want to [user-9 user-2] cc [user-3]", mail.UserID, mail.To, mail.Cc, mail.Bcc)
+			}
+		}
+		for _, owner := range []string{"user-1", "user-9", "user-2", "user-3"} {
+			if owners[owner] != 1 {
+				t.Errorf("%s got %d copies, want 1", owner, owners[owner])
+			}
+		}
+		if owners["user-4"] != 0 {
+			t.Error("the parent's Bcc recipient received the reply")
+		}
+		if len(mails) != 4 {
+			t.Errorf("inserted %d mails, want the replier's copy and three participants", len(mails))
+		}
+	})
+}
+
+func TestReplySubject(t *testing.T) {
+	for subject, want := range map[string]string{
+		"Budget Review":      "Re: Budget Review",
+		"Re: Budget Review":  "Re: Budget Review",
+		"Fwd: Budget Review": "Re: Fwd: Budget Review",
+	} {
+		if got := replySubject(subject); got != want {
+			t.Errorf("replySubject(%q) = %q, want %q", subject, got, want)
+		}
+	}
+}
+
+// TestClockOffsetCorrection injects a known server clock offset and checks
+// the timestamps the handler writes are shifted by it, for both generated
+// and caller-supplied send times
+func TestClockOffsetCorrection(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("shifted createdAt", func(mt *mtest.T) {
+		const offset = -90 * time.Second
+		h := NewDBHandler(newMockDB(mt))
+		h.SetClockOffset(offset)
+
+		sentAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+		for _, req := range []*models.MailRequest{
+			{From: "user-1", To: []string{"user-2"}},
+			{From: "user-1", To: []string{"user-2"}, SentAt: &sentAt},
+		} {
+			mt.ClearEvents()
+			for i := 0; i < 4; i++ {
+				mt.AddMockResponses(mtest.CreateSuccessResponse())
+			}
+			before := time.Now()
+			if err := h.CreateMail(context.Background(), req); err != nil {
+				t.Fatal(err)
+			}
+			after := time.Now()
+
+			mails := insertedMails(mt)
+			if len(mails) != 2 {
+				t.Fatalf("inserted %d mails, want the sender and recipient copies", len(mails))
+			}
+			for _, mail := range mails {
+				got := mail.CreatedAt
+				if req.SentAt != nil {
+					if want := sentAt.Add(offset); !got.Equal(want) {
+						t.Errorf("%s's copy createdAt = %s, want SentAt shifted to %s", mail.UserID, got, want)
+					}
+					continue
+				}
+				// BSON dates have millisecond precision
+				lo, hi := before.Add(offset).Truncate(time.Millisecond), after.Add(offset)
+				if got.Before(lo) || got.After(hi) {
+					t.Errorf("%s's copy createdAt = %s, want within [%s, %s]", mail.UserID, got, lo, hi)
+				}
+			}
+		}
+	})
+}
+
+// TestListViewProjection checks list-view lists and searches ask the server
+// for the list fields and a snippet of the configured length, and full views
+// fetch whole documents
+func TestListViewProjection(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	ns := func(mt *mtest.T) string { return mt.DB.Name() + "." + database.DefaultMailsCollection }
+
+	mt.Run("list view", func(mt *mtest.T) {
+		h := NewDBHandler(newMockDB(mt))
+		h.SetSnippetLength(40)
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, ns(mt), mtest.FirstBatch, bson.D{{Key: "subject", Value: "Weekly Report"}, {Key: "snippet", Value: "Update on"}}),
+			mtest.CreateCursorResponse(0, ns(mt), mtest.FirstBatch),
+		)
+
+		mails, err := h.ListMails(context.Background(), &models.ListMailsRequest{UserID: "user-1", View: models.MailViewList})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(mails) != 1 || mails[0].Snippet != "Update on" {
+			t.Errorf("listed %+v, want the snippet decoded", mails)
+		}
+		if _, err := h.SearchMails(context.Background(), &models.SearchMailsRequest{UserID: "user-1", SearchTerm: "report", View: models.MailViewList}); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, event := range mt.GetAllStartedEvents() {
+			projection, ok := event.Command.Lookup("projection").DocumentOK()
+			if !ok {
+				t.Errorf("%s sent no projection", event.CommandName)
+				continue
+			}
+			for _, field := range []string{"subject", "from", "createdAt", "isRead"} {
+				if _, err := projection.LookupErr(field); err != nil {
+					t.Errorf("%s projection %s lacks %s", event.CommandName, projection, field)
+				}
+			}
+			if _, err := projection.LookupErr("content"); err == nil {
+				t.Errorf("%s projection %s returns the full content", event.CommandName, projection)
+			}
+			args, _ := projection.Lookup("snippet", "$substrCP").Array().Values()
+			if len(args) != 3 || args[2].AsInt64() != 40 {
+				t.Errorf("%s snippet = %v, want a 40-character $substrCP", event.CommandName, args)
+			}
+		}
+	})
+
+	mt.Run("full view", func(mt *mtest.T) {
+		h := NewDBHandler(newMockDB(mt))
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, ns(mt), mtest.FirstBatch))
+		if _, err := h.ListMails(context.Background(), &models.ListMailsRequest{UserID: "user-1"}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := mt.GetStartedEvent().Command.LookupErr("projection"); err == nil {
+			t.Error("full view sent a projection")
+		}
+	})
+}
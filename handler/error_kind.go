@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Failure modes an operation error is classified into
+const (
+	ErrorKindConnection = "connection" // refused, reset or otherwise unreachable
+	ErrorKindTimeout    = "timeout"    // deadline exceeded before a response
+	ErrorKindServer     = "server"     // HTTP 5xx
+	ErrorKindClient     = "client"     // HTTP 4xx
+	ErrorKindOther      = "other"
+)
+
+// StatusError is returned by the API handler for an unexpected HTTP status
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("API error: status code %d, body: %s", e.StatusCode, e.Body)
+}
+
+// ClassifyError tells whether a failed operation never reached the backend,
+// got no answer in time, or was answered with an error status. A degrading
+// backend typically shows timeouts first and connection errors once it is
+// down. nil returns "".
+func ClassifyError(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	var status *StatusError
+	if errors.As(err, &status) {
+		switch {
+		case status.StatusCode >= 500:
+			return ErrorKindServer
+		case status.StatusCode >= 400:
+			return ErrorKindClient
+		}
+		return ErrorKindOther
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || mongo.IsTimeout(err) ||
+		(errors.As(err, &netErr) && netErr.Timeout()) {
+		return ErrorKindTimeout
+	}
+
+	var opErr *net.OpError
+	if errors.Is(err, ErrCircuitOpen) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF) || errors.As(err, &opErr) || mongo.IsNetworkError(err) {
+		return ErrorKindConnection
+	}
+
+	return ErrorKindOther
+}
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"mail-stress-test/models"
+)
+
+// TestClassifyAPIErrors provokes each failure mode against a real listener
+// and checks the API handler's error is classified accordingly
+func TestClassifyAPIErrors(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(200 * time.Millisecond):
+		}
+	}))
+	defer slow.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+
+	// A port that was just released refuses connections
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	refused := "http://" + listener.Addr().String()
+	listener.Close()
+
+	for _, tt := range []struct {
+		url, want string
+		timeout   time.Duration
+	}{
+		{url: refused, want: ErrorKindConnection},
+		{url: slow.URL, want: ErrorKindTimeout, timeout: 20 * time.Millisecond},
+		{url: failing.URL, want: ErrorKindServer},
+		{url: missing.URL, want: ErrorKindClient},
+	} {
+		ctx := context.Background()
+		if tt.timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+			defer cancel()
+		}
+		_, err := NewAPIHandler(tt.url).ListMails(ctx, &models.ListMailsRequest{UserID: "user-1"})
+		if got := ClassifyError(err); got != tt.want {
+			t.Errorf("%s: ClassifyError(%v) = %q, want %q", tt.url, err, got, tt.want)
+		}
+	}
+	if ClassifyError(nil) != "" {
+		t.Error("nil error classified")
+	}
+}
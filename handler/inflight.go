@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrInFlightLimit is returned in fail-fast mode when the in-flight request
+// cap is reached
+var ErrInFlightLimit = errors.New("in-flight request limit reached")
+
+// InFlightStats reports how the in-flight cap constrained requests
+type InFlightStats struct {
+	Limit      int           `json:"limit"`
+	Peak       int64         `json:"peak"`
+	Waits      int64         `json:"waits"`      // requests that blocked for a slot
+	WaitTime   time.Duration `json:"wait_time"`  // total time spent blocked
+	Rejections int64         `json:"rejections"` // fail-fast requests refused at the cap
+}
+
+// inFlightLimiter is a semaphore capping concurrent requests. Over the cap a
+// request blocks for a slot, or fails fast with ErrInFlightLimit.
+type inFlightLimiter struct {
+	slots    chan struct{}
+	failFast bool
+
+	current    int64
+	peak       int64
+	waits      int64
+	waitNanos  int64
+	rejections int64
+}
+
+func newInFlightLimiter(limit int, failFast bool) *inFlightLimiter {
+	return &inFlightLimiter{slots: make(chan struct{}, limit), failFast: failFast}
+}
+
+// acquire takes a slot, waiting until one frees up or ctx is done
+func (l *inFlightLimiter) acquire(ctx context.Context) error {
+	select {
+	case l.slots <- struct{}{}:
+	default:
+		if l.failFast {
+			atomic.AddInt64(&l.rejections, 1)
+			return ErrInFlightLimit
+		}
+		start := time.Now()
+		select {
+		case l.slots <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		atomic.AddInt64(&l.waits, 1)
+		atomic.AddInt64(&l.waitNanos, int64(time.Since(start)))
+	}
+
+	current := atomic.AddInt64(&l.current, 1)
+	for {
+		peak := atomic.LoadInt64(&l.peak)
+		if current <= peak || atomic.CompareAndSwapInt64(&l.peak, peak, current) {
+			break
+		}
+	}
+	return nil
+}
+
+// admit takes a slot for one operation and returns its release; a nil
+// limiter admits everything
+func (l *inFlightLimiter) admit(ctx context.Context) (release func(), err error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	if err := l.acquire(ctx); err != nil {
+		return nil, err
+	}
+	return l.release, nil
+}
+
+// release frees a slot taken by acquire
+func (l *inFlightLimiter) release() {
+	atomic.AddInt64(&l.current, -1)
+	<-l.slots
+}
+
+func (l *inFlightLimiter) stats() InFlightStats {
+	return InFlightStats{
+		Limit:      cap(l.slots),
+		Peak:       atomic.LoadInt64(&l.peak),
+		Waits:      atomic.LoadInt64(&l.waits),
+		WaitTime:   time.Duration(atomic.LoadInt64(&l.waitNanos)),
+		Rejections: atomic.LoadInt64(&l.rejections),
+	}
+}
+
+// releaseOnClose keeps a request's slot until its response body is closed,
+// since the response is still in flight while it is being read
+type releaseOnClose struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releaseOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
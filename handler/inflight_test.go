@@ -0,0 +1,195 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// concurrencyServer answers /api/mails/count after hold returns, recording
+// the most requests it ever served at once
+type concurrencyServer struct {
+	*httptest.Server
+	active, peak int64
+}
+
+func newConcurrencyServer(t *testing.T, hold func()) *concurrencyServer {
+	t.Helper()
+	s := &concurrencyServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		active := atomic.AddInt64(&s.active, 1)
+		defer atomic.AddInt64(&s.active, -1)
+		for {
+			peak := atomic.LoadInt64(&s.peak)
+			if active <= peak || atomic.CompareAndSwapInt64(&s.peak, peak, active) {
+				break
+			}
+		}
+		hold()
+		fmt.Fprint(w, `{"count": 1}`)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// waitFor polls cond until it holds or a deadline passes
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestInFlightCapBlocks(t *testing.T) {
+	const limit, requests = 3, 30
+	server := newConcurrencyServer(t, func() { time.Sleep(5 * time.Millisecond) })
+	h := NewAPIHandler(server.URL)
+	h.SetMaxInFlight(limit, false)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, requests)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := h.CountMails(context.Background(), "user"); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("blocking mode should not fail requests: %v", err)
+	}
+
+	stats, ok := h.InFlightStats()
+	if !ok {
+		t.Fatal("InFlightStats not reported with a cap set")
+	}
+	if peak := atomic.LoadInt64(&server.peak); peak > limit || stats.Peak > limit {
+		t.Errorf("in-flight exceeded the cap of %d: server saw %d, limiter peak %d", limit, peak, stats.Peak)
+	}
+	if stats.Peak != limit {
+		t.Errorf("limiter peak %d, want the cap %d to be reached", stats.Peak, limit)
+	}
+	if stats.Waits == 0 || stats.WaitTime <= 0 {
+		t.Errorf("requests over the cap should wait: %+v", stats)
+	}
+	if stats.Rejections != 0 {
+		t.Errorf("blocking mode rejected %d requests", stats.Rejections)
+	}
+}
+
+func TestInFlightCapFailsFast(t *testing.T) {
+	const limit = 2
+	release := make(chan struct{})
+	server := newConcurrencyServer(t, func() { <-release })
+	h := NewAPIHandler(server.URL)
+	h.SetMaxInFlight(limit, true)
+
+	var held sync.WaitGroup
+	for i := 0; i < limit; i++ {
+		held.Add(1)
+		go func() {
+			defer held.Done()
+			if _, err := h.CountMails(context.Background(), "user"); err != nil {
+				t.Errorf("request within the cap failed: %v", err)
+			}
+		}()
+	}
+	waitFor(t, "the cap to fill", func() bool { return atomic.LoadInt64(&server.active) == limit })
+
+	const over = 5
+	for i := 0; i < over; i++ {
+		if _, err := h.CountMails(context.Background(), "user"); !errors.Is(err, ErrInFlightLimit) {
+			t.Errorf("request over the cap: got %v, want ErrInFlightLimit", err)
+		}
+	}
+	close(release)
+	held.Wait()
+
+	if _, err := h.CountMails(context.Background(), "user"); err != nil {
+		t.Errorf("request after slots were freed: %v", err)
+	}
+	stats, _ := h.InFlightStats()
+	if stats.Rejections != over {
+		t.Errorf("rejections = %d, want %d", stats.Rejections, over)
+	}
+	if peak := atomic.LoadInt64(&server.peak); peak > limit || stats.Peak > limit {
+		t.Errorf("in-flight exceeded the cap of %d: server saw %d, limiter peak %d", limit, peak, stats.Peak)
+	}
+	if stats.Waits != 0 {
+		t.Errorf("fail-fast mode waited %d times", stats.Waits)
+	}
+}
+
+// TestInFlightSlotHeldUntilBodyClosed checks a response still counts against
+// the cap while its body is being read, and that closing it twice frees the
+// slot once
+func TestInFlightSlotHeldUntilBodyClosed(t *testing.T) {
+	finish := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "first half,")
+		w.(http.Flusher).Flush()
+		<-finish
+		fmt.Fprint(w, "second half")
+	}))
+	defer server.Close()
+
+	h := NewAPIHandler(server.URL)
+	h.SetMaxInFlight(1, true)
+	get := func() (*http.Response, error) {
+		req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h.do(req)
+	}
+
+	resp, err := get()
+	if err != nil {
+		t.Fatalf("first request: %v", err)
+	}
+	if _, ok := resp.Body.(*releaseOnClose); !ok {
+		t.Fatalf("response body is %T, want it wrapped to release the slot on close", resp.Body)
+	}
+	if _, err := get(); !errors.Is(err, ErrInFlightLimit) {
+		t.Fatalf("request while a body is unread: got %v, want ErrInFlightLimit", err)
+	}
+
+	close(finish)
+	body, err := io.ReadAll(resp.Body)
+	if err != nil || string(body) != "first half,second half" {
+		t.Fatalf("body = %q, %v", body, err)
+	}
+	if _, err := get(); !errors.Is(err, ErrInFlightLimit) {
+		t.Fatalf("request after reading but before closing: got %v, want ErrInFlightLimit", err)
+	}
+
+	resp.Body.Close()
+	resp.Body.Close()
+	if current := atomic.LoadInt64(&h.inFlight.current); current != 0 {
+		t.Fatalf("in-flight count %d after close, want 0", current)
+	}
+
+	next, err := get()
+	if err != nil {
+		t.Fatalf("request after the body was closed: %v", err)
+	}
+	next.Body.Close()
+	if stats, _ := h.InFlightStats(); stats.Peak != 1 || stats.Rejections != 2 {
+		t.Errorf("stats = %+v, want peak 1 and 2 rejections", stats)
+	}
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"mail-stress-test/models"
+)
+
+// TestAPIHandlerReleasesResources sends many requests of every kind, half of
+// them failing, and checks they reuse one keep-alive connection and leave no
+// goroutines behind once the connections are closed
+func TestAPIHandlerReleasesResources(t *testing.T) {
+	const rounds = 200
+	var dialed int64
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body)+r.URL.Path, "fail") {
+			http.Error(w, strings.Repeat("backend overloaded ", 100), http.StatusServiceUnavailable)
+			return
+		}
+		switch {
+		case r.URL.Path == "/api/mails/count":
+			fmt.Fprint(w, `{"count": 3}`+"\n\n")
+		case strings.HasPrefix(r.URL.Path, "/api/mails/") && r.Method == http.MethodGet:
+			http.NotFound(w, r)
+		default:
+			// Trailing whitespace past the decoder's buffer is left unread
+			fmt.Fprint(w, `[]`+strings.Repeat(" ", 32<<10))
+		}
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt64(&dialed, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	baseline := runtime.NumGoroutine()
+	h := NewAPIHandler(server.URL)
+	ctx := context.Background()
+	for i := 0; i < rounds; i++ {
+		// Odd rounds are answered with an error page
+		userID, mailID := "user-1", "missing"
+		if i%2 == 1 {
+			userID, mailID = "user-fail", "fail"
+		}
+		h.ListMails(ctx, &models.ListMailsRequest{UserID: userID})
+		h.CountMails(ctx, userID)
+		h.SearchMails(ctx, &models.SearchMailsRequest{UserID: userID, SearchTerm: "report"})
+		h.GetMail(ctx, mailID)
+		h.ListMailsStream(ctx, &models.ListMailsRequest{UserID: userID}, func(*models.Mail) error { return nil })
+	}
+
+	if n := atomic.LoadInt64(&dialed); n != 1 {
+		t.Errorf("%d requests dialed %d connections, want every body drained so one is reused", rounds*5, n)
+	}
+
+	h.httpClient.CloseIdleConnections()
+	server.CloseClientConnections()
+	waitFor(t, fmt.Sprintf("goroutines to return to the baseline of %d", baseline), func() bool {
+		return runtime.NumGoroutine() <= baseline
+	})
+}
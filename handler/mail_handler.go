@@ -2,9 +2,25 @@ package handler
 
 import (
 	"context"
+	"errors"
+
 	"mail-stress-test/models"
 )
 
+// Mail types stored in Mail.Type
+const (
+	MailTypeReceived = 0
+	MailTypeSent     = 1
+	MailTypeDraft    = 2
+)
+
+// ErrDraftNotFound is returned when a draft does not exist or was already sent
+var ErrDraftNotFound = errors.New("draft not found")
+
+// ErrMailNotFound is returned when a mail to soft-delete does not exist or
+// is already in the trash
+var ErrMailNotFound = errors.New("mail not found or already deleted")
+
 // MailHandler defines the interface for mail operations
 type MailHandler interface {
 	// CreateMail creates a new mail based on the request
@@ -15,4 +31,143 @@ type MailHandler interface {
 
 	// SearchMails searches for mails matching the criteria
 	SearchMails(ctx context.Context, req *models.SearchMailsRequest) ([]*models.Mail, error)
+
+	// CountMails returns the total number of mails in a user's mailbox
+	CountMails(ctx context.Context, userID string) (int64, error)
+}
+
+// SupportsOperation reports whether h can run the named stress test
+// operation. create, list, search and count are part of MailHandler; the
+// others need the matching optional interface.
+func SupportsOperation(h MailHandler, operation string) bool {
+	var ok bool
+	switch operation {
+	case "create", "list", "search", "count":
+		ok = true
+	case "draft":
+		_, ok = h.(DraftHandler)
+	case "forward":
+		_, ok = h.(ForwardHandler)
+	case "reply_all":
+		_, ok = h.(ReplyAllHandler)
+	case "soft_delete":
+		_, ok = h.(SoftDeleteHandler)
+	case "read":
+		_, ok = h.(MailReader)
+	}
+	return ok
+}
+
+// MailDocuments returns how many mail documents creating req inserts: the
+// sender's copy plus one received copy per recipient other than the sender
+func MailDocuments(req *models.MailRequest) int {
+	count := 1
+	for _, recipients := range [][]string{req.To, req.Cc, req.Bcc} {
+		for _, recipient := range recipients {
+			if recipient != req.From {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// DraftHandler is optionally implemented by handlers that support the "save
+// draft" flow: a draft is saved, edited in place, then optionally sent
+type DraftHandler interface {
+	// SaveDraft stores req as a draft owned by the sender and returns its ID
+	SaveDraft(ctx context.Context, req *models.MailRequest) (string, error)
+
+	// UpdateDraft edits the subject and content of a draft in place
+	UpdateDraft(ctx context.Context, draftID string, update *models.DraftUpdate) error
+
+	// SendDraft turns a draft into a sent mail and delivers it to recipients
+	SendDraft(ctx context.Context, draftID string) error
+}
+
+// ForwardHandler is optionally implemented by handlers that can forward an
+// existing mail to new recipients
+type ForwardHandler interface {
+	ForwardMail(ctx context.Context, req *models.ForwardMailRequest) error
+}
+
+// ReplyAllHandler is optionally implemented by handlers that can reply to
+// every participant of a mail within its thread
+type ReplyAllHandler interface {
+	ReplyAll(ctx context.Context, req *models.ReplyAllRequest) error
+}
+
+// SoftDeleteHandler is optionally implemented by handlers that can move a
+// mail to the trash with a deletedAt tombstone instead of removing it
+type SoftDeleteHandler interface {
+	SoftDeleteMail(ctx context.Context, mailID string) error
+}
+
+// Warmer is optionally implemented by handlers that can pre-open their
+// connections, so connection setup and TLS handshakes are not measured
+type Warmer interface {
+	// WarmUp opens connections concurrently, issuing requests cheap calls on each
+	WarmUp(ctx context.Context, connections, requests int) error
+}
+
+// MailCreator is optionally implemented by handlers that can return the ID
+// of the sender's copy of a created mail, so later steps can refer to it
+type MailCreator interface {
+	CreateMailWithID(ctx context.Context, req *models.MailRequest) (string, error)
+}
+
+// MailReader is optionally implemented by handlers that can fetch a single
+// mail by ID, as an inbox does when a mail is opened
+type MailReader interface {
+	GetMail(ctx context.Context, mailID string) (*models.Mail, error)
+}
+
+// MailStreamer is optionally implemented by handlers that can deliver list
+// and search results one mail at a time, for exports too large to hold in
+// memory. fn is called per decoded mail in result order; an error from fn
+// stops the stream and is returned.
+type MailStreamer interface {
+	ListMailsStream(ctx context.Context, req *models.ListMailsRequest, fn func(*models.Mail) error) error
+	SearchMailsStream(ctx context.Context, req *models.SearchMailsRequest, fn func(*models.Mail) error) error
+}
+
+// RetryReporter is optionally implemented by handlers that retry transient
+// write errors, so retries can be reported separately from failures
+type RetryReporter interface {
+	RetryStats() (retries, exhausted int64)
+}
+
+// RetryBudgetReporter is optionally implemented by handlers whose retries
+// draw on a shared per-second retry budget
+type RetryBudgetReporter interface {
+	RetryBudgetDenied() int64
+}
+
+// TimeoutReporter is optionally implemented by handlers with per-operation
+// deadlines, reporting how many requests of each operation timed out
+type TimeoutReporter interface {
+	TimeoutStats() map[string]int64
+}
+
+// CircuitReporter is optionally implemented by handlers with a circuit breaker
+type CircuitReporter interface {
+	CircuitStats() (rejections, opens int64)
+}
+
+// InFlightReporter is optionally implemented by handlers that cap concurrent
+// in-flight requests
+type InFlightReporter interface {
+	InFlightStats() (stats InFlightStats, ok bool)
+}
+
+// ProtocolReporter is optionally implemented by handlers that report the
+// negotiated HTTP protocol and how many connections carried the requests
+type ProtocolReporter interface {
+	ProtocolStats() (stats ProtocolStats, ok bool)
+}
+
+// PhaseReporter is optionally implemented by handlers that break request
+// latency into network and server phases
+type PhaseReporter interface {
+	PhaseStats() (stats LatencyPhaseStats, ok bool)
 }
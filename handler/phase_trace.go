@@ -0,0 +1,284 @@
+package handler
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Latency phases of an API request, in the order they happen
+const (
+	PhaseConnWait = "conn_wait" // waiting for an idle pooled connection
+	PhaseDNS      = "dns"
+	PhaseConnect  = "connect"
+	PhaseTLS      = "tls"
+	PhaseWrite    = "write"  // sending headers and body
+	PhaseServer   = "server" // request written until the first response byte (TTFB)
+	PhaseBody     = "body"   // reading the response body
+	PhaseOther    = "other"  // client time outside the traced phases
+)
+
+// Phases lists every latency phase in request order
+var Phases = []string{PhaseConnWait, PhaseDNS, PhaseConnect, PhaseTLS, PhaseWrite, PhaseServer, PhaseBody, PhaseOther}
+
+// LatencyPhases is the average time per phase over Requests traced requests
+type LatencyPhases struct {
+	Requests int64                    `json:"requests"`
+	Total    time.Duration            `json:"total"` // average end-to-end latency
+	Phases   map[string]time.Duration `json:"phases"`
+}
+
+// Share returns the percentage of the total latency spent in phase
+func (p LatencyPhases) Share(phase string) float64 {
+	if p.Total <= 0 {
+		return 0
+	}
+	return float64(p.Phases[phase]) / float64(p.Total) * 100
+}
+
+// String lists the phases by their share of the latency, largest first
+func (p LatencyPhases) String() string {
+	phases := make([]string, 0, len(p.Phases))
+	for _, phase := range Phases {
+		if p.Phases[phase] > 0 {
+			phases = append(phases, phase)
+		}
+	}
+	sort.SliceStable(phases, func(i, j int) bool { return p.Phases[phases[i]] > p.Phases[phases[j]] })
+
+	parts := make([]string, len(phases))
+	for i, phase := range phases {
+		parts[i] = fmt.Sprintf("%s %.1f%% (%s)", phase, p.Share(phase), p.Phases[phase])
+	}
+	return fmt.Sprintf("%d requests, avg %s: %s", p.Requests, p.Total, strings.Join(parts, ", "))
+}
+
+// LatencyPhaseStats is the phase breakdown of the run, overall and per operation
+type LatencyPhaseStats struct {
+	Overall     LatencyPhases            `json:"overall"`
+	ByOperation map[string]LatencyPhases `json:"by_operation"`
+}
+
+// Operations lists the traced operations alphabetically
+func (s *LatencyPhaseStats) Operations() []string {
+	operations := make([]string, 0, len(s.ByOperation))
+	for op := range s.ByOperation {
+		operations = append(operations, op)
+	}
+	sort.Strings(operations)
+	return operations
+}
+
+// Folded renders the breakdown in the collapsed-stack format read by
+// flamegraph tools ("operation;phase microseconds"), using total time
+// rather than averages so operations are weighted by their volume
+func (s *LatencyPhaseStats) Folded() string {
+	var b strings.Builder
+	for _, op := range s.Operations() {
+		p := s.ByOperation[op]
+		for _, phase := range Phases {
+			if total := p.Phases[phase] * time.Duration(p.Requests); total > 0 {
+				fmt.Fprintf(&b, "%s;%s %d\n", op, phase, total.Microseconds())
+			}
+		}
+	}
+	return b.String()
+}
+
+type operationKey struct{}
+
+// withOperation tags ctx with the operation whose requests it carries
+func withOperation(ctx context.Context, operation string) context.Context {
+	return context.WithValue(ctx, operationKey{}, operation)
+}
+
+// phaseTotals sums phase durations for one operation
+type phaseTotals struct {
+	requests int64
+	total    time.Duration
+	phases   map[string]time.Duration
+}
+
+func (t *phaseTotals) add(total time.Duration, phases map[string]time.Duration) {
+	t.requests++
+	t.total += total
+	for phase, d := range phases {
+		t.phases[phase] += d
+	}
+}
+
+func (t *phaseTotals) average() LatencyPhases {
+	p := LatencyPhases{Requests: t.requests, Phases: make(map[string]time.Duration, len(t.phases))}
+	if t.requests == 0 {
+		return p
+	}
+	p.Total = t.total / time.Duration(t.requests)
+	for phase, d := range t.phases {
+		p.Phases[phase] = d / time.Duration(t.requests)
+	}
+	return p
+}
+
+// phaseRecorder aggregates the traced phases of completed requests
+type phaseRecorder struct {
+	mu          sync.Mutex
+	overall     *phaseTotals
+	byOperation map[string]*phaseTotals
+}
+
+func newPhaseRecorder() *phaseRecorder {
+	return &phaseRecorder{
+		overall:     &phaseTotals{phases: make(map[string]time.Duration)},
+		byOperation: make(map[string]*phaseTotals),
+	}
+}
+
+func (r *phaseRecorder) record(operation string, total time.Duration, phases map[string]time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.overall.add(total, phases)
+	totals, ok := r.byOperation[operation]
+	if !ok {
+		totals = &phaseTotals{phases: make(map[string]time.Duration)}
+		r.byOperation[operation] = totals
+	}
+	totals.add(total, phases)
+}
+
+func (r *phaseRecorder) stats() LatencyPhaseStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stats := LatencyPhaseStats{Overall: r.overall.average(), ByOperation: make(map[string]LatencyPhases, len(r.byOperation))}
+	for op, totals := range r.byOperation {
+		stats.ByOperation[op] = totals.average()
+	}
+	return stats
+}
+
+// requestTrace timestamps the httptrace events of one request
+type requestTrace struct {
+	mu                        sync.Mutex
+	start, getConn, gotConn   time.Time
+	dnsStart, dnsDone         time.Time
+	connectStart, connectDone time.Time
+	tlsStart, tlsDone         time.Time
+	wroteRequest, firstByte   time.Time
+}
+
+// clientTrace returns the hooks filling in t
+func (t *requestTrace) clientTrace() *httptrace.ClientTrace {
+	stamp := func(at *time.Time) {
+		t.mu.Lock()
+		if at.IsZero() {
+			*at = time.Now()
+		}
+		t.mu.Unlock()
+	}
+	return &httptrace.ClientTrace{
+		GetConn:              func(string) { stamp(&t.getConn) },
+		GotConn:              func(httptrace.GotConnInfo) { stamp(&t.gotConn) },
+		DNSStart:             func(httptrace.DNSStartInfo) { stamp(&t.dnsStart) },
+		DNSDone:              func(httptrace.DNSDoneInfo) { stamp(&t.dnsDone) },
+		ConnectStart:         func(string, string) { stamp(&t.connectStart) },
+		ConnectDone:          func(string, string, error) { stamp(&t.connectDone) },
+		TLSHandshakeStart:    func() { stamp(&t.tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { stamp(&t.tlsDone) },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { stamp(&t.wroteRequest) },
+		GotFirstResponseByte: func() { stamp(&t.firstByte) },
+	}
+}
+
+// phases splits the request's latency up to end into phases; the
+// remainder not covered by a traced phase is reported as other
+func (t *requestTrace) phases(end time.Time) (time.Duration, map[string]time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	span := func(from, to time.Time) time.Duration {
+		if from.IsZero() || to.IsZero() || to.Before(from) {
+			return 0
+		}
+		return to.Sub(from)
+	}
+	total := span(t.start, end)
+	phases := map[string]time.Duration{
+		PhaseDNS:     span(t.dnsStart, t.dnsDone),
+		PhaseConnect: span(t.connectStart, t.connectDone),
+		PhaseTLS:     span(t.tlsStart, t.tlsDone),
+		PhaseWrite:   span(t.gotConn, t.wroteRequest),
+		PhaseServer:  span(t.wroteRequest, t.firstByte),
+		PhaseBody:    span(t.firstByte, end),
+	}
+	// Dialing happens while waiting for the connection; the rest is pool wait
+	wait := span(t.getConn, t.gotConn) - phases[PhaseDNS] - phases[PhaseConnect] - phases[PhaseTLS]
+	if wait > 0 {
+		phases[PhaseConnWait] = wait
+	}
+
+	var traced time.Duration
+	for _, d := range phases {
+		traced += d
+	}
+	if other := total - traced; other > 0 {
+		phases[PhaseOther] = other
+	}
+	return total, phases
+}
+
+// finishOnClose records the trace when the response body is closed, so the
+// body read is part of the breakdown
+type finishOnClose struct {
+	io.ReadCloser
+	once   sync.Once
+	finish func()
+}
+
+func (f *finishOnClose) Close() error {
+	err := f.ReadCloser.Close()
+	f.once.Do(f.finish)
+	return err
+}
+
+// SetPhaseTracing breaks every request's latency into connection, TLS,
+// write, server and body phases via httptrace
+func (h *APIHandler) SetPhaseTracing(enabled bool) {
+	if !enabled {
+		h.phases = nil
+		return
+	}
+	h.phases = newPhaseRecorder()
+}
+
+// PhaseStats returns the latency breakdown of the traced requests; ok is
+// false when tracing is off
+func (h *APIHandler) PhaseStats() (stats LatencyPhaseStats, ok bool) {
+	if h.phases == nil {
+		return LatencyPhaseStats{}, false
+	}
+	return h.phases.stats(), true
+}
+
+// traced sends req with phase tracing, recording the phases once the
+// response body is closed. Failed requests are left out of the breakdown.
+func (h *APIHandler) traced(req *http.Request) (*http.Response, error) {
+	operation, _ := req.Context().Value(operationKey{}).(string)
+	trace := &requestTrace{start: time.Now()}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace.clientTrace()))
+
+	resp, err := h.send(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = &finishOnClose{ReadCloser: resp.Body, finish: func() {
+		total, phases := trace.phases(time.Now())
+		h.phases.record(operation, total, phases)
+	}}
+	return resp, nil
+}
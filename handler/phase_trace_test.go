@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestPhaseTracingSumsToTotal counts mails against a server that holds each
+// request before answering and checks the traced phases add up to the
+// request latency, with the hold attributed to the server phase
+func TestPhaseTracingSumsToTotal(t *testing.T) {
+	const hold, requests = 20 * time.Millisecond, 5
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(hold)
+		fmt.Fprint(w, `{"count": 1}`)
+	}))
+	t.Cleanup(server.Close)
+
+	h := NewAPIHandler(server.URL)
+	if _, ok := h.PhaseStats(); ok {
+		t.Error("PhaseStats reported before tracing was enabled")
+	}
+	h.SetPhaseTracing(true)
+	for i := 0; i < requests; i++ {
+		if _, err := h.CountMails(context.Background(), "user-1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, ok := h.PhaseStats()
+	if !ok {
+		t.Fatal("PhaseStats not reported with tracing enabled")
+	}
+	overall := stats.Overall
+	if overall.Requests != requests {
+		t.Errorf("Requests = %d, want %d", overall.Requests, requests)
+	}
+	if overall.Total < hold {
+		t.Errorf("Total = %s, want at least the %s hold", overall.Total, hold)
+	}
+	var sum time.Duration
+	for _, d := range overall.Phases {
+		sum += d
+	}
+	if diff := overall.Total - sum; diff < -overall.Total/20 || diff > overall.Total/20 {
+		t.Errorf("phases sum to %s, want roughly the %s total", sum, overall.Total)
+	}
+	if overall.Phases[PhaseServer] < hold || overall.Share(PhaseServer) < 50 {
+		t.Errorf("server phase = %s (%.1f%%), want the %s hold to dominate", overall.Phases[PhaseServer], overall.Share(PhaseServer), hold)
+	}
+
+	if ops := stats.Operations(); len(ops) != 1 || ops[0] != "count" {
+		t.Errorf("Operations() = %v, want [count]", ops)
+	}
+	if folded := stats.Folded(); !strings.Contains(folded, "count;server ") {
+		t.Errorf("Folded() = %q, want a count;server line", folded)
+	}
+}
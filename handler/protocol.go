@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// HTTP protocol modes accepted by SetProtocol
+const (
+	ProtocolAuto  = "auto"  // HTTP/2 when the server offers it over TLS, else HTTP/1.1
+	ProtocolHTTP1 = "http1" // HTTP/1.1 only, one request per connection at a time
+	ProtocolHTTP2 = "http2" // HTTP/2 over TLS (ALPN h2), multiplexing requests on shared connections
+)
+
+// ProtocolStats reports which HTTP protocol responses came back with and how
+// many connections carried them
+type ProtocolStats struct {
+	Mode        string           `json:"mode"`
+	Responses   map[string]int64 `json:"responses"` // by negotiated protocol, e.g. "HTTP/2.0"
+	Connections int64            `json:"connections"`
+}
+
+// Mismatched returns how many responses didn't use the protocol the mode
+// asks for
+func (s ProtocolStats) Mismatched() int64 {
+	want := map[string]string{ProtocolHTTP1: "HTTP/1.1", ProtocolHTTP2: "HTTP/2.0"}[s.Mode]
+	if want == "" {
+		return 0
+	}
+	var mismatched int64
+	for proto, count := range s.Responses {
+		if proto != want {
+			mismatched += count
+		}
+	}
+	return mismatched
+}
+
+func (s ProtocolStats) String() string {
+	protos := make([]string, 0, len(s.Responses))
+	for proto := range s.Responses {
+		protos = append(protos, proto)
+	}
+	sort.Strings(protos)
+
+	parts := make([]string, len(protos))
+	var total int64
+	for i, proto := range protos {
+		parts[i] = fmt.Sprintf("%d %s", s.Responses[proto], proto)
+		total += s.Responses[proto]
+	}
+	out := fmt.Sprintf("mode %s: %s responses over %d connections", s.Mode, strings.Join(parts, ", "), s.Connections)
+	if s.Connections > 0 {
+		out += fmt.Sprintf(" (%.1f requests per connection)", float64(total)/float64(s.Connections))
+	}
+	if mismatched := s.Mismatched(); mismatched > 0 {
+		out += fmt.Sprintf(", %d not negotiated as %s", mismatched, s.Mode)
+	}
+	return out
+}
+
+// protocolRecorder counts the connections that carried requests and the
+// protocols of their responses
+type protocolRecorder struct {
+	mode        string
+	connections int64
+
+	mu        sync.Mutex
+	responses map[string]int64
+}
+
+// trace counts each connection on the first request it carries. Dials are
+// not counted: a burst of requests to an HTTP/2 server may dial several
+// connections of which all but one are discarded unused.
+func (r *protocolRecorder) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{GotConn: func(info httptrace.GotConnInfo) {
+		if !info.Reused {
+			atomic.AddInt64(&r.connections, 1)
+		}
+	}}
+}
+
+func (r *protocolRecorder) record(proto string) {
+	r.mu.Lock()
+	r.responses[proto]++
+	r.mu.Unlock()
+}
+
+// SetProtocol selects the HTTP protocol mode on the handler's transport and
+// starts counting connections and negotiated protocols. HTTP/2 is negotiated
+// with ALPN, so ProtocolHTTP2 needs an https:// base URL. Call it after
+// SetTLS, which replaces the transport.
+func (h *APIHandler) SetProtocol(mode string) error {
+	if mode == "" {
+		mode = ProtocolAuto
+	}
+	transport, ok := h.httpClient.Transport.(*http.Transport)
+	if !ok || transport == nil {
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	}
+
+	switch mode {
+	case ProtocolAuto:
+	case ProtocolHTTP1:
+		// A non-nil empty map disables the transport's built-in HTTP/2
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	case ProtocolHTTP2:
+		if u, err := url.Parse(h.baseURL); err != nil || u.Scheme != "https" {
+			return fmt.Errorf("http2 needs an https:// api endpoint (cleartext h2c is not supported), got %q", h.baseURL)
+		}
+		transport.ForceAttemptHTTP2 = true
+		transport.TLSNextProto = nil
+	default:
+		return fmt.Errorf("unknown api protocol %q (want %s, %s or %s)", mode, ProtocolAuto, ProtocolHTTP1, ProtocolHTTP2)
+	}
+
+	h.httpClient.Transport = transport
+	h.protocol = &protocolRecorder{mode: mode, responses: make(map[string]int64)}
+	return nil
+}
+
+// ProtocolStats returns the negotiated protocols and connection count; ok is
+// false when SetProtocol was not called
+func (h *APIHandler) ProtocolStats() (stats ProtocolStats, ok bool) {
+	if h.protocol == nil {
+		return ProtocolStats{}, false
+	}
+	h.protocol.mu.Lock()
+	defer h.protocol.mu.Unlock()
+
+	responses := make(map[string]int64, len(h.protocol.responses))
+	for proto, count := range h.protocol.responses {
+		responses[proto] = count
+	}
+	return ProtocolStats{
+		Mode:        h.protocol.mode,
+		Responses:   responses,
+		Connections: atomic.LoadInt64(&h.protocol.connections),
+	}, true
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"mail-stress-test/models"
+)
+
+// newH2Server starts an HTTPS server offering HTTP/2 that answers every
+// list with no mails and the protocol it was served over
+func newH2Server(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Served-Proto", r.Proto)
+		fmt.Fprint(w, `[]`)
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+// TestProtocolNegotiation lists mails concurrently under each forced mode
+// and checks every response used that protocol, HTTP/2 multiplexing the
+// requests over a single connection
+func TestProtocolNegotiation(t *testing.T) {
+	const requests = 20
+	server := newH2Server(t)
+	caFile := writePEM(t, t.TempDir(), "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+
+	tests := []struct {
+		mode, proto string
+	}{
+		{ProtocolHTTP2, "HTTP/2.0"},
+		{ProtocolHTTP1, "HTTP/1.1"},
+	}
+	for _, tt := range tests {
+		h := NewAPIHandler(server.URL)
+		if err := h.SetTLS(TLSConfig{CAFile: caFile}); err != nil {
+			t.Fatal(err)
+		}
+		if err := h.SetProtocol(tt.mode); err != nil {
+			t.Fatalf("SetProtocol(%s): %v", tt.mode, err)
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; i < requests; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := h.ListMails(context.Background(), &models.ListMailsRequest{UserID: "user-1"}); err != nil {
+					t.Errorf("%s list: %v", tt.mode, err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		stats, ok := h.ProtocolStats()
+		if !ok {
+			t.Fatalf("%s: no protocol stats", tt.mode)
+		}
+		if stats.Responses[tt.proto] != requests || stats.Mismatched() != 0 {
+			t.Errorf("%s responses = %v, want all %d as %s", tt.mode, stats.Responses, requests, tt.proto)
+		}
+		// A concurrent start may dial several h2 connections, but only one
+		// carries requests
+		if tt.mode == ProtocolHTTP2 && stats.Connections != 1 {
+			t.Errorf("h2 used %d connections, want the requests multiplexed over one", stats.Connections)
+		}
+		if tt.mode == ProtocolHTTP1 && (stats.Connections < 1 || stats.Connections > requests) {
+			t.Errorf("HTTP/1.1 used %d connections, want 1-%d", stats.Connections, requests)
+		}
+		if !strings.Contains(stats.String(), "mode "+tt.mode) {
+			t.Errorf("String() = %q", stats.String())
+		}
+	}
+}
+
+func TestSetProtocolErrors(t *testing.T) {
+	if err := NewAPIHandler("http://localhost:8080").SetProtocol(ProtocolHTTP2); err == nil {
+		t.Error("http2 accepted over cleartext http")
+	}
+	if err := NewAPIHandler("https://localhost").SetProtocol("spdy"); err == nil {
+		t.Error("unknown protocol accepted")
+	}
+	if _, ok := NewAPIHandler("https://localhost").ProtocolStats(); ok {
+		t.Error("ProtocolStats reported without SetProtocol")
+	}
+}
+
+func TestProtocolStatsMismatched(t *testing.T) {
+	stats := ProtocolStats{Mode: ProtocolHTTP2, Responses: map[string]int64{"HTTP/2.0": 8, "HTTP/1.1": 2}, Connections: 3}
+	if got := stats.Mismatched(); got != 2 {
+		t.Errorf("Mismatched() = %d, want 2", got)
+	}
+	if out := stats.String(); !strings.Contains(out, "2 not negotiated as http2") {
+		t.Errorf("String() = %q, want the mismatch reported", out)
+	}
+	stats.Mode = ProtocolAuto
+	if got := stats.Mismatched(); got != 0 {
+		t.Errorf("auto Mismatched() = %d, want 0", got)
+	}
+}
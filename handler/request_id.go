@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"sync"
+)
+
+// RequestIDHeader carries the per-request correlation ID sent to the backend
+const RequestIDHeader = "X-Request-Id"
+
+type requestIDsKey struct{}
+
+// RequestIDs collects the correlation IDs of the API requests issued under
+// one context, e.g. the several requests of a draft flow
+type RequestIDs struct {
+	mu  sync.Mutex
+	ids []string
+}
+
+// WithRequestIDs returns a context whose API requests record their
+// correlation IDs in the returned collector
+func WithRequestIDs(ctx context.Context) (context.Context, *RequestIDs) {
+	ids := &RequestIDs{}
+	return context.WithValue(ctx, requestIDsKey{}, ids), ids
+}
+
+// List returns the collected IDs in request order
+func (r *RequestIDs) List() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.ids...)
+}
+
+func (r *RequestIDs) add(id string) {
+	r.mu.Lock()
+	r.ids = append(r.ids, id)
+	r.mu.Unlock()
+}
+
+// newRequestID returns a random 128-bit ID in hex, the format of a W3C trace ID
+func newRequestID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// tagRequest sets a fresh correlation ID on req and records it with the
+// context's collector, if any
+func tagRequest(req *http.Request) {
+	id := newRequestID()
+	req.Header.Set(RequestIDHeader, id)
+	if ids, ok := req.Context().Value(requestIDsKey{}).(*RequestIDs); ok {
+		ids.add(id)
+	}
+}
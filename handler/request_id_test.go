@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+
+	"mail-stress-test/models"
+)
+
+// TestRequestIDHeader checks every API request carries its own 128-bit hex
+// X-Request-Id and the context's collector records the IDs the backend saw
+func TestRequestIDHeader(t *testing.T) {
+	var mu sync.Mutex
+	var seen []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen = append(seen, r.Header.Get(RequestIDHeader))
+		mu.Unlock()
+		fmt.Fprint(w, `[]`)
+	}))
+	defer server.Close()
+
+	h := NewAPIHandler(server.URL)
+	ctx, ids := WithRequestIDs(context.Background())
+	for i := 0; i < 20; i++ {
+		if _, err := h.ListMails(ctx, &models.ListMailsRequest{UserID: "user-1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Requests outside a collector are tagged too
+	if _, err := h.ListMails(context.Background(), &models.ListMailsRequest{UserID: "user-1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	format := regexp.MustCompile(`^[0-9a-f]{32}$`)
+	unique := make(map[string]bool)
+	for _, id := range seen {
+		if !format.MatchString(id) {
+			t.Errorf("%s = %q, want 32 hex digits", RequestIDHeader, id)
+		}
+		if unique[id] {
+			t.Errorf("%s %q sent twice", RequestIDHeader, id)
+		}
+		unique[id] = true
+	}
+	if len(seen) != 21 {
+		t.Fatalf("backend saw %d requests, want 21", len(seen))
+	}
+	if got, want := fmt.Sprint(ids.List()), fmt.Sprint(seen[:20]); got != want {
+		t.Errorf("collected IDs = %s, want the 20 the backend saw in order %s", got, want)
+	}
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// retryBudget is a token bucket shared by every worker that caps retries
+// per second, so a failing backend can't be hidden behind unbounded retries.
+// Up to one second's worth of unused retries can accumulate.
+type retryBudget struct {
+	perSecond float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+
+	denied int64
+}
+
+func newRetryBudget(perSecond float64) *retryBudget {
+	return &retryBudget{perSecond: perSecond, tokens: perSecond, last: time.Now()}
+}
+
+// allow takes a retry from the budget, or reports it exhausted
+func (b *retryBudget) allow() bool {
+	if b == nil {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.perSecond
+	if b.tokens > b.perSecond {
+		b.tokens = b.perSecond
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		atomic.AddInt64(&b.denied, 1)
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// deniedCount returns how many retries the budget refused
+func (b *retryBudget) deniedCount() int64 {
+	if b == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&b.denied)
+}
@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"mail-stress-test/models"
+
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestRetryBudgetRefills(t *testing.T) {
+	budget := newRetryBudget(10)
+	allowed := 0
+	for i := 0; i < 50; i++ {
+		if budget.allow() {
+			allowed++
+		}
+	}
+	if allowed != 10 || budget.deniedCount() != 40 {
+		t.Errorf("allowed %d, denied %d of 50 at once; want 10 and 40", allowed, budget.deniedCount())
+	}
+
+	time.Sleep(250 * time.Millisecond)
+	allowed = 0
+	for i := 0; i < 10; i++ {
+		if budget.allow() {
+			allowed++
+		}
+	}
+	if allowed < 2 || allowed > 3 {
+		t.Errorf("allowed %d after 250ms at 10/s, want 2", allowed)
+	}
+
+	var unlimited *retryBudget
+	if !unlimited.allow() || unlimited.deniedCount() != 0 {
+		t.Error("a nil budget refused a retry")
+	}
+}
+
+// TestRetryBudgetCapsRetries conflicts every thread upsert and checks the
+// shared budget stops retrying once spent, across appends, and counts the
+// refused retries
+func TestRetryBudgetCapsRetries(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("budget spent", func(mt *mtest.T) {
+		h := NewDBHandler(newMockDB(mt))
+		h.SetMaxThreadRetries(5)
+		h.SetRetryBudget(3)
+		// The first append retries three times, the other three can't retry
+		for i := 0; i < 7; i++ {
+			mt.AddMockResponses(mtest.CreateWriteErrorsResponse(writeConflict()))
+		}
+
+		for i := 0; i < 4; i++ {
+			if err := h.AppendThread(context.Background(), "user-1", "thread-1", models.ThreadMail{}); err == nil {
+				mt.Fatal("append succeeded although every attempt conflicted")
+			}
+		}
+		if retries, exhausted := h.RetryStats(); retries != 3 || exhausted != 4 {
+			mt.Errorf("RetryStats = %d retries, %d exhausted; want 3, 4", retries, exhausted)
+		}
+		if denied := h.RetryBudgetDenied(); denied != 4 {
+			mt.Errorf("RetryBudgetDenied = %d, want 4", denied)
+		}
+		if sent := len(mt.GetAllStartedEvents()); sent != 7 {
+			mt.Errorf("%d updates sent, want 7", sent)
+		}
+	})
+
+	mt.Run("no budget", func(mt *mtest.T) {
+		h := NewDBHandler(newMockDB(mt))
+		h.SetMaxThreadRetries(2)
+		h.SetRetryBudget(0)
+		for i := 0; i < 3; i++ {
+			mt.AddMockResponses(mtest.CreateWriteErrorsResponse(writeConflict()))
+		}
+		h.AppendThread(context.Background(), "user-1", "thread-1", models.ThreadMail{})
+		if retries, _ := h.RetryStats(); retries != 2 || h.RetryBudgetDenied() != 0 {
+			mt.Errorf("%d retries, %d denied; want 2 and none", retries, h.RetryBudgetDenied())
+		}
+	})
+}
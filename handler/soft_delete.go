@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SetIncludeDeleted makes list, search and count also return mails with a
+// deletedAt tombstone. By default they are excluded unless the trash is
+// requested, as in a mail client with a trash folder.
+func (h *DBHandler) SetIncludeDeleted(include bool) {
+	h.includeDeleted = include
+}
+
+// SoftDeleteMail moves a mail to the trash by setting its deletedAt
+// tombstone; the document stays in place until purged
+func (h *DBHandler) SoftDeleteMail(ctx context.Context, mailID string) error {
+	release, err := h.inFlight.admit(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	objID, err := primitive.ObjectIDFromHex(mailID)
+	if err != nil {
+		return err
+	}
+
+	res, err := h.db.Mails().UpdateOne(ctx,
+		bson.M{"_id": objID, "deletedAt": nil},
+		bson.M{"$set": bson.M{"deletedAt": h.now()}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrMailNotFound
+	}
+	return nil
+}
+
+// addTombstoneFilter restricts filter to trashed mails when trash is set,
+// and otherwise to live mails unless deleted mails are included. Both forms
+// use the {userId, deletedAt, createdAt} index.
+func (h *DBHandler) addTombstoneFilter(filter bson.M, trash bool) bson.M {
+	switch {
+	case trash:
+		filter["deletedAt"] = bson.M{"$type": "date"}
+	case !h.includeDeleted:
+		filter["deletedAt"] = nil
+	}
+	return filter
+}
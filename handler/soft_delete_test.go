@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"mail-stress-test/database"
+	"mail-stress-test/internal/mongotest"
+	"mail-stress-test/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// sentFilter returns the filter of the last find sent
+func sentFilter(mt *mtest.T) bson.Raw {
+	events := mt.GetAllStartedEvents()
+	return events[len(events)-1].Command.Lookup("filter").Document()
+}
+
+// TestTombstoneFilterDefault checks list and search exclude tombstoned
+// mails without any configuration, the trash lists only them, and including
+// deleted mails drops the filter
+func TestTombstoneFilterDefault(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	empty := func(mt *mtest.T) bson.D {
+		return mtest.CreateCursorResponse(0, mt.DB.Name()+"."+database.DefaultMailsCollection, mtest.FirstBatch)
+	}
+
+	mt.Run("default excludes deleted", func(mt *mtest.T) {
+		h := NewDBHandler(newMockDB(mt))
+		mt.AddMockResponses(empty(mt), empty(mt), empty(mt))
+		ctx := context.Background()
+
+		if _, err := h.ListMails(ctx, &models.ListMailsRequest{UserID: "user-1"}); err != nil {
+			mt.Fatal(err)
+		}
+		if deletedAt := sentFilter(mt).Lookup("deletedAt"); deletedAt.Type != bson.TypeNull {
+			mt.Errorf("list filter deletedAt = %v, want null so tombstoned mails are excluded", deletedAt)
+		}
+		if _, err := h.SearchMails(ctx, &models.SearchMailsRequest{UserID: "user-1", SearchTerm: "invoice"}); err != nil {
+			mt.Fatal(err)
+		}
+		if deletedAt := sentFilter(mt).Lookup("deletedAt"); deletedAt.Type != bson.TypeNull {
+			mt.Errorf("search filter deletedAt = %v, want null", deletedAt)
+		}
+		if _, err := h.ListMails(ctx, &models.ListMailsRequest{UserID: "user-1", Trash: true}); err != nil {
+			mt.Fatal(err)
+		}
+		if got := sentFilter(mt).Lookup("deletedAt", "$type").StringValue(); got != "date" {
+			mt.Errorf("trash filter deletedAt $type = %q, want date", got)
+		}
+	})
+
+	mt.Run("include deleted", func(mt *mtest.T) {
+		h := NewDBHandler(newMockDB(mt))
+		h.SetIncludeDeleted(true)
+		mt.AddMockResponses(empty(mt))
+
+		if _, err := h.ListMails(context.Background(), &models.ListMailsRequest{UserID: "user-1"}); err != nil {
+			mt.Fatal(err)
+		}
+		if _, err := sentFilter(mt).LookupErr("deletedAt"); err == nil {
+			mt.Errorf("list filter %s constrains deletedAt with deleted mails included", sentFilter(mt))
+		}
+	})
+}
+
+// TestTrashIntegration soft-deletes one of two mails on a real server and
+// checks it leaves the listing, search and count but is listed in the trash
+func TestTrashIntegration(t *testing.T) {
+	mdb := mongotest.Database(t)
+	db := &database.MongoDB{Client: mdb.Client(), Database: mdb}
+	db.SetCollectionNames(database.DefaultMailsCollection, database.DefaultThreadsCollection)
+	h := NewDBHandler(db)
+	ctx := context.Background()
+
+	now := time.Now()
+	kept := models.Mail{ID: primitive.NewObjectID(), UserID: "user-1", Subject: "invoice kept", CreatedAt: now}
+	trashed := models.Mail{ID: primitive.NewObjectID(), UserID: "user-1", Subject: "invoice trashed", CreatedAt: now.Add(-time.Minute)}
+	if _, err := db.Mails().InsertMany(ctx, []interface{}{kept, trashed}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.SoftDeleteMail(ctx, trashed.ID.Hex()); err != nil {
+		t.Fatal(err)
+	}
+
+	subjects := func(mails []*models.Mail, err error) []string {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out []string
+		for _, mail := range mails {
+			out = append(out, mail.Subject)
+		}
+		return out
+	}
+	if got := subjects(h.ListMails(ctx, &models.ListMailsRequest{UserID: "user-1", Limit: 10})); len(got) != 1 || got[0] != "invoice kept" {
+		t.Errorf("inbox = %q, want only the kept mail", got)
+	}
+	if got := subjects(h.SearchMails(ctx, &models.SearchMailsRequest{UserID: "user-1", SearchTerm: "invoice", Limit: 10})); len(got) != 1 || got[0] != "invoice kept" {
+		t.Errorf("search = %q, want only the kept mail", got)
+	}
+	if n, err := h.CountMails(ctx, "user-1"); err != nil || n != 1 {
+		t.Errorf("count = %d (%v), want 1", n, err)
+	}
+
+	trash, err := h.ListMails(ctx, &models.ListMailsRequest{UserID: "user-1", Limit: 10, Trash: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trash) != 1 || trash[0].ID != trashed.ID || trash[0].DeletedAt == nil {
+		t.Errorf("trash = %+v, want the trashed mail with its tombstone", trash)
+	}
+
+	if err := h.SoftDeleteMail(ctx, trashed.ID.Hex()); !errors.Is(err, ErrMailNotFound) {
+		t.Errorf("trashing twice = %v, want ErrMailNotFound", err)
+	}
+}
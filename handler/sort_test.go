@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"mail-stress-test/database"
+	"mail-stress-test/internal/mongotest"
+	"mail-stress-test/models"
+
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// TestSortBySent checks list and search send the sort SortBy asks for, and
+// no sort at all for SortByNone
+func TestSortBySent(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	tests := []struct {
+		sortBy string
+		want   string
+	}{
+		{models.SortByDefault, `{"createdAt": {"$numberInt":"-1"}}`},
+		{models.SortByDate, `{"createdAt": {"$numberInt":"-1"}}`},
+		{models.SortBySubject, `{"subject": {"$numberInt":"1"}}`},
+		{models.SortByNone, ""},
+	}
+
+	for _, tt := range tests {
+		mt.Run(fmt.Sprintf("sort %q", tt.sortBy), func(mt *mtest.T) {
+			h := NewDBHandler(newMockDB(mt))
+			ns := mt.DB.Name() + "." + database.DefaultMailsCollection
+			mt.AddMockResponses(mtest.CreateCursorResponse(0, ns, mtest.FirstBatch), mtest.CreateCursorResponse(0, ns, mtest.FirstBatch))
+
+			if _, err := h.ListMails(context.Background(), &models.ListMailsRequest{UserID: "user-1", SortBy: tt.sortBy}); err != nil {
+				mt.Fatal(err)
+			}
+			if _, err := h.SearchMails(context.Background(), &models.SearchMailsRequest{UserID: "user-1", SearchTerm: "report", SortBy: tt.sortBy}); err != nil {
+				mt.Fatal(err)
+			}
+			for _, event := range mt.GetAllStartedEvents() {
+				sort, err := event.Command.LookupErr("sort")
+				got := ""
+				if err == nil {
+					got = sort.Document().String()
+				}
+				if got != tt.want {
+					mt.Errorf("%s sort = %s, want %s", event.CommandName, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// TestSortUsesIndexIntegration explains list and search on a collection with
+// the default indexes and checks a subject sort is read in order from the
+// {userId, subject} index and a date sort from the {userId, createdAt} one,
+// neither sorting in memory
+func TestSortUsesIndexIntegration(t *testing.T) {
+	mdb := mongotest.Database(t)
+	db := &database.MongoDB{
+		Client:            mdb.Client(),
+		Database:          mdb,
+		MailsCollection:   database.DefaultMailsCollection,
+		ThreadsCollection: database.DefaultThreadsCollection,
+	}
+	ctx := context.Background()
+	if err := db.CreateIndexes(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	mails := make([]interface{}, 2000)
+	for i := range mails {
+		mails[i] = models.Mail{
+			From:      "user-2",
+			To:        []string{"user-1"},
+			Subject:   fmt.Sprintf("report %04d", (i*7919)%len(mails)),
+			Content:   "weekly numbers",
+			UserID:    fmt.Sprintf("user-%d", i%10),
+			CreatedAt: start.Add(-time.Duration(i) * time.Second),
+		}
+	}
+	if _, err := db.Mails().InsertMany(ctx, mails); err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewDBHandler(db)
+	for _, tt := range []struct {
+		sortBy, index string
+	}{
+		{models.SortBySubject, "userId_1_subject_1"},
+		{models.SortByDate, "userId_1_createdAt_-1__id_-1"},
+	} {
+		list, err := h.ExplainList(ctx, &models.ListMailsRequest{UserID: "user-1", Limit: 20, SortBy: tt.sortBy})
+		if err != nil {
+			t.Fatal(err)
+		}
+		search, err := h.ExplainSearch(ctx, &models.SearchMailsRequest{UserID: "user-1", SearchTerm: "report", Limit: 20, SortBy: tt.sortBy})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for op, plan := range map[string]*database.QueryPlan{"list": list, "search": search} {
+			if !plan.UsesIndex(tt.index) || plan.InMemorySort {
+				t.Errorf("%s sorted by %s: %s, want an in-order IXSCAN of %s", op, tt.sortBy, plan, tt.index)
+			}
+		}
+	}
+}
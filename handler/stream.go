@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"mail-stress-test/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// NDJSONContentType is the media type of newline-delimited JSON, one mail
+// per line, requested from the backend for streamed list and search results
+const NDJSONContentType = "application/x-ndjson"
+
+// ListMailsStream runs a list query and calls fn per mail as the cursor
+// decodes it, so only the current driver batch is held in memory
+func (h *DBHandler) ListMailsStream(ctx context.Context, req *models.ListMailsRequest, fn func(*models.Mail) error) error {
+	filter, opts := h.listQuery(req)
+	opts.SetComment(h.db.QueryComment("list:stream"))
+	return h.stream(ctx, filter, opts, fn)
+}
+
+// SearchMailsStream runs a regex search and calls fn per mail as the cursor
+// decodes it
+func (h *DBHandler) SearchMailsStream(ctx context.Context, req *models.SearchMailsRequest, fn func(*models.Mail) error) error {
+	filter, opts := h.searchQuery(req)
+	opts.SetComment(h.db.QueryComment("search:stream"))
+	return h.stream(ctx, filter, opts, fn)
+}
+
+// stream decodes each mail of the query into a fresh value for fn
+func (h *DBHandler) stream(ctx context.Context, filter bson.M, opts *options.FindOptions, fn func(*models.Mail) error) error {
+	release, err := h.inFlight.admit(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	cursor, err := h.db.Mails().Find(ctx, filter, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var mail models.Mail
+		if err := cursor.Decode(&mail); err != nil {
+			return err
+		}
+		if err := fn(&mail); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// ListMailsStream posts a list request asking for NDJSON and calls fn per
+// mail as it is read off the response body
+func (h *APIHandler) ListMailsStream(ctx context.Context, req *models.ListMailsRequest, fn func(*models.Mail) error) error {
+	opCtx, cancel := h.withTimeout(ctx, "list_stream")
+	defer cancel()
+	return h.recordTimeout(ctx, opCtx, "list_stream", h.streamMails(opCtx, "/api/mails/list", req, fn))
+}
+
+// SearchMailsStream posts a search request asking for NDJSON and calls fn
+// per mail as it is read off the response body
+func (h *APIHandler) SearchMailsStream(ctx context.Context, req *models.SearchMailsRequest, fn func(*models.Mail) error) error {
+	opCtx, cancel := h.withTimeout(ctx, "search_stream")
+	defer cancel()
+	return h.recordTimeout(ctx, opCtx, "search_stream", h.streamMails(opCtx, "/api/mails/search", req, fn))
+}
+
+func (h *APIHandler) streamMails(ctx context.Context, path string, req interface{}, fn func(*models.Mail) error) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", h.baseURL+path, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", NDJSONContentType)
+
+	resp, err := h.do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer closeBody(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return &StatusError{StatusCode: resp.StatusCode, Body: string(bodyBytes)}
+	}
+
+	// A backend without NDJSON support answers with the usual array, which
+	// is still decoded element by element
+	decoder := json.NewDecoder(resp.Body)
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != NDJSONContentType {
+		return decodeArray(decoder, fn)
+	}
+	return decodeNDJSON(decoder, fn)
+}
+
+// decodeNDJSON calls fn for every JSON value of the stream
+func decodeNDJSON(decoder *json.Decoder, fn func(*models.Mail) error) error {
+	for {
+		var mail models.Mail
+		if err := decoder.Decode(&mail); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := fn(&mail); err != nil {
+			return err
+		}
+	}
+}
+
+// decodeArray calls fn for every element of a JSON array without reading
+// the whole array first; null is an empty result
+func decodeArray(decoder *json.Decoder, fn func(*models.Mail) error) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token == nil {
+		return nil
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("expected a JSON array of mails, got %v", token)
+	}
+	for decoder.More() {
+		var mail models.Mail
+		if err := decoder.Decode(&mail); err != nil {
+			return err
+		}
+		if err := fn(&mail); err != nil {
+			return err
+		}
+	}
+	_, err = decoder.Token()
+	return err
+}
@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"mail-stress-test/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// TestDBStreamMatchesList answers a list with a two-batch cursor and checks
+// the stream yields the same mails as ListMails, handing over the first
+// batch before the second is fetched
+func TestDBStreamMatchesList(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("two batches", func(mt *mtest.T) {
+		h := NewDBHandler(newMockDB(mt))
+		ns := mt.DB.Name() + "." + h.db.MailsCollection
+		mail := func(subject string) bson.D {
+			return bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "subject", Value: subject}, {Key: "userId", Value: "user-1"}}
+		}
+		first, second, third := mail("first"), mail("second"), mail("third")
+		batches := func() {
+			mt.AddMockResponses(
+				mtest.CreateCursorResponse(42, ns, mtest.FirstBatch, first, second),
+				mtest.CreateCursorResponse(0, ns, mtest.NextBatch, third),
+			)
+		}
+		req := &models.ListMailsRequest{UserID: "user-1", Limit: 3}
+
+		batches()
+		listed, err := h.ListMails(context.Background(), req)
+		if err != nil {
+			mt.Fatal(err)
+		}
+
+		batches()
+		mt.ClearEvents()
+		var streamed []string
+		err = h.ListMailsStream(context.Background(), req, func(m *models.Mail) error {
+			if len(streamed) == 0 {
+				if events := mt.GetAllStartedEvents(); len(events) != 1 || events[0].CommandName != "find" {
+					mt.Errorf("first mail delivered after %d commands, want it before the getMore", len(events))
+				}
+			}
+			streamed = append(streamed, m.Subject)
+			return nil
+		})
+		if err != nil {
+			mt.Fatal(err)
+		}
+
+		if len(listed) != len(streamed) {
+			mt.Fatalf("listed %d mails, streamed %v", len(listed), streamed)
+		}
+		for i, m := range listed {
+			if m.Subject != streamed[i] {
+				mt.Errorf("mail %d: listed %q, streamed %q", i, m.Subject, streamed[i])
+			}
+		}
+	})
+}
+
+// TestAPIStreamNDJSON serves NDJSON that pauses after the first mail until
+// the handler has delivered it, so a client reading the whole body first
+// would fail, and checks the streamed mails match the listed array
+func TestAPIStreamNDJSON(t *testing.T) {
+	mails := []models.Mail{{ID: primitive.NewObjectID(), Subject: "first"}, {ID: primitive.NewObjectID(), Subject: "second"}, {ID: primitive.NewObjectID(), Subject: "third"}}
+	delivered := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != NDJSONContentType {
+			json.NewEncoder(w).Encode(mails)
+			return
+		}
+		w.Header().Set("Content-Type", NDJSONContentType)
+		encoder := json.NewEncoder(w)
+		for i := range mails {
+			encoder.Encode(mails[i])
+			w.(http.Flusher).Flush()
+			if i == 0 {
+				select {
+				case <-delivered:
+				case <-time.After(5 * time.Second):
+					return
+				}
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	h := NewAPIHandler(server.URL)
+	req := &models.ListMailsRequest{UserID: "user-1"}
+	listed, err := h.ListMails(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var streamed []string
+	err = h.ListMailsStream(context.Background(), req, func(m *models.Mail) error {
+		if len(streamed) == 0 {
+			close(delivered)
+		}
+		streamed = append(streamed, m.Subject)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(streamed) != len(listed) {
+		t.Fatalf("streamed %v, listed %d mails", streamed, len(listed))
+	}
+	for i, m := range listed {
+		if m.Subject != streamed[i] {
+			t.Errorf("mail %d: listed %q, streamed %q", i, m.Subject, streamed[i])
+		}
+	}
+}
+
+// TestDecodeArrayStreams checks the array fallback for backends without
+// NDJSON, including null for no results, and that an error from fn stops
+// the stream
+func TestDecodeArrayStreams(t *testing.T) {
+	stop := errors.New("stop")
+	tests := []struct {
+		body    string
+		want    string
+		stopAt  string
+		wantErr error
+	}{
+		{body: `null`, want: "[]"},
+		{body: `[{"subject":"a"},{"subject":"b"}]`, want: "[a b]"},
+		{body: `[{"subject":"a"},{"subject":"b"}]`, want: "[a]", stopAt: "a", wantErr: stop},
+	}
+	for _, tt := range tests {
+		got := []string{}
+		err := decodeArray(json.NewDecoder(strings.NewReader(tt.body)), func(m *models.Mail) error {
+			got = append(got, m.Subject)
+			if m.Subject == tt.stopAt {
+				return stop
+			}
+			return nil
+		})
+		if err != tt.wantErr || fmt.Sprint(got) != tt.want {
+			t.Errorf("decodeArray(%s) = %v, %v; want %s, %v", tt.body, got, err, tt.want, tt.wantErr)
+		}
+	}
+	if err := decodeArray(json.NewDecoder(strings.NewReader(`{"subject":"a"}`)), func(*models.Mail) error { return nil }); err == nil {
+		t.Error("decodeArray accepted an object")
+	}
+}
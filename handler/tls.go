@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// TLSConfig configures HTTPS for the API target
+type TLSConfig struct {
+	CAFile             string // PEM bundle trusted in addition to the system roots
+	CertFile           string // client certificate for mutual TLS
+	KeyFile            string // client key for mutual TLS
+	InsecureSkipVerify bool   // skip server verification for self-signed endpoints
+}
+
+// enabled reports whether any TLS setting differs from the default transport
+func (c TLSConfig) enabled() bool {
+	return c.CAFile != "" || c.CertFile != "" || c.KeyFile != "" || c.InsecureSkipVerify
+}
+
+// build loads the CA bundle and client key pair into a tls.Config
+func (c TLSConfig) build() (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: c.InsecureSkipVerify}
+
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", c.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if c.CertFile != "" || c.KeyFile != "" {
+		if c.CertFile == "" || c.KeyFile == "" {
+			return nil, fmt.Errorf("client certificate and key must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// SetTLS applies cfg to a dedicated transport for HTTPS targets. A zero
+// config keeps the default transport.
+func (h *APIHandler) SetTLS(cfg TLSConfig) error {
+	if !cfg.enabled() {
+		return nil
+	}
+
+	tlsConfig, err := cfg.build()
+	if err != nil {
+		return err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	h.httpClient.Transport = transport
+	return nil
+}
@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"mail-stress-test/models"
+)
+
+// writePEM writes one PEM block to a file in dir and returns its path
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// newClientCert writes a self-signed client certificate and its key to dir
+func newClientCert(t *testing.T, dir string) (cert *x509.Certificate, certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "stress-test-client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert, err = x509.ParseCertificate(der); err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, writePEM(t, dir, "client.pem", "CERTIFICATE", der), writePEM(t, dir, "client-key.pem", "EC PRIVATE KEY", keyDER)
+}
+
+// newTLSServer starts an HTTPS server answering every list with no mails;
+// clientCA, when set, is required to have signed the client's certificate
+func newTLSServer(t *testing.T, clientCA *x509.Certificate) *httptest.Server {
+	t.Helper()
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[]`)
+	}))
+	if clientCA != nil {
+		pool := x509.NewCertPool()
+		pool.AddCert(clientCA)
+		server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool}
+	}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+// listOver lists mails through an API handler configured with cfg
+func listOver(t *testing.T, server *httptest.Server, cfg TLSConfig) error {
+	t.Helper()
+	h := NewAPIHandler(server.URL)
+	if err := h.SetTLS(cfg); err != nil {
+		t.Fatalf("SetTLS(%+v): %v", cfg, err)
+	}
+	_, err := h.ListMails(context.Background(), &models.ListMailsRequest{UserID: "user-1"})
+	return err
+}
+
+// TestTLSServerVerification checks a self-signed endpoint is rejected by
+// default and reachable once its CA is trusted or verification is skipped
+func TestTLSServerVerification(t *testing.T) {
+	server := newTLSServer(t, nil)
+	caFile := writePEM(t, t.TempDir(), "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+
+	if err := listOver(t, server, TLSConfig{}); err == nil {
+		t.Error("default transport accepted a self-signed server")
+	}
+	if err := listOver(t, server, TLSConfig{CAFile: caFile}); err != nil {
+		t.Errorf("with the server's CA trusted: %v", err)
+	}
+	if err := listOver(t, server, TLSConfig{InsecureSkipVerify: true}); err != nil {
+		t.Errorf("with verification skipped: %v", err)
+	}
+}
+
+// TestTLSClientCertificate checks a server requiring mutual TLS only
+// accepts the handler once the client certificate is configured
+func TestTLSClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+	clientCert, certFile, keyFile := newClientCert(t, dir)
+	server := newTLSServer(t, clientCert)
+	caFile := writePEM(t, dir, "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+
+	if err := listOver(t, server, TLSConfig{CAFile: caFile}); err == nil {
+		t.Error("server requiring a client certificate accepted none")
+	}
+	if err := listOver(t, server, TLSConfig{CAFile: caFile, CertFile: certFile, KeyFile: keyFile}); err != nil {
+		t.Errorf("with the client certificate: %v", err)
+	}
+}
+
+func TestTLSConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	_, certFile, _ := newClientCert(t, dir)
+	notPEM := filepath.Join(dir, "not.pem")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, cfg := range []TLSConfig{
+		{CAFile: filepath.Join(dir, "missing.pem")},
+		{CAFile: notPEM},
+		{CertFile: certFile},
+		{CertFile: certFile, KeyFile: notPEM},
+	} {
+		if err := NewAPIHandler("https://localhost").SetTLS(cfg); err == nil {
+			t.Errorf("SetTLS(%+v) accepted", cfg)
+		}
+	}
+}
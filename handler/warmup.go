@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// warmUp runs requests calls of fn on each of connections goroutines at
+// once, so the pool has to open that many connections, and returns the
+// first error
+func warmUp(connections, requests int, fn func() error) error {
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+
+	for i := 0; i < connections; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < requests; j++ {
+				if err := fn(); err != nil {
+					once.Do(func() { firstErr = err })
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// WarmUp opens connections to MongoDB by pinging from that many goroutines
+func (h *DBHandler) WarmUp(ctx context.Context, connections, requests int) error {
+	return warmUp(connections, requests, func() error {
+		return h.db.Client.Ping(ctx, nil)
+	})
+}
+
+// WarmUp raises the idle connection limit to connections, then opens and
+// handshakes that many connections with GET requests to the base URL. Any
+// HTTP status counts: only transport errors fail the warm-up.
+func (h *APIHandler) WarmUp(ctx context.Context, connections, requests int) error {
+	transport, ok := h.httpClient.Transport.(*http.Transport)
+	if !ok || transport == nil {
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	}
+	if transport.MaxIdleConnsPerHost < connections {
+		transport.MaxIdleConnsPerHost = connections
+	}
+	if transport.MaxIdleConns != 0 && transport.MaxIdleConns < connections {
+		transport.MaxIdleConns = connections
+	}
+	h.httpClient.Transport = transport
+
+	return warmUp(connections, requests, func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.baseURL, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := h.httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		return closeBody(resp.Body)
+	})
+}
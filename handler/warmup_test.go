@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestAPIWarmUpOpensConnections checks the warm-up issues requests calls on
+// each of connections connections at once and leaves them idle for reuse
+func TestAPIWarmUpOpensConnections(t *testing.T) {
+	var requests, conns atomic.Int64
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		// Hold each request so concurrent callers cannot share a connection
+		time.Sleep(20 * time.Millisecond)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	server.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	server.Start()
+	t.Cleanup(server.Close)
+
+	h := NewAPIHandler(server.URL)
+	if err := h.WarmUp(context.Background(), 8, 3); err != nil {
+		t.Fatalf("WarmUp: %v", err)
+	}
+	if requests.Load() != 24 {
+		t.Errorf("%d warm-up requests, want 24", requests.Load())
+	}
+	if conns.Load() != 8 {
+		t.Errorf("%d connections opened, want 8", conns.Load())
+	}
+
+	// The next burst of concurrent calls finds the connections idle
+	if err := h.WarmUp(context.Background(), 8, 1); err != nil {
+		t.Fatal(err)
+	}
+	if conns.Load() != 8 {
+		t.Errorf("%d connections after reuse, want still 8", conns.Load())
+	}
+}
+
+func TestWarmUpReturnsFirstError(t *testing.T) {
+	boom := errors.New("boom")
+	var calls atomic.Int64
+	err := warmUp(4, 10, func() error {
+		calls.Add(1)
+		return boom
+	})
+	if !errors.Is(err, boom) {
+		t.Errorf("warmUp error = %v, want %v", err, boom)
+	}
+	if calls.Load() != 4 {
+		t.Errorf("%d calls, want each connection to stop at its first error", calls.Load())
+	}
+}
@@ -0,0 +1,48 @@
+// Package mongotest connects integration tests to a real MongoDB server.
+// Tests using it are skipped unless MONGO_TEST_URI is set, e.g.
+//
+//	MONGO_TEST_URI=mongodb://localhost:27017 go test ./...
+package mongotest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// URIEnv names the environment variable holding the test server's URI
+const URIEnv = "MONGO_TEST_URI"
+
+// Database returns a fresh database on the server at MONGO_TEST_URI, dropped
+// when the test ends. The test is skipped when MONGO_TEST_URI is not set.
+func Database(t testing.TB) *mongo.Database {
+	t.Helper()
+	uri := os.Getenv(URIEnv)
+	if uri == "" {
+		t.Skipf("integration test: set %s to run it against a MongoDB server", URIEnv)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("connect to %s: %v", URIEnv, err)
+	}
+	if err := client.Ping(ctx, nil); err != nil {
+		t.Fatalf("ping %s: %v", URIEnv, err)
+	}
+
+	db := client.Database(fmt.Sprintf("stress_test_%d", time.Now().UnixNano()))
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		db.Drop(ctx)
+		client.Disconnect(ctx)
+	})
+	return db
+}
@@ -15,13 +15,40 @@ type Mail struct {
 	Bcc       []string           `bson:"bcc,omitempty" json:"bcc,omitempty"`
 	Subject   string             `bson:"subject" json:"subject"`
 	Content   string             `bson:"content" json:"content"`
-	Type      int                `bson:"type" json:"type"`                           // 0: received, 1: sent
+	Type      int                `bson:"type" json:"type"`                           // 0: received, 1: sent, 2: draft
 	ReplyTo   string             `bson:"replyTo,omitempty" json:"replyTo,omitempty"` // ID of mail being replied to
 	ThreadID  string             `bson:"threadId" json:"threadId"`
 	UserID    string             `bson:"userId" json:"userId"` // Owner of this mail copy
 	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+	IsRead    bool               `bson:"isRead,omitempty" json:"isRead,omitempty"`
+	DeletedAt *time.Time         `bson:"deletedAt,omitempty" json:"deletedAt,omitempty"` // soft-delete tombstone
+
+	// Snippet is the start of Content, only set by list-view projections
+	Snippet string `bson:"snippet,omitempty" json:"snippet,omitempty"`
 }
 
+// Mail views accepted by list and search requests
+const (
+	MailViewFull = ""     // whole documents
+	MailViewList = "list" // subject, from, createdAt, isRead and a content snippet
+)
+
+// Search scopes accepted by SearchMailsRequest
+const (
+	SearchScopeBoth    = ""        // subject or content
+	SearchScopeSubject = "subject" // subject only
+	SearchScopeContent = "content" // content only
+)
+
+// Result orders accepted by list and search requests
+const (
+	SortByDefault   = ""          // the query's own order: relevance where scored, else newest first
+	SortByDate      = "date"      // createdAt, newest first
+	SortBySubject   = "subject"   // subject, alphabetical
+	SortByRelevance = "relevance" // match score, where the search scores matches; else newest first
+	SortByNone      = "none"      // unsorted: index or natural order
+)
+
 // MailRequest represents a request to create a mail
 type MailRequest struct {
 	From    string   `json:"from"`
@@ -31,6 +58,33 @@ type MailRequest struct {
 	Subject string   `json:"subject"`
 	Content string   `json:"content"`
 	ReplyTo string   `json:"replyTo,omitempty"` // If replying, ID of original mail
+
+	// SentAt backdates the mail, e.g. to space the replies of a seeded
+	// thread; nil means now
+	SentAt *time.Time `json:"sentAt,omitempty"`
+}
+
+// ForwardMailRequest represents a request to forward an existing mail to a
+// new set of recipients in a new thread
+type ForwardMailRequest struct {
+	MailID string   `json:"mailId"` // ID of the mail being forwarded
+	From   string   `json:"from"`   // Forwarding user, must own MailID
+	To     []string `json:"to"`
+	Cc     []string `json:"cc,omitempty"`
+	Bcc    []string `json:"bcc,omitempty"`
+}
+
+// ReplyAllRequest represents a reply to every participant of a mail
+type ReplyAllRequest struct {
+	MailID  string `json:"mailId"` // ID of the mail being replied to
+	From    string `json:"from"`   // Replying user
+	Content string `json:"content"`
+}
+
+// DraftUpdate represents an in-place edit of a saved draft
+type DraftUpdate struct {
+	Subject string `json:"subject"`
+	Content string `json:"content"`
 }
 
 // ListMailsRequest represents a request to list mails
@@ -38,6 +92,9 @@ type ListMailsRequest struct {
 	UserID string `json:"userId"`
 	Limit  int    `json:"limit,omitempty"`
 	Offset int    `json:"offset,omitempty"`
+	View   string `json:"view,omitempty"`   // MailViewFull or MailViewList
+	Trash  bool   `json:"trash,omitempty"`  // list soft-deleted mails instead of live ones
+	SortBy string `json:"sortBy,omitempty"` // SortByDate (default), SortBySubject or SortByNone
 }
 
 // SearchMailsRequest represents a request to search mails
@@ -45,6 +102,24 @@ type SearchMailsRequest struct {
 	UserID     string `json:"userId"`
 	SearchTerm string `json:"searchTerm"`
 	Limit      int    `json:"limit,omitempty"`
+	View       string `json:"view,omitempty"`   // MailViewFull or MailViewList
+	Scope      string `json:"scope,omitempty"`  // SearchScopeBoth, SearchScopeSubject or SearchScopeContent
+	SortBy     string `json:"sortBy,omitempty"` // SortByDefault, SortByDate, SortBySubject, SortByRelevance or SortByNone
+
+	// Optional participant filters, like "from:alice" / "to:bob"
+	From string `json:"from,omitempty"` // sender
+	To   string `json:"to,omitempty"`   // any of the To recipients
+
+	// Hot marks a term drawn from the repeated (cache-hot) set; not sent to the API
+	Hot bool `json:"-"`
+
+	// Miss marks a term chosen to match nothing; not sent to the API
+	Miss bool `json:"-"`
+}
+
+// HasParticipantFilter reports whether the search is narrowed by sender or recipient
+func (r *SearchMailsRequest) HasParticipantFilter() bool {
+	return r.From != "" || r.To != ""
 }
 
 // Thread represents a mail thread document
@@ -53,7 +128,7 @@ type Thread struct {
 	ThreadID   string             `bson:"thread_id" json:"threadId"`
 	Mails      []ThreadMail       `bson:"mails" json:"mails"`
 	TotalMails int                `bson:"total_mails" json:"totalMails"`
-	UserID     primitive.ObjectID `bson:"user_id" json:"userId"`
+	UserID     primitive.ObjectID `bson:"user_id" json:"userId"` // string for non-ObjectID user ID schemes
 }
 
 // ThreadMail represents a mail reference in a thread
@@ -0,0 +1,88 @@
+package monitoring
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// diffGauges are the MetricsDiff fields exported per target
+var diffGauges = []struct {
+	name, help string
+	value      func(*MetricsDiff) float64
+}{
+	{"mail_stress_target_duration_seconds", "Span between the first and last scrape of the target", func(d *MetricsDiff) float64 {
+		return d.EndTime.Sub(d.StartTime).Seconds()
+	}},
+	{"mail_stress_target_http_requests_increase", "HTTP requests the target served during the run", func(d *MetricsDiff) float64 { return d.HTTPRequestsIncrease }},
+	{"mail_stress_target_http_requests_per_second", "Average HTTP request rate of the target during the run", func(d *MetricsDiff) float64 { return d.HTTPRequestsPerSecond }},
+	{"mail_stress_target_http_error_rate_percent", "HTTP errors as a percentage of requests during the run", func(d *MetricsDiff) float64 { return d.HTTPErrorRatePercent }},
+	{"mail_stress_target_avg_cpu_usage_percent", "Average CPU usage reported by the target", func(d *MetricsDiff) float64 { return d.AvgCPUUsagePercent }},
+	{"mail_stress_target_avg_memory_usage_mb", "Average resident memory reported by the target", func(d *MetricsDiff) float64 { return d.AvgMemoryUsageMB }},
+	{"mail_stress_target_peak_goroutines", "Highest goroutine count reported by the target", func(d *MetricsDiff) float64 { return d.PeakGoroutines }},
+	{"mail_stress_target_avg_active_connections", "Average active HTTP connections reported by the target", func(d *MetricsDiff) float64 { return d.AvgActiveConnections }},
+	{"mail_stress_target_counter_resets", "Counter drops seen during the run, i.e. target restarts", func(d *MetricsDiff) float64 { return float64(d.CounterResets) }},
+}
+
+// systemGauges are the SystemSummary fields exported per phase
+var systemGauges = []struct {
+	name, help string
+	value      func(*SystemSummary) float64
+}{
+	{"mail_stress_system_avg_cpu_usage_percent", "Average host CPU usage", func(s *SystemSummary) float64 { return s.AvgCPUUsagePercent }},
+	{"mail_stress_system_peak_cpu_usage_percent", "Peak host CPU usage", func(s *SystemSummary) float64 { return s.PeakCPUUsagePercent }},
+	{"mail_stress_system_avg_memory_usage_mb", "Average host memory usage", func(s *SystemSummary) float64 { return s.AvgMemoryUsageMB }},
+	{"mail_stress_system_peak_memory_usage_mb", "Peak host memory usage", func(s *SystemSummary) float64 { return s.PeakMemoryUsageMB }},
+	{"mail_stress_system_avg_memory_usage_percent", "Average host memory usage as a percentage", func(s *SystemSummary) float64 { return s.AvgMemoryUsagePercent }},
+	{"mail_stress_system_avg_tcp_connections", "Average TCP connections on the host", func(s *SystemSummary) float64 { return s.AvgTCPConnections }},
+	{"mail_stress_system_peak_tcp_connections", "Peak TCP connections on the host", func(s *SystemSummary) float64 { return float64(s.PeakTCPConnections) }},
+	{"mail_stress_system_avg_load_average_1min", "Average 1-minute load average of the host", func(s *SystemSummary) float64 { return s.AvgLoadAverage1Min }},
+}
+
+// WriteExposition renders the run's per-target MetricsDiff and its
+// SystemSummary (phase "load", and "cooldown" if measured) as Prometheus
+// text exposition gauges labelled with the run ID, so each run can be
+// scraped or pushed into Prometheus as one snapshot
+func (r *MonitoringReport) WriteExposition(w io.Writer) error {
+	runID := r.TestInfo.RunID
+
+	targets := make([]string, 0, len(r.PrometheusTargets))
+	for name, target := range r.PrometheusTargets {
+		if target.Available && target.Diff != nil {
+			targets = append(targets, name)
+		}
+	}
+	sort.Strings(targets)
+	if len(targets) > 0 {
+		for _, gauge := range diffGauges {
+			writeGaugeHeader(w, gauge.name, gauge.help)
+			for _, name := range targets {
+				fmt.Fprintf(w, "%s{run_id=%q,target=%q} %g\n", gauge.name, runID, name, gauge.value(r.PrometheusTargets[name].Diff))
+			}
+		}
+	}
+
+	phases := []struct {
+		name    string
+		summary *SystemSummary
+	}{{"load", r.SystemSummary}, {"cooldown", r.CooldownSummary}}
+	if r.SystemSummary != nil {
+		for _, gauge := range systemGauges {
+			writeGaugeHeader(w, gauge.name, gauge.help)
+			for _, phase := range phases {
+				if phase.summary != nil {
+					fmt.Fprintf(w, "%s{run_id=%q,phase=%q} %g\n", gauge.name, runID, phase.name, gauge.value(phase.summary))
+				}
+			}
+		}
+	}
+
+	writeGaugeHeader(w, "mail_stress_run_end_timestamp_seconds", "When the run's monitoring ended")
+	_, err := fmt.Fprintf(w, "mail_stress_run_end_timestamp_seconds{run_id=%q} %d\n", runID, r.TestInfo.EndTime.Unix())
+	return err
+}
+
+func writeGaugeHeader(w io.Writer, name, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s gauge\n", name)
+}
@@ -0,0 +1,83 @@
+package monitoring
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestExpositionParsesBack renders a report with two available targets, one
+// unavailable target and both phases, parses the text with the scrape parser
+// and checks every gauge comes back with the report's value
+func TestExpositionParsesBack(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	report := &MonitoringReport{
+		PrometheusTargets: map[string]*PrometheusTargetReport{
+			"api": {Available: true, Diff: &MetricsDiff{StartTime: start, EndTime: start.Add(90 * time.Second),
+				HTTPRequestsIncrease: 12000, HTTPRequestsPerSecond: 133.33333333333334, HTTPErrorRatePercent: 0.25,
+				AvgCPUUsagePercent: 41.5, AvgMemoryUsageMB: 512.75, PeakGoroutines: 340, AvgActiveConnections: 18.2, CounterResets: 1}},
+			"proxy": {Available: true, Diff: &MetricsDiff{StartTime: start, EndTime: start.Add(time.Minute), HTTPRequestsIncrease: 6000}},
+			"down":  {Available: false},
+		},
+		SystemSummary:   &SystemSummary{AvgCPUUsagePercent: 63.2, PeakCPUUsagePercent: 97, AvgMemoryUsageMB: 2048, PeakTCPConnections: 1500, AvgLoadAverage1Min: 3.75},
+		CooldownSummary: &SystemSummary{AvgCPUUsagePercent: 4.1, PeakTCPConnections: 40},
+	}
+	report.TestInfo.RunID = "run-1"
+	report.TestInfo.EndTime = start.Add(2 * time.Minute)
+
+	var out strings.Builder
+	if err := report.WriteExposition(&out); err != nil {
+		t.Fatal(err)
+	}
+	parsed := &PrometheusMetrics{CustomMetrics: make(map[string]float64)}
+	if err := NewPrometheusClient("", PrometheusAuthConfig{}).parsePrometheusFormat(out.String(), parsed); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]float64{
+		`mail_stress_run_end_timestamp_seconds{run_id="run-1"}`: float64(report.TestInfo.EndTime.Unix()),
+	}
+	for _, gauge := range diffGauges {
+		for _, name := range []string{"api", "proxy"} {
+			want[fmt.Sprintf("%s{run_id=%q,target=%q}", gauge.name, "run-1", name)] = gauge.value(report.PrometheusTargets[name].Diff)
+		}
+	}
+	for _, gauge := range systemGauges {
+		want[fmt.Sprintf("%s{run_id=%q,phase=%q}", gauge.name, "run-1", "load")] = gauge.value(report.SystemSummary)
+		want[fmt.Sprintf("%s{run_id=%q,phase=%q}", gauge.name, "run-1", "cooldown")] = gauge.value(report.CooldownSummary)
+	}
+	if len(parsed.CustomMetrics) != len(want) {
+		t.Errorf("parsed %d series, want %d:\n%s", len(parsed.CustomMetrics), len(want), out.String())
+	}
+	for series, value := range want {
+		if got, ok := parsed.CustomMetrics[series]; !ok || got != value {
+			t.Errorf("%s = %v (present %v), want %v", series, got, ok, value)
+		}
+	}
+
+	for _, gauge := range []string{diffGauges[0].name, systemGauges[0].name, "mail_stress_run_end_timestamp_seconds"} {
+		if n := strings.Count(out.String(), "# TYPE "+gauge+" gauge\n"); n != 1 {
+			t.Errorf("%s has %d TYPE lines, want 1", gauge, n)
+		}
+	}
+	if strings.Contains(out.String(), `target="down"`) {
+		t.Error("unavailable target exported")
+	}
+}
+
+// TestExpositionWithoutData checks a report with no available target and no
+// system summary still exports just the run end timestamp
+func TestExpositionWithoutData(t *testing.T) {
+	report := &MonitoringReport{PrometheusTargets: map[string]*PrometheusTargetReport{"down": {}}}
+	report.TestInfo.RunID = "run-2"
+	report.TestInfo.EndTime = time.Unix(1700000000, 0)
+
+	var out strings.Builder
+	if err := report.WriteExposition(&out); err != nil {
+		t.Fatal(err)
+	}
+	if want := "mail_stress_run_end_timestamp_seconds{run_id=\"run-2\"} 1700000000\n"; !strings.HasSuffix(out.String(), want) || strings.Count(out.String(), "# TYPE") != 1 {
+		t.Errorf("exposition = %q, want only the run end timestamp", out.String())
+	}
+}
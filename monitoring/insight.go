@@ -0,0 +1,85 @@
+package monitoring
+
+import "fmt"
+
+// Insight severities, from least to most urgent
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Insight categories
+const (
+	CategoryErrors      = "errors"
+	CategoryCPU         = "cpu"
+	CategoryMemory      = "memory"
+	CategoryConnections = "connections"
+	CategoryRestarts    = "restarts"
+)
+
+// Insight is one threshold breach found in the monitoring data
+type Insight struct {
+	Severity  string  `json:"severity"`
+	Category  string  `json:"category"`
+	Target    string  `json:"target,omitempty"` // Prometheus target name; empty for system metrics
+	Metric    string  `json:"metric"`
+	Value     float64 `json:"value"`
+	Threshold float64 `json:"threshold"`
+	Message   string  `json:"message"`
+	Rendered  string  `json:"rendered"` // Message with severity icon and target, as printed
+}
+
+// severityIcons prefixes rendered insights on the console
+var severityIcons = map[string]string{
+	SeverityInfo:     "📡",
+	SeverityWarning:  "⚠️ ",
+	SeverityCritical: "🔥",
+}
+
+// newInsight builds an insight and its rendered display string
+func newInsight(severity, category, target, metric string, value, threshold float64, message string) Insight {
+	insight := Insight{
+		Severity:  severity,
+		Category:  category,
+		Target:    target,
+		Metric:    metric,
+		Value:     value,
+		Threshold: threshold,
+		Message:   message,
+	}
+	insight.Rendered = insight.render()
+	return insight
+}
+
+// render formats the insight for console output
+func (i Insight) render() string {
+	if i.Target != "" {
+		return fmt.Sprintf("%s [%s] %s", severityIcons[i.Severity], i.Target, i.Message)
+	}
+	return fmt.Sprintf("%s %s", severityIcons[i.Severity], i.Message)
+}
+
+// String returns the rendered display form
+func (i Insight) String() string {
+	if i.Rendered != "" {
+		return i.Rendered
+	}
+	return i.render()
+}
+
+// FilterInsights returns the insights matching severity and category; an
+// empty argument matches any value
+func (r *MonitoringReport) FilterInsights(severity, category string) []Insight {
+	var matched []Insight
+	for _, insight := range r.Insights {
+		if severity != "" && insight.Severity != severity {
+			continue
+		}
+		if category != "" && insight.Category != category {
+			continue
+		}
+		matched = append(matched, insight)
+	}
+	return matched
+}
@@ -0,0 +1,62 @@
+package monitoring
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// TestInsightJSON checks an insight keeps its structured fields and its
+// rendered console form through the JSON report
+func TestInsightJSON(t *testing.T) {
+	insight := newInsight(SeverityCritical, CategoryErrors, "proxy", "http_error_rate_percent", 12.5, 5,
+		"High error rate detected: 12.50%")
+	if want := "🔥 [proxy] High error rate detected: 12.50%"; insight.String() != want {
+		t.Errorf("String() = %q, want %q", insight.String(), want)
+	}
+	if system := newInsight(SeverityInfo, CategoryConnections, "", "peak_tcp_connections", 1500, 1000, "Peak connections: 1500"); system.String() != "📡 Peak connections: 1500" {
+		t.Errorf("system insight String() = %q", system.String())
+	}
+
+	data, err := json.Marshal(&MonitoringReport{Insights: []Insight{insight}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded MonitoringReport
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Insights) != 1 || decoded.Insights[0] != insight {
+		t.Errorf("decoded insights = %+v, want %+v", decoded.Insights, insight)
+	}
+}
+
+func TestFilterInsights(t *testing.T) {
+	report := &MonitoringReport{Insights: []Insight{
+		newInsight(SeverityCritical, CategoryErrors, "app", "http_error_rate_percent", 9, 5, "errors"),
+		newInsight(SeverityWarning, CategoryCPU, "app", "avg_cpu_usage_percent", 85, 80, "cpu"),
+		newInsight(SeverityCritical, CategoryCPU, "", "peak_cpu_usage_percent", 95, 90, "peak cpu"),
+		newInsight(SeverityWarning, CategoryMemory, "app", "avg_memory_usage_mb", 2048, 1024, "memory"),
+	}}
+
+	for _, tt := range []struct {
+		severity, category string
+		want               []string
+	}{
+		{"", "", []string{"errors", "cpu", "peak cpu", "memory"}},
+		{SeverityCritical, "", []string{"errors", "peak cpu"}},
+		{"", CategoryCPU, []string{"cpu", "peak cpu"}},
+		{SeverityWarning, CategoryCPU, []string{"cpu"}},
+		{SeverityInfo, "", nil},
+	} {
+		got := report.FilterInsights(tt.severity, tt.category)
+		if len(got) != len(tt.want) {
+			t.Errorf("FilterInsights(%q, %q) = %+v, want %v", tt.severity, tt.category, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i].Message != tt.want[i] {
+				t.Errorf("FilterInsights(%q, %q)[%d] = %q, want %q", tt.severity, tt.category, i, got[i].Message, tt.want[i])
+			}
+		}
+	}
+}
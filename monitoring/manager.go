@@ -1,26 +1,52 @@
 package monitoring
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"fmt"
+	"math/rand"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 )
 
 // MonitoringManager orchestrates all monitoring activities during stress test
 type MonitoringManager struct {
-	prometheusClient *PrometheusClient
-	systemMonitor    *SystemMonitor
-	config           MonitoringManagerConfig
-
-	// Collected data
-	prometheusSnapshots []*PrometheusMetrics
-	systemSnapshots     []*SystemMetrics
-	startTime           time.Time
-	endTime             time.Time
+	prometheusTargets []*prometheusTarget
+	systemMonitor     *SystemMonitor
+	config            MonitoringManagerConfig
+
+	// Collected data, guarded by mu since the background collector appends
+	mu              sync.Mutex
+	systemSnapshots []*SystemMetrics
+	startTime       time.Time
+	endTime         time.Time
+	loadEndTime     time.Time // set when a cooldown starts
+
+	// Background collector lifecycle: closing done stops it, wg waits for exit
+	done     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// PrometheusTarget is a named metrics endpoint, e.g. the app, a database
+// exporter or a proxy
+type PrometheusTarget struct {
+	Name string
+	URL  string
+	Auth PrometheusAuthConfig
+}
+
+// prometheusTarget holds the client and collected snapshots for one target
+type prometheusTarget struct {
+	name      string
+	url       string
+	client    *PrometheusClient
+	snapshots []*PrometheusMetrics
 }
 
 // MonitoringManagerConfig configures the monitoring manager
@@ -28,6 +54,10 @@ type MonitoringManagerConfig struct {
 	// Prometheus settings
 	EnablePrometheus bool
 	PrometheusURL    string // e.g., "http://localhost:9090/metrics"
+	PrometheusAuth   PrometheusAuthConfig
+
+	// Additional named targets scraped alongside PrometheusURL
+	PrometheusTargets []PrometheusTarget
 
 	// System monitoring settings
 	EnableSystemMonitor bool
@@ -35,30 +65,59 @@ type MonitoringManagerConfig struct {
 
 	// Collection settings
 	ScrapeInterval    time.Duration
+	ScrapeJitter      float64 // each interval varies by +/- this fraction (0 = fixed)
 	OutputDir         string
 	EnableRealtimeLog bool
+	RunID             string
+	Compress          bool // write the report as gzip-compressed .json.gz
+	ExportExposition  bool // also write the diffs and summaries as Prometheus exposition text
 }
 
 // MonitoringReport contains complete monitoring results
 type MonitoringReport struct {
 	TestInfo struct {
+		RunID     string    `json:"run_id"`
 		StartTime time.Time `json:"start_time"`
 		EndTime   time.Time `json:"end_time"`
 		Duration  string    `json:"duration"`
+
+		// LoadEndTime is when load stopped and the cooldown began
+		LoadEndTime time.Time `json:"load_end_time,omitempty"`
+		Cooldown    string    `json:"cooldown,omitempty"`
 	} `json:"test_info"`
 
-	// Prometheus metrics
+	// Prometheus metrics (of the first available target, kept for compatibility)
 	PrometheusAvailable bool                 `json:"prometheus_available"`
 	PrometheusDiff      *MetricsDiff         `json:"prometheus_diff,omitempty"`
 	PrometheusSnapshots []*PrometheusMetrics `json:"prometheus_snapshots,omitempty"`
 
-	// System metrics
+	// Per-target Prometheus results keyed by target name
+	PrometheusTargets map[string]*PrometheusTargetReport `json:"prometheus_targets,omitempty"`
+
+	// System metrics. SystemSummary covers the load only; SystemSnapshots
+	// include the cooldown
 	SystemAvailable bool             `json:"system_available"`
 	SystemSummary   *SystemSummary   `json:"system_summary,omitempty"`
 	SystemSnapshots []*SystemMetrics `json:"system_snapshots,omitempty"`
 
+	// CooldownSummary aggregates the system snapshots taken after load
+	// stopped, showing post-load recovery
+	CooldownSummary *SystemSummary `json:"cooldown_system_summary,omitempty"`
+
 	// Performance insights
-	Insights []string `json:"insights"`
+	Insights []Insight `json:"insights"`
+
+	// InsufficientData lists the sources that collected too few snapshots
+	// to compute a diff, and why
+	InsufficientData []string `json:"insufficient_data,omitempty"`
+}
+
+// PrometheusTargetReport contains the monitoring results of one named target
+type PrometheusTargetReport struct {
+	URL       string               `json:"url"`
+	Available bool                 `json:"available"`
+	Diff      *MetricsDiff         `json:"diff,omitempty"`
+	Snapshots []*PrometheusMetrics `json:"snapshots,omitempty"`
 }
 
 // SystemSummary provides aggregated system metrics
@@ -75,13 +134,28 @@ type SystemSummary struct {
 
 func NewMonitoringManager(config MonitoringManagerConfig) *MonitoringManager {
 	mm := &MonitoringManager{
-		config:              config,
-		prometheusSnapshots: make([]*PrometheusMetrics, 0),
-		systemSnapshots:     make([]*SystemMetrics, 0),
+		config:          config,
+		systemSnapshots: make([]*SystemMetrics, 0),
 	}
 
 	if config.EnablePrometheus {
-		mm.prometheusClient = NewPrometheusClient(config.PrometheusURL)
+		targets := config.PrometheusTargets
+		if config.PrometheusURL != "" {
+			targets = append([]PrometheusTarget{{
+				Name: "default",
+				URL:  config.PrometheusURL,
+				Auth: config.PrometheusAuth,
+			}}, targets...)
+		}
+
+		for _, target := range targets {
+			mm.prometheusTargets = append(mm.prometheusTargets, &prometheusTarget{
+				name:      target.Name,
+				url:       target.URL,
+				client:    NewPrometheusClient(target.URL, target.Auth),
+				snapshots: make([]*PrometheusMetrics, 0),
+			})
+		}
 	}
 
 	if config.EnableSystemMonitor {
@@ -103,13 +177,13 @@ func (mm *MonitoringManager) StartMonitoring(ctx context.Context) error {
 	fmt.Println("\n🔍 Starting monitoring...")
 
 	// Take initial snapshots
-	if mm.prometheusClient != nil {
-		metrics, err := mm.prometheusClient.ScrapeMetrics(ctx)
+	for _, target := range mm.prometheusTargets {
+		metrics, err := target.client.ScrapeMetrics(ctx)
 		if err != nil {
-			fmt.Printf("⚠️  Warning: Failed to scrape initial Prometheus metrics: %v\n", err)
+			fmt.Printf("⚠️  Warning: Failed to scrape initial Prometheus metrics [%s]: %v\n", target.name, err)
 		} else {
-			mm.prometheusSnapshots = append(mm.prometheusSnapshots, metrics)
-			fmt.Println("✅ Prometheus monitoring started")
+			mm.addPrometheusSnapshot(target, metrics)
+			fmt.Printf("✅ Prometheus monitoring started [%s]\n", target.name)
 		}
 	}
 
@@ -118,39 +192,94 @@ func (mm *MonitoringManager) StartMonitoring(ctx context.Context) error {
 		if err != nil {
 			fmt.Printf("⚠️  Warning: Failed to collect initial system metrics: %v\n", err)
 		} else {
-			mm.systemSnapshots = append(mm.systemSnapshots, metrics)
+			mm.addSystemSnapshot(metrics)
 			fmt.Println("✅ System monitoring started")
 		}
 	}
 
 	// Start periodic collection in background
-	go mm.periodicCollection(ctx)
+	mm.done = make(chan struct{})
+	mm.wg.Add(1)
+	go func() {
+		defer mm.wg.Done()
+		mm.periodicCollection(ctx)
+	}()
 
 	return nil
 }
 
-// periodicCollection collects metrics at regular intervals
+// addPrometheusSnapshot appends a scrape result for target
+func (mm *MonitoringManager) addPrometheusSnapshot(target *prometheusTarget, metrics *PrometheusMetrics) {
+	mm.mu.Lock()
+	target.snapshots = append(target.snapshots, metrics)
+	mm.mu.Unlock()
+}
+
+// addSystemSnapshot appends a system metrics sample
+func (mm *MonitoringManager) addSystemSnapshot(metrics *SystemMetrics) {
+	mm.mu.Lock()
+	mm.systemSnapshots = append(mm.systemSnapshots, metrics)
+	mm.mu.Unlock()
+}
+
+// Latest returns the most recent system snapshot and the most recent scrape
+// of each Prometheus target, or nil when none was collected yet
+func (mm *MonitoringManager) Latest() (*SystemMetrics, map[string]*PrometheusMetrics) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	var system *SystemMetrics
+	if n := len(mm.systemSnapshots); n > 0 {
+		system = mm.systemSnapshots[n-1]
+	}
+
+	targets := make(map[string]*PrometheusMetrics, len(mm.prometheusTargets))
+	for _, target := range mm.prometheusTargets {
+		if n := len(target.snapshots); n > 0 {
+			targets[target.name] = target.snapshots[n-1]
+		}
+	}
+	return system, targets
+}
+
+// stopCollector signals the background collector and waits for it to exit
+func (mm *MonitoringManager) stopCollector() {
+	mm.stopOnce.Do(func() {
+		if mm.done != nil {
+			close(mm.done)
+		}
+	})
+	mm.wg.Wait()
+}
+
+// periodicCollection collects metrics every ScrapeInterval, jittered so
+// samples don't alias with periodic backend behavior. Rates are computed
+// from snapshot timestamps, so uneven intervals don't skew them.
 func (mm *MonitoringManager) periodicCollection(ctx context.Context) {
-	ticker := time.NewTicker(mm.config.ScrapeInterval)
-	defer ticker.Stop()
+	timer := time.NewTimer(jitteredInterval(mm.config.ScrapeInterval, mm.config.ScrapeJitter, rand.Float64()))
+	defer timer.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-ticker.C:
+		case <-mm.done:
+			return
+		case <-timer.C:
+			timer.Reset(jitteredInterval(mm.config.ScrapeInterval, mm.config.ScrapeJitter, rand.Float64()))
+
 			// Collect Prometheus metrics
-			if mm.prometheusClient != nil {
-				metrics, err := mm.prometheusClient.ScrapeMetrics(ctx)
+			for _, target := range mm.prometheusTargets {
+				metrics, err := target.client.ScrapeMetrics(ctx)
 				if err != nil {
 					if mm.config.EnableRealtimeLog {
-						fmt.Printf("⚠️  Failed to scrape Prometheus metrics: %v\n", err)
+						fmt.Printf("⚠️  Failed to scrape Prometheus metrics [%s]: %v\n", target.name, err)
 					}
 				} else {
-					mm.prometheusSnapshots = append(mm.prometheusSnapshots, metrics)
+					mm.addPrometheusSnapshot(target, metrics)
 					if mm.config.EnableRealtimeLog {
-						fmt.Printf("📊 Prometheus: CPU=%.1f%%, Mem=%.1fMB, Requests=%.0f\n",
-							metrics.CPUUsagePercent, metrics.MemoryUsageMB, metrics.HTTPRequestsTotal)
+						fmt.Printf("📊 Prometheus [%s]: CPU=%.1f%%, Mem=%.1fMB, Requests=%.0f\n",
+							target.name, metrics.CPUUsagePercent, metrics.MemoryUsageMB, metrics.HTTPRequestsTotal)
 					}
 				}
 			}
@@ -163,7 +292,7 @@ func (mm *MonitoringManager) periodicCollection(ctx context.Context) {
 						fmt.Printf("⚠️  Failed to collect system metrics: %v\n", err)
 					}
 				} else {
-					mm.systemSnapshots = append(mm.systemSnapshots, metrics)
+					mm.addSystemSnapshot(metrics)
 					if mm.config.EnableRealtimeLog {
 						fmt.Printf("💻 System: CPU=%.1f%%, Mem=%.1f%%, Connections=%d\n",
 							metrics.CPUUsagePercent, metrics.MemoryUsagePercent, metrics.TCPEstablished)
@@ -174,19 +303,50 @@ func (mm *MonitoringManager) periodicCollection(ctx context.Context) {
 	}
 }
 
+// jitteredInterval scales base by 1 + jitter*(2r-1) for r in [0, 1), so
+// intervals spread evenly over base +/- jitter. jitter is clamped to [0, 1).
+func jitteredInterval(base time.Duration, jitter, r float64) time.Duration {
+	if jitter <= 0 {
+		return base
+	}
+	if jitter >= 1 {
+		jitter = 0.99
+	}
+	return time.Duration(float64(base) * (1 + jitter*(2*r-1)))
+}
+
+// Cooldown keeps collecting for d after the load has stopped, so the report
+// captures recovery (connection drain, GC, queue flush). It returns early if
+// ctx is cancelled; call StopMonitoring afterwards.
+func (mm *MonitoringManager) Cooldown(ctx context.Context, d time.Duration) {
+	mm.mu.Lock()
+	mm.loadEndTime = time.Now()
+	mm.mu.Unlock()
+
+	fmt.Printf("\n🧊 Cooldown: load stopped, monitoring for another %s...\n", d)
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
+
 // StopMonitoring stops collecting metrics and generates report
 func (mm *MonitoringManager) StopMonitoring(ctx context.Context) (*MonitoringReport, error) {
-	mm.endTime = time.Now()
-
 	fmt.Println("\n🛑 Stopping monitoring...")
 
+	// Wait for the background collector so it cannot append concurrently
+	mm.stopCollector()
+	mm.endTime = time.Now()
+
 	// Take final snapshots
-	if mm.prometheusClient != nil {
-		metrics, err := mm.prometheusClient.ScrapeMetrics(ctx)
+	for _, target := range mm.prometheusTargets {
+		metrics, err := target.client.ScrapeMetrics(ctx)
 		if err != nil {
-			fmt.Printf("⚠️  Warning: Failed to scrape final Prometheus metrics: %v\n", err)
+			fmt.Printf("⚠️  Warning: Failed to scrape final Prometheus metrics [%s]: %v\n", target.name, err)
 		} else {
-			mm.prometheusSnapshots = append(mm.prometheusSnapshots, metrics)
+			mm.addPrometheusSnapshot(target, metrics)
 		}
 	}
 
@@ -195,7 +355,7 @@ func (mm *MonitoringManager) StopMonitoring(ctx context.Context) (*MonitoringRep
 		if err != nil {
 			fmt.Printf("⚠️  Warning: Failed to collect final system metrics: %v\n", err)
 		} else {
-			mm.systemSnapshots = append(mm.systemSnapshots, metrics)
+			mm.addSystemSnapshot(metrics)
 		}
 	}
 
@@ -214,71 +374,135 @@ func (mm *MonitoringManager) StopMonitoring(ctx context.Context) (*MonitoringRep
 
 // generateReport creates monitoring report from collected data
 func (mm *MonitoringManager) generateReport() *MonitoringReport {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
 	report := &MonitoringReport{
-		Insights: make([]string, 0),
+		Insights: make([]Insight, 0),
 	}
 
+	report.TestInfo.RunID = mm.config.RunID
 	report.TestInfo.StartTime = mm.startTime
 	report.TestInfo.EndTime = mm.endTime
 	report.TestInfo.Duration = mm.endTime.Sub(mm.startTime).String()
+	if !mm.loadEndTime.IsZero() {
+		report.TestInfo.LoadEndTime = mm.loadEndTime
+		report.TestInfo.Cooldown = mm.endTime.Sub(mm.loadEndTime).String()
+	}
+
+	// Process Prometheus data per target
+	if len(mm.prometheusTargets) > 0 {
+		report.PrometheusTargets = make(map[string]*PrometheusTargetReport)
+	}
+	for _, target := range mm.prometheusTargets {
+		targetReport := &PrometheusTargetReport{URL: target.url}
+		report.PrometheusTargets[target.name] = targetReport
 
-	// Process Prometheus data
-	if len(mm.prometheusSnapshots) >= 2 {
-		report.PrometheusAvailable = true
-		start := mm.prometheusSnapshots[0]
-		end := mm.prometheusSnapshots[len(mm.prometheusSnapshots)-1]
-		report.PrometheusDiff = mm.prometheusClient.CalculateDiff(start, end)
-		report.PrometheusSnapshots = mm.prometheusSnapshots
+		if len(target.snapshots) < 2 {
+			report.InsufficientData = append(report.InsufficientData, insufficientSnapshots("prometheus ["+target.name+"]", len(target.snapshots)))
+			continue
+		}
+
+		targetReport.Available = true
+		targetReport.Diff = target.client.CalculateSeriesDiff(target.snapshots)
+		targetReport.Snapshots = target.snapshots
+
+		if !report.PrometheusAvailable {
+			report.PrometheusAvailable = true
+			report.PrometheusDiff = targetReport.Diff
+			report.PrometheusSnapshots = targetReport.Snapshots
+		}
 
 		// Add insights
-		if report.PrometheusDiff.HTTPErrorRatePercent > 5 {
-			report.Insights = append(report.Insights,
-				fmt.Sprintf("⚠️  High error rate detected: %.2f%%", report.PrometheusDiff.HTTPErrorRatePercent))
+		diff := targetReport.Diff
+		if diff.CounterResets > 0 {
+			report.Insights = append(report.Insights, newInsight(SeverityWarning, CategoryRestarts, target.name,
+				"counter_resets", float64(diff.CounterResets), 0,
+				fmt.Sprintf("Counters reset %d time(s) - target restarted mid-run, request totals exclude lost counts", diff.CounterResets)))
+		}
+		if diff.HTTPErrorRatePercent > 5 {
+			report.Insights = append(report.Insights, newInsight(SeverityCritical, CategoryErrors, target.name,
+				"http_error_rate_percent", diff.HTTPErrorRatePercent, 5,
+				fmt.Sprintf("High error rate detected: %.2f%%", diff.HTTPErrorRatePercent)))
 		}
-		if report.PrometheusDiff.AvgCPUUsagePercent > 80 {
-			report.Insights = append(report.Insights,
-				fmt.Sprintf("⚠️  High CPU usage: %.2f%%", report.PrometheusDiff.AvgCPUUsagePercent))
+		if diff.AvgCPUUsagePercent > 80 {
+			report.Insights = append(report.Insights, newInsight(SeverityWarning, CategoryCPU, target.name,
+				"avg_cpu_usage_percent", diff.AvgCPUUsagePercent, 80,
+				fmt.Sprintf("High CPU usage: %.2f%%", diff.AvgCPUUsagePercent)))
 		}
-		if report.PrometheusDiff.AvgMemoryUsageMB > 1024 {
-			report.Insights = append(report.Insights,
-				fmt.Sprintf("⚠️  High memory usage: %.2fMB", report.PrometheusDiff.AvgMemoryUsageMB))
+		if diff.AvgMemoryUsageMB > 1024 {
+			report.Insights = append(report.Insights, newInsight(SeverityWarning, CategoryMemory, target.name,
+				"avg_memory_usage_mb", diff.AvgMemoryUsageMB, 1024,
+				fmt.Sprintf("High memory usage: %.2fMB", diff.AvgMemoryUsageMB)))
 		}
 	}
 
 	// Process system data
+	if mm.systemMonitor != nil && len(mm.systemSnapshots) < 2 {
+		report.InsufficientData = append(report.InsufficientData, insufficientSnapshots("system", len(mm.systemSnapshots)))
+	}
 	if len(mm.systemSnapshots) >= 2 {
 		report.SystemAvailable = true
-		report.SystemSummary = mm.calculateSystemSummary()
 		report.SystemSnapshots = mm.systemSnapshots
 
-		// Add system insights
+		// The run summary covers the load only, so an idle cooldown doesn't
+		// drag its averages down; the cooldown is summarized on its own
+		load := mm.systemSnapshots
+		if !mm.loadEndTime.IsZero() {
+			load = nil
+			var cooldown []*SystemMetrics
+			for _, snapshot := range mm.systemSnapshots {
+				if snapshot.Timestamp.Before(mm.loadEndTime) {
+					load = append(load, snapshot)
+				} else {
+					cooldown = append(cooldown, snapshot)
+				}
+			}
+			report.CooldownSummary = summarizeSystem(cooldown)
+		}
+		report.SystemSummary = summarizeSystem(load)
+	}
+
+	// Add system insights
+	if report.SystemSummary != nil {
 		if report.SystemSummary.PeakCPUUsagePercent > 90 {
-			report.Insights = append(report.Insights,
-				fmt.Sprintf("🔥 CPU peaked at %.2f%% - consider scaling", report.SystemSummary.PeakCPUUsagePercent))
+			report.Insights = append(report.Insights, newInsight(SeverityCritical, CategoryCPU, "",
+				"peak_cpu_usage_percent", report.SystemSummary.PeakCPUUsagePercent, 90,
+				fmt.Sprintf("CPU peaked at %.2f%% - consider scaling", report.SystemSummary.PeakCPUUsagePercent)))
 		}
 		if report.SystemSummary.AvgMemoryUsagePercent > 85 {
-			report.Insights = append(report.Insights,
-				fmt.Sprintf("🔥 Memory usage high: %.2f%% - risk of OOM", report.SystemSummary.AvgMemoryUsagePercent))
+			report.Insights = append(report.Insights, newInsight(SeverityCritical, CategoryMemory, "",
+				"avg_memory_usage_percent", report.SystemSummary.AvgMemoryUsagePercent, 85,
+				fmt.Sprintf("Memory usage high: %.2f%% - risk of OOM", report.SystemSummary.AvgMemoryUsagePercent)))
 		}
 		if report.SystemSummary.PeakTCPConnections > 1000 {
-			report.Insights = append(report.Insights,
-				fmt.Sprintf("📡 Peak connections: %d - ensure connection pooling", report.SystemSummary.PeakTCPConnections))
+			report.Insights = append(report.Insights, newInsight(SeverityInfo, CategoryConnections, "",
+				"peak_tcp_connections", float64(report.SystemSummary.PeakTCPConnections), 1000,
+				fmt.Sprintf("Peak connections: %d - ensure connection pooling", report.SystemSummary.PeakTCPConnections)))
 		}
 	}
 
 	return report
 }
 
-// calculateSystemSummary computes aggregate metrics from system snapshots
-func (mm *MonitoringManager) calculateSystemSummary() *SystemSummary {
-	if len(mm.systemSnapshots) == 0 {
+// insufficientSnapshots explains why source has no diff
+func insufficientSnapshots(source string, n int) string {
+	if n == 0 {
+		return fmt.Sprintf("%s: no snapshots collected; monitoring unavailable (source unreachable)", source)
+	}
+	return fmt.Sprintf("%s: only %d snapshot, need 2; the run was shorter than scrape_interval or later scrapes failed", source, n)
+}
+
+// summarizeSystem computes aggregate metrics from system snapshots
+func summarizeSystem(snapshots []*SystemMetrics) *SystemSummary {
+	if len(snapshots) == 0 {
 		return nil
 	}
 
 	summary := &SystemSummary{}
-	count := float64(len(mm.systemSnapshots))
+	count := float64(len(snapshots))
 
-	for _, snapshot := range mm.systemSnapshots {
+	for _, snapshot := range snapshots {
 		summary.AvgCPUUsagePercent += snapshot.CPUUsagePercent
 		summary.AvgMemoryUsageMB += snapshot.UsedMemoryMB
 		summary.AvgMemoryUsagePercent += snapshot.MemoryUsagePercent
@@ -315,11 +539,26 @@ func (mm *MonitoringManager) saveReport(report *MonitoringReport) error {
 		return err
 	}
 
-	if err := os.WriteFile(filename, data, 0644); err != nil {
+	if mm.config.Compress {
+		filename += ".gz"
+		if err := writeGzip(filename, data); err != nil {
+			return err
+		}
+	} else if err := os.WriteFile(filename, data, 0644); err != nil {
 		return err
 	}
 
 	fmt.Printf("\n📊 Monitoring report saved: %s\n", filename)
+
+	if mm.config.ExportExposition {
+		var exposition bytes.Buffer
+		report.WriteExposition(&exposition)
+		promFile := filepath.Join(mm.config.OutputDir, fmt.Sprintf("monitoring_%s.prom", timestamp))
+		if err := os.WriteFile(promFile, exposition.Bytes(), 0644); err != nil {
+			return err
+		}
+		fmt.Printf("📊 Prometheus exposition saved: %s\n", promFile)
+	}
 	return nil
 }
 
@@ -329,15 +568,24 @@ func (mm *MonitoringManager) PrintSummary(report *MonitoringReport) {
 	fmt.Println("📊 MONITORING SUMMARY")
 	fmt.Println(strings.Repeat("=", 100))
 
-	fmt.Printf("\n⏱️  Test Duration: %s\n", report.TestInfo.Duration)
+	fmt.Printf("\n🆔 Run ID: %s\n", report.TestInfo.RunID)
+	fmt.Printf("⏱️  Test Duration: %s\n", report.TestInfo.Duration)
 	fmt.Printf("📅 Start: %s\n", report.TestInfo.StartTime.Format("2006-01-02 15:04:05"))
 	fmt.Printf("📅 End:   %s\n", report.TestInfo.EndTime.Format("2006-01-02 15:04:05"))
+	for _, reason := range report.InsufficientData {
+		fmt.Printf("⚠️  Insufficient data: %s\n", reason)
+	}
 
-	// Prometheus summary
-	if report.PrometheusAvailable && report.PrometheusDiff != nil {
-		fmt.Println("\n🔍 Prometheus Metrics:")
+	// Prometheus summary per target
+	for _, target := range mm.prometheusTargets {
+		targetReport := report.PrometheusTargets[target.name]
+		if targetReport == nil || !targetReport.Available || targetReport.Diff == nil {
+			continue
+		}
+
+		fmt.Printf("\n🔍 Prometheus Metrics [%s]:\n", target.name)
 		fmt.Println("   " + strings.Repeat("-", 80))
-		diff := report.PrometheusDiff
+		diff := targetReport.Diff
 		fmt.Printf("   HTTP Requests:      %.0f total (%.2f req/s)\n",
 			diff.HTTPRequestsIncrease, diff.HTTPRequestsPerSecond)
 		fmt.Printf("   Error Rate:         %.2f%%\n", diff.HTTPErrorRatePercent)
@@ -345,6 +593,9 @@ func (mm *MonitoringManager) PrintSummary(report *MonitoringReport) {
 		fmt.Printf("   Avg Memory:         %.2f MB\n", diff.AvgMemoryUsageMB)
 		fmt.Printf("   Peak Goroutines:    %.0f\n", diff.PeakGoroutines)
 		fmt.Printf("   Avg Connections:    %.0f\n", diff.AvgActiveConnections)
+		if diff.CounterResets > 0 {
+			fmt.Printf("   Counter Resets:     %d (target restarted during the run)\n", diff.CounterResets)
+		}
 
 		if diff.EndMetrics != nil {
 			fmt.Printf("\n   Response Times (End of Test):\n")
@@ -367,6 +618,16 @@ func (mm *MonitoringManager) PrintSummary(report *MonitoringReport) {
 		fmt.Printf("   TCP Connections:    Avg: %.0f | Peak: %d\n",
 			summary.AvgTCPConnections, summary.PeakTCPConnections)
 		fmt.Printf("   Load Average (1m):  %.2f\n", summary.AvgLoadAverage1Min)
+
+		if cooldown := report.CooldownSummary; cooldown != nil {
+			fmt.Printf("\n   Cooldown (%s after load stopped):\n", report.TestInfo.Cooldown)
+			fmt.Printf("   CPU Usage:          Avg: %.2f%% | Peak: %.2f%%\n",
+				cooldown.AvgCPUUsagePercent, cooldown.PeakCPUUsagePercent)
+			fmt.Printf("   Memory Usage:       Avg: %.2fMB (%.2f%%) | Peak: %.2fMB\n",
+				cooldown.AvgMemoryUsageMB, cooldown.AvgMemoryUsagePercent, cooldown.PeakMemoryUsageMB)
+			fmt.Printf("   TCP Connections:    Avg: %.0f | Peak: %d\n",
+				cooldown.AvgTCPConnections, cooldown.PeakTCPConnections)
+		}
 	}
 
 	// Insights
@@ -382,3 +643,21 @@ func (mm *MonitoringManager) PrintSummary(report *MonitoringReport) {
 
 	fmt.Println("\n" + strings.Repeat("=", 100))
 }
+
+// writeGzip writes data gzip-compressed to filename
+func writeGzip(filename string, data []byte) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	zw := gzip.NewWriter(f)
+	if _, err := zw.Write(data); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
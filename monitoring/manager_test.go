@@ -0,0 +1,412 @@
+package monitoring
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// metricsServer serves a Prometheus endpoint whose request counter grows on
+// every scrape
+func metricsServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	var scrapes int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt64(&scrapes, 1)
+		fmt.Fprintf(w, "# TYPE http_requests_total counter\nhttp_requests_total{code=\"200\"} %d\n", n*100)
+		fmt.Fprintf(w, "# TYPE go_goroutines gauge\ngo_goroutines %d\n", 10+n%5)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// snapshotCounts reads how many scrapes each target holds
+func snapshotCounts(mm *MonitoringManager) map[string]int {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	counts := make(map[string]int, len(mm.prometheusTargets))
+	for _, target := range mm.prometheusTargets {
+		counts[target.name] = len(target.snapshots)
+	}
+	return counts
+}
+
+// waitForSnapshots waits until every target has at least n scrapes
+func waitForSnapshots(t *testing.T, mm *MonitoringManager, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		ready := true
+		for _, count := range snapshotCounts(mm) {
+			ready = ready && count >= n
+		}
+		if ready {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("collector took fewer than %d scrapes per target: %v", n, snapshotCounts(mm))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestStartStopUnderLoad runs the collector at a 1ms interval while readers
+// poll Latest and a cooldown runs, then checks StopMonitoring waited for the
+// collector: no snapshot is appended after it returns. Run with -race.
+func TestStartStopUnderLoad(t *testing.T) {
+	app, exporter := metricsServer(t), metricsServer(t)
+
+	for run := 0; run < 5; run++ {
+		mm := NewMonitoringManager(MonitoringManagerConfig{
+			EnablePrometheus:  true,
+			PrometheusURL:     app.URL,
+			PrometheusTargets: []PrometheusTarget{{Name: "exporter", URL: exporter.URL}},
+			ScrapeInterval:    time.Millisecond,
+			ScrapeJitter:      0.5,
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		if err := mm.StartMonitoring(ctx); err != nil {
+			t.Fatalf("StartMonitoring: %v", err)
+		}
+
+		stopReaders := make(chan struct{})
+		var readers sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			readers.Add(1)
+			go func() {
+				defer readers.Done()
+				for {
+					select {
+					case <-stopReaders:
+						return
+					default:
+						system, targets := mm.Latest()
+						_ = system
+						for _, metrics := range targets {
+							_ = metrics.HTTPRequestsTotal
+						}
+						time.Sleep(50 * time.Microsecond)
+					}
+				}
+			}()
+		}
+
+		waitForSnapshots(t, mm, 4)
+		mm.Cooldown(ctx, 5*time.Millisecond)
+		report, err := mm.StopMonitoring(context.Background())
+		if err != nil {
+			t.Fatalf("StopMonitoring: %v", err)
+		}
+		stopped := snapshotCounts(mm)
+
+		time.Sleep(10 * time.Millisecond)
+		close(stopReaders)
+		readers.Wait()
+		cancel()
+
+		if after := snapshotCounts(mm); fmt.Sprint(after) != fmt.Sprint(stopped) {
+			t.Fatalf("run %d: snapshots grew after StopMonitoring returned: %v -> %v", run, stopped, after)
+		}
+		for _, name := range []string{"default", "exporter"} {
+			target := report.PrometheusTargets[name]
+			if target == nil || !target.Available || target.Diff == nil {
+				t.Fatalf("run %d: target %q has no diff: %+v", run, name, target)
+			}
+			if got := len(target.Snapshots); got != stopped[name] {
+				t.Errorf("run %d: target %q reports %d snapshots, collected %d", run, name, got, stopped[name])
+			}
+			if target.Diff.HTTPRequestsIncrease <= 0 {
+				t.Errorf("run %d: target %q request increase %v, want > 0", run, name, target.Diff.HTTPRequestsIncrease)
+			}
+		}
+	}
+}
+
+// TestStopAfterContextCancel stops monitoring once the collector has already
+// exited on cancellation, and twice, without blocking
+func TestStopAfterContextCancel(t *testing.T) {
+	mm := NewMonitoringManager(MonitoringManagerConfig{
+		EnablePrometheus: true,
+		PrometheusURL:    metricsServer(t).URL,
+		ScrapeInterval:   time.Millisecond,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := mm.StartMonitoring(ctx); err != nil {
+		t.Fatalf("StartMonitoring: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 2; i++ {
+			if _, err := mm.StopMonitoring(context.Background()); err != nil {
+				t.Errorf("StopMonitoring #%d: %v", i+1, err)
+			}
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("StopMonitoring blocked after the context was cancelled")
+	}
+}
+
+// counterServer serves a request counter that grows by requests and an error
+// counter that grows by errors on every scrape
+func counterServer(t *testing.T, requests, errors int64) *httptest.Server {
+	t.Helper()
+	var scrapes int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt64(&scrapes, 1)
+		fmt.Fprintf(w, "http_requests_total %d\nhttp_errors_total %d\n", n*requests, n*errors)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// TestMultipleTargetsReportedSeparately scrapes a healthy app and a failing
+// proxy, and checks each gets its own diff and the error insight names the proxy
+func TestMultipleTargetsReportedSeparately(t *testing.T) {
+	app, proxy := counterServer(t, 100, 0), counterServer(t, 10, 5)
+	mm := NewMonitoringManager(MonitoringManagerConfig{
+		EnablePrometheus: true,
+		PrometheusTargets: []PrometheusTarget{
+			{Name: "app", URL: app.URL},
+			{Name: "proxy", URL: proxy.URL},
+		},
+		ScrapeInterval: time.Millisecond,
+	})
+
+	if err := mm.StartMonitoring(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	waitForSnapshots(t, mm, 3)
+	report, err := mm.StopMonitoring(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.PrometheusTargets) != 2 {
+		t.Fatalf("report has targets %v, want app and proxy", report.PrometheusTargets)
+	}
+	appReport, proxyReport := report.PrometheusTargets["app"], report.PrometheusTargets["proxy"]
+	if appReport == nil || proxyReport == nil || !appReport.Available || !proxyReport.Available {
+		t.Fatalf("both targets should be scraped: app %+v, proxy %+v", appReport, proxyReport)
+	}
+	if appReport.URL != app.URL || proxyReport.URL != proxy.URL {
+		t.Errorf("target URLs mixed up: app %s, proxy %s", appReport.URL, proxyReport.URL)
+	}
+
+	// Each diff is computed from its own target's counters
+	appScrapes := float64(len(appReport.Snapshots) - 1)
+	if got, want := appReport.Diff.HTTPRequestsIncrease, appScrapes*100; got != want {
+		t.Errorf("app request increase = %v, want %v", got, want)
+	}
+	if appReport.Diff.HTTPErrorRatePercent != 0 {
+		t.Errorf("app error rate = %v%%, want 0", appReport.Diff.HTTPErrorRatePercent)
+	}
+	if got := proxyReport.Diff.HTTPErrorRatePercent; got != 50 {
+		t.Errorf("proxy error rate = %v%%, want 50", got)
+	}
+
+	errorInsights := report.FilterInsights(SeverityCritical, CategoryErrors)
+	if len(errorInsights) != 1 || errorInsights[0].Target != "proxy" {
+		t.Errorf("error insights = %+v, want one for the proxy", errorInsights)
+	}
+}
+
+// TestCooldownSummarizedSeparately feeds system snapshots taken under load
+// and after a cooldown began, and checks the cooldown summary only covers
+// the recovery while the run summary only covers the load
+func TestCooldownSummarizedSeparately(t *testing.T) {
+	mm := NewMonitoringManager(MonitoringManagerConfig{})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	mm.startTime = start.Add(-time.Minute)
+	for i := 0; i < 3; i++ {
+		mm.addSystemSnapshot(&SystemMetrics{Timestamp: start.Add(-time.Duration(3-i) * time.Second), CPUUsagePercent: 90, TCPEstablished: 800})
+	}
+	// A cancelled context ends the cooldown wait at once
+	mm.Cooldown(ctx, time.Hour)
+	if mm.loadEndTime.IsZero() || time.Since(start) > time.Second {
+		t.Fatalf("cooldown started at %s and waited %s, want it started and cut short", mm.loadEndTime, time.Since(start))
+	}
+	for i := 1; i <= 2; i++ {
+		mm.addSystemSnapshot(&SystemMetrics{Timestamp: mm.loadEndTime.Add(time.Duration(i) * time.Second), CPUUsagePercent: 10, TCPEstablished: 50})
+	}
+	mm.endTime = mm.loadEndTime.Add(2 * time.Second)
+
+	report := mm.generateReport()
+	if report.TestInfo.Cooldown != "2s" || !report.TestInfo.LoadEndTime.Equal(mm.loadEndTime) {
+		t.Errorf("cooldown %q from %s, want 2s from %s", report.TestInfo.Cooldown, report.TestInfo.LoadEndTime, mm.loadEndTime)
+	}
+	if report.CooldownSummary == nil {
+		t.Fatal("no cooldown summary")
+	}
+	if got := report.CooldownSummary; got.AvgCPUUsagePercent != 10 || got.PeakTCPConnections != 50 {
+		t.Errorf("cooldown avg CPU %.1f%%, peak connections %d; want 10%% and 50", got.AvgCPUUsagePercent, got.PeakTCPConnections)
+	}
+	if got := report.SystemSummary; got.AvgCPUUsagePercent != 90 || got.AvgTCPConnections != 800 || got.PeakTCPConnections != 800 {
+		t.Errorf("run avg CPU %.1f%%, avg/peak connections %.0f/%d; want the load's 90%% and 800/800",
+			got.AvgCPUUsagePercent, got.AvgTCPConnections, got.PeakTCPConnections)
+	}
+	if len(report.SystemSnapshots) != 5 {
+		t.Errorf("report holds %d system snapshots, want all 5", len(report.SystemSnapshots))
+	}
+}
+
+// TestCooldownKeepsCollecting runs the collector through a real cooldown and
+// checks scrapes keep arriving after the load ended, for the whole cooldown
+func TestCooldownKeepsCollecting(t *testing.T) {
+	const interval, cooldown = 5 * time.Millisecond, 100 * time.Millisecond
+	mm := NewMonitoringManager(MonitoringManagerConfig{
+		EnablePrometheus: true,
+		PrometheusURL:    metricsServer(t).URL,
+		ScrapeInterval:   interval,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := mm.StartMonitoring(ctx); err != nil {
+		t.Fatal(err)
+	}
+	waitForSnapshots(t, mm, 3)
+
+	start := time.Now()
+	mm.Cooldown(ctx, cooldown)
+	if waited := time.Since(start); waited < cooldown {
+		t.Errorf("cooldown returned after %s, want %s", waited, cooldown)
+	}
+
+	// Count before StopMonitoring, so its final scrape can't be mistaken
+	// for the collector's
+	mm.mu.Lock()
+	var during []time.Time
+	for _, snapshot := range mm.prometheusTargets[0].snapshots {
+		if !snapshot.Timestamp.Before(mm.loadEndTime) {
+			during = append(during, snapshot.Timestamp)
+		}
+	}
+	loadEnd := mm.loadEndTime
+	mm.mu.Unlock()
+	if _, err := mm.StopMonitoring(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(during) < 5 {
+		t.Fatalf("collector scraped %d times during a %s cooldown at %s, want it to keep collecting", len(during), cooldown, interval)
+	}
+	if last := during[len(during)-1].Sub(loadEnd); last < cooldown/2 {
+		t.Errorf("last cooldown scrape %s after load ended, want collection through the %s cooldown", last, cooldown)
+	}
+}
+
+func TestNoCooldownSummaryWithoutCooldown(t *testing.T) {
+	mm := NewMonitoringManager(MonitoringManagerConfig{})
+	now := time.Now()
+	mm.addSystemSnapshot(&SystemMetrics{Timestamp: now, CPUUsagePercent: 50})
+	mm.addSystemSnapshot(&SystemMetrics{Timestamp: now.Add(time.Second), CPUUsagePercent: 50})
+
+	if report := mm.generateReport(); report.CooldownSummary != nil || report.TestInfo.Cooldown != "" {
+		t.Errorf("cooldown summary %+v (%q) without a cooldown", report.CooldownSummary, report.TestInfo.Cooldown)
+	}
+}
+
+func TestJitteredInterval(t *testing.T) {
+	const base = 10 * time.Second
+	tests := []struct {
+		jitter, r float64
+		want      time.Duration
+	}{
+		{jitter: 0, r: 0.9, want: base},
+		{jitter: 0.2, r: 0, want: 8 * time.Second},
+		{jitter: 0.2, r: 0.5, want: base},
+		{jitter: 0.2, r: 0.75, want: 11 * time.Second},
+		// Jitter of 1 or more would allow a zero interval
+		{jitter: 3, r: 0, want: 100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := jitteredInterval(base, tt.jitter, tt.r); got != tt.want {
+			t.Errorf("jitteredInterval(%s, %v, %v) = %s, want %s", base, tt.jitter, tt.r, got, tt.want)
+		}
+	}
+}
+
+// TestScrapeJitterVariesIntervals runs the collector with jitter and checks
+// the gaps between snapshot timestamps vary but stay within the jitter band
+func TestScrapeJitterVariesIntervals(t *testing.T) {
+	const interval, jitter, scrapes = 20 * time.Millisecond, 0.5, 12
+	mm := NewMonitoringManager(MonitoringManagerConfig{
+		EnablePrometheus: true,
+		PrometheusURL:    metricsServer(t).URL,
+		ScrapeInterval:   interval,
+		ScrapeJitter:     jitter,
+	})
+	if err := mm.StartMonitoring(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	waitForSnapshots(t, mm, scrapes)
+	if _, err := mm.StopMonitoring(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mm.mu.Lock()
+	snapshots := mm.prometheusTargets[0].snapshots
+	mm.mu.Unlock()
+	low, high := time.Duration(float64(interval)*(1-jitter)), time.Duration(float64(interval)*(1+jitter))
+	shortest, longest := time.Hour, time.Duration(0)
+	// The first and last snapshots are taken on start and stop, outside the
+	// collector's timer
+	for i := 2; i < len(snapshots)-1; i++ {
+		gap := snapshots[i].Timestamp.Sub(snapshots[i-1].Timestamp)
+		if gap < shortest {
+			shortest = gap
+		}
+		if gap > longest {
+			longest = gap
+		}
+	}
+	// Allow for the scrape itself and scheduling delay around the band
+	if shortest < low-5*time.Millisecond || longest > high+10*time.Millisecond {
+		t.Errorf("scrape gaps %s-%s, want them within %s-%s", shortest, longest, low, high)
+	}
+	if longest-shortest < interval/4 {
+		t.Errorf("scrape gaps %s-%s barely vary with %.0f%% jitter", shortest, longest, jitter*100)
+	}
+}
+
+// TestReportInsufficientData checks a target scraped fewer than twice is
+// reported as insufficient data with the reason, instead of a zero diff
+func TestReportInsufficientData(t *testing.T) {
+	mm := NewMonitoringManager(MonitoringManagerConfig{
+		EnablePrometheus:  true,
+		PrometheusTargets: []PrometheusTarget{{Name: "app", URL: "http://127.0.0.1:1"}, {Name: "proxy", URL: "http://127.0.0.1:1"}},
+	})
+	mm.mu.Lock()
+	mm.prometheusTargets[1].snapshots = []*PrometheusMetrics{{Timestamp: time.Now()}}
+	mm.mu.Unlock()
+
+	report := mm.generateReport()
+	if len(report.InsufficientData) != 2 {
+		t.Fatalf("InsufficientData = %q, want one reason per target", report.InsufficientData)
+	}
+	for i, want := range []string{"prometheus [app]: no snapshots collected", "prometheus [proxy]: only 1 snapshot, need 2"} {
+		if !strings.HasPrefix(report.InsufficientData[i], want) {
+			t.Errorf("InsufficientData[%d] = %q, want it to start with %q", i, report.InsufficientData[i], want)
+		}
+	}
+	if app := report.PrometheusTargets["app"]; app == nil || app.Diff != nil {
+		t.Errorf("app report = %+v, want no diff", app)
+	}
+}
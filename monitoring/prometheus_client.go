@@ -2,9 +2,11 @@ package monitoring
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"os"
 	"strings"
@@ -15,6 +17,16 @@ import (
 type PrometheusClient struct {
 	metricsURL string
 	httpClient *http.Client
+	auth       PrometheusAuthConfig
+}
+
+// PrometheusAuthConfig configures authentication for protected metrics endpoints
+type PrometheusAuthConfig struct {
+	BearerToken        string            // Sent as "Authorization: Bearer <token>"
+	BasicAuthUser      string            // Basic auth username (ignored if BearerToken is set)
+	BasicAuthPass      string            // Basic auth password
+	Headers            map[string]string // Extra headers added to every scrape
+	InsecureSkipVerify bool              // Skip TLS verification for self-signed endpoints
 }
 
 // PrometheusMetrics stores snapshot of key metrics
@@ -59,16 +71,30 @@ type MetricsDiff struct {
 	PeakGoroutines        float64 `json:"peak_goroutines"`
 	AvgActiveConnections  float64 `json:"avg_active_connections"`
 
+	// CounterResets counts drops in the request or error counters, i.e.
+	// target restarts; increases then only cover what the restarted
+	// process counted
+	CounterResets int `json:"counter_resets,omitempty"`
+
 	StartMetrics *PrometheusMetrics `json:"start_metrics"`
 	EndMetrics   *PrometheusMetrics `json:"end_metrics"`
 }
 
-func NewPrometheusClient(metricsURL string) *PrometheusClient {
+func NewPrometheusClient(metricsURL string, auth PrometheusAuthConfig) *PrometheusClient {
+	httpClient := &http.Client{
+		Timeout: 10 * time.Second,
+	}
+
+	if auth.InsecureSkipVerify {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		httpClient.Transport = transport
+	}
+
 	return &PrometheusClient{
 		metricsURL: metricsURL,
-		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
-		},
+		httpClient: httpClient,
+		auth:       auth,
 	}
 }
 
@@ -79,6 +105,8 @@ func (pc *PrometheusClient) ScrapeMetrics(ctx context.Context) (*PrometheusMetri
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
+	pc.applyAuth(req)
+
 	resp, err := pc.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to scrape metrics: %w", err)
@@ -107,6 +135,19 @@ func (pc *PrometheusClient) ScrapeMetrics(ctx context.Context) (*PrometheusMetri
 	return metrics, nil
 }
 
+// applyAuth sets configured credentials and custom headers on the request
+func (pc *PrometheusClient) applyAuth(req *http.Request) {
+	for key, value := range pc.auth.Headers {
+		req.Header.Set(key, value)
+	}
+
+	if pc.auth.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+pc.auth.BearerToken)
+	} else if pc.auth.BasicAuthUser != "" {
+		req.SetBasicAuth(pc.auth.BasicAuthUser, pc.auth.BasicAuthPass)
+	}
+}
+
 // parsePrometheusFormat parses Prometheus exposition format
 func (pc *PrometheusClient) parsePrometheusFormat(body string, metrics *PrometheusMetrics) error {
 	lines := strings.Split(body, "\n")
@@ -176,6 +217,14 @@ func (pc *PrometheusClient) parsePrometheusFormat(body string, metrics *Promethe
 
 // CalculateDiff computes the difference between two metric snapshots
 func (pc *PrometheusClient) CalculateDiff(start, end *PrometheusMetrics) *MetricsDiff {
+	return pc.CalculateSeriesDiff([]*PrometheusMetrics{start, end})
+}
+
+// CalculateSeriesDiff computes the difference between the first and last of
+// at least two snapshots, walking every snapshot so counter resets in
+// between are detected and do not produce negative increases
+func (pc *PrometheusClient) CalculateSeriesDiff(snapshots []*PrometheusMetrics) *MetricsDiff {
+	start, end := snapshots[0], snapshots[len(snapshots)-1]
 	duration := end.Timestamp.Sub(start.Timestamp)
 
 	diff := &MetricsDiff{
@@ -187,14 +236,21 @@ func (pc *PrometheusClient) CalculateDiff(start, end *PrometheusMetrics) *Metric
 	}
 
 	// HTTP Metrics
-	diff.HTTPRequestsIncrease = end.HTTPRequestsTotal - start.HTTPRequestsTotal
+	requestsIncrease, requestResets := counterIncrease(snapshots, func(m *PrometheusMetrics) float64 { return m.HTTPRequestsTotal })
+	errorIncrease, errorResets := counterIncrease(snapshots, func(m *PrometheusMetrics) float64 { return m.HTTPErrorsTotal })
+	diff.CounterResets = requestResets
+	if errorResets > requestResets {
+		diff.CounterResets = errorResets
+	}
+
+	diff.HTTPRequestsIncrease = requestsIncrease
 	if duration.Seconds() > 0 {
 		diff.HTTPRequestsPerSecond = diff.HTTPRequestsIncrease / duration.Seconds()
 	}
 
-	errorIncrease := end.HTTPErrorsTotal - start.HTTPErrorsTotal
 	if diff.HTTPRequestsIncrease > 0 {
-		diff.HTTPErrorRatePercent = (errorIncrease / diff.HTTPRequestsIncrease) * 100
+		// Resets seen in only one counter can leave more errors than requests
+		diff.HTTPErrorRatePercent = math.Min(errorIncrease/diff.HTTPRequestsIncrease*100, 100)
 	}
 
 	// System Metrics (averages)
@@ -206,6 +262,25 @@ func (pc *PrometheusClient) CalculateDiff(start, end *PrometheusMetrics) *Metric
 	return diff
 }
 
+// counterIncrease sums the increases of a counter across snapshots. A drop
+// means the target restarted and the counter began again from zero, so the
+// value after the drop counts as the increase for that step, as Prometheus'
+// increase() does.
+func counterIncrease(snapshots []*PrometheusMetrics, value func(*PrometheusMetrics) float64) (float64, int) {
+	var total float64
+	resets := 0
+	for i := 1; i < len(snapshots); i++ {
+		prev, cur := value(snapshots[i-1]), value(snapshots[i])
+		if cur < prev {
+			resets++
+			total += cur
+			continue
+		}
+		total += cur - prev
+	}
+	return total, resets
+}
+
 // MonitorDuringTest continuously monitors metrics during test execution
 func (pc *PrometheusClient) MonitorDuringTest(ctx context.Context, interval time.Duration) ([]*PrometheusMetrics, error) {
 	var snapshots []*PrometheusMetrics
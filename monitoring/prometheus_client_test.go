@@ -0,0 +1,128 @@
+package monitoring
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// authServer serves metrics only to requests passing check
+func authServer(t *testing.T, check func(r *http.Request) bool) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !check(r) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("http_requests_total 42\ngo_goroutines 7\n"))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestScrapeMetricsBearerToken(t *testing.T) {
+	var gotAuth, gotTenant string
+	server := authServer(t, func(r *http.Request) bool {
+		gotAuth = r.Header.Get("Authorization")
+		gotTenant = r.Header.Get("X-Tenant")
+		return gotAuth == "Bearer s3cret"
+	})
+
+	client := NewPrometheusClient(server.URL, PrometheusAuthConfig{
+		BearerToken: "s3cret",
+		Headers:     map[string]string{"X-Tenant": "team-a"},
+	})
+	metrics, err := client.ScrapeMetrics(context.Background())
+	if err != nil {
+		t.Fatalf("ScrapeMetrics: %v", err)
+	}
+	if gotAuth != "Bearer s3cret" {
+		t.Errorf("Authorization = %q, want bearer token", gotAuth)
+	}
+	if gotTenant != "team-a" {
+		t.Errorf("X-Tenant = %q, want custom header", gotTenant)
+	}
+	if metrics.HTTPRequestsTotal != 42 || metrics.GoroutinesCount != 7 {
+		t.Errorf("parsed metrics = %+v", metrics)
+	}
+
+	// Without the token the endpoint refuses the scrape
+	if _, err := NewPrometheusClient(server.URL, PrometheusAuthConfig{}).ScrapeMetrics(context.Background()); err == nil {
+		t.Error("unauthenticated scrape succeeded")
+	}
+}
+
+func TestScrapeMetricsBasicAuth(t *testing.T) {
+	server := authServer(t, func(r *http.Request) bool {
+		user, pass, ok := r.BasicAuth()
+		return ok && user == "prom" && pass == "hunter2"
+	})
+
+	client := NewPrometheusClient(server.URL, PrometheusAuthConfig{BasicAuthUser: "prom", BasicAuthPass: "hunter2"})
+	if _, err := client.ScrapeMetrics(context.Background()); err != nil {
+		t.Fatalf("ScrapeMetrics: %v", err)
+	}
+}
+
+func TestScrapeMetricsInsecureSkipVerify(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("http_requests_total 1\n"))
+	}))
+	defer server.Close()
+
+	if _, err := NewPrometheusClient(server.URL, PrometheusAuthConfig{}).ScrapeMetrics(context.Background()); err == nil {
+		t.Error("scrape of a self-signed endpoint succeeded without InsecureSkipVerify")
+	}
+	client := NewPrometheusClient(server.URL, PrometheusAuthConfig{InsecureSkipVerify: true})
+	if _, err := client.ScrapeMetrics(context.Background()); err != nil {
+		t.Fatalf("ScrapeMetrics with InsecureSkipVerify: %v", err)
+	}
+}
+
+// TestSeriesDiffAcrossRestart diffs scrapes where the target restarts midway
+// and checks the increase counts what both processes served instead of going
+// negative
+func TestSeriesDiffAcrossRestart(t *testing.T) {
+	start := time.Unix(0, 0)
+	scrape := func(second int, requests, errors float64) *PrometheusMetrics {
+		return &PrometheusMetrics{Timestamp: start.Add(time.Duration(second) * time.Second), HTTPRequestsTotal: requests, HTTPErrorsTotal: errors}
+	}
+	// 1000 -> 1600, restart, 0 -> 400 -> 900
+	snapshots := []*PrometheusMetrics{scrape(0, 1000, 10), scrape(10, 1600, 16), scrape(20, 400, 4), scrape(30, 900, 9)}
+
+	client := NewPrometheusClient("http://localhost", PrometheusAuthConfig{})
+	diff := client.CalculateSeriesDiff(snapshots)
+	if diff.HTTPRequestsIncrease != 1500 {
+		t.Errorf("request increase = %v, want 1500", diff.HTTPRequestsIncrease)
+	}
+	if diff.HTTPRequestsPerSecond != 50 {
+		t.Errorf("requests/s = %v, want 50", diff.HTTPRequestsPerSecond)
+	}
+	if diff.HTTPErrorRatePercent != 1 {
+		t.Errorf("error rate = %v%%, want 1%%", diff.HTTPErrorRatePercent)
+	}
+	if diff.CounterResets != 1 {
+		t.Errorf("CounterResets = %d, want 1", diff.CounterResets)
+	}
+
+	// Start and end alone miss the restart and go negative, which is why
+	// reports diff the whole series
+	if naive := client.CalculateDiff(snapshots[0], snapshots[3]); naive.CounterResets != 1 || naive.HTTPRequestsIncrease != 900 {
+		t.Errorf("two-point diff = %v requests, %d resets; want 900 and 1", naive.HTTPRequestsIncrease, naive.CounterResets)
+	}
+}
+
+func TestSeriesDiffWithoutReset(t *testing.T) {
+	start := time.Unix(0, 0)
+	snapshots := []*PrometheusMetrics{
+		{Timestamp: start, HTTPRequestsTotal: 100},
+		{Timestamp: start.Add(time.Second), HTTPRequestsTotal: 150},
+		{Timestamp: start.Add(2 * time.Second), HTTPRequestsTotal: 300},
+	}
+	diff := NewPrometheusClient("http://localhost", PrometheusAuthConfig{}).CalculateSeriesDiff(snapshots)
+	if diff.HTTPRequestsIncrease != 200 || diff.CounterResets != 0 {
+		t.Errorf("increase %v with %d resets, want 200 and none", diff.HTTPRequestsIncrease, diff.CounterResets)
+	}
+}
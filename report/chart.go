@@ -2,19 +2,33 @@ package report
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
+	"html"
 	"time"
 
 	"mail-stress-test/benchmark"
+	"mail-stress-test/config"
+
+	"gopkg.in/yaml.v3"
 )
 
 type ChartGenerator struct {
-	outputDir string
+	sink   OutputSink
+	runID  string
+	config *config.Config // redacted snapshot shown in the report
+}
+
+// NewChartGenerator writes charts into outputDir; use SetSink to ship them elsewhere
+func NewChartGenerator(outputDir, runID string, cfg *config.Config) *ChartGenerator {
+	chartGen := &ChartGenerator{sink: NewLocalSink(outputDir), runID: runID}
+	if cfg != nil {
+		chartGen.config = cfg.Redacted()
+	}
+	return chartGen
 }
 
-func NewChartGenerator(outputDir string) *ChartGenerator {
-	return &ChartGenerator{outputDir: outputDir}
+// SetSink replaces the local output directory with sink
+func (cg *ChartGenerator) SetSink(sink OutputSink) {
+	cg.sink = sink
 }
 
 func (cg *ChartGenerator) GenerateCharts(stressResult *benchmark.StressTestResult, searchResults map[string]*benchmark.SearchBenchmarkResult) error {
@@ -27,7 +41,17 @@ func (cg *ChartGenerator) GenerateCharts(stressResult *benchmark.StressTestResul
 }
 
 func (cg *ChartGenerator) generateHTMLChart(stressResult *benchmark.StressTestResult, searchResults map[string]*benchmark.SearchBenchmarkResult) error {
-	filename := filepath.Join(cg.outputDir, fmt.Sprintf("charts_%s.html", time.Now().Format("20060102_150405")))
+	name := fmt.Sprintf("charts_%s.html", time.Now().Format("20060102_150405"))
+
+	histogramLabels, histogramCounts, histogramCDF, histogramMarkers := buildHistogramSeries(stressResult)
+	timelineLabels, timelineRPS, timelineErrors, timelineP95 := buildTimeSeries(stressResult)
+	timelineConnections, timelineTimeouts, timelineServer := buildFailureModeSeries(stressResult)
+	percentileMarkers := fmt.Sprintf("P50: %s | P95: %s | P99: %s",
+		stressResult.P50ResponseTime, stressResult.P95ResponseTime, stressResult.P99ResponseTime)
+	for _, tail := range stressResult.TailPercentiles {
+		percentileMarkers += fmt.Sprintf(" | P%g: %s", tail.Percentile, tail.Latency)
+	}
+	percentileMarkers += fmt.Sprintf(" | Max: %s", stressResult.MaxResponseTime)
 
 	html := `<!DOCTYPE html>
 <html>
@@ -79,8 +103,10 @@ func (cg *ChartGenerator) generateHTMLChart(stressResult *benchmark.StressTestRe
 </head>
 <body>
     <h1>📊 Mail System Stress Test & Benchmark Report</h1>
-    <p>Generated: ` + time.Now().Format("2006-01-02 15:04:05") + `</p>
+    <p>Run ID: ` + cg.runID + ` | Generated: ` + time.Now().Format("2006-01-02 15:04:05") + `</p>
     
+    ` + slaBanner(stressResult) + `
+    ` + cg.configSection() + `
     <h2>Summary Statistics</h2>
     <div class="stats-grid">
         <div class="stat-card">
@@ -115,6 +141,11 @@ func (cg *ChartGenerator) generateHTMLChart(stressResult *benchmark.StressTestRe
         <h2>Response Time Distribution</h2>
         <canvas id="responseTimeChart"></canvas>
     </div>
+
+    <div class="chart-container">
+        <h2>Throughput &amp; Latency Over Time</h2>
+        <canvas id="timelineChart"></canvas>
+    </div>
     
     <script>
         // Operation Performance Chart
@@ -196,28 +227,107 @@ func (cg *ChartGenerator) generateHTMLChart(stressResult *benchmark.StressTestRe
             }
         });
         
-        // Response Time Distribution Chart
+        // Response Time Distribution Chart (histogram + CDF)
         const responseCtx = document.getElementById('responseTimeChart').getContext('2d');
         new Chart(responseCtx, {
+            data: {
+                labels: [` + histogramLabels + `],
+                datasets: [{
+                    type: 'bar',
+                    label: 'Requests',
+                    data: [` + histogramCounts + `],
+                    backgroundColor: 'rgba(75, 192, 192, 0.6)',
+                    yAxisID: 'y'
+                }, {
+                    type: 'line',
+                    label: 'Cumulative %',
+                    data: [` + histogramCDF + `],
+                    borderColor: 'rgba(153, 102, 255, 1)',
+                    backgroundColor: 'rgba(153, 102, 255, 0.2)',
+                    pointRadius: [` + histogramMarkers + `],
+                    tension: 0.2,
+                    yAxisID: 'y1'
+                }]
+            },
+            options: {
+                responsive: true,
+                maintainAspectRatio: true,
+                scales: {
+                    y: {
+                        beginAtZero: true,
+                        title: { display: true, text: 'Requests' }
+                    },
+                    y1: {
+                        beginAtZero: true,
+                        max: 100,
+                        position: 'right',
+                        grid: { drawOnChartArea: false },
+                        title: { display: true, text: 'Cumulative %' }
+                    }
+                },
+                plugins: {
+                    subtitle: {
+                        display: true,
+                        text: '` + percentileMarkers + `'
+                    }
+                }
+            }
+        });
+
+        // Throughput & Latency Over Time Chart
+        const timelineCtx = document.getElementById('timelineChart').getContext('2d');
+        new Chart(timelineCtx, {
             type: 'line',
             data: {
-                labels: ['Min', 'Average', 'Max'],
+                labels: [` + timelineLabels + `],
                 datasets: [{
-                    label: 'Response Time (ms)',
-                    data: [` + fmt.Sprintf("%d, %d, %d",
-		stressResult.MinResponseTime.Milliseconds(),
-		stressResult.AvgResponseTime.Milliseconds(),
-		stressResult.MaxResponseTime.Milliseconds()) + `],
-                    borderColor: 'rgba(75, 192, 192, 1)',
-                    backgroundColor: 'rgba(75, 192, 192, 0.2)',
-                    fill: true
+                    label: 'Requests/s',
+                    data: [` + timelineRPS + `],
+                    borderColor: 'rgba(54, 162, 235, 1)',
+                    yAxisID: 'y'
+                }, {
+                    label: 'Errors',
+                    data: [` + timelineErrors + `],
+                    borderColor: 'rgba(255, 99, 132, 1)',
+                    yAxisID: 'y'
+                }, {
+                    label: 'Connection errors',
+                    data: [` + timelineConnections + `],
+                    borderColor: 'rgba(153, 102, 255, 1)',
+                    borderDash: [4, 4],
+                    yAxisID: 'y'
+                }, {
+                    label: 'Timeouts',
+                    data: [` + timelineTimeouts + `],
+                    borderColor: 'rgba(201, 203, 207, 1)',
+                    borderDash: [4, 4],
+                    yAxisID: 'y'
+                }, {
+                    label: 'HTTP 5xx',
+                    data: [` + timelineServer + `],
+                    borderColor: 'rgba(139, 0, 0, 1)',
+                    borderDash: [4, 4],
+                    yAxisID: 'y'
+                }, {
+                    label: 'P95 (ms)',
+                    data: [` + timelineP95 + `],
+                    borderColor: 'rgba(255, 159, 64, 1)',
+                    yAxisID: 'y1'
                 }]
             },
             options: {
                 responsive: true,
+                pointRadius: 0,
                 scales: {
                     y: {
-                        beginAtZero: true
+                        beginAtZero: true,
+                        title: { display: true, text: 'Requests' }
+                    },
+                    y1: {
+                        beginAtZero: true,
+                        position: 'right',
+                        grid: { drawOnChartArea: false },
+                        title: { display: true, text: 'P95 (ms)' }
                     }
                 }
             }
@@ -226,5 +336,81 @@ func (cg *ChartGenerator) generateHTMLChart(stressResult *benchmark.StressTestRe
 </body>
 </html>`
 
-	return os.WriteFile(filename, []byte(html), 0644)
+	return cg.sink.Write(name, []byte(html))
+}
+
+// buildTimeSeries renders the per-interval timeline as Chart.js array bodies
+func buildTimeSeries(stressResult *benchmark.StressTestResult) (labels, rps, errors, p95 string) {
+	for _, point := range stressResult.TimeSeries {
+		labels += fmt.Sprintf("'%s', ", point.Offset)
+		rps += fmt.Sprintf("%.1f, ", point.RequestsPerSecond)
+		errors += fmt.Sprintf("%d, ", point.Errors)
+		p95 += fmt.Sprintf("%.2f, ", float64(point.P95Duration)/float64(time.Millisecond))
+	}
+	return labels, rps, errors, p95
+}
+
+// buildFailureModeSeries renders the per-interval errors by failure mode as
+// Chart.js array bodies
+func buildFailureModeSeries(stressResult *benchmark.StressTestResult) (connections, timeouts, server string) {
+	for _, point := range stressResult.TimeSeries {
+		connections += fmt.Sprintf("%d, ", point.ConnectionErrors)
+		timeouts += fmt.Sprintf("%d, ", point.Timeouts)
+		server += fmt.Sprintf("%d, ", point.ServerErrors)
+	}
+	return connections, timeouts, server
+}
+
+// buildHistogramSeries renders the latency histogram as Chart.js array bodies.
+// The CDF point radius is enlarged on the buckets containing P50/P95/P99.
+func buildHistogramSeries(stressResult *benchmark.StressTestResult) (labels, counts, cdf, markers string) {
+	percentiles := []time.Duration{
+		stressResult.P50ResponseTime,
+		stressResult.P95ResponseTime,
+		stressResult.P99ResponseTime,
+	}
+
+	var lower time.Duration
+	for _, bucket := range stressResult.LatencyHistogram {
+		labels += "'" + bucket.Label + "', "
+		counts += fmt.Sprintf("%d, ", bucket.Count)
+		cdf += fmt.Sprintf("%.2f, ", bucket.CumulativePercent)
+
+		radius := 3
+		for _, p := range percentiles {
+			if p >= lower && (bucket.UpperBound == 0 || p < bucket.UpperBound) {
+				radius = 8
+			}
+		}
+		markers += fmt.Sprintf("%d, ", radius)
+		lower = bucket.UpperBound
+	}
+
+	return labels, counts, cdf, markers
+}
+
+// slaBanner renders a prominent PASS/FAIL line for the configured SLA
+func slaBanner(stressResult *benchmark.StressTestResult) string {
+	if stressResult.SLA == nil {
+		return ""
+	}
+	color := "#c62828"
+	if stressResult.SLA.Passed {
+		color = "#2e7d32"
+	}
+	return fmt.Sprintf(`<div class="stat-card" style="border-left: 6px solid %s"><div class="stat-value" style="color: %s">%s</div></div>`,
+		color, color, stressResult.SLA.String())
+}
+
+// configSection renders the redacted configuration as a collapsible block
+func (cg *ChartGenerator) configSection() string {
+	if cg.config == nil {
+		return ""
+	}
+	configYAML, err := yaml.Marshal(cg.config)
+	if err != nil {
+		return ""
+	}
+	return `<details class="chart-container"><summary>Configuration</summary><pre>` +
+		html.EscapeString(string(configYAML)) + `</pre></details>`
 }
@@ -0,0 +1,68 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"mail-stress-test/benchmark"
+)
+
+// readChart returns the single charts_*.html written to dir
+func readChart(t *testing.T, dir string) string {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dir, "charts_*.html"))
+	if err != nil || len(matches) != 1 {
+		t.Fatalf("want one chart in %s, got %v (%v)", dir, matches, err)
+	}
+	data, err := os.ReadFile(matches[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// TestChartHistogramBuckets renders a known sample set and checks the
+// histogram chart carries its bucket labels, counts and CDF
+func TestChartHistogramBuckets(t *testing.T) {
+	ms := time.Millisecond
+	// 3 in 0-1ms, 2 in 1-5ms, 4 in 10-25ms, 1 in 1s+
+	samples := []time.Duration{
+		200 * time.Microsecond, 500 * time.Microsecond, 900 * time.Microsecond,
+		2 * ms, 4 * ms,
+		12 * ms, 15 * ms, 20 * ms, 24 * ms,
+		2 * time.Second,
+	}
+	result := &benchmark.StressTestResult{
+		TotalRequests:    int64(len(samples)),
+		P50ResponseTime:  12 * ms,
+		P95ResponseTime:  2 * time.Second,
+		P99ResponseTime:  2 * time.Second,
+		LatencyHistogram: benchmark.BuildLatencyHistogram(samples),
+		OperationStats:   map[string]*benchmark.OperationStats{},
+	}
+
+	dir := t.TempDir()
+	if err := NewChartGenerator(dir, "run-1", nil).GenerateCharts(result, nil); err != nil {
+		t.Fatal(err)
+	}
+	chart := readChart(t, dir)
+
+	for _, label := range []string{"'0ms-1ms'", "'1ms-5ms'", "'10ms-25ms'", "'1s+'"} {
+		if !strings.Contains(chart, label) {
+			t.Errorf("chart is missing bucket label %s", label)
+		}
+	}
+	if want := "data: [3, 2, 0, 4, 0, 0, 0, 0, 0, 1, ]"; !strings.Contains(chart, want) {
+		t.Errorf("chart is missing bucket counts %q", want)
+	}
+	if want := "data: [30.00, 50.00, 50.00, 90.00, 90.00, 90.00, 90.00, 90.00, 90.00, 100.00, ]"; !strings.Contains(chart, want) {
+		t.Errorf("chart is missing the CDF %q", want)
+	}
+	// P50 marks the 10-25ms bucket, P95/P99 the open-ended one
+	if want := "pointRadius: [3, 3, 3, 8, 3, 3, 3, 3, 3, 8, ]"; !strings.Contains(chart, want) {
+		t.Errorf("chart is missing the percentile markers %q", want)
+	}
+}
@@ -0,0 +1,77 @@
+package report
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+)
+
+// writeJSON writes v as indented JSON to sink under name, or gzip-compressed
+// as name+".gz" when compress is set, and returns where it was stored
+func writeJSON(sink OutputSink, name string, v interface{}, compress bool) (string, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if compress {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(data); err != nil {
+			return "", err
+		}
+		if err := zw.Close(); err != nil {
+			return "", err
+		}
+		name += ".gz"
+		data = buf.Bytes()
+	}
+
+	if err := sink.Write(name, data); err != nil {
+		return "", err
+	}
+	return location(sink, name), nil
+}
+
+// readJSON decodes a JSON file into v, transparently decompressing gzip
+// input regardless of the file extension
+func readJSON(filename string, v interface{}) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+	var r io.Reader = br
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	return json.NewDecoder(r).Decode(v)
+}
+
+// LoadRunReport reads a run report written by GenerateRunReport, compressed or not
+func LoadRunReport(filename string) (*RunReport, error) {
+	var runReport RunReport
+	if err := readJSON(filename, &runReport); err != nil {
+		return nil, err
+	}
+	return &runReport, nil
+}
+
+// LoadReport reads a report written by GenerateReport, compressed or not
+func LoadReport(filename string) (*Report, error) {
+	var report Report
+	if err := readJSON(filename, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
@@ -0,0 +1,73 @@
+package report
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"mail-stress-test/benchmark"
+	"mail-stress-test/config"
+)
+
+// TestCompressedRunReportRoundTrip writes the run report with and without
+// compression and checks the loader reads both back unchanged
+func TestCompressedRunReportRoundTrip(t *testing.T) {
+	for _, compress := range []bool{true, false} {
+		dir := t.TempDir()
+		cfg := config.DefaultConfig()
+		cfg.Report.CompressReports = compress
+
+		path, err := NewReporter(dir, "run-1", cfg).GenerateRunReport(&RunReport{
+			StressTestResult: &benchmark.StressTestResult{TotalRequests: 1200, SuccessRequests: 1190},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := filepath.Join(dir, "run_report_run-1.json")
+		if compress {
+			want += ".gz"
+		}
+		if path != want {
+			t.Errorf("compress=%v: written to %s, want %s", compress, path, want)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gzipped := bytes.HasPrefix(data, []byte{0x1f, 0x8b}); gzipped != compress {
+			t.Errorf("compress=%v: file gzip-compressed = %v", compress, gzipped)
+		}
+
+		got, err := LoadRunReport(path)
+		if err != nil {
+			t.Fatalf("compress=%v: load: %v", compress, err)
+		}
+		if got.RunID != "run-1" || got.StressTestResult == nil || got.StressTestResult.SuccessRequests != 1190 {
+			t.Errorf("compress=%v: loaded %+v", compress, got)
+		}
+	}
+}
+
+// TestLoadReportIgnoresExtension checks gzip input is detected by content,
+// so a compressed report renamed to .json still loads
+func TestLoadReportIgnoresExtension(t *testing.T) {
+	dir := t.TempDir()
+	path, err := writeJSON(NewLocalSink(dir), "report.json", &Report{RunID: "run-2"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	renamed := filepath.Join(dir, "renamed.json")
+	if err := os.Rename(path, renamed); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := LoadReport(renamed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.RunID != "run-2" {
+		t.Errorf("RunID = %q, want run-2", got.RunID)
+	}
+}
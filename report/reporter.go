@@ -1,36 +1,123 @@
 package report
 
 import (
-	"encoding/json"
+	"bytes"
 	"fmt"
-	"os"
-	"path/filepath"
 	"time"
 
 	"mail-stress-test/benchmark"
+	"mail-stress-test/config"
+	"mail-stress-test/database"
+	"mail-stress-test/monitoring"
+
+	"gopkg.in/yaml.v3"
 )
 
 type Report struct {
+	RunID            string                                      `json:"run_id"`
+	Config           map[string]interface{}                      `json:"config,omitempty"`
 	Timestamp        time.Time                                   `json:"timestamp"`
 	StressTestResult *benchmark.StressTestResult                 `json:"stress_test_result"`
 	SearchBenchmark  map[string]*benchmark.SearchBenchmarkResult `json:"search_benchmark"`
+	SearchComparison *benchmark.ComparisonSummary                `json:"search_comparison,omitempty"`
+}
+
+// RunReport is the canonical artifact of a run: stress, search and monitoring
+// results under one object with shared run metadata
+type RunReport struct {
+	RunID            string                                      `json:"run_id"`
+	StartTime        time.Time                                   `json:"start_time"`
+	EndTime          time.Time                                   `json:"end_time"`
+	Duration         string                                      `json:"duration"`
+	Config           map[string]interface{}                      `json:"config,omitempty"`
+	StressTestResult *benchmark.StressTestResult                 `json:"stress_test_result,omitempty"`
+	SearchBenchmark  map[string]*benchmark.SearchBenchmarkResult `json:"search_benchmark,omitempty"`
+	SearchComparison *benchmark.ComparisonSummary                `json:"search_comparison,omitempty"`
+	Monitoring       *monitoring.MonitoringReport                `json:"monitoring,omitempty"`
+	PathComparison   *benchmark.PathComparison                   `json:"path_comparison,omitempty"`
+
+	// Full-document vs list-view projection latency and payload
+	ProjectionComparison *benchmark.ProjectionComparison `json:"projection_comparison,omitempty"`
+	ThreadAppend         *benchmark.ThreadAppendResult   `json:"thread_append,omitempty"`
+	Compression          *benchmark.CompressionResult    `json:"compression,omitempty"`
+	Validation           *benchmark.ValidationResult     `json:"validation,omitempty"`
+	StreamComparison     *benchmark.StreamComparison     `json:"stream_comparison,omitempty"`
+	TombstoneComparison  *benchmark.TombstoneComparison  `json:"tombstone_comparison,omitempty"`
+	CountComparison      *benchmark.CountComparison      `json:"count_comparison,omitempty"`
+	SortComparison       *benchmark.SortComparison       `json:"sort_comparison,omitempty"`
+	PaginationComparison *benchmark.PaginationComparison `json:"pagination_comparison,omitempty"`
+	Purge                *database.PurgeResult           `json:"purge,omitempty"`
+	ClockOffset          *database.ClockOffset           `json:"clock_offset,omitempty"`
+
+	// Server version/topology and the features skipped because of them
+	ServerCapabilities *database.ServerCapabilities `json:"server_capabilities,omitempty"`
+
+	// Hot/cold split made after seeding
+	Archive *database.ArchiveResult `json:"archive,omitempty"`
+
+	// Mails-per-thread distribution measured after seeding
+	ThreadDistribution *database.ThreadDistribution `json:"thread_distribution,omitempty"`
+
+	// Mails-per-inbox distribution measured before a skewed stress test
+	InboxDistribution *database.InboxDistribution `json:"inbox_distribution,omitempty"`
+
+	// collStats of mails, threads and archive after the run, with index sizes
+	Storage []*database.CollectionStats `json:"storage,omitempty"`
+
+	// Slow and COLLSCAN operations the database profiler caught during the run
+	Profile *database.ProfileReport `json:"profile,omitempty"`
 }
 
 type Reporter struct {
-	outputDir string
+	sink     OutputSink
+	runID    string
+	config   *config.Config // redacted snapshot embedded in every report
+	snapshot map[string]interface{}
+	compress bool
 }
 
-func NewReporter(outputDir string) *Reporter {
-	os.MkdirAll(outputDir, 0755)
-	return &Reporter{outputDir: outputDir}
+// NewReporter writes reports into outputDir; use SetSink to ship them elsewhere
+func NewReporter(outputDir, runID string, cfg *config.Config) *Reporter {
+	reporter := &Reporter{sink: NewLocalSink(outputDir), runID: runID}
+	if cfg != nil {
+		reporter.config = cfg.Redacted()
+		reporter.snapshot = configSnapshot(reporter.config)
+		reporter.compress = cfg.Report.CompressReports
+	}
+	return reporter
+}
+
+// configSnapshot converts cfg to a map through YAML, so the JSON reports use
+// the keys and duration strings of the config file rather than Go field
+// names and nanoseconds
+func configSnapshot(cfg *config.Config) map[string]interface{} {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil
+	}
+	var snapshot map[string]interface{}
+	if err := yaml.Unmarshal(data, &snapshot); err != nil {
+		return nil
+	}
+	return snapshot
+}
+
+// SetSink replaces the local output directory with sink
+func (r *Reporter) SetSink(sink OutputSink) {
+	r.sink = sink
 }
 
 func (r *Reporter) GenerateReport(stressResult *benchmark.StressTestResult, searchResults map[string]*benchmark.SearchBenchmarkResult) error {
 	report := &Report{
+		RunID:            r.runID,
+		Config:           r.snapshot,
 		Timestamp:        time.Now(),
 		StressTestResult: stressResult,
 		SearchBenchmark:  searchResults,
 	}
+	if searchResults != nil {
+		report.SearchComparison = benchmark.SummarizeComparison(searchResults)
+	}
 
 	// Generate JSON report
 	if err := r.generateJSONReport(report); err != nil {
@@ -42,37 +129,64 @@ func (r *Reporter) GenerateReport(stressResult *benchmark.StressTestResult, sear
 		return err
 	}
 
+	// Phase breakdown in collapsed-stack format for flamegraph tools
+	if stressResult != nil && stressResult.LatencyPhases != nil {
+		name := fmt.Sprintf("latency_phases_%s.folded", r.runID)
+		if err := r.sink.Write(name, []byte(stressResult.LatencyPhases.Folded())); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-func (r *Reporter) generateJSONReport(report *Report) error {
-	filename := filepath.Join(r.outputDir, fmt.Sprintf("report_%s.json", time.Now().Format("20060102_150405")))
-
-	data, err := json.MarshalIndent(report, "", "  ")
-	if err != nil {
-		return err
+// GenerateRunReport writes the consolidated run report to run_report_<run_id>.json
+// (.json.gz when compression is enabled) and returns where it was stored
+func (r *Reporter) GenerateRunReport(runReport *RunReport) (string, error) {
+	runReport.RunID = r.runID
+	runReport.Config = r.snapshot
+	runReport.Duration = runReport.EndTime.Sub(runReport.StartTime).String()
+	if runReport.SearchBenchmark != nil && runReport.SearchComparison == nil {
+		runReport.SearchComparison = benchmark.SummarizeComparison(runReport.SearchBenchmark)
 	}
 
-	return os.WriteFile(filename, data, 0644)
+	name := fmt.Sprintf("run_report_%s.json", r.runID)
+	return writeJSON(r.sink, name, runReport, r.compress)
 }
 
-func (r *Reporter) generateTextSummary(report *Report) error {
-	filename := filepath.Join(r.outputDir, fmt.Sprintf("summary_%s.txt", time.Now().Format("20060102_150405")))
+func (r *Reporter) generateJSONReport(report *Report) error {
+	name := fmt.Sprintf("report_%s.json", time.Now().Format("20060102_150405"))
+	_, err := writeJSON(r.sink, name, report, r.compress)
+	return err
+}
 
-	f, err := os.Create(filename)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
+func (r *Reporter) generateTextSummary(report *Report) error {
+	name := fmt.Sprintf("summary_%s.txt", time.Now().Format("20060102_150405"))
+	f := &bytes.Buffer{}
 
 	fmt.Fprintf(f, "=== Mail System Stress Test Report ===\n")
+	fmt.Fprintf(f, "Run ID: %s\n", report.RunID)
 	fmt.Fprintf(f, "Generated: %s\n\n", report.Timestamp.Format(time.RFC3339))
 
+	if r.config != nil {
+		configYAML, err := yaml.Marshal(r.config)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(f, "--- Configuration ---\n%s\n", configYAML)
+	}
+
 	// Stress Test Results
 	st := report.StressTestResult
-	if st != nil {
+	if st != nil && st.TotalRequests == 0 {
+		fmt.Fprintf(f, "--- Stress Test Results ---\n")
+		fmt.Fprintf(f, "Insufficient Data: %s\n\n", st.InsufficientData)
+	} else if st != nil {
 		fmt.Fprintf(f, "--- Stress Test Results ---\n")
 		fmt.Fprintf(f, "Total Requests: %d\n", st.TotalRequests)
+		if st.InsufficientData != "" {
+			fmt.Fprintf(f, "Insufficient Data: %s\n", st.InsufficientData)
+		}
 		fmt.Fprintf(f, "Success Requests: %d\n", st.SuccessRequests)
 		fmt.Fprintf(f, "Failed Requests: %d\n", st.FailedRequests)
 		fmt.Fprintf(f, "Error Rate: %.2f%%\n", st.ErrorRate)
@@ -80,7 +194,56 @@ func (r *Reporter) generateTextSummary(report *Report) error {
 		fmt.Fprintf(f, "Avg Response Time: %s\n", st.AvgResponseTime)
 		fmt.Fprintf(f, "Min Response Time: %s\n", st.MinResponseTime)
 		fmt.Fprintf(f, "Max Response Time: %s\n", st.MaxResponseTime)
-		fmt.Fprintf(f, "Requests/Second: %.2f\n\n", st.RequestsPerSecond)
+		fmt.Fprintf(f, "P50/P95/P99 Response Time: %s / %s / %s\n",
+			st.P50ResponseTime, st.P95ResponseTime, st.P99ResponseTime)
+		if len(st.TailPercentiles) > 0 {
+			fmt.Fprintf(f, "Tail Response Time: %s\n", benchmark.FormatPercentiles(st.TailPercentiles))
+		}
+		fmt.Fprintf(f, "Requests/Second: %.2f\n", st.RequestsPerSecond)
+		if st.SteadyStateReached {
+			fmt.Fprintf(f, "Steady State: reached after %d windows\n", len(st.SteadyStateWindows))
+		}
+		if st.Retries > 0 || st.RetryBudgetDenied > 0 {
+			fmt.Fprintf(f, "Write Retries: %d (%.3f per success, %d refused by retry budget)\n",
+				st.Retries, st.RetriesPerSuccess, st.RetryBudgetDenied)
+		}
+		if st.ShedLoad > 0 {
+			fmt.Fprintf(f, "Shed Load: %d operations dropped at the in-flight cap\n", st.ShedLoad)
+		}
+		if st.SelfMonitor != nil {
+			fmt.Fprintf(f, "Load Generator: %s\n", st.SelfMonitor)
+			if st.SelfMonitor.Warning != "" {
+				fmt.Fprintf(f, "Possible Load Generator Leak: %s\n", st.SelfMonitor.Warning)
+			}
+		}
+		if st.StickyUsers != nil {
+			fmt.Fprintf(f, "Sticky Users: %s\n", st.StickyUsers)
+		}
+		if st.Scenario != nil {
+			fmt.Fprintf(f, "Scenario: %s\n", st.Scenario)
+		}
+		if st.Sessions != nil {
+			fmt.Fprintf(f, "Virtual User Sessions: %s\n", st.Sessions)
+		}
+		if st.Protocol != nil {
+			fmt.Fprintf(f, "HTTP Protocol: %s\n", st.Protocol)
+		}
+		if st.LatencyPhases != nil {
+			fmt.Fprintf(f, "Latency Phases: %s\n", st.LatencyPhases.Overall)
+		}
+		if len(st.FailureModes) > 0 {
+			fmt.Fprintf(f, "Failure Modes Over Time: %s\n", benchmark.FormatFailureModeShifts(st.FailureModes))
+		}
+		for _, slow := range st.SlowRequests {
+			fmt.Fprintf(f, "Slow Request: %s\n", slow)
+		}
+		for _, bucket := range st.InboxSizeLatency {
+			fmt.Fprintf(f, "Inbox Size Latency: %s\n", bucket)
+		}
+		if st.SLA != nil {
+			fmt.Fprintf(f, "%s\n", st.SLA)
+		}
+		fmt.Fprintf(f, "\n")
 
 		fmt.Fprintf(f, "--- Operation Statistics ---\n")
 		for op, stats := range st.OperationStats {
@@ -98,14 +261,33 @@ func (r *Reporter) generateTextSummary(report *Report) error {
 		fmt.Fprintf(f, "\n--- Search Benchmark Results ---\n")
 		for method, result := range report.SearchBenchmark {
 			fmt.Fprintf(f, "\n%s:\n", method)
+			if result.Unsupported {
+				fmt.Fprintf(f, "  Unsupported: %s\n", result.UnsupportedReason)
+				continue
+			}
+			if result.DegradedSetup {
+				for _, failure := range result.SetupFailures {
+					fmt.Fprintf(f, "  Degraded setup: %s\n", failure)
+				}
+			}
+			if result.InsufficientData != "" {
+				fmt.Fprintf(f, "  Insufficient Data: %s\n", result.InsufficientData)
+				continue
+			}
+			if result.ColdFirstQuery > 0 {
+				fmt.Fprintf(f, "  Cold First Query: %s (warm-up: %d queries, avg %s)\n", result.ColdFirstQuery, result.WarmUpQueries, result.WarmUpAvgDuration)
+			}
 			fmt.Fprintf(f, "  Total Queries: %d\n", result.TotalQueries)
 			fmt.Fprintf(f, "  Success: %d\n", result.SuccessQueries)
 			fmt.Fprintf(f, "  Failed: %d\n", result.FailedQueries)
 			fmt.Fprintf(f, "  Avg Duration: %s\n", result.AvgDuration)
 			fmt.Fprintf(f, "  Min Duration: %s\n", result.MinDuration)
 			fmt.Fprintf(f, "  Max Duration: %s\n", result.MaxDuration)
+			if len(result.TailPercentiles) > 0 {
+				fmt.Fprintf(f, "  Tail Duration: %s\n", benchmark.FormatPercentiles(result.TailPercentiles))
+			}
 		}
 	}
 
-	return nil
+	return r.sink.Write(name, f.Bytes())
 }
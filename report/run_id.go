@@ -0,0 +1,49 @@
+package report
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// crockfordAlphabet is the base32 alphabet used by ULIDs
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ValidateRunID rejects run IDs that could escape the output directory once
+// substituted into the report path template or the sink prefix
+func ValidateRunID(runID string) error {
+	if runID == "" || runID == "." || strings.Contains(runID, "..") || strings.ContainsAny(runID, `/\`) {
+		return fmt.Errorf("invalid run ID %q: must be non-empty without path separators or \"..\"", runID)
+	}
+	return nil
+}
+
+// NewRunID returns a short, lexicographically sortable ULID-style identifier:
+// 10 characters of millisecond timestamp followed by 6 random characters
+func NewRunID() string {
+	var sb strings.Builder
+	sb.Grow(16)
+
+	ms := uint64(time.Now().UnixMilli())
+	timePart := make([]byte, 10)
+	for i := len(timePart) - 1; i >= 0; i-- {
+		timePart[i] = crockfordAlphabet[ms&31]
+		ms >>= 5
+	}
+	sb.Write(timePart)
+
+	random := make([]byte, 6)
+	if _, err := rand.Read(random); err != nil {
+		// Fall back to the clock if the system RNG is unavailable
+		nanos := time.Now().UnixNano()
+		for i := range random {
+			random[i] = byte(nanos >> (8 * i))
+		}
+	}
+	for _, b := range random {
+		sb.WriteByte(crockfordAlphabet[b&31])
+	}
+
+	return sb.String()
+}
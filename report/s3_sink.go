@@ -0,0 +1,206 @@
+package report
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Config locates a bucket on AWS S3 or an S3-compatible store (MinIO,
+// Ceph, R2, ...). Objects are addressed path-style: <endpoint>/<bucket>/<key>.
+type S3Config struct {
+	Endpoint     string // e.g. https://s3.eu-west-1.amazonaws.com or http://minio:9000
+	Bucket       string
+	Prefix       string // key prefix for every artifact
+	Region       string // signing region; defaults to us-east-1
+	AccessKey    string
+	SecretKey    string
+	SessionToken string // for temporary credentials
+}
+
+// S3Sink uploads artifacts with PUT Object requests signed with AWS
+// Signature Version 4
+type S3Sink struct {
+	cfg        S3Config
+	endpoint   *url.URL
+	httpClient *http.Client
+	now        func() time.Time
+}
+
+// NewS3Sink validates cfg and creates the sink
+func NewS3Sink(cfg S3Config) (*S3Sink, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, fmt.Errorf("s3 sink needs an endpoint and a bucket")
+	}
+	if cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, fmt.Errorf("s3 sink needs access_key and secret_key")
+	}
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint %q", cfg.Endpoint)
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	return &S3Sink{
+		cfg:        cfg,
+		endpoint:   endpoint,
+		httpClient: &http.Client{Timeout: 60 * time.Second},
+		now:        time.Now,
+	}, nil
+}
+
+// Write uploads data as <prefix>/<name>
+func (s *S3Sink) Write(name string, data []byte) error {
+	objectPath := "/" + path.Join(s.cfg.Bucket, s.cfg.Prefix, name)
+	u := *s.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + objectPath
+
+	req, err := http.NewRequest(http.MethodPut, u.String(), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType(name))
+	s.sign(req, data)
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("s3 upload of %s failed: %w", name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("s3 upload of %s failed: status %d: %s", name, resp.StatusCode, body)
+	}
+	return nil
+}
+
+// Location returns the s3:// URI of name
+func (s *S3Sink) Location(name string) string {
+	return "s3://" + path.Join(s.cfg.Bucket, s.cfg.Prefix, name)
+}
+
+// amzDateFormat is the ISO 8601 basic format of X-Amz-Date
+const amzDateFormat = "20060102T150405Z"
+
+// sign adds the SigV4 Authorization header for an unsigned-query request
+// with payload
+func (s *S3Sink) sign(req *http.Request, payload []byte) {
+	now := s.now().UTC()
+	payloadHash := sha256Hex(payload)
+
+	req.Header.Set("X-Amz-Date", now.Format(amzDateFormat))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.cfg.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.cfg.SessionToken)
+	}
+
+	headers := map[string]string{
+		"host":                 req.URL.Host,
+		"content-type":         req.Header.Get("Content-Type"),
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           now.Format(amzDateFormat),
+	}
+	if s.cfg.SessionToken != "" {
+		headers["x-amz-security-token"] = s.cfg.SessionToken
+	}
+	req.Header.Set("Authorization", s.authorization(req.Method, req.URL, headers, payloadHash, now))
+}
+
+// authorization computes the SigV4 Authorization header value of a request
+// signing headers, keyed by lowercase name with host included
+func (s *S3Sink) authorization(method string, u *url.URL, headers map[string]string, payloadHash string, now time.Time) string {
+	amzDate := now.Format(amzDateFormat)
+	date := now.Format("20060102")
+
+	// Canonical headers: lowercase names, sorted
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		canonicalURI(u.Path),
+		u.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.cfg.Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretKey), date)
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	return fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKey, scope, signedHeaders, signature)
+}
+
+// canonicalURI percent-encodes every byte of p except the unreserved
+// characters and '/', as SigV4 requires for S3 object keys
+func canonicalURI(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// contentType picks the object content type from the artifact extension
+func contentType(name string) string {
+	switch {
+	case strings.HasSuffix(name, ".gz"):
+		return "application/gzip"
+	case strings.HasSuffix(name, ".json"):
+		return "application/json"
+	case strings.HasSuffix(name, ".html"):
+		return "text/html; charset=utf-8"
+	case strings.HasSuffix(name, ".txt"):
+		return "text/plain; charset=utf-8"
+	}
+	return "application/octet-stream"
+}
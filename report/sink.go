@@ -0,0 +1,77 @@
+package report
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"mail-stress-test/config"
+)
+
+// Output sink types accepted by report.sink.type
+const (
+	SinkLocal = "local"
+	SinkS3    = "s3"
+)
+
+// OutputSink stores report artifacts by name, e.g. "run_report_<id>.json"
+type OutputSink interface {
+	Write(name string, data []byte) error
+}
+
+// locator is optionally implemented by sinks that can say where an artifact
+// ended up, for console output
+type locator interface {
+	Location(name string) string
+}
+
+// location returns where sink stored name, or name itself
+func location(sink OutputSink, name string) string {
+	if l, ok := sink.(locator); ok {
+		return l.Location(name)
+	}
+	return name
+}
+
+// LocalSink writes artifacts as files in a directory
+type LocalSink struct {
+	dir string
+}
+
+// NewLocalSink creates a sink writing into dir, created on first write
+func NewLocalSink(dir string) *LocalSink {
+	return &LocalSink{dir: dir}
+}
+
+func (s *LocalSink) Write(name string, data []byte) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(s.Location(name), data, 0644)
+}
+
+// Location returns the file path of name
+func (s *LocalSink) Location(name string) string {
+	return filepath.Join(s.dir, name)
+}
+
+// NewOutputSink builds the configured sink for a run: local files in runDir
+// by default, or objects under <prefix>/<runID>/ in an S3-compatible bucket
+func NewOutputSink(cfg config.SinkConfig, runDir, runID string) (OutputSink, error) {
+	switch cfg.Type {
+	case "", SinkLocal:
+		return NewLocalSink(runDir), nil
+	case SinkS3:
+		return NewS3Sink(S3Config{
+			Endpoint:     cfg.Endpoint,
+			Bucket:       cfg.Bucket,
+			Prefix:       path.Join(cfg.Prefix, runID),
+			Region:       cfg.Region,
+			AccessKey:    cfg.AccessKey,
+			SecretKey:    cfg.SecretKey,
+			SessionToken: cfg.SessionToken,
+		})
+	}
+	return nil, fmt.Errorf("unknown report sink type %q (expected %q or %q)", cfg.Type, SinkLocal, SinkS3)
+}
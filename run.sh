@@ -114,7 +114,7 @@ all_cmd() {
 
 open_report() {
   local latest
-  latest=$(ls -t "$REPORT_DIR"/charts_*.html 2>/dev/null | head -n 1 || true)
+  latest=$(ls -t "$REPORT_DIR"/charts_*.html "$REPORT_DIR"/*/charts_*.html 2>/dev/null | head -n 1 || true)
   if [[ -z "${latest:-}" ]]; then
     echo "No chart HTML found in $REPORT_DIR. Run a test with charts enabled."
     exit 1
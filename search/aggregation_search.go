@@ -2,6 +2,7 @@ package search
 
 import (
 	"context"
+	"fmt"
 
 	"mail-stress-test/database"
 	"mail-stress-test/models"
@@ -27,7 +28,7 @@ func (s *AggregationSearchStrategy) GetDescription() string {
 }
 
 func (s *AggregationSearchStrategy) SetupDatabase(ctx context.Context, db *database.MongoDB) error {
-	collection := db.Database.Collection("mails")
+	collection := db.Mails()
 
 	// Create indexes to support aggregation
 	indexModels := []mongo.IndexModel{
@@ -37,22 +38,25 @@ func (s *AggregationSearchStrategy) SetupDatabase(ctx context.Context, db *datab
 		},
 	}
 
-	_, err := collection.Indexes().CreateMany(ctx, indexModels)
-	return err
+	return createIndexes(ctx, collection.Indexes(), indexModels)
+}
+
+// CheckCapabilities requires $regexMatch, used for relevance scoring (4.2+)
+func (s *AggregationSearchStrategy) CheckCapabilities(caps *database.ServerCapabilities) error {
+	if !caps.AtLeast(4, 2) {
+		return fmt.Errorf("$regexMatch requires MongoDB 4.2+, server is %s", caps.Version)
+	}
+	return nil
 }
 
 func (s *AggregationSearchStrategy) SearchMails(ctx context.Context, db *database.MongoDB, req *models.SearchMailsRequest) ([]*models.Mail, error) {
-	collection := db.Database.Collection("mails")
+	collection := db.Mails()
 
 	pipeline := []bson.M{
 		{
-			"$match": bson.M{
-				"userId": req.UserID,
-				"$or": []bson.M{
-					{"subject": bson.M{"$regex": req.SearchTerm, "$options": "i"}},
-					{"content": bson.M{"$regex": req.SearchTerm, "$options": "i"}},
-				},
-			},
+			"$match": AddScopeFilter(BaseFilter(req), req.Scope,
+				bson.M{"$regex": req.SearchTerm, "$options": "i"},
+				bson.M{"$regex": req.SearchTerm, "$options": "i"}),
 		},
 		{
 			"$addFields": bson.M{
@@ -84,19 +88,17 @@ func (s *AggregationSearchStrategy) SearchMails(ctx context.Context, db *databas
 				},
 			},
 		},
-		{
-			"$sort": bson.M{
-				"relevanceScore": -1,
-				"createdAt":      -1,
-			},
-		},
+	}
+	if sort := SortOrder(req.SortBy, bson.D{{Key: "relevanceScore", Value: -1}, {Key: "createdAt", Value: -1}}); sort != nil {
+		pipeline = append(pipeline, bson.M{"$sort": sort})
 	}
 
 	if req.Limit > 0 {
 		pipeline = append(pipeline, bson.M{"$limit": req.Limit})
 	}
 
-	cursor, err := collection.Aggregate(ctx, pipeline)
+	cursor, err := collection.Aggregate(ctx, pipeline,
+		options.Aggregate().SetComment(db.QueryComment("search:"+s.GetName())))
 	if err != nil {
 		return nil, err
 	}
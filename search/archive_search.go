@@ -0,0 +1,133 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"mail-stress-test/database"
+	"mail-stress-test/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ArchiveSearcher is optionally implemented by strategies whose results span
+// the archive collection as well as the hot mails collection
+type ArchiveSearcher interface {
+	SearchesArchive() bool
+}
+
+// ArchiveUnionStrategy runs the regex search against the hot mails
+// collection and the archive collection concurrently and merges both result
+// lists by recency, as a hot/cold split mail store must
+type ArchiveUnionStrategy struct{}
+
+func NewArchiveUnionStrategy() *ArchiveUnionStrategy {
+	return &ArchiveUnionStrategy{}
+}
+
+func (s *ArchiveUnionStrategy) GetName() string {
+	return "archive_union"
+}
+
+func (s *ArchiveUnionStrategy) GetDescription() string {
+	return "Regex search on hot and archive collections in parallel, merged by createdAt - measures the cross-collection union cost"
+}
+
+func (s *ArchiveUnionStrategy) SearchesArchive() bool {
+	return true
+}
+
+func (s *ArchiveUnionStrategy) SetupDatabase(ctx context.Context, db *database.MongoDB) error {
+	if db.ArchiveCollection == "" {
+		return fmt.Errorf("%w: no archive collection configured", ErrSetupMissing)
+	}
+
+	indexModels := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}},
+			Options: options.Index().SetName("mail_userid_created_idx"),
+		},
+	}
+	if err := createIndexes(ctx, db.Mails().Indexes(), indexModels); err != nil {
+		return err
+	}
+	return createIndexes(ctx, db.Archive().Indexes(), indexModels)
+}
+
+func (s *ArchiveUnionStrategy) SearchMails(ctx context.Context, db *database.MongoDB, req *models.SearchMailsRequest) ([]*models.Mail, error) {
+	if db.ArchiveCollection == "" {
+		return nil, fmt.Errorf("%w: no archive collection configured", ErrSetupMissing)
+	}
+
+	filter := AddScopeFilter(BaseFilter(req), req.Scope,
+		bson.M{"$regex": req.SearchTerm, "$options": "i"},
+		bson.M{"$regex": req.SearchTerm, "$options": "i"})
+
+	// Each side returns at most limit mails, enough for the merged page
+	opts := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: -1}}).
+		SetComment(db.QueryComment("search:" + s.GetName()))
+	if req.Limit > 0 {
+		opts.SetLimit(int64(req.Limit))
+	}
+
+	var hot, cold []*models.Mail
+	var hotErr, coldErr error
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		hot, hotErr = findMails(ctx, db.Mails(), filter, opts)
+	}()
+	go func() {
+		defer wg.Done()
+		cold, coldErr = findMails(ctx, db.Archive(), filter, opts)
+	}()
+	wg.Wait()
+
+	if hotErr != nil {
+		return nil, hotErr
+	}
+	if coldErr != nil {
+		return nil, fmt.Errorf("archive search failed: %w", coldErr)
+	}
+	return mergeByRecency(hot, cold, req.Limit), nil
+}
+
+// findMails runs a find and decodes every result
+func findMails(ctx context.Context, collection *mongo.Collection, filter bson.M, opts *options.FindOptions) ([]*models.Mail, error) {
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var mails []*models.Mail
+	if err := cursor.All(ctx, &mails); err != nil {
+		return nil, err
+	}
+	return mails, nil
+}
+
+// mergeByRecency merges two lists sorted newest first into one list of at
+// most limit mails (0 = unlimited). On equal timestamps hot mails go first.
+func mergeByRecency(hot, cold []*models.Mail, limit int) []*models.Mail {
+	merged := make([]*models.Mail, 0, len(hot)+len(cold))
+	i, j := 0, 0
+	for i < len(hot) || j < len(cold) {
+		if limit > 0 && len(merged) == limit {
+			break
+		}
+		if j == len(cold) || (i < len(hot) && !hot[i].CreatedAt.Before(cold[j].CreatedAt)) {
+			merged = append(merged, hot[i])
+			i++
+		} else {
+			merged = append(merged, cold[j])
+			j++
+		}
+	}
+	return merged
+}
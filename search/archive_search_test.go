@@ -0,0 +1,99 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"mail-stress-test/database"
+	"mail-stress-test/internal/mongotest"
+	"mail-stress-test/models"
+)
+
+// TestMergeByRecency interleaves hot and cold mails newest first, keeps hot
+// mails first on equal timestamps and stops at the limit
+func TestMergeByRecency(t *testing.T) {
+	at := func(subject string, minute int) *models.Mail {
+		return &models.Mail{Subject: subject, CreatedAt: time.Unix(0, 0).Add(time.Duration(minute) * time.Minute)}
+	}
+	hot := []*models.Mail{at("hot-9", 9), at("hot-5", 5), at("hot-3", 3)}
+	cold := []*models.Mail{at("cold-7", 7), at("cold-5", 5), at("cold-1", 1)}
+
+	for _, tt := range []struct {
+		limit int
+		want  []string
+	}{
+		{0, []string{"hot-9", "cold-7", "hot-5", "cold-5", "hot-3", "cold-1"}},
+		{3, []string{"hot-9", "cold-7", "hot-5"}},
+	} {
+		merged := mergeByRecency(hot, cold, tt.limit)
+		if got := subjects(merged); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("limit %d: merged = %v, want %v", tt.limit, got, tt.want)
+		}
+	}
+	if got := subjects(mergeByRecency(nil, cold, 0)); fmt.Sprint(got) != "[cold-7 cold-5 cold-1]" {
+		t.Errorf("archive only: merged = %v", got)
+	}
+}
+
+func subjects(mails []*models.Mail) []string {
+	out := make([]string, len(mails))
+	for i, mail := range mails {
+		out[i] = mail.Subject
+	}
+	return out
+}
+
+// TestArchiveUnionIntegration seeds matching mails a minute apart, moves the
+// oldest half into the archive and checks the union search returns hits
+// from both collections, newest first
+func TestArchiveUnionIntegration(t *testing.T) {
+	mdb := mongotest.Database(t)
+	db := &database.MongoDB{
+		Client:            mdb.Client(),
+		Database:          mdb,
+		MailsCollection:   database.DefaultMailsCollection,
+		ThreadsCollection: database.DefaultThreadsCollection,
+	}
+	db.SetArchiveCollection("mails_archive")
+	ctx := context.Background()
+
+	start := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+	var docs []interface{}
+	for i := 0; i < 6; i++ {
+		docs = append(docs,
+			models.Mail{UserID: "user-1", Subject: fmt.Sprintf("Invoice %d", i), CreatedAt: start.Add(time.Duration(i) * time.Minute)},
+			models.Mail{UserID: "user-1", Subject: fmt.Sprintf("Lunch %d", i), CreatedAt: start.Add(time.Duration(i) * time.Minute)})
+	}
+	if _, err := db.Mails().InsertMany(ctx, docs); err != nil {
+		t.Fatal(err)
+	}
+	moved, err := db.ArchiveOldestMails(ctx, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved.Moved != 6 || moved.Remaining != 6 {
+		t.Fatalf("archive moved %d and left %d, want 6 and 6", moved.Moved, moved.Remaining)
+	}
+
+	strategy := NewArchiveUnionStrategy()
+	if err := strategy.SetupDatabase(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+	mails, err := strategy.SearchMails(ctx, db, &models.SearchMailsRequest{UserID: "user-1", SearchTerm: "invoice", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "[Invoice 5 Invoice 4 Invoice 3 Invoice 2 Invoice 1 Invoice 0]"
+	if got := fmt.Sprint(subjects(mails)); got != want {
+		t.Fatalf("union search = %s, want %s", got, want)
+	}
+	// Invoices 0-2 are only in the archive
+	for _, mail := range mails[3:] {
+		n, err := db.Mails().CountDocuments(ctx, map[string]interface{}{"_id": mail.ID})
+		if err != nil || n != 0 {
+			t.Errorf("%q is still in the hot collection (err %v), want it archived", mail.Subject, err)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"mail-stress-test/database"
+	"mail-stress-test/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultRecencyHalfLife is the age at which a mail's score is halved
+const DefaultRecencyHalfLife = 7 * 24 * time.Hour
+
+// Text index weights: a subject match counts more than a content match
+const (
+	hybridSubjectWeight = 3
+	hybridContentWeight = 1
+)
+
+// HybridSearchStrategy ranks $text matches by text score decayed by age, so
+// among equally relevant mails the newer one wins
+type HybridSearchStrategy struct {
+	halfLife time.Duration
+}
+
+// NewHybridSearchStrategy creates the strategy; halfLife <= 0 uses DefaultRecencyHalfLife
+func NewHybridSearchStrategy(halfLife time.Duration) *HybridSearchStrategy {
+	if halfLife <= 0 {
+		halfLife = DefaultRecencyHalfLife
+	}
+	return &HybridSearchStrategy{halfLife: halfLife}
+}
+
+func (s *HybridSearchStrategy) GetName() string {
+	return "hybrid"
+}
+
+func (s *HybridSearchStrategy) GetDescription() string {
+	return fmt.Sprintf("Weighted $text score (subject x%d) with recency decay (half-life %s)", hybridSubjectWeight, s.halfLife)
+}
+
+func (s *HybridSearchStrategy) SetupDatabase(ctx context.Context, db *database.MongoDB) error {
+	collection := db.Mails()
+
+	// A collection can only have one text index; replace any existing one
+	if err := dropTextIndexes(ctx, collection.Indexes()); err != nil {
+		return err
+	}
+
+	indexModel := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "subject", Value: "text"},
+			{Key: "content", Value: "text"},
+		},
+		Options: options.Index().
+			SetName("mail_text_weighted_index").
+			SetWeights(bson.M{"subject": hybridSubjectWeight, "content": hybridContentWeight}),
+	}
+
+	return createIndexes(ctx, collection.Indexes(), []mongo.IndexModel{indexModel})
+}
+
+func (s *HybridSearchStrategy) SearchMails(ctx context.Context, db *database.MongoDB, req *models.SearchMailsRequest) ([]*models.Mail, error) {
+	collection := db.Mails()
+
+	match := BaseFilter(req)
+	match["$text"] = bson.M{"$search": req.SearchTerm}
+	match, err := addTextScope(match, req)
+	if err != nil {
+		return nil, err
+	}
+
+	pipeline := []bson.M{
+		{
+			"$match": match,
+		},
+		{
+			"$addFields": bson.M{
+				"textScore": bson.M{"$meta": "textScore"},
+				"ageMs":     bson.M{"$max": []interface{}{0, bson.M{"$subtract": []interface{}{time.Now(), "$createdAt"}}}},
+			},
+		},
+		{
+			// score = textScore * 0.5^(age / halfLife)
+			"$addFields": bson.M{
+				"hybridScore": bson.M{
+					"$multiply": []interface{}{
+						"$textScore",
+						bson.M{"$pow": []interface{}{0.5, bson.M{"$divide": []interface{}{"$ageMs", s.halfLife.Milliseconds()}}}},
+					},
+				},
+			},
+		},
+	}
+	if sort := SortOrder(req.SortBy, bson.D{{Key: "hybridScore", Value: -1}, {Key: "createdAt", Value: -1}}); sort != nil {
+		pipeline = append(pipeline, bson.M{"$sort": sort})
+	}
+
+	if req.Limit > 0 {
+		pipeline = append(pipeline, bson.M{"$limit": req.Limit})
+	}
+
+	cursor, err := collection.Aggregate(ctx, pipeline,
+		options.Aggregate().SetComment(db.QueryComment("search:"+s.GetName())))
+	if err != nil {
+		if isTextIndexMissing(err) {
+			return nil, fmt.Errorf("%w: text index required for hybrid ranking (run strategy setup first): %v", ErrSetupMissing, err)
+		}
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var mails []*models.Mail
+	if err := cursor.All(ctx, &mails); err != nil {
+		return nil, err
+	}
+
+	return mails, nil
+}
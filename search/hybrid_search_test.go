@@ -0,0 +1,124 @@
+package search
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"mail-stress-test/database"
+	"mail-stress-test/internal/mongotest"
+	"mail-stress-test/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// TestSetupFailsWhenTextIndexDropFails lists an existing text index whose
+// drop is rejected and checks both text strategies report the failure
+// instead of going on to create a second text index
+func TestSetupFailsWhenTextIndexDropFails(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	strategies := []SearchStrategy{NewTextSearchStrategy(), NewHybridSearchStrategy(0)}
+
+	for _, strategy := range strategies {
+		mt.Run(strategy.GetName(), func(mt *mtest.T) {
+			mt.AddMockResponses(
+				mtest.CreateCursorResponse(0, mt.DB.Name()+"."+database.DefaultMailsCollection, mtest.FirstBatch,
+					bson.D{{Key: "name", Value: "_id_"}, {Key: "key", Value: bson.D{{Key: "_id", Value: 1}}}},
+					bson.D{{Key: "name", Value: "old_text_index"},
+						{Key: "key", Value: bson.D{{Key: "_fts", Value: "text"}, {Key: "_ftsx", Value: 1}}}}),
+				mtest.CreateCommandErrorResponse(mtest.CommandError{
+					Code:    13,
+					Name:    "Unauthorized",
+					Message: "not authorized to drop indexes",
+				}),
+			)
+
+			if err := strategy.SetupDatabase(context.Background(), newMockDB(mt)); err == nil {
+				t.Fatal("setup succeeded although the old text index could not be dropped")
+			}
+			drop := sentCommand(mt, "dropIndexes")
+			if index := drop.Lookup("index").StringValue(); index != "old_text_index" {
+				t.Errorf("dropped index %q, want old_text_index", index)
+			}
+			for _, event := range mt.GetAllStartedEvents() {
+				if event.CommandName == "createIndexes" {
+					t.Error("created a text index after the drop failed")
+				}
+			}
+		})
+	}
+}
+
+// setupHybrid returns a database with the hybrid strategy's text index
+// holding mails
+func setupHybrid(t *testing.T, strategy *HybridSearchStrategy, mails ...models.Mail) *database.MongoDB {
+	t.Helper()
+	mdb := mongotest.Database(t)
+	db := &database.MongoDB{
+		Client:            mdb.Client(),
+		Database:          mdb,
+		MailsCollection:   database.DefaultMailsCollection,
+		ThreadsCollection: database.DefaultThreadsCollection,
+	}
+	ctx := context.Background()
+	if err := strategy.SetupDatabase(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+	docs := make([]interface{}, len(mails))
+	for i := range mails {
+		docs[i] = mails[i]
+	}
+	if _, err := db.Mails().InsertMany(ctx, docs); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// searchSubjects returns the subjects of the hybrid results for term in rank order
+func searchSubjects(t *testing.T, strategy *HybridSearchStrategy, db *database.MongoDB, term string) []string {
+	t.Helper()
+	mails, err := strategy.SearchMails(context.Background(), db,
+		&models.SearchMailsRequest{UserID: "user-1", SearchTerm: term, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	subjects := make([]string, len(mails))
+	for i, mail := range mails {
+		subjects[i] = mail.Subject
+	}
+	return subjects
+}
+
+// TestHybridRankingPrefersNewerAtEqualRelevance stores two mails with the
+// same text a month apart and checks the newer one ranks first
+func TestHybridRankingPrefersNewerAtEqualRelevance(t *testing.T) {
+	strategy := NewHybridSearchStrategy(0)
+	now := time.Now()
+	db := setupHybrid(t, strategy,
+		models.Mail{UserID: "user-1", Subject: "invoice old", Content: "quarterly invoice", CreatedAt: now.Add(-30 * 24 * time.Hour)},
+		models.Mail{UserID: "user-1", Subject: "invoice new", Content: "quarterly invoice", CreatedAt: now.Add(-time.Hour)},
+	)
+
+	got := searchSubjects(t, strategy, db, "invoice")
+	if len(got) != 2 || got[0] != "invoice new" {
+		t.Errorf("ranking = %q, want the newer mail first", got)
+	}
+}
+
+// TestHybridRankingPrefersRelevantAtEqualRecency stores two mails created at
+// the same time, one matching in the weighted subject and one only in the
+// content, and checks the subject match ranks first
+func TestHybridRankingPrefersRelevantAtEqualRecency(t *testing.T) {
+	strategy := NewHybridSearchStrategy(0)
+	createdAt := time.Now().Add(-time.Hour)
+	db := setupHybrid(t, strategy,
+		models.Mail{UserID: "user-1", Subject: "meeting notes", Content: "invoice attached", CreatedAt: createdAt},
+		models.Mail{UserID: "user-1", Subject: "invoice attached", Content: "meeting notes", CreatedAt: createdAt},
+	)
+
+	got := searchSubjects(t, strategy, db, "invoice")
+	if len(got) != 2 || got[0] != "invoice attached" {
+		t.Errorf("ranking = %q, want the subject match first", got)
+	}
+}
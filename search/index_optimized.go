@@ -2,6 +2,7 @@ package search
 
 import (
 	"context"
+	"fmt"
 
 	"mail-stress-test/database"
 	"mail-stress-test/models"
@@ -11,30 +12,66 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// Default collation: English, case-insensitive
+const (
+	DefaultCollationLocale   = "en"
+	DefaultCollationStrength = 2
+)
+
+// SubjectPrefixMatcher is optionally implemented by strategies whose
+// subject-only searches match subjects starting with the term rather than
+// containing it
+type SubjectPrefixMatcher interface {
+	MatchesSubjectPrefix() bool
+}
+
 // IndexOptimizedStrategy uses compound indexes for optimal query performance
-type IndexOptimizedStrategy struct{}
+type IndexOptimizedStrategy struct {
+	collation *options.Collation
+}
 
-func NewIndexOptimizedStrategy() *IndexOptimizedStrategy {
-	return &IndexOptimizedStrategy{}
+// NewIndexOptimizedStrategy creates the strategy with the given collation.
+// Strength 1 ignores case and diacritics, 2 ignores case only, 3 is exact.
+// An empty locale or zero strength falls back to the defaults.
+func NewIndexOptimizedStrategy(locale string, strength int) *IndexOptimizedStrategy {
+	if locale == "" {
+		locale = DefaultCollationLocale
+	}
+	if strength <= 0 {
+		strength = DefaultCollationStrength
+	}
+	return &IndexOptimizedStrategy{
+		collation: &options.Collation{Locale: locale, Strength: strength},
+	}
+}
+
+// subjectIndexName keeps the original name for the default collation so
+// existing databases don't get a duplicate index
+func (s *IndexOptimizedStrategy) subjectIndexName() string {
+	if s.collation.Locale == DefaultCollationLocale && s.collation.Strength == DefaultCollationStrength {
+		return "mail_optimized_subject_idx"
+	}
+	return fmt.Sprintf("mail_optimized_subject_%s_s%d_idx", s.collation.Locale, s.collation.Strength)
 }
 
 func (s *IndexOptimizedStrategy) GetName() string {
 	return "index_optimized"
 }
 
+func (s *IndexOptimizedStrategy) MatchesSubjectPrefix() bool {
+	return true
+}
+
 func (s *IndexOptimizedStrategy) GetDescription() string {
-	return "Compound Index on userId + subject/content with case-insensitive collation - best performance for exact/prefix matches"
+	return fmt.Sprintf("Compound Index on userId + subject/content with collation (locale=%s, strength=%d) - best performance for exact/prefix matches",
+		s.collation.Locale, s.collation.Strength)
 }
 
 func (s *IndexOptimizedStrategy) SetupDatabase(ctx context.Context, db *database.MongoDB) error {
-	collection := db.Database.Collection("mails")
-
-	// Create compound indexes with collation for case-insensitive search
-	collation := &options.Collation{
-		Locale:   "en",
-		Strength: 2, // Case-insensitive
-	}
+	collection := db.Mails()
 
+	// Create compound indexes with the configured collation; queries must use
+	// the same collation for the index to be selected
 	indexModels := []mongo.IndexModel{
 		{
 			Keys: bson.D{
@@ -43,8 +80,8 @@ func (s *IndexOptimizedStrategy) SetupDatabase(ctx context.Context, db *database
 				{Key: "createdAt", Value: -1},
 			},
 			Options: options.Index().
-				SetName("mail_optimized_subject_idx").
-				SetCollation(collation),
+				SetName(s.subjectIndexName()).
+				SetCollation(s.collation),
 		},
 		{
 			Keys: bson.D{
@@ -56,30 +93,31 @@ func (s *IndexOptimizedStrategy) SetupDatabase(ctx context.Context, db *database
 		},
 	}
 
-	_, err := collection.Indexes().CreateMany(ctx, indexModels)
-	return err
+	return createIndexes(ctx, collection.Indexes(), indexModels)
 }
 
 func (s *IndexOptimizedStrategy) SearchMails(ctx context.Context, db *database.MongoDB, req *models.SearchMailsRequest) ([]*models.Mail, error) {
-	collection := db.Database.Collection("mails")
-
-	// Use regex with anchored pattern for better index utilization
-	filter := bson.M{
-		"userId": req.UserID,
-		"$or": []bson.M{
-			{"subject": bson.M{"$regex": "^.*" + req.SearchTerm, "$options": "i"}},
-			{"content": bson.M{"$regex": req.SearchTerm, "$options": "i"}},
-		},
-	}
-
-	collation := &options.Collation{
-		Locale:   "en",
-		Strength: 2,
+	collection := db.Mails()
+
+	// Subject-only searches match by prefix as a range on the collated
+	// subject index, so the collation decides case (and accent) sensitivity.
+	// A $regex would ignore the collation and could only use the index
+	// case-sensitively. Searching both fields keeps the substring match the
+	// other strategies use, so their results stay comparable.
+	subjectCond := bson.M{"$regex": req.SearchTerm, "$options": "i"}
+	if req.Scope == models.SearchScopeSubject {
+		subjectCond = subjectPrefix(req.SearchTerm)
 	}
+	filter := AddScopeFilter(BaseFilter(req), req.Scope,
+		subjectCond,
+		bson.M{"$regex": req.SearchTerm, "$options": "i"})
 
 	opts := options.Find().
-		SetSort(bson.D{{Key: "createdAt", Value: -1}}).
-		SetCollation(collation)
+		SetCollation(s.collation).
+		SetComment(db.QueryComment("search:" + s.GetName()))
+	if sort := SortOrder(req.SortBy, nil); sort != nil {
+		opts.SetSort(sort)
+	}
 
 	if req.Limit > 0 {
 		opts.SetLimit(int64(req.Limit))
@@ -98,3 +136,10 @@ func (s *IndexOptimizedStrategy) SearchMails(ctx context.Context, db *database.M
 
 	return mails, nil
 }
+
+// subjectPrefix matches subjects starting with prefix. U+FFFF sorts after
+// every character in collation order, so the range covers all extensions
+// of the prefix.
+func subjectPrefix(prefix string) bson.M {
+	return bson.M{"$gte": prefix, "$lt": prefix + "\uffff"}
+}
@@ -0,0 +1,94 @@
+package search
+
+import (
+	"context"
+	"testing"
+
+	"mail-stress-test/database"
+	"mail-stress-test/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// newMockDB returns a MongoDB over mt's mock deployment; tests queue the
+// server's replies with mt.AddMockResponses
+func newMockDB(mt *mtest.T) *database.MongoDB {
+	return &database.MongoDB{
+		Client:            mt.Client,
+		Database:          mt.DB,
+		MailsCollection:   database.DefaultMailsCollection,
+		ThreadsCollection: database.DefaultThreadsCollection,
+	}
+}
+
+// emptyCursor is the reply to a find or aggregate that matched nothing
+func emptyCursor(mt *mtest.T) bson.D {
+	return mtest.CreateCursorResponse(0, mt.DB.Name()+"."+database.DefaultMailsCollection, mtest.FirstBatch)
+}
+
+// sentCommand returns the body of the first started command named name
+func sentCommand(mt *mtest.T, name string) bson.Raw {
+	for _, event := range mt.GetAllStartedEvents() {
+		if event.CommandName == name {
+			return event.Command
+		}
+	}
+	mt.Fatalf("no %s command was sent", name)
+	return nil
+}
+
+// collationOf decodes a collation document
+func collationOf(mt *mtest.T, raw bson.RawValue) (locale string, strength int32) {
+	doc, ok := raw.DocumentOK()
+	if !ok {
+		mt.Fatalf("no collation document: %v", raw)
+	}
+	return doc.Lookup("locale").StringValue(), doc.Lookup("strength").Int32()
+}
+
+// TestIndexOptimizedCollation configures a Vietnamese, accent-insensitive
+// collation and checks both the subject index and the query use it
+func TestIndexOptimizedCollation(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("configured collation", func(mt *mtest.T) {
+		strategy := NewIndexOptimizedStrategy("vi", 1)
+		db := newMockDB(mt)
+
+		mt.AddMockResponses(mtest.CreateSuccessResponse(), emptyCursor(mt))
+		if err := strategy.SetupDatabase(context.Background(), db); err != nil {
+			t.Fatal(err)
+		}
+		req := &models.SearchMailsRequest{UserID: "user-1", SearchTerm: "Hóa đơn", Limit: 10}
+		if _, err := strategy.SearchMails(context.Background(), db, req); err != nil {
+			t.Fatal(err)
+		}
+
+		indexes, _ := sentCommand(mt, "createIndexes").Lookup("indexes").Array().Values()
+		subjectIndex := indexes[0].Document()
+		if name := subjectIndex.Lookup("name").StringValue(); name != "mail_optimized_subject_vi_s1_idx" {
+			t.Errorf("subject index name = %q, want one naming the collation", name)
+		}
+		if locale, strength := collationOf(mt, subjectIndex.Lookup("collation")); locale != "vi" || strength != 1 {
+			t.Errorf("index collation = %s/%d, want vi/1", locale, strength)
+		}
+		if locale, strength := collationOf(mt, sentCommand(mt, "find").Lookup("collation")); locale != "vi" || strength != 1 {
+			t.Errorf("query collation = %s/%d, want vi/1", locale, strength)
+		}
+	})
+
+	mt.Run("defaults", func(mt *mtest.T) {
+		strategy := NewIndexOptimizedStrategy("", 0)
+		mt.AddMockResponses(emptyCursor(mt))
+		if _, err := strategy.SearchMails(context.Background(), newMockDB(mt), &models.SearchMailsRequest{UserID: "user-1", SearchTerm: "invoice"}); err != nil {
+			t.Fatal(err)
+		}
+		if locale, strength := collationOf(mt, sentCommand(mt, "find").Lookup("collation")); locale != DefaultCollationLocale || strength != DefaultCollationStrength {
+			t.Errorf("default collation = %s/%d, want %s/%d", locale, strength, DefaultCollationLocale, DefaultCollationStrength)
+		}
+		if name := strategy.subjectIndexName(); name != "mail_optimized_subject_idx" {
+			t.Errorf("default subject index name = %q, want the original name", name)
+		}
+	})
+}
@@ -0,0 +1,161 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// IndexFailure describes one index a strategy could not create
+type IndexFailure struct {
+	Name     string `json:"name"`
+	Keys     string `json:"keys"`
+	Code     int32  `json:"code,omitempty"`
+	CodeName string `json:"code_name,omitempty"` // e.g. IndexOptionsConflict
+	Reason   string `json:"reason"`
+}
+
+func (f IndexFailure) String() string {
+	if f.CodeName != "" {
+		return fmt.Sprintf("index %s %s: %s (%s)", f.Name, f.Keys, f.Reason, f.CodeName)
+	}
+	return fmt.Sprintf("index %s %s: %s", f.Name, f.Keys, f.Reason)
+}
+
+// IndexSetupError is returned by SetupDatabase when some of a strategy's
+// indexes could not be created. Created lists the ones that did succeed;
+// when it is non-empty the strategy can still run in degraded mode.
+type IndexSetupError struct {
+	Failed  []IndexFailure
+	Created []string
+}
+
+func (e *IndexSetupError) Error() string {
+	parts := make([]string, len(e.Failed))
+	for i, failure := range e.Failed {
+		parts[i] = failure.String()
+	}
+	return fmt.Sprintf("%d of %d indexes failed: %s",
+		len(e.Failed), len(e.Failed)+len(e.Created), strings.Join(parts, "; "))
+}
+
+// Degraded reports whether at least one index was created, so the strategy
+// can run without the failed ones
+func (e *IndexSetupError) Degraded() bool {
+	return len(e.Created) > 0
+}
+
+// indexCreator is the part of mongo.IndexView used for setup
+type indexCreator interface {
+	CreateMany(ctx context.Context, models []mongo.IndexModel, opts ...*options.CreateIndexesOptions) ([]string, error)
+	CreateOne(ctx context.Context, model mongo.IndexModel, opts ...*options.CreateIndexesOptions) (string, error)
+}
+
+// dropTextIndexes drops every text index on the collection; a collection
+// can only have one, so strategies replace any existing one before creating
+// their own
+func dropTextIndexes(ctx context.Context, indexes mongo.IndexView) error {
+	cursor, err := indexes.List(ctx)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var results []bson.M
+	if err := cursor.All(ctx, &results); err != nil {
+		return err
+	}
+
+	for _, idx := range results {
+		name, ok := idx["name"].(string)
+		if !ok || name == "_id_" {
+			continue
+		}
+		if key, ok := idx["key"].(bson.M); ok {
+			if _, hasText := key["_fts"]; hasText {
+				if _, err := indexes.DropOne(ctx, name); err != nil {
+					return fmt.Errorf("drop text index %s: %w", name, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// createIndexes creates models in one round trip. If that fails the indexes
+// are retried one at a time, so the failing ones can be named and the rest
+// are still built; the result is then an *IndexSetupError.
+func createIndexes(ctx context.Context, view indexCreator, models []mongo.IndexModel) error {
+	if _, err := view.CreateMany(ctx, models); err == nil {
+		return nil
+	} else if ctx.Err() != nil {
+		return err
+	}
+
+	setupErr := &IndexSetupError{}
+	for _, model := range models {
+		name, err := view.CreateOne(ctx, model)
+		if err == nil {
+			setupErr.Created = append(setupErr.Created, name)
+			continue
+		}
+		setupErr.Failed = append(setupErr.Failed, newIndexFailure(model, err))
+	}
+
+	if len(setupErr.Failed) == 0 {
+		return nil
+	}
+	return setupErr
+}
+
+// newIndexFailure describes why model could not be created
+func newIndexFailure(model mongo.IndexModel, err error) IndexFailure {
+	failure := IndexFailure{
+		Name:   indexName(model),
+		Keys:   indexKeys(model),
+		Reason: err.Error(),
+	}
+
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) {
+		failure.Code = cmdErr.Code
+		failure.CodeName = cmdErr.Name
+		failure.Reason = cmdErr.Message
+	}
+	return failure
+}
+
+// indexName returns the explicit index name, or the server's default
+// "<field>_<value>" form when none is set
+func indexName(model mongo.IndexModel) string {
+	if model.Options != nil && model.Options.Name != nil {
+		return *model.Options.Name
+	}
+
+	var parts []string
+	switch keys := model.Keys.(type) {
+	case bson.D:
+		for _, elem := range keys {
+			parts = append(parts, fmt.Sprintf("%s_%v", elem.Key, elem.Value))
+		}
+	case bson.M:
+		for key, value := range keys {
+			parts = append(parts, fmt.Sprintf("%s_%v", key, value))
+		}
+	}
+	return strings.Join(parts, "_")
+}
+
+// indexKeys renders the key document as JSON for display
+func indexKeys(model mongo.IndexModel) string {
+	data, err := bson.MarshalExtJSON(model.Keys, false, false)
+	if err != nil {
+		return fmt.Sprintf("%v", model.Keys)
+	}
+	return string(data)
+}
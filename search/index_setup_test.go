@@ -0,0 +1,80 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// indexOptionsConflict is the reply to creating an index whose name or
+// options clash with an existing one
+func indexOptionsConflict() bson.D {
+	return mtest.CreateCommandErrorResponse(mtest.CommandError{
+		Code:    85,
+		Name:    "IndexOptionsConflict",
+		Message: "An existing index has the same name as the requested index",
+	})
+}
+
+// TestSetupNamesFailingIndex fails the batched createIndexes and one of the
+// retried indexes, and checks the failure names that index and its server
+// error while the other is still created
+func TestSetupNamesFailingIndex(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("degraded", func(mt *mtest.T) {
+		mt.AddMockResponses(indexOptionsConflict(), mtest.CreateSuccessResponse(), indexOptionsConflict())
+
+		err := NewRegexSearchStrategy().SetupDatabase(context.Background(), newMockDB(mt))
+		var setupErr *IndexSetupError
+		if !errors.As(err, &setupErr) {
+			mt.Fatalf("SetupDatabase = %v, want an *IndexSetupError", err)
+		}
+		if !setupErr.Degraded() || len(setupErr.Created) != 1 || len(setupErr.Failed) != 1 {
+			mt.Fatalf("created %v, failed %v; want one of each", setupErr.Created, setupErr.Failed)
+		}
+		failure := setupErr.Failed[0]
+		if failure.Name != "mail_userid_content_idx" || failure.Code != 85 || failure.CodeName != "IndexOptionsConflict" {
+			mt.Errorf("failure = %+v, want mail_userid_content_idx with IndexOptionsConflict", failure)
+		}
+		if !strings.Contains(err.Error(), "1 of 2 indexes failed") || !strings.Contains(failure.Keys, `"content":1`) {
+			mt.Errorf("error %q with keys %s", err, failure.Keys)
+		}
+	})
+
+	mt.Run("nothing created", func(mt *mtest.T) {
+		mt.AddMockResponses(indexOptionsConflict(), indexOptionsConflict(), indexOptionsConflict())
+
+		var setupErr *IndexSetupError
+		if err := NewRegexSearchStrategy().SetupDatabase(context.Background(), newMockDB(mt)); !errors.As(err, &setupErr) || setupErr.Degraded() {
+			mt.Errorf("SetupDatabase = %v, want an error that is not degraded", err)
+		}
+	})
+
+	mt.Run("batch succeeds", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+		if err := NewRegexSearchStrategy().SetupDatabase(context.Background(), newMockDB(mt)); err != nil {
+			mt.Errorf("SetupDatabase = %v", err)
+		}
+		if n := len(mt.GetAllStartedEvents()); n != 1 {
+			mt.Errorf("sent %d commands, want the single batch", n)
+		}
+	})
+}
+
+func TestIndexName(t *testing.T) {
+	named := mongo.IndexModel{Keys: bson.D{{Key: "userId", Value: 1}}, Options: options.Index().SetName("owner_idx")}
+	if got := indexName(named); got != "owner_idx" {
+		t.Errorf("indexName = %q, want the explicit name", got)
+	}
+	unnamed := mongo.IndexModel{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}}}
+	if got := indexName(unnamed); got != "userId_1_createdAt_-1" {
+		t.Errorf("indexName = %q, want the server's default form", got)
+	}
+}
@@ -0,0 +1,22 @@
+package search
+
+import (
+	"mail-stress-test/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// BaseFilter returns the predicates every strategy applies before matching
+// the term: the mailbox owner and the optional from:/to: participant
+// filters, which are indexed equality matches (on the to array, equality
+// matches any recipient)
+func BaseFilter(req *models.SearchMailsRequest) bson.M {
+	filter := bson.M{"userId": req.UserID}
+	if req.From != "" {
+		filter["from"] = req.From
+	}
+	if req.To != "" {
+		filter["to"] = req.To
+	}
+	return filter
+}
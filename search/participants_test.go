@@ -0,0 +1,72 @@
+package search
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"mail-stress-test/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestBaseFilter(t *testing.T) {
+	req := &models.SearchMailsRequest{UserID: "user-1", SearchTerm: "invoice"}
+	if filter := BaseFilter(req); len(filter) != 1 || filter["userId"] != "user-1" {
+		t.Errorf("BaseFilter without participants = %v, want only the owner", filter)
+	}
+
+	req.From, req.To = "user-2", "user-3"
+	filter := BaseFilter(req)
+	if filter["userId"] != "user-1" || filter["from"] != "user-2" || filter["to"] != "user-3" {
+		t.Errorf("BaseFilter = %v, want owner, from and to equality matches", filter)
+	}
+	if !req.HasParticipantFilter() {
+		t.Error("HasParticipantFilter() = false with from and to set")
+	}
+}
+
+// TestStrategiesApplyParticipantFilters runs a from:/to: search through
+// every strategy against a mock and checks each sends both filters
+func TestStrategiesApplyParticipantFilters(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	strategies := []SearchStrategy{
+		NewRegexSearchStrategy(),
+		NewIndexOptimizedStrategy("", 0),
+		NewTextSearchStrategy(),
+		NewAggregationSearchStrategy(),
+		NewHybridSearchStrategy(24 * time.Hour),
+	}
+	for _, strategy := range strategies {
+		mt.Run(strategy.GetName(), func(mt *mtest.T) {
+			mt.AddMockResponses(emptyCursor(mt))
+			req := &models.SearchMailsRequest{UserID: "user-1", SearchTerm: "invoice", Limit: 10, From: "user-2", To: "user-3"}
+			if _, err := strategy.SearchMails(context.Background(), newMockDB(mt), req); err != nil {
+				mt.Fatal(err)
+			}
+
+			sent := mt.GetStartedEvent().Command.String()
+			for _, want := range []string{`"userId": "user-1"`, `"from": "user-2"`, `"to": "user-3"`} {
+				if !strings.Contains(sent, want) {
+					mt.Errorf("%s sent %s, want it to contain %s", strategy.GetName(), sent, want)
+				}
+			}
+		})
+	}
+}
+
+// TestParticipantFilterWithoutTerm checks the filters stay equality matches
+// the participant indexes can serve, not regexes
+func TestParticipantFilterWithoutTerm(t *testing.T) {
+	req := &models.SearchMailsRequest{UserID: "user-1", To: "user-3"}
+	filter := AddScopeFilter(BaseFilter(req), models.SearchScopeBoth, bson.M{"$regex": "x"}, bson.M{"$regex": "x"})
+	if _, ok := filter["to"].(string); !ok {
+		t.Errorf("to condition = %#v, want a plain equality", filter["to"])
+	}
+	if _, ok := filter["from"]; ok {
+		t.Error("from filtered although unset")
+	}
+}
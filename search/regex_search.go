@@ -27,7 +27,7 @@ func (s *RegexSearchStrategy) GetDescription() string {
 }
 
 func (s *RegexSearchStrategy) SetupDatabase(ctx context.Context, db *database.MongoDB) error {
-	collection := db.Database.Collection("mails")
+	collection := db.Mails()
 
 	// Create compound index on userId and subject/content for better regex performance
 	indexModels := []mongo.IndexModel{
@@ -41,22 +41,20 @@ func (s *RegexSearchStrategy) SetupDatabase(ctx context.Context, db *database.Mo
 		},
 	}
 
-	_, err := collection.Indexes().CreateMany(ctx, indexModels)
-	return err
+	return createIndexes(ctx, collection.Indexes(), indexModels)
 }
 
 func (s *RegexSearchStrategy) SearchMails(ctx context.Context, db *database.MongoDB, req *models.SearchMailsRequest) ([]*models.Mail, error) {
-	collection := db.Database.Collection("mails")
+	collection := db.Mails()
 
-	filter := bson.M{
-		"userId": req.UserID,
-		"$or": []bson.M{
-			{"subject": bson.M{"$regex": req.SearchTerm, "$options": "i"}},
-			{"content": bson.M{"$regex": req.SearchTerm, "$options": "i"}},
-		},
-	}
+	filter := AddScopeFilter(BaseFilter(req), req.Scope,
+		bson.M{"$regex": req.SearchTerm, "$options": "i"},
+		bson.M{"$regex": req.SearchTerm, "$options": "i"})
 
-	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+	opts := options.Find().SetComment(db.QueryComment("search:" + s.GetName()))
+	if sort := SortOrder(req.SortBy, nil); sort != nil {
+		opts.SetSort(sort)
+	}
 
 	if req.Limit > 0 {
 		opts.SetLimit(int64(req.Limit))
@@ -0,0 +1,55 @@
+package search
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"mail-stress-test/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ValidateScope rejects search scopes other than subject, content or both
+func ValidateScope(scope string) error {
+	switch scope {
+	case models.SearchScopeBoth, models.SearchScopeSubject, models.SearchScopeContent:
+		return nil
+	}
+	return fmt.Errorf("unknown search scope %q (expected %q, %q or empty for both)",
+		scope, models.SearchScopeSubject, models.SearchScopeContent)
+}
+
+// AddScopeFilter adds the per-field match conditions selected by scope to
+// filter: subjectCond on subject, contentCond on content, or an $or of both
+func AddScopeFilter(filter bson.M, scope string, subjectCond, contentCond bson.M) bson.M {
+	switch scope {
+	case models.SearchScopeSubject:
+		filter["subject"] = subjectCond
+	case models.SearchScopeContent:
+		filter["content"] = contentCond
+	default:
+		filter["$or"] = []bson.M{
+			{"subject": subjectCond},
+			{"content": contentCond},
+		}
+	}
+	return filter
+}
+
+// addTextScope narrows a $text filter to one field. $text always searches
+// every field of the text index, so a scoped query additionally requires the
+// term as a whole word in the chosen field. Only single-word terms can be
+// scoped: $text matches any word of a phrase, which no per-field condition
+// reproduces, so multi-word scoped searches return ErrUnsupportedQuery.
+func addTextScope(filter bson.M, req *models.SearchMailsRequest) (bson.M, error) {
+	if req.Scope == models.SearchScopeBoth {
+		return filter, nil
+	}
+	if len(strings.Fields(req.SearchTerm)) != 1 {
+		return nil, fmt.Errorf("%w: %s-scoped text search needs a single-word term, got %q",
+			ErrUnsupportedQuery, req.Scope, req.SearchTerm)
+	}
+	cond := bson.M{"$regex": `\b` + regexp.QuoteMeta(strings.TrimSpace(req.SearchTerm)) + `\b`, "$options": "i"}
+	return AddScopeFilter(filter, req.Scope, cond, cond), nil
+}
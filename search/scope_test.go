@@ -0,0 +1,137 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"mail-stress-test/database"
+	"mail-stress-test/internal/mongotest"
+	"mail-stress-test/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// TestIndexOptimizedSubjectPrefix checks a subject-only condition is a range
+// over the prefix, sent with the collation, rather than a case-insensitive
+// regex the collated index cannot serve, while searching both fields keeps
+// the substring match
+func TestIndexOptimizedSubjectPrefix(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("subject scope", func(mt *mtest.T) {
+		mt.AddMockResponses(emptyCursor(mt))
+		req := &models.SearchMailsRequest{UserID: "user-1", SearchTerm: "Invoice", Scope: models.SearchScopeSubject}
+		if _, err := NewIndexOptimizedStrategy("", 0).SearchMails(context.Background(), newMockDB(mt), req); err != nil {
+			t.Fatal(err)
+		}
+
+		find := sentCommand(mt, "find")
+		subject := find.Lookup("filter", "subject").Document()
+		if _, err := subject.LookupErr("$regex"); err == nil {
+			t.Errorf("subject condition %s is a regex, want a prefix range", subject)
+		}
+		if gte, lt := subject.Lookup("$gte").StringValue(), subject.Lookup("$lt").StringValue(); gte != "Invoice" || lt != "Invoice\uffff" {
+			t.Errorf("subject range = [%q, %q), want [\"Invoice\", \"Invoice\\uffff\")", gte, lt)
+		}
+		if _, err := find.LookupErr("collation"); err != nil {
+			t.Error("prefix range sent without the collation that makes it case-insensitive")
+		}
+	})
+
+	mt.Run("both scope", func(mt *mtest.T) {
+		mt.AddMockResponses(emptyCursor(mt))
+		req := &models.SearchMailsRequest{UserID: "user-1", SearchTerm: "Invoice"}
+		if _, err := NewIndexOptimizedStrategy("", 0).SearchMails(context.Background(), newMockDB(mt), req); err != nil {
+			t.Fatal(err)
+		}
+
+		or, _ := sentCommand(mt, "find").Lookup("filter", "$or").Array().Values()
+		if len(or) != 2 {
+			t.Fatalf("$or = %v, want subject and content branches", or)
+		}
+		subject := or[0].Document().Lookup("subject").Document()
+		if regex, ok := subject.Lookup("$regex").StringValueOK(); !ok || regex != "Invoice" {
+			t.Errorf("subject condition %s, want the substring regex the other strategies use", subject)
+		}
+	})
+}
+
+// TestTextScopeSingleWord checks a scoped $text search requires the term as
+// a whole word in the scoped field, and multi-word terms are unsupported
+func TestTextScopeSingleWord(t *testing.T) {
+	req := &models.SearchMailsRequest{UserID: "user-1", SearchTerm: "c++", Scope: models.SearchScopeSubject}
+	filter, err := addTextScope(bson.M{"$text": bson.M{"$search": req.SearchTerm}}, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cond, ok := filter["subject"].(bson.M)
+	if !ok || cond["$regex"] != `\bc\+\+\b` || cond["$options"] != "i" {
+		t.Errorf("subject condition = %v, want the escaped term as a whole word", filter["subject"])
+	}
+	if _, ok := filter["content"]; ok {
+		t.Error("subject-scoped search also constrains content")
+	}
+
+	req.SearchTerm = "Weekly Report"
+	if _, err := addTextScope(bson.M{}, req); !errors.Is(err, ErrUnsupportedQuery) || !IsUnsupported(err) {
+		t.Errorf("multi-word scoped term: err = %v, want ErrUnsupportedQuery", err)
+	}
+	req.Scope = models.SearchScopeBoth
+	if _, err := addTextScope(bson.M{}, req); err != nil {
+		t.Errorf("unscoped multi-word term: %v", err)
+	}
+}
+
+// TestScopeSeparatesFieldsIntegration stores one mail with the term only in
+// its subject and one with it only in its content, and checks every strategy
+// returns just the subject hit for a subject scope and just the content hit
+// for a content scope
+func TestScopeSeparatesFieldsIntegration(t *testing.T) {
+	mdb := mongotest.Database(t)
+	db := &database.MongoDB{
+		Client:            mdb.Client(),
+		Database:          mdb,
+		MailsCollection:   database.DefaultMailsCollection,
+		ThreadsCollection: database.DefaultThreadsCollection,
+	}
+	ctx := context.Background()
+	subjectHit, contentHit := primitive.NewObjectID(), primitive.NewObjectID()
+	if _, err := db.Mails().InsertMany(ctx, []interface{}{
+		models.Mail{ID: subjectHit, UserID: "user-1", Subject: "Invoice March", Content: "see attached"},
+		models.Mail{ID: contentHit, UserID: "user-1", Subject: "Monthly order", Content: "the invoice is attached"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	strategies := []SearchStrategy{
+		NewRegexSearchStrategy(),
+		NewIndexOptimizedStrategy("", 0),
+		NewTextSearchStrategy(),
+		NewHybridSearchStrategy(0),
+	}
+	for _, strategy := range strategies {
+		if err := strategy.SetupDatabase(ctx, db); err != nil {
+			t.Fatalf("%s setup: %v", strategy.GetName(), err)
+		}
+		for scope, want := range map[string]primitive.ObjectID{
+			models.SearchScopeSubject: subjectHit,
+			models.SearchScopeContent: contentHit,
+		} {
+			mails, err := strategy.SearchMails(ctx, db, &models.SearchMailsRequest{UserID: "user-1", SearchTerm: "invoice", Scope: scope, Limit: 10})
+			if err != nil {
+				t.Fatalf("%s %s scope: %v", strategy.GetName(), scope, err)
+			}
+			for _, mail := range mails {
+				if mail.ID != want {
+					t.Errorf("%s %s scope returned %q, a hit in the other field", strategy.GetName(), scope, mail.Subject)
+				}
+			}
+			if len(mails) == 0 {
+				t.Errorf("%s %s scope returned nothing", strategy.GetName(), scope)
+			}
+		}
+	}
+}
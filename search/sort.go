@@ -0,0 +1,44 @@
+package search
+
+import (
+	"fmt"
+
+	"mail-stress-test/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// DateSort orders mails newest first
+var DateSort = bson.D{{Key: "createdAt", Value: -1}}
+
+// SubjectSort orders mails by subject; with an equality match on userId it
+// can be read in order from the {userId, subject} index
+var SubjectSort = bson.D{{Key: "subject", Value: 1}}
+
+// ValidateSortBy rejects result orders other than the models.SortBy values
+func ValidateSortBy(sortBy string) error {
+	switch sortBy {
+	case models.SortByDefault, models.SortByDate, models.SortBySubject, models.SortByRelevance, models.SortByNone:
+		return nil
+	}
+	return fmt.Errorf("unknown sort %q (expected %q, %q, %q, %q or empty for the default)",
+		sortBy, models.SortByDate, models.SortBySubject, models.SortByRelevance, models.SortByNone)
+}
+
+// SortOrder returns the sort for sortBy. relevance is the query's score
+// order, nil where matches aren't scored; the default and SortByRelevance
+// use it, falling back to DateSort. SortByNone returns nil: no sort at all.
+func SortOrder(sortBy string, relevance bson.D) bson.D {
+	switch sortBy {
+	case models.SortByDate:
+		return DateSort
+	case models.SortBySubject:
+		return SubjectSort
+	case models.SortByNone:
+		return nil
+	}
+	if relevance != nil {
+		return relevance
+	}
+	return DateSort
+}
@@ -0,0 +1,43 @@
+package search
+
+import (
+	"fmt"
+	"testing"
+
+	"mail-stress-test/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestSortOrder(t *testing.T) {
+	relevance := bson.D{{Key: "score", Value: bson.M{"$meta": "textScore"}}}
+	tests := []struct {
+		sortBy    string
+		relevance bson.D
+		want      bson.D
+	}{
+		{models.SortByDefault, nil, DateSort},
+		{models.SortByDefault, relevance, relevance},
+		{models.SortByRelevance, nil, DateSort},
+		{models.SortByRelevance, relevance, relevance},
+		{models.SortByDate, relevance, DateSort},
+		{models.SortBySubject, relevance, SubjectSort},
+		{models.SortByNone, relevance, nil},
+	}
+	for _, tt := range tests {
+		if got := SortOrder(tt.sortBy, tt.relevance); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("SortOrder(%q, %v) = %v, want %v", tt.sortBy, tt.relevance, got, tt.want)
+		}
+	}
+}
+
+func TestValidateSortBy(t *testing.T) {
+	for _, sortBy := range []string{models.SortByDefault, models.SortByDate, models.SortBySubject, models.SortByRelevance, models.SortByNone} {
+		if err := ValidateSortBy(sortBy); err != nil {
+			t.Errorf("ValidateSortBy(%q) = %v", sortBy, err)
+		}
+	}
+	if err := ValidateSortBy("createdAt"); err == nil {
+		t.Error(`ValidateSortBy("createdAt") accepted`)
+	}
+}
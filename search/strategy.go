@@ -2,6 +2,7 @@ package search
 
 import (
 	"context"
+	"errors"
 
 	"mail-stress-test/database"
 	"mail-stress-test/models"
@@ -21,3 +22,26 @@ type SearchStrategy interface {
 	// GetDescription returns a description of how this strategy works
 	GetDescription() string
 }
+
+// CapabilityChecker is optionally implemented by strategies that need server
+// features beyond a plain find, so they can be skipped on servers lacking them
+type CapabilityChecker interface {
+	// CheckCapabilities returns an error describing the missing feature
+	CheckCapabilities(caps *database.ServerCapabilities) error
+}
+
+// ErrSetupMissing is returned (wrapped) when a strategy cannot run because the
+// database setup it relies on is missing, e.g. no text index exists. The
+// benchmark reports this as unsupported rather than as a query failure.
+var ErrSetupMissing = errors.New("strategy setup missing")
+
+// ErrUnsupportedQuery is returned (wrapped) when a strategy cannot answer a
+// request faithfully, e.g. a scoped $text search for a multi-word term. Like
+// ErrSetupMissing, the benchmark reports it as unsupported.
+var ErrUnsupportedQuery = errors.New("query unsupported by strategy")
+
+// IsUnsupported reports whether err means the strategy cannot run the query
+// at all, as opposed to the query failing
+func IsUnsupported(err error) bool {
+	return errors.Is(err, ErrSetupMissing) || errors.Is(err, ErrUnsupportedQuery)
+}
@@ -2,6 +2,9 @@ package search
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 
 	"mail-stress-test/database"
 	"mail-stress-test/models"
@@ -27,32 +30,13 @@ func (s *TextSearchStrategy) GetDescription() string {
 }
 
 func (s *TextSearchStrategy) SetupDatabase(ctx context.Context, db *database.MongoDB) error {
-	collection := db.Database.Collection("mails")
+	collection := db.Mails()
 
 	// Drop existing text index if any
-	indexes := collection.Indexes()
-	cursor, err := indexes.List(ctx)
-	if err != nil {
-		return err
-	}
-	defer cursor.Close(ctx)
-
-	var results []bson.M
-	if err := cursor.All(ctx, &results); err != nil {
+	if err := dropTextIndexes(ctx, collection.Indexes()); err != nil {
 		return err
 	}
 
-	for _, idx := range results {
-		if name, ok := idx["name"].(string); ok && name != "_id_" {
-			// Drop non-primary indexes to recreate
-			if key, ok := idx["key"].(bson.M); ok {
-				if _, hasText := key["_fts"]; hasText {
-					indexes.DropOne(ctx, name)
-				}
-			}
-		}
-	}
-
 	// Create text index on subject and content
 	indexModel := mongo.IndexModel{
 		Keys: bson.M{
@@ -62,21 +46,25 @@ func (s *TextSearchStrategy) SetupDatabase(ctx context.Context, db *database.Mon
 		Options: options.Index().SetName("mail_text_index"),
 	}
 
-	_, err = collection.Indexes().CreateOne(ctx, indexModel)
-	return err
+	return createIndexes(ctx, collection.Indexes(), []mongo.IndexModel{indexModel})
 }
 
 func (s *TextSearchStrategy) SearchMails(ctx context.Context, db *database.MongoDB, req *models.SearchMailsRequest) ([]*models.Mail, error) {
-	collection := db.Database.Collection("mails")
+	collection := db.Mails()
 
-	filter := bson.M{
-		"userId": req.UserID,
-		"$text":  bson.M{"$search": req.SearchTerm},
+	filter := BaseFilter(req)
+	filter["$text"] = bson.M{"$search": req.SearchTerm}
+	filter, err := addTextScope(filter, req)
+	if err != nil {
+		return nil, err
 	}
 
 	opts := options.Find().
-		SetSort(bson.D{{Key: "score", Value: bson.M{"$meta": "textScore"}}}).
-		SetProjection(bson.M{"score": bson.M{"$meta": "textScore"}})
+		SetProjection(bson.M{"score": bson.M{"$meta": "textScore"}}).
+		SetComment(db.QueryComment("search:" + s.GetName()))
+	if sort := SortOrder(req.SortBy, bson.D{{Key: "score", Value: bson.M{"$meta": "textScore"}}}); sort != nil {
+		opts.SetSort(sort)
+	}
 
 	if req.Limit > 0 {
 		opts.SetLimit(int64(req.Limit))
@@ -84,6 +72,9 @@ func (s *TextSearchStrategy) SearchMails(ctx context.Context, db *database.Mongo
 
 	cursor, err := collection.Find(ctx, filter, opts)
 	if err != nil {
+		if isTextIndexMissing(err) {
+			return nil, fmt.Errorf("%w: text index required for $text query (run strategy setup first): %v", ErrSetupMissing, err)
+		}
 		return nil, err
 	}
 	defer cursor.Close(ctx)
@@ -95,3 +86,16 @@ func (s *TextSearchStrategy) SearchMails(ctx context.Context, db *database.Mongo
 
 	return mails, nil
 }
+
+// indexNotFoundCode is MongoDB's IndexNotFound error code, returned when a
+// $text query runs without a text index
+const indexNotFoundCode = 27
+
+// isTextIndexMissing reports whether err means the collection has no text index
+func isTextIndexMissing(err error) bool {
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) && serverErr.HasErrorCode(indexNotFoundCode) {
+		return true
+	}
+	return strings.Contains(err.Error(), "text index required")
+}
@@ -0,0 +1,44 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"mail-stress-test/models"
+
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// TestTextSearchWithoutTextIndex runs the text strategy against a collection
+// whose $text query fails for lack of a text index
+func TestTextSearchWithoutTextIndex(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	req := &models.SearchMailsRequest{UserID: "user-1", SearchTerm: "invoice"}
+
+	mt.Run("missing index is a setup error", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{
+			Code:    indexNotFoundCode,
+			Name:    "IndexNotFound",
+			Message: "text index required for $text query",
+		}))
+
+		_, err := (&TextSearchStrategy{}).SearchMails(context.Background(), newMockDB(mt), req)
+		if !errors.Is(err, ErrSetupMissing) {
+			t.Fatalf("got %v, want ErrSetupMissing", err)
+		}
+	})
+
+	mt.Run("other errors pass through", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{
+			Code:    2,
+			Name:    "BadValue",
+			Message: "invalid $text query",
+		}))
+
+		_, err := (&TextSearchStrategy{}).SearchMails(context.Background(), newMockDB(mt), req)
+		if err == nil || errors.Is(err, ErrSetupMissing) {
+			t.Fatalf("got %v, want a plain query error", err)
+		}
+	})
+}
@@ -0,0 +1,100 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"mail-stress-test/benchmark"
+	"mail-stress-test/monitoring"
+)
+
+// clearScreen moves the cursor home and clears the terminal
+const clearScreen = "\033[H\033[2J"
+
+// Dashboard redraws the model on a terminal at a fixed interval, sampling
+// the stress test's live counters and the monitoring snapshots
+type Dashboard struct {
+	live    *benchmark.LiveMetrics
+	monitor *monitoring.MonitoringManager // nil when monitoring is disabled
+	out     io.Writer
+	model   *Model
+}
+
+// NewDashboard renders to out; monitor may be nil
+func NewDashboard(live *benchmark.LiveMetrics, monitor *monitoring.MonitoringManager, out io.Writer) *Dashboard {
+	return &Dashboard{live: live, monitor: monitor, out: out, model: NewModel(30)}
+}
+
+// IsTerminal reports whether f is attached to a terminal (character device)
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Start redraws every interval until the returned stop function is called
+// or ctx is cancelled. stop waits for the last redraw, so output printed
+// afterwards is not overwritten.
+func (d *Dashboard) Start(ctx context.Context, interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		d.model.Update(d.frame(time.Now()))
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-done:
+				return
+			case now := <-ticker.C:
+				d.model.Update(d.frame(now))
+				fmt.Fprint(d.out, clearScreen+d.model.View())
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+		wg.Wait()
+	}
+}
+
+// frame samples the live counters and latest monitoring readings
+func (d *Dashboard) frame(now time.Time) Frame {
+	f := Frame{Time: now, Operations: d.live.Snapshot()}
+	if d.monitor == nil {
+		return f
+	}
+
+	system, targets := d.monitor.Latest()
+	if system != nil {
+		f.Resources = append(f.Resources, Resource{
+			Name:       "system",
+			CPUPercent: system.CPUUsagePercent,
+			Memory:     fmt.Sprintf("%.1f%%", system.MemoryUsagePercent),
+		})
+	}
+	names := make([]string, 0, len(targets))
+	for name := range targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		f.Resources = append(f.Resources, Resource{
+			Name:       name,
+			CPUPercent: targets[name].CPUUsagePercent,
+			Memory:     fmt.Sprintf("%.1fMB", targets[name].MemoryUsageMB),
+		})
+	}
+	return f
+}
@@ -0,0 +1,179 @@
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"mail-stress-test/benchmark"
+)
+
+// sparkBlocks are the eight bar heights of a sparkline, lowest first
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// Frame is one sample of the run: cumulative operation counters plus the
+// latest resource readings
+type Frame struct {
+	Time       time.Time
+	Operations map[string]benchmark.LiveOperation
+	Resources  []Resource
+}
+
+// Resource is the latest CPU/memory reading of one monitored target
+type Resource struct {
+	Name       string
+	CPUPercent float64
+	Memory     string // already formatted, e.g. "61.2%" or "120.5MB"
+}
+
+// operationState is the per-interval view of one operation
+type operationState struct {
+	rps     float64
+	avg     time.Duration
+	latency []float64 // per-interval average latency in ms, oldest first
+}
+
+// Model turns consecutive frames into per-interval rates and keeps a short
+// history for sparklines. It does no I/O, so it can be driven with
+// synthetic frames.
+type Model struct {
+	history int
+	start   time.Time
+	prev    *Frame
+
+	rps        float64
+	rpsHistory []float64
+	errorRate  float64 // failed share of the last interval, percent
+	requests   int64
+	errors     int64
+	operations map[string]*operationState
+	resources  []Resource
+}
+
+// NewModel keeps up to history intervals per sparkline
+func NewModel(history int) *Model {
+	if history <= 0 {
+		history = 30
+	}
+	return &Model{history: history, operations: make(map[string]*operationState)}
+}
+
+// Update folds the next frame into the model
+func (m *Model) Update(f Frame) {
+	m.resources = f.Resources
+	if m.prev == nil {
+		m.start = f.Time
+		m.prev = &f
+		m.requests, m.errors = totals(f.Operations)
+		return
+	}
+
+	interval := f.Time.Sub(m.prev.Time).Seconds()
+	if interval <= 0 {
+		return
+	}
+
+	var deltaRequests, deltaErrors int64
+	for name, cur := range f.Operations {
+		prev := m.prev.Operations[name]
+		requests := cur.Requests - prev.Requests
+		deltaRequests += requests
+		deltaErrors += cur.Errors - prev.Errors
+
+		state, ok := m.operations[name]
+		if !ok {
+			state = &operationState{}
+			m.operations[name] = state
+		}
+		state.rps = float64(requests) / interval
+		// An idle interval has no latency; carrying the last average over
+		// would draw a flat line where nothing ran
+		state.avg = 0
+		if requests > 0 {
+			state.avg = (cur.TotalDuration - prev.TotalDuration) / time.Duration(requests)
+		}
+		state.latency = m.push(state.latency, float64(state.avg)/float64(time.Millisecond))
+	}
+
+	m.rps = float64(deltaRequests) / interval
+	m.rpsHistory = m.push(m.rpsHistory, m.rps)
+	m.errorRate = 0
+	if deltaRequests > 0 {
+		m.errorRate = float64(deltaErrors) / float64(deltaRequests) * 100
+	}
+	m.requests, m.errors = totals(f.Operations)
+	m.prev = &f
+}
+
+// push appends v and drops the oldest values beyond the history size
+func (m *Model) push(values []float64, v float64) []float64 {
+	values = append(values, v)
+	if len(values) > m.history {
+		values = values[len(values)-m.history:]
+	}
+	return values
+}
+
+// View renders the dashboard as plain lines
+func (m *Model) View() string {
+	var b strings.Builder
+
+	elapsed := time.Duration(0)
+	if m.prev != nil {
+		elapsed = m.prev.Time.Sub(m.start).Round(time.Second)
+	}
+	fmt.Fprintf(&b, "📊 mail-stress-test live  (elapsed %s)\n\n", elapsed)
+	fmt.Fprintf(&b, "  RPS      %9.1f  %s\n", m.rps, sparkline(m.rpsHistory))
+	fmt.Fprintf(&b, "  Errors   %8.2f%%  (%d of %d total)\n", m.errorRate, m.errors, m.requests)
+
+	names := make([]string, 0, len(m.operations))
+	for name := range m.operations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(&b, "\n  %-12s %9s %12s  %s\n", "Operation", "RPS", "Avg", "Latency")
+	for _, name := range names {
+		state := m.operations[name]
+		fmt.Fprintf(&b, "  %-12s %9.1f %12s  %s\n", name, state.rps, state.avg.Round(10*time.Microsecond), sparkline(state.latency))
+	}
+
+	if len(m.resources) > 0 {
+		fmt.Fprintf(&b, "\n  %-12s %9s %12s\n", "Resource", "CPU", "Memory")
+		for _, r := range m.resources {
+			fmt.Fprintf(&b, "  %-12s %8.1f%% %12s\n", r.Name, r.CPUPercent, r.Memory)
+		}
+	}
+
+	return b.String()
+}
+
+// totals sums requests and errors over all operations
+func totals(operations map[string]benchmark.LiveOperation) (requests, errors int64) {
+	for _, op := range operations {
+		requests += op.Requests
+		errors += op.Errors
+	}
+	return requests, errors
+}
+
+// sparkline scales values to the block heights relative to their maximum
+func sparkline(values []float64) string {
+	max := 0.0
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+
+	spark := make([]rune, len(values))
+	for i, v := range values {
+		level := 0
+		if max > 0 {
+			level = int(v / max * float64(len(sparkBlocks)-1))
+		}
+		spark[i] = sparkBlocks[level]
+	}
+	return string(spark)
+}
@@ -0,0 +1,91 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"mail-stress-test/benchmark"
+)
+
+// frame builds a Frame at offset seconds with cumulative create counters
+func frame(start time.Time, offset int, requests, errors int64, total time.Duration) Frame {
+	return Frame{
+		Time: start.Add(time.Duration(offset) * time.Second),
+		Operations: map[string]benchmark.LiveOperation{
+			"create": {Requests: requests, Errors: errors, TotalDuration: total},
+		},
+	}
+}
+
+// viewLine returns the first rendered line starting with prefix
+func viewLine(t *testing.T, view, prefix string) string {
+	t.Helper()
+	for _, line := range strings.Split(view, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), prefix) {
+			return line
+		}
+	}
+	t.Fatalf("no %q line in view:\n%s", prefix, view)
+	return ""
+}
+
+func TestModelUpdateRendersIntervals(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := NewModel(10)
+
+	// 10 requests at 5ms, an idle second, then 20 requests at 10ms
+	m.Update(frame(start, 0, 0, 0, 0))
+	m.Update(frame(start, 1, 10, 1, 50*time.Millisecond))
+	if line := viewLine(t, m.View(), "Errors"); !strings.Contains(line, "10.00%") || !strings.Contains(line, "(1 of 10 total)") {
+		t.Errorf("errors line after the first interval: %q", line)
+	}
+
+	m.Update(frame(start, 2, 10, 1, 50*time.Millisecond))
+	if line := viewLine(t, m.View(), "create"); !strings.Contains(line, " 0.0 ") || !strings.Contains(line, " 0s ") {
+		t.Errorf("idle interval should show no rate and no latency: %q", line)
+	}
+
+	m.Update(frame(start, 3, 30, 1, 250*time.Millisecond))
+	m.Update(Frame{
+		Time:       start.Add(3 * time.Second), // same instant: ignored apart from resources
+		Operations: frame(start, 3, 99, 99, time.Hour).Operations,
+		Resources:  []Resource{{Name: "mongodb", CPUPercent: 42.5, Memory: "61.2%"}},
+	})
+	view := m.View()
+
+	if line := viewLine(t, view, "📊"); !strings.Contains(line, "elapsed 3s") {
+		t.Errorf("header: %q", line)
+	}
+	if line := viewLine(t, view, "RPS"); !strings.Contains(line, "20.0") || !strings.HasSuffix(line, "▄▁█") {
+		t.Errorf("RPS line should show 20.0 and the 10/0/20 history: %q", line)
+	}
+	if line := viewLine(t, view, "Errors"); !strings.Contains(line, "0.00%") || !strings.Contains(line, "(1 of 30 total)") {
+		t.Errorf("errors line: %q", line)
+	}
+	// The idle interval must draw the lowest bar, not repeat the 5ms average
+	if line := viewLine(t, view, "create"); !strings.Contains(line, "20.0") || !strings.Contains(line, "10ms") || !strings.HasSuffix(line, "▄▁█") {
+		t.Errorf("create line should show 20.0 rps, 10ms and the 5/0/10ms latency history: %q", line)
+	}
+	if line := viewLine(t, view, "mongodb"); !strings.Contains(line, "42.5%") || !strings.Contains(line, "61.2%") {
+		t.Errorf("resource line: %q", line)
+	}
+}
+
+func TestModelHistoryIsBounded(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := NewModel(3)
+	for i := 0; i <= 6; i++ {
+		m.Update(frame(start, i, int64(i*i), 0, time.Duration(i*i)*time.Millisecond))
+	}
+
+	if got := len(m.rpsHistory); got != 3 {
+		t.Errorf("rps history holds %d intervals, want 3", got)
+	}
+	if got := m.rpsHistory; got[0] != 7 || got[2] != 11 {
+		t.Errorf("rps history %v, want the last three intervals 7, 9, 11", got)
+	}
+	if got := len(m.operations["create"].latency); got != 3 {
+		t.Errorf("latency history holds %d intervals, want 3", got)
+	}
+}